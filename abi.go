@@ -0,0 +1,138 @@
+package dashwasi
+
+import "strings"
+
+// ABIVersion is the version of the reactor export API described by ABI. It
+// changes when an export is removed or its signature changes.
+const ABIVersion = 1
+
+// ValueType is a WebAssembly value type, encoded as in the binary format.
+type ValueType byte
+
+// Value types used by the reactor exports.
+const (
+	ValueTypeI32 ValueType = 0x7f
+	ValueTypeI64 ValueType = 0x7e
+	ValueTypeF32 ValueType = 0x7d
+	ValueTypeF64 ValueType = 0x7c
+)
+
+// String returns the type name, such as "i32".
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeI32:
+		return "i32"
+	case ValueTypeI64:
+		return "i64"
+	case ValueTypeF32:
+		return "f32"
+	case ValueTypeF64:
+		return "f64"
+	default:
+		return "unknown"
+	}
+}
+
+// ExportFunc describes a function exported by the reactor.
+type ExportFunc struct {
+	// Name is the export name, such as ExportDashEval.
+	Name string
+	// Params and Results are the signature of the function.
+	Params  []ValueType
+	Results []ValueType
+	// Optional is set for exports a module may lack. Hosts check for them
+	// before use.
+	Optional bool
+}
+
+// String returns the signature, such as "dash_eval(i32, i32) -> i32".
+func (f ExportFunc) String() string {
+	return f.Name + Signature(f.Params, f.Results)
+}
+
+// Signature formats a function signature without a name, such as
+// "(i32, i32) -> i32".
+func Signature(params, results []ValueType) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	writeTypes(&b, params)
+	b.WriteByte(')')
+	if len(results) != 0 {
+		b.WriteString(" -> ")
+		if len(results) == 1 {
+			b.WriteString(results[0].String())
+		} else {
+			b.WriteByte('(')
+			writeTypes(&b, results)
+			b.WriteByte(')')
+		}
+	}
+	return b.String()
+}
+
+// writeTypes writes types separated by commas.
+func writeTypes(b *strings.Builder, types []ValueType) {
+	for i, t := range types {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(t.String())
+	}
+}
+
+// ABIDescriptor describes the exports of a dash reactor module.
+type ABIDescriptor struct {
+	// Version is the ABIVersion the descriptor describes.
+	Version int
+	// Funcs are the function exports.
+	Funcs []ExportFunc
+}
+
+// Func returns the function export named name.
+func (a *ABIDescriptor) Func(name string) (ExportFunc, bool) {
+	for _, f := range a.Funcs {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ExportFunc{}, false
+}
+
+// ABI describes the exports of the reactor, as documented by the Export
+// constants. Hosts validate custom builds against it when loading them.
+var ABI = ABIDescriptor{
+	Version: ABIVersion,
+	Funcs: []ExportFunc{
+		{Name: ExportMalloc, Params: i32s(1), Results: i32s(1)},
+		{Name: ExportFree, Params: i32s(1)},
+		{Name: ExportRealloc, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportCalloc, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashInit, Params: i32s(2), Results: i32s(1)},
+		{Name: ExportDashEval, Params: i32s(2), Results: i32s(1)},
+		{Name: ExportDashGetExitStatus, Results: i32s(1), Optional: true},
+		{Name: ExportDashGetVar, Params: i32s(1), Results: i32s(1), Optional: true},
+		{Name: ExportDashSetVar, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashListVars, Params: i32s(1), Results: i32s(1), Optional: true},
+		{Name: ExportDashUnsetVar, Params: i32s(1), Results: i32s(1), Optional: true},
+		{Name: ExportDashExportVar, Params: i32s(1), Results: i32s(1), Optional: true},
+		{Name: ExportDashSetVarReadOnly, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashListFuncs, Results: i32s(1), Optional: true},
+		{Name: ExportDashGetLastError, Results: i32s(1), Optional: true},
+		{Name: ExportDashSplitWords, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashCheckComplete, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashQuote, Params: i32s(1), Results: i32s(1), Optional: true},
+		{Name: ExportDashRunInteractive, Results: i32s(1), Optional: true},
+		{Name: ExportDashStackBounds, Results: []ValueType{ValueTypeI64}, Optional: true},
+		{Name: ExportDashParse, Params: i32s(2), Results: i32s(1), Optional: true},
+		{Name: ExportDashDestroy},
+	},
+}
+
+// i32s returns n i32 value types.
+func i32s(n int) []ValueType {
+	types := make([]ValueType, n)
+	for i := range types {
+		types[i] = ValueTypeI32
+	}
+	return types
+}
@@ -0,0 +1,28 @@
+package dashwasi
+
+import "testing"
+
+func TestABI(t *testing.T) {
+	for _, name := range []string{
+		ExportMalloc, ExportFree, ExportRealloc, ExportCalloc,
+		ExportDashInit, ExportDashEval, ExportDashGetExitStatus,
+		ExportDashGetVar, ExportDashSetVar, ExportDashListVars, ExportDashUnsetVar,
+		ExportDashExportVar, ExportDashSetVarReadOnly, ExportDashListFuncs,
+		ExportDashGetLastError, ExportDashSplitWords, ExportDashCheckComplete,
+		ExportDashQuote, ExportDashRunInteractive,
+		ExportDashStackBounds, ExportDashParse,
+		ExportDashDestroy,
+	} {
+		if _, ok := ABI.Func(name); !ok {
+			t.Errorf("ABI does not describe %s", name)
+		}
+	}
+	eval, _ := ABI.Func(ExportDashEval)
+	if got := eval.String(); got != "dash_eval(i32, i32) -> i32" {
+		t.Errorf("dash_eval signature %q", got)
+	}
+	destroy, _ := ABI.Func(ExportDashDestroy)
+	if got := destroy.String(); got != "dash_destroy()" {
+		t.Errorf("dash_destroy signature %q", got)
+	}
+}
@@ -60,7 +60,112 @@ const (
 	// Returns: 0 on success, -1 on error.
 	ExportDashSetVar = "dash_setvar"
 
+	// ExportDashListVars lists the set shell variables, or only the
+	// exported ones if flags has ListVarsExported.
+	// Optional: added by reactor/src/var.c, missing from older builds.
+	// Signature: dash_listvars(flags: i32) -> i32 (char*)
+	// Returns: a malloc'd list of NUL-terminated "name=value" entries
+	// ending with an empty entry, to be freed by the caller, or NULL on
+	// error.
+	ExportDashListVars = "dash_listvars"
+
+	// ExportDashUnsetVar unsets a shell variable.
+	// Optional: added by reactor/src/var.c, missing from older builds.
+	// Signature: dash_unsetvar(name: i32) -> i32
+	// Returns: 0 on success, -1 if the variable is read-only.
+	ExportDashUnsetVar = "dash_unsetvar"
+
+	// ExportDashExportVar marks a shell variable for export.
+	// Optional: added by reactor/src/var.c, missing from older builds.
+	// Signature: dash_exportvar(name: i32) -> i32
+	// Returns: 0 on success, -1 on error.
+	ExportDashExportVar = "dash_exportvar"
+
+	// ExportDashSetVarReadOnly sets a shell variable and makes it read-only.
+	// Optional: added by reactor/src/var.c, missing from older builds.
+	// Signature: dash_setvar_readonly(name: i32, value: i32) -> i32
+	// Returns: 0 on success, -1 if the variable is already read-only.
+	ExportDashSetVarReadOnly = "dash_setvar_readonly"
+
+	// ExportDashListFuncs lists the names of the defined shell functions.
+	// Optional: added by reactor/src/exec.c, missing from older builds.
+	// Signature: dash_listfuncs() -> i32 (char*)
+	// Returns: a malloc'd list of NUL-terminated names ending with an empty
+	// entry, to be freed by the caller, or NULL on error.
+	ExportDashListFuncs = "dash_listfuncs"
+
+	// ExportDashGetLastError returns the last syntax error and forgets it.
+	// Optional: added by reactor/src/parser.c, missing from older builds.
+	// Signature: dash_get_last_error() -> i32 (char*)
+	// Returns: a malloc'd "LINE:MESSAGE" string, such as `3:"fi" unexpected`,
+	// to be freed by the caller, or NULL if there was no syntax error since
+	// the last call.
+	ExportDashGetLastError = "dash_get_last_error"
+
+	// ExportDashSplitWords splits a command line into tokens with dash's
+	// lexer, without parsing it.
+	// Optional: added by reactor/src/parser.c, missing from older builds.
+	// Signature: dash_split_words(src: i32, len: i32) -> i32 (int32_t*)
+	// Returns: a malloc'd array of the token ends, each the byte offset past
+	// a token shifted left by one with the low bit set for operators,
+	// ending with -1, to be freed by the caller; or NULL on a syntax error,
+	// recorded for dash_get_last_error. Lexing stops after the newline
+	// ending a line with a here-document.
+	ExportDashSplitWords = "dash_split_words"
+
+	// ExportDashCheckComplete reports whether a script is complete rather
+	// than needing more lines, without executing it.
+	// Optional: added by reactor/src/parser.c, missing from older builds.
+	// Signature: dash_check_complete(src: i32, len: i32) -> i32
+	// Returns: 1 if the script is complete or fails to parse for another
+	// reason, 0 if it needs more lines.
+	ExportDashCheckComplete = "dash_check_complete"
+
+	// ExportDashQuote quotes a string for the shell as printf %q does.
+	// Optional: added by reactor/src/bltin/printf.c, missing from older
+	// builds, whose printf lacks %q.
+	// Signature: dash_quote(s: i32) -> i32 (char*)
+	// Returns: a malloc'd copy of s quoted, to be freed by the caller, or
+	// NULL if out of memory.
+	ExportDashQuote = "dash_quote"
+
+	// ExportDashRunInteractive runs dash's native interactive command loop
+	// against the module's stdio until EOF or exit.
+	// Optional: added by reactor/src/main.c, missing from older builds.
+	// Signature: dash_run_interactive() -> i32
+	// Returns: exit status of the shell.
+	ExportDashRunInteractive = "dash_run_interactive"
+
+	// ExportDashStackBounds reports the C stack region, for builds that do
+	// not export the __stack_pointer and __heap_base globals.
+	// Optional: only used when those globals are missing.
+	// Signature: dash_stack_bounds() -> i64
+	// Returns: the stack pointer of the caller in the low 32 bits and the
+	// top of the stack, which grows down, in the high 32 bits.
+	ExportDashStackBounds = "dash_stack_bounds"
+
+	// ExportDashParse parses a script without executing it.
+	// Optional: not present in older reactor builds.
+	// Signature: dash_parse(src: i32, len: i32) -> i32 (char*)
+	// Returns: a malloc'd NUL-terminated JSON document of dash's parse tree,
+	// to be freed by the caller, or NULL on a syntax error, which dash has
+	// reported on stderr. The document is {"tree": node or null}, where
+	// each node has a "type" named after dash's node types (cmd, pipe,
+	// redir, background, subshell, and, or, semi, if, while, until, for,
+	// case, defun, not) and the fields of that node type; words are the
+	// source text dash reconstructs for them as jobs does, with command
+	// substitutions elided to $(...), and here-documents have a "heredoc"
+	// body and no "target". Added by reactor/src/parser.c and read by
+	// Dash.Parse.
+	ExportDashParse = "dash_parse"
+
 	// ExportDashDestroy destroys the dash runtime.
 	// Signature: dash_destroy() -> void
 	ExportDashDestroy = "dash_destroy"
 )
+
+// Flags of ExportDashListVars.
+const (
+	// ListVarsExported lists only the exported variables.
+	ListVarsExported = 1 << iota
+)
@@ -0,0 +1,58 @@
+/*
+ * The %q conversion for the WASI reactor, appended to src/bltin/printf.c by
+ * update-dash.bash, which adds a case for it to printfcmd above.
+ */
+
+/* Whether c needs no quoting in any position of a word. */
+static int
+dash_wasi_safechar(int c)
+{
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+	       (c >= '0' && c <= '9') || (c && strchr("%+,-./:@_", c));
+}
+
+/*
+ * Return s quoted for the shell on the stack, as Quote in quote.go: words
+ * made of safe characters as is, others single-quoted, each embedded
+ * single quote closing the quotes around an escaped one.
+ */
+char *
+dash_wasi_quote(const char *s)
+{
+	const char *q;
+	char *p;
+
+	for (q = s; dash_wasi_safechar((unsigned char)*q); q++)
+		;
+	if (*s && !*q)
+		return (char *)s;
+	STARTSTACKSTR(p);
+	STPUTC('\'', p);
+	for (; *s; s++) {
+		if (*s == '\'') {
+			p = stputs("'\\''", p);
+			continue;
+		}
+		STPUTC(*s, p);
+	}
+	STPUTC('\'', p);
+	STPUTC('\0', p);
+	return grabstackstr(p);
+}
+
+/*
+ * Return a malloc'd copy of s quoted as by printf %q, or NULL if out of
+ * memory.
+ */
+__attribute__((export_name("dash_quote")))
+char *
+dash_quote(const char *s)
+{
+	struct stackmark smark;
+	char *q;
+
+	setstackmark(&smark);
+	q = strdup(dash_wasi_quote(s));
+	popstackmark(&smark);
+	return q;
+}
@@ -0,0 +1,36 @@
+/*
+ * Function listing for the WASI reactor, appended to src/exec.c by
+ * update-dash.bash.
+ */
+
+#include <stdlib.h>
+#include <string.h>
+
+/*
+ * Return a malloc'd list of the names of the defined functions, as
+ * NUL-terminated entries ending with an empty entry, or NULL if out of
+ * memory.
+ */
+__attribute__((export_name("dash_listfuncs")))
+char *
+dash_listfuncs(void)
+{
+	struct tblentry **pp, *cmdp;
+	size_t size = 1;
+	char *list, *p;
+
+	for (pp = cmdtable; pp < &cmdtable[CMDTABLESIZE]; pp++)
+		for (cmdp = *pp; cmdp; cmdp = cmdp->next)
+			if (cmdp->cmdtype == CMDFUNCTION)
+				size += strlen(cmdp->cmdname) + 1;
+	list = malloc(size);
+	if (list == NULL)
+		return NULL;
+	p = list;
+	for (pp = cmdtable; pp < &cmdtable[CMDTABLESIZE]; pp++)
+		for (cmdp = *pp; cmdp; cmdp = cmdp->next)
+			if (cmdp->cmdtype == CMDFUNCTION)
+				p = stpcpy(p, cmdp->cmdname) + 1;
+	*p = '\0';
+	return list;
+}
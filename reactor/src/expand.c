@@ -0,0 +1,33 @@
+/*
+ * The expansion size limit for the WASI reactor, appended to src/expand.c
+ * by update-dash.bash, which has memtodest above check each expansion
+ * result it extends with dash_wasi_checkexp.
+ *
+ * The limits are kept by the host, in the SizeLimits of the shell: the
+ * __size_check function of the env module returns 0 if size bytes are
+ * within the limit of kind, or the limit.
+ */
+
+#include <limits.h>
+
+__attribute__((import_module("env"), import_name("__size_check")))
+unsigned int __size_check(int kind, unsigned int size);
+
+/* The kind of __size_check for expansion results, as in limits.go. */
+#define DASH_WASI_SIZE_EXPANSION 0
+
+/*
+ * Fail the command if appending len bytes makes the expansion result
+ * being built at expdest longer than the limit.
+ */
+void
+dash_wasi_checkexp(size_t len)
+{
+	size_t size = expdest - (char *)stackblock() + len;
+	unsigned int limit;
+
+	limit = __size_check(DASH_WASI_SIZE_EXPANSION,
+			     size < UINT_MAX ? size : UINT_MAX);
+	if (limit)
+		sh_error("expansion result longer than %u bytes", limit);
+}
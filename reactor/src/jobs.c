@@ -0,0 +1,18 @@
+/*
+ * Word text for the WASI reactor, appended to src/jobs.c by
+ * update-dash.bash.
+ */
+
+/*
+ * Return the source text of the parsed word s, as jobs shows it, with
+ * command substitutions elided to $(...). The text is on the stack and
+ * valid until the next stack allocation.
+ */
+char *
+dash_wasi_wordtext(const char *s)
+{
+	STARTSTACKSTR(cmdnextc);
+	cmdputs(s);
+	STPUTC('\0', cmdnextc);
+	return stackblock();
+}
@@ -0,0 +1,51 @@
+
+/*
+ * Interactive command loop for the WASI reactor, appended to src/main.c by
+ * update-dash.bash.
+ */
+
+/*
+ * Run cmdloop as an interactive shell reading standard input, until end of
+ * file or exit, and return the exit status. Errors are reported and the
+ * loop reads on, as in main.
+ */
+__attribute__((export_name("dash_run_interactive")))
+int
+dash_run_interactive(void)
+{
+	struct jmploc jmploc;
+	struct jmploc *volatile savehandler = handler;
+	struct stackmark smark;
+	volatile int saveiflag = iflag;
+
+	setstackmark(&smark);
+	iflag = 1;
+	optschanged();
+	for (;;) {
+		if (!setjmp(jmploc.loc)) {
+			handler = &jmploc;
+			exitstatus = cmdloop(1);
+			break;
+		}
+		if (exception == EXEXIT || exception == EXEND) {
+			if (savestatus >= 0) {
+				exitstatus = savestatus;
+				savestatus = -1;
+			}
+			break;
+		}
+		handler = savehandler;
+		reset();
+		if (exception == EXINT)
+			out2c('\n');
+		else if (exception == EXERROR)
+			exitstatus = 2;
+		popstackmark(&smark);
+		FORCEINTON;
+	}
+	handler = savehandler;
+	popstackmark(&smark);
+	iflag = saveiflag;
+	optschanged();
+	return exitstatus;
+}
@@ -0,0 +1,103 @@
+/*
+ * read -t for the WASI reactor, appended to src/miscbltin.c by
+ * update-dash.bash, which renames readcmd above to dash_wasi_readcmd and
+ * points its option parsing and reads at dash_wasi_readopt and
+ * dash_wasi_read.
+ *
+ * WASI cannot wait for input with a timeout, so each read of a timed read
+ * first waits for the descriptor with the __fd_poll function of the env
+ * module, which returns 1 once it is readable and 0 on timeout, or -1 and
+ * stores the error in errno on failure.
+ */
+
+#include <errno.h>
+#include <limits.h>
+#include <stdlib.h>
+#include <time.h>
+
+__attribute__((import_module("env"), import_name("__fd_poll")))
+int __fd_poll(int fd, int timeout, int *err);
+
+/* The exit status of read -t on timeout, 128 plus SIGALRM like bash. */
+#define READ_TIMEOUT_STATUS 142
+
+/*
+ * Whether the running read has a timeout, its deadline in milliseconds,
+ * and whether it expired.
+ */
+static int readtimed;
+static long long readdeadline;
+static int readtimedout;
+
+/* The CLOCK_MONOTONIC time in milliseconds. */
+static long long
+dash_wasi_millis(void)
+{
+	struct timespec ts;
+
+	clock_gettime(CLOCK_MONOTONIC, &ts);
+	return (long long)ts.tv_sec * 1000 + ts.tv_nsec / 1000000;
+}
+
+/*
+ * nextopt for readcmd, also taking -t SECONDS, a decimal number of
+ * seconds, which sets the deadline of the read.
+ */
+int
+dash_wasi_readopt(void)
+{
+	char *end;
+	double secs;
+	int c;
+
+	while ((c = nextopt("p:rt:")) == 't') {
+		secs = strtod(optionarg, &end);
+		if (end == optionarg || *end || !(secs >= 0))
+			sh_error("%s: invalid timeout specification", optionarg);
+		readtimed = 1;
+		readdeadline = dash_wasi_millis() + (long long)(secs * 1000);
+	}
+	return c;
+}
+
+/*
+ * read for readcmd: with a timeout, wait for fd to be readable until the
+ * deadline first, and return 0, as at the end of input, if it expires.
+ */
+ssize_t
+dash_wasi_read(int fd, void *buf, size_t nbytes)
+{
+	long long left;
+
+	if (readtimed) {
+		left = readdeadline - dash_wasi_millis();
+		if (left < 0)
+			left = 0;
+		switch (__fd_poll(fd, left < INT_MAX ? (int)left : INT_MAX, &errno)) {
+		case 0:
+			readtimedout = 1;
+			return 0;
+		case -1:
+			return -1;
+		}
+	}
+	return read(fd, buf, nbytes);
+}
+
+/*
+ * The read builtin: readcmd above, failing with READ_TIMEOUT_STATUS if its
+ * timeout expired, after assigning the partial input.
+ */
+int
+readcmd(int argc, char **argv)
+{
+	int status;
+
+	readtimed = 0;
+	readtimedout = 0;
+	status = dash_wasi_readcmd(argc, argv);
+	if (readtimedout)
+		status = READ_TIMEOUT_STATUS;
+	readtimed = 0;
+	return status;
+}
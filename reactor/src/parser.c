@@ -0,0 +1,632 @@
+/*
+ * Parse trees and errors for the WASI reactor, appended to src/parser.c by
+ * update-dash.bash.
+ */
+
+#include <limits.h>
+#include <stdlib.h>
+#include <string.h>
+
+char *dash_wasi_wordtext(const char *);
+
+/* A malloc'd buffer holding a JSON document. */
+struct jsonbuf {
+	char *p;
+	size_t len;
+	size_t size;
+};
+
+static void jsonnode(struct jsonbuf *, union node *);
+
+static void
+jsonputn(struct jsonbuf *b, const char *s, size_t n)
+{
+	if (b->len + n >= b->size) {
+		b->size = (b->len + n + 1) * 2;
+		b->p = ckrealloc(b->p, b->size);
+	}
+	memcpy(b->p + b->len, s, n);
+	b->len += n;
+	b->p[b->len] = '\0';
+}
+
+static void
+jsonputs(struct jsonbuf *b, const char *s)
+{
+	jsonputn(b, s, strlen(s));
+}
+
+static void
+jsonstr(struct jsonbuf *b, const char *s)
+{
+	static const char hex[] = "0123456789abcdef";
+	char esc[] = "\\u0000";
+
+	jsonputs(b, "\"");
+	for (; *s; s++) {
+		unsigned char c = *s;
+
+		if (c == '"' || c == '\\') {
+			esc[1] = c;
+			jsonputn(b, esc, 2);
+			esc[1] = 'u';
+		} else if (c < 0x20) {
+			esc[4] = hex[c >> 4];
+			esc[5] = hex[c & 0xf];
+			jsonputs(b, esc);
+		} else
+			jsonputn(b, s, 1);
+	}
+	jsonputs(b, "\"");
+}
+
+static void
+jsonint(struct jsonbuf *b, int n)
+{
+	char buf[12];
+	char *p = buf + sizeof(buf);
+	unsigned int u = n < 0 ? -(unsigned int)n : n;
+
+	*--p = '\0';
+	do
+		*--p = '0' + u % 10;
+	while ((u /= 10) != 0);
+	if (n < 0)
+		*--p = '-';
+	jsonputs(b, p);
+}
+
+static void
+jsonkey(struct jsonbuf *b, const char *key)
+{
+	jsonputs(b, ",\"");
+	jsonputs(b, key);
+	jsonputs(b, "\":");
+}
+
+/* Start the object of a node of the given type, with its line if known. */
+static void
+jsonhead(struct jsonbuf *b, const char *type, int linno)
+{
+	jsonputs(b, "{\"type\":");
+	jsonstr(b, type);
+	if (linno > 0) {
+		jsonkey(b, "line");
+		jsonint(b, linno);
+	}
+}
+
+static void
+jsonword(struct jsonbuf *b, union node *n)
+{
+	jsonstr(b, dash_wasi_wordtext(n->narg.text));
+}
+
+static void
+jsonwords(struct jsonbuf *b, union node *n)
+{
+	const char *sep = "[";
+
+	for (; n; n = n->narg.next) {
+		jsonputs(b, sep);
+		sep = ",";
+		jsonword(b, n);
+	}
+	jsonputs(b, *sep == '[' ? "[]" : "]");
+}
+
+static void
+jsonredirs(struct jsonbuf *b, union node *n)
+{
+	const char *sep = "[";
+
+	if (n == NULL)
+		return;
+	jsonkey(b, "redirect");
+	for (; n; n = n->nfile.next) {
+		const char *op;
+
+		switch (n->type) {
+		case NTO:	op = ">"; break;
+		case NCLOBBER:	op = ">|"; break;
+		case NFROM:	op = "<"; break;
+		case NFROMTO:	op = "<>"; break;
+		case NAPPEND:	op = ">>"; break;
+		case NTOFD:	op = ">&"; break;
+		case NFROMFD:	op = "<&"; break;
+		default:	op = "<<"; break;
+		}
+		jsonputs(b, sep);
+		sep = ",";
+		jsonputs(b, "{\"op\":");
+		jsonstr(b, op);
+		jsonkey(b, "fd");
+		jsonint(b, n->nfile.fd);
+		switch (n->type) {
+		case NTOFD:
+		case NFROMFD:
+			jsonkey(b, "target");
+			if (n->ndup.vname)
+				jsonword(b, n->ndup.vname);
+			else if (n->ndup.dupfd < 0)
+				jsonstr(b, "-");
+			else {
+				jsonputs(b, "\"");
+				jsonint(b, n->ndup.dupfd);
+				jsonputs(b, "\"");
+			}
+			break;
+		case NHERE:
+		case NXHERE:
+			if (n->nhere.doc) {
+				jsonkey(b, "heredoc");
+				jsonword(b, n->nhere.doc);
+			}
+			break;
+		default:
+			jsonkey(b, "target");
+			jsonword(b, n->nfile.fname);
+			break;
+		}
+		jsonputs(b, "}");
+	}
+	jsonputs(b, "]");
+}
+
+/* The line of the first command of n, for nodes not recording one. */
+static int
+nodelinno(union node *n)
+{
+	while (n) {
+		switch (n->type) {
+		case NCMD:
+			return n->ncmd.linno;
+		case NREDIR:
+		case NSUBSHELL:
+			return n->nredir.linno;
+		case NFOR:
+			return n->nfor.linno;
+		case NCASE:
+			return n->ncase.linno;
+		case NDEFUN:
+			return n->ndefun.linno;
+		case NPIPE:
+			n = n->npipe.cmdlist->n;
+			break;
+		case NBACKGND:
+			n = n->nredir.n;
+			break;
+		case NAND:
+		case NOR:
+		case NSEMI:
+		case NWHILE:
+		case NUNTIL:
+			n = n->nbinary.ch1;
+			break;
+		case NIF:
+			n = n->nif.test;
+			break;
+		case NNOT:
+			n = n->nnot.com;
+			break;
+		default:
+			return 0;
+		}
+	}
+	return 0;
+}
+
+static void
+jsonnode(struct jsonbuf *b, union node *n)
+{
+	static const char *const binary[] = {
+		[NAND] = "and", [NOR] = "or", [NSEMI] = "semi",
+		[NWHILE] = "while", [NUNTIL] = "until",
+	};
+	struct nodelist *lp;
+	const char *sep;
+
+	if (n == NULL) {
+		jsonputs(b, "null");
+		return;
+	}
+	switch (n->type) {
+	case NCMD:
+		jsonhead(b, "cmd", n->ncmd.linno);
+		if (n->ncmd.assign) {
+			jsonkey(b, "assign");
+			jsonwords(b, n->ncmd.assign);
+		}
+		jsonkey(b, "args");
+		jsonwords(b, n->ncmd.args);
+		jsonredirs(b, n->ncmd.redirect);
+		break;
+	case NPIPE:
+		/* dash marks a pipeline run in the background in place. */
+		if (n->npipe.backgnd)
+			jsonputs(b, "{\"type\":\"background\",\"body\":");
+		jsonhead(b, "pipe", 0);
+		jsonkey(b, "cmds");
+		sep = "[";
+		for (lp = n->npipe.cmdlist; lp; lp = lp->next) {
+			jsonputs(b, sep);
+			sep = ",";
+			jsonnode(b, lp->n);
+		}
+		jsonputs(b, "]");
+		if (n->npipe.backgnd)
+			jsonputs(b, "}");
+		break;
+	case NBACKGND:
+		/*
+		 * A redirected compound command run in the background is its
+		 * redir node retyped.
+		 */
+		jsonhead(b, "background", 0);
+		jsonkey(b, "body");
+		if (n->nredir.redirect) {
+			jsonhead(b, "redir", n->nredir.linno);
+			jsonredirs(b, n->nredir.redirect);
+			jsonkey(b, "body");
+			jsonnode(b, n->nredir.n);
+			jsonputs(b, "}");
+		} else
+			jsonnode(b, n->nredir.n);
+		break;
+	case NREDIR:
+	case NSUBSHELL:
+		jsonhead(b, n->type == NREDIR ? "redir" : "subshell",
+			 n->nredir.linno);
+		jsonredirs(b, n->nredir.redirect);
+		jsonkey(b, "body");
+		jsonnode(b, n->nredir.n);
+		break;
+	case NAND:
+	case NOR:
+	case NSEMI:
+		jsonhead(b, binary[n->type], 0);
+		jsonkey(b, "left");
+		jsonnode(b, n->nbinary.ch1);
+		jsonkey(b, "right");
+		jsonnode(b, n->nbinary.ch2);
+		break;
+	case NWHILE:
+	case NUNTIL:
+		jsonhead(b, binary[n->type], nodelinno(n));
+		jsonkey(b, "test");
+		jsonnode(b, n->nbinary.ch1);
+		jsonkey(b, "body");
+		jsonnode(b, n->nbinary.ch2);
+		break;
+	case NIF:
+		jsonhead(b, "if", nodelinno(n));
+		jsonkey(b, "test");
+		jsonnode(b, n->nif.test);
+		jsonkey(b, "body");
+		jsonnode(b, n->nif.ifpart);
+		if (n->nif.elsepart) {
+			jsonkey(b, "else");
+			jsonnode(b, n->nif.elsepart);
+		}
+		break;
+	case NFOR:
+		jsonhead(b, "for", n->nfor.linno);
+		jsonkey(b, "var");
+		jsonstr(b, n->nfor.var);
+		jsonkey(b, "args");
+		jsonwords(b, n->nfor.args);
+		jsonkey(b, "body");
+		jsonnode(b, n->nfor.body);
+		break;
+	case NCASE:
+		jsonhead(b, "case", n->ncase.linno);
+		jsonkey(b, "word");
+		jsonword(b, n->ncase.expr);
+		jsonkey(b, "items");
+		sep = "[";
+		for (n = n->ncase.cases; n; n = n->nclist.next) {
+			jsonputs(b, sep);
+			sep = ",";
+			jsonputs(b, "{\"patterns\":");
+			jsonwords(b, n->nclist.pattern);
+			jsonkey(b, "body");
+			jsonnode(b, n->nclist.body);
+			jsonputs(b, "}");
+		}
+		jsonputs(b, *sep == '[' ? "[]" : "]");
+		break;
+	case NDEFUN:
+		jsonhead(b, "defun", n->ndefun.linno);
+		jsonkey(b, "name");
+		jsonstr(b, n->ndefun.text);
+		jsonkey(b, "body");
+		jsonnode(b, n->ndefun.body);
+		break;
+	case NNOT:
+		jsonhead(b, "not", 0);
+		jsonkey(b, "body");
+		jsonnode(b, n->nnot.com);
+		break;
+	default:
+		/* Rejected by the host. */
+		jsonhead(b, "unknown", 0);
+		break;
+	}
+	jsonputs(b, "}");
+}
+
+/*
+ * Parse the len bytes at src as a script, without executing it, and
+ * return a malloc'd JSON document of its parse tree, {"tree": node}, with
+ * the lines of the script joined by semi nodes. Return NULL on a syntax
+ * error, which has been reported on stderr.
+ */
+__attribute__((export_name("dash_parse")))
+char *
+dash_parse(const char *src, int len)
+{
+	struct jmploc jmploc;
+	struct jmploc *volatile savehandler = handler;
+	volatile int savesuppressint = suppressint;
+	struct stackmark smark;
+	struct jsonbuf b = { NULL, 0, 0 };
+	char *s;
+	volatile int pushed = 0;
+	union node *n;
+	int depth = 0;
+
+	s = ckmalloc(len + 1);
+	memcpy(s, src, len);
+	s[len] = '\0';
+	setstackmark(&smark);
+	if (setjmp(jmploc.loc)) {
+		handler = savehandler;
+		if (pushed)
+			popfile();
+		tokpushback = 0;
+		checkkwd = 0;
+		heredoclist = NULL;
+		popstackmark(&smark);
+		free(b.p);
+		free(s);
+		suppressint = savesuppressint;
+		return NULL;
+	}
+	handler = &jmploc;
+	setinputstring(s);
+	pushed = 1;
+	plinno = 1;
+
+	jsonputs(&b, "{\"tree\":");
+	while ((n = parsecmd(0)) != NEOF) {
+		if (n == NULL)
+			continue;
+		jsonputs(&b, "{\"type\":\"semi\",\"left\":");
+		jsonnode(&b, n);
+		jsonputs(&b, ",\"right\":");
+		depth++;
+		popstackmark(&smark);
+	}
+	jsonputs(&b, "null");
+	while (depth--)
+		jsonputs(&b, "}");
+	jsonputs(&b, "}");
+
+	popfile();
+	handler = savehandler;
+	popstackmark(&smark);
+	free(s);
+	return b.p;
+}
+
+/* The last syntax error, as "LINE:MESSAGE", for dash_get_last_error. */
+static char lasterror[128];
+
+/* Record the syntax error msg at the current line. Called by synerror. */
+void
+dash_wasi_set_last_error(const char *msg)
+{
+	fmtstr(lasterror, sizeof(lasterror), "%d:%s", plinno, msg);
+}
+
+/*
+ * Return a malloc'd copy of the last syntax error, as "LINE:MESSAGE", and
+ * forget it, or NULL if there was none since the last call.
+ */
+__attribute__((export_name("dash_get_last_error")))
+char *
+dash_get_last_error(void)
+{
+	char *err;
+
+	if (*lasterror == '\0')
+		return NULL;
+	err = strdup(lasterror);
+	if (err)
+		*lasterror = '\0';
+	return err;
+}
+
+/* The offset in s of the input position of the lexer, which reads s. */
+static int
+lexoffset(const char *s)
+{
+	int off = parsefile->nextc - s;
+	int i;
+
+	for (i = 0; i < parsefile->unget; i++)
+		if (parsefile->lastc[i] != PEOF)
+			off--;
+	return off;
+}
+
+/*
+ * Split the len bytes at src into tokens with the lexer, without parsing
+ * them, and return a malloc'd array of their ends, each the offset past a
+ * token shifted left by one, with the low bit set for operators, ending
+ * with -1. Keywords are words and aliases are not expanded. Lexing stops
+ * after the newline ending a line with a here-document, whose body is not
+ * split. Return NULL on a syntax error, which has been reported on stderr
+ * and recorded for dash_get_last_error.
+ */
+__attribute__((export_name("dash_split_words")))
+int *
+dash_split_words(const char *src, int len)
+{
+	struct jmploc jmploc;
+	struct jmploc *volatile savehandler = handler;
+	volatile int savesuppressint = suppressint;
+	struct stackmark smark;
+	int *volatile ends = NULL;
+	volatile size_t n = 0;
+	size_t size = 0;
+	char *s;
+	volatile int pushed = 0;
+	int inheredoc = 0;
+	int t;
+
+	s = ckmalloc(len + 1);
+	memcpy(s, src, len);
+	s[len] = '\0';
+	setstackmark(&smark);
+	if (setjmp(jmploc.loc)) {
+		handler = savehandler;
+		if (pushed)
+			popfile();
+		tokpushback = 0;
+		checkkwd = 0;
+		popstackmark(&smark);
+		free(ends);
+		free(s);
+		suppressint = savesuppressint;
+		return NULL;
+	}
+	handler = &jmploc;
+	setinputstring(s);
+	pushed = 1;
+
+	ends = ckmalloc((size = 16) * sizeof(*ends));
+	for (;;) {
+		checkkwd = 0;
+		t = readtoken();
+		if (t == TEOF)
+			break;
+		if (n + 2 > size)
+			ends = ckrealloc(ends, (size *= 2) * sizeof(*ends));
+		ends[n++] = lexoffset(s) << 1 | (t != TWORD);
+		if (t == TREDIR &&
+		    (redirnode->type == NHERE || redirnode->type == NXHERE))
+			inheredoc = 1;
+		else if (t == TNL && inheredoc)
+			break;
+	}
+	ends[n] = -1;
+
+	popfile();
+	handler = savehandler;
+	popstackmark(&smark);
+	free(s);
+	return ends;
+}
+
+/* The command dash_check_complete appends to the script it checks. */
+#define COMPLETE_SENTINEL "__dash_wasi_complete"
+
+/* Whether n is the command COMPLETE_SENTINEL alone. */
+static int
+issentinel(union node *n)
+{
+	return n && n->type == NCMD && !n->ncmd.assign &&
+	       !n->ncmd.redirect && n->ncmd.args &&
+	       !n->ncmd.args->narg.next &&
+	       !strcmp(n->ncmd.args->narg.text, COMPLETE_SENTINEL);
+}
+
+/*
+ * Report whether the len bytes at src are a complete script rather than
+ * one needing more lines, without executing it. The script is parsed
+ * with a command line appended: the script is incomplete if that line is
+ * read into its last command, by an open compound command, quote, here
+ * document, line continuation or operator, or the parser fails reading
+ * it. Other syntax errors make a complete script; they have been
+ * reported on stderr and are not recorded for dash_get_last_error.
+ * Return 1 if complete, 0 if not.
+ */
+__attribute__((export_name("dash_check_complete")))
+int
+dash_check_complete(const char *src, int len)
+{
+	struct jmploc jmploc;
+	struct jmploc *volatile savehandler = handler;
+	volatile int savesuppressint = suppressint;
+	struct stackmark smark;
+	char *s;
+	volatile int pushed = 0;
+	union node *n;
+	int last = 0;
+
+	s = ckmalloc(len + sizeof("\n" COMPLETE_SENTINEL "\n"));
+	memcpy(s, src, len);
+	strcpy(s + len, "\n" COMPLETE_SENTINEL "\n");
+	setstackmark(&smark);
+	if (setjmp(jmploc.loc)) {
+		/* Past the newline ending the script, into the appended line. */
+		int complete = !pushed || lexoffset(s) <= len + 1;
+
+		handler = savehandler;
+		if (pushed)
+			popfile();
+		tokpushback = 0;
+		checkkwd = 0;
+		heredoclist = NULL;
+		*lasterror = '\0';
+		popstackmark(&smark);
+		free(s);
+		suppressint = savesuppressint;
+		return complete;
+	}
+	handler = &jmploc;
+	setinputstring(s);
+	pushed = 1;
+	plinno = 1;
+
+	while ((n = parsecmd(0)) != NEOF) {
+		if (n == NULL)
+			continue;
+		last = issentinel(n);
+		popstackmark(&smark);
+	}
+
+	popfile();
+	handler = savehandler;
+	popstackmark(&smark);
+	free(s);
+	return last;
+}
+
+__attribute__((import_module("env"), import_name("__size_check")))
+unsigned int __size_check(int kind, unsigned int size);
+
+/* The kind of __size_check for here-documents, as in limits.go. */
+#define DASH_WASI_SIZE_HEREDOC 1
+
+/*
+ * Fail with a syntax error if the here-document body just read into
+ * text is longer than the limit, see reactor/src/expand.c. Called by
+ * parseheredoc.
+ */
+void
+dash_wasi_checkheredoc(const char *text)
+{
+	size_t size = strlen(text);
+	unsigned int limit;
+	char msg[64];
+
+	limit = __size_check(DASH_WASI_SIZE_HEREDOC,
+			     size < UINT_MAX ? size : UINT_MAX);
+	if (limit) {
+		fmtstr(msg, sizeof(msg), "here-document longer than %u bytes",
+		       limit);
+		synerror(msg);
+	}
+}
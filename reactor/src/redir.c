@@ -0,0 +1,29 @@
+
+/*
+ * Descriptor duplication for the WASI reactor, appended to src/redir.c by
+ * update-dash.bash, which points the dup2 and fcntl(F_DUPFD) calls above
+ * at these functions.
+ *
+ * WASI has no call duplicating a file descriptor: wasi-libc has no dup2
+ * and its fcntl rejects F_DUPFD. The host keeps a table mapping the
+ * shell's descriptors to the WASI ones and implements both with the
+ * __fd_dup and __fd_dup2 functions of the env module. They return -1 and
+ * store the error in errno on failure.
+ */
+
+__attribute__((import_module("env"), import_name("__fd_dup")))
+int __fd_dup(int fd, int minfd, int *err);
+__attribute__((import_module("env"), import_name("__fd_dup2")))
+int __fd_dup2(int fd, int to, int *err);
+
+int
+dash_wasi_dupfd(int fd, int minfd)
+{
+	return __fd_dup(fd, minfd, &errno);
+}
+
+int
+dash_wasi_dup2(int fd, int to)
+{
+	return __fd_dup2(fd, to, &errno);
+}
@@ -0,0 +1,88 @@
+/*
+ * Variable access for the WASI reactor, appended to src/var.c by
+ * update-dash.bash.
+ */
+
+#include <stdlib.h>
+#include <string.h>
+
+/* Flags of dash_listvars, as ListVarsExported in embed.go. */
+#define DASH_WASI_LISTVARS_EXPORTED 1
+
+/*
+ * Return a malloc'd list of the set variables, or only the exported ones,
+ * as NUL-terminated "name=value" entries ending with an empty entry, or
+ * NULL if out of memory.
+ */
+__attribute__((export_name("dash_listvars")))
+char *
+dash_listvars(int flags)
+{
+	struct stackmark smark;
+	char **vars, **end, **vp;
+	size_t size = 1;
+	char *list, *p;
+
+	setstackmark(&smark);
+	vars = listvars(flags & DASH_WASI_LISTVARS_EXPORTED ? VEXPORT : 0,
+			VUNSET, &end);
+	for (vp = vars; vp < end; vp++)
+		size += strlen(*vp) + 1;
+	list = malloc(size);
+	if (list) {
+		p = list;
+		for (vp = vars; vp < end; vp++)
+			p = stpcpy(p, *vp) + 1;
+		*p = '\0';
+	}
+	popstackmark(&smark);
+	return list;
+}
+
+/* Unset the variable name. Return -1 if it is read-only. */
+__attribute__((export_name("dash_unsetvar")))
+int
+dash_unsetvar(const char *name)
+{
+	struct var *vp = *findvar(hashvar(name), name);
+
+	if (vp == NULL)
+		return 0;
+	if (vp->flags & VREADONLY)
+		return -1;
+	unsetvar(name);
+	return 0;
+}
+
+/*
+ * Mark the variable name for export, as export does, creating it unset if
+ * it does not exist.
+ */
+__attribute__((export_name("dash_exportvar")))
+int
+dash_exportvar(const char *name)
+{
+	struct var *vp = *findvar(hashvar(name), name);
+
+	if (vp)
+		vp->flags |= VEXPORT;
+	else
+		setvar(name, NULL, VEXPORT);
+	return 0;
+}
+
+/*
+ * Set the variable name to value and make it read-only. Return -1 if it
+ * is read-only already.
+ */
+__attribute__((export_name("dash_setvar_readonly")))
+int
+dash_setvar_readonly(const char *name, const char *value)
+{
+	struct var *vp = *findvar(hashvar(name), name);
+
+	if (vp && vp->flags & VREADONLY)
+		return -1;
+	setvar(name, value, VREADONLY);
+	return 0;
+}
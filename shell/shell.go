@@ -0,0 +1,65 @@
+// Package shell defines the interface shared by POSIX shell WASI reactors.
+//
+// Host tooling written against Interpreter works with any reactor that
+// implements it, such as the dash reactor in the wazero-dash package. It is
+// the only one so far: a busybox ash reactor in a sibling package is
+// deferred until ash has a WASI reactor build.
+package shell
+
+import "context"
+
+// Interpreter is a re-entrant POSIX shell running in a WASI reactor.
+//
+// Shell state (variables, functions, aliases, exit status) persists between
+// Eval calls until Close.
+type Interpreter interface {
+	// Init initializes the shell runtime. Must be called before Eval.
+	// Pass nil args for default initialization.
+	Init(ctx context.Context, args []string) error
+
+	// Eval evaluates a shell command string and returns the exit status
+	// of the last command.
+	Eval(ctx context.Context, cmd string) (int, error)
+
+	// GetVar returns the value of a shell variable, or empty string if unset.
+	GetVar(ctx context.Context, name string) (string, error)
+
+	// SetVar sets a shell variable.
+	SetVar(ctx context.Context, name, value string) error
+
+	// Close destroys the shell runtime and releases resources.
+	Close(ctx context.Context) error
+
+	// Capabilities reports the features supported by this interpreter.
+	Capabilities() Capabilities
+}
+
+// Capabilities describes the features an Interpreter supports.
+//
+// Optional features depend on the reactor build; callers should check them
+// before relying on the corresponding methods.
+type Capabilities struct {
+	// Name identifies the shell implementation, e.g. "dash".
+	Name string
+
+	// Version is the upstream shell version the reactor was built from.
+	Version string
+
+	// ExitStatus reports whether the exit status of the last command can be
+	// queried separately from Eval.
+	ExitStatus bool
+
+	// Vars reports whether GetVar and SetVar are supported.
+	Vars bool
+
+	// Interactive reports whether the shell can run its native interactive
+	// command loop.
+	Interactive bool
+
+	// HostCalls reports whether scripts can invoke registered host handlers.
+	HostCalls bool
+
+	// ExecHandler reports whether external commands are dispatched to the
+	// host.
+	ExecHandler bool
+}
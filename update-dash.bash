@@ -44,13 +44,98 @@ UPSTREAM_VERSION="$(grep 'PACKAGE_VERSION' "$DASH_DIR/config-wasi.h" | head -1 |
 
 echo "Dash commit: $SHORT ($UPSTREAM_VERSION)"
 
+# Build from a copy of the checkout with the host integration in reactor/:
+# each reactor/src/NAME.c, NAME possibly in a directory such as bltin/, is
+# appended to src/NAME.c, where it can use the file's static definitions,
+# and the calls it replaces are pointed at it.
+echo "Applying reactor sources..."
+WORK_DIR="$(mktemp -d)"
+trap 'rm -rf "$WORK_DIR"' EXIT
+cp -a "$DASH_DIR/." "$WORK_DIR/"
+rm -rf "$WORK_DIR/build-wasi"
+
+for SRC in "$SCRIPT_DIR"/reactor/src/*.c "$SCRIPT_DIR"/reactor/src/*/*.c; do
+    NAME="${SRC#"$SCRIPT_DIR/reactor/src/"}"
+    TARGET="$WORK_DIR/src/$NAME"
+    if [ ! -f "$TARGET" ]; then
+        echo "Error: reactor/src/$NAME has no src/$NAME to extend"
+        exit 1
+    fi
+    case "$NAME" in
+    redir.c)
+        # WASI cannot duplicate descriptors; the host does, see
+        # reactor/src/redir.c.
+        perl -pi -e 's/\bdup2\(/dash_wasi_dup2(/g; s/\bfcntl\(([^,]*), F_DUPFD(?:_CLOEXEC)?, /dash_wasi_dupfd($1, /g' "$TARGET"
+        if ! grep -q 'dash_wasi_dup2(' "$TARGET" || ! grep -q 'dash_wasi_dupfd(' "$TARGET"; then
+            echo "Error: no dup2 and fcntl(F_DUPFD) calls found in src/redir.c"
+            exit 1
+        fi
+        printf 'int dash_wasi_dupfd(int, int);\nint dash_wasi_dup2(int, int);\n' |
+            cat - "$TARGET" > "$TARGET.new"
+        mv "$TARGET.new" "$TARGET"
+        ;;
+    parser.c)
+        # Record syntax errors for dash_get_last_error and check the size
+        # of here-documents, see reactor/src/parser.c.
+        perl -0pi -e 's/(\bsynerror\(const char \*msg\)\s*\{)/$1\n\tdash_wasi_set_last_error(msg);/; s/(\breadtoken1\([^;]*here->eofmark[^;]*\);)/$1\n\t\tdash_wasi_checkheredoc(wordtext);/' "$TARGET"
+        if ! grep -q 'dash_wasi_set_last_error(msg)' "$TARGET"; then
+            echo "Error: no synerror definition found in src/parser.c"
+            exit 1
+        fi
+        if ! grep -q 'dash_wasi_checkheredoc(wordtext)' "$TARGET"; then
+            echo "Error: no here-document read found in src/parser.c"
+            exit 1
+        fi
+        printf 'void dash_wasi_set_last_error(const char *);\nvoid dash_wasi_checkheredoc(const char *);\n' |
+            cat - "$TARGET" > "$TARGET.new"
+        mv "$TARGET.new" "$TARGET"
+        ;;
+    expand.c)
+        # Check the size of expansion results, see reactor/src/expand.c.
+        perl -0pi -e 's/(\bmemtodest\(const char \*p, size_t len, int flags\)\s*\{.*?\n)([ \t]*q = makestrspace\()/$1\tdash_wasi_checkexp(len);\n$2/s' "$TARGET"
+        if ! grep -q 'dash_wasi_checkexp(len)' "$TARGET"; then
+            echo "Error: no memtodest definition found in src/expand.c"
+            exit 1
+        fi
+        printf '#include <stddef.h>\nvoid dash_wasi_checkexp(size_t);\n' |
+            cat - "$TARGET" > "$TARGET.new"
+        mv "$TARGET.new" "$TARGET"
+        ;;
+    miscbltin.c)
+        # Add read -t, see reactor/src/miscbltin.c.
+        perl -0pi -e 's/^readcmd\(int argc, char \*\*argv\)$/dash_wasi_readcmd(int argc, char **argv)/m; s/\bnextopt\("p:r"\)/dash_wasi_readopt()/; s/\bread\(0, /dash_wasi_read(0, /g' "$TARGET"
+        if ! grep -q '^dash_wasi_readcmd(' "$TARGET" || ! grep -q 'dash_wasi_readopt()' "$TARGET" ||
+            ! grep -q 'dash_wasi_read(0, ' "$TARGET"; then
+            echo "Error: no readcmd with p:r options and read(0, ...) calls found in src/miscbltin.c"
+            exit 1
+        fi
+        printf '#include <sys/types.h>\nint dash_wasi_readopt(void);\nssize_t dash_wasi_read(int, void *, size_t);\n' |
+            cat - "$TARGET" > "$TARGET.new"
+        mv "$TARGET.new" "$TARGET"
+        ;;
+    bltin/printf.c)
+        # Add the %q conversion, see reactor/src/bltin/printf.c: it is
+        # formatted as %s with the quoted argument.
+        perl -0pi -e 's/^([ \t]*)(case .s.:)/$1case \x27q\x27: {\n$1\tchar *p = dash_wasi_quote(getstr());\n$1\t*fmt = \x27s\x27;\n$1\tPF(start, p);\n$1\t*fmt = \x27q\x27;\n$1\tbreak;\n$1}\n$1$2/m' "$TARGET"
+        if ! grep -q 'dash_wasi_quote(getstr())' "$TARGET"; then
+            echo "Error: no %s conversion found in src/bltin/printf.c"
+            exit 1
+        fi
+        printf 'char *dash_wasi_quote(const char *);\n' |
+            cat - "$TARGET" > "$TARGET.new"
+        mv "$TARGET.new" "$TARGET"
+        ;;
+    esac
+    cat "$SRC" >> "$TARGET"
+done
+
 # Build WASM reactor binary.
 echo "Building WASI reactor..."
-BUILD_DIR="$DASH_DIR/build-wasi"
+BUILD_DIR="$WORK_DIR/build-wasi"
 mkdir -p "$BUILD_DIR"
 cd "$BUILD_DIR"
 
-cmake "$DASH_DIR" \
+cmake "$WORK_DIR" \
     -DCMAKE_SYSTEM_NAME=WASI \
     -DCMAKE_C_COMPILER="$WASI_SDK/bin/clang" \
     -DCMAKE_SYSROOT="$WASI_SDK/share/wasi-sysroot" \
@@ -69,6 +154,19 @@ fi
 cp "$BUILD_DIR/dash.wasm" "$SCRIPT_DIR/dash.wasm"
 echo "Copied dash.wasm ($(wc -c < "$SCRIPT_DIR/dash.wasm" | tr -d ' ') bytes)"
 
+# Record the version in a custom section, checked by verify-reactor.
+SECTION_NAME="dash.version"
+SECTION_DATA="$UPSTREAM_VERSION $COMMIT"
+SECTION_SIZE=$((1 + ${#SECTION_NAME} + ${#SECTION_DATA}))
+if [ "$SECTION_SIZE" -ge 128 ]; then
+    echo "Error: version section too large"
+    exit 1
+fi
+printf "\\x00\\x$(printf %02x "$SECTION_SIZE")\\x$(printf %02x "${#SECTION_NAME}")%s%s" \
+    "$SECTION_NAME" "$SECTION_DATA" >> "$SCRIPT_DIR/dash.wasm"
+SHA256="$(sha256sum "$SCRIPT_DIR/dash.wasm" 2>/dev/null || shasum -a 256 "$SCRIPT_DIR/dash.wasm")"
+SHA256="${SHA256%% *}"
+
 # Generate version info Go file.
 echo "Generating version.go..."
 cat > "$SCRIPT_DIR/version.go" << EOF
@@ -84,9 +182,14 @@ const (
 
 	// SourceURL is the repository URL for the dash fork.
 	SourceURL = "https://github.com/$REPO"
+
+	// SHA256 is the hex SHA-256 of dash.wasm.
+	SHA256 = "$SHA256"
 )
 EOF
 
 echo "Generated version.go (dash $UPSTREAM_VERSION, commit $SHORT)"
 echo ""
 echo "Update complete!"
+echo "Review conformance changes and refresh the goldens with:"
+echo "  go test ./wazero-dash/conformance -update"
@@ -10,4 +10,7 @@ const (
 
 	// SourceURL is the repository URL for the dash fork.
 	SourceURL = "https://github.com/aperturerobotics/dash"
+
+	// SHA256 is the hex SHA-256 of dash.wasm.
+	SHA256 = "508f6d13da5603c436820499dfe4f22b514110c0b81ef4394e0fbc1516cb0b23"
 )
@@ -0,0 +1,62 @@
+package dashwasi
+
+import "strings"
+
+// VersionSection is the name of the custom section in which update-dash.bash
+// records the Version and Commit a module was built from, separated by a
+// space.
+const VersionSection = "dash.version"
+
+// ReadVersionSection returns the version and commit recorded in the
+// VersionSection of the WASM module wasm. Returns false if the module has no
+// such section or is malformed.
+func ReadVersionSection(wasm []byte) (version, commit string, ok bool) {
+	data, ok := customSection(wasm, VersionSection)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(string(data), " ")
+}
+
+// customSection returns the contents of the first custom section named
+// name in the WASM module wasm.
+func customSection(wasm []byte, name string) ([]byte, bool) {
+	if len(wasm) < 8 || string(wasm[:4]) != "\x00asm" {
+		return nil, false
+	}
+	rest := wasm[8:]
+	for len(rest) != 0 {
+		id := rest[0]
+		size, n := readULEB128(rest[1:])
+		if n == 0 || size > uint64(len(rest)-1-n) {
+			return nil, false
+		}
+		body := rest[1+n : 1+n+int(size)]
+		rest = rest[1+n+int(size):]
+		if id != 0 {
+			continue
+		}
+		nameLen, m := readULEB128(body)
+		if m == 0 || nameLen > uint64(len(body)-m) {
+			return nil, false
+		}
+		if string(body[m:m+int(nameLen)]) == name {
+			return body[m+int(nameLen):], true
+		}
+	}
+	return nil, false
+}
+
+// readULEB128 decodes the unsigned LEB128 number at the start of b and
+// returns it with its length in bytes, or a length of 0 if b does not start
+// with one.
+func readULEB128(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
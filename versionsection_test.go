@@ -0,0 +1,27 @@
+package dashwasi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestReadVersionSection(t *testing.T) {
+	version, commit, ok := ReadVersionSection(DashWASM)
+	if !ok || version != Version || commit != Commit {
+		t.Fatalf("ReadVersionSection = %q, %q, %v, want %q, %q", version, commit, ok, Version, Commit)
+	}
+	sum := sha256.Sum256(DashWASM)
+	if got := hex.EncodeToString(sum[:]); got != SHA256 {
+		t.Fatalf("dash.wasm has SHA-256 %s but version.go records %s", got, SHA256)
+	}
+
+	empty := []byte("\x00asm\x01\x00\x00\x00")
+	if _, _, ok := ReadVersionSection(empty); ok {
+		t.Fatal("expected no version section in an empty module")
+	}
+	// A custom section claiming more bytes than the module has.
+	if _, _, ok := ReadVersionSection(append(empty, 0, 0x7f, 1, 'x')); ok {
+		t.Fatal("expected no version section in a truncated module")
+	}
+}
@@ -0,0 +1,46 @@
+package dash
+
+import (
+	"errors"
+	"slices"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ValidateABI checks the exports of a compiled module against
+// dashwasi.ABI. It returns an *ExportMissingError for each missing required
+// export and an *ABIMismatchError for each export with the wrong signature,
+// joined. NewDash validates the module before instantiating it.
+func ValidateABI(compiled wazero.CompiledModule) error {
+	defs := compiled.ExportedFunctions()
+	var errs []error
+	for _, f := range dashwasi.ABI.Funcs {
+		def, ok := defs[f.Name]
+		if !ok {
+			if !f.Optional {
+				errs = append(errs, &ExportMissingError{Name: f.Name})
+			}
+			continue
+		}
+		params, results := abiTypes(def.ParamTypes()), abiTypes(def.ResultTypes())
+		if !slices.Equal(params, f.Params) || !slices.Equal(results, f.Results) {
+			errs = append(errs, &ABIMismatchError{
+				Name: f.Name,
+				Want: dashwasi.Signature(f.Params, f.Results),
+				Got:  dashwasi.Signature(params, results),
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// abiTypes converts wazero value types to dashwasi value types.
+func abiTypes(types []api.ValueType) []dashwasi.ValueType {
+	out := make([]dashwasi.ValueType, len(types))
+	for i, t := range types {
+		out[i] = dashwasi.ValueType(t)
+	}
+	return out
+}
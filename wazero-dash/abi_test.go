@@ -0,0 +1,119 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	"github.com/tetratelabs/wazero"
+)
+
+// exportsModule builds a wasm module exporting the functions of the
+// descriptor, each returning zeros.
+func exportsModule(funcs []dashwasi.ExportFunc) []byte {
+	leb128 := func(n int) []byte {
+		var b []byte
+		for ; n >= 0x80; n >>= 7 {
+			b = append(b, byte(n)|0x80)
+		}
+		return append(b, byte(n))
+	}
+	vec := func(n int, items ...byte) []byte {
+		return append(leb128(n), items...)
+	}
+	section := func(id byte, n int, items []byte) []byte {
+		body := vec(n, items...)
+		return append(append([]byte{id}, leb128(len(body))...), body...)
+	}
+	typesOf := func(types []dashwasi.ValueType) []byte {
+		b := make([]byte, len(types))
+		for i, t := range types {
+			b[i] = byte(t)
+		}
+		return vec(len(b), b...)
+	}
+
+	var types, decls, exports, code []byte
+	for i, f := range funcs {
+		types = append(types, 0x60)
+		types = append(types, typesOf(f.Params)...)
+		types = append(types, typesOf(f.Results)...)
+		decls = append(decls, byte(i))
+		exports = append(exports, vec(len(f.Name), []byte(f.Name)...)...)
+		exports = append(exports, 0x00, byte(i))
+		body := []byte{0x00}
+		for _, t := range f.Results {
+			switch t {
+			case dashwasi.ValueTypeI64:
+				body = append(body, 0x42, 0x00)
+			default:
+				body = append(body, 0x41, 0x00)
+			}
+		}
+		body = append(body, 0x0b)
+		code = append(code, vec(len(body), body...)...)
+	}
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, section(1, len(funcs), types)...)
+	wasm = append(wasm, section(3, len(funcs), decls)...)
+	wasm = append(wasm, section(7, len(funcs), exports)...)
+	wasm = append(wasm, section(10, len(funcs), code)...)
+	return wasm
+}
+
+func TestValidateABI(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	compiled, err := CompileDash(ctx, r)
+	if err != nil {
+		t.Fatal("CompileDash:", err)
+	}
+	if err := ValidateABI(compiled); err != nil {
+		t.Fatal("embedded module:", err)
+	}
+
+	var funcs []dashwasi.ExportFunc
+	for _, f := range dashwasi.ABI.Funcs {
+		switch f.Name {
+		case dashwasi.ExportDashEval:
+			f.Params = f.Params[:1]
+		case dashwasi.ExportDashGetVar:
+			f.Results = []dashwasi.ValueType{dashwasi.ValueTypeI64}
+		case dashwasi.ExportDashInit, dashwasi.ExportDashRunInteractive:
+			continue
+		}
+		funcs = append(funcs, f)
+	}
+	custom, err := r.CompileModule(ctx, exportsModule(funcs))
+	if err != nil {
+		t.Fatal("CompileModule:", err)
+	}
+	err = ValidateABI(custom)
+	var missing *ExportMissingError
+	if !errors.As(err, &missing) || missing.Name != dashwasi.ExportDashInit {
+		t.Fatalf("got %v, want missing dash_init", err)
+	}
+	for _, want := range []string{
+		"dash: export signature mismatch: dash_eval: want (i32, i32) -> i32, got (i32) -> i32",
+		"dash: export signature mismatch: dash_getvar: want (i32) -> i32, got (i32) -> i64",
+	} {
+		found := false
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			found = found || e.Error() == want
+		}
+		if !found {
+			t.Fatalf("%v does not report %q", err, want)
+		}
+	}
+	if !errors.Is(err, ErrABIMismatch) {
+		t.Fatalf("%v does not match ErrABIMismatch", err)
+	}
+
+	// NewDash rejects the module before instantiating it.
+	if _, err := NewDash(ctx, WithRuntime(r), WithCompiledModule(custom)); !errors.Is(err, ErrABIMismatch) {
+		t.Fatalf("NewDash: got %v, want ErrABIMismatch", err)
+	}
+}
@@ -0,0 +1,189 @@
+package dash
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+// DefaultArchiveMaxBytes is the default cap on the file data a tar archive
+// of WithArchiveMount loads into memory, when SizeLimits.MaxMemoryBytes is
+// not set.
+const DefaultArchiveMaxBytes = 256 << 20
+
+// archiveMount is an archive requested with WithArchiveMount.
+type archiveMount struct {
+	r     io.ReaderAt
+	size  int64
+	guest string
+}
+
+// WithArchiveMount mounts the zip, tar or gzip-compressed tar archive read
+// from r, size bytes long, read-only at guestPath. The format is detected
+// from the archive contents.
+//
+// Zip archives are read from r on demand, so r must stay readable until
+// the shell is closed. Tar archives are loaded into memory by NewDash, up
+// to the cap set with WithArchiveMaxBytes: NewDash fails for an archive
+// whose files hold more data once decompressed.
+func WithArchiveMount(r io.ReaderAt, size int64, guestPath string) Option {
+	return func(o *options) {
+		o.archiveMounts = append(o.archiveMounts, archiveMount{r: r, size: size, guest: guestPath})
+	}
+}
+
+// WithArchiveMaxBytes caps the file data each tar archive of
+// WithArchiveMount loads into memory at maxBytes. Zero disables the cap.
+// The default is the MaxMemoryBytes of the policy's limits, if set, and
+// DefaultArchiveMaxBytes otherwise.
+func WithArchiveMaxBytes(maxBytes int64) Option {
+	return func(o *options) {
+		o.archiveMaxBytes = &maxBytes
+	}
+}
+
+// archiveMaxBytes returns the cap on the file data of a tar archive, given
+// the options and the size limits of the shell.
+func archiveMaxBytes(opts *options, limits SizeLimits) int64 {
+	switch {
+	case opts.archiveMaxBytes != nil:
+		return *opts.archiveMaxBytes
+	case limits.MaxMemoryBytes != 0:
+		return int64(min(limits.MaxMemoryBytes, math.MaxInt64))
+	}
+	return DefaultArchiveMaxBytes
+}
+
+// archiveFS returns the read-only file system holding the archive a, with
+// tar archives holding at most maxBytes of file data.
+func archiveFS(a archiveMount, maxBytes int64) (experimentalsys.FS, error) {
+	var magic [4]byte
+	n, err := a.r.ReadAt(magic[:], 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	sr := io.NewSectionReader(a.r, 0, a.size)
+	switch {
+	case bytes.HasPrefix(magic[:n], []byte("PK")):
+		zr, err := zip.NewReader(a.r, a.size)
+		if err != nil {
+			return nil, fmt.Errorf("dash: archive for %s: %w", a.guest, err)
+		}
+		return &readOnlyFS{FS: &sysfs.AdaptFS{FS: zr}}, nil
+	case bytes.HasPrefix(magic[:n], []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(sr)
+		if err != nil {
+			return nil, fmt.Errorf("dash: archive for %s: %w", a.guest, err)
+		}
+		defer gz.Close()
+		return loadTar(gz, a.guest, maxBytes)
+	default:
+		return loadTar(sr, a.guest, maxBytes)
+	}
+}
+
+// loadTar reads a tar archive into a read-only in-memory file system
+// holding at most maxBytes of file data, unless maxBytes is 0.
+func loadTar(r io.Reader, guest string, maxBytes int64) (experimentalsys.FS, error) {
+	m := newMemFS(maxBytes)
+	if err := extractTar(m, r, false); err != nil {
+		return nil, fmt.Errorf("dash: archive for %s: %w", guest, err)
+	}
+	return &readOnlyFS{FS: m}, nil
+}
+
+// archivePath cleans the name of an archive entry, reporting false for
+// names escaping the archive root.
+func archivePath(name string) (string, bool) {
+	if strings.Contains(name, "\x00") || slices.Contains(strings.Split(name, "/"), "..") {
+		return "", false
+	}
+	if name = strings.TrimPrefix(path.Clean("/"+name), "/"); name == "" {
+		return ".", true
+	}
+	return name, true
+}
+
+// addTarEntry creates the entry hdr at name in m, creating missing parent
+// directories. Entry types other than files, directories and links are
+// skipped.
+func addTarEntry(m *memFS, name string, hdr *tar.Header, r io.Reader) experimentalsys.Errno {
+	switch hdr.Typeflag {
+	case tar.TypeDir, tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+	default:
+		return 0
+	}
+	if dir := path.Dir(name); dir != "." {
+		if errno := mkdirAll(m, dir); errno != 0 {
+			return errno
+		}
+	}
+	perm := fs.FileMode(hdr.Mode) & fs.ModePerm
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if errno := m.Mkdir(name, perm); errno == experimentalsys.EEXIST {
+			return m.Chmod(name, perm)
+		} else if errno != 0 {
+			return errno
+		}
+	case tar.TypeReg:
+		f, errno := m.OpenFile(name, experimentalsys.O_CREAT|experimentalsys.O_TRUNC|experimentalsys.O_WRONLY, perm)
+		if errno != 0 {
+			return errno
+		}
+		defer f.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if _, errno := f.Write(buf[:n]); errno != 0 {
+					return errno
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return experimentalsys.EIO
+			}
+		}
+	case tar.TypeSymlink:
+		return m.Symlink(hdr.Linkname, name)
+	case tar.TypeLink:
+		target, ok := archivePath(hdr.Linkname)
+		if !ok {
+			return experimentalsys.EINVAL
+		}
+		return m.Link(target, name)
+	}
+	mtim := hdr.ModTime.UnixNano()
+	return m.Utimens(name, mtim, mtim)
+}
+
+// mkdirAll creates dir and its missing parents in m.
+func mkdirAll(m *memFS, dir string) experimentalsys.Errno {
+	if _, errno := m.Stat(dir); errno == 0 {
+		return 0
+	}
+	if parent := path.Dir(dir); parent != "." {
+		if errno := mkdirAll(m, parent); errno != 0 {
+			return errno
+		}
+	}
+	if errno := m.Mkdir(dir, 0o755); errno != 0 && errno != experimentalsys.EEXIST {
+		return errno
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package dash
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// archiveFiles are the regular files of the test archives.
+var archiveFiles = map[string]string{
+	"bin/run.sh":    "echo run\n",
+	"data/a.txt":    "alpha\n",
+	"data/empty":    "",
+	"./data/b.conf": "beta\n",
+}
+
+func testTar(t *testing.T, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{"bin/run.sh", "data/a.txt", "data/empty", "./data/b.conf"} {
+		body := archiveFiles[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), ModTime: mtime}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, hdr := range []*tar.Header{
+		{Name: "data/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "current", Typeflag: tar.TypeSymlink, Linkname: "data/a.txt"},
+		{Name: "data/hard", Typeflag: tar.TypeLink, Linkname: "data/a.txt"},
+		{Name: "dev/null", Typeflag: tar.TypeChar},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func testZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range archiveFiles {
+		w, err := zw.Create(strings.TrimPrefix(name, "./"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveMount(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		archive []byte
+		links   bool
+	}{
+		{"tar", testTar(t, false), true},
+		{"tgz", testTar(t, true), true},
+		{"zip", testZip(t), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntime(ctx)
+			defer r.Close(ctx)
+			var stdout, stderr bytes.Buffer
+			config := wazero.NewModuleConfig().WithStdout(&stdout).WithStderr(&stderr)
+			d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config), WithArchiveMount(bytes.NewReader(tc.archive), int64(len(tc.archive)), "/assets"))
+			if err != nil {
+				t.Fatal("NewDash:", err)
+			}
+			defer d.Close(ctx)
+			if err := d.Init(ctx, nil); err != nil {
+				t.Fatal("Init:", err)
+			}
+
+			script := `echo /assets/*
+echo /assets/data/*
+test -s /assets/data/a.txt && ! test -s /assets/data/empty && echo sizes
+true >/assets/new; test -e /assets/new || echo not created
+true >/assets/data/a.txt; test -s /assets/data/a.txt && echo unchanged`
+			if _, err := d.Eval(ctx, script); err != nil {
+				t.Fatal("Eval:", err)
+			}
+			want := "/assets/bin /assets/current /assets/data\n/assets/data/a.txt /assets/data/b.conf /assets/data/empty /assets/data/hard\nsizes\nnot created\nunchanged\n"
+			if !tc.links {
+				want = strings.Replace(want, "/assets/current ", "", 1)
+				want = strings.Replace(want, " /assets/data/hard", "", 1)
+			}
+			if got := stdout.String(); got != want {
+				t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+			}
+
+			m, rel, ok := d.state.lookupMount("/assets/data/b.conf")
+			if !ok {
+				t.Fatal("archive mount not managed")
+			}
+			f, errno := m.fs.OpenFile(rel, experimentalsys.O_RDONLY, 0)
+			if errno != 0 {
+				t.Fatal("OpenFile:", errno)
+			}
+			defer f.Close()
+			buf := make([]byte, 64)
+			n, _ := f.Read(buf)
+			if got := string(buf[:n]); got != "beta\n" {
+				t.Fatalf("unexpected contents %q", got)
+			}
+		})
+	}
+}
+
+func TestArchiveMountErrors(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	_ = tw.Close()
+
+	for name, archive := range map[string][]byte{
+		"escape":  buf.Bytes(),
+		"corrupt": []byte("PK\x03\x04 not a zip"),
+		"gzip":    {0x1f, 0x8b, 0},
+	} {
+		r := wazero.NewRuntime(ctx)
+		_, err := NewDash(ctx, WithRuntime(r), WithArchiveMount(bytes.NewReader(archive), int64(len(archive)), "/assets"))
+		_ = r.Close(ctx)
+		if err == nil || !strings.HasPrefix(err.Error(), "dash: archive for /assets") {
+			t.Fatalf("%s: expected an archive error, got %v", name, err)
+		}
+	}
+}
+
+func TestArchiveMountMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"a", "b"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 5 << 20}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(make([]byte, 5<<20)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	archive := buf.Bytes()
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		ok   bool
+	}{
+		{"default", nil, true},
+		{"option", []Option{WithArchiveMaxBytes(8 << 20)}, false},
+		{"option fits", []Option{WithArchiveMaxBytes(16 << 20)}, true},
+		{"no cap", []Option{WithArchiveMaxBytes(0)}, true},
+		{"memory limit", []Option{WithPolicy(&Policy{Limits: PolicyLimits{MaxMemoryBytes: 8 << 20}})}, false},
+		{"option over memory limit", []Option{WithPolicy(&Policy{Limits: PolicyLimits{MaxMemoryBytes: 8 << 20}}), WithArchiveMaxBytes(16 << 20)}, true},
+	} {
+		r := wazero.NewRuntime(ctx)
+		d, err := NewDash(ctx, append(tc.opts, WithRuntime(r), WithArchiveMount(bytes.NewReader(archive), int64(len(archive)), "/assets"))...)
+		if d != nil {
+			_ = d.Close(ctx)
+		}
+		_ = r.Close(ctx)
+		if tc.ok && err != nil {
+			t.Fatalf("%s: NewDash: %v", tc.name, err)
+		}
+		if !tc.ok && (err == nil || err.Error() != "dash: archive for /assets: b: file data exceeds 8388608 bytes") {
+			t.Fatalf("%s: expected the archive to exceed the cap, got %v", tc.name, err)
+		}
+	}
+}
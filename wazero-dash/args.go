@@ -0,0 +1,53 @@
+package dash
+
+import (
+	"context"
+	"strings"
+)
+
+// EvalArgs evaluates script like Eval with the positional parameters $1 to
+// $N, "$@" and $# set from args, each passed as one parameter without
+// quoting hazards. The previous positional parameters are restored
+// afterwards, even if script changes them with set.
+func (d *Dash) EvalArgs(ctx context.Context, script string, args ...string) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	saved, err := d.positionalParams(ctx)
+	if err != nil {
+		return -1, err
+	}
+	if err := d.setPositionalParams(ctx, args); err != nil {
+		return -1, err
+	}
+	status, err := d.Eval(ctx, script)
+	// Restore even if ctx is done: a cancelled Eval only rolls back to the
+	// parameters set above.
+	restoreErr := d.setPositionalParams(context.WithoutCancel(ctx), saved)
+	if err == nil && restoreErr != nil {
+		return -1, restoreErr
+	}
+	return status, err
+}
+
+// positionalParams returns the current positional parameters.
+func (d *Dash) positionalParams(ctx context.Context) ([]string, error) {
+	out, err := d.evalQuiet(ctx, `[ $# -eq 0 ] || command printf '%s\0' "$@"`)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00"), nil
+}
+
+// setPositionalParams replaces the positional parameters with params.
+func (d *Dash) setPositionalParams(ctx context.Context, params []string) error {
+	cmd := "set --"
+	for _, p := range params {
+		cmd += " " + Quote(p)
+	}
+	_, err := d.evalQuiet(ctx, cmd)
+	return err
+}
@@ -0,0 +1,69 @@
+package dash
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEvalArgs(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "set -- keep 'me too' ''; false"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	var status int
+	stdout, err := d.captureStdout(func() error {
+		var err error
+		status, err = d.EvalArgs(ctx, `printf '%s|' "$?" "$#" "$@"; set -- changed; false`, "a b", "it's", "$HOME", "")
+		return err
+	})
+	if err != nil {
+		t.Fatal("EvalArgs:", err)
+	}
+	if want := "1|4|a b|it's|$HOME||"; string(stdout) != want || status != 1 {
+		t.Fatalf("expected %q and status 1, got %q and %d", want, stdout, status)
+	}
+
+	stdout, _, _, err = d.EvalCapture(ctx, `printf '%s|' "$#" "$@"`)
+	if err != nil {
+		t.Fatal("EvalCapture:", err)
+	}
+	if want := "3|keep|me too||"; string(stdout) != want {
+		t.Fatalf("expected the parameters restored as %q, got %q", want, stdout)
+	}
+
+	// A function shadowing printf does not lose the parameters.
+	if _, err := d.Eval(ctx, "printf() { :; }"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	stdout, err = d.captureStdout(func() error {
+		_, err := d.EvalArgs(ctx, `echo $#`, "x")
+		return err
+	})
+	if err != nil || string(stdout) != "1\n" {
+		t.Fatalf("EvalArgs with printf redefined = %q, %v", stdout, err)
+	}
+	if _, err := d.Eval(ctx, "unset -f printf"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	stdout, _, _, err = d.EvalCapture(ctx, `printf '%s|' "$#" "$@"`)
+	if err != nil || string(stdout) != "3|keep|me too||" {
+		t.Fatalf("expected the parameters restored with printf redefined, got %q, %v", stdout, err)
+	}
+
+	stdout, err = d.captureStdout(func() error {
+		_, err := d.EvalArgs(ctx, `echo $#`)
+		return err
+	})
+	if err != nil || string(stdout) != "0\n" {
+		t.Fatalf("EvalArgs without args = %q, %v", stdout, err)
+	}
+}
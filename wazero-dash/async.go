@@ -0,0 +1,54 @@
+package dash
+
+import "context"
+
+// EvalHandle is an evaluation started by EvalAsync.
+type EvalHandle struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	status int
+	err    error
+}
+
+// EvalAsync starts evaluating cmd like Eval on a new goroutine and returns
+// at once. No other method of d may be called until the evaluation is done.
+func (d *Dash) EvalAsync(ctx context.Context, cmd string) *EvalHandle {
+	ctx, cancel := context.WithCancel(ctx)
+	h := &EvalHandle{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		h.status, h.err = d.Eval(ctx, cmd)
+	}()
+	return h
+}
+
+// Done returns a channel closed when the evaluation is done.
+func (h *EvalHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait waits for the evaluation and returns the results of Eval.
+func (h *EvalHandle) Wait() (int, error) {
+	<-h.done
+	return h.status, h.err
+}
+
+// Status returns the exit status and true if the evaluation is done, and
+// -1 and false otherwise.
+func (h *EvalHandle) Status() (int, bool) {
+	select {
+	case <-h.done:
+		return h.status, true
+	default:
+		return -1, false
+	}
+}
+
+// Interrupt cancels the context of the evaluation, which aborts it as
+// described for Eval; Wait then returns an error wrapping ErrInterrupted.
+// Host commands such as the ExecHandler see the cancellation too. It does
+// not wait; see Wait.
+func (h *EvalHandle) Interrupt() {
+	h.cancel()
+}
@@ -0,0 +1,36 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEvalAsync(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+	started := make(chan struct{})
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		close(started)
+		<-ctx.Done()
+		return 130
+	})
+
+	h := d.EvalAsync(ctx, `echo before; block; echo "after $?"`)
+	<-started
+	if _, done := h.Status(); done {
+		t.Fatal("expected the evaluation to be running")
+	}
+	h.Interrupt()
+	<-h.Done()
+	status, err := h.Wait()
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if s, done := h.Status(); !done || s != status || status != -1 {
+		t.Fatalf("unexpected status %d (done %v), Wait returned %d", s, done, status)
+	}
+	if want := "before\n"; stdout.String() != want {
+		t.Fatalf("expected %q, got %q", want, stdout.String())
+	}
+}
@@ -0,0 +1,163 @@
+package dash
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// BinDir is the virtual directory listing the host commands.
+//
+// It holds an empty, read-only file per command implemented by the host,
+// such as chmod and mktemp, so scripts can discover them with `ls /bin` or
+// `test -f /bin/chmod`. Dash only resolves PATH entries with execute
+// permission, which WASI preview1 cannot report, so `command -v` and `type`
+// still treat these commands as not found; running them is unaffected.
+const BinDir = "/bin"
+
+// WithoutBinDir disables the virtual BinDir.
+//
+// BinDir is also omitted if WithDirMount mounts a host directory there.
+func WithoutBinDir() Option {
+	return func(o *options) {
+		o.binDir = false
+	}
+}
+
+// binFS is the file system of BinDir, generated from the registered host
+// commands on each access. Wrapped in readOnlyFS when mounted.
+type binFS struct {
+	experimentalsys.UnimplementedFS
+	state *dashState
+}
+
+// names returns the sorted host command names.
+func (f *binFS) names() []string {
+	names := make([]string, 0, len(f.state.commands)+len(f.state.registered))
+	for name := range f.state.commands {
+		names = append(names, name)
+	}
+	for name := range f.state.registered {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookup returns the stat of p: the directory itself or a command file.
+func (f *binFS) lookup(p string) (sys.Stat_t, experimentalsys.Errno) {
+	if p == "" || p == "." {
+		return sys.Stat_t{Ino: 1, Mode: fs.ModeDir | 0o555, Nlink: 2}, 0
+	}
+	if strings.Contains(p, "/") {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	i, found := slices.BinarySearch(f.names(), p)
+	if !found {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	return sys.Stat_t{Ino: sys.Inode(i + 2), Mode: 0o555, Nlink: 1}, 0
+}
+
+// OpenFile implements experimentalsys.FS.
+func (f *binFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	st, errno := f.lookup(p)
+	if errno != 0 {
+		return nil, errno
+	}
+	if flag&experimentalsys.O_DIRECTORY != 0 && !st.Mode.IsDir() {
+		return nil, experimentalsys.ENOTDIR
+	}
+	file := &binFile{st: st}
+	if st.Mode.IsDir() {
+		for i, name := range f.names() {
+			file.dirents = append(file.dirents, experimentalsys.Dirent{Ino: sys.Inode(i + 2), Name: name})
+		}
+	}
+	return file, 0
+}
+
+// Stat implements experimentalsys.FS.
+func (f *binFS) Stat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	return f.lookup(p)
+}
+
+// Lstat implements experimentalsys.FS.
+func (f *binFS) Lstat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	return f.lookup(p)
+}
+
+// Readlink implements experimentalsys.FS.
+func (f *binFS) Readlink(p string) (string, experimentalsys.Errno) {
+	if _, errno := f.lookup(p); errno != 0 {
+		return "", errno
+	}
+	return "", experimentalsys.EINVAL
+}
+
+// binFile is an open BinDir entry. Command files are empty.
+type binFile struct {
+	experimentalsys.UnimplementedFile
+	st      sys.Stat_t
+	dirents []experimentalsys.Dirent
+	closed  bool
+}
+
+// Ino implements experimentalsys.File.
+func (f *binFile) Ino() (sys.Inode, experimentalsys.Errno) {
+	return f.st.Ino, 0
+}
+
+// IsDir implements experimentalsys.File.
+func (f *binFile) IsDir() (bool, experimentalsys.Errno) {
+	return f.st.Mode.IsDir(), 0
+}
+
+// Stat implements experimentalsys.File.
+func (f *binFile) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	if f.closed {
+		return sys.Stat_t{}, experimentalsys.EBADF
+	}
+	return f.st, 0
+}
+
+// Read implements experimentalsys.File.
+func (f *binFile) Read([]byte) (int, experimentalsys.Errno) {
+	if f.st.Mode.IsDir() {
+		return 0, experimentalsys.EISDIR
+	}
+	return 0, 0
+}
+
+// Pread implements experimentalsys.File.
+func (f *binFile) Pread(buf []byte, _ int64) (int, experimentalsys.Errno) {
+	return f.Read(buf)
+}
+
+// Readdir implements experimentalsys.File.
+func (f *binFile) Readdir(n int) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	if f.closed || !f.st.Mode.IsDir() {
+		return nil, experimentalsys.EBADF
+	}
+	if n <= 0 || n > len(f.dirents) {
+		n = len(f.dirents)
+	}
+	out := f.dirents[:n]
+	f.dirents = f.dirents[n:]
+	return out, 0
+}
+
+// Close implements experimentalsys.File.
+func (f *binFile) Close() experimentalsys.Errno {
+	f.closed = true
+	return 0
+}
+
+// _ is a type assertion
+var (
+	_ experimentalsys.FS   = (*binFS)(nil)
+	_ experimentalsys.File = (*binFile)(nil)
+)
@@ -0,0 +1,51 @@
+package dash
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestBinDir(t *testing.T) {
+	d, stdout, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	script := `echo /bin/*
+test -f /bin/chmod && echo file
+test -e /bin/missing || echo missing
+true >/bin/new
+chmod 600 /bin/chmod || echo chmod failed`
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	want := "/bin/chmod /bin/mktemp\nfile\nmissing\nchmod failed\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+	}
+
+	for _, tc := range []struct {
+		opt  Option
+		want string
+	}{
+		{WithoutTempDir(), "/bin/chmod\n"},
+		{WithoutBinDir(), "/bin/*\n"},
+	} {
+		stdout.Reset()
+		r := wazero.NewRuntime(ctx)
+		defer r.Close(ctx)
+		d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(wazero.NewModuleConfig().WithStdout(stdout)), tc.opt)
+		if err != nil {
+			t.Fatal("NewDash:", err)
+		}
+		if err := d.Init(ctx, nil); err != nil {
+			t.Fatal("Init:", err)
+		}
+		if _, err := d.Eval(ctx, "echo /bin/*"); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		if got := stdout.String(); got != tc.want {
+			t.Fatalf("expected %q, got %q", tc.want, got)
+		}
+	}
+}
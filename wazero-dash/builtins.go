@@ -0,0 +1,111 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// BuiltinFunc implements a command in Go. args holds the command name and
+// its arguments; stdin, stdout and stderr are the guest's standard streams.
+// Returns the exit status.
+type BuiltinFunc func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// OverridableBuiltins lists the dash builtins OverrideBuiltin accepts.
+var OverridableBuiltins = []string{"echo", "printf", "test"}
+
+// builtinCommand is the command the override functions dispatch through.
+const builtinCommand = "@builtin"
+
+// OverrideBuiltin replaces the dash builtin name, one of
+// OverridableBuiltins, with fn. Passing a nil fn restores the builtin,
+// including the %q support of printf.
+//
+// The override is a shell function forwarding to the host, so it applies
+// wherever dash looks up functions; `builtin name ...` and
+// `command name ...` still reach the original. A script redefining or
+// unsetting the function removes the override.
+func (d *Dash) OverrideBuiltin(ctx context.Context, name string, fn BuiltinFunc) error {
+	if !slices.Contains(OverridableBuiltins, name) {
+		return errors.New("dash: builtin cannot be overridden: " + name)
+	}
+	if err := d.ready(); err != nil {
+		return err
+	}
+	if fn == nil {
+		delete(d.state.builtins, name)
+		if name == "printf" {
+			return d.installPrintf(ctx)
+		}
+		_, err := d.evalQuiet(ctx, "unset -f "+name)
+		return err
+	}
+	if d.state.builtins == nil {
+		d.state.builtins = make(map[string]BuiltinFunc)
+	}
+	d.state.builtins[name] = fn
+	return d.installOverride(ctx, name)
+}
+
+// installOverride defines the function forwarding the builtin name to its
+// override.
+func (d *Dash) installOverride(ctx context.Context, name string) error {
+	_, err := d.evalQuiet(ctx, name+`() { `+builtinCommand+` `+name+` "$@"; }
+builtin() { command "$@"; }`)
+	return err
+}
+
+// runBuiltin dispatches a `@builtin NAME ARGS...` command to the override
+// registered for NAME. Returns 127 if there is none.
+func runBuiltin(ctx context.Context, mod api.Module, state *dashState, argv []string) int {
+	if len(argv) < 2 {
+		return 127
+	}
+	fn, ok := state.builtins[argv[1]]
+	if !ok {
+		return 127
+	}
+	return callBuiltin(ctx, mod, state, fn, argv[1:])
+}
+
+// RegisterBuiltin adds the command name, implemented by fn, so scripts can
+// run it like an external command. Registering the same name again
+// replaces the command; passing a nil fn removes it. The commands of the
+// host itself, such as chmod and mktemp, and names starting with @, which
+// the reactor uses internally, are reserved: registering or removing them
+// fails, as do names that are empty or contain / or =.
+//
+// The command is dispatched when dash finds no builtin or function of the
+// same name, so it cannot shadow those; see OverrideBuiltin. It is listed in
+// BinDir and subject to the Policy and CommandTimeouts.
+func (d *Dash) RegisterBuiltin(name string, fn BuiltinFunc) error {
+	if _, host := d.state.commands[name]; host || name == "" || name[0] == '@' || strings.ContainsAny(name, "/=") {
+		return errors.New("dash: reserved command name: " + name)
+	}
+	if fn == nil {
+		delete(d.state.registered, name)
+		return nil
+	}
+	if d.state.registered == nil {
+		d.state.registered = make(map[string]BuiltinFunc)
+	}
+	d.state.registered[name] = fn
+	return nil
+}
+
+// callBuiltin runs fn with args on the guest's standard streams.
+func callBuiltin(ctx context.Context, mod api.Module, state *dashState, fn BuiltinFunc, args []string) int {
+	// The streams keep the parent context: writing through a cancelled
+	// context would close the module on runtimes configured with
+	// WithCloseOnContextDone.
+	stream := func(fd uint32) *guestStream {
+		return &guestStream{ctx: ctx, mod: mod, state: state, fd: fd}
+	}
+	return runWithTimeout(ctx, mod, state, args[0], func(cmdCtx context.Context) int {
+		return fn(cmdCtx, args, stream(fdStdin), stream(fdStdout), stream(fdStderr))
+	})
+}
@@ -0,0 +1,101 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOverrideBuiltin(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+
+	err := d.OverrideBuiltin(ctx, "echo", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		fmt.Fprintln(stdout, strings.ToUpper(strings.Join(args[1:], " ")))
+		return 3
+	})
+	if err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+	status, err := d.Eval(ctx, `echo hello world; s=$?; builtin echo "raw $s"; command echo plain`)
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if want := "HELLO WORLD\nraw 3\nplain\n"; stdout.String() != want || status != 0 {
+		t.Fatalf("expected %q, got %q (status %d)", want, stdout.String(), status)
+	}
+
+	if err := d.OverrideBuiltin(ctx, "echo", nil); err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+	stdout.Reset()
+	if _, err := d.Eval(ctx, "echo restored"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if stdout.String() != "restored\n" {
+		t.Fatalf("expected the original echo, got %q", stdout.String())
+	}
+
+	if err := d.OverrideBuiltin(ctx, "cd", nil); err == nil {
+		t.Fatal("expected an error overriding cd")
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	d, stdout, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	if err := d.RegisterBuiltin("mytool", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		in, _ := io.ReadAll(stdin)
+		fmt.Fprintf(stdout, "%s [%s]\n", strings.Join(args, " "), in)
+		fmt.Fprintln(stderr, "done")
+		return 4
+	}); err != nil {
+		t.Fatal("RegisterBuiltin:", err)
+	}
+	status, err := d.EvalWithInput(ctx, `mytool --flag "a b"; echo "status $?"; test -f /bin/mytool && echo listed`, strings.NewReader("input"))
+	if err != nil {
+		t.Fatal("EvalWithInput:", err)
+	}
+	if want := "mytool --flag a b [input]\nstatus 4\nlisted\n"; stdout.String() != want || status != 0 {
+		t.Fatalf("expected %q, got %q (status %d)", want, stdout.String(), status)
+	}
+	if stderr.String() != "done\n" {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	if err := d.RegisterBuiltin("mytool", nil); err != nil {
+		t.Fatal("RegisterBuiltin:", err)
+	}
+	status, err = d.Eval(ctx, "mytool")
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if status != 127 {
+		t.Fatalf("expected status 127 after removal, got %d", status)
+	}
+}
+
+func TestRegisterBuiltinReserved(t *testing.T) {
+	d, _, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	fn := func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int { return 3 }
+	for _, name := range []string{"chmod", "@builtin", "", "a/b", "a=b"} {
+		if err := d.RegisterBuiltin(name, fn); err == nil {
+			t.Fatalf("expected an error registering %q", name)
+		}
+	}
+	if err := d.RegisterBuiltin("chmod", nil); err == nil {
+		t.Fatal("expected an error removing chmod")
+	}
+	status, err := d.Eval(ctx, "chmod")
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if !strings.Contains(stderr.String(), "usage: chmod") || status != 1 {
+		t.Fatalf("expected the host chmod to run, got %q (status %d)", stderr.String(), status)
+	}
+}
@@ -0,0 +1,24 @@
+package dash
+
+import "context"
+
+// EvalCapture evaluates cmd like Eval and returns what it wrote to stdout
+// and stderr, instead of writing it to the streams of the module config.
+//
+// Output written on the guest streams by host code during the evaluation,
+// such as builtin overrides and rendered PS4 traces, is captured as well.
+// Captures nest: an EvalCapture made from a host command only captures the
+// output of its own evaluation.
+func (d *Dash) EvalCapture(ctx context.Context, cmd string) (stdout, stderr []byte, status int, err error) {
+	status = -1
+	stderr, err = d.captureStderr(func() error {
+		var err error
+		stdout, err = d.captureStdout(func() error {
+			var err error
+			status, err = d.Eval(ctx, cmd)
+			return err
+		})
+		return err
+	})
+	return stdout, stderr, status, err
+}
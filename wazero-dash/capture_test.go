@@ -0,0 +1,56 @@
+package dash
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEvalCapture(t *testing.T) {
+	d, stdout, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	if err := d.OverrideBuiltin(ctx, "printf", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		_, _ = io.WriteString(stdout, "host:"+strings.Join(args[1:], ","))
+		return 0
+	}); err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+	var inner []byte
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		out, _, status, err := d.EvalCapture(ctx, "echo inner")
+		if err != nil || status != 0 {
+			t.Errorf("nested EvalCapture: %d %v", status, err)
+		}
+		inner = out
+		return 3
+	})
+
+	out, errOut, status, err := d.EvalCapture(ctx, "echo one; printf a b; echo; nested; echo status $?; cd /missing")
+	if err != nil {
+		t.Fatal("EvalCapture:", err)
+	}
+	if status != 2 {
+		t.Fatalf("expected status 2, got %d", status)
+	}
+	if got, want := string(out), "one\nhost:a,b\nstatus 3\n"; got != want {
+		t.Fatalf("expected stdout %q, got %q", want, got)
+	}
+	if !strings.Contains(string(errOut), "can't cd to /missing") {
+		t.Fatalf("unexpected stderr %q", errOut)
+	}
+	if string(inner) != "inner\n" {
+		t.Fatalf("unexpected nested output %q", inner)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("output leaked to the module streams: %q %q", stdout.String(), stderr.String())
+	}
+
+	if _, err := d.Eval(ctx, "echo after"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if stdout.String() != "after\n" {
+		t.Fatalf("expected capture to end, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,68 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+)
+
+// Clone returns a new Dash in the state of d, with fork-like semantics:
+// run an expensive setup script once, then clone the shell for parallel,
+// isolated evaluations. The guest memory is copied into a new instance of
+// the module, so variables, functions, aliases, options and the working
+// directory carry over, and later changes to either shell do not affect
+// the other. Handlers, registered commands and builtin overrides are
+// shared, as are host directory mounts and the stdout and stderr of
+// WithStdio.
+//
+// The clone's stdin is empty: sharing the reader of d would let both
+// shells take each other's input. Use EvalWithInput to feed it.
+//
+// The clone is created with the options of d and gets its own /tmp, empty:
+// files are not copied. Neither are the journal, quota usage, FSStats
+// counters and profiler. Clone is not available for a Dash created with WithRuntime,
+// as each instance needs its own runtime.
+func (d *Dash) Clone(ctx context.Context) (*Dash, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.state.depth != 0 {
+		return nil, errors.New("dash: Clone cannot be called from a host command")
+	}
+	if d.opts == nil {
+		return nil, errors.New("dash: Clone cannot be used with WithRuntime")
+	}
+	o := *d.opts
+	// Init does not run: the clone gets the exported variables with the
+	// memory.
+	o.envFiles = nil
+	o.stdin = nil
+	c, err := newDashWithOptions(ctx, &o)
+	if err != nil {
+		return nil, err
+	}
+	captureMemory(d.mod).restore(c.mod)
+
+	s, cs := d.state, c.state
+	cs.execHandler = s.execHandler
+	cs.hostCalls = maps.Clone(s.hostCalls)
+	cs.commands = maps.Clone(s.commands)
+	cs.registered = maps.Clone(s.registered)
+	cs.builtins = maps.Clone(s.builtins)
+	cs.functions = slices.Clone(s.functions)
+	cs.commandNotFound = s.commandNotFound
+	cs.commandTimeouts = s.commandTimeouts
+	cs.sizeLimits = s.sizeLimits
+	cs.quota = s.quota
+	cs.ps4 = s.ps4
+	if s.sessions != nil {
+		cs.sessions.saved = maps.Clone(s.sessions.saved)
+	}
+	c.arg0, c.arg0Ptr = d.arg0, d.arg0Ptr
+	c.ps1 = d.ps1
+	c.normalizeCRLF = d.normalizeCRLF
+	c.initSnap = d.initSnap
+	c.initialized = true
+	return c, nil
+}
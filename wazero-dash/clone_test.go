@@ -0,0 +1,125 @@
+package dash
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestClone(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if _, err := d.Clone(ctx); err != ErrNotInitialized {
+		t.Fatalf("expected ErrNotInitialized, got %v", err)
+	}
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if err := d.RegisterBuiltin("greet", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "hello "+args[1]+"\n")
+		return 0
+	}); err != nil {
+		t.Fatal("RegisterBuiltin:", err)
+	}
+	if _, err := d.Eval(ctx, "SETUP=done; n=0; inc() { n=$((n + $1)); }; alias g=greet; cd /tmp"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	// Clones evaluate in parallel without affecting each other.
+	clones := make([]*Dash, 3)
+	for i := range clones {
+		if clones[i], err = d.Clone(ctx); err != nil {
+			t.Fatal("Clone:", err)
+		}
+		defer clones[i].Close(ctx)
+	}
+	var wg sync.WaitGroup
+	for i, c := range clones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, err := c.Eval(ctx, "inc "+strconv.Itoa(i+1)); err != nil {
+					t.Error("Eval:", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, c := range clones {
+		stdout, _, _, err := c.EvalCapture(ctx, "echo $SETUP $n $PWD\ng clone")
+		if err != nil {
+			t.Fatal("EvalCapture:", err)
+		}
+		if want := "done " + strconv.Itoa(10*(i+1)) + " /tmp\nhello clone\n"; string(stdout) != want {
+			t.Fatalf("clone %d: expected %q, got %q", i, want, stdout)
+		}
+	}
+	if n, _ := d.GetVar(ctx, "n"); n != "0" {
+		t.Fatalf("expected the original shell unchanged, got n=%s", n)
+	}
+
+	// Reset returns a clone to the state after Init.
+	if err := clones[0].Reset(ctx); err != nil {
+		t.Fatal("Reset:", err)
+	}
+	if v, _ := clones[0].GetVar(ctx, "SETUP"); v != "" {
+		t.Fatalf("expected SETUP unset after Reset, got %q", v)
+	}
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	shared, err := NewDash(ctx, WithRuntime(r))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer shared.Close(ctx)
+	if err := shared.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := shared.Clone(ctx); err == nil {
+		t.Fatal("expected Clone to fail with WithRuntime")
+	}
+}
+
+func TestCloneStdin(t *testing.T) {
+	ctx := context.Background()
+	var stdout strings.Builder
+	d, err := NewDash(ctx, WithStdio(strings.NewReader("parent\n"), &stdout, io.Discard))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	c, err := d.Clone(ctx)
+	if err != nil {
+		t.Fatal("Clone:", err)
+	}
+	defer c.Close(ctx)
+
+	// The clone does not take the input of d.
+	if c.Stdin() != nil {
+		t.Fatal("clone shares the stdin of d")
+	}
+	if status, err := c.Eval(ctx, `read line || echo "clone: ${line:-empty}"`); err != nil || status != 0 {
+		t.Fatalf("clone read: %d, %v", status, err)
+	}
+	if _, err := d.Eval(ctx, `read line && echo "parent: $line"`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stdout.String(); got != "clone: empty\nparent: parent\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+// setupConsole prepares the terminal for the shell. POSIX terminals need no
+// setup.
+func setupConsole() (restore func()) {
+	return func() {}
+}
+
+// defaultCRLF is the default for -crlf.
+const defaultCRLF = false
@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// setupConsole enables ANSI escape sequence processing on the Windows
+// console so prompts and script output render as they would in a POSIX
+// terminal. The returned function restores the previous console modes.
+func setupConsole() (restore func()) {
+	var restores []func()
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		h := windows.Handle(f.Fd())
+		var mode uint32
+		if err := windows.GetConsoleMode(h, &mode); err != nil {
+			continue // not a console
+		}
+		if err := windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err == nil {
+			restores = append(restores, func() { _ = windows.SetConsoleMode(h, mode) })
+		}
+	}
+	return func() {
+		for _, fn := range restores {
+			fn()
+		}
+	}
+}
+
+// defaultCRLF is the default for -crlf: scripts on Windows commonly have
+// CRLF line endings.
+const defaultCRLF = true
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// debugHelp lists the debugger commands.
+const debugHelp = `commands:
+  next, n            run to the next command, stepping over function calls
+  step, s            run to the next command, stepping into function calls
+  continue, c        run to the next breakpoint
+  break, b [LINE]    set a breakpoint at LINE, or list breakpoints
+  delete, d LINE     remove the breakpoint at LINE
+  print, p VAR...    print shell variables
+  bt                 print the function stack
+  list, l            print the lines around the current command
+  quit, q            stop the script
+an empty line repeats the previous command
+`
+
+// runDebug steps through script interactively, reading debugger commands
+// from in and writing to out. Returns the exit status of the script.
+func runDebug(ctx context.Context, d *dash.Dash, name string, script string, in io.Reader, out io.Writer) (int, error) {
+	s := &debugSession{d: d, name: name, in: bufio.NewScanner(in), out: out}
+	g, err := d.NewDebugger(ctx, script, dash.Source{Name: name, Line: 1}, s.stop)
+	if err != nil {
+		return 1, err
+	}
+	fmt.Fprintf(out, "debugging %s, type help for commands\n", name)
+	status, err := g.Run(ctx, dash.DebugStep)
+	if t := (*dash.TerminatedError)(nil); errors.As(err, &t) && s.quit {
+		return t.Code, nil
+	}
+	return status, err
+}
+
+// debugSession is the state of an interactive debugging session.
+type debugSession struct {
+	d    *dash.Dash
+	name string
+	in   *bufio.Scanner
+	out  io.Writer
+	// last is the previous command, repeated by an empty line.
+	last []string
+	quit bool
+}
+
+// stop implements dash.DebugStopFunc, prompting for commands until one
+// resumes the script.
+func (s *debugSession) stop(ctx context.Context, g *dash.Debugger, line int) dash.DebugAction {
+	fmt.Fprintf(s.out, "%s:%d: %s\n", s.name, line, strings.TrimSpace(g.Line(line)))
+	for {
+		fmt.Fprint(s.out, "(debug) ")
+		if !s.in.Scan() {
+			// No more input: let the script finish.
+			fmt.Fprintln(s.out)
+			for _, bp := range g.Breakpoints() {
+				g.ClearBreakpoint(bp)
+			}
+			return dash.DebugContinue
+		}
+		args := strings.Fields(s.in.Text())
+		if len(args) == 0 {
+			args = s.last
+		}
+		s.last = args
+		if action, resume := s.command(ctx, g, line, args); resume {
+			return action
+		}
+	}
+}
+
+// command runs a debugger command. resume reports whether the script
+// should continue with action.
+func (s *debugSession) command(ctx context.Context, g *dash.Debugger, line int, args []string) (action dash.DebugAction, resume bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch args[0] {
+	case "next", "n":
+		return dash.DebugNext, true
+	case "step", "s":
+		return dash.DebugStep, true
+	case "continue", "c":
+		return dash.DebugContinue, true
+	case "break", "b":
+		if len(args) == 1 {
+			for _, bp := range g.Breakpoints() {
+				fmt.Fprintf(s.out, "breakpoint at %s:%d\n", s.name, bp)
+			}
+			return 0, false
+		}
+		for _, arg := range args[1:] {
+			n, err := strconv.Atoi(arg)
+			if err == nil {
+				err = g.SetBreakpoint(n)
+			}
+			if err != nil {
+				fmt.Fprintf(s.out, "cannot set breakpoint %s: %v\n", arg, err)
+				continue
+			}
+			fmt.Fprintf(s.out, "breakpoint at %s:%d\n", s.name, n)
+		}
+	case "delete", "d":
+		for _, arg := range args[1:] {
+			n, err := strconv.Atoi(arg)
+			if err != nil || !slices.Contains(g.Breakpoints(), n) {
+				fmt.Fprintf(s.out, "no breakpoint at %s\n", arg)
+				continue
+			}
+			g.ClearBreakpoint(n)
+		}
+	case "print", "p":
+		for _, name := range args[1:] {
+			value, err := s.d.GetVar(ctx, name)
+			if err != nil {
+				fmt.Fprintf(s.out, "%s: %v\n", name, err)
+				continue
+			}
+			fmt.Fprintf(s.out, "%s=%q\n", name, value)
+		}
+	case "bt", "backtrace":
+		for i, f := range g.Stack() {
+			if f.Function == "" {
+				fmt.Fprintf(s.out, "#%d  %s:%d\n", i, s.name, f.Line)
+				continue
+			}
+			call := strings.Join(append([]string{f.Function}, f.Args...), " ")
+			fmt.Fprintf(s.out, "#%d  %s (%s:%d)\n", i, call, s.name, f.Line)
+		}
+	case "list", "l":
+		for n := max(1, line-3); n <= line+3; n++ {
+			text := g.Line(n)
+			if n > line && text == "" && g.Line(n+1) == "" {
+				break
+			}
+			marker := " "
+			if n == line {
+				marker = ">"
+			} else if slices.Contains(g.Breakpoints(), n) {
+				marker = "*"
+			}
+			fmt.Fprintf(s.out, "%s%4d  %s\n", marker, n, text)
+		}
+	case "quit", "q":
+		s.quit = true
+		_ = s.d.Terminate(1)
+		return dash.DebugContinue, true
+	case "help", "h":
+		fmt.Fprint(s.out, debugHelp)
+	default:
+		fmt.Fprintf(s.out, "unknown command %q, type help for commands\n", args[0])
+	}
+	return 0, false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/tetratelabs/wazero"
+)
+
+const debugTestScript = `greet() {
+  msg="hello $1"
+  echo "$msg"
+}
+x=1
+greet world
+echo "done $x"
+`
+
+func newDebugDash(t *testing.T) (*dash.Dash, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	t.Cleanup(func() { _ = r.Close(ctx) })
+	var stdout bytes.Buffer
+	d, err := dash.NewDash(ctx, dash.WithRuntime(r), dash.WithModuleConfig(wazero.NewModuleConfig().WithStdout(&stdout)))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	return d, &stdout
+}
+
+func TestRunDebug(t *testing.T) {
+	d, stdout := newDebugDash(t)
+	ctx := context.Background()
+
+	in := strings.NewReader("b 7\nb 4\nn\n\ns\np msg x\nbt\nc\nc\n")
+	var out bytes.Buffer
+	status, err := runDebug(ctx, d, "t.sh", debugTestScript, in, &out)
+	if err != nil {
+		t.Fatal("runDebug:", err)
+	}
+	if status != 0 {
+		t.Fatalf("expected status 0, got %d", status)
+	}
+	for _, want := range []string{
+		"t.sh:1: greet() {\n",
+		"breakpoint at t.sh:7\n",
+		"cannot set breakpoint 4: dash: no command starts at line 4\n",
+		"t.sh:5: x=1\n",
+		"t.sh:6: greet world\n",
+		"t.sh:2: msg=\"hello $1\"\n",
+		"msg=\"\"\nx=\"1\"\n",
+		"#0  greet world (t.sh:2)\n#1  t.sh:6\n",
+		"t.sh:7: echo \"done $x\"\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+	if got := stdout.String(); got != "hello world\ndone 1\n" {
+		t.Fatalf("unexpected script output %q", got)
+	}
+}
+
+func TestRunDebugQuit(t *testing.T) {
+	d, stdout := newDebugDash(t)
+	ctx := context.Background()
+
+	status, err := runDebug(ctx, d, "t.sh", debugTestScript, strings.NewReader("n\nq\n"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal("runDebug:", err)
+	}
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d", status)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no script output, got %q", stdout.String())
+	}
+}
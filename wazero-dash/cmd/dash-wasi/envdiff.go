@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// envDiffMounts returns the options mounting dirs, and the writable mounts
+// of policy, as overlays for the env-diff subcommand, so the script cannot
+// change the host directories, and the guest paths of the overlays.
+// Read-only policy mounts are kept as they are.
+func envDiffMounts(dirs []dirMount, policy *dash.Policy) ([]dash.Option, []string) {
+	var opts []dash.Option
+	var overlays []string
+	for _, m := range dirs {
+		opts = append(opts, dash.WithOverlayDir(m.host, m.guest))
+		overlays = append(overlays, m.guest)
+	}
+	if policy != nil {
+		sandboxed := *policy
+		sandboxed.Mounts = nil
+		for _, m := range policy.Mounts {
+			if m.ReadOnly {
+				sandboxed.Mounts = append(sandboxed.Mounts, m)
+				continue
+			}
+			opts = append(opts, dash.WithOverlayDir(m.Host, m.Guest))
+			overlays = append(overlays, m.Guest)
+		}
+		opts = append(opts, dash.WithPolicy(&sandboxed))
+	}
+	return append(opts, dash.WithJournal()), overlays
+}
+
+// runEnvDiff runs code like runScript and prints to w the exported
+// variables it created, modified or unset, then the files it changed: the
+// differences of the overlays, whose guest paths are given, and the net
+// changes recorded by the journal elsewhere, leaving out those made before.
+// Returns the script status.
+func runEnvDiff(ctx context.Context, d *dash.Dash, code string, src dash.Source, overlays []string, w io.Writer) (int, error) {
+	before, err := d.ExportedVars(ctx)
+	if err != nil {
+		return -1, err
+	}
+	changed := d.FileChanges()
+	d.ResetJournal()
+	status := runScript(ctx, d, code, src, false)
+	after, err := d.ExportedVars(ctx)
+	if err != nil {
+		return status, err
+	}
+
+	vars := dash.DiffVars(before, after)
+	files := slices.DeleteFunc(d.FileChanges(), func(f dash.FileChange) bool {
+		return slices.Contains(changed, f)
+	})
+	files = append(files, journalChanges(d.Journal(), func(p string) bool {
+		return slices.ContainsFunc(overlays, func(guest string) bool { return underPath(p, guest) })
+	})...)
+	slices.SortFunc(files, func(a, b dash.FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	fmt.Fprintf(w, "status %d\n", status)
+	for _, v := range vars {
+		switch v.Kind {
+		case dash.VarCreated:
+			fmt.Fprintf(w, "var created %s=%s\n", v.Name, dash.Quote(v.New))
+		case dash.VarModified:
+			fmt.Fprintf(w, "var modified %s=%s (was %s)\n", v.Name, dash.Quote(v.New), dash.Quote(v.Old))
+		default:
+			fmt.Fprintf(w, "var %s %s\n", v.Kind, v.Name)
+		}
+	}
+	for _, f := range files {
+		fmt.Fprintf(w, "file %s %s\n", f.Kind, f.Path)
+	}
+	if len(vars) == 0 && len(files) == 0 {
+		fmt.Fprintln(w, "no changes")
+	}
+	return status, nil
+}
+
+// underPath reports whether the guest path p is dir or below it.
+func underPath(p, dir string) bool {
+	dir = path.Clean(dir)
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// journalChanges summarizes entries as the net change of each path they
+// touch, except the paths skip reports: a path missing before its first
+// mutation and present after its last one was created, and so on. A write
+// leaving the contents of a file as they were is not a change.
+func journalChanges(entries []dash.JournalEntry, skip func(string) bool) []dash.FileChange {
+	type pathState struct{ existed, exists bool }
+	states := make(map[string]*pathState)
+	touch := func(p string, op dash.FileChangeKind) {
+		if skip(p) {
+			return
+		}
+		s := states[p]
+		if s == nil {
+			s = &pathState{existed: op != dash.FileCreated}
+			states[p] = s
+		}
+		s.exists = op != dash.FileDeleted
+	}
+	for _, e := range entries {
+		switch e.Op {
+		case dash.JournalCreate, dash.JournalMkdir, dash.JournalSymlink:
+			touch(e.Path, dash.FileCreated)
+		case dash.JournalWrite:
+			if _, seen := states[e.Path]; !seen && e.BeforeHash != "" && e.BeforeHash == e.AfterHash {
+				continue
+			}
+			touch(e.Path, dash.FileModified)
+		case dash.JournalChmod:
+			touch(e.Path, dash.FileModified)
+		case dash.JournalRemove, dash.JournalRmdir:
+			touch(e.Path, dash.FileDeleted)
+		case dash.JournalRename:
+			touch(e.Path, dash.FileDeleted)
+			touch(e.Target, dash.FileCreated)
+		case dash.JournalLink:
+			touch(e.Target, dash.FileCreated)
+		}
+	}
+	var changes []dash.FileChange
+	for p, s := range states {
+		switch {
+		case !s.existed && s.exists:
+			changes = append(changes, dash.FileChange{Path: p, Kind: dash.FileCreated})
+		case s.existed && !s.exists:
+			changes = append(changes, dash.FileChange{Path: p, Kind: dash.FileDeleted})
+		case s.existed:
+			changes = append(changes, dash.FileChange{Path: p, Kind: dash.FileModified})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/tetratelabs/wazero"
+)
+
+func TestRunEnvDiff(t *testing.T) {
+	ctx := context.Background()
+	host := t.TempDir()
+	if err := os.WriteFile(filepath.Join(host, "keep"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := wazero.NewRuntime(ctx)
+	t.Cleanup(func() { _ = r.Close(ctx) })
+	mounts, overlays := envDiffMounts([]dirMount{{host: host, guest: "/proj"}}, nil)
+	var stdout bytes.Buffer
+	opts := append(mounts, dash.WithRuntime(r), dash.WithModuleConfig(wazero.NewModuleConfig().WithStdout(&stdout)))
+	d, err := dash.NewDash(ctx, opts...)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "export GONE=1 KEPT=1 EDITED=old"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	script := `echo hi
+export NEW='a b' EDITED=new
+unset GONE
+true >/proj/out || :
+true >/tmp/scratch || :
+exit 3`
+	var report bytes.Buffer
+	status, err := runEnvDiff(ctx, d, script, dash.Source{Name: "install.sh", Line: 1}, overlays, &report)
+	if err != nil || status != 3 {
+		t.Fatalf("runEnvDiff = %d, %v", status, err)
+	}
+	want := `status 3
+var modified EDITED=new (was old)
+var unset GONE
+var created NEW='a b'
+file created /proj/out
+file created /tmp/scratch
+`
+	if report.String() != want {
+		t.Fatalf("report:\n%s\nwant:\n%s", report.String(), want)
+	}
+	if stdout.String() != "hi\n" {
+		t.Fatalf("script printed %q", stdout.String())
+	}
+	if entries, _ := os.ReadDir(host); len(entries) != 1 {
+		t.Fatalf("the host directory was changed: %v", entries)
+	}
+
+	report.Reset()
+	if _, err := runEnvDiff(ctx, d, "x=1", dash.Source{}, overlays, &report); err != nil || report.String() != "status 0\nno changes\n" {
+		t.Fatalf("report %q, %v", report.String(), err)
+	}
+}
+
+func TestJournalChanges(t *testing.T) {
+	entries := []dash.JournalEntry{
+		{Op: dash.JournalCreate, Path: "/tmp/a"},
+		{Op: dash.JournalWrite, Path: "/tmp/a"},
+		{Op: dash.JournalCreate, Path: "/tmp/gone"},
+		{Op: dash.JournalRemove, Path: "/tmp/gone"},
+		{Op: dash.JournalWrite, Path: "/tmp/same", BeforeHash: "h", AfterHash: "h"},
+		{Op: dash.JournalWrite, Path: "/tmp/edited", BeforeHash: "h", AfterHash: "i"},
+		{Op: dash.JournalRename, Path: "/tmp/old", Target: "/tmp/new"},
+		{Op: dash.JournalMkdir, Path: "/skip/dir"},
+	}
+	got := journalChanges(entries, func(p string) bool { return underPath(p, "/skip") })
+	slices.SortFunc(got, func(a, b dash.FileChange) int { return strings.Compare(a.Path, b.Path) })
+	want := []dash.FileChange{
+		{Path: "/tmp/a", Kind: dash.FileCreated},
+		{Path: "/tmp/edited", Kind: dash.FileModified},
+		{Path: "/tmp/new", Kind: dash.FileCreated},
+		{Path: "/tmp/old", Kind: dash.FileDeleted},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("journalChanges = %v, want %v", got, want)
+	}
+}
@@ -5,31 +5,233 @@
 //	dash-wasi              # interactive REPL
 //	dash-wasi -c 'echo hi' # execute a command string
 //	dash-wasi script.sh    # execute a script file
+//	dash-wasi -crlf x.sh   # execute a script with CRLF line endings
+//	dash-wasi -n x.sh      # check the syntax of a script without running it
+//	dash-wasi < script.sh  # execute a script read from stdin
+//	dash-wasi -dir C:\work # mount a host directory (at /c/work)
+//	dash-wasi -modes strict -dir . # fail chmod the host cannot apply exactly
+//	dash-wasi -symlinks within-root -dir . x.sh # keep symlinks inside -dir mounts
+//	dash-wasi -readonly -dir . x.sh # run a script without file system writes
+//	dash-wasi -mount-archive assets.tgz:/assets x.sh # mount an archive read-only
+//	dash-wasi -env-file .env x.sh # export the variables of a .env file
+//	dash-wasi -init-script setup.sh # run a setup script before the REPL
+//	dash-wasi -locale C.UTF-8 x.sh # run a script in a UTF-8 locale
+//	dash-wasi -policy sandbox.json x.sh # run a script under a reviewed policy
+//	dash-wasi -policy sandbox.json -describe # print what the policy allows
+//	dash-wasi -image alpine.tar # use a docker save archive as the root file system
+//	dash-wasi debug script.sh # step through a script with breakpoints
+//	dash-wasi -dir . env-diff install.sh # report what a script would change
+//	dash-wasi -profile trace.json x.sh # record a timeline of a script
+//	dash-wasi -trace trace.jsonl x.sh # record the events of a script as JSON lines
+//	dash-wasi -snapshot window deep.sh # bound the C stack saved at each setjmp
+//	dash-wasi trace view trace.jsonl # print a recorded trace
+//	dash-wasi verify-reactor # check the embedded reactor against version.go
+//	dash-wasi run https://example.com/x.sh -sha256 HASH # run a verified download
+//	dash-wasi -stdlib -c 'retry 3 fetch' # use the shell function library
+//	dash-wasi -checkpoints # REPL with the checkpoint and restore commands
+//	dash-wasi -json -c 'json get -f app.json .version' # add the json command
+//	dash-wasi -history ~/.dash_history # REPL recording lines, with the history command
 package main
 
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 
 	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
-	"github.com/tetratelabs/wazero"
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/repl"
 )
 
 func main() {
+	command := flag.String("c", "", "execute the given command string and exit")
+	crlf := flag.Bool("crlf", defaultCRLF, "normalize CRLF line endings in scripts")
+	noExec := flag.Bool("n", false, "check the syntax of the -c command or script and exit without running it")
+	var dirs dirFlag
+	flag.Var(&dirs, "dir", "mount a host directory as `host[:guest]` (repeatable)")
+	var archives archiveFlag
+	flag.Var(&archives, "mount-archive", "mount a zip, tar or tar.gz archive read-only as `file:guest` (repeatable)")
+	image := flag.String("image", "", "use the layers of the image archive `file` (docker save or OCI layout) as the root file system")
+	var envFiles []string
+	flag.Func("env-file", "export the variables of the dotenv `file` (repeatable)", func(name string) error {
+		envFiles = append(envFiles, name)
+		return nil
+	})
+	var initScripts []string
+	flag.Func("init-script", "evaluate the script `file` after initialization (repeatable)", func(name string) error {
+		initScripts = append(initScripts, name)
+		return nil
+	})
+	locale := flag.String("locale", "", "set LANG to the locale `name`: C, POSIX or a UTF-8 locale")
+	policyFile := flag.String("policy", "", "apply the JSON sandbox policy in `file`; other flags add to it")
+	describe := flag.Bool("describe", false, "print the capabilities the -policy grants and exit")
+	var modes dash.ModeMapping
+	flag.TextVar(&modes, "modes", dash.ModeBestEffort, "apply chmod on -dir mounts as `mapping`: ignore, best-effort or strict")
+	var symlinks dash.SymlinkPolicy
+	flag.TextVar(&symlinks, "symlinks", dash.SymlinkFollow, "follow symlinks on -dir mounts as `policy`: follow, deny or within-root")
+	var snapshot dash.SnapshotStrategy
+	flag.TextVar(&snapshot, "snapshot", dash.SnapshotFullStack, "save the C stack at setjmp as `strategy`: full or window")
+	readOnly := flag.Bool("readonly", false, "make the guest file system read-only")
+	stdlib := flag.Bool("stdlib", false, "load the embedded shell function library")
+	checkpoints := flag.Bool("checkpoints", false, "add the checkpoint and restore commands")
+	jsonCmd := flag.Bool("json", false, "add the json command")
+	historyFile := flag.String("history", "", "record the REPL lines in `file` and add the history command")
+	profile := flag.String("profile", "", "write a Chrome trace-event timeline of the run to `file`")
+	traceFile := flag.String("trace", "", "write the events of the run to `file` as JSON lines")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dash-wasi [flags] [-c command | script | - | debug script | env-diff script | run url -sha256 hash | trace view file | verify-reactor [file.wasm]]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// trace subcommand: print a trace without starting a shell.
+	if flag.Arg(0) == "trace" {
+		if err := runTrace(flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ctx := context.Background()
 
-	r := wazero.NewRuntime(ctx)
-	defer r.Close(ctx)
+	// verify-reactor subcommand: check a reactor build without starting a
+	// shell.
+	if flag.Arg(0) == "verify-reactor" {
+		ok, err := runVerifyReactor(ctx, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
 
-	config := wazero.NewModuleConfig().
-		WithStdin(os.Stdin).
-		WithStdout(os.Stdout).
-		WithStderr(os.Stderr)
+	// env-diff subcommand: the script writes to stderr, leaving stdout to
+	// the report, and runs on overlays of the mounts.
+	envDiff := flag.NArg() == 2 && flag.Arg(0) == "env-diff"
+	stdout := io.Writer(os.Stdout)
+	if envDiff {
+		stdout = os.Stderr
+	}
+	opts := []dash.Option{dash.WithStdio(os.Stdin, stdout, os.Stderr), dash.WithInterrupts()}
+	var policy *dash.Policy
+	if *policyFile != "" {
+		var err error
+		if policy, err = loadPolicy(*policyFile); err != nil {
+			log.Fatal(err)
+		}
+		if !envDiff {
+			opts = append(opts, dash.WithPolicy(policy))
+		}
+	}
+	if *describe {
+		if policy == nil {
+			log.Fatal("-describe requires -policy")
+		}
+		fmt.Print(policy.Describe())
+		return
+	}
+	if policy == nil || isFlagSet("modes") {
+		opts = append(opts, dash.WithModeMapping(modes))
+	}
+	if policy == nil || isFlagSet("symlinks") {
+		opts = append(opts, dash.WithSymlinkPolicy(symlinks))
+	}
+	var overlays []string
+	if envDiff {
+		var mounts []dash.Option
+		mounts, overlays = envDiffMounts(dirs, policy)
+		opts = append(opts, mounts...)
+	} else {
+		for _, m := range dirs {
+			opts = append(opts, dash.WithDirMount(m.host, m.guest))
+		}
+	}
+	for _, m := range archives {
+		f, err := os.Open(m.host)
+		if err != nil {
+			log.Fatalf("failed to open archive: %v", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			log.Fatalf("failed to open archive: %v", err)
+		}
+		opts = append(opts, dash.WithArchiveMount(f, info.Size(), m.guest))
+	}
+	if *image != "" {
+		f, err := os.Open(*image)
+		if err != nil {
+			log.Fatalf("failed to open image: %v", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			log.Fatalf("failed to open image: %v", err)
+		}
+		opts = append(opts, dash.WithImageTarball(f, info.Size()))
+	}
+	for _, name := range envFiles {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatalf("failed to open env file: %v", err)
+		}
+		defer f.Close()
+		opts = append(opts, dash.WithEnvFile(f))
+	}
+	for _, name := range initScripts {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatalf("failed to read init script: %v", err)
+		}
+		opts = append(opts, dash.WithInitScript(string(src)))
+	}
+	if *locale != "" {
+		opts = append(opts, dash.WithLocale(*locale))
+	}
+	if isFlagSet("snapshot") {
+		opts = append(opts, dash.WithSnapshotStrategy(snapshot))
+	}
+	if *readOnly {
+		opts = append(opts, dash.WithReadOnlyFS())
+	}
+	if *stdlib {
+		opts = append(opts, dash.WithStdlib())
+	}
+	if *checkpoints {
+		opts = append(opts, dash.WithSessionCheckpoints())
+	}
+	if *jsonCmd {
+		opts = append(opts, dash.WithJSONCommand())
+	}
+	var history dash.History
+	if *historyFile != "" {
+		h, err := dash.OpenFileHistory(*historyFile)
+		if err != nil {
+			log.Fatalf("failed to open history: %v", err)
+		}
+		defer h.Close()
+		history = h
+		opts = append(opts, dash.WithHistory(h))
+	}
+	var trace *bufio.Writer
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			log.Fatalf("failed to create trace: %v", err)
+		}
+		defer f.Close()
+		trace = bufio.NewWriter(f)
+		opts = append(opts, dash.WithTraceWriter(trace))
+	}
 
-	d, err := dash.NewDash(ctx, r, config)
+	d, err := dash.NewDash(ctx, opts...)
 	if err != nil {
 		log.Fatalf("failed to create dash: %v", err)
 	}
@@ -38,57 +240,214 @@ func main() {
 	if err := d.Init(ctx, nil); err != nil {
 		log.Fatalf("failed to init dash: %v", err)
 	}
+	d.SetNormalizeCRLF(*crlf)
 
-	// -c flag: execute command and exit.
-	if len(os.Args) >= 3 && os.Args[1] == "-c" {
-		status, err := d.Eval(ctx, os.Args[2])
-		if err != nil {
-			log.Fatalf("eval error: %v", err)
+	var profiler *dash.Profiler
+	if *profile != "" {
+		profiler = &dash.Profiler{}
+		d.SetProfiler(profiler)
+	}
+	exit := func(status int) {
+		if trace != nil {
+			// Close the shell first to trace it.
+			_ = d.Close(ctx)
+			if err := trace.Flush(); err != nil {
+				log.Fatalf("failed to write trace: %v", err)
+			}
+		}
+		if profiler != nil {
+			if err := writeProfile(*profile, profiler); err != nil {
+				log.Fatalf("failed to write profile: %v", err)
+			}
 		}
 		os.Exit(status)
 	}
 
-	// File argument: read and execute.
-	if len(os.Args) >= 2 && os.Args[1] != "-" {
-		code, err := os.ReadFile(os.Args[1])
+	// -c flag: execute command and exit.
+	if isFlagSet("c") {
+		if *noExec {
+			exit(checkSyntax(ctx, d, *command, "-c"))
+		}
+		exit(runScript(ctx, d, *command, dash.Source{}, profiler != nil))
+	}
+
+	// debug subcommand: step through a script.
+	if flag.NArg() == 2 && flag.Arg(0) == "debug" {
+		script := flag.Arg(1)
+		code, err := os.ReadFile(script)
 		if err != nil {
-			log.Fatalf("failed to read %s: %v", os.Args[1], err)
+			log.Fatalf("failed to read %s: %v", script, err)
 		}
-		status, err := d.Eval(ctx, string(code))
+		status, err := runDebug(ctx, d, script, string(code), os.Stdin, os.Stderr)
 		if err != nil {
-			log.Fatalf("eval error: %v", err)
+			log.Fatalf("debug error: %v", err)
 		}
-		os.Exit(status)
+		exit(status)
 	}
 
-	// Interactive REPL.
-	runREPL(ctx, d)
-}
-
-func runREPL(ctx context.Context, d *dash.Dash) {
-	fmt.Fprintln(os.Stderr, "dash-wasi (POSIX shell in WASM, type 'exit' or Ctrl+D to quit)")
+	// env-diff subcommand: run a script in a sandbox and report what it
+	// changed.
+	if envDiff {
+		script := flag.Arg(1)
+		code, err := os.ReadFile(script)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", script, err)
+		}
+		status, err := runEnvDiff(ctx, d, string(code), dash.Source{Name: script, Line: 1}, overlays, os.Stdout)
+		if err != nil {
+			log.Fatalf("env-diff error: %v", err)
+		}
+		exit(status)
+	}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Fprint(os.Stderr, "$ ")
-		if !scanner.Scan() {
-			fmt.Fprintln(os.Stderr)
-			break
+	// run subcommand: fetch a script, verify its digest and execute it.
+	if flag.Arg(0) == "run" {
+		url, sum, err := parseRunArgs(flag.Args()[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := checkRunHost(policy, url); err != nil {
+			log.Fatal(err)
 		}
+		code, err := fetchScript(ctx, runClient(httpClient, policy), url, sum)
+		if err != nil {
+			log.Fatal(err)
+		}
+		exit(runScript(ctx, d, string(code), dash.Source{Name: url, Line: 1}, profiler != nil))
+	}
 
-		line := scanner.Text()
-		if line == "exit" || line == "quit" {
-			break
+	// File argument: read and execute.
+	if script := flag.Arg(0); script != "" && script != "-" {
+		code, err := os.ReadFile(script)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", script, err)
 		}
-		if line == "" {
-			continue
+		if *noExec {
+			exit(checkSyntax(ctx, d, string(code), script))
 		}
+		exit(runScript(ctx, d, string(code), dash.Source{Name: script, Line: 1}, profiler != nil))
+	}
 
-		status, err := d.Eval(ctx, line)
+	// Script on stdin: read and execute, as sh does when not on a terminal.
+	if flag.Arg(0) == "-" || !isTerminal(os.Stdin) {
+		code, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			continue
+			log.Fatalf("failed to read stdin: %v", err)
+		}
+		if *noExec {
+			exit(checkSyntax(ctx, d, string(code), "stdin"))
 		}
-		_ = status
+		exit(runScript(ctx, d, string(code), dash.Source{Name: "stdin", Line: 1}, profiler != nil))
+	}
+
+	exit(runInteractive(ctx, d, history))
+}
+
+// reportedError returns err, or nil for a syntax error, which dash has
+// already reported on stderr as sh does.
+func reportedError(err error) error {
+	var se *dash.SyntaxError
+	if errors.As(err, &se) {
+		return nil
+	}
+	return err
+}
+
+// checkSyntax reports the syntax error of code, if any, for -n. Returns
+// the exit status of sh -n: 2 for a syntax error, else 0.
+func checkSyntax(ctx context.Context, d *dash.Dash, code, name string) int {
+	err := d.CheckSyntax(ctx, code)
+	var se *dash.SyntaxError
+	switch {
+	case errors.As(err, &se):
+		fmt.Fprintf(os.Stderr, "%s: %d: Syntax error: %s\n", name, se.Line, se.Message)
+		return 2
+	case err != nil:
+		log.Fatalf("syntax check error: %v", err)
+	}
+	return 0
+}
+
+// loadPolicy reads the sandbox policy in the file name.
+func loadPolicy(name string) (*dash.Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dash.LoadPolicy(f)
+}
+
+// writeProfile writes the recorded timeline to the file name.
+func writeProfile(name string, p *dash.Profiler) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteChromeTrace(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runInteractive runs an interactive session and returns the exit status.
+// The lines are recorded in history unless it is nil.
+func runInteractive(ctx context.Context, d *dash.Dash, history dash.History) int {
+	restore := setupConsole()
+	defer restore()
+
+	// dash's own command loop does not hand the lines to the host.
+	if history != nil {
+		runREPL(ctx, d, history)
+		return 0
+	}
+	// Prefer dash's own command loop.
+	status, err := d.RunInteractive(ctx)
+	if err == nil {
+		return status
+	}
+	if !errors.Is(err, dash.ErrNotAvailable) {
+		fmt.Fprintf(os.Stderr, "interactive error: %v\n", err)
+		return 1
+	}
+	runREPL(ctx, d, nil)
+	return 0
+}
+
+// isTerminal reports whether f is connected to a terminal or console.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// isFlagSet reports whether the named flag was passed on the command line.
+func isFlagSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// runREPL runs a line-oriented REPL for modules without dash_run_interactive.
+//
+// Ctrl+C sends a SIGINT to the running command, or runs the INT trap at the
+// prompt; a second Ctrl+C aborts commands that ignore it. Lines are
+// recorded in history unless it is nil.
+func runREPL(ctx context.Context, d *dash.Dash, history dash.History) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	err := repl.Run(ctx, d, repl.Options{
+		History:    history,
+		Interrupts: sigs,
+		Banner:     "dash-wasi (POSIX shell in WASM, type 'exit' or Ctrl+D to quit)",
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// dirMount is a host directory exposed to the guest.
+type dirMount struct {
+	host  string
+	guest string
+}
+
+// dirFlag collects repeated -dir flags.
+type dirFlag []dirMount
+
+// String implements flag.Value.
+func (f *dirFlag) String() string {
+	parts := make([]string, len(*f))
+	for i, m := range *f {
+		parts[i] = m.host + ":" + m.guest
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value.
+func (f *dirFlag) Set(spec string) error {
+	m, err := parseDirMount(spec)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, m)
+	return nil
+}
+
+// archiveFlag collects repeated -mount-archive flags.
+type archiveFlag []dirMount
+
+// String implements flag.Value.
+func (f *archiveFlag) String() string {
+	return (*dirFlag)(f).String()
+}
+
+// Set implements flag.Value.
+func (f *archiveFlag) Set(spec string) error {
+	m, err := parseMount("-mount-archive", spec)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, m)
+	return nil
+}
+
+// parseDirMount parses a -dir value of the form host[:guest].
+//
+// A Windows drive letter prefix (C:\work) is not treated as a separator.
+// Without an explicit guest path the host path is reused, with drive-letter
+// paths translated to POSIX form: C:\work becomes /c/work.
+func parseDirMount(spec string) (dirMount, error) {
+	return parseMount("-dir", spec)
+}
+
+// parseMount parses a mount flag value of the form host[:guest], naming the
+// flag in errors.
+func parseMount(name, spec string) (dirMount, error) {
+	host, guest := spec, ""
+	if i := strings.LastIndexByte(spec, ':'); i > 0 && !(i == 1 && hasDriveLetter(spec)) {
+		host, guest = spec[:i], spec[i+1:]
+	}
+	if host == "" {
+		return dirMount{}, errors.New("empty host path in " + name + " " + spec)
+	}
+	if guest == "" {
+		guest = guestPath(host)
+	}
+	if !strings.HasPrefix(guest, "/") {
+		return dirMount{}, errors.New("guest path must be absolute in " + name + " " + spec)
+	}
+	return dirMount{host: host, guest: guest}, nil
+}
+
+// hasDriveLetter reports whether p starts with a Windows drive letter.
+func hasDriveLetter(p string) bool {
+	if len(p) < 2 || p[1] != ':' {
+		return false
+	}
+	c := p[0] | 0x20
+	return c >= 'a' && c <= 'z'
+}
+
+// guestPath translates a host path to the guest path it is mounted at by
+// default.
+func guestPath(host string) string {
+	if !hasDriveLetter(host) {
+		return strings.ReplaceAll(host, "\\", "/")
+	}
+	rest := strings.ReplaceAll(host[2:], "\\", "/")
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return strings.TrimSuffix("/"+string(host[0]|0x20)+rest, "/")
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestParseDirMount(t *testing.T) {
+	tests := []struct {
+		spec  string
+		host  string
+		guest string
+	}{
+		{"/srv/data", "/srv/data", "/srv/data"},
+		{"/srv/data:/data", "/srv/data", "/data"},
+		{`C:\work`, `C:\work`, "/c/work"},
+		{`C:\work\`, `C:\work\`, "/c/work"},
+		{`D:\`, `D:\`, "/d"},
+		{`C:\work:/src`, `C:\work`, "/src"},
+		{`c:/Users/me:/home/me`, "c:/Users/me", "/home/me"},
+	}
+	for _, tc := range tests {
+		m, err := parseDirMount(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		if m.host != tc.host || m.guest != tc.guest {
+			t.Fatalf("%q: expected %q:%q, got %q:%q", tc.spec, tc.host, tc.guest, m.host, m.guest)
+		}
+	}
+
+	for _, spec := range []string{"", ":/data", "/srv:data"} {
+		if _, err := parseDirMount(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestArchiveFlag(t *testing.T) {
+	var f archiveFlag
+	if err := f.Set("assets.tgz:/assets"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != 1 || f[0].host != "assets.tgz" || f[0].guest != "/assets" {
+		t.Fatalf("unexpected mounts %+v", f)
+	}
+	if err := f.Set("assets.tgz"); err == nil || err.Error() != "guest path must be absolute in -mount-archive assets.tgz" {
+		t.Fatalf("expected a guest path error, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// maxRunScript is the largest script the run subcommand fetches.
+const maxRunScript = 16 << 20
+
+// httpClient fetches scripts for the run subcommand. Tests replace it.
+var httpClient = http.DefaultClient
+
+// parseRunArgs parses the arguments of `run URL -sha256 HASH`. The flag
+// may come before or after the URL.
+func parseRunArgs(args []string) (url, sum string, err error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&sum, "sha256", "", "expected SHA-256 digest of the script, in hex")
+	if err := fs.Parse(args); err != nil {
+		return "", "", err
+	}
+	if fs.NArg() > 0 {
+		url = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return "", "", err
+		}
+	}
+	switch {
+	case url == "" || fs.NArg() > 0:
+		return "", "", errors.New("usage: dash-wasi run URL -sha256 HASH")
+	case !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://"):
+		return "", "", errors.New("run URL must be http or https: " + url)
+	case sum == "":
+		return "", "", errors.New("run requires -sha256 HASH")
+	}
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return "", "", errors.New("invalid -sha256 digest: " + sum)
+	}
+	return url, strings.ToLower(sum), nil
+}
+
+// checkRunHost returns an error if policy, if any, does not allow fetching
+// rawURL.
+func checkRunHost(policy *dash.Policy, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if policy != nil && !policy.AllowsHost(u.Hostname()) {
+		return errors.New("run URL host not allowed by policy: " + u.Hostname())
+	}
+	return nil
+}
+
+// maxRunRedirects is the number of redirects the run subcommand follows,
+// as http.Client does by default.
+const maxRunRedirects = 10
+
+// runClient returns a copy of client that checks the host of every
+// redirect with checkRunHost, so a redirect cannot leave the hosts policy
+// allows.
+func runClient(client *http.Client, policy *dash.Policy) *http.Client {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRunRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRunRedirects)
+		}
+		return checkRunHost(policy, req.URL.String())
+	}
+	return &c
+}
+
+// fetchScript downloads the script at url with client and returns it if
+// its SHA-256 digest is sum. Nothing of a mismatching script is returned.
+func fetchScript(ctx context.Context, client *http.Client, url, sum string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	code, err := io.ReadAll(io.LimitReader(resp.Body, maxRunScript+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(code) > maxRunScript {
+		return nil, fmt.Errorf("script %s exceeds %d bytes", url, maxRunScript)
+	}
+	digest := sha256.Sum256(code)
+	if got := hex.EncodeToString(digest[:]); got != strings.ToLower(sum) {
+		return nil, fmt.Errorf("sha256 mismatch for %s: got %s, expected %s", url, got, sum)
+	}
+	return code, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	const sum = "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"
+	for _, args := range [][]string{
+		{"https://example.com/x.sh", "-sha256", sum},
+		{"--sha256", sum, "https://example.com/x.sh"},
+		{"https://example.com/x.sh", "--sha256=" + sum},
+	} {
+		url, got, err := parseRunArgs(args)
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		if url != "https://example.com/x.sh" || got != strings.ToLower(sum) {
+			t.Fatalf("%q: unexpected %q %q", args, url, got)
+		}
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"https://example.com/x.sh"},
+		{"https://example.com/x.sh", "-sha256", "abc"},
+		{"file:///etc/x.sh", "-sha256", sum},
+		{"https://example.com/x.sh", "extra", "-sha256", sum},
+	} {
+		if _, _, err := parseRunArgs(args); err == nil {
+			t.Fatalf("%q: expected error", args)
+		}
+	}
+}
+
+func TestFetchScript(t *testing.T) {
+	const script = "echo fetched\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x.sh" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(script))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte(script))
+	sum := hex.EncodeToString(digest[:])
+
+	code, err := fetchScript(ctx, srv.Client(), srv.URL+"/x.sh", sum)
+	if err != nil {
+		t.Fatal("fetchScript:", err)
+	}
+	if string(code) != script {
+		t.Fatalf("unexpected script %q", code)
+	}
+
+	if _, err := fetchScript(ctx, srv.Client(), srv.URL+"/x.sh", strings.Repeat("0", 64)); err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Fatalf("expected a digest mismatch, got %v", err)
+	}
+	if _, err := fetchScript(ctx, srv.Client(), srv.URL+"/missing.sh", sum); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a 404 error, got %v", err)
+	}
+
+	// The fetched script runs in the sandbox.
+	d, stdout := newDebugDash(t)
+	if status := runScript(ctx, d, string(code), dash.Source{Name: srv.URL + "/x.sh", Line: 1}, false); status != 0 {
+		t.Fatal("runScript:", status)
+	}
+	if stdout.String() != "fetched\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestCheckRunHost(t *testing.T) {
+	p, err := dash.LoadPolicy(strings.NewReader(`{"network": {"allow": ["*.example.com"]}}`))
+	if err != nil {
+		t.Fatal("LoadPolicy:", err)
+	}
+	if err := checkRunHost(p, "https://get.example.com/x.sh"); err != nil {
+		t.Fatal("checkRunHost:", err)
+	}
+	if err := checkRunHost(p, "https://evil.com/x.sh"); err == nil {
+		t.Fatal("expected evil.com to be denied")
+	}
+	if err := checkRunHost(nil, "https://evil.com/x.sh"); err != nil {
+		t.Fatal("checkRunHost without policy:", err)
+	}
+}
+
+func TestRunClientRedirect(t *testing.T) {
+	const script = "echo fetched\n"
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x.sh":
+			_, _ = w.Write([]byte(script))
+		case "/local":
+			http.Redirect(w, r, "/x.sh", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, "http://evil.com/x.sh", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	p, err := dash.LoadPolicy(strings.NewReader(`{"network": {"allow": ["127.0.0.1"]}}`))
+	if err != nil {
+		t.Fatal("LoadPolicy:", err)
+	}
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte(script))
+	sum := hex.EncodeToString(digest[:])
+	client := runClient(srv.Client(), p)
+
+	if code, err := fetchScript(ctx, client, srv.URL+"/local", sum); err != nil || string(code) != script {
+		t.Fatalf("redirect within the policy: %q, %v", code, err)
+	}
+	if _, err := fetchScript(ctx, client, srv.URL+"/away", sum); err == nil || !strings.Contains(err.Error(), "not allowed by policy: evil.com") {
+		t.Fatalf("expected the redirect to evil.com to be denied, got %v", err)
+	}
+	if _, err := fetchScript(ctx, client, srv.URL+"/loop", sum); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Fatalf("expected the redirect loop to stop, got %v", err)
+	}
+	if srv.Client().CheckRedirect != nil {
+		t.Fatal("runClient changed the client it was given")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// runScript runs code for the -c, script, stdin and run modes and returns
+// the status to exit with, as sh script.sh would: exit N sets it, the EXIT
+// trap runs, commands that cannot run fail with 126 or 127 and a SIGINT
+// gives 130. With lines set, it runs through a debugger so the profiler
+// records each command of the script, without those exit semantics.
+func runScript(ctx context.Context, d *dash.Dash, code string, src dash.Source, lines bool) int {
+	name := src.Name
+	if name == "" {
+		name = "dash"
+	}
+	d.SetCommandNotFoundHandler(commandNotFound(d, name))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	status, err := evalInterruptibly(ctx, d, sigs, func(ctx context.Context) (int, error) {
+		if !lines {
+			return d.EvalScript(ctx, code, src)
+		}
+		g, err := d.NewDebugger(ctx, code, src, nil)
+		if err != nil {
+			return -1, err
+		}
+		return g.Run(ctx, dash.DebugContinue)
+	})
+	switch {
+	case errors.Is(err, dash.ErrSIGINT), errors.Is(err, dash.ErrInterrupted):
+		return dash.SIGINTStatus
+	case reportedError(err) != nil:
+		log.Fatalf("eval error: %v", err)
+	}
+	return status
+}
+
+// commandNotFound returns a handler reporting the commands dash cannot run
+// as sh does: the reactor cannot execute files, so 126 for a path that
+// exists and 127 otherwise. name prefixes the messages.
+func commandNotFound(d *dash.Dash, name string) dash.CommandNotFoundHandler {
+	return func(ctx context.Context, cmd string, args []string) (bool, int) {
+		if strings.Contains(cmd, "/") {
+			if paths, err := d.Glob(ctx, globQuote(cmd)); err == nil && len(paths) != 0 {
+				fmt.Fprintf(os.Stderr, "%s: %s: Permission denied\n", name, cmd)
+				return true, 126
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: not found\n", name, cmd)
+		return true, dash.NotFoundStatus
+	}
+}
+
+// globQuote quotes the pattern characters of p for Dash.Glob.
+func globQuote(p string) string {
+	var b strings.Builder
+	for _, c := range p {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// evalInterruptibly runs eval, sending the first SIGINT received meanwhile
+// to the shell and aborting the evaluation at the next ones.
+func evalInterruptibly(ctx context.Context, d *dash.Dash, sigs <-chan os.Signal, eval func(context.Context) (int, error)) (int, error) {
+	evalCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		status, err := eval(evalCtx)
+		done <- result{status, err}
+	}()
+	interrupted := false
+	for {
+		select {
+		case r := <-done:
+			return r.status, r.err
+		case <-sigs:
+			if interrupted {
+				cancel()
+				continue
+			}
+			interrupted = true
+			fmt.Fprintln(os.Stderr)
+			if err := d.Interrupt(ctx); err != nil {
+				return -1, err
+			}
+		}
+	}
+}
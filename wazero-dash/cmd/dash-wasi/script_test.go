@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+func TestRunScript(t *testing.T) {
+	ctx := context.Background()
+	d, stdout := newDebugDash(t)
+	for _, tc := range []struct {
+		script, stdout string
+		status         int
+	}{
+		{"echo a; exit 3; echo b", "a\n", 3},
+		{`trap 'echo "bye $?"' EXIT; set -e; false`, "bye 1\n", 1},
+		{"nosuchcommand", "", 127},
+		{"/no/such/file", "", 127},
+		{"/tmp", "", 126},
+		{"fi", "", 2},
+	} {
+		stdout.Reset()
+		if status := runScript(ctx, d, tc.script, dash.Source{Name: "x.sh", Line: 1}, false); status != tc.status || stdout.String() != tc.stdout {
+			t.Errorf("%s: got %d, %q; want %d, %q", tc.script, status, stdout.String(), tc.status, tc.stdout)
+		}
+		if _, err := d.Eval(ctx, "set +e"); err != nil {
+			t.Fatal("Eval:", err)
+		}
+	}
+}
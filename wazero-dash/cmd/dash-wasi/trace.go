@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// maxTraceDetail is the longest script or output text viewTrace prints.
+const maxTraceDetail = 120
+
+// runTrace runs the trace subcommand with args, currently only
+// `view FILE`, where FILE - reads stdin.
+func runTrace(args []string, w io.Writer) error {
+	if len(args) != 2 || args[0] != "view" {
+		return errors.New("usage: dash-wasi trace view FILE")
+	}
+	in := os.Stdin
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	out := bufio.NewWriter(w)
+	if err := viewTrace(out, in); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// viewTrace prints the records of the trace r as a timeline: the time since
+// the first record, the shell and the record details.
+func viewTrace(w io.Writer, r io.Reader) error {
+	var start time.Time
+	return dash.ReadTrace(r, func(rec dash.TraceRecord) error {
+		if start.IsZero() {
+			start = rec.Time
+		}
+		offset := rec.Time.Sub(start).Seconds() * 1000
+		_, err := fmt.Fprintf(w, "%10.3fms  #%d %-13s %s\n", offset, rec.Shell, rec.Type, traceDetail(rec))
+		return err
+	})
+}
+
+// traceDetail formats the fields of rec.
+func traceDetail(rec dash.TraceRecord) string {
+	var b strings.Builder
+	switch {
+	case rec.Argv != nil:
+		for i, arg := range rec.Argv {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(dash.Quote(arg))
+		}
+	case rec.Script != "":
+		b.WriteString(truncate(strconv.Quote(rec.Script)))
+	case rec.Var != nil:
+		fmt.Fprintf(&b, "%s %s %q -> %q", rec.Var.Name, rec.Var.Kind, rec.Var.Old, rec.Var.New)
+	case rec.File != nil:
+		b.WriteString(rec.File.Op + " " + rec.File.Path)
+		if rec.File.Target != "" {
+			b.WriteString(" -> " + rec.File.Target)
+		}
+	case rec.Stream != "":
+		b.WriteString(rec.Stream + " " + truncate(strconv.Quote(rec.Data)))
+	}
+	if rec.Status != nil {
+		fmt.Fprintf(&b, " = %d", *rec.Status)
+	}
+	if rec.DurationNs > 0 {
+		fmt.Fprintf(&b, " (%v)", time.Duration(rec.DurationNs))
+	}
+	if rec.Error != "" {
+		b.WriteString(" error: " + rec.Error)
+	}
+	return b.String()
+}
+
+// truncate shortens s to maxTraceDetail bytes.
+func truncate(s string) string {
+	if len(s) <= maxTraceDetail {
+		return s
+	}
+	return s[:maxTraceDetail] + "..."
+}
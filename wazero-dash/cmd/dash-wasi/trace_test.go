@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+func TestViewTrace(t *testing.T) {
+	ctx := context.Background()
+	var trace bytes.Buffer
+	d, err := dash.NewDash(ctx, dash.WithTraceWriter(&trace), dash.WithStdio(nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int { return 2 })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "echo hi; greet 'a b'"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	var out bytes.Buffer
+	if err := viewTrace(&out, &trace); err != nil {
+		t.Fatal("viewTrace:", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	for i, want := range []string{
+		"#1 created",
+		`#1 eval-started  "echo hi; greet 'a b'"`,
+		`#1 output        stdout "hi\n"`,
+		"#1 command       greet 'a b' = 2 (",
+		`#1 eval-finished "echo hi; greet 'a b'" = 2 (`,
+		"#1 closed",
+	} {
+		if i >= len(lines) || !strings.Contains(lines[i], want) {
+			t.Fatalf("line %d: expected %q in:\n%s", i, want, out.String())
+		}
+	}
+	if !strings.HasPrefix(lines[0], "     0.000ms") {
+		t.Fatalf("expected times relative to the first record, got %q", lines[0])
+	}
+
+	if err := runTrace([]string{"show", "x"}, &out); err == nil {
+		t.Fatal("expected a usage error")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// runVerifyReactor runs the verify-reactor subcommand with args, an
+// optional reactor module file checked instead of the embedded one, and
+// prints the report to w. Returns false if the reactor drifted or failed a
+// check.
+func runVerifyReactor(ctx context.Context, args []string, w io.Writer) (bool, error) {
+	wasm := dashwasi.DashWASM
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		if wasm, err = os.ReadFile(args[0]); err != nil {
+			return false, err
+		}
+	default:
+		return false, errors.New("usage: dash-wasi verify-reactor [file.wasm]")
+	}
+	report, err := dash.VerifyReactor(ctx, wasm)
+	if err != nil {
+		return false, err
+	}
+	printReactorReport(w, report)
+	return report.OK(), nil
+}
+
+// printReactorReport prints report, one line per drift or failure.
+func printReactorReport(w io.Writer, report *dash.ReactorReport) {
+	fmt.Fprintf(w, "reactor: version %s, commit %s, sha256 %s\n", orNone(report.Version), orNone(report.Commit), report.SHA256)
+	for _, drift := range report.Drift {
+		fmt.Fprintln(w, "drift:", drift)
+	}
+	for _, failure := range report.Failures {
+		fmt.Fprintln(w, "fail:", failure)
+	}
+	if report.OK() {
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
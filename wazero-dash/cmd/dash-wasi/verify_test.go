@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+func TestVerifyReactor(t *testing.T) {
+	var out bytes.Buffer
+	ok, err := runVerifyReactor(context.Background(), nil, &out)
+	if err != nil || !ok {
+		t.Fatalf("runVerifyReactor = %v, %v\n%s", ok, err, &out)
+	}
+	if !strings.HasSuffix(out.String(), "\nok\n") {
+		t.Fatalf("expected the report to end with ok, got:\n%s", &out)
+	}
+
+	out.Reset()
+	printReactorReport(&out, &dash.ReactorReport{
+		SHA256:   "abc",
+		Drift:    []string{"no dash.version section records the version"},
+		Failures: []string{"quoting: got \"\", want \"a\""},
+	})
+	want := "reactor: version none, commit none, sha256 abc\n" +
+		"drift: no dash.version section records the version\n" +
+		"fail: quoting: got \"\", want \"a\"\n"
+	if out.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, &out)
+	}
+}
@@ -0,0 +1,24 @@
+package dash
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// hostCommand implements a command in Go, dispatched before the
+// ExecHandler. Returns the exit status.
+type hostCommand func(ctx context.Context, d *Dash, argv []string) int
+
+// registerCommand adds a host command.
+func (s *dashState) registerCommand(name string, cmd hostCommand) {
+	if s.commands == nil {
+		s.commands = make(map[string]hostCommand)
+	}
+	s.commands[name] = cmd
+}
+
+// commandError writes a diagnostic for a host command to the guest stderr.
+func commandError(ctx context.Context, mod api.Module, state *dashState, name, msg string) {
+	_ = guestWrite(ctx, mod, state, fdStderr, []byte(name+": "+msg+"\n"))
+}
@@ -0,0 +1,338 @@
+// Package complete implements dash-aware tab completion for shells run with
+// the wazero-dash library, for use by REPLs, IDEs and other frontends:
+//
+//	for _, c := range complete.Complete(ctx, d, line, cursor) {
+//		fmt.Println(line[:c.Start] + c.Text + line[cursor:])
+//	}
+//
+// Commands (builtins, keywords, aliases and the host commands listed in
+// /bin), paths, variables and the options of common builtins are completed.
+// Shell functions are not: dash cannot list them.
+package complete
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// Kind is the kind of a completion candidate.
+type Kind int
+
+// Candidate kinds.
+const (
+	// KindCommand is a builtin, keyword, alias or host command.
+	KindCommand Kind = iota
+	// KindPath is a file or directory. Directories end with a slash.
+	KindPath
+	// KindVariable is a shell variable reference.
+	KindVariable
+	// KindOption is a command option.
+	KindOption
+)
+
+// String returns the kind name.
+func (k Kind) String() string {
+	switch k {
+	case KindCommand:
+		return "command"
+	case KindPath:
+		return "path"
+	case KindVariable:
+		return "variable"
+	case KindOption:
+		return "option"
+	default:
+		return "unknown"
+	}
+}
+
+// Candidate is a possible completion of the word at the cursor.
+type Candidate struct {
+	// Text replaces the word being completed, line[Start:cursor]. It is
+	// quoted for the shell.
+	Text string
+	Kind Kind
+	// Start is the byte offset in the line of the word being completed.
+	Start int
+	// Description is a short explanation, set for options.
+	Description string
+}
+
+// Builtins lists the dash builtin commands.
+var Builtins = []string{
+	".", ":", "[", "alias", "bg", "break", "cd", "chdir", "command",
+	"continue", "echo", "eval", "exec", "exit", "export", "false", "fg",
+	"getopts", "hash", "jobs", "kill", "local", "printf", "pwd", "read",
+	"readonly", "return", "set", "shift", "test", "times", "trap", "true",
+	"type", "ulimit", "umask", "unalias", "unset", "wait",
+}
+
+// Keywords lists the dash reserved words.
+var Keywords = []string{
+	"!", "case", "do", "done", "elif", "else", "esac", "fi", "for", "if",
+	"in", "then", "until", "while", "{", "}",
+}
+
+// Option is a command option offered for completion.
+type Option struct {
+	Flag        string
+	Description string
+}
+
+// Options lists the options completed per command.
+var Options = map[string][]Option{
+	"cd":       {{"-L", "follow symbolic links"}, {"-P", "use the physical directory structure"}},
+	"command":  {{"-p", "use the default PATH"}, {"-v", "print the command's pathname"}, {"-V", "describe the command"}},
+	"echo":     {{"-n", "do not print the trailing newline"}},
+	"export":   {{"-p", "list exported variables"}},
+	"hash":     {{"-r", "forget remembered locations"}},
+	"jobs":     {{"-l", "list process IDs"}, {"-p", "list process group IDs only"}},
+	"kill":     {{"-l", "list signal names"}, {"-s", "send the named signal"}},
+	"mktemp":   {{"-d", "create a directory"}, {"-p", "use the given directory"}, {"-q", "fail silently"}, {"-t", "interpret the template relative to the temp directory"}},
+	"read":     {{"-p", "print a prompt"}, {"-r", "do not treat backslashes as escapes"}},
+	"readonly": {{"-p", "list read-only variables"}},
+	"set": {
+		{"-a", "export assigned variables"}, {"-C", "do not overwrite files with >"},
+		{"-e", "exit on failure"}, {"-f", "disable pathname expansion"},
+		{"-n", "read commands without executing them"}, {"-u", "fail on unset variables"},
+		{"-v", "print input lines"}, {"-x", "trace commands"},
+	},
+	"ulimit": {{"-a", "show all limits"}, {"-H", "use the hard limit"}, {"-S", "use the soft limit"}},
+	"umask":  {{"-S", "use symbolic output"}},
+	"unset":  {{"-f", "unset functions"}, {"-v", "unset variables"}},
+}
+
+// commandKeywords are the reserved words followed by a command name.
+var commandKeywords = []string{"!", "{", "do", "elif", "else", "if", "then", "until", "while"}
+
+// Complete returns the completion candidates for the word ending at the
+// byte offset cursor in line, sorted. Shell state such as variables and
+// aliases is read from d; $? is left unchanged.
+func Complete(ctx context.Context, d *dash.Dash, line string, cursor int) []Candidate {
+	cursor = max(0, min(cursor, len(line)))
+	w := currentWord(line[:cursor])
+
+	var out []Candidate
+	add := func(text string, kind Kind, desc string) {
+		out = append(out, Candidate{Text: text, Kind: kind, Start: w.start, Description: desc})
+	}
+	switch {
+	case w.variable >= 0:
+		prefix, open := w.raw[w.variable+1:], false
+		if strings.HasPrefix(prefix, "{") {
+			prefix, open = prefix[1:], true
+		}
+		names, _ := d.VarNames(ctx)
+		for _, name := range names {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if open {
+				add(w.raw[:w.variable]+"${"+name+"}", KindVariable, "")
+			} else {
+				add(w.raw[:w.variable]+"$"+name, KindVariable, "")
+			}
+		}
+	case w.command && !strings.Contains(w.text, "/"):
+		for _, name := range commands(ctx, d) {
+			if strings.HasPrefix(name, w.text) {
+				add(quote(name), KindCommand, "")
+			}
+		}
+	case strings.HasPrefix(w.text, "-") && !w.redirect:
+		for _, opt := range Options[w.name] {
+			if strings.HasPrefix(opt.Flag, w.text) {
+				add(opt.Flag, KindOption, opt.Description)
+			}
+		}
+	default:
+		for _, p := range paths(ctx, d, w.text) {
+			add(quote(p), KindPath, "")
+		}
+	}
+	slices.SortFunc(out, func(a, b Candidate) int {
+		return strings.Compare(a.Text, b.Text)
+	})
+	return slices.CompactFunc(out, func(a, b Candidate) bool {
+		return a.Text == b.Text
+	})
+}
+
+// commands returns the command names known to the shell.
+func commands(ctx context.Context, d *dash.Dash) []string {
+	names := slices.Concat(Builtins, Keywords)
+	aliases, _ := d.Aliases(ctx)
+	for name := range aliases {
+		names = append(names, name)
+	}
+	bin, _ := d.Glob(ctx, dash.BinDir+"/*")
+	for _, p := range bin {
+		names = append(names, path.Base(p))
+	}
+	return names
+}
+
+// paths returns the guest paths starting with prefix, directories with a
+// trailing slash.
+func paths(ctx context.Context, d *dash.Dash, prefix string) []string {
+	pattern := escapeGlob(prefix) + "*"
+	files, _ := d.Glob(ctx, pattern)
+	dirs, _ := d.Glob(ctx, pattern+"/")
+	for _, dir := range dirs {
+		if i := slices.Index(files, strings.TrimSuffix(dir, "/")); i >= 0 {
+			files[i] = dir
+		}
+	}
+	return files
+}
+
+// escapeGlob quotes the pattern characters in s for dash.Glob.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]!\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// quote backslash-escapes the characters of s special to the shell.
+func quote(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(" \t\n'\"\\$`;&|()<>*?[]#~=%", c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// word is the word being completed.
+type word struct {
+	// start is the byte offset of the word in the line.
+	start int
+	// raw is the word as typed; text is the word unquoted.
+	raw, text string
+	// variable is the offset in raw of a trailing $name reference being
+	// completed, or -1.
+	variable int
+	// command reports whether the word is in command position; name is
+	// the command the word is an argument of otherwise.
+	command bool
+	name    string
+	// redirect reports whether the word is the target of a redirection.
+	redirect bool
+}
+
+// token is a shell word or operator.
+type token struct {
+	text string
+	op   bool
+}
+
+// currentWord splits line into shell tokens and returns the last one,
+// which ends at the cursor.
+func currentWord(line string) word {
+	var tokens []token
+	var text strings.Builder
+	start, inWord := 0, false
+	var quote byte
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{text: text.String()})
+			text.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				text.WriteByte(c)
+			}
+			continue
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '\\' && i+1 < len(line):
+			i++
+			text.WriteByte(line[i])
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+			continue
+		case strings.IndexByte(";&|()<>", c) >= 0:
+			flush()
+			op := string(c)
+			if i+1 < len(line) && slices.Contains([]string{"&&", "||", ">>", ";;"}, line[i:i+2]) {
+				op = line[i : i+2]
+				i++
+			}
+			tokens = append(tokens, token{text: op, op: true})
+			continue
+		default:
+			text.WriteByte(c)
+		}
+		if !inWord {
+			inWord, start = true, i
+		}
+	}
+
+	// The word at the cursor is not flushed into tokens.
+	w := word{start: len(line), variable: -1}
+	if inWord {
+		w.start, w.raw, w.text = start, line[start:], text.String()
+	}
+	if quote != '\'' {
+		if i := strings.LastIndexByte(w.raw, '$'); i >= 0 && isNamePrefix(strings.TrimPrefix(w.raw[i+1:], "{")) {
+			w.variable = i
+		}
+	}
+
+	// Find the command the word belongs to.
+	w.command = true
+	for _, t := range tokens {
+		switch {
+		case t.op && (t.text == "<" || t.text == ">" || t.text == ">>"):
+			w.redirect = true
+		case t.op:
+			w.command, w.name, w.redirect = true, "", false
+		case w.redirect:
+			w.redirect = false
+		case w.command && (slices.Contains(commandKeywords, t.text) || isAssignment(t.text)):
+		case w.command:
+			w.command, w.name = false, t.text
+		}
+	}
+	if w.redirect {
+		w.command = false
+	}
+	return w
+}
+
+// isAssignment reports whether s is a NAME=value variable assignment.
+func isAssignment(s string) bool {
+	name, _, ok := strings.Cut(s, "=")
+	return ok && name != "" && isNamePrefix(name)
+}
+
+// isNamePrefix reports whether s can start a shell variable name.
+func isNamePrefix(s string) bool {
+	for i, c := range s {
+		switch {
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
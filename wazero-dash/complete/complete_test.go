@@ -0,0 +1,103 @@
+package complete
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/tetratelabs/wazero"
+)
+
+func newTestDash(t *testing.T) *dash.Dash {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, name := range []string{"notes.txt", "new file", "src/main.go"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := wazero.NewRuntime(ctx)
+	t.Cleanup(func() { _ = r.Close(ctx) })
+	d, err := dash.NewDash(ctx, dash.WithRuntime(r), dash.WithDirMount(dir, "/work"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "GREETING=hi; GREP_OPTS=; alias greet='echo hi'; false"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	return d
+}
+
+func texts(cands []Candidate) []string {
+	out := make([]string, len(cands))
+	for i, c := range cands {
+		out[i] = c.Text
+	}
+	return out
+}
+
+func TestComplete(t *testing.T) {
+	d := newTestDash(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		line  string
+		kind  Kind
+		start int
+		want  []string
+	}{
+		{"ex", KindCommand, 0, []string{"exec", "exit", "export"}},
+		{"true && gre", KindCommand, 8, []string{"greet"}},
+		{"if mkt", KindCommand, 3, []string{"mktemp"}},
+		{"X=1 chm", KindCommand, 4, []string{"chmod"}},
+		{"echo $GRE", KindVariable, 5, []string{"$GREETING", "$GREP_OPTS"}},
+		{"echo ${GREE", KindVariable, 5, []string{"${GREETING}"}},
+		{"echo a$GREE", KindVariable, 5, []string{"a$GREETING"}},
+		{"set -", KindOption, 4, []string{"-C", "-a", "-e", "-f", "-n", "-u", "-v", "-x"}},
+		{"mktemp -", KindOption, 7, []string{"-d", "-p", "-q", "-t"}},
+		{"cat /work/n", KindPath, 4, []string{`/work/new\ file`, "/work/notes.txt"}},
+		{"cat /work/new\\ f", KindPath, 4, []string{`/work/new\ file`}},
+		{"cat '/work/new f", KindPath, 4, []string{`/work/new\ file`}},
+		{"echo > /work/s", KindPath, 7, []string{"/work/src/"}},
+		{"/work/s", KindPath, 0, []string{"/work/src/"}},
+		{"cat /work/src/", KindPath, 4, []string{"/work/src/main.go"}},
+		{"cat /work/x", KindPath, 4, nil},
+	}
+	for _, tt := range tests {
+		got := Complete(ctx, d, tt.line, len(tt.line))
+		if !slices.Equal(texts(got), tt.want) {
+			t.Errorf("Complete(%q) = %q, want %q", tt.line, texts(got), tt.want)
+			continue
+		}
+		for _, c := range got {
+			if c.Kind != tt.kind || c.Start != tt.start {
+				t.Errorf("Complete(%q): got kind %v start %d, want %v %d", tt.line, c.Kind, c.Start, tt.kind, tt.start)
+			}
+		}
+	}
+
+	// The cursor may sit before the end of the line.
+	if got := texts(Complete(ctx, d, "ex foo", 2)); !slices.Contains(got, "exit") {
+		t.Errorf("expected exit at cursor 2, got %q", got)
+	}
+	if got := Complete(ctx, d, "set -", 5); got[0].Description == "" {
+		t.Error("expected option descriptions")
+	}
+
+	// Completion leaves $? alone.
+	if status, _ := d.GetExitStatus(ctx); status != 1 {
+		t.Fatalf("expected exit status 1, got %d", status)
+	}
+}
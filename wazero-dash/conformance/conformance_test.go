@@ -0,0 +1,107 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/tetratelabs/wazero"
+)
+
+var update = flag.Bool("update", false, "regenerate golden files for the embedded reactor")
+
+// reactorFile records the reactor build the goldens were produced with.
+const reactorFile = "testdata/REACTOR"
+
+// reactorID identifies the embedded reactor build.
+func reactorID() string {
+	return dashwasi.Version + " " + dashwasi.Commit + "\n"
+}
+
+func TestConformance(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/*.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no conformance scripts found")
+	}
+
+	if *update {
+		if err := os.WriteFile(reactorFile, []byte(reactorID()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		recorded, err := os.ReadFile(reactorFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(recorded) != reactorID() {
+			t.Fatalf("goldens were recorded for reactor %q but %q is embedded: review and run with -update",
+				strings.TrimSpace(string(recorded)), strings.TrimSpace(reactorID()))
+		}
+	}
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".sh")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := run(t, name+".sh", string(src))
+
+			golden := strings.TrimSuffix(script, ".sh") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("output mismatch for %s\n--- want\n%s\n--- got\n%s", script, want, got)
+			}
+		})
+	}
+}
+
+// run evaluates a script in a fresh shell and returns its transcript.
+func run(t *testing.T, name, src string) []byte {
+	t.Helper()
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	var out bytes.Buffer
+	config := wazero.NewModuleConfig().
+		WithStdout(&out).
+		WithStderr(&out)
+
+	d, err := dash.NewDash(ctx, dash.WithRuntime(r), dash.WithModuleConfig(config))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	status, err := d.EvalWithSource(ctx, src, dash.Source{Name: name, Line: 1})
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	out.WriteString("[exit " + strconv.Itoa(status) + "]\n")
+	return out.Bytes()
+}
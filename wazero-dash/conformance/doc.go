@@ -0,0 +1,14 @@
+// Package conformance runs a curated POSIX sh conformance corpus against the
+// embedded dash reactor.
+//
+// Each testdata/*.sh script is evaluated in a fresh shell and its combined
+// stdout and stderr, followed by the exit status, is compared against the
+// matching .golden file. The corpus covers parameter expansion, quoting,
+// field splitting, arithmetic, traps and compound commands.
+//
+// The goldens are recorded against a specific reactor build, named in
+// testdata/REACTOR. After rebuilding dash.wasm, review the differences and
+// regenerate them with:
+//
+//	go test ./wazero-dash/conformance -update
+package conformance
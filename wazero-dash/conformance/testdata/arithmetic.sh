@@ -0,0 +1,17 @@
+# Arithmetic expansion from POSIX 2.6.4.
+echo $((1 + 2 * 3))
+echo $(( (1 + 2) * 3 ))
+echo $((7 / 2)) $((7 % 2)) $((-7 / 2))
+echo $((1 << 4)) $((256 >> 2))
+echo $((5 & 3)) $((5 | 3)) $((5 ^ 3)) $((~0))
+echo $((3 > 2)) $((3 < 2)) $((2 == 2)) $((2 != 2))
+echo $((1 && 0)) $((1 || 0)) $((!5))
+echo $((1 ? 10 : 20)) $((0 ? 10 : 20))
+x=5
+echo $((x + 1)) $(($x * 2))
+: $((x += 3))
+echo $x
+: $((x *= 2)) $((x -= 1))
+echo $x
+echo $((0x1f)) $((010))
+echo $((2147483647 + 1))
@@ -0,0 +1,29 @@
+# Compound commands and functions from POSIX 2.9.4 and 2.9.5.
+i=0
+while [ $i -lt 3 ]; do i=$((i + 1)); echo "while $i"; done
+until [ $i -eq 0 ]; do i=$((i - 1)); done
+echo "until $i"
+for w in a b c; do
+	case $w in
+	a) echo "case a" ;;
+	b|c) echo "case $w" ;;
+	esac
+done
+case foo.txt in *.sh) echo sh ;; *.txt) echo txt ;; esac
+if false; then echo no; elif true; then echo elif; else echo else; fi
+f() {
+	local_var=$1
+	return $2
+}
+f set 7
+echo "status $? $local_var"
+g() { echo "args $#: $*"; }
+g 1 "2 3"
+false || echo "or"
+true && echo "and"
+! false && echo "not"
+for n in 1 2 3 4; do
+	[ $n -eq 2 ] && continue
+	[ $n -eq 4 ] && break
+	echo "loop $n"
+done
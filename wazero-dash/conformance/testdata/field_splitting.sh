@@ -0,0 +1,19 @@
+# Field splitting and IFS from POSIX 2.6.5.
+count() { echo "$#"; }
+v='  one  two   three  '
+count $v
+IFS=:
+p='a:b::c'
+count $p
+for f in $p; do echo "[$f]"; done
+IFS=' :'
+q=' a : b '
+count $q
+for f in $q; do echo "[$f]"; done
+IFS=
+count $v
+unset IFS
+count $v
+IFS=,
+set -- x y z
+echo "$*"
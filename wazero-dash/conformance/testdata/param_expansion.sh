@@ -0,0 +1,19 @@
+# Parameter expansion forms from POSIX 2.6.2.
+unset u
+e=
+v=value
+echo "1 [${u-default}] [${e-default}] [${v-default}]"
+echo "2 [${u:-default}] [${e:-default}] [${v:-default}]"
+echo "3 [${u+alt}] [${e+alt}] [${v+alt}]"
+echo "4 [${u:+alt}] [${e:+alt}] [${v:+alt}]"
+echo "5 [${a=assigned}] [$a]"
+echo "6 [${#v}] [${#u}]"
+p=/usr/local/lib/file.tar.gz
+echo "7 [${p%.*}] [${p%%.*}] [${p#*/}] [${p##*/}]"
+echo "8 [${p%/*}] [${p#/usr}]"
+set -- one two three
+echo "9 [$#] [$1] [$3] [${4-none}]"
+echo "10 [$*]"
+shift 2
+echo "11 [$#] [$1]"
+echo "12 [${undefined_var:-$v}]"
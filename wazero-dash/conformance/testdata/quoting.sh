@@ -0,0 +1,17 @@
+# Quoting rules from POSIX 2.2.
+v='a  b'
+echo "1" $v
+echo "2" "$v"
+echo '3 $v "x"'
+echo "4 \$v \"x\" \\ \`"
+echo "5 'single' in double"
+echo 6 a\ b\ \ c
+echo "7 ${v}"suffix
+e=''
+echo "8 [$e]" [] "[]"
+set -- "x y" z
+for a in "$@"; do echo "9 [$a]"; done
+for a in $*; do echo "10 [$a]"; done
+echo "11 \a\b"
+echo 12 "multi
+line"
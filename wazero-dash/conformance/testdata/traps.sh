@@ -0,0 +1,11 @@
+# Trap builtin from POSIX 2.14.
+trap 'echo exiting' EXIT
+trap 'echo interrupted' INT
+trap
+trap - INT
+trap
+trap '' TERM
+trap
+trap - EXIT TERM
+trap
+echo done
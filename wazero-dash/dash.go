@@ -8,16 +8,36 @@
 package dash
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
-	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 )
 
+// ErrHostTrap is wrapped by errors returned when a host function aborted
+// the guest call because of invalid state, such as a corrupted jmp_buf.
+var ErrHostTrap = errors.New("dash: host function trap")
+
+// ErrNotAvailable is wrapped by errors returned when the loaded module does
+// not export an optional function needed by the called method.
+var ErrNotAvailable = errors.New("not available")
+
+// errNotAvailable returns an error reporting a missing optional export.
+func errNotAvailable(export string) error {
+	return fmt.Errorf("%s %w", export, ErrNotAvailable)
+}
+
 // dashStateKey is the context key for checkpoint state.
 type dashStateKey struct{}
 
@@ -26,6 +46,11 @@ type checkpoint struct {
 	snapshot     experimental.Snapshot
 	stackPointer uint32
 	cstack       []byte
+
+	// bufPtr and prevBuf record the jmp_buf written by setjmp and its
+	// previous contents, restored when the checkpoint is discarded.
+	bufPtr  uint32
+	prevBuf uint64
 }
 
 // ExecHandler is called when dash tries to execute an external command.
@@ -37,7 +62,164 @@ type ExecHandler func(ctx context.Context, argv []string) int
 // host functions and the Dash wrapper.
 type dashState struct {
 	checkpoints []*checkpoint
-	execHandler ExecHandler
+	// checkpointBase is the index of the first checkpoint of the running
+	// exported call; setjmp only discards checkpoints from there.
+	checkpointBase int
+	depth          int
+	execHandler    ExecHandler
+	hostCalls      map[string]HostCallFunc
+	commands       map[string]hostCommand
+	// registered are the commands added by RegisterBuiltin, kept apart
+	// from the host's own commands.
+	registered map[string]BuiltinFunc
+	builtins   map[string]BuiltinFunc
+	// commandNotFound is the fallback for unknown commands, or nil.
+	commandNotFound CommandNotFoundHandler
+
+	// commandTimeouts limits ExecHandler commands and builtin overrides.
+	commandTimeouts CommandTimeouts
+
+	// mounts are the guest file systems managed by Dash, tmp backs TempDir
+	// or is nil if disabled.
+	mounts []mount
+	tmp    *memFS
+	// overlays are the mounts added by WithOverlayDir and WithOverlayFS.
+	overlays []overlayState
+
+	// dash is the wrapper owning this state, used by host commands.
+	dash *Dash
+
+	sizeLimits   SizeLimits
+	sizeLimitHit bool
+
+	// meter enforces the Limits of the running EvalWithLimits, or is nil.
+	// limitHit is set when the evaluation exceeded one. metering is set by
+	// WithMetering.
+	meter    *evalMeter
+	limitHit *LimitExceededError
+	metering bool
+
+	// sessions holds the checkpoints of the checkpoint command, or is nil
+	// without WithSessionCheckpoints.
+	sessions *sessionCheckpoints
+
+	// history is the History of the history command, or is nil without
+	// WithHistory.
+	history History
+
+	// sigint tracks the SIGINTs sent by Interrupt, or is nil without
+	// WithInterrupts.
+	sigint *sigintState
+
+	// fdWrite and fdRead are the WASI fd_write and fd_read implementations,
+	// used by host functions to access the guest's stdio.
+	fdWrite api.GoModuleFunction
+	fdRead  api.GoModuleFunction
+	// fdClose closes host descriptors, keeping the standard streams open,
+	// fdAdvise is the WASI fd_advise, see hostFdOpen, and fdPrestatGet the
+	// WASI fd_prestat_get, see movePreopens.
+	fdClose      api.GoModuleFunction
+	fdAdvise     api.GoModuleFunction
+	fdPrestatGet api.GoModuleFunction
+	// fds maps the guest's file descriptors to the host's.
+	fds fdTable
+
+	// capture and captureErr receive guest stdout and stderr instead of
+	// the configured writers while set. See captureStdout.
+	capture    *bytes.Buffer
+	captureErr *bytes.Buffer
+	// stderrTail keeps the end of guest stderr while set, wherever it
+	// goes. See SourceFile.
+	stderrTail *stderrTail
+
+	heartbeat *heartbeat
+	// yield holds the yield points added by WithYield, or is nil.
+	yield *yieldState
+
+	// terminated is set by Terminate.
+	terminated atomic.Pointer[TerminatedError]
+
+	// diagnostics receives failure reports, recent holds the command
+	// history they include.
+	diagnostics func(*Diagnostics)
+	recent      []string
+
+	// quota caps file system writes on managed mounts; quotaUsage counts
+	// them and quotaHit is set when the current Eval exceeded the quota.
+	quota      WriteQuota
+	quotaUsage QuotaUsage
+	quotaHit   *QuotaExceededError
+
+	// events receives the lifecycle events, or is nil. See WithEvents.
+	events Events
+
+	// stackWindow bounds the C stack saved by setjmp, or is 0 to save all
+	// of it. See SnapshotStrategy.
+	stackWindow uint32
+
+	// functions lists the names of the functions defined by the host, for
+	// ListFunctions without dash_listfuncs. See trackFunctions.
+	functions []string
+
+	// fsStats counts the guest calls on each managed mount. See FSStats.
+	fsStats []*mountCounters
+
+	// journal records file mutations on managed mounts. See WithJournal.
+	journal *journal
+
+	// readOnly fails all file system writes with EROFS. See WithReadOnlyFS.
+	readOnly bool
+
+	// ps4 renders xtrace prefixes. See SetPS4Func.
+	ps4 PromptFunc
+
+	// debugger is the Debugger running, if any.
+	debugger *Debugger
+
+	// chunkDone is set when the chunk evaluated by EvalReader ran to its
+	// end.
+	chunkDone bool
+
+	// profiler records spans. See SetProfiler.
+	profiler *Profiler
+
+	// stdlib lists the library modules Init sources. See WithStdlib.
+	stdlib []string
+	// initScripts are the WithInitScript sources Init evaluates.
+	initScripts []string
+
+	// env holds the WithEnvFile assignments Init exports.
+	env []envAssignment
+
+	// input replaces the guest stdin during EvalWithInput.
+	input io.Reader
+	// stdin is the guest stdin given with WithStdio, or nil.
+	stdin *pollReader
+
+	// policy restricts the host commands, or is nil. See WithPolicy.
+	policy *Policy
+
+	// stdoutBuf buffers the WithStdio stdout, or is nil. See
+	// WithStdoutBuffer.
+	stdoutBuf *bufio.Writer
+
+	// faults is created by Dash.Faults, or nil.
+	faults *FaultInjector
+}
+
+// listenerFactories returns the function listeners the module must be
+// compiled with.
+func (s *dashState) listenerFactories() []experimental.FunctionListenerFactory {
+	var factories []experimental.FunctionListenerFactory
+	if s.heartbeat != nil {
+		if f := s.heartbeat.listenerFactory(); f != nil {
+			factories = append(factories, f)
+		}
+	}
+	if s.metering {
+		factories = append(factories, s.meterListenerFactory())
+	}
+	return factories
 }
 
 // Dash wraps a dash WASI reactor module providing a high-level API
@@ -47,6 +229,11 @@ type Dash struct {
 	mod     api.Module
 	state   *dashState
 
+	// compiled and config instantiated mod, to replace it if an
+	// interrupted call closed it.
+	compiled wazero.CompiledModule
+	config   wazero.ModuleConfig
+
 	malloc api.Function
 	free   api.Function
 
@@ -55,9 +242,47 @@ type Dash struct {
 	dashGetExitStatus api.Function
 	dashGetVar        api.Function
 	dashSetVar        api.Function
+	dashListVars      api.Function
+	dashUnsetVar      api.Function
+	dashExportVar     api.Function
+	dashSetVarRO      api.Function
+	dashListFuncs     api.Function
+	dashGetLastError  api.Function
+	dashSplitWords    api.Function
 	dashDestroy       api.Function
 
-	initialized bool
+	dashRunInteractive api.Function
+	dashParse          api.Function
+	dashCheckComplete  api.Function
+	dashQuote          api.Function
+
+	// arg0Ptr is the guest buffer dash uses as $0, holding arg0 between
+	// evaluations. See EvalWithSource.
+	arg0    string
+	arg0Ptr uint32
+
+	ps1 PromptFunc
+
+	// partial holds the lines given to EvalPartial whose command is not
+	// complete yet.
+	partial string
+
+	// args are the default arguments of Init, see WithArgs.
+	args []string
+	// ownsRuntime is set if Close also closes the runtime.
+	ownsRuntime bool
+
+	normalizeCRLF bool
+	initialized   bool
+	// initSnap is the guest memory at the end of Init, see Reset.
+	initSnap *memorySnapshot
+	// evalSnap is the snapshot of the last evaluation, see takeSnapshot.
+	evalSnap *memorySnapshot
+	// parseSnap is the snapshot parseOnly rolls back to, refreshed by each
+	// call.
+	parseSnap *memorySnapshot
+	// opts created the Dash, for Clone. It is nil with WithRuntime.
+	opts *options
 }
 
 // CompileDash compiles the embedded dash WASM module.
@@ -68,11 +293,138 @@ func CompileDash(ctx context.Context, r wazero.Runtime) (wazero.CompiledModule,
 
 // NewDash creates a new Dash instance using the embedded WASM reactor.
 // Call Close() when done to release resources.
-func NewDash(ctx context.Context, r wazero.Runtime, config wazero.ModuleConfig) (*Dash, error) {
-	state := &dashState{}
+//
+// Without WithRuntime, NewDash creates a runtime that Close releases. By
+// default the guest gets a private in-memory /tmp; see WithTempDir.
+func NewDash(ctx context.Context, opts ...Option) (*Dash, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return newDashWithOptions(ctx, &o)
+}
+
+// newDashWithOptions implements NewDash.
+func newDashWithOptions(ctx context.Context, o *options) (*Dash, error) {
+	if err := checkStdlibModules(o.stdlibModules); err != nil {
+		return nil, err
+	}
+	if o.locale != nil {
+		if _, err := ParseLocale(*o.locale); err != nil {
+			return nil, err
+		}
+	}
+	if o.runtime != nil && o.compilationCache != nil {
+		return nil, errors.New("dash: WithCompilationCache cannot be combined with WithRuntime")
+	}
+	if o.runtime == nil && o.compiled != nil {
+		return nil, errors.New("dash: WithCompiledModule requires WithRuntime")
+	}
+	if o.runtime == nil {
+		cache := o.compilationCache
+		if cache == nil {
+			var err error
+			if cache, err = SharedCompilationCache(dashwasi.DashWASM); err != nil {
+				return nil, err
+			}
+		}
+		config := newRuntimeConfig().WithCompilationCache(cache).WithCloseOnContextDone(true)
+		r := wazero.NewRuntimeWithConfig(ctx, config)
+		d, err := newDash(ctx, r, o)
+		if err != nil {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+		d.ownsRuntime = true
+		opts := *o
+		d.opts = &opts
+		return d, nil
+	}
+	return newDash(ctx, o.runtime, o)
+}
+
+// newDash creates a Dash in r configured by o.
+func newDash(ctx context.Context, r wazero.Runtime, o *options) (*Dash, error) {
+	config := o.moduleConfig
+	if config == nil {
+		config = wazero.NewModuleConfig()
+	}
+	var stdoutBuf *bufio.Writer
+	if o.stdout != nil {
+		stdout := o.stdout
+		if o.stdoutBuffer > 0 {
+			stdoutBuf = bufio.NewWriterSize(stdout, o.stdoutBuffer)
+			stdout = stdoutBuf
+		}
+		config = config.WithStdout(stdout)
+	}
+	if o.stderr != nil {
+		config = config.WithStderr(o.stderr)
+	}
+	if o.locale != nil {
+		config = config.WithEnv("LANG", *o.locale)
+	}
+	for _, kv := range o.env {
+		config = config.WithEnv(kv[0], kv[1])
+	}
+	env, err := readEnvFiles(o.envFiles)
+	if err != nil {
+		return nil, err
+	}
+	state := &dashState{diagnostics: o.diagnostics, events: o.events, stackWindow: o.stackWindow(), readOnly: o.readOnlyFS, stdlib: o.stdlibModules, initScripts: o.initScripts, metering: o.metering}
+	if o.policy != nil {
+		state.applyPolicy(o.policy)
+	}
+	state.env = append(state.env, env...)
+	state.stdoutBuf = stdoutBuf
+	if o.interrupts {
+		state.sigint = newSIGINTState()
+	}
+	if o.stdin != nil {
+		state.stdin = newPollReader(o.stdin, state.sigint)
+		config = config.WithStdin(state.stdin)
+	}
+	if o.heartbeat != nil {
+		state.heartbeat = &heartbeat{config: *o.heartbeat}
+	}
+	if o.yield != nil && o.yield.Func != nil {
+		state.yield = &yieldState{config: *o.yield}
+	}
+	config, err = applyFSOptions(config, o, state)
+	if err != nil {
+		return nil, err
+	}
+	state.registerCommand("chmod", chmodCommand)
+	if state.tmp != nil {
+		state.registerCommand("mktemp", mktempCommand)
+	}
+	if o.stdlib {
+		state.registerCommand("sleep", sleepCommand)
+	}
+	if o.jsonCommand {
+		state.registerCommand("json", jsonCommand)
+	}
+	if o.sessionCheckpoints {
+		state.sessions = &sessionCheckpoints{}
+		state.registerCommand("checkpoint", checkpointCommand)
+		state.registerCommand("restore", restoreCommand)
+	}
+	if o.history != nil {
+		state.history = o.history
+		state.registerCommand("history", historyCommand)
+	}
+
+	factories := state.listenerFactories()
+	if o.compiled != nil && len(factories) != 0 {
+		return nil, errors.New("dash: WithCompiledModule cannot be combined with HeartbeatConfig.Calls or WithMetering")
+	}
 
 	// Install WASI.
-	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+	wasi, err := compileWASI(ctx, r, state)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.InstantiateModule(ctx, wasi, wazero.NewModuleConfig()); err != nil {
 		return nil, err
 	}
 
@@ -87,76 +439,105 @@ func NewDash(ctx context.Context, r wazero.Runtime, config wazero.ModuleConfig)
 		NewFunctionBuilder().
 		WithFunc(execCommandHost).
 		Export("__exec_command").
+		NewFunctionBuilder().
+		WithFunc(fdDupHost).
+		Export(fdDupImport).
+		NewFunctionBuilder().
+		WithFunc(fdDup2Host).
+		Export(fdDup2Import).
+		NewFunctionBuilder().
+		WithFunc(fdPollHost).
+		Export(fdPollImport).
+		NewFunctionBuilder().
+		WithFunc(sizeCheckHost).
+		Export(sizeCheckImport).
 		Instantiate(ctx); err != nil {
 		return nil, err
 	}
 
-	compiled, err := CompileDash(ctx, r)
+	compiled := o.compiled
+	if compiled == nil {
+		compileCtx := ctx
+		if len(factories) != 0 {
+			compileCtx = experimental.WithFunctionListenerFactory(ctx, experimental.MultiFunctionListenerFactory(factories...))
+		}
+		if compiled, err = CompileDash(compileCtx, r); err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := newDashFromCompiled(ctx, r, compiled, config, state)
 	if err != nil {
 		return nil, err
 	}
-
-	return newDashFromCompiled(ctx, r, compiled, config, state)
+	d.args = o.args
+	state.publish(Event{Kind: EventCreated})
+	return d, nil
 }
 
 // newDashFromCompiled instantiates dash from a pre-compiled module.
 func newDashFromCompiled(ctx context.Context, r wazero.Runtime, compiled wazero.CompiledModule, config wazero.ModuleConfig, state *dashState) (*Dash, error) {
 	ctx = withDashState(ctx, state)
+	ctx = withMemoryLimit(ctx, state)
 
+	if err := ValidateABI(compiled); err != nil {
+		return nil, err
+	}
 	mod, err := r.InstantiateModule(ctx, compiled, config.WithName(dashwasi.DashWASMFilename))
 	if err != nil {
 		return nil, err
 	}
+	state.fds = state.movePreopens(ctx, mod)
 
 	// Call _initialize for WASI reactor startup.
 	initFn := mod.ExportedFunction("_initialize")
 	if initFn != nil {
 		if _, err := initFn.Call(ctx); err != nil {
 			_ = mod.Close(ctx)
-			return nil, errors.New("_initialize failed: " + err.Error())
+			return nil, trapError("_initialize", err)
 		}
 	}
 
-	d := &Dash{
-		runtime: r,
-		mod:     mod,
-		state:   state,
-
-		malloc: mod.ExportedFunction(dashwasi.ExportMalloc),
-		free:   mod.ExportedFunction(dashwasi.ExportFree),
-
-		dashInit:          mod.ExportedFunction(dashwasi.ExportDashInit),
-		dashEval:          mod.ExportedFunction(dashwasi.ExportDashEval),
-		dashGetExitStatus: mod.ExportedFunction(dashwasi.ExportDashGetExitStatus),
-		dashGetVar:        mod.ExportedFunction(dashwasi.ExportDashGetVar),
-		dashSetVar:        mod.ExportedFunction(dashwasi.ExportDashSetVar),
-		dashDestroy:       mod.ExportedFunction(dashwasi.ExportDashDestroy),
-	}
-
-	if d.malloc == nil {
+	d := &Dash{runtime: r, state: state, compiled: compiled, config: config}
+	if err := d.bind(mod); err != nil {
 		_ = mod.Close(ctx)
-		return nil, errors.New("missing export: " + dashwasi.ExportMalloc)
-	}
-	if d.free == nil {
-		_ = mod.Close(ctx)
-		return nil, errors.New("missing export: " + dashwasi.ExportFree)
-	}
-	if d.dashInit == nil {
-		_ = mod.Close(ctx)
-		return nil, errors.New("missing export: " + dashwasi.ExportDashInit)
-	}
-	if d.dashEval == nil {
-		_ = mod.Close(ctx)
-		return nil, errors.New("missing export: " + dashwasi.ExportDashEval)
-	}
-	if d.dashDestroy == nil {
-		_ = mod.Close(ctx)
-		return nil, errors.New("missing export: " + dashwasi.ExportDashDestroy)
+		return nil, err
 	}
-
+	state.dash = d
 	return d, nil
 }
 
+// bind makes mod the dash module instance.
+func (d *Dash) bind(mod api.Module) error {
+	d.mod = mod
+	d.malloc = mod.ExportedFunction(dashwasi.ExportMalloc)
+	d.free = mod.ExportedFunction(dashwasi.ExportFree)
+	d.dashInit = mod.ExportedFunction(dashwasi.ExportDashInit)
+	d.dashEval = mod.ExportedFunction(dashwasi.ExportDashEval)
+	d.dashGetExitStatus = mod.ExportedFunction(dashwasi.ExportDashGetExitStatus)
+	d.dashGetVar = mod.ExportedFunction(dashwasi.ExportDashGetVar)
+	d.dashSetVar = mod.ExportedFunction(dashwasi.ExportDashSetVar)
+	d.dashListVars = mod.ExportedFunction(dashwasi.ExportDashListVars)
+	d.dashUnsetVar = mod.ExportedFunction(dashwasi.ExportDashUnsetVar)
+	d.dashExportVar = mod.ExportedFunction(dashwasi.ExportDashExportVar)
+	d.dashSetVarRO = mod.ExportedFunction(dashwasi.ExportDashSetVarReadOnly)
+	d.dashListFuncs = mod.ExportedFunction(dashwasi.ExportDashListFuncs)
+	d.dashGetLastError = mod.ExportedFunction(dashwasi.ExportDashGetLastError)
+	d.dashSplitWords = mod.ExportedFunction(dashwasi.ExportDashSplitWords)
+	d.dashDestroy = mod.ExportedFunction(dashwasi.ExportDashDestroy)
+	d.dashRunInteractive = mod.ExportedFunction(dashwasi.ExportDashRunInteractive)
+	d.dashParse = mod.ExportedFunction(dashwasi.ExportDashParse)
+	d.dashCheckComplete = mod.ExportedFunction(dashwasi.ExportDashCheckComplete)
+	d.dashQuote = mod.ExportedFunction(dashwasi.ExportDashQuote)
+
+	for _, f := range dashwasi.ABI.Funcs {
+		if !f.Optional && mod.ExportedFunction(f.Name) == nil {
+			return &ExportMissingError{Name: f.Name}
+		}
+	}
+	return nil
+}
+
 // withDashState returns a context with snapshotter enabled and dash state attached.
 func withDashState(ctx context.Context, state *dashState) context.Context {
 	ctx = experimental.WithSnapshotter(ctx)
@@ -168,10 +549,111 @@ func (d *Dash) callCtx(ctx context.Context) context.Context {
 	return withDashState(ctx, d.state)
 }
 
+// scopeCheckpoints scopes setjmp checkpoints to one exported call.
+//
+// Once a call returns, the frames that called setjmp during it are gone and
+// their checkpoints can never be restored. The returned function discards
+// them. Calls nested inside host functions (for example a host call handler
+// running Eval) only discard their own checkpoints, leaving the caller's
+// intact.
+func (d *Dash) scopeCheckpoints() func() {
+	n, base := len(d.state.checkpoints), d.state.checkpointBase
+	d.state.checkpointBase = n
+	return func() {
+		d.state.truncateCheckpoints(d.mod.Memory(), n)
+		d.state.checkpointBase = base
+	}
+}
+
+// truncateCheckpoints discards checkpoints at index n and above.
+//
+// A nested call may reuse a jmp_buf owned by its caller (dash keeps some in
+// static storage), so each discarded checkpoint's jmp_buf is reset to the
+// value it held before setjmp overwrote it.
+func (s *dashState) truncateCheckpoints(mem api.Memory, n int) {
+	for i := len(s.checkpoints) - 1; i >= n; i-- {
+		cp := s.checkpoints[i]
+		mem.WriteUint64Le(cp.bufPtr, cp.prevBuf)
+		s.checkpoints[i] = nil
+	}
+	if n < len(s.checkpoints) {
+		s.checkpoints = s.checkpoints[:n]
+	}
+}
+
+// pruneCheckpoints discards the checkpoints of the running call that can
+// no longer be restored, before setjmp saves a new one at stack pointer sp
+// with the jmp_buf at bufPtr. This keeps the number of checkpoints bounded
+// by the stack depth rather than by the number of commands run.
+//
+// A checkpoint whose stack pointer is below sp was taken by a frame that
+// has returned, since a function keeps its stack pointer until it returns;
+// longjmp to it would be undefined. Checkpoints are discarded from the top
+// only, so indices stay valid. Their jmp_buf may now be another frame's
+// memory and is left alone.
+//
+// If the top checkpoint was taken at sp with the same jmp_buf, the frame
+// calls setjmp again on it, as dash does for every command it runs at the
+// same depth. The new checkpoint replaces it, and the returned index and
+// previous jmp_buf value are those of the replaced one.
+func (s *dashState) pruneCheckpoints(mem api.Memory, sp, bufPtr uint32) (idx int, prevBuf uint64, ok bool) {
+	n := len(s.checkpoints)
+	for n > s.checkpointBase && s.checkpoints[n-1].stackPointer < sp {
+		n--
+		s.checkpoints[n] = nil
+	}
+	s.checkpoints = s.checkpoints[:n]
+	if n > s.checkpointBase {
+		if top := s.checkpoints[n-1]; top.stackPointer == sp && top.bufPtr == bufPtr {
+			s.checkpoints[n-1] = nil
+			s.checkpoints = s.checkpoints[:n-1]
+			return n - 1, top.prevBuf, true
+		}
+	}
+	prevBuf, ok = mem.ReadUint64Le(bufPtr)
+	return n, prevBuf, ok
+}
+
+// call invokes an exported function of the dash module. The call is not
+// aborted if ctx is done: on runtimes closing modules when the context is
+// done that would lose the shell. See invoke.
+func (d *Dash) call(ctx context.Context, fn api.Function, params ...uint64) ([]uint64, error) {
+	return d.invoke(context.WithoutCancel(ctx), fn, params...)
+}
+
+// invoke is call letting ctx abort the guest. Callers must recover an
+// interrupted call with recoverInterrupted.
+//
+// An api.Function must not be re-entered while it is executing, so calls
+// nested inside host functions use a fresh instance of the export.
+func (d *Dash) invoke(ctx context.Context, fn api.Function, params ...uint64) ([]uint64, error) {
+	if d.state.depth != 0 {
+		fn = d.mod.ExportedFunction(fn.Definition().ExportNames()[0])
+	}
+	d.state.depth++
+	defer func() { d.state.depth-- }()
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		if t := d.state.terminated.Load(); t != nil {
+			return nil, t
+		}
+	}
+	return results, err
+}
+
 // allocString allocates a null-terminated string in WASM memory.
 func (d *Dash) allocString(ctx context.Context, s string) (uint32, error) {
+	return d.allocStringSize(ctx, s, len(s)+1)
+}
+
+// allocStringSize allocates size bytes in WASM memory holding the
+// null-terminated string s. size must be at least len(s)+1.
+func (d *Dash) allocStringSize(ctx context.Context, s string, size int) (uint32, error) {
 	b := []byte(s)
-	results, err := d.malloc.Call(ctx, uint64(len(b)+1))
+	if d.state.faults.failMalloc() {
+		return 0, errMallocFault
+	}
+	results, err := d.call(ctx, d.malloc, uint64(size))
 	if err != nil {
 		return 0, err
 	}
@@ -180,7 +662,7 @@ func (d *Dash) allocString(ctx context.Context, s string) (uint32, error) {
 		return 0, errors.New("malloc returned null")
 	}
 	if !d.mod.Memory().Write(ptr, append(b, 0)) {
-		_, _ = d.free.Call(ctx, uint64(ptr))
+		_, _ = d.call(ctx, d.free, uint64(ptr))
 		return 0, errors.New("failed to write string to memory")
 	}
 	return ptr, nil
@@ -189,49 +671,70 @@ func (d *Dash) allocString(ctx context.Context, s string) (uint32, error) {
 // freePtr frees a pointer in WASM memory.
 func (d *Dash) freePtr(ctx context.Context, ptr uint32) {
 	if ptr != 0 {
-		_, _ = d.free.Call(ctx, uint64(ptr))
+		_, _ = d.call(ctx, d.free, uint64(ptr))
 	}
 }
 
 // Init initializes the dash shell runtime.
-// Must be called before Eval. Pass nil args for default initialization.
+// Must be called before Eval; methods needing the shell return
+// ErrNotInitialized until it succeeds, and calling it again returns
+// ErrAlreadyInitialized. Pass nil args for the arguments given with
+// WithArgs, or default initialization.
 func (d *Dash) Init(ctx context.Context, args []string) error {
+	if t := d.state.terminated.Load(); t != nil {
+		return t
+	}
 	if d.initialized {
-		return errors.New("dash already initialized")
+		return ErrAlreadyInitialized
 	}
 
 	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
 
+	if len(args) == 0 {
+		args = d.args
+	}
 	if len(args) == 0 {
 		args = []string{"dash"}
 	}
 
+	// Dash keeps pointers into argv for the lifetime of the shell ($0, the
+	// positional parameters and error message prefixes), so the strings and
+	// the array are only freed if initialization fails. argv[0] is given
+	// spare capacity so EvalWithSource can rename it.
 	argc := len(args)
 	ptrs := make([]uint32, argc)
+	freeArgs := func() {
+		for _, ptr := range ptrs {
+			d.freePtr(ctx, ptr)
+		}
+	}
 	for i, arg := range args {
-		ptr, err := d.allocString(ctx, arg)
+		size := len(arg) + 1
+		if i == 0 {
+			size = max(size, maxSourceName+1)
+		}
+		ptr, err := d.allocStringSize(ctx, arg, size)
 		if err != nil {
-			for j := 0; j < i; j++ {
-				d.freePtr(ctx, ptrs[j])
-			}
+			freeArgs()
 			return err
 		}
 		ptrs[i] = ptr
 	}
 
 	// Allocate argv array (4 bytes per pointer in wasm32).
-	results, err := d.malloc.Call(ctx, uint64(argc*4))
+	if d.state.faults.failMalloc() {
+		freeArgs()
+		return errMallocFault
+	}
+	results, err := d.call(ctx, d.malloc, uint64(argc*4))
 	if err != nil {
-		for _, ptr := range ptrs {
-			d.freePtr(ctx, ptr)
-		}
+		freeArgs()
 		return err
 	}
 	argv := uint32(results[0])
 	if argv == 0 {
-		for _, ptr := range ptrs {
-			d.freePtr(ctx, ptr)
-		}
+		freeArgs()
 		return errors.New("malloc returned null for argv")
 	}
 
@@ -240,57 +743,295 @@ func (d *Dash) Init(ctx context.Context, args []string) error {
 		d.mod.Memory().WriteUint32Le(argv+uint32(i*4), ptr)
 	}
 
-	initResults, err := d.dashInit.Call(ctx, uint64(argc), uint64(argv))
-
-	d.freePtr(ctx, argv)
-	for _, ptr := range ptrs {
-		d.freePtr(ctx, ptr)
-	}
-
+	initResults, err := d.call(ctx, d.dashInit, uint64(argc), uint64(argv))
 	if err != nil {
-		return errors.New("dash_init failed: " + err.Error())
+		err = trapError(dashwasi.ExportDashInit, err)
+	} else if status := int(int32(initResults[0])); status != 0 {
+		err = fmt.Errorf("dash_init returned error: %w", &ExitError{Status: status})
 	}
-	if int32(initResults[0]) != 0 {
-		return errors.New("dash_init returned error")
+	if err != nil {
+		d.freePtr(ctx, argv)
+		freeArgs()
+		return err
 	}
 
+	d.arg0, d.arg0Ptr = args[0], ptrs[0]
 	d.initialized = true
+	if err := d.exportEnv(ctx); err != nil {
+		return err
+	}
+	if err := d.installPrintf(ctx); err != nil {
+		return err
+	}
+	if err := d.installRead(ctx); err != nil {
+		return err
+	}
+	if err := d.sourceStdlib(ctx); err != nil {
+		return err
+	}
+	if err := d.runInitScripts(ctx); err != nil {
+		return err
+	}
+	d.initSnap = d.captureShell()
 	return nil
 }
 
 // Eval evaluates a shell command string.
 // Returns the exit status of the last command.
-func (d *Dash) Eval(ctx context.Context, cmd string) (int, error) {
-	if !d.initialized {
-		return -1, errors.New("dash not initialized")
+//
+// If the command hit a configured SizeLimits cap, Eval returns the exit
+// status together with ErrSizeLimitExceeded. If it exceeded the WriteQuota,
+// Eval returns the exit status with a *QuotaExceededError. If the guest
+// trapped, Eval returns an *EvalTrapError. If cmd failed to parse, Eval
+// returns status 2 with a *SyntaxError locating the error; the commands
+// before it have run, and dash has written its message to stderr.
+//
+// If ctx is done before the command completes, Eval aborts it, rolls the
+// shell back to its state before the call and returns an *InterruptedError
+// matching ErrInterrupted, the context's error and its cause. Host
+// commands see the same context. Guest code is stopped at once on runtimes
+// created with wazero.RuntimeConfig.WithCloseOnContextDone, as NewDash does
+// when it creates the runtime, and otherwise at its next host call.
+// Evaluations with a cancellable context save the guest memory first,
+// copying the pages changed since the previous evaluation.
+func (d *Dash) Eval(ctx context.Context, cmd string) (status int, err error) {
+	d.state.recordCommand("eval", cmd)
+	if p := d.state.profiler; p != nil {
+		defer p.begin(SpanEval, "eval", d.state.evalDetail(cmd))()
+	}
+	if d.state.events != nil {
+		d.state.publish(Event{Kind: EventEvalStarted, Script: cmd})
+		start := time.Now()
+		defer func() {
+			d.state.publish(Event{Kind: EventEvalFinished, Script: cmd, Status: status, Err: err, Duration: time.Since(start)})
+		}()
+	}
+	// Forget syntax errors of earlier evaluations.
+	if d.dashGetLastError != nil && d.ready() == nil {
+		if _, err := d.takeSyntaxError(ctx, ""); err != nil {
+			return -1, err
+		}
+	}
+	msg, err := d.watchStderr(func() error {
+		var err error
+		status, err = d.eval(ctx, cmd)
+		return err
+	})
+	if d.dashListFuncs == nil {
+		d.state.trackFunctions(d.normalizeScript(cmd))
+	}
+	if err == nil && status == 2 {
+		e := parseSyntaxError([]byte(msg), d.normalizeScript(cmd))
+		if d.dashGetLastError != nil {
+			e, err = d.takeSyntaxError(ctx, d.normalizeScript(cmd))
+		}
+		if e != nil {
+			return status, e
+		}
+	}
+	return status, err
+}
+
+// eval implements Eval without recording the command. Used for scripts run
+// internally by the host.
+func (d *Dash) eval(ctx context.Context, cmd string) (status int, err error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	if d.state.depth == 0 {
+		if err := d.checkSizeLimits(); err != nil {
+			return -1, err
+		}
+	}
+	if s := d.state.sessions; s != nil && d.state.depth == 0 {
+		s.begin()
+		defer func() { s.end(d.mod, status != -1) }()
+	}
+	if s := d.state.sigint; s != nil && d.state.depth == 0 && ctx.Value(idleTrapKey{}) == nil {
+		s.begin()
+		defer s.end()
 	}
 
 	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
 
+	cmd = d.normalizeScript(cmd)
 	cmdPtr, err := d.allocString(ctx, cmd)
 	if err != nil {
 		return -1, err
 	}
 	defer d.freePtr(ctx, cmdPtr)
 
-	results, err := d.dashEval.Call(ctx, uint64(cmdPtr), uint64(len(cmd)))
+	// Top-level evaluations with a cancellable context can be interrupted,
+	// which rolls the shell back to this snapshot.
+	interruptible := ctx.Done() != nil && d.state.depth == 0
+	if interruptible && ctx.Err() != nil {
+		return -1, interruptedError(ctx)
+	}
+	var snap *memorySnapshot
+	if d.state.sizeLimits.MaxMemoryBytes != 0 || interruptible || (d.state.depth == 0 && (d.state.meter != nil || d.state.sigint != nil || d.state.yield != nil)) {
+		snap = d.takeSnapshot()
+		defer d.putSnapshot(snap)
+	}
+	ncheckpoints := len(d.state.checkpoints)
+
+	if hb := d.state.heartbeat; hb != nil && d.state.depth == 0 {
+		defer hb.begin()()
+	}
+	if y := d.state.yield; y != nil && d.state.depth == 0 {
+		defer y.begin()()
+	}
+	if d.state.stdoutBuf != nil && d.state.depth == 0 {
+		defer func() {
+			if ferr := d.state.flushStdout(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}()
+	}
+
+	d.state.sizeLimitHit = false
+	if d.state.depth == 0 {
+		d.state.quotaHit = nil
+		d.state.limitHit = nil
+	}
+	call := d.call
+	if interruptible {
+		call = d.invoke
+	}
+	evalPtr := uint64(cmdPtr)
+	if d.state.depth == 0 && d.state.faults.takeTrap() {
+		evalPtr = faultTrapPtr
+	}
+	results, err := call(ctx, d.dashEval, evalPtr, uint64(len(cmd)))
 	if err != nil {
-		return -1, errors.New("dash_eval failed: " + err.Error())
+		if s := d.state.sessions; s != nil && s.restore != nil {
+			if d.state.depth != 0 {
+				// Abort the enclosing call too.
+				panic(errRestoring)
+			}
+			return 0, nil
+		}
+		if s := d.state.sigint; s != nil && s.caught {
+			if d.state.depth != 0 {
+				panic(errSIGINT)
+			}
+			s.caught = false
+			d.rollback(snap, ncheckpoints)
+			if err := d.runSIGINTTrap(ctx, s.action); err != nil {
+				return -1, err
+			}
+			return SIGINTStatus, ErrSIGINT
+		}
+		if interruptible && ctx.Err() != nil && !errors.Is(err, ErrTerminated) {
+			return -1, d.recoverInterrupted(ctx, snap, ncheckpoints)
+		}
+		if d.state.limitHit != nil && snap != nil {
+			d.rollback(snap, ncheckpoints)
+			return -1, d.state.limitHit
+		}
+		if y := d.state.yield; y != nil && y.err != nil {
+			if d.state.depth != 0 {
+				// Abort the enclosing call too.
+				panic(y.err)
+			}
+			d.rollback(snap, ncheckpoints)
+			return -1, y.err
+		}
+		if d.state.sizeLimitHit && snap != nil {
+			d.rollback(snap, ncheckpoints)
+			return -1, ErrSizeLimitExceeded
+		}
+		err = trapError(dashwasi.ExportDashEval, err)
+		d.diagnose(ctx, dashwasi.ExportDashEval, err)
+		return -1, err
+	}
+
+	status = int(int32(results[0]))
+	if s := d.state.sigint; s != nil && d.state.depth == 0 && s.take() {
+		// The SIGINT came after the last command that could be stopped,
+		// for example during a read it interrupted.
+		action, ignored, err := d.intTrap(ctx)
+		if err != nil {
+			return -1, err
+		}
+		if !ignored {
+			d.rollback(snap, ncheckpoints)
+			if err := d.runSIGINTTrap(ctx, action); err != nil {
+				return SIGINTStatus, err
+			}
+			return SIGINTStatus, ErrSIGINT
+		}
+	}
+	if d.state.sizeLimitHit {
+		return status, ErrSizeLimitExceeded
 	}
+	if d.state.quotaHit != nil && d.state.depth == 0 {
+		return status, d.state.quotaHit
+	}
+	return status, nil
+}
+
+// rollback restores a snapshot taken with captureShell before an aborted
+// call and discards setjmp checkpoints created after it.
+func (d *Dash) rollback(snap *memorySnapshot, ncheckpoints int) {
+	snap.restore(d.mod)
+	d.state.fds = snap.fds.clone()
+	d.state.truncateCheckpoints(d.mod.Memory(), ncheckpoints)
+}
 
+// RunInteractive runs dash's native interactive command loop against the
+// module's configured stdio, returning the shell's exit status at EOF or exit.
+//
+// Prompts (PS1/PS2) and line handling are provided by dash itself. Returns an
+// error wrapping ErrNotAvailable if the module has no interactive support.
+// Like Eval, the loop is interrupted when ctx is done.
+func (d *Dash) RunInteractive(ctx context.Context) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	if d.dashRunInteractive == nil {
+		return -1, errNotAvailable(dashwasi.ExportDashRunInteractive)
+	}
+
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	interruptible := ctx.Done() != nil && d.state.depth == 0
+	if interruptible && ctx.Err() != nil {
+		return -1, interruptedError(ctx)
+	}
+	var snap *memorySnapshot
+	if interruptible {
+		snap = d.captureShell()
+	}
+	ncheckpoints := len(d.state.checkpoints)
+	call := d.call
+	if interruptible {
+		call = d.invoke
+	}
+	results, err := call(ctx, d.dashRunInteractive)
+	if err != nil {
+		if interruptible && ctx.Err() != nil && !errors.Is(err, ErrTerminated) {
+			return -1, d.recoverInterrupted(ctx, snap, ncheckpoints)
+		}
+		err = trapError(dashwasi.ExportDashRunInteractive, err)
+		d.diagnose(ctx, dashwasi.ExportDashRunInteractive, err)
+		return -1, err
+	}
 	return int(int32(results[0])), nil
 }
 
 // GetExitStatus returns the exit status of the last command.
 func (d *Dash) GetExitStatus(ctx context.Context) (int, error) {
-	if !d.initialized {
-		return -1, errors.New("dash not initialized")
+	if err := d.ready(); err != nil {
+		return -1, err
 	}
 	if d.dashGetExitStatus == nil {
-		return -1, errors.New("dash_get_exitstatus not available")
+		return -1, errNotAvailable(dashwasi.ExportDashGetExitStatus)
 	}
 
-	results, err := d.dashGetExitStatus.Call(d.callCtx(ctx))
+	defer d.scopeCheckpoints()()
+	results, err := d.call(d.callCtx(ctx), d.dashGetExitStatus)
 	if err != nil {
 		return -1, err
 	}
@@ -298,45 +1039,61 @@ func (d *Dash) GetExitStatus(ctx context.Context) (int, error) {
 }
 
 // GetVar returns the value of a shell variable, or empty string if unset.
+// Use LookupVar to tell an empty variable from an unset one.
 func (d *Dash) GetVar(ctx context.Context, name string) (string, error) {
-	if !d.initialized {
-		return "", errors.New("dash not initialized")
+	value, _, err := d.LookupVar(ctx, name)
+	return value, err
+}
+
+// LookupVar returns the value of a shell variable and whether it is set,
+// as `${name-default}` distinguishes `name=` from an unset name. Exported
+// variables that were never assigned are not set.
+func (d *Dash) LookupVar(ctx context.Context, name string) (value string, ok bool, err error) {
+	if err := d.ready(); err != nil {
+		return "", false, err
 	}
 	if d.dashGetVar == nil {
-		return "", errors.New("dash_getvar not available")
+		return "", false, errNotAvailable(dashwasi.ExportDashGetVar)
 	}
 
 	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
 
 	namePtr, err := d.allocString(ctx, name)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	defer d.freePtr(ctx, namePtr)
 
-	results, err := d.dashGetVar.Call(ctx, uint64(namePtr))
+	results, err := d.call(ctx, d.dashGetVar, uint64(namePtr))
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	valPtr := uint32(results[0])
 	if valPtr == 0 {
-		return "", nil
+		return "", false, nil
 	}
 
-	return d.readCString(valPtr), nil
+	return d.readCString(valPtr), true, nil
 }
 
 // SetVar sets a shell variable.
 func (d *Dash) SetVar(ctx context.Context, name, value string) error {
-	if !d.initialized {
-		return errors.New("dash not initialized")
+	if err := d.ready(); err != nil {
+		return err
 	}
 	if d.dashSetVar == nil {
-		return errors.New("dash_setvar not available")
+		return errNotAvailable(dashwasi.ExportDashSetVar)
+	}
+	var old string
+	var wasSet bool
+	if d.state.events != nil {
+		old, wasSet, _ = d.LookupVar(ctx, name)
 	}
 
 	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
 
 	namePtr, err := d.allocString(ctx, name)
 	if err != nil {
@@ -350,13 +1107,14 @@ func (d *Dash) SetVar(ctx context.Context, name, value string) error {
 	}
 	defer d.freePtr(ctx, valPtr)
 
-	results, err := d.dashSetVar.Call(ctx, uint64(namePtr), uint64(valPtr))
+	results, err := d.call(ctx, d.dashSetVar, uint64(namePtr), uint64(valPtr))
 	if err != nil {
 		return err
 	}
 	if int32(results[0]) != 0 {
 		return errors.New("dash_setvar failed")
 	}
+	d.state.publishVarSet(name, old, wasSet, value)
 	return nil
 }
 
@@ -381,13 +1139,27 @@ func (d *Dash) readCString(ptr uint32) string {
 	return string(buf)
 }
 
-// Close destroys the dash runtime and releases resources.
+// Close destroys the dash runtime and releases resources, including the
+// wazero runtime if NewDash created it.
 func (d *Dash) Close(ctx context.Context) error {
+	defer d.state.publish(Event{Kind: EventClosed})
+	if d.state.terminated.Load() != nil {
+		if d.ownsRuntime {
+			return d.runtime.Close(ctx)
+		}
+		return nil
+	}
 	if d.initialized {
-		_, _ = d.dashDestroy.Call(d.callCtx(ctx))
+		end := d.scopeCheckpoints()
+		_, _ = d.call(d.callCtx(ctx), d.dashDestroy)
+		end()
 		d.initialized = false
 	}
-	return d.mod.Close(ctx)
+	err := errors.Join(d.state.flushStdout(), d.mod.Close(ctx))
+	if d.ownsRuntime {
+		err = errors.Join(err, d.runtime.Close(ctx))
+	}
+	return err
 }
 
 // setjmpHost implements setjmp via wazero snapshot.
@@ -397,32 +1169,52 @@ func (d *Dash) Close(ctx context.Context) error {
 // Returns 0 on first call. When longjmp restores this checkpoint,
 // setjmp "returns" the longjmp value instead.
 func setjmpHost(ctx context.Context, mod api.Module, bufPtr uint32) int32 {
-	state := ctx.Value(dashStateKey{}).(*dashState)
+	const fn = "__setjmp"
+	state := hostState(ctx, fn)
+	// dash calls setjmp for each command, often enough to yield at.
+	yieldGuest()
+	if y := state.yield; y != nil {
+		y.point(ctx)
+	}
 
-	snap := experimental.GetSnapshotter(ctx).Snapshot()
+	snapshotter := getSnapshotter(ctx)
+	if snapshotter == nil {
+		hostTrap(fn, "snapshotter not enabled in context")
+	}
 
 	// Save C stack: memory from __stack_pointer to __heap_base.
-	sp := uint32(mod.ExportedGlobal("__stack_pointer").Get())
-	heapBase := uint32(mod.ExportedGlobal("__heap_base").Get())
 	var cstack []byte
-	if sp < heapBase {
-		view, ok := mod.Memory().Read(sp, heapBase-sp)
-		if ok {
-			cstack = make([]byte, len(view))
-			copy(cstack, view)
+	sp, top, ok := stackBounds(ctx, mod, fn)
+	if ok {
+		if sp > top {
+			hostTrap(fn, "stack pointer above __heap_base")
+		}
+		if state.stackWindow > 0 && top-sp > state.stackWindow {
+			top = sp + state.stackWindow
 		}
+		view, ok := mod.Memory().Read(sp, top-sp)
+		if !ok {
+			hostTrap(fn, "C stack out of memory bounds")
+		}
+		cstack = make([]byte, len(view))
+		copy(cstack, view)
 	}
 
-	idx := len(state.checkpoints)
+	idx, prevBuf, ok := state.pruneCheckpoints(mod.Memory(), sp, bufPtr)
+	if !ok {
+		hostTrap(fn, "jmp_buf out of memory bounds")
+	}
+	mod.Memory().WriteUint64Le(bufPtr, uint64(idx))
+
+	snap := snapshotter.Snapshot()
 	state.checkpoints = append(state.checkpoints, &checkpoint{
 		snapshot:     snap,
 		stackPointer: sp,
 		cstack:       cstack,
+		bufPtr:       bufPtr,
+		prevBuf:      prevBuf,
 	})
 
-	// Write checkpoint index to jmp_buf (8 bytes little-endian).
-	mod.Memory().WriteUint64Le(bufPtr, uint64(idx))
-
 	return 0
 }
 
@@ -431,9 +1223,16 @@ func setjmpHost(ctx context.Context, mod api.Module, bufPtr uint32) int32 {
 // Restores the C stack and execution state saved by a previous setjmp.
 // The corresponding setjmp "returns" val. Does not return.
 func longjmpHost(ctx context.Context, mod api.Module, bufPtr uint32, val int32) {
-	state := ctx.Value(dashStateKey{}).(*dashState)
+	const fn = "__longjmp"
+	state := hostState(ctx, fn)
 
-	idx, _ := mod.Memory().ReadUint64Le(bufPtr)
+	idx, ok := mod.Memory().ReadUint64Le(bufPtr)
+	if !ok {
+		hostTrap(fn, "jmp_buf out of memory bounds")
+	}
+	if idx >= uint64(len(state.checkpoints)) || state.checkpoints[idx] == nil {
+		hostTrap(fn, "invalid checkpoint index "+strconv.FormatUint(idx, 10))
+	}
 
 	// C standard: longjmp(buf, 0) behaves as longjmp(buf, 1).
 	if val == 0 {
@@ -443,24 +1242,63 @@ func longjmpHost(ctx context.Context, mod api.Module, bufPtr uint32, val int32)
 	cp := state.checkpoints[idx]
 
 	// Restore C stack: reset __stack_pointer and write back saved memory.
-	mod.ExportedGlobal("__stack_pointer").(api.MutableGlobal).Set(uint64(cp.stackPointer))
-	if len(cp.cstack) > 0 {
-		mod.Memory().Write(cp.stackPointer, cp.cstack)
+	// Without the global, functions reset the stack pointer as they return.
+	if g := mod.ExportedGlobal("__stack_pointer"); g != nil {
+		sp, ok := g.(api.MutableGlobal)
+		if !ok {
+			hostTrap(fn, "__stack_pointer is not mutable")
+		}
+		sp.Set(uint64(cp.stackPointer))
+	}
+	if !mod.Memory().Write(cp.stackPointer, cp.cstack) {
+		hostTrap(fn, "C stack out of memory bounds")
 	}
 
 	// Restore execution state. Makes the setjmp host function return val.
 	cp.snapshot.Restore([]uint64{uint64(uint32(val))})
 }
 
+// stackBounds returns the guest's C stack pointer and the top of its C
+// stack, from the __stack_pointer and __heap_base globals or, for builds
+// lacking them, the dash_stack_bounds export. Returns false if neither is
+// available: checkpoints then only save the WASM execution state.
+func stackBounds(ctx context.Context, mod api.Module, fn string) (sp, top uint32, ok bool) {
+	spGlobal := mod.ExportedGlobal("__stack_pointer")
+	topGlobal := mod.ExportedGlobal("__heap_base")
+	if spGlobal != nil && topGlobal != nil {
+		return uint32(spGlobal.Get()), uint32(topGlobal.Get()), true
+	}
+	bounds := mod.ExportedFunction(dashwasi.ExportDashStackBounds)
+	if bounds == nil {
+		return 0, 0, false
+	}
+	results, err := bounds.Call(ctx)
+	if err != nil {
+		hostTrap(fn, dashwasi.ExportDashStackBounds+": "+err.Error())
+	}
+	sp, top = uint32(results[0]), uint32(results[0]>>32)
+	if spGlobal != nil {
+		sp = uint32(spGlobal.Get())
+	}
+	if topGlobal != nil {
+		top = uint32(topGlobal.Get())
+	}
+	return sp, top, true
+}
+
 // execCommandHost is called when dash tries to execute an external command.
 //
 // C signature: int __wasi_host_exec(int argc, char **argv)
 // The host reads argc and the argv pointer array from WASM memory,
-// dispatches to the registered ExecHandler, and returns the exit status.
+// dispatches @host calls, builtin overrides and host commands, and otherwise
+// the registered ExecHandler, and returns the exit status.
 func execCommandHost(ctx context.Context, mod api.Module, argc uint32, argvPtr uint32) int32 {
-	state := ctx.Value(dashStateKey{}).(*dashState)
-	if state.execHandler == nil {
-		return 127
+	const fn = "__exec_command"
+	state := hostState(ctx, fn)
+
+	// Bound argc by the memory holding the pointer array before allocating.
+	if uint64(argvPtr)+uint64(argc)*4 > uint64(mod.Memory().Size()) {
+		hostTrap(fn, "argv out of memory bounds")
 	}
 
 	argv := make([]string, argc)
@@ -469,7 +1307,112 @@ func execCommandHost(ctx context.Context, mod api.Module, argc uint32, argvPtr u
 		argv[i] = readCStringMod(mod, ptr)
 	}
 
-	return int32(state.execHandler(ctx, argv))
+	if len(argv) == 0 {
+		return 127
+	}
+	if sig := state.sigint; sig != nil && state.dash != nil {
+		// Stop at the command, or after it if a SIGINT interrupted it.
+		state.dash.deliverSIGINT(ctx)
+		cmdCtx, stop := sig.commandContext(ctx)
+		status := dispatchCommand(cmdCtx, mod, state, argv)
+		stop()
+		state.dash.deliverSIGINT(ctx)
+		return status
+	}
+	return dispatchCommand(ctx, mod, state, argv)
+}
+
+// dispatchCommand runs the command argv for execCommandHost.
+func dispatchCommand(ctx context.Context, mod api.Module, state *dashState, argv []string) int32 {
+	if !checkArgBytes(ctx, mod, state, argv) {
+		return 126
+	}
+	if argv[0] == debugCommand {
+		return int32(runDebugHook(ctx, state, argv))
+	}
+	if argv[0] == chunkCommand {
+		return int32(runChunkHook(state, argv))
+	}
+	if state.events == nil {
+		return runCommand(ctx, mod, state, argv)
+	}
+	start := time.Now()
+	status := runCommand(ctx, mod, state, argv)
+	state.publish(Event{Kind: EventCommand, Argv: argv, Status: int(status), Duration: time.Since(start)})
+	return status
+}
+
+// runCommand runs the command argv for dispatchCommand.
+func runCommand(ctx context.Context, mod api.Module, state *dashState, argv []string) int32 {
+	if p := state.profiler; p != nil {
+		defer p.begin(SpanCommand, argv[0], strings.Join(argv, " "))()
+	}
+	if argv[0] == HostCallCommand {
+		return int32(runHostCall(ctx, mod, state, argv))
+	}
+	state.recordCommand("exec", strings.Join(argv, " "))
+	if hb := state.heartbeat; hb != nil {
+		hb.setCommand(argv)
+		defer hb.setCommand(nil)
+	}
+	if argv[0] == builtinCommand {
+		return int32(runBuiltin(ctx, mod, state, argv))
+	}
+	if argv[0] == stderrCommand && state.dash != nil {
+		return int32(runStderr(ctx, state.dash, argv))
+	}
+	if argv[0] == printfCommand && state.dash != nil {
+		return int32(runPrintf(ctx, state.dash, argv))
+	}
+	if argv[0] == readCommand && state.dash != nil {
+		return int32(runRead(ctx, state.dash, argv))
+	}
+	if !checkPolicy(ctx, mod, state, argv) {
+		return PolicyDeniedStatus
+	}
+	if cmd, ok := state.commands[argv[0]]; ok && state.dash != nil {
+		return int32(cmd(ctx, state.dash, argv))
+	}
+	if fn, ok := state.registered[argv[0]]; ok {
+		return int32(callBuiltin(ctx, mod, state, fn, argv))
+	}
+	status := NotFoundStatus
+	if state.execHandler != nil {
+		status = runWithTimeout(ctx, mod, state, argv[0], func(ctx context.Context) int {
+			return state.execHandler(ctx, argv)
+		})
+	}
+	if status == NotFoundStatus {
+		status = runCommandNotFound(ctx, mod, state, argv)
+	}
+	return int32(status)
+}
+
+// hostState returns the dash state attached to a host function call.
+func hostState(ctx context.Context, fn string) *dashState {
+	state, _ := ctx.Value(dashStateKey{}).(*dashState)
+	if state == nil {
+		hostTrap(fn, "missing dash state in context")
+	}
+	state.checkTerminated()
+	state.checkInterrupted(ctx)
+	return state
+}
+
+// getSnapshotter returns the snapshotter of the current call, or nil if
+// snapshots were not enabled for it.
+func getSnapshotter(ctx context.Context) (s experimental.Snapshotter) {
+	defer func() { _ = recover() }()
+	return experimental.GetSnapshotter(ctx)
+}
+
+// hostTrap aborts the current guest call.
+//
+// wazero recovers the panic and returns the error from the exported function
+// call, so a malformed module or corrupted guest state surfaces as an error
+// wrapping ErrHostTrap rather than an unexplained runtime panic.
+func hostTrap(fn, msg string) {
+	panic(fmt.Errorf("%w: %s: %s", ErrHostTrap, fn, msg))
 }
 
 // readCStringMod reads a null-terminated string from WASM memory.
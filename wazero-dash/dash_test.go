@@ -3,9 +3,11 @@ package dash
 import (
 	"bytes"
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/aperturerobotics/go-dash-wasi-reactor/shell"
 	"github.com/tetratelabs/wazero"
 )
 
@@ -19,7 +21,7 @@ func TestDashEval(t *testing.T) {
 		WithStdout(&stdout).
 		WithStderr(&stdout)
 
-	d, err := NewDash(ctx, r, config)
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config))
 	if err != nil {
 		t.Fatal("NewDash:", err)
 	}
@@ -85,3 +87,175 @@ func TestDashEval(t *testing.T) {
 		t.Fatalf("expected GetExitStatus 1, got %d", es)
 	}
 }
+
+// newTestDash creates an initialized Dash writing stdout and stderr to the
+// returned buffers. Resources are released when the test finishes.
+func newTestDash(t *testing.T) (*Dash, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	return d, &stdout, &stderr
+}
+
+// TestCheckpointsBounded checks that setjmp checkpoints are discarded once
+// unreachable, rather than accumulating for every command of a long loop.
+func TestCheckpointsBounded(t *testing.T) {
+	d, stdout, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	most := 0
+	d.state.registerCommand("count", func(ctx context.Context, d *Dash, argv []string) int {
+		most = max(most, len(d.state.checkpoints))
+		return 0
+	})
+	script := `f() { count; return 3; }
+i=0 n=0
+while [ $i -lt 500 ]; do
+	i=$((i+1))
+	f
+	command eval 'if'
+	case $i in *0) continue;; esac
+	eval 'count; n=$((n+1))'
+done
+echo "$i $n"`
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stdout.String(); got != "500 450\n" {
+		t.Fatalf("unexpected output %q (stderr %q)", got, stderr.String())
+	}
+	if most == 0 || most > 16 {
+		t.Fatalf("expected a few live checkpoints, got up to %d", most)
+	}
+	if n := d.MemoryStats().Checkpoints; n != 0 {
+		t.Fatalf("expected no checkpoints after Eval, got %d", n)
+	}
+}
+
+func TestHostFunctionTraps(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	withState := withDashState(context.Background(), &dashState{})
+
+	tests := []struct {
+		name string
+		call func()
+	}{
+		{"missing state", func() { setjmpHost(context.Background(), d.mod, 0) }},
+		{"bad checkpoint", func() { longjmpHost(withState, d.mod, 0, 1) }},
+		{"jmp_buf out of bounds", func() { longjmpHost(withState, d.mod, ^uint32(0), 1) }},
+		{"argv out of bounds", func() { execCommandHost(withState, d.mod, 1<<30, 0) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(error)
+				if !ok || !errors.Is(err, ErrHostTrap) {
+					t.Fatalf("expected ErrHostTrap, got %v", err)
+				}
+			}()
+			tc.call()
+		})
+	}
+}
+
+// stackBoundsModule returns a module exporting dash_stack_bounds, which
+// returns bounds, and the __heap_base global if heapBase is not negative.
+func stackBoundsModule(bounds uint64, heapBase int32) []byte {
+	sleb := func(v int64) []byte {
+		var b []byte
+		for {
+			c := byte(v & 0x7f)
+			v >>= 7
+			if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+				return append(b, c)
+			}
+			b = append(b, c|0x80)
+		}
+	}
+	section := func(id byte, body ...byte) []byte {
+		return append([]byte{id, byte(len(body))}, body...)
+	}
+	name := "dash_stack_bounds"
+	exports := append([]byte{1, byte(len(name))}, name...)
+	exports = append(exports, 0x00, 0)
+	var globals []byte
+	if heapBase >= 0 {
+		globals = section(6, append(append([]byte{1, 0x7f, 0x00, 0x41}, sleb(int64(heapBase))...), 0x0b)...)
+		exports[0] = 2
+		exports = append(append(exports, byte(len("__heap_base"))), "__heap_base"...)
+		exports = append(exports, 0x03, 0)
+	}
+	body := append(append([]byte{0x00, 0x42}, sleb(int64(bounds))...), 0x0b)
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	wasm = append(wasm, section(1, 1, 0x60, 0, 1, 0x7e)...)
+	wasm = append(wasm, section(3, 1, 0)...)
+	wasm = append(wasm, globals...)
+	wasm = append(wasm, section(7, exports...)...)
+	wasm = append(wasm, section(10, append([]byte{1, byte(len(body))}, body...)...)...)
+	return wasm
+}
+
+func TestStackBounds(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	for _, tc := range []struct {
+		name    string
+		wasm    []byte
+		sp, top uint32
+		ok      bool
+	}{
+		{"export", stackBoundsModule(0x10000<<32|0x8000, -1), 0x8000, 0x10000, true},
+		{"export and __heap_base", stackBoundsModule(0x10000<<32|0x8000, 0x20000), 0x8000, 0x20000, true},
+		{"none", exportsModule(nil), 0, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mod, err := r.InstantiateWithConfig(ctx, tc.wasm, wazero.NewModuleConfig().WithName(tc.name))
+			if err != nil {
+				t.Fatal("Instantiate:", err)
+			}
+			sp, top, ok := stackBounds(ctx, mod, "test")
+			if sp != tc.sp || top != tc.top || ok != tc.ok {
+				t.Fatalf("stackBounds = %#x, %#x, %v, want %#x, %#x, %v", sp, top, ok, tc.sp, tc.top, tc.ok)
+			}
+		})
+	}
+
+	// The embedded build exports the globals.
+	d, _, _ := newTestDash(t)
+	if _, _, ok := stackBounds(ctx, d.mod, "test"); !ok {
+		t.Fatal("expected the globals of the embedded module")
+	}
+}
+
+func TestRunInteractiveUnavailable(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	if d.dashRunInteractive != nil {
+		t.Skip("module exports dash_run_interactive")
+	}
+	if _, err := d.RunInteractive(context.Background()); !errors.Is(err, ErrNotAvailable) {
+		t.Fatalf("expected ErrNotAvailable, got %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	var sh shell.Interpreter = d
+	caps := sh.Capabilities()
+	if caps.Name != "dash" || caps.Version == "" {
+		t.Fatalf("unexpected identity %q %q", caps.Name, caps.Version)
+	}
+	if !caps.Vars || !caps.ExitStatus || !caps.HostCalls {
+		t.Fatalf("expected core capabilities, got %+v", caps)
+	}
+}
@@ -0,0 +1,226 @@
+// Package dashast defines the parse tree of dash scripts returned by
+// Dash.Parse in the wazero-dash library, for linters, rewriters and other
+// tools analyzing scripts as dash parses them:
+//
+//	f, err := d.Parse(ctx, script)
+//	dashast.Walk(f, func(n dashast.Node) bool {
+//		if c, ok := n.(*dashast.Command); ok && len(c.Args) != 0 {
+//			fmt.Println(c.Line, c.Args[0].Text)
+//		}
+//		return true
+//	})
+//
+// Words are kept as source text, quotes and expansions included.
+package dashast
+
+import "reflect"
+
+// Node is a node of a parse tree.
+type Node interface {
+	node()
+}
+
+// File is a parsed script.
+type File struct {
+	List *List
+}
+
+// List is a sequence of and-or lists, as separated by ;, & or newlines.
+type List struct {
+	Items []*AndOr
+}
+
+// AndOr is a list of pipelines joined by && and ||.
+type AndOr struct {
+	Pipelines []*Pipeline
+	// Ops are the operators between the pipelines, "&&" or "||", one
+	// fewer than Pipelines.
+	Ops []string
+	// Background is set for a list run with &.
+	Background bool
+}
+
+// Pipeline is one or more commands joined by |. Its commands are
+// *Command, *If, *While, *For, *Case, *Group, *Subshell or *FuncDef nodes.
+type Pipeline struct {
+	// Negated is set for a pipeline preceded by !.
+	Negated  bool
+	Commands []Node
+}
+
+// Command is a simple command. The Line of a command is the line it starts
+// on, counted from 1, or 0 if unknown.
+type Command struct {
+	Line int
+	// Assigns are the NAME=value words before the command name.
+	Assigns   []*Word
+	Args      []*Word
+	Redirects []*Redirect
+}
+
+// If is an if command. An elif is an If alone in the Else of the one
+// before it.
+type If struct {
+	Line       int
+	Cond, Then *List
+	// Else is nil without an else or elif part.
+	Else      *List
+	Redirects []*Redirect
+}
+
+// While is a while or until loop.
+type While struct {
+	Line int
+	// Until is set for an until loop.
+	Until      bool
+	Cond, Body *List
+	Redirects  []*Redirect
+}
+
+// For is a for loop.
+type For struct {
+	Line int
+	Var  string
+	// Words are the words after in; nil without in, which loops over the
+	// positional parameters.
+	Words     []*Word
+	Body      *List
+	Redirects []*Redirect
+}
+
+// Case is a case command.
+type Case struct {
+	Line      int
+	Word      *Word
+	Items     []*CaseItem
+	Redirects []*Redirect
+}
+
+// CaseItem is a pattern list of a Case and the commands it runs.
+type CaseItem struct {
+	Patterns []*Word
+	// Body is empty for an item without commands.
+	Body *List
+}
+
+// Group is a brace group, { list; }.
+type Group struct {
+	Line      int
+	Body      *List
+	Redirects []*Redirect
+}
+
+// Subshell is a list run in a subshell, ( list ).
+type Subshell struct {
+	Line      int
+	Body      *List
+	Redirects []*Redirect
+}
+
+// FuncDef is a function definition. Body is a compound command.
+type FuncDef struct {
+	Line int
+	Name string
+	Body Node
+}
+
+// Redirect is a redirection of a command.
+type Redirect struct {
+	// Op is the operator: <, >, >>, >|, <>, <&, >&, << or <<-.
+	Op string
+	// Fd is the redirected file descriptor, written before Op or implied
+	// by it: 0 for the input operators and <>, else 1.
+	Fd int
+	// Target is the file, the descriptor duplicated, or for a
+	// here-document the delimiter.
+	Target *Word
+	// HereDoc is the body of a here-document, each line ending with a
+	// newline, without the delimiter line.
+	HereDoc string
+}
+
+// Word is a word as written in the script.
+type Word struct {
+	Text string
+}
+
+func (*File) node()     {}
+func (*List) node()     {}
+func (*AndOr) node()    {}
+func (*Pipeline) node() {}
+func (*Command) node()  {}
+func (*If) node()       {}
+func (*While) node()    {}
+func (*For) node()      {}
+func (*Case) node()     {}
+func (*CaseItem) node() {}
+func (*Group) node()    {}
+func (*Subshell) node() {}
+func (*FuncDef) node()  {}
+func (*Redirect) node() {}
+func (*Word) node()     {}
+
+// Walk calls fn for n and then, unless fn returns false, for each of its
+// children in source order, recursively. Nil children are skipped.
+func Walk(n Node, fn func(Node) bool) {
+	if isNil(n) || !fn(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *File:
+		Walk(n.List, fn)
+	case *List:
+		walkAll(n.Items, fn)
+	case *AndOr:
+		walkAll(n.Pipelines, fn)
+	case *Pipeline:
+		walkAll(n.Commands, fn)
+	case *Command:
+		walkAll(n.Assigns, fn)
+		walkAll(n.Args, fn)
+		walkAll(n.Redirects, fn)
+	case *If:
+		Walk(n.Cond, fn)
+		Walk(n.Then, fn)
+		Walk(n.Else, fn)
+		walkAll(n.Redirects, fn)
+	case *While:
+		Walk(n.Cond, fn)
+		Walk(n.Body, fn)
+		walkAll(n.Redirects, fn)
+	case *For:
+		walkAll(n.Words, fn)
+		Walk(n.Body, fn)
+		walkAll(n.Redirects, fn)
+	case *Case:
+		Walk(n.Word, fn)
+		walkAll(n.Items, fn)
+		walkAll(n.Redirects, fn)
+	case *CaseItem:
+		walkAll(n.Patterns, fn)
+		Walk(n.Body, fn)
+	case *Group:
+		Walk(n.Body, fn)
+		walkAll(n.Redirects, fn)
+	case *Subshell:
+		Walk(n.Body, fn)
+		walkAll(n.Redirects, fn)
+	case *FuncDef:
+		Walk(n.Body, fn)
+	case *Redirect:
+		Walk(n.Target, fn)
+	}
+}
+
+// walkAll walks each of nodes.
+func walkAll[N Node](nodes []N, fn func(Node) bool) {
+	for _, n := range nodes {
+		Walk(n, fn)
+	}
+}
+
+// isNil reports whether n is nil or a nil pointer.
+func isNil(n Node) bool {
+	v := reflect.ValueOf(n)
+	return !v.IsValid() || v.IsNil()
+}
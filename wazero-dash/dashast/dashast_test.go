@@ -0,0 +1,64 @@
+package dashast
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	// if a; then b >out; fi; f() { c; }
+	cmd := func(args ...string) *Command {
+		c := &Command{}
+		for _, a := range args {
+			c.Args = append(c.Args, &Word{Text: a})
+		}
+		return c
+	}
+	list := func(nodes ...Node) *List {
+		l := &List{}
+		for _, n := range nodes {
+			l.Items = append(l.Items, &AndOr{Pipelines: []*Pipeline{{Commands: []Node{n}}}})
+		}
+		return l
+	}
+	b := cmd("b")
+	b.Redirects = []*Redirect{{Op: ">", Fd: 1, Target: &Word{Text: "out"}}}
+	f := &File{List: list(
+		&If{Cond: list(cmd("a")), Then: list(b)},
+		&FuncDef{Name: "f", Body: &Group{Body: list(cmd("c"))}},
+	)}
+
+	var visited []string
+	Walk(f, func(n Node) bool {
+		switch n := n.(type) {
+		case *Word:
+			visited = append(visited, n.Text)
+		case *Redirect:
+			visited = append(visited, n.Op)
+		case *If, *FuncDef, *Group:
+			visited = append(visited, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+	want := []string{"*dashast.If", "a", "b", ">", "out", "*dashast.FuncDef", "*dashast.Group", "c"}
+	if !slices.Equal(visited, want) {
+		t.Fatalf("Walk visited %q, want %q", visited, want)
+	}
+
+	// Returning false skips the children.
+	visited = nil
+	Walk(f, func(n Node) bool {
+		if w, ok := n.(*Word); ok {
+			visited = append(visited, w.Text)
+		}
+		_, fn := n.(*FuncDef)
+		return !fn
+	})
+	if !slices.Equal(visited, []string{"a", "b", "out"}) {
+		t.Fatalf("Walk visited %q below a skipped node", visited)
+	}
+
+	Walk(nil, func(Node) bool { panic("called for nil") })
+	Walk((*File)(nil), func(Node) bool { panic("called for a nil File") })
+}
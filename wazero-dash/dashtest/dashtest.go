@@ -0,0 +1,205 @@
+// Package dashtest provides helpers for unit testing shell scripts with the
+// dash WASI reactor.
+//
+// Each Shell runs in its own wazero runtime with in-memory stdio, so tests
+// are hermetic and can run in parallel:
+//
+//	func TestConfigs(t *testing.T) {
+//		sh := dashtest.New(t, dashtest.WithFiles(map[string]string{
+//			"etc/app/a.conf": "",
+//			"etc/app/b.conf": "",
+//		}))
+//		sh.Run(t, `for f in /etc/app/*.conf; do echo "${f##*/}"; done`).
+//			RequireStatus(0).
+//			RequireStdout("a.conf\nb.conf\n")
+//	}
+package dashtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+// UpdateEnv is the environment variable that, when set to 1, makes
+// RequireGolden rewrite golden files with the current output.
+const UpdateEnv = "DASHTEST_UPDATE"
+
+// Option configures a Shell created by New.
+type Option func(*options)
+
+// options holds the configuration for New.
+type options struct {
+	args   []string
+	env    [][2]string
+	mounts []mount
+}
+
+// mount is a file system exposed to the guest.
+type mount struct {
+	fsys  fs.FS
+	guest string
+}
+
+// WithArgs sets the arguments passed to dash_init ($0 and the positional
+// parameters).
+func WithArgs(args ...string) Option {
+	return func(o *options) { o.args = args }
+}
+
+// WithEnv adds an environment variable visible to the shell.
+func WithEnv(key, value string) Option {
+	return func(o *options) { o.env = append(o.env, [2]string{key, value}) }
+}
+
+// WithFS mounts fsys read-only at the guest path.
+func WithFS(fsys fs.FS, guest string) Option {
+	return func(o *options) { o.mounts = append(o.mounts, mount{fsys: fsys, guest: guest}) }
+}
+
+// WithFiles mounts an in-memory file tree read-only at the guest root.
+// Keys are slash-separated paths relative to /, values are file contents.
+func WithFiles(files map[string]string) Option {
+	fsys := make(fstest.MapFS, len(files))
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data), Mode: 0o755}
+	}
+	return WithFS(fsys, "/")
+}
+
+// Shell is an initialized dash instance with captured output.
+type Shell struct {
+	t      testing.TB
+	d      *dash.Dash
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	status int
+}
+
+// New creates an initialized Shell. Resources are released when the test
+// finishes.
+func New(t testing.TB, opts ...Option) *Shell {
+	t.Helper()
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx := context.Background()
+	s := &Shell{t: t}
+	dashOpts := []dash.Option{dash.WithStdio(nil, &s.stdout, &s.stderr), dash.WithArgs(o.args...)}
+	for _, kv := range o.env {
+		dashOpts = append(dashOpts, dash.WithEnv(kv[0], kv[1]))
+	}
+	for _, m := range o.mounts {
+		dashOpts = append(dashOpts, dash.WithFS(m.fsys, m.guest))
+	}
+
+	d, err := dash.NewDash(ctx, dashOpts...)
+	if err != nil {
+		t.Fatal("dashtest: NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("dashtest: Init:", err)
+	}
+	s.d = d
+	return s
+}
+
+// Dash returns the underlying Dash instance.
+func (s *Shell) Dash() *dash.Dash {
+	return s.d
+}
+
+// Run evaluates script, replacing the captured output and status of any
+// previous Run. Failures of later assertions are reported on t. A syntax
+// error is not a failure: it is reported by status 2 and stderr, as in sh.
+func (s *Shell) Run(t testing.TB, script string) *Shell {
+	t.Helper()
+	s.t = t
+	s.stdout.Reset()
+	s.stderr.Reset()
+	status, err := s.d.Eval(context.Background(), script)
+	var se *dash.SyntaxError
+	if err != nil && !errors.As(err, &se) {
+		t.Fatalf("dashtest: Eval: %v", err)
+	}
+	s.status = status
+	return s
+}
+
+// Stdout returns the stdout of the last Run.
+func (s *Shell) Stdout() string {
+	return s.stdout.String()
+}
+
+// Stderr returns the stderr of the last Run.
+func (s *Shell) Stderr() string {
+	return s.stderr.String()
+}
+
+// Status returns the exit status of the last Run.
+func (s *Shell) Status() int {
+	return s.status
+}
+
+// RequireStatus fails the test if the last Run did not exit with status.
+func (s *Shell) RequireStatus(status int) *Shell {
+	s.t.Helper()
+	if s.status != status {
+		s.t.Fatalf("dashtest: expected exit status %d, got %d\nstderr:\n%s", status, s.status, s.Stderr())
+	}
+	return s
+}
+
+// RequireStdout fails the test if the stdout of the last Run is not want.
+func (s *Shell) RequireStdout(want string) *Shell {
+	s.t.Helper()
+	if got := s.Stdout(); got != want {
+		s.t.Fatalf("dashtest: stdout mismatch\n--- want\n%s\n--- got\n%s", want, got)
+	}
+	return s
+}
+
+// RequireStderr fails the test if the stderr of the last Run is not want.
+func (s *Shell) RequireStderr(want string) *Shell {
+	s.t.Helper()
+	if got := s.Stderr(); got != want {
+		s.t.Fatalf("dashtest: stderr mismatch\n--- want\n%s\n--- got\n%s", want, got)
+	}
+	return s
+}
+
+// RequireGolden fails the test if the stdout of the last Run differs from
+// the contents of the golden file at path. Run the tests with
+// DASHTEST_UPDATE=1 to write the current output instead.
+func (s *Shell) RequireGolden(path string) *Shell {
+	s.t.Helper()
+	got := s.stdout.Bytes()
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			s.t.Fatal("dashtest:", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			s.t.Fatal("dashtest:", err)
+		}
+		return s
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("dashtest: %v (set %s=1 to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		s.t.Fatalf("dashtest: output differs from %s\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package dashtest
+
+import "testing"
+
+func TestShell(t *testing.T) {
+	sh := New(t,
+		WithEnv("GREETING", "hello"),
+		WithFiles(map[string]string{
+			"fixtures/a.txt": "a",
+			"fixtures/b.txt": "b",
+		}),
+	)
+
+	sh.Run(t, `echo "$GREETING world"`).
+		RequireStatus(0).
+		RequireStdout("hello world\n")
+
+	sh.Run(t, `for f in /fixtures/*.txt; do [ -f "$f" ] && echo "$f"; done`).
+		RequireStatus(0).
+		RequireGolden("testdata/fixtures.golden")
+
+	sh.Run(t, "false").RequireStatus(1)
+
+	sh.Run(t, "if then")
+	if sh.Status() != 2 || sh.Stderr() == "" {
+		t.Fatalf("expected syntax error, got status %d stderr %q", sh.Status(), sh.Stderr())
+	}
+}
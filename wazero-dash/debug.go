@@ -0,0 +1,341 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// debugCommand is the command the debugger's hooks dispatch through.
+const debugCommand = "@debug"
+
+// debugFuncPrefix prefixes the renamed body of an instrumented function.
+const debugFuncPrefix = "__dash_wasi_debug_"
+
+// DebugAction tells a paused Debugger how to resume.
+type DebugAction int
+
+// Debugger resume actions.
+const (
+	// DebugContinue runs until the next breakpoint.
+	DebugContinue DebugAction = iota
+	// DebugNext stops at the next command of the current function or a
+	// caller, stepping over function calls.
+	DebugNext
+	// DebugStep stops at the next command, stepping into function calls.
+	DebugStep
+)
+
+// Frame is an entry of the function stack of a debugged script.
+type Frame struct {
+	// Function is the function name, empty for the script itself.
+	Function string
+	// Args are the arguments the function was called with.
+	Args []string
+	// Line is the line of the command running in the frame.
+	Line int
+}
+
+// DebugStopFunc is called when a Debugger pauses before the command
+// starting at line. It runs inside the evaluation, so it can inspect the
+// shell with GetVar and the other accessors. Returns how to resume.
+type DebugStopFunc func(ctx context.Context, g *Debugger, line int) DebugAction
+
+// Debugger runs a script one command at a time, pausing at breakpoints.
+//
+// The debugger pauses before commands at the top level of the script and
+// directly in the bodies of functions written as
+//
+//	name() {
+//		...
+//	}
+//
+// Compound commands such as if and while, and functions defined in other
+// forms, run as a single command. Lines are numbered from Source.Line.
+// While a Profiler is set, each command and function call gets a span.
+type Debugger struct {
+	d      *Dash
+	src    Source
+	lines  []string
+	script string
+	onStop DebugStopFunc
+
+	// commands are the sorted lines where commands start.
+	commands    []int
+	breakpoints map[int]bool
+
+	stack  []Frame
+	action DebugAction
+	// depth is the stack depth when the debugger last paused.
+	depth  int
+	paused bool
+}
+
+// NewDebugger prepares script for debugging. onStop is called whenever the
+// debugger pauses.
+func (d *Dash) NewDebugger(ctx context.Context, script string, src Source, onStop DebugStopFunc) (*Debugger, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	src.Line = max(src.Line, 1)
+	g := &Debugger{
+		d:           d,
+		src:         src,
+		lines:       strings.Split(d.normalizeScript(script), "\n"),
+		onStop:      onStop,
+		breakpoints: make(map[int]bool),
+	}
+	lines := slices.Clone(g.lines)
+	if err := g.instrument(ctx, lines, 0, len(lines)); err != nil {
+		return nil, err
+	}
+	slices.Sort(g.commands)
+	g.script = strings.Join(lines, "\n")
+	return g, nil
+}
+
+// Run evaluates the script, starting as if resumed with first: DebugStep
+// pauses before the first command. Returns the exit status of the script.
+func (g *Debugger) Run(ctx context.Context, first DebugAction) (int, error) {
+	if g.d.state.debugger != nil {
+		return -1, errors.New("dash: debugger already running")
+	}
+	g.d.state.debugger = g
+	defer func() { g.d.state.debugger = nil }()
+	g.stack = []Frame{{}}
+	g.action, g.depth = first, 1
+	return g.d.EvalWithSource(ctx, g.script, g.src)
+}
+
+// Commands returns the lines where the debugger can pause, in order.
+func (g *Debugger) Commands() []int {
+	return slices.Clone(g.commands)
+}
+
+// Line returns the text of the script line n, or "" if out of range.
+func (g *Debugger) Line(n int) string {
+	if i := n - g.src.Line; i >= 0 && i < len(g.lines) {
+		return g.lines[i]
+	}
+	return ""
+}
+
+// SetBreakpoint pauses the debugger before the command starting at line.
+func (g *Debugger) SetBreakpoint(line int) error {
+	if _, ok := slices.BinarySearch(g.commands, line); !ok {
+		return fmt.Errorf("dash: no command starts at line %d", line)
+	}
+	g.breakpoints[line] = true
+	return nil
+}
+
+// ClearBreakpoint removes the breakpoint at line, if any.
+func (g *Debugger) ClearBreakpoint(line int) {
+	delete(g.breakpoints, line)
+}
+
+// Breakpoints returns the lines with breakpoints, in order.
+func (g *Debugger) Breakpoints() []int {
+	lines := make([]int, 0, len(g.breakpoints))
+	for line := range g.breakpoints {
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// Stack returns the function stack, innermost frame first. The last frame
+// is the script itself.
+func (g *Debugger) Stack() []Frame {
+	stack := make([]Frame, len(g.stack))
+	for i, f := range g.stack {
+		f.Args = slices.Clone(f.Args)
+		stack[len(stack)-1-i] = f
+	}
+	return stack
+}
+
+// funcDefPattern matches the first line of a `name() {` function
+// definition whose body the debugger can step through.
+var funcDefPattern = regexp.MustCompile(`^(\s*)([A-Za-z_][A-Za-z0-9_]*)\s*\(\s*\)\s*\{\s*$`)
+
+// instrument prefixes each command in lines[start:end] with a debugger
+// hook, recording the command lines. Line numbers are kept so dash reports
+// errors against the original script.
+func (g *Debugger) instrument(ctx context.Context, lines []string, start, end int) error {
+	for i := start; i < end; {
+		if text := strings.TrimSpace(lines[i]); text == "" || strings.HasPrefix(text, "#") {
+			i++
+			continue
+		}
+		last, err := g.commandEnd(ctx, i, end)
+		if err != nil {
+			return err
+		}
+		line := g.src.Line + i
+		g.commands = append(g.commands, line)
+		hook := debugCommand + " line " + strconv.Itoa(line) + " $?; "
+		if m := funcDefPattern.FindStringSubmatch(lines[i]); m != nil && last > i && strings.TrimSpace(lines[last]) == "}" {
+			// Rename the function and call the body from a wrapper
+			// maintaining the stack; return in the body still works.
+			name, body := m[2], debugFuncPrefix+m[2]
+			if err := g.instrument(ctx, lines, i+1, last); err != nil {
+				return err
+			}
+			lines[i] = m[1] + hook + body + "() {"
+			lines[last] += "; " + name + "() { " + debugCommand + " enter " + name + ` $? "$@"; ` + body + ` "$@"; ` + debugCommand + " leave $?; }"
+		} else {
+			lines[i] = hook + lines[i]
+		}
+		i = last + 1
+	}
+	return nil
+}
+
+// syntaxErrorPattern matches a dash syntax error message.
+var syntaxErrorPattern = regexp.MustCompile(`: (\d+): Syntax error: (.*)`)
+
+// commandEnd returns the index of the last line of the complete command
+// starting at lines[start], looking no further than end. A command that
+// never completes extends to end.
+func (g *Debugger) commandEnd(ctx context.Context, start, end int) (int, error) {
+	for last := start; last < end-1; last++ {
+		complete, err := g.d.parses(ctx, strings.Join(g.lines[start:last+1], "\n"))
+		if err != nil {
+			return 0, err
+		}
+		if complete {
+			return last, nil
+		}
+	}
+	return end - 1, nil
+}
+
+// parses reports whether script is syntactically complete, that is, does
+// not fail to parse for want of more lines. Nothing is executed. Reactor
+// builds without the dash_check_complete export parse script alone, then,
+// if it may hold an open here-document or line continuation, followed by
+// a line dash cannot parse: that line is only reported on its own if the
+// script did not take it in.
+func (d *Dash) parses(ctx context.Context, script string) (bool, error) {
+	if d.dashCheckComplete != nil {
+		return d.checkComplete(ctx, script)
+	}
+	e, err := d.parseOnly(ctx, script)
+	if err != nil {
+		return false, err
+	}
+	if e != nil {
+		// Errors at the end of input mean it needs more lines.
+		incomplete := strings.HasPrefix(e.Message, "end of file unexpected") ||
+			strings.HasPrefix(e.Message, "Unterminated") ||
+			strings.HasPrefix(e.Message, "Missing") ||
+			strings.HasPrefix(e.Message, "EOF in")
+		return !incomplete, nil
+	}
+	if !strings.Contains(script, "<<") && !strings.HasSuffix(script, "\\") {
+		return true, nil
+	}
+	// A line holding only a continuation leaves the next line as it is.
+	lines := strings.Split(script, "\n")
+	if last := strings.TrimLeft(lines[len(lines)-1], " \t"); strings.Trim(last, "\\") == "" && len(last)%2 == 1 {
+		return false, nil
+	}
+	e, err = d.parseOnly(ctx, script+"\n()")
+	if err != nil {
+		return false, err
+	}
+	return e != nil && e.Line == len(lines)+1 && e.Message == `")" unexpected`, nil
+}
+
+// checkComplete calls dash_check_complete on script.
+func (d *Dash) checkComplete(ctx context.Context, script string) (bool, error) {
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	srcPtr, err := d.allocString(ctx, script)
+	if err != nil {
+		return false, err
+	}
+	defer d.freePtr(ctx, srcPtr)
+	var complete bool
+	// Syntax errors other than the end of input are reported on stderr.
+	if _, err := d.captureStderr(func() error {
+		results, err := d.call(ctx, d.dashCheckComplete, uint64(srcPtr), uint64(len(script)))
+		if err != nil {
+			return err
+		}
+		complete = results[0] != 0
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return complete, nil
+}
+
+// runDebugHook handles a hook inserted by the debugger:
+//
+//	@debug line LINE STATUS
+//	@debug enter NAME STATUS ARGS...
+//	@debug leave STATUS
+//
+// Returns STATUS, so hooks leave $? intact.
+func runDebugHook(ctx context.Context, state *dashState, argv []string) int {
+	g := state.debugger
+	switch {
+	case len(argv) == 4 && argv[1] == "line":
+		if g != nil {
+			line, _ := strconv.Atoi(argv[2])
+			g.pause(ctx, line)
+			if p := state.profiler; p != nil {
+				p.line(g.src.Name+":"+argv[2], strings.TrimSpace(g.Line(line)))
+			}
+		}
+		status, _ := strconv.Atoi(argv[3])
+		return status
+	case len(argv) >= 4 && argv[1] == "enter":
+		if g != nil {
+			g.stack = append(g.stack, Frame{Function: argv[2], Args: slices.Clone(argv[4:])})
+			if p := state.profiler; p != nil {
+				p.begin(SpanFunction, argv[2], strings.Join(argv[4:], " "))
+			}
+		}
+		status, _ := strconv.Atoi(argv[3])
+		return status
+	case len(argv) == 3 && argv[1] == "leave":
+		if g != nil && len(g.stack) > 1 {
+			g.stack = g.stack[:len(g.stack)-1]
+			if p := state.profiler; p != nil {
+				p.endFunction()
+			}
+		}
+		status, _ := strconv.Atoi(argv[2])
+		return status
+	default:
+		return 2
+	}
+}
+
+// pause records that the command at line is about to run and calls the
+// stop hook if the debugger should pause there.
+func (g *Debugger) pause(ctx context.Context, line int) {
+	g.stack[len(g.stack)-1].Line = line
+	if g.paused {
+		// Commands run by the stop hook itself.
+		return
+	}
+	stop := g.breakpoints[line] ||
+		g.action == DebugStep ||
+		g.action == DebugNext && len(g.stack) <= g.depth
+	if !stop || g.onStop == nil {
+		return
+	}
+	g.paused = true
+	g.action = g.onStop(ctx, g, line)
+	g.paused = false
+	g.depth = len(g.stack)
+}
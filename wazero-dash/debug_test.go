@@ -0,0 +1,108 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// debugScript has commands spanning several lines: a function, an if and a
+// heredoc (whose cat is not found).
+const debugScript = `# greet people
+greet() {
+  msg="hello $1"
+  echo "$msg"
+  return 3
+}
+if true; then
+  echo in if
+fi
+greet world
+echo "status $?"
+cat <<EOF
+greet
+EOF
+false
+`
+
+func TestDebugger(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+
+	var trace []string
+	actions := []DebugAction{DebugNext, DebugNext, DebugStep, DebugStep, DebugNext, DebugNext, DebugContinue, DebugContinue}
+	g, err := d.NewDebugger(ctx, debugScript, Source{Name: "greet.sh"}, func(ctx context.Context, g *Debugger, line int) DebugAction {
+		var frames []string
+		for _, f := range g.Stack() {
+			frames = append(frames, fmt.Sprintf("%s%v@%d", f.Function, f.Args, f.Line))
+		}
+		msg, _ := d.GetVar(ctx, "msg")
+		trace = append(trace, fmt.Sprintf("%d %s msg=%q", line, strings.Join(frames, " "), msg))
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	})
+	if err != nil {
+		t.Fatal("NewDebugger:", err)
+	}
+	if want := []int{2, 3, 4, 5, 7, 10, 11, 12, 15}; !slices.Equal(g.Commands(), want) {
+		t.Fatalf("expected commands %v, got %v", want, g.Commands())
+	}
+	if err := g.SetBreakpoint(8); err == nil {
+		t.Fatal("expected error for a breakpoint inside a compound command")
+	}
+	if err := g.SetBreakpoint(15); err != nil {
+		t.Fatal("SetBreakpoint:", err)
+	}
+
+	status, err := g.Run(ctx, DebugStep)
+	if err != nil {
+		t.Fatal("Run:", err)
+	}
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d", status)
+	}
+	want := []string{
+		`2 []@2 msg=""`,
+		`7 []@7 msg=""`,
+		`10 []@10 msg=""`,
+		`3 greet[world]@3 []@10 msg=""`,
+		`4 greet[world]@4 []@10 msg="hello world"`,
+		`5 greet[world]@5 []@10 msg="hello world"`,
+		`11 []@11 msg="hello world"`,
+		`15 []@15 msg="hello world"`,
+	}
+	if !slices.Equal(trace, want) {
+		t.Fatalf("expected trace\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(trace, "\n"))
+	}
+	if got, want := stdout.String(), "in if\nhello world\nstatus 3\n"; got != want {
+		t.Fatalf("expected output %q, got %q", want, got)
+	}
+
+	// Functions keep working after the debugger finished.
+	stdout.Reset()
+	if status, _ := d.Eval(ctx, "greet again"); status != 3 || stdout.String() != "hello again\n" {
+		t.Fatalf("unexpected greet result %d %q", status, stdout.String())
+	}
+}
+
+func TestDebuggerSyntaxError(t *testing.T) {
+	d, _, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	g, err := d.NewDebugger(ctx, "echo one\nfi\necho two\n", Source{Name: "bad.sh", Line: 10}, nil)
+	if err != nil {
+		t.Fatal("NewDebugger:", err)
+	}
+	if want := []int{10, 11, 12}; !slices.Equal(g.Commands(), want) {
+		t.Fatalf("expected commands %v, got %v", want, g.Commands())
+	}
+	if status, _ := g.Run(ctx, DebugContinue); status != 2 {
+		t.Fatalf("expected status 2, got %d", status)
+	}
+	if !strings.Contains(stderr.String(), "bad.sh: 11: Syntax error") {
+		t.Fatalf("expected the error at the original line, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,162 @@
+package dash
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Capabilities reports what a session configured by a Policy can do, for
+// audit logs and prompts such as "this script will be able to...".
+type Capabilities struct {
+	// Paths are the guest paths the session can access, sorted.
+	Paths []PathAccess `json:"paths"`
+	// Commands are the host commands the session can run. Shell builtins
+	// and functions are always available.
+	Commands CommandAccess `json:"commands"`
+	// Network lists the hosts the embedder may contact for the session.
+	Network NetworkAccess `json:"network"`
+	// Env lists the environment the session receives.
+	Env EnvAccess `json:"env"`
+	// Limits are the resource limits. Zero values are unlimited.
+	Limits PolicyLimits `json:"limits"`
+}
+
+// PathAccess is a guest path of Capabilities.
+type PathAccess struct {
+	Guest string `json:"guest"`
+	// Host is the host directory backing Guest, if any.
+	Host     string `json:"host,omitempty"`
+	Writable bool   `json:"writable"`
+	// MaxBytes caps the file data written below Guest. Zero is no cap.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+}
+
+// CommandAccess lists the host commands of Capabilities.
+type CommandAccess struct {
+	// All is set if any command not in Deny may run.
+	All   bool     `json:"all"`
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// NetworkAccess lists the hosts of Capabilities.
+type NetworkAccess struct {
+	// All is set if every host may be contacted.
+	All   bool     `json:"all"`
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// EnvAccess lists the environment of Capabilities.
+type EnvAccess struct {
+	// Host lists the host variables passed through, as patterns.
+	Host []string `json:"host,omitempty"`
+	// Set holds the fixed values exported.
+	Set map[string]string `json:"set,omitempty"`
+}
+
+// Describe reports the capabilities of a session configured with
+// WithPolicy(p) alone. Options given after WithPolicy are not reflected.
+func (p *Policy) Describe() Capabilities {
+	c := Capabilities{
+		Commands: CommandAccess{
+			All:   len(p.Commands.Allow) == 0,
+			Allow: slices.Clone(p.Commands.Allow),
+			Deny:  slices.Clone(p.Commands.Deny),
+		},
+		Network: NetworkAccess{All: p.Network == nil},
+		Env:     EnvAccess{Host: slices.Clone(p.Env.Allow), Set: maps.Clone(p.Env.Set)},
+		Limits:  p.Limits,
+	}
+	if p.Network != nil {
+		c.Network.Hosts = slices.Clone(p.Network.Allow)
+	}
+	bin := true
+	for _, m := range p.Mounts {
+		c.Paths = append(c.Paths, PathAccess{Guest: m.Guest, Host: m.Host, Writable: !m.ReadOnly && !p.ReadOnly})
+		bin = bin && m.Guest != BinDir
+	}
+	if p.TempDir == nil || !p.TempDir.Disabled {
+		tmp := PathAccess{Guest: TempDir, Writable: !p.ReadOnly, MaxBytes: DefaultTempDirMaxBytes}
+		if p.TempDir != nil {
+			tmp.MaxBytes = p.TempDir.MaxBytes
+		}
+		c.Paths = append(c.Paths, tmp)
+	}
+	if bin {
+		c.Paths = append(c.Paths, PathAccess{Guest: BinDir})
+	}
+	slices.SortFunc(c.Paths, func(a, b PathAccess) int { return strings.Compare(a.Guest, b.Guest) })
+	return c
+}
+
+// String returns a human-readable summary of c, one capability per line.
+func (c Capabilities) String() string {
+	var b strings.Builder
+	for _, p := range c.Paths {
+		access := "read"
+		if p.Writable {
+			access = "read and write"
+		}
+		fmt.Fprintf(&b, "%s %s", access, p.Guest)
+		if p.Host != "" {
+			fmt.Fprintf(&b, " (host %s)", p.Host)
+		}
+		if p.Writable && p.MaxBytes > 0 {
+			fmt.Fprintf(&b, ", up to %d bytes", p.MaxBytes)
+		}
+		b.WriteByte('\n')
+	}
+	switch {
+	case c.Commands.All && len(c.Commands.Deny) == 0:
+		b.WriteString("run any host command\n")
+	case c.Commands.All:
+		fmt.Fprintf(&b, "run any host command except %s\n", strings.Join(c.Commands.Deny, ", "))
+	case len(c.Commands.Deny) == 0:
+		fmt.Fprintf(&b, "run host commands %s\n", strings.Join(c.Commands.Allow, ", "))
+	default:
+		fmt.Fprintf(&b, "run host commands %s, except %s\n", strings.Join(c.Commands.Allow, ", "), strings.Join(c.Commands.Deny, ", "))
+	}
+	switch {
+	case c.Network.All:
+		b.WriteString("contact any network host\n")
+	case len(c.Network.Hosts) == 0:
+		b.WriteString("contact no network hosts\n")
+	default:
+		fmt.Fprintf(&b, "contact network hosts %s\n", strings.Join(c.Network.Hosts, ", "))
+	}
+	if len(c.Env.Host) > 0 {
+		fmt.Fprintf(&b, "read host environment variables %s\n", strings.Join(c.Env.Host, ", "))
+	}
+	if len(c.Env.Set) > 0 {
+		fmt.Fprintf(&b, "receive environment variables %s\n", strings.Join(slices.Sorted(maps.Keys(c.Env.Set)), ", "))
+	}
+	l := c.Limits
+	if l.MaxMemoryBytes > 0 {
+		fmt.Fprintf(&b, "use up to %d bytes of memory\n", l.MaxMemoryBytes)
+	}
+	if l.MaxArgBytes > 0 {
+		fmt.Fprintf(&b, "pass up to %d bytes of arguments to host commands\n", l.MaxArgBytes)
+	}
+	if l.MaxExpansionBytes > 0 {
+		fmt.Fprintf(&b, "expand words of up to %d bytes\n", l.MaxExpansionBytes)
+	}
+	if l.MaxHereDocBytes > 0 {
+		fmt.Fprintf(&b, "write here-documents of up to %d bytes\n", l.MaxHereDocBytes)
+	}
+	if l.WriteQuota.MaxBytes > 0 {
+		fmt.Fprintf(&b, "write up to %d bytes to files in total\n", l.WriteQuota.MaxBytes)
+	}
+	if l.WriteQuota.MaxFiles > 0 {
+		fmt.Fprintf(&b, "create up to %d files in total\n", l.WriteQuota.MaxFiles)
+	}
+	if l.CommandTimeout > 0 {
+		fmt.Fprintf(&b, "run each host command for up to %s\n", time.Duration(l.CommandTimeout))
+	}
+	for _, name := range slices.Sorted(maps.Keys(l.CommandTimeouts)) {
+		fmt.Fprintf(&b, "run %s for up to %s\n", name, time.Duration(l.CommandTimeouts[name]))
+	}
+	return b.String()
+}
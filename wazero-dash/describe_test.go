@@ -0,0 +1,52 @@
+package dash
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	p, err := LoadPolicy(strings.NewReader(`{
+  "mounts": [{"host": "./src", "guest": "/src", "readOnly": true}, {"host": "./out", "guest": "/out"}],
+  "tempDir": {"maxBytes": 4096},
+  "env": {"allow": ["CI_*"], "set": {"MODE": "audit"}},
+  "commands": {"allow": ["git", "make*"], "deny": ["makepkg"]},
+  "network": {"allow": []},
+  "limits": {"maxArgBytes": 64, "commandTimeout": "30s", "commandTimeouts": {"make": "5m"}}
+}`))
+	if err != nil {
+		t.Fatal("LoadPolicy:", err)
+	}
+	want := `read /bin
+read and write /out (host ./out)
+read /src (host ./src)
+read and write /tmp, up to 4096 bytes
+run host commands git, make*, except makepkg
+contact no network hosts
+read host environment variables CI_*
+receive environment variables MODE
+pass up to 64 bytes of arguments to host commands
+run each host command for up to 30s
+run make for up to 5m0s
+`
+	if got := p.Describe().String(); got != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	data, err := json.Marshal(p.Describe())
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	for _, want := range []string{`{"guest":"/src","host":"./src","writable":false}`, `"network":{"all":false}`, `"commandTimeout":"30s"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s in %s", want, data)
+		}
+	}
+
+	var open Policy
+	open.ReadOnly = true
+	if got, want := open.Describe().String(), "read /bin\nread /tmp\nrun any host command\ncontact any network host\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,161 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxRecentCommands is the number of recent commands kept for diagnostics.
+const maxRecentCommands = 32
+
+// maxRecentCommandLen truncates recorded commands.
+const maxRecentCommandLen = 256
+
+// Diagnostics describes an unexpected failure of a guest call, for offline
+// analysis. See WithDiagnostics.
+type Diagnostics struct {
+	// Time is when the failure was observed.
+	Time time.Time
+	// Op is the failed operation, e.g. "dash_eval".
+	Op string
+	// Err is the error returned by the call.
+	Err error
+	// StackTrace holds the wasm stack frames reported by wazero, innermost
+	// first. The embedded module has no name section, so frames are named
+	// by function index.
+	StackTrace []string
+	// RecentCommands lists the latest evaluated scripts and host-dispatched
+	// commands, oldest first, each prefixed by "eval: " or "exec: ".
+	RecentCommands []string
+	// State is the output of `set` after the failure, or empty if it could
+	// not be captured, in which case StateErr says why.
+	State    string
+	StateErr error
+	// Memory describes the guest memory at the time of the failure.
+	Memory MemoryStats
+}
+
+// MemoryStats describes the guest memory of a shell.
+type MemoryStats struct {
+	// MemoryBytes is the size of linear memory.
+	MemoryBytes uint64
+	// StackPointer and HeapBase are the guest's __stack_pointer and
+	// __heap_base. The C stack grows down from HeapBase.
+	StackPointer uint32
+	HeapBase     uint32
+	// Checkpoints is the number of live setjmp checkpoints.
+	Checkpoints int
+	// TempDir is the usage of the in-memory /tmp.
+	TempDir DirUsage
+}
+
+// WriteTo writes a human-readable report.
+func (g *Diagnostics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\nop: %s\nerror: %v\n", g.Time.Format(time.RFC3339Nano), g.Op, firstLine(fmt.Sprint(g.Err)))
+	fmt.Fprintf(&b, "memory: %d bytes, stack pointer %#x, heap base %#x, %d checkpoints\n",
+		g.Memory.MemoryBytes, g.Memory.StackPointer, g.Memory.HeapBase, g.Memory.Checkpoints)
+	fmt.Fprintf(&b, "tmp: %d bytes in %d files\n", g.Memory.TempDir.Bytes, g.Memory.TempDir.Files)
+	b.WriteString("wasm stack trace:\n")
+	for _, frame := range g.StackTrace {
+		b.WriteString("\t" + frame + "\n")
+	}
+	b.WriteString("recent commands:\n")
+	for _, cmd := range g.RecentCommands {
+		b.WriteString("\t" + strings.ReplaceAll(cmd, "\n", "\n\t\t") + "\n")
+	}
+	if g.StateErr != nil {
+		fmt.Fprintf(&b, "state: unavailable: %v\n", g.StateErr)
+	} else {
+		b.WriteString("state:\n" + g.State)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// WithDiagnostics calls sink with a Diagnostics bundle whenever a guest
+// call fails unexpectedly, e.g. on a wasm trap or a panicking handler.
+//
+// Size limit and Terminate errors are expected and not reported. The sink
+// runs before the failing method returns.
+func WithDiagnostics(sink func(*Diagnostics)) Option {
+	return func(o *options) {
+		o.diagnostics = sink
+	}
+}
+
+// recordCommand appends to the recent command history if diagnostics are
+// enabled.
+func (s *dashState) recordCommand(kind, cmd string) {
+	if s.diagnostics == nil {
+		return
+	}
+	if len(cmd) > maxRecentCommandLen {
+		cmd = cmd[:maxRecentCommandLen] + "..."
+	}
+	if len(s.recent) == maxRecentCommands {
+		s.recent = append(s.recent[:0], s.recent[1:]...)
+	}
+	s.recent = append(s.recent, kind+": "+cmd)
+}
+
+// diagnose reports an unexpected failure of op to the diagnostics sink.
+func (d *Dash) diagnose(ctx context.Context, op string, err error) {
+	sink := d.state.diagnostics
+	if sink == nil || d.state.depth != 0 || errors.Is(err, ErrTerminated) || errors.Is(err, ErrSizeLimitExceeded) {
+		return
+	}
+	g := &Diagnostics{
+		Time:           time.Now(),
+		Op:             op,
+		Err:            err,
+		StackTrace:     wasmStackTrace(err),
+		RecentCommands: append([]string(nil), d.state.recent...),
+		Memory:         d.MemoryStats(),
+	}
+
+	// The failed call did not unwind, so dash may be unable to run even
+	// `set`. Any failure is reported rather than recursing.
+	d.state.diagnostics = nil
+	out, stateErr := d.evalQuiet(ctx, "set")
+	d.state.diagnostics = sink
+	g.State, g.StateErr = string(out), stateErr
+	sink(g)
+}
+
+// MemoryStats returns statistics about the guest memory.
+func (d *Dash) MemoryStats() MemoryStats {
+	stats := MemoryStats{
+		MemoryBytes: uint64(d.mod.Memory().Size()),
+		Checkpoints: len(d.state.checkpoints),
+		TempDir:     d.TempDirUsage(),
+	}
+	if sp := d.mod.ExportedGlobal("__stack_pointer"); sp != nil {
+		stats.StackPointer = uint32(sp.Get())
+	}
+	if hb := d.mod.ExportedGlobal("__heap_base"); hb != nil {
+		stats.HeapBase = uint32(hb.Get())
+	}
+	return stats
+}
+
+// wasmStackTrace extracts the frames of the stack trace wazero appends to
+// errors.
+func wasmStackTrace(err error) []string {
+	_, trace, ok := strings.Cut(err.Error(), "wasm stack trace:\n")
+	if !ok {
+		return nil
+	}
+	var frames []string
+	for _, line := range strings.Split(trace, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			break
+		}
+		frames = append(frames, strings.TrimSpace(line))
+	}
+	return frames
+}
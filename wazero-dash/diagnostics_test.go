@@ -0,0 +1,71 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	var reports []*Diagnostics
+	d, err := NewDash(ctx, WithRuntime(r), WithDiagnostics(func(g *Diagnostics) {
+		reports = append(reports, g)
+	}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		panic("handler bug")
+	})
+
+	if _, err := d.Eval(ctx, "MARKER=before"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if len(reports) != 0 {
+		t.Fatal("unexpected report for a successful Eval")
+	}
+	if _, err := d.Eval(ctx, "crash now"); err == nil {
+		t.Fatal("expected Eval to fail")
+	}
+	if len(reports) != 1 {
+		t.Fatalf("expected one report, got %d", len(reports))
+	}
+
+	g := reports[0]
+	if g.Op != "dash_eval" || !strings.Contains(g.Err.Error(), "handler bug") {
+		t.Fatalf("unexpected report %q: %v", g.Op, g.Err)
+	}
+	if len(g.StackTrace) == 0 {
+		t.Fatal("expected a wasm stack trace")
+	}
+	want := []string{"eval: MARKER=before", "eval: crash now", "exec: crash now"}
+	if !slices.Equal(g.RecentCommands, want) {
+		t.Fatalf("expected recent commands %q, got %q", want, g.RecentCommands)
+	}
+	if g.StateErr != nil || !strings.Contains(g.State, "MARKER='before'") {
+		t.Fatalf("expected state dump with MARKER, got %q (%v)", g.State, g.StateErr)
+	}
+	if g.Memory.MemoryBytes == 0 || g.Memory.HeapBase == 0 {
+		t.Fatalf("unexpected memory stats %+v", g.Memory)
+	}
+
+	var buf bytes.Buffer
+	if _, err := g.WriteTo(&buf); err != nil {
+		t.Fatal("WriteTo:", err)
+	}
+	if !strings.Contains(buf.String(), "wasm stack trace:\n\t") {
+		t.Fatalf("unexpected report text:\n%s", buf.String())
+	}
+}
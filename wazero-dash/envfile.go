@@ -0,0 +1,278 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// envAssignment is a variable assignment of an environment file.
+type envAssignment struct {
+	name  string
+	value []envPart
+}
+
+// envPart is literal text, or the value of the variable ref if set.
+type envPart struct {
+	text, ref string
+}
+
+// WithEnvFile exports the variables assigned in the dotenv file read from r
+// during Init. May be repeated; files apply in order.
+//
+// Each line holds a NAME=VALUE assignment, optionally preceded by export, or
+// a # comment. Values follow the quoting rules of the shell: single quotes
+// are literal, double quotes and unquoted text expand $NAME and ${NAME} and
+// honor backslash escapes, and quoted values may span lines. Command
+// substitution is rejected. The file is read by NewDash.
+func WithEnvFile(r io.Reader) Option {
+	return func(o *options) {
+		o.envFiles = append(o.envFiles, r)
+	}
+}
+
+// readEnvFiles reads and parses the files passed to WithEnvFile.
+func readEnvFiles(files []io.Reader) ([]envAssignment, error) {
+	var all []envAssignment
+	for _, r := range files {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		env, err := parseEnvFile(string(data))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, env...)
+	}
+	return all, nil
+}
+
+// parseEnvFile parses the assignments of a dotenv file.
+func parseEnvFile(s string) ([]envAssignment, error) {
+	p := &envParser{s: s, line: 1}
+	var env []envAssignment
+	for {
+		p.skipBlank()
+		if p.done() {
+			return env, nil
+		}
+		if p.peek() == '#' {
+			p.skipComment()
+			continue
+		}
+		start := p.line
+		a, err := p.assignment()
+		if err != nil {
+			return nil, fmt.Errorf("dash: env file line %d: %w", start, err)
+		}
+		env = append(env, a)
+	}
+}
+
+// envParser is the state of parseEnvFile.
+type envParser struct {
+	s    string
+	i    int
+	line int
+}
+
+func (p *envParser) done() bool { return p.i >= len(p.s) }
+func (p *envParser) peek() byte { return p.s[p.i] }
+
+// next consumes and returns the next byte, counting lines.
+func (p *envParser) next() byte {
+	c := p.s[p.i]
+	p.i++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipBlank skips whitespace, including newlines.
+func (p *envParser) skipBlank() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.next()
+	}
+}
+
+// skipComment skips to the end of the line.
+func (p *envParser) skipComment() {
+	for !p.done() && p.peek() != '\n' {
+		p.next()
+	}
+}
+
+// name consumes a variable name, returning "" if there is none.
+func (p *envParser) name() string {
+	start := p.i
+	for !p.done() {
+		c := p.peek()
+		if c != '_' && !('a' <= c|0x20 && c|0x20 <= 'z') && !(p.i > start && '0' <= c && c <= '9') {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// assignment parses [export] NAME=VALUE up to the end of the line.
+func (p *envParser) assignment() (envAssignment, error) {
+	name := p.name()
+	if name == "export" && !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+			p.next()
+		}
+		name = p.name()
+	}
+	if name == "" || p.done() || p.peek() != '=' {
+		return envAssignment{}, errors.New("expected NAME=VALUE")
+	}
+	p.next()
+	a := envAssignment{name: name}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			a.value = append(a.value, envPart{text: text.String()})
+			text.Reset()
+		}
+	}
+	ref := func() error {
+		r, err := p.ref()
+		if err != nil {
+			return err
+		}
+		if r == "" {
+			text.WriteByte('$')
+			return nil
+		}
+		flush()
+		a.value = append(a.value, envPart{ref: r})
+		return nil
+	}
+	for !p.done() {
+		switch c := p.peek(); c {
+		case ' ', '\t', '\r', '\n':
+			flush()
+			return a, p.endOfLine()
+		case '\'':
+			p.next()
+			end := strings.IndexByte(p.s[p.i:], '\'')
+			if end < 0 {
+				return envAssignment{}, errors.New("unterminated single quote")
+			}
+			for range end {
+				text.WriteByte(p.next())
+			}
+			p.next()
+		case '"':
+			p.next()
+			for closed := false; !closed; {
+				if p.done() {
+					return envAssignment{}, errors.New("unterminated double quote")
+				}
+				switch c := p.next(); {
+				case c == '"':
+					closed = true
+				case c == '\\' && !p.done() && strings.IndexByte("$`\"\\\n", p.peek()) >= 0:
+					if c := p.next(); c != '\n' {
+						text.WriteByte(c)
+					}
+				case c == '$':
+					if err := ref(); err != nil {
+						return envAssignment{}, err
+					}
+				case c == '`':
+					return envAssignment{}, errors.New("command substitution is not supported")
+				default:
+					text.WriteByte(c)
+				}
+			}
+		case '\\':
+			p.next()
+			if p.done() {
+				return envAssignment{}, errors.New("trailing backslash")
+			}
+			if c := p.next(); c != '\n' {
+				text.WriteByte(c)
+			}
+		case '$':
+			p.next()
+			if err := ref(); err != nil {
+				return envAssignment{}, err
+			}
+		case '`', ';', '&', '|', '<', '>', '(', ')':
+			return envAssignment{}, fmt.Errorf("unexpected %q in value", c)
+		default:
+			text.WriteByte(p.next())
+		}
+	}
+	flush()
+	return a, nil
+}
+
+// ref parses the variable reference following a $, returning "" for a
+// literal $.
+func (p *envParser) ref() (string, error) {
+	if p.done() {
+		return "", nil
+	}
+	switch p.peek() {
+	case '(':
+		return "", errors.New("command substitution is not supported")
+	case '{':
+		p.next()
+		name := p.name()
+		if name == "" || p.done() || p.peek() != '}' {
+			return "", errors.New("unsupported parameter expansion")
+		}
+		p.next()
+		return name, nil
+	}
+	return p.name(), nil
+}
+
+// endOfLine consumes trailing blanks and an optional comment, failing if
+// anything else follows the value on its line.
+func (p *envParser) endOfLine() error {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.next()
+	}
+	if p.done() || p.peek() == '\n' {
+		return nil
+	}
+	if p.peek() == '#' {
+		p.skipComment()
+		return nil
+	}
+	return errors.New("unexpected text after value")
+}
+
+// exportEnv exports the assignments of the environment files, expanding
+// references against the variables set so far.
+func (d *Dash) exportEnv(ctx context.Context) error {
+	for _, a := range d.state.env {
+		var value strings.Builder
+		for _, part := range a.value {
+			if part.ref == "" {
+				value.WriteString(part.text)
+				continue
+			}
+			v, err := d.GetVar(ctx, part.ref)
+			if err != nil {
+				return err
+			}
+			value.WriteString(v)
+		}
+		if err := d.SetVar(ctx, a.name, value.String()); err != nil {
+			return err
+		}
+		if _, err := d.evalQuiet(ctx, "export "+a.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+const testEnvFile = `# deployment settings
+export APP_NAME=demo
+GREETING="hello, $APP_NAME"  # trailing comment
+LITERAL='no $expansion \ here'
+ESCAPED=a\ b\$c
+MULTI="line one
+line two"
+BRACED=${APP_NAME}_x
+EMPTY=
+HASH=a#b
+`
+
+func TestEnvFile(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	var stdout bytes.Buffer
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(wazero.NewModuleConfig().WithStdout(&stdout)),
+		WithEnvFile(strings.NewReader(testEnvFile)),
+		WithEnvFile(strings.NewReader("APP_NAME=override\n")))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	for name, want := range map[string]string{
+		"APP_NAME": "override",
+		"GREETING": "hello, demo",
+		"LITERAL":  `no $expansion \ here`,
+		"ESCAPED":  "a b$c",
+		"MULTI":    "line one\nline two",
+		"BRACED":   "demo_x",
+		"EMPTY":    "",
+		"HASH":     "a#b",
+	} {
+		got, err := d.GetVar(ctx, name)
+		if err != nil {
+			t.Fatal("GetVar:", err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	env, err := d.Environ(ctx)
+	if err != nil {
+		t.Fatal("Environ:", err)
+	}
+	for _, want := range []string{"APP_NAME=override", "EMPTY=", "MULTI=line one\nline two"} {
+		if !strings.Contains(strings.Join(env, "\x00")+"\x00", want+"\x00") {
+			t.Fatalf("expected %q exported, got %q", want, env)
+		}
+	}
+}
+
+func TestEnvFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		file, err string
+	}{
+		{"A=1\nB=$(date)\n", "line 2: command substitution"},
+		{"A=`date`", "line 1: unexpected '`'"},
+		{"A=\"x`date`\"", "command substitution"},
+		{"A='open\n\n", "line 1: unterminated single quote"},
+		{"A=1; rm x", "unexpected ';'"},
+		{"A=1 B=2", "unexpected text after value"},
+		{"A = 1", "expected NAME=VALUE"},
+		{"1A=1", "expected NAME=VALUE"},
+		{"A=${B:-x}", "unsupported parameter expansion"},
+	} {
+		_, err := parseEnvFile(tc.file)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%q: expected error containing %q, got %v", tc.file, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+)
+
+// Environ returns the shell's exported variables in KEY=value form.
+//
+// This is the environment a command started by the shell would see, suitable
+// for os/exec.Cmd.Env when spawning native processes. Exported variables
+// without a value are omitted, as are unexported shell variables. Entries
+// are sorted by name.
+//
+// It uses the dash_listvars export if the module has it, and otherwise
+// parses the output of `export -p`.
+func (d *Dash) Environ(ctx context.Context) ([]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.dashListVars != nil {
+		vars, err := d.listVars(ctx, dashwasi.ListVarsExported)
+		if err != nil {
+			return nil, err
+		}
+		env := make([]string, 0, len(vars))
+		for _, name := range slices.Sorted(maps.Keys(vars)) {
+			env = append(env, name+"="+vars[name])
+		}
+		return env, nil
+	}
+	out, err := d.evalQuiet(ctx, "export -p")
+	if err != nil {
+		return nil, err
+	}
+	env, err := parseExportList(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("parse export -p: %w", err)
+	}
+	return env, nil
+}
+
+// Vars returns all set shell variables by name, exported or not.
+//
+// It uses the dash_listvars export if the module has it, and otherwise
+// parses the output of `set`.
+func (d *Dash) Vars(ctx context.Context) (map[string]string, error) {
+	return d.shellVars(ctx)
+}
+
+// ExportedVars returns the shell's exported variables by name, as Environ
+// but without parsing KEY=value entries. Exported variables without a value
+// are omitted.
+func (d *Dash) ExportedVars(ctx context.Context) (map[string]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.dashListVars != nil {
+		return d.listVars(ctx, dashwasi.ListVarsExported)
+	}
+	env, err := d.Environ(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// listVars calls dash_listvars with flags and parses the returned list.
+func (d *Dash) listVars(ctx context.Context, flags uint32) (map[string]string, error) {
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	results, err := d.call(ctx, d.dashListVars, uint64(flags))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(results[0])
+	if ptr == 0 {
+		return nil, errors.New("dash_listvars failed")
+	}
+	defer d.freePtr(ctx, ptr)
+	return d.readVarList(ptr)
+}
+
+// readVarList reads the dash_listvars list at ptr.
+func (d *Dash) readVarList(ptr uint32) (map[string]string, error) {
+	vars := make(map[string]string)
+	for {
+		entry := d.readCString(ptr)
+		if entry == "" {
+			return vars, nil
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, errors.New("dash_listvars: unexpected entry: " + entry)
+		}
+		vars[name] = value
+		ptr += uint32(len(entry)) + 1
+	}
+}
+
+// VarNames returns the names of all set shell variables, exported or not,
+// sorted.
+func (d *Dash) VarNames(ctx context.Context) ([]string, error) {
+	vars, err := d.shellVars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Aliases returns the shell's aliases by name.
+func (d *Dash) Aliases(ctx context.Context) (map[string]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	out, err := d.evalQuiet(ctx, "alias")
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := parseAliasList(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("parse alias: %w", err)
+	}
+	return aliases, nil
+}
+
+// parseAliasList parses the output of `alias`: one quoted `name=value`
+// word per alias.
+func parseAliasList(out string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for out != "" {
+		word, tail, err := unquoteWord(out)
+		if err != nil {
+			return nil, err
+		}
+		name, value, ok := strings.Cut(word, "=")
+		if !ok {
+			return nil, errors.New("unexpected line: " + firstLine(out))
+		}
+		aliases[name] = value
+		out = strings.TrimPrefix(tail, "\n")
+	}
+	return aliases, nil
+}
+
+// parseExportList parses the output of `export -p`.
+//
+// Each entry is `export NAME` or `export NAME=value`, with the value quoted
+// as by dash's single_quote: single-quoted runs, double-quoted runs of
+// single quotes and backslash escapes, concatenated.
+func parseExportList(out string) ([]string, error) {
+	var env []string
+	for out != "" {
+		rest, ok := strings.CutPrefix(out, "export ")
+		if !ok {
+			return nil, errors.New("unexpected line: " + firstLine(out))
+		}
+		end := strings.IndexAny(rest, "=\n")
+		if end == -1 || rest[end] == '\n' {
+			// Exported but unset.
+			_, out, _ = strings.Cut(rest, "\n")
+			continue
+		}
+		name := rest[:end]
+		value, tail, err := unquoteWord(rest[end+1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		env = append(env, name+"="+value)
+		out = strings.TrimPrefix(tail, "\n")
+	}
+	return env, nil
+}
+
+// unquoteWord decodes a shell word quoted by dash up to the next unquoted
+// newline, returning the value and the remaining input.
+func unquoteWord(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '\n':
+			return b.String(), s[i:], nil
+		case '\'', '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return "", "", errors.New("unterminated quote")
+			}
+			b.WriteString(s[i+1 : i+1+end])
+			i += end + 2
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", errors.New("trailing backslash")
+			}
+			b.WriteByte(s[i+1])
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), "", nil
+}
+
+// firstLine returns s up to the first newline.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
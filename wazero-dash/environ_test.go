@@ -0,0 +1,96 @@
+package dash
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestEnviron(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	config := wazero.NewModuleConfig().WithEnv("HOME", "/home")
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	script := "A=\"it's '' quoted\nline\"; export A; export UNSET; LOCAL=1; false"
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	env, err := d.Environ(ctx)
+	if err != nil {
+		t.Fatal("Environ:", err)
+	}
+	want := []string{"A=it's '' quoted\nline", "HOME=/home", "PWD=/"}
+	if !slices.Equal(env, want) {
+		t.Fatalf("expected %q, got %q", want, env)
+	}
+
+	// Environ leaves $? alone.
+	if status, _ := d.GetExitStatus(ctx); status != 1 {
+		t.Fatalf("expected exit status 1, got %d", status)
+	}
+}
+
+func TestVars(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithModuleConfig(wazero.NewModuleConfig().WithEnv("HOME", "/home")))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "A='x y\nz'; export A; export UNSET; LOCAL=1"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	vars, err := d.Vars(ctx)
+	if err != nil {
+		t.Fatal("Vars:", err)
+	}
+	for name, want := range map[string]string{"A": "x y\nz", "HOME": "/home", "LOCAL": "1"} {
+		if vars[name] != want {
+			t.Fatalf("Vars()[%q] = %q, want %q", name, vars[name], want)
+		}
+	}
+	if _, ok := vars["UNSET"]; ok {
+		t.Fatal("expected no unset variable")
+	}
+
+	exported, err := d.ExportedVars(ctx)
+	if err != nil {
+		t.Fatal("ExportedVars:", err)
+	}
+	want := map[string]string{"A": "x y\nz", "HOME": "/home", "PWD": "/"}
+	if !maps.Equal(exported, want) {
+		t.Fatalf("ExportedVars() = %q, want %q", exported, want)
+	}
+
+	// The dash_listvars list is parsed up to its empty entry.
+	ptr, err := d.allocString(ctx, "A=1\x00B=x=y\x00\x00C=ignored\x00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.freePtr(ctx, ptr)
+	list, err := d.readVarList(ptr)
+	if err != nil {
+		t.Fatal("readVarList:", err)
+	}
+	if want := map[string]string{"A": "1", "B": "x=y"}; !maps.Equal(list, want) {
+		t.Fatalf("readVarList = %q, want %q", list, want)
+	}
+}
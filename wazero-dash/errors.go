@@ -0,0 +1,104 @@
+package dash
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNotInitialized is returned by methods that need a shell when Init has
+// not been called successfully.
+var ErrNotInitialized = errors.New("dash: not initialized")
+
+// ErrAlreadyInitialized is returned by Init on an initialized shell.
+var ErrAlreadyInitialized = errors.New("dash: already initialized")
+
+// ErrExportMissing is wrapped by the errors returned when the module lacks
+// an export required by the wrapper.
+var ErrExportMissing = errors.New("dash: missing export")
+
+// ExportMissingError is returned by NewDash when the module lacks a
+// required export.
+type ExportMissingError struct {
+	// Name is the name of the missing export.
+	Name string
+}
+
+// Error implements error.
+func (e *ExportMissingError) Error() string {
+	return ErrExportMissing.Error() + ": " + e.Name
+}
+
+// Unwrap returns ErrExportMissing.
+func (e *ExportMissingError) Unwrap() error {
+	return ErrExportMissing
+}
+
+// ErrABIMismatch is wrapped by the errors returned when an export of the
+// module does not have the signature given by dashwasi.ABI.
+var ErrABIMismatch = errors.New("dash: export signature mismatch")
+
+// ABIMismatchError is returned by NewDash and ValidateABI when an export of
+// the module has the wrong signature.
+type ABIMismatchError struct {
+	// Name is the name of the export.
+	Name string
+	// Want and Got are the expected and actual signatures, such as
+	// "(i32, i32) -> i32".
+	Want, Got string
+}
+
+// Error implements error.
+func (e *ABIMismatchError) Error() string {
+	return ErrABIMismatch.Error() + ": " + e.Name + ": want " + e.Want + ", got " + e.Got
+}
+
+// Unwrap returns ErrABIMismatch.
+func (e *ABIMismatchError) Unwrap() error {
+	return ErrABIMismatch
+}
+
+// ErrEvalTrap is wrapped by the errors returned when a guest call trapped
+// instead of returning, so the shell state may be inconsistent.
+var ErrEvalTrap = errors.New("dash: guest call trapped")
+
+// EvalTrapError is returned when a call into the guest, such as dash_eval,
+// trapped. It matches both ErrEvalTrap and the cause, which wraps
+// ErrHostTrap if a host function aborted the call.
+type EvalTrapError struct {
+	// Op is the name of the called export.
+	Op string
+	// Cause is the error returned by the wasm runtime.
+	Cause error
+	// WASMStack holds the frames of the guest stack trace, innermost
+	// first, or is nil if the runtime reported none.
+	WASMStack []string
+}
+
+// Error implements error.
+func (e *EvalTrapError) Error() string {
+	return e.Op + " failed: " + e.Cause.Error()
+}
+
+// Unwrap returns ErrEvalTrap and the cause.
+func (e *EvalTrapError) Unwrap() []error {
+	return []error{ErrEvalTrap, e.Cause}
+}
+
+// trapError wraps the error of the failed guest call op in an
+// EvalTrapError. Errors after Terminate are returned unchanged.
+func trapError(op string, err error) error {
+	if errors.Is(err, ErrTerminated) {
+		return err
+	}
+	return &EvalTrapError{Op: op, Cause: err, WASMStack: wasmStackTrace(err)}
+}
+
+// ExitError reports a script that completed with a non-zero exit status.
+type ExitError struct {
+	Status int
+}
+
+// Error implements error.
+func (e *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(e.Status)
+}
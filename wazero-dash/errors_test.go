@@ -0,0 +1,48 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if _, err := d.Eval(ctx, "true"); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected ErrNotInitialized, got %v", err)
+	}
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if err := d.Init(ctx, nil); !errors.Is(err, ErrAlreadyInitialized) {
+		t.Fatalf("expected ErrAlreadyInitialized, got %v", err)
+	}
+
+	boom := errors.New("boom")
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		panic(boom)
+	})
+	_, err = d.Eval(ctx, "explode")
+	var trap *EvalTrapError
+	if !errors.As(err, &trap) || !errors.Is(err, ErrEvalTrap) || !errors.Is(err, boom) {
+		t.Fatalf("expected an EvalTrapError caused by boom, got %v", err)
+	}
+	if trap.Op != "dash_eval" || len(trap.WASMStack) == 0 {
+		t.Fatalf("unexpected trap %+v", trap)
+	}
+
+	_, err = RunParallel(ctx, []Script{{Name: "fail", Code: "false"}}, 1, CollectAll)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Status != 1 {
+		t.Fatalf("expected an ExitError with status 1, got %v", err)
+	}
+
+	if err := error(&ExportMissingError{Name: "dash_eval"}); !errors.Is(err, ErrExportMissing) || err.Error() != "dash: missing export: dash_eval" {
+		t.Fatalf("unexpected ExportMissingError %v", err)
+	}
+}
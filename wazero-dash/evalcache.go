@@ -0,0 +1,157 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"slices"
+	"sync"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// EvalCache memoizes the results of EvalCached, for build-system style use
+// where a script is re-run only when its inputs change. It is safe for
+// concurrent use and may be shared by several Dash instances.
+type EvalCache struct {
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]EvalResult
+}
+
+// NewEvalCache returns an empty EvalCache.
+func NewEvalCache() *EvalCache {
+	return &EvalCache{results: make(map[[sha256.Size]byte]EvalResult)}
+}
+
+// Len returns the number of cached results.
+func (c *EvalCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.results)
+}
+
+// Clear removes all cached results.
+func (c *EvalCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.results)
+}
+
+// EvalInputs declares what a cached script depends on besides its text.
+type EvalInputs struct {
+	// Files are guest paths on managed mounts, such as WithDirMount and
+	// the in-memory /tmp. Their contents are part of the cache key; a
+	// missing file is an input too.
+	Files []string
+	// Env are the names of the shell variables the script reads.
+	Env []string
+}
+
+// EvalResult is the result of EvalCached.
+type EvalResult struct {
+	Stdout, Stderr []byte
+	Status         int
+	// Cached is set if the result was replayed from the cache.
+	Cached bool
+}
+
+// EvalCached evaluates cmd like EvalCapture, unless cache holds a result
+// for the same cmd and inputs, which is then returned without running cmd.
+//
+// Only scripts whose effects are their output and exit status should be
+// cached: variables, functions and files the script would change are not
+// replayed. Evaluations failing with an error are not cached.
+func (d *Dash) EvalCached(ctx context.Context, cache *EvalCache, cmd string, inputs EvalInputs) (EvalResult, error) {
+	key, err := d.evalCacheKey(ctx, cmd, inputs)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	cache.mu.Lock()
+	r, ok := cache.results[key]
+	cache.mu.Unlock()
+	if ok {
+		return EvalResult{Stdout: bytes.Clone(r.Stdout), Stderr: bytes.Clone(r.Stderr), Status: r.Status, Cached: true}, nil
+	}
+
+	stdout, stderr, status, err := d.EvalCapture(ctx, cmd)
+	if err != nil {
+		return EvalResult{Stdout: stdout, Stderr: stderr, Status: status}, err
+	}
+	r = EvalResult{Stdout: stdout, Stderr: stderr, Status: status}
+	cache.mu.Lock()
+	cache.results[key] = EvalResult{Stdout: bytes.Clone(stdout), Stderr: bytes.Clone(stderr), Status: status}
+	cache.mu.Unlock()
+	return r, nil
+}
+
+// evalCacheKey hashes cmd with the current state of inputs.
+func (d *Dash) evalCacheKey(ctx context.Context, cmd string, inputs EvalInputs) ([sha256.Size]byte, error) {
+	var key [sha256.Size]byte
+	h := sha256.New()
+	writeField(h, []byte(cmd))
+	h.Write(binary.AppendUvarint(nil, uint64(len(inputs.Env))))
+	for _, name := range slices.Sorted(slices.Values(inputs.Env)) {
+		value, err := d.GetVar(ctx, name)
+		if err != nil {
+			return key, err
+		}
+		writeField(h, []byte(name))
+		writeField(h, []byte(value))
+	}
+	h.Write(binary.AppendUvarint(nil, uint64(len(inputs.Files))))
+	for _, file := range slices.Sorted(slices.Values(inputs.Files)) {
+		p := d.guestPath(ctx, file)
+		writeField(h, []byte(p))
+		if err := d.hashGuestFile(h, p); err != nil {
+			return key, err
+		}
+	}
+	h.Sum(key[:0])
+	return key, nil
+}
+
+// hashGuestFile writes the contents of the guest file p to h, or a marker
+// if it does not exist.
+func (d *Dash) hashGuestFile(h hash.Hash, p string) error {
+	m, rel, ok := d.state.lookupMount(p)
+	if !ok {
+		return errors.New("dash: cache input " + p + ": not on a managed mount")
+	}
+	f, errno := m.fs.OpenFile(rel, experimentalsys.O_RDONLY, 0)
+	if errno == experimentalsys.ENOENT {
+		writeField(h, nil)
+		return nil
+	}
+	if errno != 0 {
+		return errors.New("dash: cache input " + p + ": " + errno.Error())
+	}
+	defer f.Close()
+	if st, errno := f.Stat(); errno == 0 && st.Mode.IsDir() {
+		return errors.New("dash: cache input " + p + ": is a directory")
+	}
+	sum := sha256.New()
+	buf := make([]byte, 32<<10)
+	for {
+		n, errno := f.Read(buf)
+		sum.Write(buf[:n])
+		if errno != 0 {
+			return errors.New("dash: cache input " + p + ": " + errno.Error())
+		}
+		if n == 0 {
+			break
+		}
+	}
+	h.Write([]byte{1})
+	writeField(h, sum.Sum(nil))
+	return nil
+}
+
+// writeField writes b to h, prefixed by its length so that fields cannot run
+// into each other.
+func writeField(h hash.Hash, b []byte) {
+	h.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	h.Write(b)
+}
@@ -0,0 +1,54 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvalCached(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+	cache := NewEvalCache()
+	inputs := EvalInputs{Files: []string{"/tmp/in"}, Env: []string{"MODE"}}
+	runs := 0
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		runs++
+		return 1
+	})
+	script := `echo "mode $MODE"; tick`
+	eval := func(want string, cached bool) {
+		t.Helper()
+		r, err := d.EvalCached(ctx, cache, script, inputs)
+		if err != nil {
+			t.Fatal("EvalCached:", err)
+		}
+		if string(r.Stdout) != want || r.Status != 1 || r.Cached != cached {
+			t.Fatalf("expected %q (cached %v), got %+v", want, cached, r)
+		}
+	}
+
+	if err := d.SetVar(ctx, "MODE", "a"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	eval("mode a\n", false)
+	eval("mode a\n", true)
+	if _, err := d.Eval(ctx, "true >/tmp/in"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	eval("mode a\n", false)
+	eval("mode a\n", true)
+	if err := d.SetVar(ctx, "MODE", "b"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	eval("mode b\n", false)
+	if runs != 3 || cache.Len() != 3 || stdout.Len() != 0 {
+		t.Fatalf("unexpected runs %d, cache size %d or output %q", runs, cache.Len(), stdout.String())
+	}
+	cache.Clear()
+	eval("mode b\n", false)
+
+	if _, err := d.EvalCached(ctx, cache, "true", EvalInputs{Files: []string{"/nowhere/x"}}); err == nil || !strings.Contains(err.Error(), "not on a managed mount") {
+		t.Fatalf("expected a mount error, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// ErrLimitExceeded is wrapped by the errors returned by EvalWithLimits when
+// the evaluation exceeded one of its Limits.
+var ErrLimitExceeded = errors.New("dash: eval limit exceeded")
+
+// Names of the Limits reported by LimitExceededError.
+const (
+	LimitInstructions = "instructions"
+	LimitWallTime     = "wall time"
+	LimitMemoryGrowth = "memory growth"
+)
+
+// Limits bounds the resources of a single evaluation, for untrusted
+// scripts. Zero values disable the corresponding limit.
+type Limits struct {
+	// MaxInstructions caps the work done by the guest. wazero cannot count
+	// instructions, so each guest function call counts as one; dash makes
+	// several per command, including builtins and loop iterations.
+	// Requires WithMetering.
+	MaxInstructions uint64
+	// MaxWallTime caps the duration of the evaluation.
+	MaxWallTime time.Duration
+	// MaxMemoryGrowth caps how far the guest linear memory may grow past
+	// its size when the evaluation starts.
+	MaxMemoryGrowth uint64
+}
+
+// LimitExceededError is returned by EvalWithLimits when the evaluation
+// exceeded one of its Limits.
+type LimitExceededError struct {
+	// Limit is the exceeded limit, such as LimitWallTime.
+	Limit string
+	// Max is the configured value: a count, nanoseconds or bytes.
+	Max uint64
+}
+
+// Error implements error.
+func (e *LimitExceededError) Error() string {
+	return ErrLimitExceeded.Error() + ": " + e.Limit + " limit " + strconv.FormatUint(e.Max, 10)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// WithMetering compiles the module with a function listener counting guest
+// calls, as needed by Limits.MaxInstructions. The listener slows guest
+// execution.
+func WithMetering() Option {
+	return func(o *options) {
+		o.metering = true
+	}
+}
+
+// evalMeter tracks the Limits of the running EvalWithLimits.
+type evalMeter struct {
+	limits Limits
+	calls  uint64
+	// maxMemory is the memory size the guest may grow to, or 0.
+	maxMemory uint64
+}
+
+// EvalWithLimits evaluates cmd like Eval within limits. When a limit is
+// exceeded the evaluation is aborted, the shell is rolled back to its state
+// before the call and a *LimitExceededError is returned. The shell remains
+// usable.
+//
+// Like SizeLimits.MaxMemoryBytes, limits other than MaxWallTime copy the
+// guest memory once per call.
+func (d *Dash) EvalWithLimits(ctx context.Context, cmd string, limits Limits) (int, error) {
+	if limits.MaxInstructions != 0 && !d.state.metering {
+		return -1, errors.New("dash: Limits.MaxInstructions requires WithMetering")
+	}
+	if d.state.depth != 0 {
+		return -1, errors.New("dash: EvalWithLimits cannot be called from a host command")
+	}
+	if limits.MaxWallTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limits.MaxWallTime, &LimitExceededError{Limit: LimitWallTime, Max: uint64(limits.MaxWallTime)})
+		defer cancel()
+	}
+	meter := &evalMeter{limits: limits}
+	if limits.MaxMemoryGrowth != 0 {
+		meter.maxMemory = uint64(d.mod.Memory().Size()) + limits.MaxMemoryGrowth
+	}
+	d.state.meter = meter
+	defer func() { d.state.meter = nil }()
+
+	status, err := d.Eval(ctx, cmd)
+	if lim := (*LimitExceededError)(nil); errors.As(err, &lim) {
+		return -1, lim
+	}
+	return status, err
+}
+
+// exceed records that the running evaluation exceeded a limit and aborts
+// the guest call. Eval recovers by restoring its memory snapshot.
+func (s *dashState) exceed(limit string, max uint64) {
+	s.limitHit = &LimitExceededError{Limit: limit, Max: max}
+	// Stop metering the calls made while recovering.
+	s.meter = nil
+	panic(s.limitHit)
+}
+
+// checkMemoryGrowth enforces Limits.MaxMemoryGrowth for a memory of size
+// bytes.
+func (s *dashState) checkMemoryGrowth(size uint64) {
+	if m := s.meter; m != nil && m.maxMemory != 0 && size > m.maxMemory {
+		s.exceed(LimitMemoryGrowth, m.limits.MaxMemoryGrowth)
+	}
+}
+
+// meterListenerFactory returns a function listener enforcing
+// Limits.MaxInstructions.
+func (s *dashState) meterListenerFactory() experimental.FunctionListenerFactory {
+	listener := experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+		m := s.meter
+		if m == nil || m.limits.MaxInstructions == 0 {
+			return
+		}
+		if m.calls++; m.calls > m.limits.MaxInstructions {
+			s.exceed(LimitInstructions, m.limits.MaxInstructions)
+		}
+	})
+	return experimental.FunctionListenerFactoryFunc(func(def api.FunctionDefinition) experimental.FunctionListener {
+		if def.GoFunction() != nil {
+			return nil
+		}
+		return listener
+	})
+}
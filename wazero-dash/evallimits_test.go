@@ -0,0 +1,74 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvalWithLimits(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+
+	check := func(cmd string, limits Limits, limit string) {
+		t.Helper()
+		if err := d.SetVar(ctx, "kept", "yes"); err != nil {
+			t.Fatal("SetVar:", err)
+		}
+		status, err := d.EvalWithLimits(ctx, "kept=no; "+cmd, limits)
+		var lim *LimitExceededError
+		if !errors.As(err, &lim) || lim.Limit != limit || !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("%s: got status %d, %v, want %s limit", cmd, status, err, limit)
+		}
+		if status != -1 {
+			t.Fatalf("%s: got status %d, want -1", cmd, status)
+		}
+		// The shell is rolled back and usable.
+		if got, _ := d.GetVar(ctx, "kept"); got != "yes" {
+			t.Fatalf("%s: kept=%q after rollback", cmd, got)
+		}
+		stdout.Reset()
+		if status, err := d.Eval(ctx, "echo ok"); err != nil || status != 0 || stdout.String() != "ok\n" {
+			t.Fatalf("%s: shell unusable: status %d, %v, %q", cmd, status, err, stdout.String())
+		}
+	}
+
+	check("while :; do :; done", Limits{MaxWallTime: 50 * time.Millisecond}, LimitWallTime)
+	check("x=0123456789; while :; do x=$x$x; done", Limits{MaxMemoryGrowth: 1 << 20}, LimitMemoryGrowth)
+
+	status, err := d.EvalWithLimits(ctx, "x=1; echo $x", Limits{MaxWallTime: time.Minute, MaxMemoryGrowth: 1 << 20})
+	if err != nil || status != 0 {
+		t.Fatalf("within limits: status %d, %v", status, err)
+	}
+	if _, err := d.EvalWithLimits(ctx, "true", Limits{MaxInstructions: 10}); err == nil {
+		t.Fatal("MaxInstructions without WithMetering succeeded")
+	}
+}
+
+func TestEvalWithLimitsInstructions(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithMetering())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	_, err = d.EvalWithLimits(ctx, "i=0; while :; do i=$((i+1)); done", Limits{MaxInstructions: 100000})
+	var lim *LimitExceededError
+	if !errors.As(err, &lim) || lim.Limit != LimitInstructions || lim.Max != 100000 {
+		t.Fatalf("got %v, want instructions limit", err)
+	}
+	if got, _ := d.GetVar(ctx, "i"); got != "" {
+		t.Fatalf("i=%q after rollback", got)
+	}
+	if status, err := d.EvalWithLimits(ctx, "i=1", Limits{MaxInstructions: 100000}); err != nil || status != 0 {
+		t.Fatalf("within limits: status %d, %v", status, err)
+	}
+	if got, _ := d.GetVar(ctx, "i"); got != "1" {
+		t.Fatalf("i=%q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// evalReaderChunk is how much of the script EvalReader reads at a time.
+const evalReaderChunk = 64 << 10
+
+// chunkCommand ends each chunk evaluated by EvalReader, telling chunks that
+// ran to their end from those stopped by exit, set -e or a syntax error.
+const chunkCommand = "@chunk"
+
+// EvalReader evaluates the script read from r like Eval, without holding
+// all of it in memory: the complete commands read so far are evaluated
+// before reading on, so only the command being read is buffered in Go and
+// guest memory. Each evaluation is reported to the EvalRecorder and Events
+// as a separate Eval.
+//
+// As with a script file, evaluation stops at exit, at a syntax error and
+// at a failing command under set -e. Error messages and $LINENO count lines
+// from the start of the script. A command still incomplete at the end of r
+// fails with a syntax error. Errors reading r are returned with the status
+// of the commands evaluated before.
+func (d *Dash) EvalReader(ctx context.Context, r io.Reader) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	var pending []byte
+	buf := make([]byte, evalReaderChunk)
+	status, line := 0, 1
+	for eof := false; !eof; {
+		n, err := io.ReadFull(r, buf)
+		pending = append(pending, buf[:n]...)
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			eof = true
+		case err != nil:
+			return status, err
+		}
+
+		// Cut after the last line read, keeping lines continued by a
+		// backslash with the next one.
+		end := len(pending)
+		if !eof {
+			end = bytes.LastIndexByte(pending, '\n') + 1
+			if end == 0 || bytes.HasSuffix(pending[:end], []byte("\\\n")) {
+				continue
+			}
+		}
+		if len(bytes.TrimSpace(pending[:end])) == 0 {
+			line += bytes.Count(pending[:end], []byte("\n"))
+			pending = append(pending[:0], pending[end:]...)
+			continue
+		}
+		script := d.normalizeScript(string(pending[:end]))
+		if !eof {
+			complete, err := d.parses(ctx, script)
+			if err != nil {
+				return -1, err
+			}
+			if !complete {
+				continue
+			}
+		}
+
+		var done bool
+		status, done, err = d.evalChunk(ctx, script, line)
+		if err != nil || !done {
+			return status, err
+		}
+		line += strings.Count(script, "\n")
+		pending = append(pending[:0], pending[end:]...)
+	}
+	return status, nil
+}
+
+// evalChunk evaluates the commands script, starting at line, for
+// EvalReader. done reports whether the evaluation ran to the end of
+// script.
+func (d *Dash) evalChunk(ctx context.Context, script string, line int) (status int, done bool, err error) {
+	prev := d.state.chunkDone
+	d.state.chunkDone = false
+	defer func() { d.state.chunkDone = prev }()
+	if !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	status, err = d.EvalWithSource(ctx, script+chunkCommand+" $?", Source{Line: line})
+	return status, d.state.chunkDone, err
+}
+
+// runChunkHook handles the `@chunk STATUS` command ending a chunk of
+// EvalReader. Returns STATUS, so the hook leaves $? intact.
+func runChunkHook(state *dashState, argv []string) int {
+	if len(argv) != 2 {
+		return 2
+	}
+	state.chunkDone = true
+	status, _ := strconv.Atoi(argv[1])
+	return status
+}
@@ -0,0 +1,76 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEvalReader(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+
+	// A generated script spanning several chunks, with compound commands
+	// across the chunk boundaries.
+	padding := strings.Repeat("i=$((i+1))\n", evalReaderChunk/11)
+	var script strings.Builder
+	script.WriteString("i=0\n")
+	for range 3 {
+		script.WriteString(padding)
+		script.WriteString("add() {\n  i=$((i + $1))\n}\nfor n in 1 2 \\\n  3; do\n  add $n\ndone\n")
+	}
+	script.WriteString(`echo "$i"`)
+	status, err := d.EvalReader(ctx, iotest.HalfReader(strings.NewReader(script.String())))
+	if err != nil || status != 0 {
+		t.Fatalf("EvalReader = %d, %v", status, err)
+	}
+	if want := strconv.Itoa(3*(evalReaderChunk/11+6)) + "\n"; stdout.String() != want {
+		t.Fatalf("got %q, want %q", stdout.String(), want)
+	}
+
+	for _, tc := range []struct {
+		name, script, stdout string
+		status               int
+		syntaxLine           int
+	}{
+		{"exit", "echo a\nexit\n" + padding + "echo no\n", "a\n", 0, 0},
+		{"errexit", "set -e\nfalse\n" + padding + "echo no\n", "", 1, 0},
+		{"syntax error", "echo a\n" + padding + "fi\necho no\n", "a\n", 2, evalReaderChunk/11 + 2},
+		{"incomplete", "echo a\nif true; then\n  echo b\n", "a\n", 2, 4},
+		{"last status", "true\n" + padding + "false\n\n\n", "", 1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout.Reset()
+			status, err := d.EvalReader(ctx, strings.NewReader(tc.script))
+			if tc.syntaxLine != 0 {
+				var se *SyntaxError
+				if !errors.As(err, &se) || se.Line != tc.syntaxLine {
+					t.Fatalf("expected a syntax error at line %d, got %v", tc.syntaxLine, err)
+				}
+				err = nil
+			}
+			if err != nil || status != tc.status || stdout.String() != tc.stdout {
+				t.Fatalf("got %d, %q, %v; want %d, %q", status, stdout.String(), err, tc.status, tc.stdout)
+			}
+			if _, err := d.Eval(ctx, "set +e"); err != nil {
+				t.Fatal("Eval:", err)
+			}
+		})
+	}
+
+	// Errors count lines from the start of the script.
+	if want := ": " + strconv.Itoa(evalReaderChunk/11+2) + ": Syntax error"; !strings.Contains(stderr.String(), want) {
+		t.Fatalf("expected %q in %q", want, stderr.String())
+	}
+
+	errRead := errors.New("read failed")
+	stdout.Reset()
+	r := io.MultiReader(strings.NewReader("echo a\n"+padding+padding), iotest.ErrReader(errRead))
+	if _, err := d.EvalReader(ctx, r); !errors.Is(err, errRead) || stdout.String() != "a\n" {
+		t.Fatalf("expected the read error after the first chunk, got %q, %v", stdout.String(), err)
+	}
+}
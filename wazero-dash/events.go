@@ -0,0 +1,158 @@
+package dash
+
+import (
+	"slices"
+	"time"
+)
+
+// EventKind is a kind of lifecycle event published to Events.
+type EventKind int
+
+// Lifecycle events.
+const (
+	// EventCreated is published once NewDash created an instance,
+	// including the instances of a Pool and clones.
+	EventCreated EventKind = iota
+	// EventClosed is published by Close.
+	EventClosed
+	// EventEvalStarted is published when Eval, or a method built on it
+	// such as EvalCapture, starts evaluating Script.
+	EventEvalStarted
+	// EventEvalFinished is published when the evaluation of Script
+	// returns Status and Err after Duration.
+	EventEvalFinished
+	// EventCommand is published when a command dispatched to the host,
+	// such as an ExecHandler command, a registered host command or a
+	// builtin override, returns Status after Duration. Commands the Policy
+	// denied are also reported, after EventPolicyDenied.
+	EventCommand
+	// EventVarChanged is published for each variable change Var made by
+	// the host, as with SetVar and UnsetVar, or reported by EvalDiff. Other evaluations are not inspected,
+	// as listing the variables costs two evaluations of `set`.
+	EventVarChanged
+	// EventFSWrite is published for each file mutation File the guest
+	// makes on a managed mount, as recorded by WithJournal.
+	EventFSWrite
+	// EventPolicyDenied is published when the Policy denies the command
+	// Argv.
+	EventPolicyDenied
+	// EventOutput is published for each write of the guest to the output
+	// Stream, with the written Data.
+	EventOutput
+)
+
+// String returns the kind name.
+func (k EventKind) String() string {
+	switch k {
+	case EventCreated:
+		return "created"
+	case EventClosed:
+		return "closed"
+	case EventEvalStarted:
+		return "eval-started"
+	case EventEvalFinished:
+		return "eval-finished"
+	case EventCommand:
+		return "command"
+	case EventVarChanged:
+		return "var-changed"
+	case EventFSWrite:
+		return "fs-write"
+	case EventPolicyDenied:
+		return "policy-denied"
+	case EventOutput:
+		return "output"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a lifecycle event of a Dash. Only the fields documented for
+// its Kind are set.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Dash is the instance publishing the event.
+	Dash *Dash
+
+	// Script is the script of EventEvalStarted and EventEvalFinished.
+	Script string
+	// Argv is the command of EventCommand and EventPolicyDenied.
+	Argv []string
+	// Status is the exit status of EventEvalFinished and EventCommand.
+	Status int
+	// Err is the error returned by the evaluation of EventEvalFinished.
+	Err error
+	// Duration is the time EventEvalFinished and EventCommand took.
+	Duration time.Duration
+	// Var is the change of EventVarChanged.
+	Var VarChange
+	// File is the mutation of EventFSWrite.
+	File JournalEntry
+	// Stream and Data are the output of EventOutput.
+	Stream Stream
+	Data   []byte
+}
+
+// Events observes the lifecycle of Dash instances, so integrators
+// implement one observer instead of wiring separate hooks. Event is called
+// synchronously on the goroutine performing the operation, often while the
+// guest waits in a host call: it must return quickly and must not use the
+// Dash. Observers shared by several instances, as with a Pool, must be
+// safe for concurrent use. The evaluations performed internally by methods
+// such as Environ are not published.
+type Events interface {
+	Event(e Event)
+}
+
+// EventsFunc adapts a function to the Events interface.
+type EventsFunc func(e Event)
+
+// Event calls f(e).
+func (f EventsFunc) Event(e Event) {
+	f(e)
+}
+
+// WithEvents publishes the lifecycle events of the Dash to events. It may
+// be given several times, and combines with WithTraceWriter.
+func WithEvents(events Events) Option {
+	return func(o *options) {
+		o.events = joinEvents(o.events, events)
+	}
+}
+
+// multiEvents publishes to several Events in order.
+type multiEvents []Events
+
+// Event implements Events.
+func (m multiEvents) Event(e Event) {
+	for _, events := range m {
+		events.Event(e)
+	}
+}
+
+// joinEvents returns an Events publishing to a, then b, either of which
+// may be nil.
+func joinEvents(a, b Events) Events {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	m, _ := a.(multiEvents)
+	if m == nil {
+		m = multiEvents{a}
+	}
+	return append(slices.Clip(m), b)
+}
+
+// publish stamps e and sends it to the Events, if any.
+func (s *dashState) publish(e Event) {
+	if s.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Dash = s.dash
+	s.events.Event(e)
+}
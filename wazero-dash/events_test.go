@@ -0,0 +1,136 @@
+package dash
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	var events []Event
+	observer := EventsFunc(func(e Event) {
+		if e.Time.IsZero() || e.Dash == nil {
+			t.Errorf("event %v not stamped", e.Kind)
+		}
+		events = append(events, e)
+	})
+	policy := &Policy{Commands: PolicyCommands{Deny: []string{"rm"}}}
+	d, err := NewDash(ctx, WithEvents(observer), WithPolicy(policy))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		return 3
+	})
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if len(events) == 0 || events[0].Kind != EventCreated || events[0].Dash != d {
+		t.Fatalf("expected created event first, got %+v", events)
+	}
+
+	// find returns the events of kind published since the last reset.
+	find := func(kind EventKind) []Event {
+		var found []Event
+		for _, e := range events {
+			if e.Kind == kind {
+				found = append(found, e)
+			}
+		}
+		return found
+	}
+	reset := func() { events = nil }
+
+	reset()
+	if _, err := d.Eval(ctx, "greet a b; rm x; true >/tmp/out"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	var kinds []EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	if kinds[0] != EventEvalStarted || kinds[len(kinds)-1] != EventEvalFinished {
+		t.Fatalf("expected the eval to enclose the events, got %v", kinds)
+	}
+	if start := find(EventEvalStarted); start[0].Script != "greet a b; rm x; true >/tmp/out" {
+		t.Fatalf("unexpected eval started event: %+v", start[0])
+	}
+	if finish := find(EventEvalFinished); finish[0].Err != nil || finish[0].Duration <= 0 {
+		t.Fatalf("unexpected eval finished event: %+v", finish[0])
+	}
+	commands := find(EventCommand)
+	if len(commands) != 2 || !slices.Equal(commands[0].Argv, []string{"greet", "a", "b"}) || commands[0].Status != 3 || commands[1].Status != 126 {
+		t.Fatalf("unexpected command events: %+v", commands)
+	}
+	if denied := find(EventPolicyDenied); len(denied) != 1 || !slices.Equal(denied[0].Argv, []string{"rm", "x"}) {
+		t.Fatalf("unexpected policy denied events: %+v", denied)
+	}
+	writes := find(EventFSWrite)
+	if len(writes) != 1 || writes[0].File.Op != JournalCreate || writes[0].File.Path != "/tmp/out" {
+		t.Fatalf("unexpected fs write events: %+v", writes)
+	}
+	if d.Journal() != nil {
+		t.Fatal("expected no journal without WithJournal")
+	}
+
+	reset()
+	if err := d.SetVar(ctx, "FOO", "1"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	if err := d.SetVar(ctx, "FOO", "1"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	if err := d.SetVar(ctx, "FOO", "2"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	want := []VarChange{
+		{Name: "FOO", Kind: VarCreated, New: "1"},
+		{Name: "FOO", Kind: VarModified, Old: "1", New: "2"},
+	}
+	var got []VarChange
+	for _, e := range find(EventVarChanged) {
+		got = append(got, e.Var)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("SetVar changes = %+v, want %+v", got, want)
+	}
+
+	reset()
+	if _, _, err := d.EvalDiff(ctx, "unset FOO; BAR=x"); err != nil {
+		t.Fatal("EvalDiff:", err)
+	}
+	got = nil
+	for _, e := range find(EventVarChanged) {
+		got = append(got, e.Var)
+	}
+	want = []VarChange{
+		{Name: "BAR", Kind: VarCreated, New: "x"},
+		{Name: "FOO", Kind: VarUnset, Old: "2"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("EvalDiff changes = %+v, want %+v", got, want)
+	}
+
+	reset()
+	if err := d.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+	if len(events) != 1 || events[0].Kind != EventClosed {
+		t.Fatalf("expected a closed event, got %+v", events)
+	}
+}
+
+func TestEventKindString(t *testing.T) {
+	for kind, want := range map[EventKind]string{
+		EventCreated:      "created",
+		EventEvalFinished: "eval-finished",
+		EventFSWrite:      "fs-write",
+		EventPolicyDenied: "policy-denied",
+		EventKind(99):     "unknown",
+	} {
+		if got := kind.String(); got != want {
+			t.Fatalf("%d.String() = %q, want %q", kind, got, want)
+		}
+	}
+}
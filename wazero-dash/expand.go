@@ -0,0 +1,144 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrCommandSubstitution is wrapped by the ExpandError for a word
+// containing a command substitution, which the reactor cannot run.
+var ErrCommandSubstitution = errors.New("dash: command substitution not supported")
+
+// ErrNotWord is wrapped by the ExpandError for a string that is not a
+// single shell word, such as one containing an unquoted blank or ';'.
+var ErrNotWord = errors.New("dash: not a single shell word")
+
+// ErrExpansion is wrapped by the ExpandError for a word the shell failed to
+// expand, as with ${name?} for an unset name.
+var ErrExpansion = errors.New("dash: expansion failed")
+
+// ExpandError reports a word that Expand could not expand.
+type ExpandError struct {
+	Word string
+	// Err is ErrCommandSubstitution, ErrNotWord or ErrExpansion.
+	Err error
+	// Message is the shell's error message for ErrExpansion, such as
+	// "dash: 1: name: parameter not set".
+	Message string
+}
+
+// Error implements error.
+func (e *ExpandError) Error() string {
+	msg := e.Err.Error() + ": " + Quote(e.Word)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns Err.
+func (e *ExpandError) Unwrap() error {
+	return e.Err
+}
+
+// Expand expands word as the shell expands a word in a command's arguments,
+// with dash's own expander: tilde, parameter and arithmetic expansion,
+// field splitting on IFS, pathname expansion and quote removal. It returns
+// the resulting fields, or none for a word expanding to nothing, such as
+// an unquoted unset variable.
+//
+// word must be a single shell word; quoted blanks and those inside ${...}
+// and $((...)) are part of it. Nothing is executed: the reactor cannot
+// run command substitutions, so words with $(...) or backquotes fail with
+// ErrCommandSubstitution rather than being left unexpanded. Assignments
+// in the word, as by ${name=value}, persist in the shell.
+func (d *Dash) Expand(ctx context.Context, word string) ([]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if err := checkWord(word); err != nil {
+		return nil, &ExpandError{Word: word, Err: err}
+	}
+	if word == "" {
+		return nil, nil
+	}
+	// Fields cannot contain NUL, which separates them. The final "." is
+	// missing if the expansion failed, which stops the evaluation.
+	var out []byte
+	stderr, err := d.captureStderr(func() error {
+		var err error
+		out, err = d.evalQuiet(ctx, `for __dash_wasi_word in `+word+`
+do command printf '%s\0' "$__dash_wasi_word"
+done
+unset __dash_wasi_word
+command printf .`)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	rest, ok := strings.CutSuffix(string(out), ".")
+	if !ok {
+		_, _ = d.evalQuiet(ctx, "unset __dash_wasi_word")
+		msg := strings.TrimSpace(string(stderr))
+		return nil, &ExpandError{Word: word, Err: ErrExpansion, Message: msg}
+	}
+	if rest == "" {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(rest, "\x00"), "\x00"), nil
+}
+
+// checkWord returns ErrNotWord if word is not a single complete shell
+// word, and ErrCommandSubstitution if it contains a command substitution.
+func checkWord(word string) error {
+	var dquote bool
+	// braces counts the open ${ and parens the open parentheses of
+	// arithmetic expansions, inside which any character is part of the
+	// word.
+	var braces, parens int
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case c == '\\':
+			if i++; i == len(word) || word[i] == '\n' {
+				return ErrNotWord
+			}
+		case c == '`':
+			return ErrCommandSubstitution
+		case c == '\'' && !dquote:
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end < 0 {
+				return ErrNotWord
+			}
+			i += end + 1
+		case c == '"':
+			dquote = !dquote
+		case c == '$' && strings.HasPrefix(word[i+1:], "(("):
+			parens += 2
+			i += 2
+		case c == '$' && strings.HasPrefix(word[i+1:], "("):
+			return ErrCommandSubstitution
+		case c == '$' && strings.HasPrefix(word[i+1:], "{"):
+			braces++
+			i++
+		case c == '}' && braces > 0:
+			braces--
+		case c == '(' && parens > 0:
+			parens++
+		case c == ')' && parens > 0:
+			parens--
+		case dquote || braces > 0 || parens > 0:
+		case strings.IndexByte(" \t\n;&|<>()#", c) >= 0:
+			// # only starts a comment at the start of a word.
+			if c != '#' || i == 0 {
+				return ErrNotWord
+			}
+		}
+	}
+	if dquote || braces > 0 || parens > 0 {
+		return ErrNotWord
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+	if _, err := d.Eval(ctx, `HOME=/home/me; v='a  b*'; n=3; cd /tmp && true >x1 || :; true >x2 || :`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	stderr.Reset()
+
+	for _, tc := range []struct {
+		word string
+		want []string
+	}{
+		{`plain`, []string{"plain"}},
+		{`$v`, []string{"a", "b*"}},
+		{`"$v"`, []string{"a  b*"}},
+		{`'$v'`, []string{"$v"}},
+		{`~/src`, []string{"/home/me/src"}},
+		{`${v%% *}-$((n * 2 + 1))`, []string{"a-7"}},
+		{`${unset:-x y}`, []string{"x", "y"}},
+		{`"${unset:-x y}"`, []string{"x y"}},
+		{`$unset`, nil},
+		{`""`, []string{""}},
+		{``, nil},
+		{`x*`, []string{"x1", "x2"}},
+		{`a\ b"c d"`, []string{"a bc d"}},
+		{`"line
+two"`, []string{"line\ntwo"}},
+		{`a#b`, []string{"a#b"}},
+	} {
+		got, err := d.Expand(ctx, tc.word)
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tc.word, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		word string
+		err  error
+	}{
+		{`$(rm -rf /)`, ErrCommandSubstitution},
+		{"`id`", ErrCommandSubstitution},
+		{`"${x:-$(id)}"`, ErrCommandSubstitution},
+		{`a; echo pwned`, ErrNotWord},
+		{`a b`, ErrNotWord},
+		{`a|b`, ErrNotWord},
+		{`#c`, ErrNotWord},
+		{`'open`, ErrNotWord},
+		{`"open`, ErrNotWord},
+		{`${open`, ErrNotWord},
+		{`$((1 + 2)`, ErrNotWord},
+		{`${unset?missing}`, ErrExpansion},
+	} {
+		_, err := d.Expand(ctx, tc.word)
+		var ee *ExpandError
+		if !errors.Is(err, tc.err) || !errors.As(err, &ee) || ee.Word != tc.word {
+			t.Errorf("Expand(%q) = %v, want %v", tc.word, err, tc.err)
+		}
+	}
+	_, err := d.Expand(ctx, `${unset?missing}`)
+	if ee := (*ExpandError)(nil); !errors.As(err, &ee) || !strings.Contains(ee.Message, "missing") {
+		t.Fatalf("expected the shell's message, got %v", err)
+	}
+
+	// Nothing was printed and the shell works as before.
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("Expand wrote %q, %q", stdout.String(), stderr.String())
+	}
+	if v, err := d.GetVar(ctx, "__dash_wasi_word"); err != nil || v != "" {
+		t.Fatalf("Expand left its variable set: %q, %v", v, err)
+	}
+	if status, err := d.Eval(ctx, `echo "$v"`); err != nil || status != 0 || stdout.String() != "a  b*\n" {
+		t.Fatalf("shell changed by Expand: %d, %v, %q", status, err, stdout.String())
+	}
+}
@@ -0,0 +1,122 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errMallocFault is returned by host allocations failed by a FaultInjector.
+var errMallocFault = errors.New("malloc returned null")
+
+// faultTrapPtr is the out-of-bounds command pointer passed to dash_eval to
+// make the guest trap.
+const faultTrapPtr = 0xfffffff0
+
+// FaultInjector injects failures into a Dash, so embedders can test their
+// handling of errors and their recovery logic, such as discarding a pooled
+// instance, against realistic failure modes. Its methods may be called
+// from any goroutine and take effect at the next guest call.
+type FaultInjector struct {
+	mu sync.Mutex
+	// mallocLeft counts the host allocations that succeed before the
+	// others fail, while mallocArmed is set.
+	mallocLeft  int
+	mallocArmed bool
+	trapNext    bool
+	writeDelay  time.Duration
+}
+
+// Faults returns the FaultInjector of d, which injects nothing until
+// configured. Call it from the goroutine using d, before handing the
+// injector to others.
+func (d *Dash) Faults() *FaultInjector {
+	if d.state.faults == nil {
+		d.state.faults = &FaultInjector{}
+	}
+	return d.state.faults
+}
+
+// FailMallocAfter makes the allocations the host makes in guest memory,
+// such as for the script of Eval or the value of SetVar, fail once n more
+// succeeded, as if the guest malloc returned null. A negative n stops
+// failing them.
+func (f *FaultInjector) FailMallocAfter(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mallocLeft, f.mallocArmed = n, n >= 0
+}
+
+// TrapNextEval makes the guest trap at the start of the next top-level
+// evaluation, which returns an *EvalTrapError. The trap is a real
+// out-of-bounds memory access in dash_eval.
+func (f *FaultInjector) TrapNextEval() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trapNext = true
+}
+
+// DelayWrites delays each write of the guest to stdout and stderr by
+// delay, as with a slow terminal or pipe. Zero stops delaying them.
+func (f *FaultInjector) DelayWrites(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writeDelay = delay
+}
+
+// Reset stops injecting faults.
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mallocLeft, f.mallocArmed, f.trapNext, f.writeDelay = 0, false, false, 0
+}
+
+// failMalloc reports whether the next host allocation fails. f may be nil.
+func (f *FaultInjector) failMalloc() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.mallocArmed {
+		return false
+	}
+	if f.mallocLeft > 0 {
+		f.mallocLeft--
+		return false
+	}
+	return true
+}
+
+// takeTrap consumes a TrapNextEval. f may be nil.
+func (f *FaultInjector) takeTrap() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	trap := f.trapNext
+	f.trapNext = false
+	return trap
+}
+
+// delayWrite waits for the DelayWrites delay or until ctx is done. f may
+// be nil.
+func (f *FaultInjector) delayWrite(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	delay := f.writeDelay
+	f.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
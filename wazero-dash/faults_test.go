@@ -0,0 +1,66 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	f := d.Faults()
+
+	// SetVar allocates its name and value.
+	f.FailMallocAfter(2)
+	if err := d.SetVar(ctx, "A", "1"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	if err := d.SetVar(ctx, "B", "2"); err == nil {
+		t.Fatal("expected SetVar to fail")
+	}
+	if _, err := d.Eval(ctx, "true"); err == nil {
+		t.Fatal("expected Eval to fail")
+	}
+	f.FailMallocAfter(-1)
+	if v, err := d.GetVar(ctx, "A"); err != nil || v != "1" {
+		t.Fatalf("expected A=1, got %q, %v", v, err)
+	}
+
+	f.TrapNextEval()
+	var trap *EvalTrapError
+	if _, err := d.Eval(ctx, "A=2"); !errors.As(err, &trap) {
+		t.Fatalf("expected *EvalTrapError, got %v", err)
+	}
+	if status, err := d.Eval(ctx, `[ "$A" = 1 ]`); err != nil || status != 0 {
+		t.Fatalf("expected the shell to survive the trap, got %d, %v", status, err)
+	}
+
+	f.DelayWrites(50 * time.Millisecond)
+	start := time.Now()
+	if _, _, _, err := d.EvalCapture(ctx, "echo a; echo b"); err != nil {
+		t.Fatal("EvalCapture:", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expected delayed writes, took %v", elapsed)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.Eval(tctx, "echo a"); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+
+	f.Reset()
+	stdout, _, _, err := d.EvalCapture(ctx, "echo $A")
+	if err != nil || string(stdout) != "1\n" {
+		t.Fatalf("expected 1, got %q, %v", stdout, err)
+	}
+}
@@ -0,0 +1,296 @@
+package dash
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// WASI errno values used by the descriptor table.
+const (
+	wasiErrnoBadf  = 8
+	wasiErrnoInval = 28
+)
+
+// WASI has no call duplicating a file descriptor, so the reactor imports
+// two functions of the env host module for dup2 and fcntl(F_DUPFD), see
+// reactor/src/redir.c:
+//
+//	__fd_dup(fd, minfd, errno) -> fd    fcntl(fd, F_DUPFD, minfd)
+//	__fd_dup2(fd, to, errno) -> status  dup2(fd, to)
+//
+// Both return -1 and store the error at the guest address errno on failure.
+const (
+	fdDupImport  = "__fd_dup"
+	fdDup2Import = "__fd_dup2"
+)
+
+// fdTable maps the guest's file descriptors to the host's, so the guest
+// can duplicate them, which WASI cannot. A guest
+// descriptor not in the table is the host descriptor of the same number.
+// The zero value maps every descriptor to itself.
+type fdTable struct {
+	// alias maps the guest descriptors duplicating another to the host
+	// descriptor they refer to.
+	alias map[uint32]uint32
+	// closed holds the guest descriptors closed while the host descriptor
+	// of the same number may stay open for an alias.
+	closed map[uint32]bool
+}
+
+// guestPreopenFd is the guest descriptor of the first preopened directory.
+// WASI preopens them from descriptor 3, which scripts redirect with
+// `exec 3>f`; wasi-libc keeps resolving paths with them, so they are
+// moved from 10, where dash does not redirect.
+const guestPreopenFd = 10
+
+// movePreopens returns the descriptor table of the new instance mod: its
+// preopened directories, found with fd_prestat_get, moved to the guest
+// descriptors from guestPreopenFd, and the guest descriptors they leave
+// closed.
+func (s *dashState) movePreopens(ctx context.Context, mod api.Module) fdTable {
+	var t fdTable
+	mem := mod.Memory()
+	// fd_prestat_get writes the prestat at a scratch address, the start of
+	// memory, which is restored.
+	const scratch, prestatSize = 0, 8
+	saved, _ := mem.Read(scratch, prestatSize)
+	saved = append([]byte(nil), saved...)
+	defer mem.Write(scratch, saved)
+	for h := uint32(3); ; h++ {
+		stack := []uint64{uint64(h), scratch}
+		s.fdPrestatGet.Call(ctx, mod, stack)
+		if stack[0] != wasiErrnoSuccess {
+			return t
+		}
+		t.set(guestPreopenFd+h-3, h)
+		if h < guestPreopenFd {
+			t.remove(h)
+		}
+	}
+}
+
+// host returns the host descriptor of the guest descriptor fd, or false
+// if fd is closed.
+func (t *fdTable) host(fd uint32) (uint32, bool) {
+	if h, ok := t.alias[fd]; ok {
+		return h, true
+	}
+	return fd, !t.closed[fd]
+}
+
+// set makes the guest descriptor fd refer to the host descriptor h.
+func (t *fdTable) set(fd, h uint32) {
+	delete(t.closed, fd)
+	if fd == h {
+		delete(t.alias, fd)
+		return
+	}
+	if t.alias == nil {
+		t.alias = map[uint32]uint32{}
+	}
+	t.alias[fd] = h
+}
+
+// remove closes the guest descriptor fd.
+func (t *fdTable) remove(fd uint32) {
+	delete(t.alias, fd)
+	if t.closed == nil {
+		t.closed = map[uint32]bool{}
+	}
+	t.closed[fd] = true
+}
+
+// refs returns the number of guest descriptors referring to the host
+// descriptor h.
+func (t *fdTable) refs(h uint32) int {
+	n := 0
+	if _, aliased := t.alias[h]; !aliased && !t.closed[h] {
+		n++
+	}
+	for _, to := range t.alias {
+		if to == h {
+			n++
+		}
+	}
+	return n
+}
+
+// clone returns a copy of t.
+func (t *fdTable) clone() fdTable {
+	return fdTable{alias: maps.Clone(t.alias), closed: maps.Clone(t.closed)}
+}
+
+// wasiFdParams lists the parameters holding file descriptors of the WASI
+// functions for which they are not just the first one.
+var wasiFdParams = map[string][]int{
+	"fd_renumber":  {0, 1},
+	"path_link":    {0, 4},
+	"path_rename":  {0, 3},
+	"path_symlink": {2},
+}
+
+// fdTableWASI wraps the WASI function name implemented by fn to take the
+// guest's file descriptors, translated with state.fds. fd_close and
+// path_open also update the table, and fd_prestat_get hides the
+// descriptors the preopened directories were moved from; functions
+// without descriptors are returned unchanged.
+func fdTableWASI(state *dashState, name string, fn api.GoModuleFunction) api.GoModuleFunction {
+	params, ok := wasiFdParams[name]
+	if !ok && (strings.HasPrefix(name, "fd_") || strings.HasPrefix(name, "path_") || strings.HasPrefix(name, "sock_")) {
+		params = []int{0}
+	}
+	switch {
+	case name == "fd_prestat_get":
+		return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			fd := uint32(stack[0])
+			if fd < 3 || fd >= guestPreopenFd {
+				h, ok := state.fds.host(fd)
+				if !ok {
+					stack[0] = wasiErrnoBadf
+					return
+				}
+				stack[0] = uint64(h)
+				fn.Call(ctx, mod, stack)
+				return
+			}
+			// wasi-libc looks for preopens from 3 until fd_prestat_get
+			// fails, skipping those of a type other than a directory.
+			const notDir = 1
+			mod.Memory().WriteUint64Le(uint32(stack[1]), notDir)
+			stack[0] = wasiErrnoSuccess
+		})
+	case name == "fd_close":
+		return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = uint64(state.closeFd(ctx, mod, uint32(stack[0])))
+		})
+	case name == "path_open":
+		return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			h, ok := state.fds.host(uint32(stack[0]))
+			if !ok {
+				stack[0] = wasiErrnoBadf
+				return
+			}
+			result := uint32(stack[8])
+			stack[0] = uint64(h)
+			fn.Call(ctx, mod, stack)
+			if stack[0] != wasiErrnoSuccess {
+				return
+			}
+			opened, _ := mod.Memory().ReadUint32Le(result)
+			mod.Memory().WriteUint32Le(result, state.openedFd(ctx, mod, opened))
+		})
+	case len(params) == 0:
+		return fn
+	}
+	return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		for _, i := range params {
+			h, ok := state.fds.host(uint32(stack[i]))
+			if !ok {
+				stack[0] = wasiErrnoBadf
+				return
+			}
+			stack[i] = uint64(h)
+		}
+		fn.Call(ctx, mod, stack)
+	})
+}
+
+// closeFd closes the guest descriptor fd, and its host descriptor once no
+// guest descriptor refers to it. Returns the errno.
+func (s *dashState) closeFd(ctx context.Context, mod api.Module, fd uint32) uint32 {
+	h, ok := s.fds.host(fd)
+	if !ok {
+		return wasiErrnoBadf
+	}
+	s.fds.remove(fd)
+	return s.releaseHostFd(ctx, mod, h)
+}
+
+// releaseHostFd closes the host descriptor h if no guest descriptor refers
+// to it any more. Returns the errno.
+func (s *dashState) releaseHostFd(ctx context.Context, mod api.Module, h uint32) uint32 {
+	if s.fds.refs(h) != 0 {
+		return wasiErrnoSuccess
+	}
+	stack := []uint64{uint64(h)}
+	s.fdClose.Call(ctx, mod, stack)
+	return uint32(stack[0])
+}
+
+// openedFd returns the guest descriptor for the host descriptor h just
+// opened: h itself, unless a guest descriptor of that number duplicates
+// another.
+func (s *dashState) openedFd(ctx context.Context, mod api.Module, h uint32) uint32 {
+	fd := h
+	if _, aliased := s.fds.alias[h]; aliased {
+		fd = s.freeFd(ctx, mod, 0)
+	}
+	s.fds.set(fd, h)
+	return fd
+}
+
+// freeFd returns the lowest guest descriptor from min that is not open.
+func (s *dashState) freeFd(ctx context.Context, mod api.Module, min uint32) uint32 {
+	for fd := min; ; fd++ {
+		if _, aliased := s.fds.alias[fd]; aliased {
+			continue
+		}
+		if s.fds.closed[fd] || !s.hostFdOpen(ctx, mod, fd) {
+			return fd
+		}
+	}
+}
+
+// hostFdOpen reports whether the host descriptor h is open: fd_advise
+// checks the descriptor before rejecting an invalid advice.
+func (s *dashState) hostFdOpen(ctx context.Context, mod api.Module, h uint32) bool {
+	const invalidAdvice = 0xff
+	stack := []uint64{uint64(h), 0, 0, invalidAdvice}
+	s.fdAdvise.Call(ctx, mod, stack)
+	return stack[0] != wasiErrnoBadf
+}
+
+// fdDupHost implements __fd_dup.
+func fdDupHost(ctx context.Context, mod api.Module, fd uint32, minfd int32, errno uint32) int32 {
+	const fn = fdDupImport
+	state := hostState(ctx, fn)
+	h, ok := state.fds.host(fd)
+	if !ok {
+		return guestErrno(mod, errno, wasiErrnoBadf)
+	}
+	if minfd < 0 {
+		return guestErrno(mod, errno, wasiErrnoInval)
+	}
+	dup := state.freeFd(ctx, mod, uint32(minfd))
+	state.fds.set(dup, h)
+	return int32(dup)
+}
+
+// fdDup2Host implements __fd_dup2. The host descriptor to referred to is
+// closed once no other guest descriptor refers to it.
+func fdDup2Host(ctx context.Context, mod api.Module, fd uint32, to int32, errno uint32) int32 {
+	const fn = fdDup2Import
+	state := hostState(ctx, fn)
+	h, ok := state.fds.host(fd)
+	if !ok || to < 0 {
+		return guestErrno(mod, errno, wasiErrnoBadf)
+	}
+	if fd == uint32(to) {
+		return 0
+	}
+	old, open := state.fds.host(uint32(to))
+	state.fds.set(uint32(to), h)
+	if open {
+		state.releaseHostFd(ctx, mod, old)
+	}
+	return 0
+}
+
+// guestErrno stores code at the guest address errno and returns -1.
+func guestErrno(mod api.Module, errno, code uint32) int32 {
+	mod.Memory().WriteUint32Le(errno, code)
+	return -1
+}
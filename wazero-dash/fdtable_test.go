@@ -0,0 +1,118 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func newRedirectDash(t *testing.T, opts ...Option) (*Dash, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, append(opts, WithStdio(nil, &stdout, &stderr), WithMemRoot(1<<20))...)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	return d, &stdout, &stderr
+}
+
+func TestRedirections(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newRedirectDash(t)
+	d.state.registerCommand("greet", func(ctx context.Context, d *Dash, argv []string) int {
+		if err := guestWrite(ctx, d.mod, d.state, fdStdout, []byte("hello\n")); err != nil {
+			return 1
+		}
+		return 0
+	})
+	script := `echo x >/tmp/f
+echo y >>/tmp/f
+{ echo out; echo err >&2; } >/tmp/both 2>&1
+exec 3>/tmp/three; echo three >&3; exec 3>&-
+{ echo a; echo b >/tmp/inner; echo c; } >/tmp/outer
+f() { echo in function; }; f >/tmp/func
+greet >/tmp/greet
+read v </tmp/f; echo read $v
+echo closed >&- 2>/dev/null || echo closed failed
+echo done`
+	if status, err := d.Eval(ctx, script); err != nil || status != 0 {
+		t.Fatalf("Eval: %d, %v (stderr %q)", status, err, stderr.String())
+	}
+	if got, want := stdout.String(), "read x\nclosed failed\ndone\n"; got != want {
+		t.Fatalf("stdout %q, want %q (stderr %q)", got, want, stderr.String())
+	}
+	for path, want := range map[string]string{
+		"/tmp/f":     "x\ny\n",
+		"/tmp/both":  "out\nerr\n",
+		"/tmp/three": "three\n",
+		"/tmp/inner": "b\n",
+		"/tmp/outer": "a\nc\n",
+		"/tmp/func":  "in function\n",
+		"/tmp/greet": "hello\n",
+	} {
+		if data, err := d.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s: %q, %v, want %q", path, data, err, want)
+		}
+	}
+
+	// Captures still get the shell's stdout.
+	out, _, _, err := d.EvalCapture(ctx, "echo captured")
+	if err != nil || string(out) != "captured\n" {
+		t.Fatalf("EvalCapture: %q, %v", out, err)
+	}
+}
+
+// TestRedirectionInterrupted checks that an evaluation interrupted while its
+// stdout is redirected gives it back to the shell.
+func TestRedirectionInterrupted(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newRedirectDash(t)
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := d.Eval(tctx, `{ echo start; while :; do :; done; } >/tmp/loop`); err == nil {
+		t.Fatal("expected the evaluation to be interrupted")
+	}
+	if _, err := d.Eval(ctx, "echo after"); err != nil || stdout.String() != "after\n" {
+		t.Fatalf("Eval: %q, %v", stdout.String(), err)
+	}
+	if err := d.Reset(ctx); err != nil {
+		t.Fatal("Reset:", err)
+	}
+	if _, err := d.Eval(ctx, "exec >/tmp/rest"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if err := d.Reset(ctx); err != nil {
+		t.Fatal("Reset:", err)
+	}
+	stdout.Reset()
+	if _, err := d.Eval(ctx, "echo reset"); err != nil || stdout.String() != "reset\n" {
+		t.Fatalf("Eval after Reset: %q, %v", stdout.String(), err)
+	}
+}
+
+// TestRedirectionsOverPreopens checks that replacing or closing the guest
+// descriptors of the preopened directories leaves paths resolving.
+func TestRedirectionsOverPreopens(t *testing.T) {
+	ctx := context.Background()
+	for _, fd := range []string{"3", "4", "5"} {
+		for _, script := range []string{
+			"exec " + fd + ">/tmp/g; echo g >&" + fd + "; echo a >/tmp/h",
+			"exec " + fd + ">/tmp/g; exec " + fd + ">&-; echo a >/tmp/h",
+			"exec " + fd + ">&-; echo a >/tmp/h",
+		} {
+			d, _, stderr := newRedirectDash(t)
+			if status, err := d.Eval(ctx, script); err != nil || status != 0 {
+				t.Fatalf("%q: %d, %v (stderr %q)", script, status, err, stderr.String())
+			}
+			if data, err := d.ReadFile("/tmp/h"); err != nil || string(data) != "a\n" {
+				t.Errorf("%q: /tmp/h: %q, %v", script, data, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package dash
+
+import "io/fs"
+
+// fsMount is a Go file system requested with WithFS.
+type fsMount struct {
+	fsys  fs.FS
+	guest string
+}
+
+// WithFS mounts fsys read-only at the absolute guest path mountPoint, for
+// example an embed.FS or fstest.MapFS. It may be given several times, and
+// unlike mounts made through WithFSConfig the mounts are visible to host
+// code such as EvalCached inputs.
+func WithFS(fsys fs.FS, mountPoint string) Option {
+	return func(o *options) {
+		o.fsMounts = append(o.fsMounts, fsMount{fsys: fsys, guest: mountPoint})
+	}
+}
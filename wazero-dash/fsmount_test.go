@@ -0,0 +1,33 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithFS(t *testing.T) {
+	ctx := context.Background()
+	assets := fstest.MapFS{
+		"a.conf":     {Data: []byte("alpha\n")},
+		"b.conf":     {Data: []byte("beta\n")},
+		"sub/c.conf": {Data: []byte("gamma\n")},
+	}
+	docs := fstest.MapFS{"readme": {Data: []byte("docs\n")}}
+	res, err := Run(ctx, `for f in /etc/app/*.conf /etc/app/*/*.conf; do test -s "$f" && echo "${f#/etc/app/}"; done
+test -r /usr/share/doc/readme && echo readable
+true >/etc/app/new || echo read-only`,
+		WithFS(assets, "/etc/app"),
+		WithFS(docs, "/usr/share/doc"))
+	if err != nil {
+		t.Fatal("Run:", err)
+	}
+	if want := "a.conf\nb.conf\nsub/c.conf\nreadable\nread-only\n"; string(res.Stdout) != want {
+		t.Fatalf("expected %q, got %q (stderr %q)", want, res.Stdout, res.Stderr)
+	}
+
+	if _, err := NewDash(ctx, WithFS(assets, "relative")); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Fatalf("expected a mount point error, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package dash
+
+import (
+	"cmp"
+	"io/fs"
+	"slices"
+	"sync/atomic"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// MountStats counts the file system calls the guest made on a mount.
+type MountStats struct {
+	// Guest is the mount point.
+	Guest string
+	// Opens counts the files and directories opened, OpenErrors the
+	// attempts that failed, such as opens of missing files.
+	Opens      int64
+	OpenErrors int64
+	// Reads and Writes count the read and write calls on open files.
+	Reads  int64
+	Writes int64
+	// BytesRead and BytesWritten are the bytes the calls transferred.
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// mountCounters holds the MountStats of a mount, updated by the guest and
+// read by FSStats from any goroutine.
+type mountCounters struct {
+	guest                   string
+	opens, openErrors       atomic.Int64
+	reads, writes           atomic.Int64
+	bytesRead, bytesWritten atomic.Int64
+}
+
+// FSStats returns the counters of each mount managed by Dash since the
+// shell was created, sorted by mount point. Only calls made by the guest
+// are counted, not host accesses such as ReadFile; mounts passed with
+// WithFSConfig are not tracked. It may be called from any goroutine, for
+// example to monitor a running evaluation.
+func (d *Dash) FSStats() []MountStats {
+	stats := make([]MountStats, len(d.state.fsStats))
+	for i, c := range d.state.fsStats {
+		stats[i] = MountStats{
+			Guest:        c.guest,
+			Opens:        c.opens.Load(),
+			OpenErrors:   c.openErrors.Load(),
+			Reads:        c.reads.Load(),
+			Writes:       c.writes.Load(),
+			BytesRead:    c.bytesRead.Load(),
+			BytesWritten: c.bytesWritten.Load(),
+		}
+	}
+	slices.SortStableFunc(stats, func(a, b MountStats) int { return cmp.Compare(a.Guest, b.Guest) })
+	return stats
+}
+
+// statsFS counts the guest calls on a mount.
+type statsFS struct {
+	experimentalsys.FS
+	counters *mountCounters
+}
+
+// OpenFile implements experimentalsys.FS.
+func (f *statsFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	file, errno := f.FS.OpenFile(p, flag, perm)
+	if errno != 0 {
+		f.counters.openErrors.Add(1)
+		return nil, errno
+	}
+	f.counters.opens.Add(1)
+	return &statsFile{File: file, counters: f.counters}, 0
+}
+
+// statsFile counts the reads and writes of an open file.
+type statsFile struct {
+	experimentalsys.File
+	counters *mountCounters
+}
+
+// Read implements experimentalsys.File.
+func (f *statsFile) Read(buf []byte) (int, experimentalsys.Errno) {
+	n, errno := f.File.Read(buf)
+	f.countRead(n)
+	return n, errno
+}
+
+// Pread implements experimentalsys.File.
+func (f *statsFile) Pread(buf []byte, off int64) (int, experimentalsys.Errno) {
+	n, errno := f.File.Pread(buf, off)
+	f.countRead(n)
+	return n, errno
+}
+
+// Write implements experimentalsys.File.
+func (f *statsFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	n, errno := f.File.Write(buf)
+	f.countWrite(n)
+	return n, errno
+}
+
+// Pwrite implements experimentalsys.File.
+func (f *statsFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	n, errno := f.File.Pwrite(buf, off)
+	f.countWrite(n)
+	return n, errno
+}
+
+// countRead counts a read call transferring n bytes.
+func (f *statsFile) countRead(n int) {
+	f.counters.reads.Add(1)
+	f.counters.bytesRead.Add(int64(n))
+}
+
+// countWrite counts a write call transferring n bytes.
+func (f *statsFile) countWrite(n int) {
+	f.counters.writes.Add(1)
+	f.counters.bytesWritten.Add(int64(n))
+}
+
+// _ is a type assertion
+var (
+	_ experimentalsys.FS   = (*statsFS)(nil)
+	_ experimentalsys.File = (*statsFile)(nil)
+)
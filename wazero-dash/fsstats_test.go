@@ -0,0 +1,104 @@
+package dash
+
+import (
+	"context"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func TestFSStats(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithDirMount(t.TempDir(), "/work"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	stats := func() map[string]MountStats {
+		t.Helper()
+		all := d.FSStats()
+		m := make(map[string]MountStats, len(all))
+		for i, s := range all {
+			if i > 0 && all[i-1].Guest > s.Guest {
+				t.Fatalf("FSStats not sorted: %+v", all)
+			}
+			m[s.Guest] = s
+		}
+		return m
+	}
+	eval := func(script string) {
+		t.Helper()
+		if _, _, _, err := d.EvalCapture(ctx, script); err != nil {
+			t.Fatal("EvalCapture:", err)
+		}
+	}
+
+	// The first path lookup of the guest opens the root of every mount.
+	eval("test -e /tmp/a")
+	before := stats()
+	if _, ok := before[TempDir]; !ok {
+		t.Fatalf("expected stats for %s, got %+v", TempDir, before)
+	}
+	eval("true >/tmp/a")
+	eval("true </tmp/missing")
+	after := stats()
+	tmp := after[TempDir]
+	if tmp.Opens <= before[TempDir].Opens || tmp.OpenErrors != before[TempDir].OpenErrors+1 {
+		t.Fatalf("expected opens and one failed open, got %+v then %+v", before[TempDir], tmp)
+	}
+	if after["/work"] != before["/work"] {
+		t.Fatalf("untouched mount changed: %+v then %+v", before["/work"], after["/work"])
+	}
+
+	// Host accesses are not counted.
+	if _, err := d.ReadFile("/tmp/a"); err != nil {
+		t.Fatal("ReadFile:", err)
+	}
+	if got := stats()[TempDir]; got != tmp {
+		t.Fatalf("ReadFile was counted: %+v then %+v", tmp, got)
+	}
+
+	eval("cd /work")
+	if got := stats()["/work"]; got.Opens == 0 {
+		t.Fatalf("expected cd to open /work, got %+v", got)
+	}
+}
+
+func TestStatsFS(t *testing.T) {
+	counters := &mountCounters{guest: "/data"}
+	fsys := &statsFS{FS: newMemFS(0), counters: counters}
+
+	f, errno := fsys.OpenFile("f", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o644)
+	if errno != 0 {
+		t.Fatal("OpenFile:", errno)
+	}
+	if _, errno := f.Write([]byte("hello")); errno != 0 {
+		t.Fatal("Write:", errno)
+	}
+	if _, errno := f.Pwrite([]byte("!"), 5); errno != 0 {
+		t.Fatal("Pwrite:", errno)
+	}
+	buf := make([]byte, 8)
+	if n, errno := f.Pread(buf, 0); errno != 0 || string(buf[:n]) != "hello!" {
+		t.Fatalf("Pread: %q, %v", buf[:n], errno)
+	}
+	if _, errno := f.Read(buf); errno != 0 {
+		t.Fatal("Read:", errno)
+	}
+	if errno := f.Close(); errno != 0 {
+		t.Fatal("Close:", errno)
+	}
+	if _, errno := fsys.OpenFile("missing", experimentalsys.O_RDONLY, 0); errno != experimentalsys.ENOENT {
+		t.Fatal("expected ENOENT, got", errno)
+	}
+
+	d := &Dash{state: &dashState{fsStats: []*mountCounters{counters}}}
+	want := MountStats{Guest: "/data", Opens: 1, OpenErrors: 1, Reads: 2, Writes: 2, BytesRead: 7, BytesWritten: 6}
+	if got := d.FSStats(); len(got) != 1 || got[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,168 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/dashast"
+)
+
+// ErrFunctionNotFound is returned by CallFunction for a name that is not a
+// shell function.
+var ErrFunctionNotFound = errors.New("dash: function not found")
+
+// DefineFunction defines the shell function name with the shell code body,
+// as `name() { body }`, replacing any function of that name. Syntax errors
+// in body are returned without evaluating it.
+func (d *Dash) DefineFunction(ctx context.Context, name, body string) error {
+	if err := d.ready(); err != nil {
+		return err
+	}
+	if !isShellName(name) {
+		return errors.New("dash: invalid function name: " + strconv.Quote(name))
+	}
+	def := name + "() {\n" + body + "\n}"
+	var status int
+	msg, err := d.captureStderr(func() error {
+		var err error
+		status, _, err = d.evalQuietStatus(ctx, "eval "+Quote(def))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return errors.New("dash: defining " + name + ": " + strings.TrimSpace(string(msg)))
+	}
+	if d.dashListFuncs == nil && !slices.Contains(d.state.functions, name) {
+		d.state.functions = append(d.state.functions, name)
+	}
+	return nil
+}
+
+// CallFunction calls the shell function name with args, each passed as one
+// positional parameter without quoting hazards, and returns its exit
+// status like Eval. Names that are not functions, such as builtins, fail
+// with ErrFunctionNotFound.
+func (d *Dash) CallFunction(ctx context.Context, name string, args ...string) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	funcs, err := d.definedFunctions(ctx, []string{name})
+	if err != nil {
+		return -1, err
+	}
+	if len(funcs) == 0 {
+		return -1, fmt.Errorf("%w: %s", ErrFunctionNotFound, name)
+	}
+	cmd := name
+	for _, arg := range args {
+		cmd += " " + Quote(arg)
+	}
+	return d.Eval(ctx, cmd)
+}
+
+// ListFunctions returns the names of the defined shell functions, sorted.
+//
+// It reads the shell's command table with the dash_listfuncs export if the
+// module has it. dash has no builtin listing its functions, so without the
+// export the host records the functions defined with DefineFunction and in
+// the scripts it evaluates with Eval and WithInitScript, and returns those
+// still defined; functions defined by eval, by files sourced with `.` or
+// by EvalArgs are missing.
+func (d *Dash) ListFunctions(ctx context.Context) ([]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.dashListFuncs != nil {
+		return d.listFuncs(ctx)
+	}
+	slices.Sort(d.state.functions)
+	d.state.functions = slices.Compact(d.state.functions)
+	funcs, err := d.definedFunctions(ctx, d.state.functions)
+	if err != nil {
+		return nil, err
+	}
+	// Forget the functions unset since.
+	d.state.functions = funcs
+	return slices.Clone(funcs), nil
+}
+
+// listFuncs calls dash_listfuncs and parses the returned list.
+func (d *Dash) listFuncs(ctx context.Context) ([]string, error) {
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	results, err := d.call(ctx, d.dashListFuncs)
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(results[0])
+	if ptr == 0 {
+		return nil, errors.New("dash_listfuncs failed")
+	}
+	defer d.freePtr(ctx, ptr)
+
+	var funcs []string
+	for {
+		name := d.readCString(ptr)
+		if name == "" {
+			break
+		}
+		funcs = append(funcs, name)
+		ptr += uint32(len(name)) + 1
+	}
+	slices.Sort(funcs)
+	return funcs, nil
+}
+
+// trackFunctions records the names of the functions script defines, for
+// ListFunctions. Scripts the Go parser rejects are skipped.
+func (s *dashState) trackFunctions(script string) {
+	if !strings.Contains(script, "(") {
+		return
+	}
+	f, err := parseScript(script)
+	if err != nil {
+		return
+	}
+	dashast.Walk(f, func(n dashast.Node) bool {
+		if def, ok := n.(*dashast.FuncDef); ok && !slices.Contains(s.functions, def.Name) {
+			s.functions = append(s.functions, def.Name)
+		}
+		return true
+	})
+}
+
+// definedFunctions returns the names that are shell functions, in order.
+func (d *Dash) definedFunctions(ctx context.Context, names []string) ([]string, error) {
+	var script strings.Builder
+	for _, name := range names {
+		if isShellName(name) {
+			script.WriteString("command -V " + name + "\n")
+		}
+	}
+	if script.Len() == 0 {
+		return nil, nil
+	}
+	var out []byte
+	_, err := d.captureStderr(func() error {
+		var err error
+		out, err = d.evalQuiet(ctx, script.String())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var funcs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, ok := strings.CutSuffix(line, " is a shell function"); ok {
+			funcs = append(funcs, name)
+		}
+	}
+	return funcs, nil
+}
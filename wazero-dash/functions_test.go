@@ -0,0 +1,100 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	if err := d.DefineFunction(ctx, "greet", `printf '%s|' "$#" "$@"; return 3`); err != nil {
+		t.Fatal("DefineFunction:", err)
+	}
+	if err := d.DefineFunction(ctx, "other", "true"); err != nil {
+		t.Fatal("DefineFunction:", err)
+	}
+	var status int
+	stdout, err := d.captureStdout(func() error {
+		var err error
+		status, err = d.CallFunction(ctx, "greet", "a b", "it's", "$HOME", "; exit", "")
+		return err
+	})
+	if err != nil {
+		t.Fatal("CallFunction:", err)
+	}
+	if want := "5|a b|it's|$HOME|; exit||"; string(stdout) != want || status != 3 {
+		t.Fatalf("expected %q and status 3, got %q and %d", want, stdout, status)
+	}
+
+	for _, name := range []string{"echo", "missing"} {
+		if _, err := d.CallFunction(ctx, name); !errors.Is(err, ErrFunctionNotFound) {
+			t.Fatalf("CallFunction(%q): expected ErrFunctionNotFound, got %v", name, err)
+		}
+	}
+	if err := d.DefineFunction(ctx, "bad", "if"); err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+	// Functions shadowing regular builtins do not break it.
+	if err := d.DefineFunction(ctx, "echo", ":"); err != nil {
+		t.Fatal("DefineFunction:", err)
+	}
+	if err := d.DefineFunction(ctx, "bad", "fi"); err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Fatalf("expected a syntax error with echo redefined, got %v", err)
+	}
+	if _, err := d.Eval(ctx, "unset -f echo"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if err := d.DefineFunction(ctx, "a-b", "true"); err == nil {
+		t.Fatal("expected an invalid name error")
+	}
+
+	if _, err := d.Eval(ctx, "unset -f other"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	funcs, err := d.ListFunctions(ctx)
+	if err != nil {
+		t.Fatal("ListFunctions:", err)
+	}
+	if !slices.Equal(funcs, []string{"greet"}) {
+		t.Fatalf("expected [greet], got %q", funcs)
+	}
+
+	// Functions defined by evaluated scripts are listed too.
+	if _, err := d.Eval(ctx, "top() { :; }\nif true; then nested() { top; }; fi\ngone() { :; }; unset -f gone"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	funcs, err = d.ListFunctions(ctx)
+	if err != nil {
+		t.Fatal("ListFunctions:", err)
+	}
+	if want := []string{"greet", "nested", "top"}; !slices.Equal(funcs, want) {
+		t.Fatalf("expected %q, got %q", want, funcs)
+	}
+}
+
+func TestListFunctionsInitScript(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithInitScript("setup() { :; }"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if funcs, err := d.ListFunctions(ctx); err != nil || !slices.Equal(funcs, []string{"setup"}) {
+		t.Fatalf("ListFunctions: %q, %v", funcs, err)
+	}
+}
@@ -0,0 +1,55 @@
+package dash
+
+import (
+	"context"
+	"strings"
+)
+
+// Glob returns the guest paths matching the shell pattern, sorted as by
+// pathname expansion, or nil if none match.
+//
+// Only the pattern characters *, ? and [...] are special, and a backslash
+// quotes the next character: the pattern is not subject to parameter
+// expansion or field splitting.
+func (d *Dash) Glob(ctx context.Context, pattern string) ([]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	// command skips overrides of printf and test.
+	out, err := d.evalQuiet(ctx, `for __dash_wasi_glob in `+globWord(pattern)+`; do
+command test -e "$__dash_wasi_glob" || command test -h "$__dash_wasi_glob" || continue
+command printf '%s\n' "$__dash_wasi_glob"
+done
+unset __dash_wasi_glob`)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), nil
+}
+
+// globWord quotes pattern as an unquoted shell word, keeping *, ? and
+// brackets special.
+func globWord(pattern string) string {
+	var b strings.Builder
+	quoted := false
+	for _, c := range pattern {
+		switch {
+		case c == '\\' && !quoted:
+			quoted = true
+			continue
+		case c == '\n':
+			// A backslash-newline is a line continuation.
+			b.WriteString("'\n'")
+		case strings.ContainsRune("*?[]!", c) && !quoted:
+			b.WriteRune(c)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		}
+		quoted = false
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package dash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestGlob(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c d.txt", "*.txt", "x.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	d, err := NewDash(ctx, WithRuntime(r), WithDirMount(dir, "/work"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/work/*.txt", []string{"/work/*.txt", "/work/a.txt", "/work/b.txt", "/work/c d.txt"}},
+		{`/work/\*.txt`, []string{"/work/*.txt"}},
+		{"/work/[ab].txt", []string{"/work/a.txt", "/work/b.txt"}},
+		{"/work/c d*", []string{"/work/c d.txt"}},
+		{"/work/$HOME*", nil},
+		{"/work/none*", nil},
+	}
+	for _, tt := range tests {
+		got, err := d.Glob(ctx, tt.pattern)
+		if err != nil {
+			t.Fatalf("Glob(%q): %v", tt.pattern, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Glob(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestVarNamesAliases(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	ctx := context.Background()
+
+	if _, err := d.Eval(ctx, "MY_VAR=1; alias ll='ls -l' q=\"it's\""); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	names, err := d.VarNames(ctx)
+	if err != nil {
+		t.Fatal("VarNames:", err)
+	}
+	if !slices.Contains(names, "MY_VAR") || !slices.IsSorted(names) {
+		t.Fatalf("expected sorted names with MY_VAR, got %q", names)
+	}
+	aliases, err := d.Aliases(ctx)
+	if err != nil {
+		t.Fatal("Aliases:", err)
+	}
+	if aliases["ll"] != "ls -l" || aliases["q"] != "it's" || len(aliases) != 2 {
+		t.Fatalf("unexpected aliases %q", aliases)
+	}
+}
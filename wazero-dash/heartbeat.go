@@ -0,0 +1,137 @@
+package dash
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Heartbeat reports the progress of a running Eval.
+type Heartbeat struct {
+	// Elapsed is the time since the Eval started.
+	Elapsed time.Duration
+	// Calls is the number of guest function calls since the Eval started,
+	// or zero if HeartbeatConfig.Calls is unset.
+	Calls uint64
+	// Command is the argv of the host-dispatched command currently
+	// running, or nil. Builtins and shell functions are not reported.
+	Command []string
+}
+
+// HeartbeatConfig configures Eval heartbeats. See WithHeartbeat.
+type HeartbeatConfig struct {
+	// Interval sends a heartbeat every Interval of wall time. These are
+	// sent from a separate goroutine, so they keep arriving while the guest
+	// is blocked in a host function.
+	Interval time.Duration
+
+	// Calls sends a heartbeat every Calls guest function calls, from the
+	// goroutine running Eval. Enabling this installs a function listener on
+	// the module, which slows guest execution.
+	Calls uint64
+
+	// Func receives heartbeats. Calls to Func are serialized.
+	Func func(Heartbeat)
+}
+
+// WithHeartbeat sends periodic heartbeats while an Eval runs, so callers can
+// show liveness for long scripts or detect hangs.
+func WithHeartbeat(config HeartbeatConfig) Option {
+	return func(o *options) {
+		o.heartbeat = &config
+	}
+}
+
+// heartbeat tracks the progress of the running Eval.
+type heartbeat struct {
+	config HeartbeatConfig
+
+	// mu serializes config.Func and guards start.
+	mu    sync.Mutex
+	start time.Time
+
+	active  atomic.Bool
+	calls   atomic.Uint64
+	command atomic.Pointer[[]string]
+}
+
+// begin starts reporting heartbeats for an Eval. The returned func stops.
+func (h *heartbeat) begin() func() {
+	h.mu.Lock()
+	h.start = time.Now()
+	h.mu.Unlock()
+	h.calls.Store(0)
+	h.active.Store(true)
+
+	if h.config.Interval <= 0 {
+		return func() { h.active.Store(false) }
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(h.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				h.send()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		h.active.Store(false)
+	}
+}
+
+// send delivers a heartbeat.
+func (h *heartbeat) send() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	beat := Heartbeat{Elapsed: time.Since(h.start), Calls: h.calls.Load()}
+	if cmd := h.command.Load(); cmd != nil {
+		beat.Command = slices.Clone(*cmd)
+	}
+	h.config.Func(beat)
+}
+
+// setCommand records the host-dispatched command being run, or nil.
+func (h *heartbeat) setCommand(argv []string) {
+	if argv == nil {
+		h.command.Store(nil)
+		return
+	}
+	h.command.Store(&argv)
+}
+
+// listenerFactory returns a function listener counting guest calls, or nil
+// if call-based heartbeats are disabled.
+func (h *heartbeat) listenerFactory() experimental.FunctionListenerFactory {
+	if h.config.Calls == 0 {
+		return nil
+	}
+	listener := experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+		if !h.active.Load() {
+			return
+		}
+		if n := h.calls.Add(1); n%h.config.Calls == 0 {
+			h.send()
+		}
+	})
+	return experimental.FunctionListenerFactoryFunc(func(def api.FunctionDefinition) experimental.FunctionListener {
+		if def.GoFunction() != nil {
+			return nil
+		}
+		return listener
+	})
+}
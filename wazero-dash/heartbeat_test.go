@@ -0,0 +1,68 @@
+package dash
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	var mu sync.Mutex
+	var beats []Heartbeat
+	d, err := NewDash(ctx, WithRuntime(r), WithHeartbeat(HeartbeatConfig{
+		Interval: 5 * time.Millisecond,
+		Calls:    1000,
+		Func: func(h Heartbeat) {
+			mu.Lock()
+			beats = append(beats, h)
+			mu.Unlock()
+		},
+	}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		time.Sleep(50 * time.Millisecond)
+		return 0
+	})
+
+	if _, err := d.Eval(ctx, "i=0; while [ $i -lt 200 ]; do i=$((i+1)); done; slow 1"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	mu.Lock()
+	var byCalls, inCommand bool
+	for _, h := range beats {
+		if h.Calls != 0 && h.Calls%1000 == 0 {
+			byCalls = true
+		}
+		if slices.Equal(h.Command, []string{"slow", "1"}) {
+			inCommand = true
+		}
+	}
+	n := len(beats)
+	mu.Unlock()
+	if !byCalls || !inCommand {
+		t.Fatalf("expected call and interval heartbeats during the command, got %+v", beats)
+	}
+
+	// No heartbeats between evaluations.
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(beats) != n {
+		t.Fatal("heartbeat sent outside Eval")
+	}
+}
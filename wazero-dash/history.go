@@ -0,0 +1,304 @@
+package dash
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is a command line recorded in a History.
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	// Status is the exit status of the command.
+	Status int `json:"status"`
+}
+
+// History stores the command lines of interactive sessions. Embedders
+// implement it to centralize or encrypt history; MemHistory, FileHistory
+// and SQLHistory are provided. Implementations must be safe for concurrent
+// use.
+type History interface {
+	// Append records e.
+	Append(ctx context.Context, e HistoryEntry) error
+	// List returns the last n entries, oldest first, or all of them if n
+	// is 0.
+	List(ctx context.Context, n int) ([]HistoryEntry, error)
+	// Search returns the entries whose command contains query, newest
+	// first, at most n of them unless n is 0.
+	Search(ctx context.Context, query string, n int) ([]HistoryEntry, error)
+}
+
+// WithHistory adds the history command, which lists the command lines in
+// h:
+//
+//	history [N]        print the last N commands, or all, oldest first
+//	history -s TEXT [N] print the commands containing TEXT, newest first
+//
+// The host records the lines it evaluates with h.Append, as repl.Run does
+// given the History.
+func WithHistory(h History) Option {
+	return func(o *options) {
+		o.history = h
+	}
+}
+
+// historyCommand implements history.
+func historyCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "history"
+	args := argv[1:]
+	query, search := "", len(args) != 0 && args[0] == "-s"
+	if search {
+		if len(args) < 2 {
+			commandError(ctx, d.mod, d.state, name, "usage: history [-s text] [n]")
+			return 2
+		}
+		query, args = args[1], args[2:]
+	}
+	n := 0
+	switch {
+	case len(args) > 1:
+		commandError(ctx, d.mod, d.state, name, "usage: history [-s text] [n]")
+		return 2
+	case len(args) == 1:
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			commandError(ctx, d.mod, d.state, name, "Illegal number: "+args[0])
+			return 2
+		}
+	}
+
+	var entries []HistoryEntry
+	var err error
+	if search {
+		entries, err = d.state.history.Search(ctx, query, n)
+	} else {
+		entries, err = d.state.history.List(ctx, n)
+	}
+	if err != nil {
+		commandError(ctx, d.mod, d.state, name, err.Error())
+		return 1
+	}
+	var out strings.Builder
+	for _, e := range entries {
+		out.WriteString(e.Command + "\n")
+	}
+	if err := guestWrite(ctx, d.mod, d.state, fdStdout, []byte(out.String())); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// MemHistory is a History held in memory.
+type MemHistory struct {
+	mu      sync.Mutex
+	max     int
+	entries []HistoryEntry
+}
+
+// NewMemHistory returns an empty MemHistory keeping the last max entries,
+// or all of them if max is 0.
+func NewMemHistory(max int) *MemHistory {
+	return &MemHistory{max: max}
+}
+
+// Append implements History.
+func (h *MemHistory) Append(ctx context.Context, e HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+	if h.max > 0 && len(h.entries) > h.max {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-h.max)
+	}
+	return nil
+}
+
+// List implements History.
+func (h *MemHistory) List(ctx context.Context, n int) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return lastEntries(h.entries, n), nil
+}
+
+// Search implements History.
+func (h *MemHistory) Search(ctx context.Context, query string, n int) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return searchEntries(h.entries, query, n), nil
+}
+
+// FileHistory is a History stored in a file, one JSON object per line, so
+// that sessions append to it without rewriting it.
+type FileHistory struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// OpenFileHistory opens the history file at path for appending, creating
+// it if needed.
+func OpenFileHistory(path string) (*FileHistory, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileHistory{path: path, f: f}, nil
+}
+
+// Append implements History. Each entry is written with a single write.
+func (h *FileHistory) Append(ctx context.Context, e HistoryEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.f.Write(append(line, '\n'))
+	return err
+}
+
+// List implements History.
+func (h *FileHistory) List(ctx context.Context, n int) ([]HistoryEntry, error) {
+	entries, err := h.read()
+	if err != nil {
+		return nil, err
+	}
+	return lastEntries(entries, n), nil
+}
+
+// Search implements History.
+func (h *FileHistory) Search(ctx context.Context, query string, n int) ([]HistoryEntry, error) {
+	entries, err := h.read()
+	if err != nil {
+		return nil, err
+	}
+	return searchEntries(entries, query, n), nil
+}
+
+// Close closes the file.
+func (h *FileHistory) Close() error {
+	return h.f.Close()
+}
+
+// read reads the entries of the file.
+func (h *FileHistory) read() ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, err := os.Open(h.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("dash: history %s:%d: %w", h.path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// SQLHistory is a History stored in the dash_history table of a database,
+// created if missing. Open db with a SQLite driver for database/sql: the
+// statements use SQLite's dialect, which other databases do not accept as
+// is. They need ? placeholders, an INTEGER PRIMARY KEY column numbering the
+// inserted rows, LIMIT -1 for no limit and the instr function.
+//
+// The tests run the statements on a fake driver, as the module depends on
+// no SQLite driver; they are not checked against SQLite itself.
+type SQLHistory struct {
+	db *sql.DB
+}
+
+// NewSQLHistory returns the SQLHistory in db, creating its table.
+func NewSQLHistory(ctx context.Context, db *sql.DB) (*SQLHistory, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS dash_history (id INTEGER PRIMARY KEY, time INTEGER NOT NULL, command TEXT NOT NULL, status INTEGER NOT NULL)`); err != nil {
+		return nil, fmt.Errorf("dash: history: %w", err)
+	}
+	return &SQLHistory{db: db}, nil
+}
+
+// Append implements History.
+func (h *SQLHistory) Append(ctx context.Context, e HistoryEntry) error {
+	_, err := h.db.ExecContext(ctx, `INSERT INTO dash_history (time, command, status) VALUES (?, ?, ?)`, e.Time.UnixNano(), e.Command, e.Status)
+	return err
+}
+
+// List implements History.
+func (h *SQLHistory) List(ctx context.Context, n int) ([]HistoryEntry, error) {
+	entries, err := h.query(ctx, `SELECT time, command, status FROM dash_history ORDER BY id DESC LIMIT ?`, sqlLimit(n))
+	slices.Reverse(entries)
+	return entries, err
+}
+
+// Search implements History.
+func (h *SQLHistory) Search(ctx context.Context, query string, n int) ([]HistoryEntry, error) {
+	return h.query(ctx, `SELECT time, command, status FROM dash_history WHERE instr(command, ?) > 0 ORDER BY id DESC LIMIT ?`, query, sqlLimit(n))
+}
+
+// query returns the entries selected by q.
+func (h *SQLHistory) query(ctx context.Context, q string, args ...any) ([]HistoryEntry, error) {
+	rows, err := h.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var nanos int64
+		if err := rows.Scan(&nanos, &e.Command, &e.Status); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, nanos)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// sqlLimit returns the LIMIT for n entries: -1, no limit in SQLite, for 0.
+func sqlLimit(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// lastEntries returns a copy of the last n entries, or all if n is 0.
+func lastEntries(entries []HistoryEntry, n int) []HistoryEntry {
+	if n > 0 && n < len(entries) {
+		entries = entries[len(entries)-n:]
+	}
+	return slices.Clone(entries)
+}
+
+// searchEntries returns the entries containing query, newest first, at
+// most n unless n is 0.
+func searchEntries(entries []HistoryEntry, query string, n int) []HistoryEntry {
+	var found []HistoryEntry
+	for i := len(entries) - 1; i >= 0 && (n == 0 || len(found) < n); i-- {
+		if strings.Contains(entries[i].Command, query) {
+			found = append(found, entries[i])
+		}
+	}
+	return found
+}
+
+// _ is a type assertion
+var (
+	_ History = (*MemHistory)(nil)
+	_ History = (*FileHistory)(nil)
+	_ History = (*SQLHistory)(nil)
+)
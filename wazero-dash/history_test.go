@@ -0,0 +1,243 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryBackends(t *testing.T) {
+	ctx := context.Background()
+	file, err := OpenFileHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal("OpenFileHistory:", err)
+	}
+	defer file.Close()
+	db, err := sql.Open("dash-history-test", t.Name())
+	if err != nil {
+		t.Fatal("sql.Open:", err)
+	}
+	defer db.Close()
+	sqlHistory, err := NewSQLHistory(ctx, db)
+	if err != nil {
+		t.Fatal("NewSQLHistory:", err)
+	}
+
+	commands := func(entries []HistoryEntry) []string {
+		cmds := []string{}
+		for _, e := range entries {
+			cmds = append(cmds, e.Command)
+		}
+		return cmds
+	}
+	for name, h := range map[string]History{"mem": NewMemHistory(0), "file": file, "sql": sqlHistory} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Unix(1700000000, 0)
+			for i, cmd := range []string{"echo a", "ls /", "echo b", "false"} {
+				if err := h.Append(ctx, HistoryEntry{Time: start.Add(time.Duration(i) * time.Second), Command: cmd, Status: i % 2}); err != nil {
+					t.Fatal("Append:", err)
+				}
+			}
+
+			all, err := h.List(ctx, 0)
+			if err != nil {
+				t.Fatal("List:", err)
+			}
+			if got := commands(all); !reflect.DeepEqual(got, []string{"echo a", "ls /", "echo b", "false"}) {
+				t.Fatalf("List(0) = %q", got)
+			}
+			if !all[3].Time.Equal(start.Add(3*time.Second)) || all[3].Status != 1 {
+				t.Fatalf("List(0) last entry = %+v", all[3])
+			}
+			last, _ := h.List(ctx, 2)
+			if got := commands(last); !reflect.DeepEqual(got, []string{"echo b", "false"}) {
+				t.Fatalf("List(2) = %q", got)
+			}
+			found, _ := h.Search(ctx, "echo", 0)
+			if got := commands(found); !reflect.DeepEqual(got, []string{"echo b", "echo a"}) {
+				t.Fatalf("Search(echo, 0) = %q", got)
+			}
+			found, _ = h.Search(ctx, "echo", 1)
+			if got := commands(found); !reflect.DeepEqual(got, []string{"echo b"}) {
+				t.Fatalf("Search(echo, 1) = %q", got)
+			}
+			found, _ = h.Search(ctx, "ECHO", 0)
+			if len(found) != 0 {
+				t.Fatalf("Search is not case-sensitive: %q", commands(found))
+			}
+		})
+	}
+
+	// A FileHistory reopened later sees the entries of earlier sessions.
+	again, err := OpenFileHistory(file.path)
+	if err != nil {
+		t.Fatal("OpenFileHistory:", err)
+	}
+	defer again.Close()
+	if entries, _ := again.List(ctx, 0); len(entries) != 4 {
+		t.Fatalf("reopened file history has %d entries", len(entries))
+	}
+}
+
+func TestMemHistoryMax(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemHistory(2)
+	for _, cmd := range []string{"a", "b", "c"} {
+		h.Append(ctx, HistoryEntry{Command: cmd})
+	}
+	entries, _ := h.List(ctx, 0)
+	if len(entries) != 2 || entries[0].Command != "b" || entries[1].Command != "c" {
+		t.Fatalf("List = %+v", entries)
+	}
+}
+
+func TestFileHistoryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte(`{"command":"ok"}`+"\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := OpenFileHistory(path)
+	if err != nil {
+		t.Fatal("OpenFileHistory:", err)
+	}
+	defer h.Close()
+	if _, err := h.List(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "history:2:") {
+		t.Fatalf("List of a corrupt file returned %v", err)
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemHistory(0)
+	for _, cmd := range []string{"echo one", "cd /tmp", "echo two"} {
+		h.Append(ctx, HistoryEntry{Command: cmd})
+	}
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithHistory(h))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	for _, tc := range []struct{ script, stdout, stderr string }{
+		{`history`, "echo one\ncd /tmp\necho two\n", ""},
+		{`history 1`, "echo two\n", ""},
+		{`history -s echo`, "echo two\necho one\n", ""},
+		{`history -s echo 1`, "echo two\n", ""},
+		{`history -s; echo $?`, "2\n", "usage"},
+		{`history x; echo $?`, "2\n", "Illegal number: x"},
+	} {
+		stdout.Reset()
+		stderr.Reset()
+		if _, err := d.Eval(ctx, tc.script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		if stdout.String() != tc.stdout || !strings.Contains(stderr.String(), tc.stderr) {
+			t.Errorf("%s: stdout %q, stderr %q", tc.script, stdout.String(), stderr.String())
+		}
+	}
+}
+
+// historyDriver is a database/sql driver running the statements of
+// SQLHistory against a slice, standing in for SQLite.
+type historyDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*[]historyRow
+}
+
+type historyRow struct {
+	time    int64
+	command string
+	status  int64
+}
+
+func init() {
+	sql.Register("dash-history-test", &historyDriver{dbs: map[string]*[]historyRow{}})
+}
+
+func (d *historyDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dbs[name] == nil {
+		d.dbs[name] = &[]historyRow{}
+	}
+	return &historyConn{rows: d.dbs[name]}, nil
+}
+
+type historyConn struct {
+	rows *[]historyRow
+}
+
+func (c *historyConn) Prepare(query string) (driver.Stmt, error) {
+	return &historyStmt{c: c, query: query}, nil
+}
+
+func (c *historyConn) Close() error              { return nil }
+func (c *historyConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("no transactions") }
+
+type historyStmt struct {
+	c     *historyConn
+	query string
+}
+
+func (s *historyStmt) Close() error  { return nil }
+func (s *historyStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *historyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS dash_history "):
+	case strings.HasPrefix(s.query, "INSERT INTO dash_history (time, command, status) "):
+		*s.c.rows = append(*s.c.rows, historyRow{args[0].(int64), args[1].(string), args[2].(int64)})
+	default:
+		return nil, fmt.Errorf("unexpected statement %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *historyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	query := ""
+	switch s.query {
+	case `SELECT time, command, status FROM dash_history ORDER BY id DESC LIMIT ?`:
+	case `SELECT time, command, status FROM dash_history WHERE instr(command, ?) > 0 ORDER BY id DESC LIMIT ?`:
+		query, args = args[0].(string), args[1:]
+	default:
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	limit := args[0].(int64)
+	var rows []historyRow
+	for i := len(*s.c.rows) - 1; i >= 0 && (limit < 0 || int64(len(rows)) < limit); i-- {
+		if r := (*s.c.rows)[i]; strings.Contains(r.command, query) {
+			rows = append(rows, r)
+		}
+	}
+	return &historyRows{rows: rows}, nil
+}
+
+type historyRows struct {
+	rows []historyRow
+}
+
+func (r *historyRows) Columns() []string { return []string{"time", "command", "status"} }
+func (r *historyRows) Close() error      { return nil }
+
+func (r *historyRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	dest[0], dest[1], dest[2] = r.rows[0].time, r.rows[0].command, r.rows[0].status
+	r.rows = r.rows[1:]
+	return nil
+}
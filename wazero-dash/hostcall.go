@@ -0,0 +1,93 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HostCallCommand is the shell command name that dispatches to registered
+// host call handlers.
+//
+// Usage from a script:
+//
+//	@host NAME [json]
+//
+// The optional json argument is passed to the handler as raw JSON. The
+// handler result is encoded as JSON and written to stdout followed by a
+// newline.
+const HostCallCommand = "@host"
+
+// HostCallFunc handles a @host call from a shell script.
+//
+// args contains the JSON argument, or nil if the script passed none. The
+// returned value is encoded as JSON and written to stdout; a nil result
+// writes nothing. Returning an error writes the message to stderr and sets
+// exit status 1.
+type HostCallFunc func(ctx context.Context, args json.RawMessage) (any, error)
+
+// RegisterHostCall registers a handler reachable from scripts as
+// `@host name [json]`. Registering the same name again replaces the handler.
+func (d *Dash) RegisterHostCall(name string, fn HostCallFunc) {
+	if d.state.hostCalls == nil {
+		d.state.hostCalls = make(map[string]HostCallFunc)
+	}
+	d.state.hostCalls[name] = fn
+}
+
+// UnregisterHostCall removes a host call handler.
+func (d *Dash) UnregisterHostCall(name string) {
+	delete(d.state.hostCalls, name)
+}
+
+// runHostCall dispatches a @host command and returns its exit status.
+//
+// Exit statuses: 0 on success, 1 if the handler failed, 2 on usage or JSON
+// errors, 127 if no handler is registered under the name.
+func runHostCall(ctx context.Context, mod api.Module, state *dashState, argv []string) int {
+	if len(argv) < 2 || len(argv) > 3 {
+		hostCallError(ctx, mod, state, "usage: "+HostCallCommand+" NAME [json]")
+		return 2
+	}
+
+	name := argv[1]
+	fn, ok := state.hostCalls[name]
+	if !ok {
+		hostCallError(ctx, mod, state, name+": not found")
+		return 127
+	}
+
+	var args json.RawMessage
+	if len(argv) == 3 {
+		if !json.Valid([]byte(argv[2])) {
+			hostCallError(ctx, mod, state, name+": invalid json argument")
+			return 2
+		}
+		args = json.RawMessage(argv[2])
+	}
+
+	result, err := fn(ctx, args)
+	if err != nil {
+		hostCallError(ctx, mod, state, name+": "+err.Error())
+		return 1
+	}
+	if result == nil {
+		return 0
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		hostCallError(ctx, mod, state, name+": encode result: "+err.Error())
+		return 1
+	}
+	if err := guestWrite(ctx, mod, state, fdStdout, append(out, '\n')); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// hostCallError writes a @host diagnostic to the guest stderr.
+func hostCallError(ctx context.Context, mod api.Module, state *dashState, msg string) {
+	commandError(ctx, mod, state, HostCallCommand, msg)
+}
@@ -0,0 +1,107 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHostCall(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+
+	d.RegisterHostCall("add", func(ctx context.Context, args json.RawMessage) (any, error) {
+		var req struct{ A, B int }
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		return map[string]int{"sum": req.A + req.B}, nil
+	})
+	d.RegisterHostCall("fail", func(ctx context.Context, args json.RawMessage) (any, error) {
+		return nil, errors.New("boom")
+	})
+
+	status, err := d.Eval(ctx, `@host add '{"a": 2, "b": 3}'`)
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %d", status)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != `{"sum":5}` {
+		t.Fatalf("expected sum result, got %q", got)
+	}
+
+	tests := []struct {
+		cmd    string
+		status int
+		stderr string
+	}{
+		{`@host fail`, 1, "fail: boom"},
+		{`@host add '{bad'`, 2, "invalid json"},
+		{`@host missing`, 127, "missing: not found"},
+		{`@host`, 2, "usage"},
+	}
+	for _, tc := range tests {
+		stderr.Reset()
+		status, err := d.Eval(ctx, tc.cmd)
+		if err != nil {
+			t.Fatalf("Eval %q: %v", tc.cmd, err)
+		}
+		if status != tc.status {
+			t.Fatalf("%q: expected exit status %d, got %d", tc.cmd, tc.status, status)
+		}
+		if !strings.Contains(stderr.String(), tc.stderr) {
+			t.Fatalf("%q: expected stderr to contain %q, got %q", tc.cmd, tc.stderr, stderr.String())
+		}
+	}
+}
+
+func TestHostCallReentrantEval(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+
+	d.RegisterHostCall("leaf", func(ctx context.Context, args json.RawMessage) (any, error) {
+		return "leaf", nil
+	})
+	d.RegisterHostCall("eval", func(ctx context.Context, args json.RawMessage) (any, error) {
+		var cmd string
+		if err := json.Unmarshal(args, &cmd); err != nil {
+			return nil, err
+		}
+		status, err := d.Eval(ctx, cmd)
+		var se *SyntaxError
+		if errors.As(err, &se) {
+			return status, nil
+		}
+		return status, err
+	})
+
+	baseline := len(d.state.checkpoints)
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		// builtin -> Eval -> builtin
+		{`@host eval '"echo nested; @host leaf"'; echo after`, "nested\n\"leaf\"\n0\nafter"},
+		// builtin -> Eval -> builtin -> Eval
+		{`@host eval '"@host eval \"\\\"echo deep\\\"\""'`, "deep\n0\n0"},
+		// errors raised in the nested evaluation stay inside it
+		{`@host eval '"if then"'; echo recovered`, "2\nrecovered"},
+		{`@host eval '"for"'; f() { echo $1; }; f ok`, "2\nok"},
+	}
+	for _, tc := range tests {
+		stdout.Reset()
+		if _, err := d.Eval(ctx, tc.cmd); err != nil {
+			t.Fatalf("Eval %q: %v", tc.cmd, err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != tc.want {
+			t.Fatalf("%q: expected %q, got %q", tc.cmd, tc.want, got)
+		}
+		if n := len(d.state.checkpoints); n != baseline {
+			t.Fatalf("%q: checkpoints leaked: %d != %d", tc.cmd, n, baseline)
+		}
+	}
+}
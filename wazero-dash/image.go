@@ -0,0 +1,296 @@
+package dash
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// OCI layer whiteout markers.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// maxImageMetadata is the largest manifest or index file read from an
+// image tarball.
+const maxImageMetadata = 4 << 20
+
+// imageSource is the guest root file system requested with
+// WithImageLayers or WithImageTarball.
+type imageSource struct {
+	layers []io.Reader
+	// tarball, if set, is an image archive holding the layers.
+	tarball     io.ReaderAt
+	tarballSize int64
+}
+
+// WithImageLayers uses the file systems of OCI or Docker image layers as the
+// guest root file system. Each layer is a tar archive, optionally gzip
+// compressed, applied over the previous ones in order, honoring whiteout
+// files. Layers pulled from a registry by the embedder can be passed as is.
+//
+// The layers are read by NewDash into a writable in-memory file system
+// mounted at "/". Other mounts, including /tmp, are layered on top of it.
+// The virtual BinDir is not mounted, so the image's /bin stays visible.
+func WithImageLayers(layers ...io.Reader) Option {
+	return func(o *options) {
+		o.image = &imageSource{layers: layers}
+	}
+}
+
+// WithImageTarball is like WithImageLayers, reading the layers from an image
+// archive in the format written by `docker save` or an OCI image layout
+// packed as a tar, size bytes long. The archive must hold a single image.
+func WithImageTarball(r io.ReaderAt, size int64) Option {
+	return func(o *options) {
+		o.image = &imageSource{tarball: r, tarballSize: size}
+	}
+}
+
+// imageFS builds the guest root file system of the image src.
+func imageFS(src *imageSource) (*memFS, error) {
+	layers := src.layers
+	if src.tarball != nil {
+		var err error
+		if layers, err = tarballLayers(src.tarball, src.tarballSize); err != nil {
+			return nil, err
+		}
+	}
+	m := newMemFS(0)
+	for i, layer := range layers {
+		r, err := decompress(layer)
+		if err != nil {
+			return nil, fmt.Errorf("dash: image layer %d: %w", i, err)
+		}
+		if err := extractTar(m, r, true); err != nil {
+			return nil, fmt.Errorf("dash: image layer %d: %w", i, err)
+		}
+	}
+	return m, nil
+}
+
+// decompress returns r, transparently gunzipped if it is compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// tarballEntry locates the contents of a file in an image tarball.
+type tarballEntry struct {
+	offset, size int64
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// tarballLayers returns readers for the layers of the image archive r, in
+// the order they apply.
+func tarballLayers(r io.ReaderAt, size int64) ([]io.Reader, error) {
+	// Index the regular files of the archive by offset, so layers are read
+	// in manifest order whatever their order in the archive.
+	entries := make(map[string]tarballEntry)
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dash: image tarball: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			name, _ := archivePath(hdr.Name)
+			entries[name] = tarballEntry{offset: cr.n, size: hdr.Size}
+		}
+	}
+	readJSON := func(name string, v any) error {
+		e, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("dash: image tarball: missing %s", name)
+		}
+		if e.size > maxImageMetadata {
+			return fmt.Errorf("dash: image tarball: %s too large", name)
+		}
+		if err := json.NewDecoder(io.NewSectionReader(r, e.offset, e.size)).Decode(v); err != nil {
+			return fmt.Errorf("dash: image tarball: %s: %w", name, err)
+		}
+		return nil
+	}
+	// blob returns the path of a blob of an OCI image layout.
+	blob := func(digest string) string {
+		return "blobs/" + strings.Replace(digest, ":", "/", 1)
+	}
+
+	var names []string
+	switch _, isDocker := entries["manifest.json"]; {
+	case isDocker:
+		var manifest []struct{ Layers []string }
+		if err := readJSON("manifest.json", &manifest); err != nil {
+			return nil, err
+		}
+		if len(manifest) != 1 {
+			return nil, fmt.Errorf("dash: image tarball holds %d images, expected 1", len(manifest))
+		}
+		for _, l := range manifest[0].Layers {
+			name, _ := archivePath(l)
+			names = append(names, name)
+		}
+	default:
+		type descriptor struct {
+			MediaType string
+			Digest    string
+		}
+		var index struct{ Manifests []descriptor }
+		if err := readJSON("index.json", &index); err != nil {
+			return nil, err
+		}
+		if len(index.Manifests) != 1 {
+			return nil, fmt.Errorf("dash: image tarball holds %d images, expected 1", len(index.Manifests))
+		}
+		var manifest struct{ Layers []descriptor }
+		if err := readJSON(blob(index.Manifests[0].Digest), &manifest); err != nil {
+			return nil, err
+		}
+		for _, l := range manifest.Layers {
+			names = append(names, blob(l.Digest))
+		}
+	}
+
+	layers := make([]io.Reader, len(names))
+	for i, name := range names {
+		e, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("dash: image tarball: missing layer %s", name)
+		}
+		layers[i] = io.NewSectionReader(r, e.offset, e.size)
+	}
+	return layers, nil
+}
+
+// extractTar adds the entries of the tar archive r to m, failing for a
+// file over the space left under m's cap. With layer set, OCI whiteout
+// files remove entries of the previous layers instead of being added.
+func extractTar(m *memFS, r io.Reader, layer bool) error {
+	// added holds the paths created by this layer, which opaque whiteouts
+	// keep.
+	added := make(map[string]bool)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := archivePath(hdr.Name)
+		if !ok {
+			return fmt.Errorf("invalid path %q", hdr.Name)
+		}
+		if name == "." {
+			continue
+		}
+		if base := path.Base(name); layer && strings.HasPrefix(base, whiteoutPrefix) {
+			dir := path.Dir(name)
+			if base == whiteoutOpaque {
+				errno := m.clearDir(dir, func(child string) bool { return added[path.Join(dir, child)] })
+				if errno != 0 && errno != experimentalsys.ENOENT {
+					return fmt.Errorf("%s: %w", hdr.Name, errno)
+				}
+				continue
+			}
+			if errno := m.removeAll(path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))); errno != 0 && errno != experimentalsys.ENOENT {
+				return fmt.Errorf("%s: %w", hdr.Name, errno)
+			}
+			continue
+		}
+		if layer && hdr.Typeflag != tar.TypeDir {
+			// A later layer replaces a file, or a directory with a file.
+			if errno := m.removeAll(name); errno != 0 && errno != experimentalsys.ENOENT {
+				return fmt.Errorf("%s: %w", hdr.Name, errno)
+			}
+		}
+		if hdr.Typeflag == tar.TypeReg && m.maxBytes != 0 {
+			if used, _ := m.usage(); hdr.Size > m.maxBytes-used {
+				return fmt.Errorf("%s: file data exceeds %d bytes", hdr.Name, m.maxBytes)
+			}
+		}
+		if errno := addTarEntry(m, name, hdr, tr); errno != 0 {
+			return fmt.Errorf("%s: %w", hdr.Name, errno)
+		}
+		added[name] = true
+	}
+}
+
+// removeAll removes p and, for a directory, everything below it. Symbolic
+// links are removed, not followed.
+func (m *memFS) removeAll(p string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, name, errno := m.parent(p)
+	if errno != 0 {
+		return errno
+	}
+	if _, ok := dir.children[name]; !ok {
+		return experimentalsys.ENOENT
+	}
+	m.unlinkAll(dir, name)
+	return 0
+}
+
+// clearDir removes the entries of the directory p for which keep returns
+// false.
+func (m *memFS) clearDir(p string, keep func(name string) bool) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, errno := m.resolve(p, true)
+	if errno != 0 {
+		return errno
+	}
+	if !dir.mode.IsDir() {
+		return experimentalsys.ENOTDIR
+	}
+	for name := range dir.children {
+		if !keep(name) {
+			m.unlinkAll(dir, name)
+		}
+	}
+	return 0
+}
+
+// unlinkAll unlinks name from dir after the entries below it. Callers
+// hold mu.
+func (m *memFS) unlinkAll(dir *memNode, name string) {
+	if node := dir.children[name]; node.mode.IsDir() {
+		for child := range node.children {
+			m.unlinkAll(node, child)
+		}
+	}
+	m.unlink(dir, name)
+}
@@ -0,0 +1,159 @@
+package dash
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// tarEntry is a file of a test tar archive. An empty body with a trailing
+// slash in name is a directory.
+type tarEntry struct {
+	name, body string
+}
+
+func tarBytes(t *testing.T, compress bool, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	gz := gzip.NewWriter(&buf)
+	if compress {
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body))}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compress {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// testLayers returns two image layers, the second gzip compressed.
+func testLayers(t *testing.T) [][]byte {
+	return [][]byte{
+		tarBytes(t, false,
+			tarEntry{"bin/sh", "#!"},
+			tarEntry{"etc/os-release", "ID=base\n"},
+			tarEntry{"usr/share/doc/a", "a"},
+			tarEntry{"usr/share/doc/b", "b"},
+			tarEntry{"opt/x/file", "x"},
+		),
+		tarBytes(t, true,
+			tarEntry{"etc/os-release", "ID=test\n"},
+			tarEntry{"usr/share/doc/c", "c"},
+			tarEntry{"usr/share/doc/.wh..wh..opq", ""},
+			tarEntry{"opt/.wh.x", ""},
+			tarEntry{"tmp/image-file", "shadowed"},
+		),
+	}
+}
+
+// readGuestFile reads the guest file p through the managed mounts of d.
+func readGuestFile(t *testing.T, d *Dash, p string) string {
+	t.Helper()
+	data, err := d.ReadFile(p)
+	if err != nil {
+		t.Fatal("ReadFile:", err)
+	}
+	return string(data)
+}
+
+func TestImageRootFS(t *testing.T) {
+	layers := testLayers(t)
+	dockerManifest, _ := json.Marshal([]map[string]any{{"Config": "config.json", "Layers": []string{"l0/layer.tar", "l1/layer.tar"}}})
+	docker := tarBytes(t, false,
+		tarEntry{"l1/layer.tar", string(layers[1])},
+		tarEntry{"manifest.json", string(dockerManifest)},
+		tarEntry{"l0/layer.tar", string(layers[0])},
+	)
+	digest := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	blob := func(b []byte) tarEntry {
+		return tarEntry{"blobs/sha256/" + strings.TrimPrefix(digest(b), "sha256:"), string(b)}
+	}
+	ociManifest, _ := json.Marshal(map[string]any{"layers": []map[string]string{{"digest": digest(layers[0])}, {"digest": digest(layers[1])}}})
+	ociIndex, _ := json.Marshal(map[string]any{"manifests": []map[string]string{{"digest": digest(ociManifest)}}})
+	oci := tarBytes(t, false,
+		tarEntry{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		tarEntry{"index.json", string(ociIndex)},
+		blob(ociManifest), blob(layers[0]), blob(layers[1]),
+	)
+
+	ctx := context.Background()
+	for name, opt := range map[string]Option{
+		"layers": WithImageLayers(bytes.NewReader(layers[0]), bytes.NewReader(layers[1])),
+		"docker": WithImageTarball(bytes.NewReader(docker), int64(len(docker))),
+		"oci":    WithImageTarball(bytes.NewReader(oci), int64(len(oci))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := wazero.NewRuntime(ctx)
+			defer r.Close(ctx)
+			var stdout, stderr bytes.Buffer
+			d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(wazero.NewModuleConfig().WithStdout(&stdout).WithStderr(&stderr)), opt)
+			if err != nil {
+				t.Fatal("NewDash:", err)
+			}
+			defer d.Close(ctx)
+			if err := d.Init(ctx, nil); err != nil {
+				t.Fatal("Init:", err)
+			}
+
+			script := `echo /*
+echo /bin/* /usr/share/doc/* /opt/*
+echo /tmp/*
+true >/etc/new; test -f /etc/new && echo writable`
+			if _, err := d.Eval(ctx, script); err != nil {
+				t.Fatal("Eval:", err)
+			}
+			want := "/bin /etc /opt /tmp /usr\n/bin/sh /usr/share/doc/c /opt/*\n/tmp/*\nwritable\n"
+			if got := stdout.String(); got != want {
+				t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+			}
+			if got := readGuestFile(t, d, "/etc/os-release"); got != "ID=test\n" {
+				t.Fatalf("unexpected /etc/os-release %q", got)
+			}
+		})
+	}
+}
+
+func TestImageRootFSErrors(t *testing.T) {
+	ctx := context.Background()
+	for name, opt := range map[string]Option{
+		"layer":    WithImageLayers(strings.NewReader("not a tar archive, but long enough to fill a header block")),
+		"manifest": WithImageTarball(bytes.NewReader(tarBytes(t, false, tarEntry{"oci-layout", "{}"})), 2048),
+	} {
+		r := wazero.NewRuntime(ctx)
+		_, err := NewDash(ctx, WithRuntime(r), opt)
+		_ = r.Close(ctx)
+		if err == nil || !strings.HasPrefix(err.Error(), "dash: image") {
+			t.Fatalf("%s: expected an image error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+)
+
+// initScriptName is $0 while an init script runs, as shown in its error
+// messages.
+const initScriptName = "init-script"
+
+// WithInitScript evaluates src at the end of Init, after WithEnvFile and
+// WithStdlib, to preconfigure every instance: setting PATH, defining
+// functions and so on. May be repeated; scripts run in order. Init fails if
+// a script exits with a non-zero status.
+func WithInitScript(src string) Option {
+	return func(o *options) {
+		o.initScripts = append(o.initScripts, src)
+	}
+}
+
+// runInitScripts evaluates the scripts passed to WithInitScript.
+func (d *Dash) runInitScripts(ctx context.Context) error {
+	if len(d.state.initScripts) == 0 {
+		return nil
+	}
+	d.setArg0(initScriptName)
+	defer d.setArg0(d.arg0)
+	for i, src := range d.state.initScripts {
+		status, err := d.eval(ctx, src)
+		if d.dashListFuncs == nil {
+			d.state.trackFunctions(src)
+		}
+		if err != nil {
+			return fmt.Errorf("dash: init script %d: %w", i+1, err)
+		}
+		if status != 0 {
+			return fmt.Errorf("dash: init script %d failed with status %d", i+1, status)
+		}
+	}
+	return nil
+}
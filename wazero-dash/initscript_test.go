@@ -0,0 +1,53 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInitScript(t *testing.T) {
+	ctx := context.Background()
+	var stdout bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, nil),
+		WithInitScript("PATH=/opt/bin:$PATH\nNAME=init\ngreet() { echo \"hello, $1\"; }"),
+		WithInitScript("GREETING=\"hello, $NAME\""))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	greeting, err := d.GetVar(ctx, "GREETING")
+	if err != nil {
+		t.Fatal("GetVar:", err)
+	}
+	if greeting != "hello, init" {
+		t.Fatalf("expected GREETING from the second script, got %q", greeting)
+	}
+	path, err := d.GetVar(ctx, "PATH")
+	if err != nil {
+		t.Fatal("GetVar:", err)
+	}
+	if !strings.HasPrefix(path, "/opt/bin:") {
+		t.Fatalf("expected PATH to start with /opt/bin:, got %q", path)
+	}
+	if _, err := d.Eval(ctx, "greet world"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stdout.String(); got != "hello, world\n" {
+		t.Fatalf("expected greet output, got %q", got)
+	}
+
+	d, err = NewDash(ctx, WithInitScript("true"), WithInitScript("false"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	err = d.Init(ctx, nil)
+	if err == nil || !strings.Contains(err.Error(), "init script 2 failed with status 1") {
+		t.Fatalf("expected init script 2 to fail, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package dash
+
+import (
+	"context"
+	"io"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// EvalWithInput evaluates cmd like Eval with the guest stdin read from
+// stdin instead of the stream of the module config, so commands such as
+// read consume host-supplied input for this evaluation only. Builtin
+// overrides read from it too. Input left unread stays in stdin.
+func (d *Dash) EvalWithInput(ctx context.Context, cmd string, stdin io.Reader) (int, error) {
+	prev := d.state.input
+	d.state.input = stdin
+	defer func() { d.state.input = prev }()
+	return d.Eval(ctx, cmd)
+}
+
+// fdReadHost implements WASI fd_read, serving stdin from the
+// EvalWithInput reader while one is active.
+//
+// Stack: fd, iovs, iovs_len, result.nread -> errno
+func fdReadHost(ctx context.Context, mod api.Module, stack []uint64) {
+	state, _ := ctx.Value(dashStateKey{}).(*dashState)
+	if state == nil {
+		return
+	}
+	if uint32(stack[0]) == fdStdin {
+		// Show prompts before waiting for input.
+		_ = state.flushStdout()
+	}
+	if state.input == nil || uint32(stack[0]) != fdStdin {
+		state.fdRead.Call(ctx, mod, stack)
+		return
+	}
+
+	const wasiErrnoFault = 21
+	mem := mod.Memory()
+	iovs, iovsLen := uint32(stack[1]), uint32(stack[2])
+	var nread uint32
+	for i := uint32(0); i < iovsLen; i++ {
+		buf, ok1 := mem.ReadUint32Le(iovs + i*8)
+		size, ok2 := mem.ReadUint32Le(iovs + i*8 + 4)
+		dst, ok3 := mem.Read(buf, size)
+		if !ok1 || !ok2 || !ok3 {
+			stack[0] = wasiErrnoFault
+			return
+		}
+		if len(dst) == 0 {
+			continue
+		}
+		n, err := state.input.Read(dst)
+		nread += uint32(n)
+		if err != nil && err != io.EOF && nread == 0 {
+			stack[0] = wasiErrnoIO
+			return
+		}
+		if err != nil || n < len(dst) {
+			break
+		}
+	}
+	if !mem.WriteUint32Le(uint32(stack[3]), nread) {
+		stack[0] = wasiErrnoFault
+		return
+	}
+	stack[0] = wasiErrnoSuccess
+}
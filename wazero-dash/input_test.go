@@ -0,0 +1,47 @@
+package dash
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEvalWithInput(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+
+	var fromBuiltin string
+	if err := d.OverrideBuiltin(ctx, "test", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		b, _ := io.ReadAll(stdin)
+		fromBuiltin = string(b)
+		return 0
+	}); err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+
+	in := strings.NewReader("first line\nsecond\nrest\nof input\n")
+	status, err := d.EvalWithInput(ctx, `read a; read b; echo "$a|$b"`, in)
+	if err != nil {
+		t.Fatal("EvalWithInput:", err)
+	}
+	if status != 0 || stdout.String() != "first line|second\n" {
+		t.Fatalf("unexpected status %d, output %q", status, stdout.String())
+	}
+
+	if _, err := d.EvalWithInput(ctx, "test", in); err != nil {
+		t.Fatal("EvalWithInput:", err)
+	}
+	if fromBuiltin != "rest\nof input\n" {
+		t.Fatalf("unexpected builtin input %q", fromBuiltin)
+	}
+
+	// The input ends with the evaluation: the module stdin is empty.
+	stdout.Reset()
+	if _, err := d.Eval(ctx, `read c; echo "status $?"`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if stdout.String() != "status 1\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
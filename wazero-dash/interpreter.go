@@ -0,0 +1,22 @@
+package dash
+
+import (
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	"github.com/aperturerobotics/go-dash-wasi-reactor/shell"
+)
+
+// _ is a type assertion
+var _ shell.Interpreter = (*Dash)(nil)
+
+// Capabilities reports the features supported by the loaded dash module.
+func (d *Dash) Capabilities() shell.Capabilities {
+	return shell.Capabilities{
+		Name:        "dash",
+		Version:     dashwasi.Version,
+		ExitStatus:  d.dashGetExitStatus != nil,
+		Vars:        d.dashGetVar != nil && d.dashSetVar != nil,
+		Interactive: d.dashRunInteractive != nil,
+		HostCalls:   true,
+		ExecHandler: true,
+	}
+}
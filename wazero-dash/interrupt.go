@@ -0,0 +1,80 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+)
+
+// ErrInterrupted is wrapped by the errors returned when the context of Eval
+// or RunInteractive was done before the shell returned.
+var ErrInterrupted = errors.New("dash: interrupted")
+
+// InterruptedError is returned when the context of Eval or RunInteractive
+// was done before the shell returned. It matches ErrInterrupted, the
+// context's error and its cause, so callers can tell a deadline from a
+// cancellation and from the causes given to context.WithCancelCause or
+// context.WithTimeoutCause.
+type InterruptedError struct {
+	// Err is context.Canceled or context.DeadlineExceeded.
+	Err error
+	// Cause is the context's cause, which is Err unless one was given.
+	Cause error
+}
+
+// Error implements error.
+func (e *InterruptedError) Error() string {
+	return ErrInterrupted.Error() + ": " + e.Cause.Error()
+}
+
+// Unwrap returns ErrInterrupted, Cause and Err.
+func (e *InterruptedError) Unwrap() []error {
+	if e.Cause == e.Err {
+		return []error{ErrInterrupted, e.Err}
+	}
+	return []error{ErrInterrupted, e.Cause, e.Err}
+}
+
+// interruptedError returns the error of a call interrupted by ctx.
+func interruptedError(ctx context.Context) error {
+	return &InterruptedError{Err: ctx.Err(), Cause: context.Cause(ctx)}
+}
+
+// checkInterrupted aborts the current guest call if its context is done.
+// Only calls made with invoke have a context that can be done.
+func (s *dashState) checkInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		panic(ErrInterrupted)
+	}
+}
+
+// recoverInterrupted makes the shell usable after a call interrupted by
+// ctx, restoring snap and discarding the setjmp checkpoints from index
+// ncheckpoints. If the runtime closed the module, it is instantiated again
+// first; file descriptors opened by the shell are lost. Returns the error
+// for the interrupted call.
+func (d *Dash) recoverInterrupted(ctx context.Context, snap *memorySnapshot, ncheckpoints int) error {
+	interrupted := interruptedError(ctx)
+	reinstantiated := d.mod.IsClosed()
+	var fds fdTable
+	if reinstantiated {
+		ictx := withMemoryLimit(withDashState(context.WithoutCancel(ctx), d.state), d.state)
+		mod, err := d.runtime.InstantiateModule(ictx, d.compiled, d.config.WithName(dashwasi.DashWASMFilename))
+		if err != nil {
+			return errors.Join(interrupted, fmt.Errorf("dash: recovering from interruption: %w", err))
+		}
+		if err := d.bind(mod); err != nil {
+			return errors.Join(interrupted, err)
+		}
+		// The new instance only has the standard streams and the
+		// preopened directories.
+		fds = d.state.movePreopens(ictx, mod)
+	}
+	d.rollback(snap, ncheckpoints)
+	if reinstantiated {
+		d.state.fds = fds
+	}
+	return interrupted
+}
@@ -0,0 +1,131 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestEvalInterrupted(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	if _, err := d.Eval(ctx, "x=before"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	status, err := d.Eval(tctx, "x=during; while :; do :; done")
+	if !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.DeadlineExceeded) || status != -1 {
+		t.Fatalf("expected an interruption by the deadline, got %d, %v", status, err)
+	}
+	if _, err := d.Eval(tctx, "echo unreachable"); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted for a done context, got %v", err)
+	}
+
+	stdout.Reset()
+	if status, err := d.Eval(ctx, `echo "$x"`); err != nil || status != 0 || stdout.String() != "before\n" {
+		t.Fatalf("shell not rolled back: %d, %v, %q", status, err, stdout.String())
+	}
+}
+
+func TestEvalSnapshotRefreshed(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if _, err := d.Eval(cctx, "x=first"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	snap := d.evalSnap
+	// Growing the memory extends the saved copy.
+	size := d.mod.Memory().Size()
+	if status, err := d.Eval(cctx, `i=0; while [ $i -lt 16 ]; do x=$x$x$x$x; i=$((i + 1)); [ ${#x} -gt 1000000 ] && break; done`); err != nil || status != 0 {
+		t.Fatalf("Eval: %d, %v", status, err)
+	}
+	if d.mod.Memory().Size() == size {
+		t.Fatal("memory did not grow")
+	}
+	if _, err := d.Eval(cctx, "x=kept"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if d.evalSnap != snap {
+		t.Fatal("evaluations did not reuse the snapshot")
+	}
+
+	tctx, cancelT := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelT()
+	if _, err := d.Eval(tctx, "x=during; while :; do :; done"); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected an interruption, got %v", err)
+	}
+	stdout.Reset()
+	if status, err := d.Eval(ctx, `echo "$x"`); err != nil || status != 0 || stdout.String() != "kept\n" {
+		t.Fatalf("shell not rolled back to the last evaluation: %d, %v, %q", status, err, stdout.String())
+	}
+}
+
+func TestEvalInterruptedAtHostCall(t *testing.T) {
+	// Without WithCloseOnContextDone, the guest stops at its next host call.
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	d, err := NewDash(ctx, WithRuntime(r))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	calls := 0
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		if calls++; calls == 3 {
+			cancel()
+		}
+		return 0
+	})
+	h := d.EvalAsync(cctx, "while :; do tick; done")
+	if _, err := h.Wait(); !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected an interruption, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 commands before the interruption, got %d", calls)
+	}
+	if status, err := d.Eval(ctx, "true"); err != nil || status != 0 {
+		t.Fatalf("shell unusable after interruption: %d, %v", status, err)
+	}
+}
+
+func TestEvalInterruptedCause(t *testing.T) {
+	ctx := context.Background()
+	d, _, _ := newTestDash(t)
+
+	errShutdown := errors.New("shutting down")
+	cctx, cancel := context.WithCancelCause(ctx)
+	cancel(errShutdown)
+	_, err := d.Eval(cctx, "true")
+	var ie *InterruptedError
+	if !errors.As(err, &ie) || ie.Err != context.Canceled || ie.Cause != errShutdown {
+		t.Fatalf("expected an InterruptedError with the cause, got %#v", err)
+	}
+	for _, target := range []error{ErrInterrupted, errShutdown, context.Canceled} {
+		if !errors.Is(err, target) {
+			t.Fatalf("%v does not match %v", err, target)
+		}
+	}
+	if want := "dash: interrupted: shutting down"; err.Error() != want {
+		t.Fatalf("got %q, want %q", err, want)
+	}
+
+	tctx, cancel2 := context.WithTimeoutCause(ctx, time.Millisecond, errShutdown)
+	defer cancel2()
+	_, err = d.Eval(tctx, "while :; do :; done")
+	if !errors.Is(err, errShutdown) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline and its cause, got %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package dash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// JournalHashMaxBytes is the largest file hashed by the write journal.
+const JournalHashMaxBytes = 64 << 10
+
+// JournalOp is a kind of file mutation recorded by the write journal.
+type JournalOp int
+
+// File mutations recorded by the write journal.
+const (
+	// JournalCreate records a file created by the guest. Data written to
+	// it is recorded by a following JournalWrite.
+	JournalCreate JournalOp = iota
+	// JournalWrite records the writes to, or truncation of, a file between
+	// its open and close.
+	JournalWrite
+	// JournalRemove records an unlinked file.
+	JournalRemove
+	// JournalMkdir records a created directory.
+	JournalMkdir
+	// JournalRmdir records a removed directory.
+	JournalRmdir
+	// JournalRename records a rename from Path to Target.
+	JournalRename
+	// JournalChmod records a permission change.
+	JournalChmod
+	// JournalLink records a hard link Target to Path.
+	JournalLink
+	// JournalSymlink records a symbolic link at Path pointing to Target.
+	JournalSymlink
+)
+
+// String returns the operation name.
+func (op JournalOp) String() string {
+	switch op {
+	case JournalCreate:
+		return "create"
+	case JournalWrite:
+		return "write"
+	case JournalRemove:
+		return "remove"
+	case JournalMkdir:
+		return "mkdir"
+	case JournalRmdir:
+		return "rmdir"
+	case JournalRename:
+		return "rename"
+	case JournalChmod:
+		return "chmod"
+	case JournalLink:
+		return "link"
+	case JournalSymlink:
+		return "symlink"
+	default:
+		return "unknown"
+	}
+}
+
+// JournalEntry is a file mutation performed by the guest.
+type JournalEntry struct {
+	Time time.Time
+	Op   JournalOp
+	// Path is the absolute guest path of the mutated file.
+	Path string
+	// Target is the new path of a rename, the new name of a hard link or
+	// the target of a symbolic link.
+	Target string
+	// Written is the number of bytes written by write.
+	Written int64
+	// Size is the file size after write, or before remove.
+	Size int64
+	// BeforeHash and AfterHash are hex SHA-256 digests of the file contents
+	// before and after the mutation. Empty if the file did not exist, is not
+	// a regular file, or is larger than JournalHashMaxBytes.
+	BeforeHash, AfterHash string
+}
+
+// WithJournal records the file mutations the guest makes on managed mounts
+// (WithDirMount and /tmp), retrievable with Dash.Journal. Mounts passed
+// with WithFSConfig are not journaled.
+func WithJournal() Option {
+	return func(o *options) {
+		o.journal = true
+	}
+}
+
+// Journal returns the file mutations recorded since the shell was created
+// or the journal was last reset, oldest first. Returns nil unless
+// WithJournal was given.
+func (d *Dash) Journal() []JournalEntry {
+	if d.state.journal == nil || d.state.journal.discard {
+		return nil
+	}
+	return d.state.journal.snapshot()
+}
+
+// ResetJournal discards the recorded file mutations.
+func (d *Dash) ResetJournal() {
+	if d.state.journal != nil {
+		d.state.journal.reset()
+	}
+}
+
+// journal is the list of recorded file mutations.
+type journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+	// discard is set without WithJournal, when entries are only
+	// published as EventFSWrite.
+	discard bool
+	state   *dashState
+}
+
+// add appends e, stamping its time, and publishes it.
+func (j *journal) add(e JournalEntry) {
+	e.Time = time.Now()
+	if !j.discard {
+		j.mu.Lock()
+		j.entries = append(j.entries, e)
+		j.mu.Unlock()
+	}
+	if j.state != nil {
+		j.state.publish(Event{Kind: EventFSWrite, File: e})
+	}
+}
+
+// snapshot returns a copy of the entries.
+func (j *journal) snapshot() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// reset discards the entries.
+func (j *journal) reset() {
+	j.mu.Lock()
+	j.entries = nil
+	j.mu.Unlock()
+}
+
+// journalFS records the mutations made through a managed mount.
+type journalFS struct {
+	experimentalsys.FS
+	guest   string
+	journal *journal
+}
+
+// guestPath returns the guest path of p relative to the mount.
+func (f *journalFS) guestPath(p string) string {
+	return path.Join(f.guest, p)
+}
+
+// fileState returns whether p exists, its size and its hash if small.
+func (f *journalFS) fileState(p string) (exists bool, size int64, hash string) {
+	st, errno := f.FS.Stat(p)
+	if errno != 0 {
+		return false, 0, ""
+	}
+	if !st.Mode.IsRegular() || st.Size > JournalHashMaxBytes {
+		return true, st.Size, ""
+	}
+	file, errno := f.FS.OpenFile(p, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return true, st.Size, ""
+	}
+	defer file.Close()
+	h := sha256.New()
+	buf := make([]byte, 4096)
+	for {
+		n, errno := file.Read(buf)
+		h.Write(buf[:n])
+		if errno != 0 {
+			return true, st.Size, ""
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return true, st.Size, hex.EncodeToString(h.Sum(nil))
+}
+
+// OpenFile implements experimentalsys.FS.
+func (f *journalFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	const write = experimentalsys.O_WRONLY | experimentalsys.O_RDWR | experimentalsys.O_APPEND | experimentalsys.O_CREAT | experimentalsys.O_TRUNC
+	if flag&write == 0 {
+		return f.FS.OpenFile(p, flag, perm)
+	}
+	existed, _, before := f.fileState(p)
+	file, errno := f.FS.OpenFile(p, flag, perm)
+	if errno != 0 {
+		return nil, errno
+	}
+	if !existed {
+		// Recorded now: the guest may never close the file.
+		_, _, before = f.fileState(p)
+		f.journal.add(JournalEntry{Op: JournalCreate, Path: f.guestPath(p), AfterHash: before})
+	}
+	jf := &journalFile{File: file, fs: f, path: p, before: before}
+	jf.changed = existed && flag&experimentalsys.O_TRUNC != 0
+	return jf, 0
+}
+
+// Mkdir implements experimentalsys.FS.
+func (f *journalFS) Mkdir(p string, perm fs.FileMode) experimentalsys.Errno {
+	errno := f.FS.Mkdir(p, perm)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalMkdir, Path: f.guestPath(p)})
+	}
+	return errno
+}
+
+// Chmod implements experimentalsys.FS.
+func (f *journalFS) Chmod(p string, perm fs.FileMode) experimentalsys.Errno {
+	errno := f.FS.Chmod(p, perm)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalChmod, Path: f.guestPath(p)})
+	}
+	return errno
+}
+
+// Rename implements experimentalsys.FS.
+func (f *journalFS) Rename(from, to string) experimentalsys.Errno {
+	errno := f.FS.Rename(from, to)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalRename, Path: f.guestPath(from), Target: f.guestPath(to)})
+	}
+	return errno
+}
+
+// Rmdir implements experimentalsys.FS.
+func (f *journalFS) Rmdir(p string) experimentalsys.Errno {
+	errno := f.FS.Rmdir(p)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalRmdir, Path: f.guestPath(p)})
+	}
+	return errno
+}
+
+// Unlink implements experimentalsys.FS.
+func (f *journalFS) Unlink(p string) experimentalsys.Errno {
+	_, size, before := f.fileState(p)
+	errno := f.FS.Unlink(p)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalRemove, Path: f.guestPath(p), Size: size, BeforeHash: before})
+	}
+	return errno
+}
+
+// Link implements experimentalsys.FS.
+func (f *journalFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	errno := f.FS.Link(oldPath, newPath)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalLink, Path: f.guestPath(oldPath), Target: f.guestPath(newPath)})
+	}
+	return errno
+}
+
+// Symlink implements experimentalsys.FS.
+func (f *journalFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	errno := f.FS.Symlink(oldPath, linkName)
+	if errno == 0 {
+		f.journal.add(JournalEntry{Op: JournalSymlink, Path: f.guestPath(linkName), Target: oldPath})
+	}
+	return errno
+}
+
+// journalFile records the writes to a file opened for writing, producing
+// one journal entry when it is closed.
+type journalFile struct {
+	experimentalsys.File
+	fs      *journalFS
+	path    string
+	before  string
+	written int64
+	changed bool
+}
+
+// Write implements experimentalsys.File.
+func (f *journalFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	n, errno := f.File.Write(buf)
+	f.record(n)
+	return n, errno
+}
+
+// Pwrite implements experimentalsys.File.
+func (f *journalFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	n, errno := f.File.Pwrite(buf, off)
+	f.record(n)
+	return n, errno
+}
+
+// Truncate implements experimentalsys.File.
+func (f *journalFile) Truncate(size int64) experimentalsys.Errno {
+	errno := f.File.Truncate(size)
+	if errno == 0 {
+		f.changed = true
+	}
+	return errno
+}
+
+// record counts n written bytes.
+func (f *journalFile) record(n int) {
+	if n > 0 {
+		f.written += int64(n)
+		f.changed = true
+	}
+}
+
+// Close implements experimentalsys.File.
+func (f *journalFile) Close() experimentalsys.Errno {
+	errno := f.File.Close()
+	if !f.changed {
+		return errno
+	}
+	f.changed = false
+	_, size, after := f.fs.fileState(f.path)
+	f.fs.journal.add(JournalEntry{
+		Op:         JournalWrite,
+		Path:       f.fs.guestPath(f.path),
+		Written:    f.written,
+		Size:       size,
+		BeforeHash: f.before,
+		AfterHash:  after,
+	})
+	return errno
+}
+
+// _ is a type assertion
+var (
+	_ experimentalsys.FS   = (*journalFS)(nil)
+	_ experimentalsys.File = (*journalFile)(nil)
+)
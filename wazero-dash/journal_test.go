@@ -0,0 +1,101 @@
+package dash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func TestJournalFS(t *testing.T) {
+	j := &journal{}
+	fsys := &journalFS{FS: newMemFS(0), guest: "/tmp", journal: j}
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	write := func(flag experimentalsys.Oflag, data string) {
+		t.Helper()
+		f, errno := fsys.OpenFile("a", flag, 0o600)
+		if errno != 0 {
+			t.Fatal("OpenFile:", errno)
+		}
+		if data != "" {
+			if _, errno := f.Write([]byte(data)); errno != 0 {
+				t.Fatal("Write:", errno)
+			}
+		}
+		if errno := f.Close(); errno != 0 {
+			t.Fatal("Close:", errno)
+		}
+	}
+
+	write(experimentalsys.O_WRONLY|experimentalsys.O_CREAT, "hello")
+	write(experimentalsys.O_WRONLY|experimentalsys.O_APPEND, " world")
+	write(experimentalsys.O_WRONLY, "")
+	if errno := fsys.Rename("a", "b"); errno != 0 {
+		t.Fatal("Rename:", errno)
+	}
+	if errno := fsys.Unlink("b"); errno != 0 {
+		t.Fatal("Unlink:", errno)
+	}
+
+	want := []JournalEntry{
+		{Op: JournalCreate, Path: "/tmp/a", AfterHash: hash("")},
+		{Op: JournalWrite, Path: "/tmp/a", Written: 5, Size: 5, BeforeHash: hash(""), AfterHash: hash("hello")},
+		{Op: JournalWrite, Path: "/tmp/a", Written: 6, Size: 11, BeforeHash: hash("hello"), AfterHash: hash("hello world")},
+		{Op: JournalRename, Path: "/tmp/a", Target: "/tmp/b"},
+		{Op: JournalRemove, Path: "/tmp/b", Size: 11, BeforeHash: hash("hello world")},
+	}
+	got := j.snapshot()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), got)
+	}
+	for i, e := range got {
+		if e.Time.IsZero() {
+			t.Fatalf("entry %d has no time", i)
+		}
+		e.Time = want[i].Time
+		if e != want[i] {
+			t.Fatalf("entry %d: expected %+v, got %+v", i, want[i], e)
+		}
+	}
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	d, err := NewDash(ctx, WithRuntime(r), WithJournal())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	if _, err := d.Eval(ctx, "true >/tmp/a; chmod 644 /tmp/a; mktemp -d"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	var ops []JournalOp
+	for _, e := range d.Journal() {
+		ops = append(ops, e.Op)
+	}
+	want := []JournalOp{JournalCreate, JournalChmod, JournalMkdir}
+	if len(ops) != len(want) || ops[0] != want[0] || ops[1] != want[1] || ops[2] != want[2] {
+		t.Fatalf("expected ops %v, got %v", want, ops)
+	}
+	if e := d.Journal()[0]; e.Path != "/tmp/a" || e.AfterHash == "" {
+		t.Fatalf("unexpected create entry %+v", e)
+	}
+
+	d.ResetJournal()
+	if entries := d.Journal(); len(entries) != 0 {
+		t.Fatalf("expected an empty journal, got %+v", entries)
+	}
+}
@@ -0,0 +1,416 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// WithJSONCommand adds the json command, which reads and edits JSON
+// documents without an external tool such as jq:
+//
+//	json get [-v name] (-f file | doc) path        print the value at path
+//	json set [-v name] [-s] (-f file [-i] | doc) path value
+//	                                               set the value at path
+//	json fmt [-v name] [-c] (-f file | doc)        indent, or compact with -c
+//
+// The document is the doc argument or, with -f, the file, on a mount
+// managed by Dash. -v assigns the result to the variable name instead of
+// printing it, since the reactor has no command substitution. Strings are
+// printed as is by get, other values as compact JSON.
+//
+// Paths select object members with .key and array elements with [index],
+// as in .items[0].name; "." is the whole document. set creates missing
+// object members and appends to an array at its length. Its value is JSON,
+// or a string with -s; -i writes the result back to the file.
+//
+// Documents keep the order of object members. The command fails with
+// status 1 for a path not found and 2 for invalid usage or JSON.
+func WithJSONCommand() Option {
+	return func(o *options) {
+		o.jsonCommand = true
+	}
+}
+
+// jsonObject is a JSON object keeping the order of its members.
+type jsonObject struct {
+	keys []string
+	vals map[string]any
+}
+
+// jsonStep is an element of a json command path: an object member, or an
+// array element if key is empty.
+type jsonStep struct {
+	key   string
+	index int
+}
+
+// errJSONPathNotFound is returned by jsonGet for a path not in the document.
+var errJSONPathNotFound = errors.New("path not found")
+
+// jsonCommand implements the json command added by WithJSONCommand.
+func jsonCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "json"
+	fail := func(status int, msg string) int {
+		commandError(ctx, d.mod, d.state, name, msg)
+		return status
+	}
+	const usage = "usage: json get|set|fmt [-v name] [-c] [-s] [-f file [-i]] [doc] [path [value]]"
+	if len(argv) < 2 {
+		return fail(2, usage)
+	}
+	op, args := argv[1], argv[2:]
+	var varName, file string
+	var compact, asString, inPlace bool
+	for len(args) != 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+		switch opt {
+		case "-v", "-f":
+			if len(args) == 0 {
+				return fail(2, "option requires an argument -- "+opt[1:])
+			}
+			if opt == "-v" {
+				varName = args[0]
+			} else {
+				file = args[0]
+			}
+			args = args[1:]
+		case "-c":
+			compact = true
+		case "-s":
+			asString = true
+		case "-i":
+			inPlace = true
+		default:
+			return fail(2, usage)
+		}
+	}
+	if varName != "" && !isShellName(varName) {
+		return fail(2, varName+": bad variable name")
+	}
+	if inPlace && (file == "" || op != "set" || varName != "") {
+		return fail(2, "-i requires set and -f, without -v")
+	}
+
+	var src []byte
+	if file != "" {
+		data, err := d.ReadFile(d.guestPath(ctx, file))
+		if err != nil {
+			return fail(2, file+": "+errorMessage(err))
+		}
+		src = data
+	} else if len(args) != 0 {
+		src, args = []byte(args[0]), args[1:]
+	} else {
+		return fail(2, usage)
+	}
+	doc, err := decodeJSON(src)
+	if err != nil {
+		return fail(2, "invalid JSON: "+err.Error())
+	}
+
+	var out []byte
+	switch {
+	case op == "get" && len(args) == 1:
+		steps, err := parseJSONPath(args[0])
+		if err != nil {
+			return fail(2, err.Error())
+		}
+		v, err := jsonGet(doc, steps)
+		if err != nil {
+			return fail(1, err.Error()+": "+args[0])
+		}
+		if s, ok := v.(string); ok {
+			out = []byte(s)
+		} else {
+			out = encodeJSON(nil, v)
+		}
+	case op == "set" && len(args) == 2:
+		steps, err := parseJSONPath(args[0])
+		if err != nil {
+			return fail(2, err.Error())
+		}
+		var v any = args[1]
+		if !asString {
+			if v, err = decodeJSON([]byte(args[1])); err != nil {
+				return fail(2, "invalid JSON value: "+err.Error())
+			}
+		}
+		if doc, err = jsonSet(doc, steps, v); err != nil {
+			return fail(1, err.Error()+": "+args[0])
+		}
+		out = encodeJSON(nil, doc)
+	case op == "fmt" && len(args) == 0:
+		out = encodeJSON(nil, doc)
+		if !compact {
+			var buf bytes.Buffer
+			_ = json.Indent(&buf, out, "", "  ")
+			out = buf.Bytes()
+		}
+	default:
+		return fail(2, usage)
+	}
+
+	switch {
+	case inPlace:
+		if err := d.writeFile(d.guestPath(ctx, file), append(out, '\n')); err != nil {
+			return fail(1, file+": "+errorMessage(err))
+		}
+	case varName != "":
+		if err := d.SetVar(ctx, varName, string(out)); err != nil {
+			return fail(1, err.Error())
+		}
+	default:
+		if err := guestWrite(ctx, d.mod, d.state, fdStdout, append(out, '\n')); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+// errorMessage returns the message of err without the path of an
+// fs.PathError, which the json command reports itself.
+func errorMessage(err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err.Error()
+	}
+	return err.Error()
+}
+
+// parseJSONPath parses a json command path such as .items[0].name.
+func parseJSONPath(p string) ([]jsonStep, error) {
+	invalid := errors.New("invalid path: " + p)
+	if p == "." {
+		return nil, nil
+	}
+	rest := strings.TrimPrefix(p, ".")
+	var steps []jsonStep
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, invalid
+			}
+			steps = append(steps, jsonStep{index: i})
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, invalid
+		}
+		steps = append(steps, jsonStep{key: rest[:end]})
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			if rest = rest[1:]; rest == "" {
+				return nil, invalid
+			}
+		}
+	}
+	return steps, nil
+}
+
+// jsonGet returns the value at the path steps in v.
+func jsonGet(v any, steps []jsonStep) (any, error) {
+	for _, s := range steps {
+		switch c := v.(type) {
+		case *jsonObject:
+			val, ok := c.vals[s.key]
+			if s.key == "" || !ok {
+				return nil, errJSONPathNotFound
+			}
+			v = val
+		case []any:
+			if s.key != "" || s.index >= len(c) {
+				return nil, errJSONPathNotFound
+			}
+			v = c[s.index]
+		default:
+			return nil, errJSONPathNotFound
+		}
+	}
+	return v, nil
+}
+
+// jsonSet returns v with the value at the path steps set to val.
+func jsonSet(v any, steps []jsonStep, val any) (any, error) {
+	if len(steps) == 0 {
+		return val, nil
+	}
+	s := steps[0]
+	switch c := v.(type) {
+	case *jsonObject:
+		if s.key == "" {
+			return nil, errors.New("not an array")
+		}
+		old, ok := c.vals[s.key]
+		if !ok && len(steps) > 1 {
+			old = newJSONContainer(steps[1])
+		}
+		next, err := jsonSet(old, steps[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			c.keys = append(c.keys, s.key)
+		}
+		c.vals[s.key] = next
+		return c, nil
+	case []any:
+		if s.key != "" {
+			return nil, errors.New("not an object")
+		}
+		if s.index > len(c) {
+			return nil, errors.New("index out of range")
+		}
+		var old any
+		if s.index < len(c) {
+			old = c[s.index]
+		} else if len(steps) > 1 {
+			old = newJSONContainer(steps[1])
+		}
+		next, err := jsonSet(old, steps[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		if s.index == len(c) {
+			return append(c, next), nil
+		}
+		c[s.index] = next
+		return c, nil
+	default:
+		if s.key == "" {
+			return nil, errors.New("not an array")
+		}
+		return nil, errors.New("not an object")
+	}
+}
+
+// newJSONContainer returns the empty object or array created by jsonSet
+// for a missing value the path continues into with next.
+func newJSONContainer(next jsonStep) any {
+	if next.key == "" {
+		return []any{}
+	}
+	return &jsonObject{vals: map[string]any{}}
+}
+
+// decodeJSON decodes a single JSON value, keeping the order of object
+// members and the text of numbers.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("data after the value")
+	}
+	return v, nil
+}
+
+// decodeJSONValue decodes the next value from dec.
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{vals: map[string]any{}}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			val, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := obj.vals[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.vals[key] = val
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// encodeJSON appends the compact JSON encoding of v to buf.
+func encodeJSON(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case *jsonObject:
+		buf = append(buf, '{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = encodeJSONString(buf, k)
+			buf = append(buf, ':')
+			buf = encodeJSON(buf, v.vals[k])
+		}
+		return append(buf, '}')
+	case []any:
+		buf = append(buf, '[')
+		for i, e := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = encodeJSON(buf, e)
+		}
+		return append(buf, ']')
+	case string:
+		return encodeJSONString(buf, v)
+	case json.Number:
+		return append(buf, v...)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	default:
+		return append(buf, "null"...)
+	}
+}
+
+// encodeJSONString appends s as a JSON string to buf, leaving HTML
+// characters unescaped.
+func encodeJSONString(buf []byte, s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return append(buf, bytes.TrimSuffix(b.Bytes(), []byte("\n"))...)
+}
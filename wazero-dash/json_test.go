@@ -0,0 +1,88 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestJSONCommand(t *testing.T) {
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithJSONCommand())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	const doc = `{"name": "app", "tags": ["a", "b"], "meta": {"n": 1.50, "html": "<b>"}}`
+	if err := d.SetVar(ctx, "doc", doc); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+
+	for _, tc := range []struct {
+		script, stdout string
+		status         int
+	}{
+		{`json get "$doc" .name`, "app\n", 0},
+		{`json get "$doc" .tags[1]`, "b\n", 0},
+		{`json get "$doc" .meta`, `{"n":1.50,"html":"<b>"}` + "\n", 0},
+		{`json get "$doc" .`, `{"name":"app","tags":["a","b"],"meta":{"n":1.50,"html":"<b>"}}` + "\n", 0},
+		{`json get -v v "$doc" .meta.n; echo "$v"`, "1.50\n", 0},
+		{`json get "$doc" .missing`, "", 1},
+		{`json get "$doc" .tags[2]`, "", 1},
+		{`json get "$doc" .name.x`, "", 1},
+		{`json set "$doc" .tags[2] '"c"'`, `{"name":"app","tags":["a","b","c"],"meta":{"n":1.50,"html":"<b>"}}` + "\n", 0},
+		{`json set -s '{}' .a.b[0].c 'it''s'`, `{"a":{"b":[{"c":"its"}]}}` + "\n", 0},
+		{`json set '{"z":1,"a":2}' .z null`, `{"z":null,"a":2}` + "\n", 0},
+		{`json set '[1]' .x 1`, "", 1},
+		{`json set '{}' .a '{bad'`, "", 2},
+		{`json fmt -c '{ "b" : [ 1 , true ] , "a" : {} }'`, `{"b":[1,true],"a":{}}` + "\n", 0},
+		{`json fmt '{"b":[1],"a":{}}'`, "{\n  \"b\": [\n    1\n  ],\n  \"a\": {}\n}\n", 0},
+		{`json get '{"a":' .a`, "", 2},
+		{`json get '{} []' .`, "", 2},
+		{`json get "$doc" '.tags[x]'`, "", 2},
+		{`json frob "$doc"`, "", 2},
+		{`json get -v 1x "$doc" .name`, "", 2},
+	} {
+		stdout.Reset()
+		stderr.Reset()
+		status, err := d.Eval(ctx, tc.script)
+		if err != nil || status != tc.status || stdout.String() != tc.stdout {
+			t.Errorf("%s: got %d, %q, %v; want %d, %q (stderr %q)", tc.script, status, stdout.String(), err, tc.status, tc.stdout, stderr.String())
+		}
+		if status != 0 && !strings.HasPrefix(stderr.String(), "json: ") {
+			t.Errorf("%s: expected a json error message, got %q", tc.script, stderr.String())
+		}
+	}
+
+	// Files are edited in place.
+	if err := d.writeFile("/tmp/app.json", []byte(doc)); err != nil {
+		t.Fatal("writeFile:", err)
+	}
+	stdout.Reset()
+	if _, err := d.Eval(ctx, `cd /tmp && json set -f app.json -i .meta.n 2 && json get -f /tmp/app.json .meta.n`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if stdout.String() != "2\n" {
+		t.Fatalf("got %q, stderr %q", stdout.String(), stderr.String())
+	}
+	data, err := d.ReadFile("/tmp/app.json")
+	if want := `{"name":"app","tags":["a","b"],"meta":{"n":2,"html":"<b>"}}` + "\n"; err != nil || string(data) != want {
+		t.Fatalf("ReadFile = %q, %v; want %q", data, err, want)
+	}
+	stderr.Reset()
+	if status, _ := d.Eval(ctx, `json get -f /tmp/missing.json .`); status != 2 || !strings.Contains(stderr.String(), "missing.json") {
+		t.Fatalf("expected a missing file error, got %d, %q", status, stderr.String())
+	}
+}
+
+func TestJSONCommandOptIn(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	if status, _ := d.Eval(context.Background(), `json fmt '{}'`); status != 127 {
+		t.Fatalf("expected json to be unavailable by default, got status %d", status)
+	}
+}
@@ -0,0 +1,173 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// ErrSizeLimitExceeded is returned by Eval when a command hit one of the
+// configured SizeLimits. The shell remains usable.
+var ErrSizeLimitExceeded = errors.New("dash: size limit exceeded")
+
+// SizeLimits caps the size of data a script can materialize.
+//
+// Zero values disable the corresponding limit.
+type SizeLimits struct {
+	// MaxMemoryBytes caps the size of the guest linear memory.
+	//
+	// Dash builds expansion results ($(...), $x$x, arithmetic) and
+	// here-documents in linear memory. When growing memory past this cap
+	// would be required, the evaluation is aborted and the shell is rolled
+	// back to its state before the Eval call, instead of exhausting host
+	// memory. Enabling this saves linear memory once per Eval, copying the
+	// pages changed since the previous one.
+	MaxMemoryBytes uint64
+
+	// MaxArgBytes caps the total length of the argument list passed to a
+	// host-dispatched command, counting a terminating NUL per argument.
+	// Commands over the limit fail with exit status 126. Builtins and
+	// functions run in the guest and are not checked.
+	MaxArgBytes int
+
+	// MaxExpansionBytes caps the length of a single expansion result: the
+	// word built by expanding variables, substitutions and arithmetic,
+	// such as x=$x$x, including a here-document body once expanded.
+	// Commands growing one past the limit fail with a "expansion result
+	// longer than N bytes" error.
+	//
+	// Dash builds the result in the guest, which checks its size with
+	// the host as it grows, see sizeCheckImport. Reactor builds without
+	// that check fail evaluations with an error wrapping ErrNotAvailable
+	// while the limit is set.
+	MaxExpansionBytes int
+
+	// MaxHereDocBytes caps the length of a here-document body as written
+	// in the script. Scripts with a longer one fail to parse with a
+	// "here-document longer than N bytes" syntax error. It is checked by
+	// the guest like MaxExpansionBytes.
+	MaxHereDocBytes int
+}
+
+// SetSizeLimits configures size caps for subsequent evaluations.
+func (d *Dash) SetSizeLimits(limits SizeLimits) {
+	d.state.sizeLimits = limits
+}
+
+// SizeLimits returns the configured size caps.
+func (d *Dash) SizeLimits() SizeLimits {
+	return d.state.sizeLimits
+}
+
+// The reactor checks the size of the strings dash builds in the guest with
+// a function of the env module, see reactor/src/expand.c and
+// reactor/src/parser.c:
+//
+//	__size_check(kind, size) -> limit
+//
+// It returns 0 if size bytes are within the limit of kind, one of the
+// sizeCheck kinds, or the limit, for the guest to fail the command.
+const sizeCheckImport = "__size_check"
+
+// Kinds of __size_check.
+const (
+	sizeCheckExpansion = 0
+	sizeCheckHereDoc   = 1
+)
+
+// sizeCheckHost implements __size_check.
+func sizeCheckHost(ctx context.Context, kind, size uint32) uint32 {
+	state := hostState(ctx, sizeCheckImport)
+	var limit int
+	switch kind {
+	case sizeCheckExpansion:
+		limit = state.sizeLimits.MaxExpansionBytes
+	case sizeCheckHereDoc:
+		limit = state.sizeLimits.MaxHereDocBytes
+	}
+	if limit <= 0 || int64(size) <= int64(limit) {
+		return 0
+	}
+	state.sizeLimitHit = true
+	return uint32(limit)
+}
+
+// checkSizeLimits returns an error wrapping ErrNotAvailable if a limit
+// checked by the guest is set but the reactor does not import
+// sizeCheckImport.
+func (d *Dash) checkSizeLimits() error {
+	l := d.state.sizeLimits
+	if l.MaxExpansionBytes == 0 && l.MaxHereDocBytes == 0 || importsFunc(d.compiled, "env", sizeCheckImport) {
+		return nil
+	}
+	return fmt.Errorf("dash: MaxExpansionBytes and MaxHereDocBytes need the %s import: %w", sizeCheckImport, ErrNotAvailable)
+}
+
+// withMemoryLimit installs a memory allocator enforcing MaxMemoryBytes.
+func withMemoryLimit(ctx context.Context, state *dashState) context.Context {
+	return experimental.WithMemoryAllocator(ctx, experimental.MemoryAllocatorFunc(func(cap, max uint64) experimental.LinearMemory {
+		return &limitedMemory{state: state, buf: make([]byte, 0, cap)}
+	}))
+}
+
+// limitedMemory is a LinearMemory that refuses to grow past the state's
+// MaxMemoryBytes.
+type limitedMemory struct {
+	state *dashState
+	buf   []byte
+}
+
+// Reallocate implements experimental.LinearMemory.
+func (m *limitedMemory) Reallocate(size uint64) []byte {
+	m.state.checkMemoryGrowth(size)
+	if limit := m.state.sizeLimits.MaxMemoryBytes; limit != 0 && size > limit {
+		// Failing the grow leaves dash in its out-of-memory path, which is
+		// not reliable inside the reactor. Abort the call instead: Eval
+		// recovers by restoring its pre-call memory snapshot.
+		m.state.sizeLimitHit = true
+		panic(ErrSizeLimitExceeded)
+	}
+	if size <= uint64(cap(m.buf)) {
+		m.buf = m.buf[:size]
+		return m.buf
+	}
+	// Doubling amortizes the copies, but must not reserve host memory past
+	// the limit.
+	newCap := max(size, 2*uint64(cap(m.buf)))
+	if limit := m.state.sizeLimits.MaxMemoryBytes; limit != 0 {
+		newCap = min(newCap, limit)
+	}
+	grown := make([]byte, size, newCap)
+	copy(grown, m.buf)
+	m.buf = grown
+	return m.buf
+}
+
+// Free implements experimental.LinearMemory.
+//
+// The buffer is left to the garbage collector: Terminate closes the module
+// while guest code may still be running on it.
+func (m *limitedMemory) Free() {}
+
+// checkArgBytes enforces MaxArgBytes for a host-dispatched command.
+// Returns false after reporting the error on stderr if argv is too long.
+func checkArgBytes(ctx context.Context, mod api.Module, state *dashState, argv []string) bool {
+	limit := state.sizeLimits.MaxArgBytes
+	if limit == 0 {
+		return true
+	}
+	var total int
+	for _, arg := range argv {
+		total += len(arg) + 1
+	}
+	if total <= limit {
+		return true
+	}
+	state.sizeLimitHit = true
+	_ = guestWrite(ctx, mod, state, fdStderr, []byte(argv[0]+": argument list too long ("+strconv.Itoa(total)+" > "+strconv.Itoa(limit)+" bytes)\n"))
+	return false
+}
@@ -0,0 +1,118 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitedMemoryGrowth(t *testing.T) {
+	state := &dashState{sizeLimits: SizeLimits{MaxMemoryBytes: 100}}
+	m := &limitedMemory{state: state}
+	for _, size := range []uint64{10, 60, 100} {
+		if buf := m.Reallocate(size); len(buf) != int(size) || cap(buf) > 100 {
+			t.Fatalf("Reallocate(%d): length %d, capacity %d over the limit", size, len(buf), cap(buf))
+		}
+	}
+	if cap(m.Reallocate(20)) != 100 {
+		t.Fatal("shrinking reallocated the buffer")
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+
+	d.SetSizeLimits(SizeLimits{
+		MaxMemoryBytes: 4 << 20,
+		MaxArgBytes:    64,
+	})
+
+	// Unbounded expansion growth fails cleanly.
+	status, err := d.Eval(ctx, "x=aaaaaaaaaaaaaaaa; while :; do x=$x$x; done")
+	if !errors.Is(err, ErrSizeLimitExceeded) {
+		t.Fatalf("expected ErrSizeLimitExceeded, got status %d err %v", status, err)
+	}
+	if status == 0 {
+		t.Fatal("expected non-zero exit status")
+	}
+
+	// Oversized argument lists are rejected before dispatch.
+	var called bool
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		called = true
+		return 0
+	})
+	stderr.Reset()
+	status, err = d.Eval(ctx, "cmd "+strings.Repeat("y", 100))
+	if !errors.Is(err, ErrSizeLimitExceeded) {
+		t.Fatalf("expected ErrSizeLimitExceeded, got %v", err)
+	}
+	if status != 126 || called {
+		t.Fatalf("expected status 126 without dispatch, got %d (called=%v)", status, called)
+	}
+	if !strings.Contains(stderr.String(), "argument list too long") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	// The shell remains usable.
+	stdout.Reset()
+	status, err = d.Eval(ctx, "echo ok")
+	if err != nil || status != 0 {
+		t.Fatalf("Eval after limit: status %d err %v", status, err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "ok" {
+		t.Fatalf("expected 'ok', got %q", got)
+	}
+}
+
+func TestSizeCheckHost(t *testing.T) {
+	state := &dashState{sizeLimits: SizeLimits{MaxExpansionBytes: 10, MaxHereDocBytes: 20}}
+	ctx := withDashState(context.Background(), state)
+	if limit := sizeCheckHost(ctx, sizeCheckExpansion, 10); limit != 0 || state.sizeLimitHit {
+		t.Fatalf("expansion at the limit: %d, hit %v", limit, state.sizeLimitHit)
+	}
+	if limit := sizeCheckHost(ctx, sizeCheckHereDoc, 21); limit != 20 || !state.sizeLimitHit {
+		t.Fatalf("here-document over the limit: %d, hit %v", limit, state.sizeLimitHit)
+	}
+	state.sizeLimits = SizeLimits{}
+	if limit := sizeCheckHost(ctx, sizeCheckExpansion, 1<<30); limit != 0 {
+		t.Fatalf("disabled limit: %d", limit)
+	}
+}
+
+func TestExpansionLimits(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+	d.SetSizeLimits(SizeLimits{MaxExpansionBytes: 1024, MaxHereDocBytes: 64})
+	if !importsFunc(d.compiled, "env", sizeCheckImport) {
+		if _, err := d.Eval(ctx, "echo ok"); !errors.Is(err, ErrNotAvailable) {
+			t.Fatalf("expected ErrNotAvailable, got %v", err)
+		}
+		t.Skip("module does not import " + sizeCheckImport)
+	}
+
+	status, err := d.Eval(ctx, "x=aaaaaaaaaaaaaaaa; while :; do x=$x$x; done")
+	if !errors.Is(err, ErrSizeLimitExceeded) || status == 0 {
+		t.Fatalf("expected ErrSizeLimitExceeded, got status %d err %v", status, err)
+	}
+	if !strings.Contains(stderr.String(), "expansion result longer than 1024 bytes") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	stderr.Reset()
+	status, err = d.Eval(ctx, "cat <<EOF\n"+strings.Repeat("y", 100)+"\nEOF")
+	if !errors.Is(err, ErrSizeLimitExceeded) || status != 2 {
+		t.Fatalf("expected ErrSizeLimitExceeded, got status %d err %v", status, err)
+	}
+	if !strings.Contains(stderr.String(), "here-document longer than 64 bytes") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	// The shell remains usable.
+	stdout.Reset()
+	if status, err := d.Eval(ctx, "echo ok"); err != nil || status != 0 || stdout.String() != "ok\n" {
+		t.Fatalf("Eval after limit: status %d err %v stdout %q", status, err, stdout.String())
+	}
+}
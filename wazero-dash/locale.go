@@ -0,0 +1,99 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrUnsupportedLocale is wrapped by the errors returned for locales other
+// than C, POSIX and UTF-8 locales.
+var ErrUnsupportedLocale = errors.New("dash: unsupported locale")
+
+// Locale is a locale recognized by the shell.
+//
+// Dash processes text as bytes whatever the locale: ${#var} counts bytes,
+// ? and bracket expressions in patterns match single bytes, character
+// classes such as [:alpha:] match ASCII characters only, and globs and the
+// host sort by byte value. UTF-8 locales collate like C.UTF-8, by code
+// point, which orders UTF-8 text like its bytes, so sorting is the same in
+// every locale. The locale tells host code how to decode the output of
+// scripts; see the README for the behavior matrix.
+type Locale struct {
+	// Name is the locale name, such as "C" or "en_US.UTF-8".
+	Name string
+	// UTF8 is set for UTF-8 locales, and unset for C and POSIX.
+	UTF8 bool
+}
+
+// LocaleC is the C locale, used when no locale variable is set.
+var LocaleC = Locale{Name: "C"}
+
+// ParseLocale parses a locale name: C, POSIX, or
+// language[_territory].codeset[@modifier] with the UTF-8 codeset, spelled
+// UTF-8 or utf8 in any case. An empty name is the C locale. Other codesets
+// return an error wrapping ErrUnsupportedLocale.
+func ParseLocale(name string) (Locale, error) {
+	switch name {
+	case "":
+		return LocaleC, nil
+	case "C", "POSIX":
+		return Locale{Name: name}, nil
+	}
+	base, _, _ := strings.Cut(name, "@")
+	lang, codeset, ok := strings.Cut(base, ".")
+	if ok && lang != "" && (strings.EqualFold(codeset, "UTF-8") || strings.EqualFold(codeset, "utf8")) {
+		return Locale{Name: name, UTF8: true}, nil
+	}
+	return Locale{}, &LocaleError{Name: name}
+}
+
+// LocaleError reports a locale ParseLocale does not support.
+type LocaleError struct {
+	Name string
+}
+
+// Error implements error.
+func (e *LocaleError) Error() string {
+	return ErrUnsupportedLocale.Error() + ": " + e.Name
+}
+
+// Unwrap returns ErrUnsupportedLocale.
+func (e *LocaleError) Unwrap() error {
+	return ErrUnsupportedLocale
+}
+
+// WithLocale sets LANG to the locale name in the guest environment.
+// LC_ALL and LC_CTYPE given with WithEnv take precedence, as in POSIX.
+// NewDash fails if ParseLocale rejects the name.
+func WithLocale(name string) Option {
+	return func(o *options) {
+		o.locale = &name
+	}
+}
+
+// localeVars lists the variables selecting the character type locale, by
+// precedence.
+var localeVars = []string{"LC_ALL", "LC_CTYPE", "LANG"}
+
+// Locale returns the character type locale selected by the shell
+// variables LC_ALL, LC_CTYPE and LANG, the first set and non-empty taking
+// precedence. Unsupported locales yield LocaleC, as setlocale falls back to
+// it.
+func (d *Dash) Locale(ctx context.Context) (Locale, error) {
+	for _, name := range localeVars {
+		value, err := d.GetVar(ctx, name)
+		if err != nil {
+			return Locale{}, err
+		}
+		if value == "" {
+			continue
+		}
+		l, err := ParseLocale(value)
+		if err != nil {
+			return LocaleC, nil
+		}
+		return l, nil
+	}
+	return LocaleC, nil
+}
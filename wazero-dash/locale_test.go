@@ -0,0 +1,97 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseLocale(t *testing.T) {
+	for name, want := range map[string]Locale{
+		"":                  LocaleC,
+		"POSIX":             {Name: "POSIX"},
+		"C.UTF-8":           {Name: "C.UTF-8", UTF8: true},
+		"en_US.utf8":        {Name: "en_US.utf8", UTF8: true},
+		"sr_RS.UTF-8@latin": {Name: "sr_RS.UTF-8@latin", UTF8: true},
+	} {
+		got, err := ParseLocale(name)
+		if err != nil || got != want {
+			t.Errorf("ParseLocale(%q): expected %v, got %v, %v", name, want, got, err)
+		}
+	}
+	for _, name := range []string{"en_US", "de_DE.ISO-8859-1", ".UTF-8"} {
+		if _, err := ParseLocale(name); !errors.Is(err, ErrUnsupportedLocale) {
+			t.Errorf("ParseLocale(%q): expected ErrUnsupportedLocale, got %v", name, err)
+		}
+	}
+}
+
+// TestLocaleBehavior checks the behavior matrix documented in the README:
+// text is processed as bytes in every locale.
+func TestLocaleBehavior(t *testing.T) {
+	ctx := context.Background()
+	for _, locale := range []string{"C", "C.UTF-8"} {
+		d, err := NewDash(ctx, WithLocale(locale))
+		if err != nil {
+			t.Fatal("NewDash:", err)
+		}
+		defer d.Close(ctx)
+		if err := d.Init(ctx, nil); err != nil {
+			t.Fatal("Init:", err)
+		}
+		for script, want := range map[string]string{
+			`x=é; echo ${#x}`:                                           "2\n",
+			`case é in ?) echo 1;; ??) echo 2;; esac`:                   "2\n",
+			`case é in [[:alpha:]]) echo alpha;; *) echo other;; esac`:  "other\n",
+			`case É in [[:upper:]]) echo upper;; *) echo other;; esac`:  "other\n",
+			`for f in b é a Z; do true >/tmp/g_$f; done; echo /tmp/g_*`: "/tmp/g_Z /tmp/g_a /tmp/g_b /tmp/g_é\n",
+		} {
+			stdout, _, _, err := d.EvalCapture(ctx, script)
+			if err != nil {
+				t.Fatal("EvalCapture:", err)
+			}
+			if string(stdout) != want {
+				t.Errorf("%s: %s: expected %q, got %q", locale, script, want, stdout)
+			}
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewDash(ctx, WithLocale("de_DE.ISO-8859-1")); !errors.Is(err, ErrUnsupportedLocale) {
+		t.Fatalf("expected ErrUnsupportedLocale, got %v", err)
+	}
+
+	d, err := NewDash(ctx, WithLocale("en_US.UTF-8"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	// Each step applies on top of the previous ones.
+	for _, step := range []struct {
+		script string
+		want   Locale
+	}{
+		{"", Locale{Name: "en_US.UTF-8", UTF8: true}},
+		{"LC_CTYPE=C", Locale{Name: "C"}},
+		{"LC_ALL=C.utf8", Locale{Name: "C.utf8", UTF8: true}},
+		{"LC_ALL=fr_FR.ISO8859-1", LocaleC},
+		{"unset LC_ALL LC_CTYPE LANG", LocaleC},
+	} {
+		script, want := step.script, step.want
+		if _, err := d.Eval(ctx, script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		got, err := d.Locale(ctx)
+		if err != nil {
+			t.Fatal("Locale:", err)
+		}
+		if got != want {
+			t.Errorf("after %q: expected %v, got %v", script, want, got)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package dash
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// maxSymlinks is the number of symbolic links followed before ELOOP.
+const maxSymlinks = 40
+
+// memFS is an in-memory read-write file system.
+//
+// File contents count against maxBytes, if non-zero. Writes that would
+// exceed it fail with EIO, the closest errno WASI preview1 can report for a
+// full device. memFS is safe for concurrent use.
+type memFS struct {
+	mu       sync.Mutex
+	root     *memNode
+	nextIno  sys.Inode
+	maxBytes int64
+	bytes    int64
+	files    int64
+}
+
+// _ is a type assertion
+var _ experimentalsys.FS = (*memFS)(nil)
+
+// memNode is a file, directory or symbolic link in a memFS.
+type memNode struct {
+	ino      sys.Inode
+	mode     fs.FileMode
+	data     []byte
+	children map[string]*memNode
+	target   string
+
+	// nlink counts directory entries referencing the node, opens counts
+	// open files. The node's storage is released when both reach zero.
+	nlink uint64
+	opens int
+
+	atim, mtim, ctim int64
+}
+
+// newMemFS returns an empty memFS capped at maxBytes of file data.
+func newMemFS(maxBytes int64) *memFS {
+	m := &memFS{maxBytes: maxBytes}
+	m.root = m.newNode(fs.ModeDir | 0o777)
+	return m
+}
+
+// newNode allocates a node. Callers hold mu.
+func (m *memFS) newNode(mode fs.FileMode) *memNode {
+	m.nextIno++
+	now := time.Now().UnixNano()
+	n := &memNode{ino: m.nextIno, mode: mode, nlink: 1, atim: now, mtim: now, ctim: now}
+	if mode.IsDir() {
+		n.children = make(map[string]*memNode)
+		n.nlink = 2
+	}
+	return n
+}
+
+// usage returns the bytes of file data stored and the number of nodes,
+// excluding the root directory.
+func (m *memFS) usage() (bytes, files int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes, m.files
+}
+
+// splitPath splits a path relative to the file system root into names.
+func splitPath(p string) []string {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
+
+// resolve looks up the node at p. Callers hold mu.
+func (m *memFS) resolve(p string, followLast bool) (*memNode, experimentalsys.Errno) {
+	return m.resolveNames(splitPath(p), followLast, 0)
+}
+
+// resolveNames looks up the node at the path given by names, following
+// symbolic links. Absolute link targets resolve against the memFS root.
+func (m *memFS) resolveNames(names []string, followLast bool, links int) (*memNode, experimentalsys.Errno) {
+	node := m.root
+	for i, name := range names {
+		if !node.mode.IsDir() {
+			return nil, experimentalsys.ENOTDIR
+		}
+		child, ok := node.children[name]
+		if !ok {
+			return nil, experimentalsys.ENOENT
+		}
+		last := i == len(names)-1
+		if child.mode&fs.ModeSymlink != 0 && (!last || followLast) {
+			if links == maxSymlinks {
+				return nil, experimentalsys.ELOOP
+			}
+			target := child.target
+			if !strings.HasPrefix(target, "/") {
+				target = path.Join("/"+strings.Join(names[:i], "/"), target)
+			}
+			next := append(splitPath(target), names[i+1:]...)
+			return m.resolveNames(next, followLast, links+1)
+		}
+		node = child
+	}
+	return node, 0
+}
+
+// parent resolves the directory containing p and returns it with the base
+// name of p. Callers hold mu.
+func (m *memFS) parent(p string) (*memNode, string, experimentalsys.Errno) {
+	names := splitPath(p)
+	if len(names) == 0 {
+		return nil, "", experimentalsys.EINVAL
+	}
+	dir, errno := m.resolveNames(names[:len(names)-1], true, 0)
+	if errno != 0 {
+		return nil, "", errno
+	}
+	if !dir.mode.IsDir() {
+		return nil, "", experimentalsys.ENOTDIR
+	}
+	return dir, names[len(names)-1], 0
+}
+
+// link adds node to dir under name. Callers hold mu.
+func (m *memFS) link(dir *memNode, name string, node *memNode) {
+	dir.children[name] = node
+	dir.mtim = time.Now().UnixNano()
+	if node.mode.IsDir() {
+		dir.nlink++
+	}
+}
+
+// unlink removes name from dir, releasing the node if unreferenced.
+// Callers hold mu.
+func (m *memFS) unlink(dir *memNode, name string) {
+	node := dir.children[name]
+	delete(dir.children, name)
+	dir.mtim = time.Now().UnixNano()
+	if node.mode.IsDir() {
+		dir.nlink--
+		node.nlink = 0
+	} else {
+		node.nlink--
+	}
+	node.ctim = dir.mtim
+	m.release(node)
+}
+
+// release frees the storage of a node that is no longer referenced.
+// Callers hold mu.
+func (m *memFS) release(node *memNode) {
+	if node.nlink != 0 || node.opens != 0 {
+		return
+	}
+	m.bytes -= int64(len(node.data))
+	m.files--
+	node.data = nil
+}
+
+// resize sets the length of a regular file's data, enforcing maxBytes.
+// Callers hold mu.
+func (m *memFS) resize(node *memNode, size int64) experimentalsys.Errno {
+	grow := size - int64(len(node.data))
+	if grow > 0 && m.maxBytes != 0 && m.bytes+grow > m.maxBytes {
+		return experimentalsys.EIO
+	}
+	if size <= int64(cap(node.data)) {
+		clear(node.data[min(size, int64(len(node.data))):size])
+		node.data = node.data[:size]
+	} else {
+		data := make([]byte, size, max(size, 2*int64(cap(node.data))))
+		copy(data, node.data)
+		node.data = data
+	}
+	m.bytes += grow
+	return 0
+}
+
+// OpenFile implements experimentalsys.FS.
+func (m *memFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, errno := m.resolve(p, flag&experimentalsys.O_NOFOLLOW == 0)
+	switch {
+	case errno == experimentalsys.ENOENT && flag&experimentalsys.O_CREAT != 0:
+		dir, name, errno := m.parent(p)
+		if errno != 0 {
+			return nil, errno
+		}
+		if _, ok := dir.children[name]; ok {
+			// Dangling symbolic link.
+			return nil, experimentalsys.ENOENT
+		}
+		node = m.newNode(perm & fs.ModePerm)
+		m.files++
+		m.link(dir, name, node)
+	case errno != 0:
+		return nil, errno
+	case flag&(experimentalsys.O_CREAT|experimentalsys.O_EXCL) == experimentalsys.O_CREAT|experimentalsys.O_EXCL:
+		return nil, experimentalsys.EEXIST
+	}
+
+	writable := flag&(experimentalsys.O_RDWR|experimentalsys.O_WRONLY) != 0
+	switch {
+	case node.mode&fs.ModeSymlink != 0:
+		return nil, experimentalsys.ELOOP
+	case flag&experimentalsys.O_DIRECTORY != 0 && !node.mode.IsDir():
+		return nil, experimentalsys.ENOTDIR
+	case node.mode.IsDir() && writable:
+		return nil, experimentalsys.EISDIR
+	}
+	if flag&experimentalsys.O_TRUNC != 0 && writable {
+		_ = m.resize(node, 0)
+		node.mtim = time.Now().UnixNano()
+	}
+
+	node.opens++
+	return &memFile{
+		fs:       m,
+		node:     node,
+		readable: flag&experimentalsys.O_WRONLY == 0,
+		writable: writable,
+		append:   flag&experimentalsys.O_APPEND != 0,
+	}, 0
+}
+
+// Lstat implements experimentalsys.FS.
+func (m *memFS) Lstat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(p, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return node.stat(), 0
+}
+
+// Stat implements experimentalsys.FS.
+func (m *memFS) Stat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(p, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return node.stat(), 0
+}
+
+// Mkdir implements experimentalsys.FS.
+func (m *memFS) Mkdir(p string, perm fs.FileMode) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, name, errno := m.parent(p)
+	if errno == experimentalsys.EINVAL {
+		return experimentalsys.EEXIST
+	} else if errno != 0 {
+		return errno
+	}
+	if _, ok := dir.children[name]; ok {
+		return experimentalsys.EEXIST
+	}
+	m.files++
+	m.link(dir, name, m.newNode(fs.ModeDir|perm&fs.ModePerm))
+	return 0
+}
+
+// Chmod implements experimentalsys.FS.
+func (m *memFS) Chmod(p string, perm fs.FileMode) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(p, true)
+	if errno != 0 {
+		return errno
+	}
+	node.mode = node.mode&fs.ModeType | perm&fs.ModePerm
+	node.ctim = time.Now().UnixNano()
+	return 0
+}
+
+// Rename implements experimentalsys.FS.
+func (m *memFS) Rename(from, to string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fromDir, fromName, errno := m.parent(from)
+	if errno != 0 {
+		return errno
+	}
+	node, ok := fromDir.children[fromName]
+	if !ok {
+		return experimentalsys.ENOENT
+	}
+	toDir, toName, errno := m.parent(to)
+	if errno != 0 {
+		return errno
+	}
+	if node.mode.IsDir() && m.contains(node, toDir) {
+		// Moving a directory into its own subtree.
+		return experimentalsys.EINVAL
+	}
+	if existing, ok := toDir.children[toName]; ok {
+		if existing == node {
+			return 0
+		}
+		switch {
+		case node.mode.IsDir() && !existing.mode.IsDir():
+			return experimentalsys.ENOTDIR
+		case !node.mode.IsDir() && existing.mode.IsDir():
+			return experimentalsys.EISDIR
+		case existing.mode.IsDir() && len(existing.children) != 0:
+			return experimentalsys.ENOTEMPTY
+		}
+		m.unlink(toDir, toName)
+	}
+
+	delete(fromDir.children, fromName)
+	fromDir.mtim = time.Now().UnixNano()
+	if node.mode.IsDir() {
+		fromDir.nlink--
+	}
+	m.link(toDir, toName, node)
+	node.ctim = fromDir.mtim
+	return 0
+}
+
+// contains reports whether dir is node or one of its descendants.
+// Callers hold mu.
+func (m *memFS) contains(node, dir *memNode) bool {
+	if node == dir {
+		return true
+	}
+	for _, child := range node.children {
+		if child.mode.IsDir() && m.contains(child, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rmdir implements experimentalsys.FS.
+func (m *memFS) Rmdir(p string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, name, errno := m.parent(p)
+	if errno != 0 {
+		return errno
+	}
+	node, ok := dir.children[name]
+	switch {
+	case !ok:
+		return experimentalsys.ENOENT
+	case !node.mode.IsDir():
+		return experimentalsys.ENOTDIR
+	case len(node.children) != 0:
+		return experimentalsys.ENOTEMPTY
+	}
+	m.unlink(dir, name)
+	return 0
+}
+
+// Unlink implements experimentalsys.FS.
+func (m *memFS) Unlink(p string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, name, errno := m.parent(p)
+	if errno != 0 {
+		return errno
+	}
+	node, ok := dir.children[name]
+	switch {
+	case !ok:
+		return experimentalsys.ENOENT
+	case node.mode.IsDir():
+		return experimentalsys.EISDIR
+	}
+	m.unlink(dir, name)
+	return 0
+}
+
+// Link implements experimentalsys.FS.
+func (m *memFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(oldPath, false)
+	if errno != 0 {
+		return errno
+	}
+	if node.mode.IsDir() {
+		return experimentalsys.EPERM
+	}
+	dir, name, errno := m.parent(newPath)
+	if errno != 0 {
+		return errno
+	}
+	if _, ok := dir.children[name]; ok {
+		return experimentalsys.EEXIST
+	}
+	node.nlink++
+	node.ctim = time.Now().UnixNano()
+	m.link(dir, name, node)
+	return 0
+}
+
+// Symlink implements experimentalsys.FS.
+func (m *memFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, name, errno := m.parent(linkName)
+	if errno != 0 {
+		return errno
+	}
+	if _, ok := dir.children[name]; ok {
+		return experimentalsys.EEXIST
+	}
+	node := m.newNode(fs.ModeSymlink | 0o777)
+	node.target = oldPath
+	m.files++
+	m.link(dir, name, node)
+	return 0
+}
+
+// Readlink implements experimentalsys.FS.
+func (m *memFS) Readlink(p string) (string, experimentalsys.Errno) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(p, false)
+	if errno != 0 {
+		return "", errno
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", experimentalsys.EINVAL
+	}
+	return node.target, 0
+}
+
+// Utimens implements experimentalsys.FS.
+func (m *memFS) Utimens(p string, atim, mtim int64) experimentalsys.Errno {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, errno := m.resolve(p, true)
+	if errno != 0 {
+		return errno
+	}
+	node.utimens(atim, mtim)
+	return 0
+}
+
+// stat returns the node's attributes.
+func (n *memNode) stat() sys.Stat_t {
+	st := sys.Stat_t{
+		Ino:   n.ino,
+		Mode:  n.mode,
+		Nlink: n.nlink,
+		Atim:  n.atim,
+		Mtim:  n.mtim,
+		Ctim:  n.ctim,
+	}
+	switch {
+	case n.mode&fs.ModeSymlink != 0:
+		st.Size = int64(len(n.target))
+	case !n.mode.IsDir():
+		st.Size = int64(len(n.data))
+	}
+	return st
+}
+
+// utimens sets the node's times, leaving UTIME_OMIT values unchanged.
+func (n *memNode) utimens(atim, mtim int64) {
+	if atim != experimentalsys.UTIME_OMIT {
+		n.atim = atim
+	}
+	if mtim != experimentalsys.UTIME_OMIT {
+		n.mtim = mtim
+	}
+	n.ctim = time.Now().UnixNano()
+}
+
+// memFile is an open file or directory of a memFS.
+//
+// Seek is left unimplemented (ENOSYS): the experimentalsys.File signature
+// does not satisfy go vet's io.Seeker check, and dash only reads and writes
+// files sequentially.
+type memFile struct {
+	experimentalsys.UnimplementedFile
+
+	fs       *memFS
+	node     *memNode
+	readable bool
+	writable bool
+	append   bool
+	closed   bool
+
+	offset int64
+
+	// dirents holds the directory entries not yet returned by Readdir,
+	// listed on the first call.
+	dirents []experimentalsys.Dirent
+	listed  bool
+}
+
+// _ is a type assertion
+var _ experimentalsys.File = (*memFile)(nil)
+
+// Dev implements experimentalsys.File.
+func (f *memFile) Dev() (uint64, experimentalsys.Errno) {
+	return 0, 0
+}
+
+// Ino implements experimentalsys.File.
+func (f *memFile) Ino() (sys.Inode, experimentalsys.Errno) {
+	return f.node.ino, 0
+}
+
+// IsDir implements experimentalsys.File.
+func (f *memFile) IsDir() (bool, experimentalsys.Errno) {
+	return f.node.mode.IsDir(), 0
+}
+
+// IsAppend implements experimentalsys.File.
+func (f *memFile) IsAppend() bool {
+	return f.append
+}
+
+// SetAppend implements experimentalsys.File.
+func (f *memFile) SetAppend(enable bool) experimentalsys.Errno {
+	f.append = enable
+	return 0
+}
+
+// Stat implements experimentalsys.File.
+func (f *memFile) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return sys.Stat_t{}, experimentalsys.EBADF
+	}
+	return f.node.stat(), 0
+}
+
+// check validates the file for a read or write. Callers hold mu.
+func (f *memFile) check(write bool) experimentalsys.Errno {
+	switch {
+	case f.closed:
+		return experimentalsys.EBADF
+	case f.node.mode.IsDir():
+		return experimentalsys.EISDIR
+	case write && !f.writable, !write && !f.readable:
+		return experimentalsys.EBADF
+	}
+	return 0
+}
+
+// Read implements experimentalsys.File.
+func (f *memFile) Read(buf []byte) (int, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	n, errno := f.readAt(buf, f.offset)
+	f.offset += int64(n)
+	return n, errno
+}
+
+// Pread implements experimentalsys.File.
+func (f *memFile) Pread(buf []byte, off int64) (int, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+	return f.readAt(buf, off)
+}
+
+// readAt reads from the file data at off. Callers hold mu.
+func (f *memFile) readAt(buf []byte, off int64) (int, experimentalsys.Errno) {
+	if errno := f.check(false); errno != 0 {
+		return 0, errno
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, 0
+	}
+	f.node.atim = time.Now().UnixNano()
+	return copy(buf, f.node.data[off:]), 0
+}
+
+// Readdir implements experimentalsys.File.
+func (f *memFile) Readdir(n int) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed || !f.node.mode.IsDir() {
+		return nil, experimentalsys.EBADF
+	}
+	if !f.listed {
+		names := make([]string, 0, len(f.node.children))
+		for name := range f.node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		f.dirents = make([]experimentalsys.Dirent, len(names))
+		for i, name := range names {
+			child := f.node.children[name]
+			f.dirents[i] = experimentalsys.Dirent{Ino: child.ino, Name: name, Type: child.mode.Type()}
+		}
+		f.listed = true
+	}
+	if n <= 0 || n > len(f.dirents) {
+		n = len(f.dirents)
+	}
+	out := f.dirents[:n]
+	f.dirents = f.dirents[n:]
+	return out, 0
+}
+
+// Write implements experimentalsys.File.
+func (f *memFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+	n, errno := f.writeAt(buf, f.offset)
+	f.offset += int64(n)
+	return n, errno
+}
+
+// Pwrite implements experimentalsys.File.
+func (f *memFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+	return f.writeAt(buf, off)
+}
+
+// writeAt writes buf to the file data at off. Callers hold mu.
+func (f *memFile) writeAt(buf []byte, off int64) (int, experimentalsys.Errno) {
+	if errno := f.check(true); errno != 0 {
+		return 0, errno
+	}
+	if end := off + int64(len(buf)); end > int64(len(f.node.data)) {
+		if errno := f.fs.resize(f.node, end); errno != 0 {
+			return 0, errno
+		}
+	}
+	f.node.mtim = time.Now().UnixNano()
+	return copy(f.node.data[off:], buf), 0
+}
+
+// Truncate implements experimentalsys.File.
+func (f *memFile) Truncate(size int64) experimentalsys.Errno {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if errno := f.check(true); errno != 0 {
+		return errno
+	}
+	if size < 0 {
+		return experimentalsys.EINVAL
+	}
+	if errno := f.fs.resize(f.node, size); errno != 0 {
+		return errno
+	}
+	f.node.mtim = time.Now().UnixNano()
+	return 0
+}
+
+// Sync implements experimentalsys.File.
+func (f *memFile) Sync() experimentalsys.Errno {
+	return 0
+}
+
+// Datasync implements experimentalsys.File.
+func (f *memFile) Datasync() experimentalsys.Errno {
+	return 0
+}
+
+// Utimens implements experimentalsys.File.
+func (f *memFile) Utimens(atim, mtim int64) experimentalsys.Errno {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return experimentalsys.EBADF
+	}
+	f.node.utimens(atim, mtim)
+	return 0
+}
+
+// Close implements experimentalsys.File.
+func (f *memFile) Close() experimentalsys.Errno {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return 0
+	}
+	f.closed = true
+	f.node.opens--
+	f.fs.release(f.node)
+	return 0
+}
@@ -0,0 +1,59 @@
+package dash
+
+import (
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func TestMemFS(t *testing.T) {
+	m := newMemFS(8)
+
+	f, errno := m.OpenFile("a", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o644)
+	if errno != 0 {
+		t.Fatal("OpenFile:", errno)
+	}
+	if _, errno := f.Write([]byte("hello")); errno != 0 {
+		t.Fatal("Write:", errno)
+	}
+	if _, errno := f.Write([]byte("world")); errno != experimentalsys.EIO {
+		t.Fatalf("expected EIO past the cap, got %v", errno)
+	}
+	buf := make([]byte, 8)
+	if n, _ := f.Pread(buf, 0); string(buf[:n]) != "hello" {
+		t.Fatalf("unexpected contents %q", buf[:n])
+	}
+	_ = f.Close()
+
+	if errno := m.Mkdir("d", 0o755); errno != 0 {
+		t.Fatal("Mkdir:", errno)
+	}
+	if errno := m.Rename("a", "d/b"); errno != 0 {
+		t.Fatal("Rename:", errno)
+	}
+	if errno := m.Rename("d", "d/e"); errno != experimentalsys.EINVAL {
+		t.Fatalf("expected EINVAL renaming into self, got %v", errno)
+	}
+	if errno := m.Symlink("d/b", "link"); errno != 0 {
+		t.Fatal("Symlink:", errno)
+	}
+	if st, errno := m.Stat("link"); errno != 0 || st.Size != 5 {
+		t.Fatalf("Stat through link: %v %v", st, errno)
+	}
+	if errno := m.Rmdir("d"); errno != experimentalsys.ENOTEMPTY {
+		t.Fatalf("expected ENOTEMPTY, got %v", errno)
+	}
+
+	if bytes, files := m.usage(); bytes != 5 || files != 3 {
+		t.Fatalf("unexpected usage %d bytes, %d files", bytes, files)
+	}
+	if errno := m.Unlink("d/b"); errno != 0 {
+		t.Fatal("Unlink:", errno)
+	}
+	if bytes, files := m.usage(); bytes != 0 || files != 2 {
+		t.Fatalf("unexpected usage after unlink %d bytes, %d files", bytes, files)
+	}
+	if _, errno := m.Stat("link"); errno != experimentalsys.ENOENT {
+		t.Fatalf("expected dangling link ENOENT, got %v", errno)
+	}
+}
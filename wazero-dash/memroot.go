@@ -0,0 +1,80 @@
+package dash
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// WithMemRoot mounts a private, writable in-memory file system at the guest
+// root, capping the file data it can hold at maxBytes; zero disables the
+// cap. Scripts can then create files anywhere without access to host
+// directories. Other mounts, such as /tmp, stay in place on top of it, and
+// files can be read back with ReadFile.
+//
+// It cannot be combined with WithImageLayers or WithImageTarball.
+func WithMemRoot(maxBytes int64) Option {
+	return func(o *options) {
+		o.memRoot = true
+		o.memRootMaxBytes = maxBytes
+	}
+}
+
+// ReadFile returns the contents of the file at the absolute guest path p,
+// for example a file written by a script. The file must be on a mount
+// managed by Dash: the in-memory root and /tmp, WithDirMount, WithFS, and
+// archive and image mounts.
+func (d *Dash) ReadFile(p string) ([]byte, error) {
+	if !path.IsAbs(p) {
+		return nil, errors.New("dash: ReadFile path must be absolute: " + p)
+	}
+	m, rel, ok := d.state.lookupMount(p)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: p, Err: errors.New("not on a managed mount")}
+	}
+	f, errno := m.fs.OpenFile(rel, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: errno}
+	}
+	defer f.Close()
+	var data []byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, errno := f.Read(buf)
+		data = append(data, buf[:n]...)
+		if errno != 0 {
+			return nil, &fs.PathError{Op: "read", Path: p, Err: errno}
+		}
+		if n == 0 {
+			return data, nil
+		}
+	}
+}
+
+// writeFile replaces the contents of the file at the absolute guest path p
+// with data, creating it if needed. The file must be on a mount managed by
+// Dash, as for ReadFile.
+func (d *Dash) writeFile(p string, data []byte) error {
+	if !path.IsAbs(p) {
+		return &fs.PathError{Op: "write", Path: p, Err: errors.New("path must be absolute")}
+	}
+	m, rel, ok := d.state.lookupMount(p)
+	if !ok {
+		return &fs.PathError{Op: "write", Path: p, Err: errors.New("not on a managed mount")}
+	}
+	f, errno := m.fs.OpenFile(rel, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_TRUNC, 0o666)
+	if errno != 0 {
+		return &fs.PathError{Op: "open", Path: p, Err: errno}
+	}
+	defer f.Close()
+	for len(data) > 0 {
+		n, errno := f.Write(data)
+		if errno != 0 {
+			return &fs.PathError{Op: "write", Path: p, Err: errno}
+		}
+		data = data[n:]
+	}
+	return nil
+}
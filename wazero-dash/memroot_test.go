@@ -0,0 +1,47 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemRoot(t *testing.T) {
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithMemRoot(1<<20),
+		WithFS(fstest.MapFS{"config": {Data: []byte("key=value\n")}}, "/etc/app"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, `true >/report; test -f /report && echo created; test -d /bin && echo bin; true >/etc/app/x || echo read-only`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if !strings.HasPrefix(stdout.String(), "created\nbin\nread-only\n") {
+		t.Fatalf("unexpected output %q (stderr %q)", stdout.String(), stderr.String())
+	}
+	data, err := d.ReadFile("/report")
+	if err != nil || len(data) != 0 {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if data, err := d.ReadFile("/etc/app/config"); err != nil || string(data) != "key=value\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if _, err := d.ReadFile("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := d.ReadFile("relative"); err == nil {
+		t.Fatal("expected an error for a relative path")
+	}
+	if _, err := NewDash(ctx, WithMemRoot(0), WithImageLayers()); err == nil {
+		t.Fatal("expected an error combining WithMemRoot with an image")
+	}
+}
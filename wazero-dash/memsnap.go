@@ -0,0 +1,112 @@
+package dash
+
+import (
+	"bytes"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasmPageSize is the size of a page of linear memory.
+const wasmPageSize = 65536
+
+// memorySnapshot is a copy of the guest linear memory and stack pointer.
+//
+// Restoring a memory snapshot rolls the shell back to the exact state it had
+// when the snapshot was taken. This is used to recover from evaluations that
+// were aborted mid-execution, where dash had no chance to unwind.
+type memorySnapshot struct {
+	mem          []byte
+	stackPointer uint64
+	// fds is the guest's descriptor table, for the snapshots taken with
+	// captureShell.
+	fds fdTable
+}
+
+// captureMemory copies the module's linear memory and stack pointer.
+func captureMemory(mod api.Module) *memorySnapshot {
+	mem := mod.Memory()
+	view, _ := mem.Read(0, mem.Size())
+	snap := &memorySnapshot{mem: make([]byte, len(view))}
+	copy(snap.mem, view)
+	if sp := mod.ExportedGlobal("__stack_pointer"); sp != nil {
+		snap.stackPointer = sp.Get()
+	}
+	return snap
+}
+
+// captureShell is captureMemory also saving the guest's descriptor table,
+// which rollback restores along with the memory.
+func (d *Dash) captureShell() *memorySnapshot {
+	snap := captureMemory(d.mod)
+	snap.fds = d.state.fds.clone()
+	return snap
+}
+
+// takeSnapshot returns a snapshot of the shell for an evaluation to roll
+// back to, which putSnapshot hands back once it returns. The snapshot of
+// the previous evaluation is refreshed rather than captured again: only the
+// pages changed since are copied, and no memory is allocated. Evaluations
+// started meanwhile, by host commands or traps, capture their own.
+func (d *Dash) takeSnapshot() *memorySnapshot {
+	snap := d.evalSnap
+	if snap == nil {
+		return d.captureShell()
+	}
+	d.evalSnap = nil
+	snap.refresh(d.mod)
+	snap.fds = d.state.fds.clone()
+	return snap
+}
+
+// putSnapshot keeps snap, taken with takeSnapshot, for the next
+// evaluation.
+func (d *Dash) putSnapshot(snap *memorySnapshot) {
+	d.evalSnap = snap
+}
+
+// refresh updates s to the module's current memory and stack pointer,
+// copying only the pages that differ.
+func (s *memorySnapshot) refresh(mod api.Module) {
+	mem := mod.Memory()
+	view, _ := mem.Read(0, mem.Size())
+	if len(view) <= cap(s.mem) {
+		s.mem = s.mem[:len(view)]
+	} else {
+		s.mem = append(s.mem, make([]byte, len(view)-len(s.mem))...)
+	}
+	for off := 0; off < len(view); off += wasmPageSize {
+		page, saved := view[off:min(off+wasmPageSize, len(view))], s.mem[off:min(off+wasmPageSize, len(view))]
+		if !bytes.Equal(page, saved) {
+			copy(saved, page)
+		}
+	}
+	if sp := mod.ExportedGlobal("__stack_pointer"); sp != nil {
+		s.stackPointer = sp.Get()
+	}
+}
+
+// restore writes the snapshot back into the module, only the pages that
+// differ.
+//
+// Linear memory cannot shrink, so any memory grown since the snapshot was
+// taken is zeroed and left for the allocator to reuse.
+func (s *memorySnapshot) restore(mod api.Module) {
+	mem := mod.Memory()
+	if size := mem.Size(); size < uint32(len(s.mem)) {
+		// A new instance replacing a closed module starts smaller.
+		mem.Grow((uint32(len(s.mem)) - size) / wasmPageSize)
+	}
+	view, _ := mem.Read(0, uint32(len(s.mem)))
+	for off := 0; off < len(s.mem); off += wasmPageSize {
+		page, saved := view[off:min(off+wasmPageSize, len(view))], s.mem[off:min(off+wasmPageSize, len(view))]
+		if !bytes.Equal(page, saved) {
+			copy(page, saved)
+		}
+	}
+	if size := mem.Size(); size > uint32(len(s.mem)) {
+		mem.Write(uint32(len(s.mem)), make([]byte, size-uint32(len(s.mem))))
+	}
+	if sp, ok := mod.ExportedGlobal("__stack_pointer").(api.MutableGlobal); ok {
+		sp.Set(s.stackPointer)
+	}
+}
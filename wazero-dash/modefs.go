@@ -0,0 +1,258 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"runtime"
+	"strconv"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// ModeMapping controls how guest permission changes apply to host
+// directory mounts.
+type ModeMapping int
+
+const (
+	// ModeBestEffort applies permission changes the host supports and
+	// silently drops the rest, e.g. everything but the write bit on Windows.
+	ModeBestEffort ModeMapping = iota
+	// ModeIgnore accepts permission changes without applying them.
+	ModeIgnore
+	// ModeStrict applies permission changes exactly, failing with EPERM if
+	// the host stored a different mode.
+	ModeStrict
+)
+
+// String returns the mapping name as accepted by UnmarshalText.
+func (m ModeMapping) String() string {
+	switch m {
+	case ModeBestEffort:
+		return "best-effort"
+	case ModeIgnore:
+		return "ignore"
+	case ModeStrict:
+		return "strict"
+	default:
+		return "ModeMapping(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m ModeMapping) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *ModeMapping) UnmarshalText(text []byte) error {
+	for _, v := range []ModeMapping{ModeBestEffort, ModeIgnore, ModeStrict} {
+		if string(text) == v.String() {
+			*m = v
+			return nil
+		}
+	}
+	return errors.New("unknown mode mapping: " + string(text))
+}
+
+// ModeBehavior describes how file permissions behave under a guest path.
+type ModeBehavior struct {
+	// Mapping is the policy applied to permission changes. In-memory mounts
+	// always store modes exactly and report ModeStrict.
+	Mapping ModeMapping
+	// HostBacked reports whether the path is on a host directory mount.
+	HostBacked bool
+	// Chmod reports whether chmod changes the stored permission bits.
+	Chmod bool
+	// ExactPerm reports whether all nine permission bits are stored.
+	ExactPerm bool
+	// Umask reports whether the shell umask affects created files. WASI
+	// preview1 opens files without a mode, so files are always created
+	// 0600 and this is false.
+	Umask bool
+	// AccessChecks reports whether test -r, -w and -x consult permission
+	// bits. WASI preview1 does not expose them, so this is false: test -r
+	// and -w succeed for any existing file and test -x always fails.
+	AccessChecks bool
+}
+
+// ModeBehavior returns the effective permission behavior for guestPath.
+// Fails if the path is not on a mount managed by Dash.
+func (d *Dash) ModeBehavior(ctx context.Context, guestPath string) (ModeBehavior, error) {
+	m, _, ok := d.state.lookupMount(d.guestPath(ctx, guestPath))
+	if !ok {
+		return ModeBehavior{}, errors.New(guestPath + ": not on a managed mount")
+	}
+	fsys := m.fs
+	if ro, ok := fsys.(*readOnlyFS); ok {
+		fsys = ro.FS
+	}
+	mfs, ok := fsys.(*modeFS)
+	switch {
+	case m.fs != fsys:
+		// Read-only: chmod fails with EROFS.
+		b := ModeBehavior{Mapping: ModeStrict}
+		if ok {
+			b.Mapping, b.HostBacked = mfs.mapping, true
+		}
+		return b, nil
+	case !ok:
+		return ModeBehavior{Mapping: ModeStrict, Chmod: true, ExactPerm: true}, nil
+	}
+	b := ModeBehavior{Mapping: mfs.mapping, HostBacked: true}
+	if mfs.mapping != ModeIgnore {
+		b.Chmod = true
+		b.ExactPerm = runtime.GOOS != "windows"
+	}
+	return b, nil
+}
+
+// modeFS applies a ModeMapping to the Chmod calls of a host file system.
+type modeFS struct {
+	experimentalsys.FS
+	mapping ModeMapping
+}
+
+// Chmod implements experimentalsys.FS.
+func (f *modeFS) Chmod(p string, perm fs.FileMode) experimentalsys.Errno {
+	switch f.mapping {
+	case ModeIgnore:
+		if _, errno := f.FS.Stat(p); errno != 0 {
+			return errno
+		}
+		return 0
+	case ModeStrict:
+		if errno := f.FS.Chmod(p, perm); errno != 0 {
+			return errno
+		}
+		st, errno := f.FS.Stat(p)
+		if errno != 0 {
+			return errno
+		}
+		if st.Mode.Perm() != perm.Perm() {
+			return experimentalsys.EPERM
+		}
+		return 0
+	default:
+		switch errno := f.FS.Chmod(p, perm); errno {
+		case experimentalsys.ENOSYS, experimentalsys.ENOTSUP, experimentalsys.EPERM:
+			return 0
+		default:
+			return errno
+		}
+	}
+}
+
+// chmodCommand implements `chmod MODE FILE...` on managed mounts.
+//
+// MODE is octal or a comma-separated list of symbolic clauses such as
+// u+x or go-w.
+func chmodCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "chmod"
+	if len(argv) < 3 {
+		commandError(ctx, d.mod, d.state, name, "usage: chmod MODE FILE...")
+		return 1
+	}
+	mode := argv[1]
+	status := 0
+	for _, file := range argv[2:] {
+		m, rel, ok := d.state.lookupMount(d.guestPath(ctx, file))
+		if !ok {
+			commandError(ctx, d.mod, d.state, name, file+": not on a managed mount")
+			status = 1
+			continue
+		}
+		st, errno := m.fs.Stat(rel)
+		if errno != 0 {
+			commandError(ctx, d.mod, d.state, name, "cannot access '"+file+"': "+errno.Error())
+			status = 1
+			continue
+		}
+		perm, err := parseMode(mode, st.Mode)
+		if err != nil {
+			commandError(ctx, d.mod, d.state, name, err.Error())
+			return 1
+		}
+		if errno := m.fs.Chmod(rel, perm); errno != 0 {
+			commandError(ctx, d.mod, d.state, name, "changing permissions of '"+file+"': "+errno.Error())
+			status = 1
+		}
+	}
+	return status
+}
+
+// parseMode applies a chmod mode string to the current file mode and
+// returns the new permission bits.
+func parseMode(mode string, cur fs.FileMode) (fs.FileMode, error) {
+	invalid := errors.New("invalid mode: '" + mode + "'")
+	if v, err := strconv.ParseUint(mode, 8, 32); err == nil {
+		if v > 0o7777 {
+			return 0, invalid
+		}
+		return fs.FileMode(v) & fs.ModePerm, nil
+	}
+
+	perm := cur.Perm()
+	for _, clause := range strings.Split(mode, ",") {
+		i := 0
+		var who fs.FileMode
+	who:
+		for ; i < len(clause); i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0o700
+			case 'g':
+				who |= 0o070
+			case 'o':
+				who |= 0o007
+			case 'a':
+				who |= 0o777
+			default:
+				break who
+			}
+		}
+		if who == 0 {
+			who = 0o777
+		}
+		if i == len(clause) {
+			return 0, invalid
+		}
+		for i < len(clause) {
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return 0, invalid
+			}
+			i++
+			var bits fs.FileMode
+			for ; i < len(clause) && strings.IndexByte("+-=", clause[i]) == -1; i++ {
+				switch clause[i] {
+				case 'r':
+					bits |= 0o444
+				case 'w':
+					bits |= 0o222
+				case 'x':
+					bits |= 0o111
+				case 'X':
+					if cur.IsDir() || perm&0o111 != 0 {
+						bits |= 0o111
+					}
+				case 's', 't':
+					// Not representable on guest mounts.
+				default:
+					return 0, invalid
+				}
+			}
+			bits &= who
+			switch op {
+			case '+':
+				perm |= bits
+			case '-':
+				perm &^= bits
+			case '=':
+				perm = perm&^who | bits
+			}
+		}
+	}
+	return perm, nil
+}
@@ -0,0 +1,115 @@
+package dash
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		cur  fs.FileMode
+		want fs.FileMode
+	}{
+		{"755", 0o600, 0o755},
+		{"+x", 0o644, 0o755},
+		{"u+x,go-r", 0o644, 0o700},
+		{"go=r", 0o700, 0o744},
+		{"a-w", 0o666, 0o444},
+		{"u=rwx,g=rx,o=", 0o000, 0o750},
+		{"+X", 0o644, 0o644},
+		{"+X", fs.ModeDir | 0o644, 0o755},
+	} {
+		got, err := parseMode(tc.mode, tc.cur)
+		if err != nil || got != tc.want {
+			t.Errorf("parseMode(%q, %o) = %o, %v; want %o", tc.mode, tc.cur, got, err, tc.want)
+		}
+	}
+	for _, mode := range []string{"9", "u", "u+q", "+x,", "17777"} {
+		if _, err := parseMode(mode, 0o644); err == nil {
+			t.Errorf("parseMode(%q): expected error", mode)
+		}
+	}
+}
+
+func TestModeMapping(t *testing.T) {
+	for _, mapping := range []ModeMapping{ModeBestEffort, ModeIgnore, ModeStrict} {
+		t.Run(mapping.String(), func(t *testing.T) {
+			ctx := context.Background()
+			r := wazero.NewRuntime(ctx)
+			defer r.Close(ctx)
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "f")
+			if err := os.WriteFile(file, nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := NewDash(ctx, WithRuntime(r), WithDirMount(dir, "/work"), WithModeMapping(mapping))
+			if err != nil {
+				t.Fatal("NewDash:", err)
+			}
+			defer d.Close(ctx)
+			if err := d.Init(ctx, nil); err != nil {
+				t.Fatal("Init:", err)
+			}
+
+			behavior, err := d.ModeBehavior(ctx, "/work/f")
+			if err != nil {
+				t.Fatal("ModeBehavior:", err)
+			}
+			if behavior.Mapping != mapping || !behavior.HostBacked || behavior.Umask || behavior.AccessChecks {
+				t.Fatalf("unexpected behavior %+v", behavior)
+			}
+
+			status, err := d.Eval(ctx, "cd /work && chmod u+x f")
+			if err != nil {
+				t.Fatal("Eval:", err)
+			}
+			info, err := os.Stat(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPerm := fs.FileMode(0o700)
+			if mapping == ModeIgnore || runtime.GOOS == "windows" {
+				wantPerm = 0o600
+			}
+			if runtime.GOOS != "windows" && info.Mode().Perm() != wantPerm {
+				t.Fatalf("expected mode %o, got %o", wantPerm, info.Mode().Perm())
+			}
+			wantStatus := 0
+			if mapping == ModeStrict && runtime.GOOS == "windows" {
+				wantStatus = 1
+			}
+			if status != wantStatus {
+				t.Fatalf("expected chmod status %d, got %d", wantStatus, status)
+			}
+
+			// WASI preview1 does not expose permission bits to the guest.
+			if status, _ := d.Eval(ctx, "test -x /work/f"); status == 0 {
+				t.Fatal("expected test -x to fail")
+			}
+		})
+	}
+}
+
+func TestModeBehaviorTempDir(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	ctx := context.Background()
+	behavior, err := d.ModeBehavior(ctx, "/tmp")
+	if err != nil {
+		t.Fatal("ModeBehavior:", err)
+	}
+	if want := (ModeBehavior{Mapping: ModeStrict, Chmod: true, ExactPerm: true}); behavior != want {
+		t.Fatalf("expected %+v, got %+v", want, behavior)
+	}
+	if _, err := d.ModeBehavior(ctx, "/elsewhere"); err == nil {
+		t.Fatal("expected error for unmanaged path")
+	}
+}
@@ -0,0 +1,179 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+// mount is a guest file system managed by Dash.
+//
+// Mounts configured through WithFSConfig are opaque to the host; only
+// managed mounts are reachable by host commands such as chmod.
+type mount struct {
+	guest string
+	fs    experimentalsys.FS
+
+	// hostDir is the host directory backing the mount, or empty if the
+	// mount is in memory.
+	hostDir string
+}
+
+// dirMount is a host directory requested with WithDirMount.
+type dirMount struct {
+	host, guest string
+	readOnly    bool
+}
+
+// applyFSOptions installs the file system mounts selected by opts on config
+// and records the managed mounts in state.
+func applyFSOptions(config wazero.ModuleConfig, opts *options, state *dashState) (wazero.ModuleConfig, error) {
+	fsConfig := opts.fsConfig
+	if len(opts.dirMounts) == 0 && len(opts.archiveMounts) == 0 && len(opts.fsMounts) == 0 && len(opts.overlays) == 0 && len(opts.workspaces) == 0 && opts.image == nil && !opts.memRoot && !opts.tempDir && !opts.binDir && !opts.stdlib {
+		if fsConfig != nil {
+			config = config.WithFSConfig(fsConfig)
+		}
+		return config, nil
+	}
+
+	if fsConfig == nil {
+		fsConfig = wazero.NewFSConfig()
+	}
+	if opts.journal || opts.events != nil {
+		state.journal = &journal{discard: !opts.journal, state: state}
+	}
+	// managed layers the write quota and journal over fsys. They sit below
+	// modeFS so only permission changes actually applied are journaled,
+	// and the journal only sees writes the quota allowed.
+	managed := func(fsys experimentalsys.FS, guest string) experimentalsys.FS {
+		if state.journal != nil {
+			fsys = &journalFS{FS: fsys, guest: guest, journal: state.journal}
+		}
+		return &quotaFS{FS: fsys, state: state}
+	}
+	if opts.memRoot {
+		if opts.image != nil {
+			return nil, errors.New("dash: WithMemRoot cannot be combined with an image root file system")
+		}
+		state.mounts = append(state.mounts, mount{guest: "/", fs: managed(newMemFS(opts.memRootMaxBytes), "/")})
+	}
+	if opts.image != nil {
+		root, err := imageFS(opts.image)
+		if err != nil {
+			return nil, err
+		}
+		state.mounts = append(state.mounts, mount{guest: "/", fs: managed(root, "/")})
+	}
+	for _, w := range opts.workspaces {
+		state.mounts = append(state.mounts, mount{guest: w.mountPoint, fs: managed(w.fs, w.mountPoint)})
+	}
+	for _, m := range opts.dirMounts {
+		var fsys experimentalsys.FS = &modeFS{FS: managed(hostDirFS(m.host, opts.symlinks), m.guest), mapping: opts.modeMapping}
+		if m.readOnly {
+			fsys = &readOnlyFS{FS: fsys}
+		}
+		state.mounts = append(state.mounts, mount{guest: m.guest, fs: fsys, hostDir: m.host})
+	}
+	for _, a := range opts.archiveMounts {
+		fsys, err := archiveFS(a, archiveMaxBytes(opts, state.sizeLimits))
+		if err != nil {
+			return nil, err
+		}
+		state.mounts = append(state.mounts, mount{guest: a.guest, fs: fsys})
+	}
+	for _, m := range opts.fsMounts {
+		if !path.IsAbs(m.guest) {
+			return nil, errors.New("dash: WithFS mount point must be absolute: " + m.guest)
+		}
+		state.mounts = append(state.mounts, mount{guest: m.guest, fs: &readOnlyFS{FS: &sysfs.AdaptFS{FS: m.fsys}}})
+	}
+	for _, m := range opts.overlays {
+		if !path.IsAbs(m.guest) {
+			return nil, errors.New("dash: overlay mount point must be absolute: " + m.guest)
+		}
+		lower := m.lower
+		if m.hostDir != "" {
+			lower = &readOnlyFS{FS: hostDirFS(m.hostDir, opts.symlinks)}
+		}
+		overlay := newOverlayFS(lower)
+		state.overlays = append(state.overlays, overlayState{guest: path.Clean(m.guest), fs: overlay})
+		state.mounts = append(state.mounts, mount{guest: m.guest, fs: managed(overlay, m.guest)})
+	}
+	if opts.tempDir {
+		state.tmp = newMemFS(opts.tempDirMaxBytes)
+		state.mounts = append(state.mounts, mount{guest: TempDir, fs: managed(state.tmp, TempDir)})
+	}
+	if opts.binDir && opts.image == nil && !slices.ContainsFunc(state.mounts, func(m mount) bool { return path.Clean(m.guest) == BinDir }) {
+		state.mounts = append(state.mounts, mount{guest: BinDir, fs: &readOnlyFS{FS: &binFS{state: state}}})
+	}
+	if opts.stdlib {
+		m, err := stdlibMount()
+		if err != nil {
+			return nil, err
+		}
+		state.mounts = append(state.mounts, m)
+	}
+
+	for i := range state.mounts {
+		if opts.readOnlyFS {
+			state.mounts[i].fs = &readOnlyFS{FS: state.mounts[i].fs}
+		}
+		m := state.mounts[i]
+		sysConfig, ok := fsConfig.(sysfs.FSConfig)
+		if !ok {
+			return nil, errors.New("fs config does not support sys mounts")
+		}
+		// Only the guest sees the counting layer; host accesses through
+		// m.fs are not counted.
+		counters := &mountCounters{guest: m.guest}
+		state.fsStats = append(state.fsStats, counters)
+		fsConfig = sysConfig.WithSysFSMount(&statsFS{FS: m.fs, counters: counters}, m.guest)
+	}
+	return config.WithFSConfig(fsConfig), nil
+}
+
+// lookupMount returns the managed mount holding the absolute guest path p
+// and the path relative to the mount root.
+func (s *dashState) lookupMount(p string) (*mount, string, bool) {
+	p = path.Clean(p)
+	var best *mount
+	var rel string
+	for i := range s.mounts {
+		m := &s.mounts[i]
+		r, ok := relativeTo(m.guest, p)
+		if ok && (best == nil || len(m.guest) > len(best.guest)) {
+			best, rel = m, r
+		}
+	}
+	return best, rel, best != nil
+}
+
+// relativeTo returns p relative to the directory root, if inside it.
+func relativeTo(root, p string) (string, bool) {
+	if p == root {
+		return ".", true
+	}
+	if root == "/" {
+		return strings.TrimPrefix(p, "/"), true
+	}
+	rel, ok := strings.CutPrefix(p, root+"/")
+	return rel, ok
+}
+
+// guestPath resolves p against the shell's working directory.
+func (d *Dash) guestPath(ctx context.Context, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	pwd, err := d.GetVar(ctx, "PWD")
+	if err != nil || pwd == "" {
+		pwd = "/"
+	}
+	return path.Join(pwd, p)
+}
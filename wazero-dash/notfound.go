@@ -0,0 +1,39 @@
+package dash
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// NotFoundStatus is the exit status of a command dash cannot locate.
+const NotFoundStatus = 127
+
+// CommandNotFoundHandler is called when no builtin, function, host command
+// or ExecHandler runs the command name, with its arguments. It returns
+// whether it handled the command and, if so, the exit status.
+type CommandNotFoundHandler func(ctx context.Context, name string, args []string) (handled bool, status int)
+
+// SetCommandNotFoundHandler registers a fallback for commands dash cannot
+// locate, for example to dispatch them elsewhere, suggest corrections or log
+// them. It runs if there is no ExecHandler or the ExecHandler returns
+// NotFoundStatus, and is subject to the CommandTimeouts. Passing nil removes
+// the handler.
+func (d *Dash) SetCommandNotFoundHandler(h CommandNotFoundHandler) {
+	d.state.commandNotFound = h
+}
+
+// runCommandNotFound passes argv to the CommandNotFoundHandler, if any, and
+// returns the exit status.
+func runCommandNotFound(ctx context.Context, mod api.Module, state *dashState, argv []string) int {
+	h := state.commandNotFound
+	if h == nil {
+		return NotFoundStatus
+	}
+	return runWithTimeout(ctx, mod, state, argv[0], func(ctx context.Context) int {
+		if handled, status := h(ctx, argv[0], argv[1:]); handled {
+			return status
+		}
+		return NotFoundStatus
+	})
+}
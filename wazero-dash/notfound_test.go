@@ -0,0 +1,37 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommandNotFoundHandler(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+
+	var missing []string
+	d.SetCommandNotFoundHandler(func(ctx context.Context, name string, args []string) (bool, int) {
+		if name != "fallback" {
+			missing = append(missing, name)
+			return false, 0
+		}
+		return true, len(args)
+	})
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		if argv[0] == "known" {
+			return 0
+		}
+		return NotFoundStatus
+	})
+	status, err := d.Eval(ctx, `known; echo "known $?"; fallback a b c; echo "fallback $?"; gti status; echo "gti $?"`)
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if want := "known 0\nfallback 3\ngti 127\n"; stdout.String() != want || status != 0 {
+		t.Fatalf("expected %q, got %q (status %d)", want, stdout.String(), status)
+	}
+	if strings.Join(missing, " ") != "gti" {
+		t.Fatalf("unexpected missing commands %q", missing)
+	}
+}
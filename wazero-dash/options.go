@@ -0,0 +1,184 @@
+package dash
+
+import (
+	"io"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Option configures a Dash created by NewDash.
+type Option func(*options)
+
+// options holds the settings applied by Options.
+type options struct {
+	runtime          wazero.Runtime
+	moduleConfig     wazero.ModuleConfig
+	stdin            io.Reader
+	stdout, stderr   io.Writer
+	stdoutBuffer     int
+	env              [][2]string
+	locale           *string
+	args             []string
+	compiled         wazero.CompiledModule
+	compilationCache wazero.CompilationCache
+
+	fsConfig           wazero.FSConfig
+	fsMounts           []fsMount
+	overlays           []overlayMount
+	dirMounts          []dirMount
+	archiveMounts      []archiveMount
+	archiveMaxBytes    *int64
+	image              *imageSource
+	memRoot            bool
+	memRootMaxBytes    int64
+	workspaces         []*Workspace
+	jsonCommand        bool
+	envFiles           []io.Reader
+	initScripts        []string
+	policy             *Policy
+	modeMapping        ModeMapping
+	symlinks           SymlinkPolicy
+	tempDir            bool
+	tempDirMaxBytes    int64
+	heartbeat          *HeartbeatConfig
+	yield              *YieldConfig
+	metering           bool
+	sessionCheckpoints bool
+	history            History
+	interrupts         bool
+	diagnostics        func(*Diagnostics)
+	events             Events
+	snapshotStrategy   SnapshotStrategy
+	snapshotWindow     int
+	readOnlyFS         bool
+	journal            bool
+	binDir             bool
+	stdlib             bool
+	stdlibModules      []string
+}
+
+// defaultOptions returns the settings used when no Option is given.
+func defaultOptions() options {
+	return options{
+		tempDir:         true,
+		tempDirMaxBytes: DefaultTempDirMaxBytes,
+		binDir:          true,
+	}
+}
+
+// WithStdoutBuffer buffers up to size bytes of the stdout given with
+// WithStdio, which is then written in fewer, larger calls. Writes of at
+// least size bytes go through unbuffered. The buffer is flushed when a
+// top-level evaluation returns, before the guest reads stdin or writes to
+// stderr, and on Close.
+func WithStdoutBuffer(size int) Option {
+	return func(o *options) {
+		o.stdoutBuffer = size
+	}
+}
+
+// WithRuntime runs dash in r instead of a runtime created by NewDash. The
+// caller closes r after the Dash.
+func WithRuntime(r wazero.Runtime) Option {
+	return func(o *options) {
+		o.runtime = r
+	}
+}
+
+// WithModuleConfig sets the module config options such as WithStdio apply
+// to. The default is wazero.NewModuleConfig().
+func WithModuleConfig(config wazero.ModuleConfig) Option {
+	return func(o *options) {
+		o.moduleConfig = config
+	}
+}
+
+// WithStdio sets the standard streams of the shell. Nil streams keep those
+// of the module config, which default to empty input and discarded output.
+func WithStdio(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(o *options) {
+		o.stdin, o.stdout, o.stderr = stdin, stdout, stderr
+	}
+}
+
+// WithEnv adds the environment variable key to the shell environment.
+func WithEnv(key, value string) Option {
+	return func(o *options) {
+		o.env = append(o.env, [2]string{key, value})
+	}
+}
+
+// WithArgs sets the dash command line Init uses when called without one,
+// such as "script", "-s", "one", "two" for $0 and the positional
+// parameters.
+func WithArgs(args ...string) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+// WithCompiledModule instantiates dash from compiled, the result of
+// CompileDash on the runtime given with WithRuntime, instead of compiling
+// it again.
+func WithCompiledModule(compiled wazero.CompiledModule) Option {
+	return func(o *options) {
+		o.compiled = compiled
+	}
+}
+
+// WithCompilationCache makes the runtime created by NewDash use cache
+// instead of the SharedCompilationCache. It cannot be combined with
+// WithRuntime.
+func WithCompilationCache(cache wazero.CompilationCache) Option {
+	return func(o *options) {
+		o.compilationCache = cache
+	}
+}
+
+// WithFSConfig sets the guest file system mounts.
+//
+// Use this instead of wazero.ModuleConfig.WithFSConfig: NewDash installs
+// its own FSConfig on the module config to add mounts such as /tmp, which
+// replaces any FSConfig set there.
+func WithFSConfig(fsConfig wazero.FSConfig) Option {
+	return func(o *options) {
+		o.fsConfig = fsConfig
+	}
+}
+
+// WithDirMount mounts the host directory hostDir read-write at guestPath.
+//
+// Unlike mounts made through WithFSConfig, these are visible to host
+// commands such as chmod and follow the configured ModeMapping.
+func WithDirMount(hostDir, guestPath string) Option {
+	return func(o *options) {
+		o.dirMounts = append(o.dirMounts, dirMount{host: hostDir, guest: guestPath})
+	}
+}
+
+// WithModeMapping sets how permission changes apply to WithDirMount
+// mounts. The default is ModeBestEffort.
+func WithModeMapping(mapping ModeMapping) Option {
+	return func(o *options) {
+		o.modeMapping = mapping
+	}
+}
+
+// WithTempDir configures the in-memory /tmp, capping the file data it can
+// hold at maxBytes. Zero disables the cap.
+func WithTempDir(maxBytes int64) Option {
+	return func(o *options) {
+		o.tempDir = true
+		o.tempDirMaxBytes = maxBytes
+	}
+}
+
+// WithoutTempDir disables the in-memory /tmp.
+//
+// The module config's FSConfig is then used as is if WithFSConfig,
+// WithDirMount and BinDir are not used either.
+func WithoutTempDir() Option {
+	return func(o *options) {
+		o.tempDir = false
+	}
+}
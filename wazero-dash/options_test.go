@@ -0,0 +1,66 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestNewDashOptions(t *testing.T) {
+	ctx := context.Background()
+	cache := wazero.NewCompilationCache()
+	defer cache.Close(ctx)
+	files := fstest.MapFS{"a.txt": {Data: []byte("x")}, "b.txt": {Data: []byte("y")}}
+	var stdout bytes.Buffer
+	d, err := NewDash(ctx,
+		WithStdio(strings.NewReader("input\n"), &stdout, nil),
+		WithEnv("GREETING", "hello"),
+		WithArgs("script", "-s", "one", "two"),
+		WithFS(files, "/data"),
+		WithCompilationCache(cache))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, `read line; echo "$GREETING $0 $2 $line"; echo /data/*.txt; true >/data/new || echo read-only`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if want := "hello script two input\n/data/a.txt /data/b.txt\nread-only\n"; stdout.String() != want {
+		t.Fatalf("expected %q, got %q", want, stdout.String())
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+	if _, err := d.runtime.CompileModule(ctx, []byte("\x00asm\x01\x00\x00\x00")); err == nil {
+		t.Fatal("expected Close to close the runtime NewDash created")
+	}
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	compiled, err := CompileDash(ctx, r)
+	if err != nil {
+		t.Fatal("CompileDash:", err)
+	}
+	for _, opts := range [][]Option{
+		{WithCompiledModule(compiled)},
+		{WithRuntime(r), WithCompilationCache(cache)},
+		{WithRuntime(r), WithCompiledModule(compiled), WithHeartbeat(HeartbeatConfig{Calls: 1})},
+	} {
+		if _, err := NewDash(ctx, opts...); err == nil {
+			t.Fatal("expected an error combining the options")
+		}
+	}
+	d, err = NewDash(ctx, WithRuntime(r), WithCompiledModule(compiled))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+}
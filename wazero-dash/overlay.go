@@ -0,0 +1,704 @@
+package dash
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// overlayMount is an overlay requested with WithOverlayDir or WithOverlayFS.
+type overlayMount struct {
+	lower experimentalsys.FS
+	// hostDir is the WithOverlayDir directory, opened as the lower layer
+	// once the SymlinkPolicy is known.
+	hostDir string
+	guest   string
+}
+
+// WithOverlayDir mounts the host directory hostDir at guestPath as a
+// read-only base with an in-memory upper layer: the guest sees and may
+// change every file, but writes, removals and permission changes only
+// reach the upper layer, so the host directory is never modified. This
+// allows dry runs of scripts against real project trees. The upper layer
+// holds at most DefaultTempDirMaxBytes of file data.
+//
+// FileChanges reports what the script changed, and ReadFile reads the
+// changed files.
+func WithOverlayDir(hostDir, guestPath string) Option {
+	return func(o *options) {
+		o.overlays = append(o.overlays, overlayMount{hostDir: hostDir, guest: guestPath})
+	}
+}
+
+// WithOverlayFS is like WithOverlayDir with fsys as the base.
+func WithOverlayFS(fsys fs.FS, guestPath string) Option {
+	return func(o *options) {
+		o.overlays = append(o.overlays, overlayMount{lower: &sysfs.AdaptFS{FS: fsys}, guest: guestPath})
+	}
+}
+
+// FileChangeKind is how a file in an overlay differs from its base.
+type FileChangeKind int
+
+// Kinds of file changes.
+const (
+	// FileCreated marks a file missing from the base.
+	FileCreated FileChangeKind = iota
+	// FileModified marks a file whose contents, type or permissions
+	// differ from the base.
+	FileModified
+	// FileDeleted marks a base file removed in the overlay. Files below a
+	// deleted directory are not listed.
+	FileDeleted
+)
+
+// String returns the kind name.
+func (k FileChangeKind) String() string {
+	switch k {
+	case FileCreated:
+		return "created"
+	case FileModified:
+		return "modified"
+	case FileDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// FileChange describes a file changed in an overlay mount.
+type FileChange struct {
+	// Path is the guest path of the file.
+	Path string
+	Kind FileChangeKind
+}
+
+// FileChanges returns the differences between the overlay mounts and their
+// bases, sorted by path.
+func (d *Dash) FileChanges() []FileChange {
+	var changes []FileChange
+	for _, o := range d.state.overlays {
+		for _, c := range o.fs.changes() {
+			changes = append(changes, FileChange{Path: path.Join(o.guest, c.Path), Kind: c.Kind})
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes
+}
+
+// overlayState is a mounted overlay, for FileChanges.
+type overlayState struct {
+	guest string
+	fs    *overlayFS
+}
+
+// overlayFS layers a writable memFS over a read-only file system.
+//
+// A path resolves to the upper layer if it exists there, and otherwise to
+// the lower layer unless it or a parent directory is hidden. Files are
+// copied up before they are changed, and removing a lower file hides it.
+// Hiding a directory also hides the lower files below it, so a directory
+// removed and created again starts empty.
+type overlayFS struct {
+	lower experimentalsys.FS
+	upper *memFS
+
+	mu     sync.Mutex
+	hidden map[string]bool
+}
+
+// _ is a type assertion
+var _ experimentalsys.FS = (*overlayFS)(nil)
+
+// newOverlayFS returns an overlay on lower with an empty upper layer.
+func newOverlayFS(lower experimentalsys.FS) *overlayFS {
+	return &overlayFS{lower: lower, upper: newMemFS(DefaultTempDirMaxBytes), hidden: make(map[string]bool)}
+}
+
+// cleanPath normalizes a path relative to the mount root.
+func cleanPath(p string) string {
+	return path.Clean("./" + p)
+}
+
+// isHidden reports whether p or one of its parents is hidden.
+func (o *overlayFS) isHidden(p string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for {
+		if o.hidden[p] {
+			return true
+		}
+		if p == "." {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+// hide hides the lower file p.
+func (o *overlayFS) hide(p string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hidden[p] = true
+}
+
+// inUpper reports whether p exists in the upper layer.
+func (o *overlayFS) inUpper(p string) bool {
+	_, errno := o.upper.Lstat(p)
+	return errno == 0
+}
+
+// lowerLstat stats the lower file p unless it is hidden.
+func (o *overlayFS) lowerLstat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	if o.isHidden(p) {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	return o.lower.Lstat(p)
+}
+
+// exists reports whether p is visible in either layer.
+func (o *overlayFS) exists(p string) bool {
+	if o.inUpper(p) {
+		return true
+	}
+	_, errno := o.lowerLstat(p)
+	return errno == 0
+}
+
+// lowerDirVisible reports whether the lower directory p contributes
+// entries to the merged directory p.
+func (o *overlayFS) lowerDirVisible(p string) bool {
+	if o.isHidden(p) {
+		return false
+	}
+	st, errno := o.lower.Stat(p)
+	return errno == 0 && st.Mode.IsDir()
+}
+
+// copyUp copies the visible lower file p and its parents to the upper
+// layer, unless p already exists there.
+func (o *overlayFS) copyUp(p string) experimentalsys.Errno {
+	if p == "." || o.inUpper(p) {
+		return 0
+	}
+	st, errno := o.lowerLstat(p)
+	if errno != 0 {
+		return errno
+	}
+	if errno := o.copyUp(path.Dir(p)); errno != 0 {
+		return errno
+	}
+	switch {
+	case st.Mode.IsDir():
+		errno = o.upper.Mkdir(p, st.Mode.Perm())
+	case st.Mode&fs.ModeSymlink != 0:
+		var target string
+		if target, errno = o.lower.Readlink(p); errno == 0 {
+			errno = o.upper.Symlink(target, p)
+		}
+	default:
+		errno = o.copyFile(p, st.Mode.Perm())
+	}
+	if errno != 0 {
+		return errno
+	}
+	if st.Mode&fs.ModeSymlink == 0 {
+		_ = o.upper.Utimens(p, st.Atim, st.Mtim)
+	}
+	return 0
+}
+
+// copyFile copies the contents of the lower file p to the upper layer.
+func (o *overlayFS) copyFile(p string, perm fs.FileMode) experimentalsys.Errno {
+	src, errno := o.lower.OpenFile(p, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return errno
+	}
+	defer src.Close()
+	dst, errno := o.upper.OpenFile(p, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_TRUNC, perm)
+	if errno != 0 {
+		return errno
+	}
+	defer dst.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		n, errno := src.Read(buf)
+		if n > 0 {
+			if _, errno := dst.Write(buf[:n]); errno != 0 {
+				return errno
+			}
+		}
+		if errno != 0 {
+			return errno
+		}
+		if n == 0 {
+			return 0
+		}
+	}
+}
+
+// copyUpTree copies p and, if it is a directory, every visible file below
+// it to the upper layer.
+func (o *overlayFS) copyUpTree(p string) experimentalsys.Errno {
+	if errno := o.copyUp(p); errno != 0 {
+		return errno
+	}
+	if !o.lowerDirVisible(p) || !o.upperIsDir(p) {
+		return 0
+	}
+	entries, errno := o.lowerEntries(p)
+	if errno != 0 {
+		return errno
+	}
+	for _, e := range entries {
+		if errno := o.copyUpTree(path.Join(p, e.Name)); errno != 0 {
+			return errno
+		}
+	}
+	return 0
+}
+
+// upperIsDir reports whether p is a directory in the upper layer.
+func (o *overlayFS) upperIsDir(p string) bool {
+	st, errno := o.upper.Lstat(p)
+	return errno == 0 && st.Mode.IsDir()
+}
+
+// lowerEntries lists the visible entries of the lower directory p.
+func (o *overlayFS) lowerEntries(p string) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	dir, errno := o.lower.OpenFile(p, experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer dir.Close()
+	entries, errno := dir.Readdir(-1)
+	if errno != 0 {
+		return nil, errno
+	}
+	return slices.DeleteFunc(entries, func(e experimentalsys.Dirent) bool {
+		return e.Name == "." || e.Name == ".." || o.isHidden(path.Join(p, e.Name))
+	}), 0
+}
+
+// mergedEntries lists the directory p of the overlay.
+func (o *overlayFS) mergedEntries(p string) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	var entries []experimentalsys.Dirent
+	if o.upperIsDir(p) {
+		dir, errno := o.upper.OpenFile(p, experimentalsys.O_RDONLY, 0)
+		if errno != 0 {
+			return nil, errno
+		}
+		entries, errno = dir.Readdir(-1)
+		dir.Close()
+		if errno != 0 {
+			return nil, errno
+		}
+	}
+	if o.lowerDirVisible(p) {
+		lower, errno := o.lowerEntries(p)
+		if errno != 0 {
+			return nil, errno
+		}
+		for _, e := range lower {
+			if !slices.ContainsFunc(entries, func(u experimentalsys.Dirent) bool { return u.Name == e.Name }) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	slices.SortFunc(entries, func(a, b experimentalsys.Dirent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, 0
+}
+
+// OpenFile implements experimentalsys.FS.
+func (o *overlayFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	p = cleanPath(p)
+	const write = experimentalsys.O_WRONLY | experimentalsys.O_RDWR | experimentalsys.O_APPEND | experimentalsys.O_CREAT | experimentalsys.O_TRUNC
+	if flag&write == 0 {
+		st, errno := o.Stat(p)
+		if errno != 0 {
+			return nil, errno
+		}
+		if st.Mode.IsDir() {
+			return &overlayDir{fs: o, path: p, st: st}, 0
+		}
+		if o.inUpper(p) {
+			return o.upper.OpenFile(p, flag, perm)
+		}
+		return o.lower.OpenFile(p, flag, perm)
+	}
+
+	if o.exists(p) {
+		if flag&experimentalsys.O_CREAT != 0 && flag&experimentalsys.O_EXCL != 0 {
+			return nil, experimentalsys.EEXIST
+		}
+		if errno := o.copyUp(p); errno != 0 {
+			return nil, errno
+		}
+	} else if errno := o.copyUp(path.Dir(p)); errno != 0 {
+		return nil, errno
+	}
+	return o.upper.OpenFile(p, flag, perm)
+}
+
+// Lstat implements experimentalsys.FS.
+func (o *overlayFS) Lstat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	p = cleanPath(p)
+	if st, errno := o.upper.Lstat(p); errno == 0 {
+		return st, 0
+	}
+	return o.lowerLstat(p)
+}
+
+// Stat implements experimentalsys.FS.
+func (o *overlayFS) Stat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	p = cleanPath(p)
+	if o.inUpper(p) {
+		return o.upper.Stat(p)
+	}
+	if o.isHidden(p) {
+		return sys.Stat_t{}, experimentalsys.ENOENT
+	}
+	return o.lower.Stat(p)
+}
+
+// Mkdir implements experimentalsys.FS.
+func (o *overlayFS) Mkdir(p string, perm fs.FileMode) experimentalsys.Errno {
+	p = cleanPath(p)
+	if o.exists(p) {
+		return experimentalsys.EEXIST
+	}
+	if errno := o.copyUp(path.Dir(p)); errno != 0 {
+		return errno
+	}
+	return o.upper.Mkdir(p, perm)
+}
+
+// Chmod implements experimentalsys.FS.
+func (o *overlayFS) Chmod(p string, perm fs.FileMode) experimentalsys.Errno {
+	p = cleanPath(p)
+	if errno := o.copyUp(p); errno != 0 {
+		return errno
+	}
+	return o.upper.Chmod(p, perm)
+}
+
+// Rename implements experimentalsys.FS.
+func (o *overlayFS) Rename(from, to string) experimentalsys.Errno {
+	from, to = cleanPath(from), cleanPath(to)
+	_, errno := o.lowerLstat(from)
+	fromLower := errno == 0
+	_, errno = o.lowerLstat(to)
+	toLower := errno == 0
+	if errno := o.copyUpTree(from); errno != 0 {
+		return errno
+	}
+	if errno := o.copyUp(path.Dir(to)); errno != 0 {
+		return errno
+	}
+	if toLower && !o.inUpper(to) {
+		if st, _ := o.lower.Lstat(to); st.Mode.IsDir() {
+			if entries, _ := o.mergedEntries(to); len(entries) != 0 {
+				return experimentalsys.ENOTEMPTY
+			}
+		}
+	}
+	if errno := o.upper.Rename(from, to); errno != 0 {
+		return errno
+	}
+	if toLower {
+		o.hide(to)
+	}
+	if fromLower {
+		o.hide(from)
+	}
+	return 0
+}
+
+// Rmdir implements experimentalsys.FS.
+func (o *overlayFS) Rmdir(p string) experimentalsys.Errno {
+	p = cleanPath(p)
+	st, errno := o.Lstat(p)
+	if errno != 0 {
+		return errno
+	}
+	if !st.Mode.IsDir() {
+		return experimentalsys.ENOTDIR
+	}
+	if entries, errno := o.mergedEntries(p); errno != 0 {
+		return errno
+	} else if len(entries) != 0 {
+		return experimentalsys.ENOTEMPTY
+	}
+	return o.remove(p, o.upper.Rmdir)
+}
+
+// Unlink implements experimentalsys.FS.
+func (o *overlayFS) Unlink(p string) experimentalsys.Errno {
+	p = cleanPath(p)
+	st, errno := o.Lstat(p)
+	if errno != 0 {
+		return errno
+	}
+	if st.Mode.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	return o.remove(p, o.upper.Unlink)
+}
+
+// remove removes p from the upper layer with removeUpper and hides the
+// lower file.
+func (o *overlayFS) remove(p string, removeUpper func(string) experimentalsys.Errno) experimentalsys.Errno {
+	if o.inUpper(p) {
+		if errno := removeUpper(p); errno != 0 {
+			return errno
+		}
+	}
+	if _, errno := o.lowerLstat(p); errno == 0 {
+		o.hide(p)
+	}
+	return 0
+}
+
+// Link implements experimentalsys.FS.
+func (o *overlayFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	oldPath, newPath = cleanPath(oldPath), cleanPath(newPath)
+	if o.exists(newPath) {
+		return experimentalsys.EEXIST
+	}
+	if errno := o.copyUp(oldPath); errno != 0 {
+		return errno
+	}
+	if errno := o.copyUp(path.Dir(newPath)); errno != 0 {
+		return errno
+	}
+	return o.upper.Link(oldPath, newPath)
+}
+
+// Symlink implements experimentalsys.FS.
+func (o *overlayFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	linkName = cleanPath(linkName)
+	if o.exists(linkName) {
+		return experimentalsys.EEXIST
+	}
+	if errno := o.copyUp(path.Dir(linkName)); errno != 0 {
+		return errno
+	}
+	return o.upper.Symlink(oldPath, linkName)
+}
+
+// Readlink implements experimentalsys.FS.
+func (o *overlayFS) Readlink(p string) (string, experimentalsys.Errno) {
+	p = cleanPath(p)
+	if o.inUpper(p) {
+		return o.upper.Readlink(p)
+	}
+	if o.isHidden(p) {
+		return "", experimentalsys.ENOENT
+	}
+	return o.lower.Readlink(p)
+}
+
+// Utimens implements experimentalsys.FS.
+func (o *overlayFS) Utimens(p string, atim, mtim int64) experimentalsys.Errno {
+	p = cleanPath(p)
+	if errno := o.copyUp(p); errno != 0 {
+		return errno
+	}
+	return o.upper.Utimens(p, atim, mtim)
+}
+
+// changes compares the overlay with its base.
+func (o *overlayFS) changes() []FileChange {
+	var changes []FileChange
+	o.diffUpper(".", &changes)
+	o.mu.Lock()
+	hidden := make([]string, 0, len(o.hidden))
+	for p := range o.hidden {
+		hidden = append(hidden, p)
+	}
+	o.mu.Unlock()
+	for _, p := range hidden {
+		if o.inUpper(p) || (p != "." && o.isHidden(path.Dir(p))) {
+			continue
+		}
+		if _, errno := o.lower.Lstat(p); errno == 0 {
+			changes = append(changes, FileChange{Path: p, Kind: FileDeleted})
+		}
+	}
+	return changes
+}
+
+// diffUpper compares the entries of the upper directory dir with the base.
+func (o *overlayFS) diffUpper(dir string, changes *[]FileChange) {
+	f, errno := o.upper.OpenFile(dir, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return
+	}
+	entries, _ := f.Readdir(-1)
+	f.Close()
+	for _, e := range entries {
+		p := path.Join(dir, e.Name)
+		st, _ := o.upper.Lstat(p)
+		base, errno := o.lower.Lstat(p)
+		switch {
+		case errno != 0:
+			*changes = append(*changes, FileChange{Path: p, Kind: FileCreated})
+		case !o.sameAsBase(p, st, base):
+			*changes = append(*changes, FileChange{Path: p, Kind: FileModified})
+		}
+		if st.Mode.IsDir() {
+			o.diffUpper(p, changes)
+			if errno == 0 && base.Mode.IsDir() && o.isHidden(p) {
+				o.diffMasked(p, changes)
+			}
+		}
+	}
+}
+
+// diffMasked reports the base files below the upper directory dir that are
+// masked by hiding dir and missing from the upper layer.
+func (o *overlayFS) diffMasked(dir string, changes *[]FileChange) {
+	f, errno := o.lower.OpenFile(dir, experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	if errno != 0 {
+		return
+	}
+	entries, _ := f.Readdir(-1)
+	f.Close()
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." {
+			continue
+		}
+		p := path.Join(dir, e.Name)
+		if !o.inUpper(p) {
+			*changes = append(*changes, FileChange{Path: p, Kind: FileDeleted})
+		} else if o.upperIsDir(p) && e.Type.IsDir() && !o.isHiddenSelf(p) {
+			o.diffMasked(p, changes)
+		}
+	}
+}
+
+// isHiddenSelf reports whether p itself is hidden, ignoring its parents.
+func (o *overlayFS) isHiddenSelf(p string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.hidden[p]
+}
+
+// sameAsBase reports whether the upper file p with stat st matches the
+// base file with stat base.
+func (o *overlayFS) sameAsBase(p string, st, base sys.Stat_t) bool {
+	if st.Mode.Type() != base.Mode.Type() || st.Mode.Perm() != base.Mode.Perm() {
+		return false
+	}
+	switch {
+	case st.Mode.IsDir():
+		return true
+	case st.Mode&fs.ModeSymlink != 0:
+		a, _ := o.upper.Readlink(p)
+		b, _ := o.lower.Readlink(p)
+		return a == b
+	}
+	if st.Size != base.Size {
+		return false
+	}
+	a, errA := readAllFS(o.upper, p)
+	b, errB := readAllFS(o.lower, p)
+	return errA == 0 && errB == 0 && bytes.Equal(a, b)
+}
+
+// readAllFS returns the contents of the file p of fsys.
+func readAllFS(fsys experimentalsys.FS, p string) ([]byte, experimentalsys.Errno) {
+	f, errno := fsys.OpenFile(p, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer f.Close()
+	var data []byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, errno := f.Read(buf)
+		data = append(data, buf[:n]...)
+		if errno != 0 {
+			return nil, errno
+		}
+		if n == 0 {
+			return data, 0
+		}
+	}
+}
+
+// overlayDir is an open directory of an overlayFS, listing the merged
+// entries of both layers.
+type overlayDir struct {
+	experimentalsys.UnimplementedFile
+
+	fs   *overlayFS
+	path string
+	st   sys.Stat_t
+
+	// dirents holds the entries not yet returned by Readdir, listed on the
+	// first call.
+	dirents []experimentalsys.Dirent
+	listed  bool
+}
+
+// _ is a type assertion
+var _ experimentalsys.File = (*overlayDir)(nil)
+
+// Dev implements experimentalsys.File.
+func (f *overlayDir) Dev() (uint64, experimentalsys.Errno) {
+	return f.st.Dev, 0
+}
+
+// Ino implements experimentalsys.File.
+func (f *overlayDir) Ino() (sys.Inode, experimentalsys.Errno) {
+	return f.st.Ino, 0
+}
+
+// IsDir implements experimentalsys.File.
+func (f *overlayDir) IsDir() (bool, experimentalsys.Errno) {
+	return true, 0
+}
+
+// Stat implements experimentalsys.File.
+func (f *overlayDir) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	return f.fs.Stat(f.path)
+}
+
+// Readdir implements experimentalsys.File.
+func (f *overlayDir) Readdir(n int) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	if !f.listed {
+		entries, errno := f.fs.mergedEntries(f.path)
+		if errno != 0 {
+			return nil, errno
+		}
+		f.dirents, f.listed = entries, true
+	}
+	if n <= 0 || n > len(f.dirents) {
+		n = len(f.dirents)
+	}
+	out := f.dirents[:n]
+	f.dirents = f.dirents[n:]
+	return out, 0
+}
+
+// Utimens implements experimentalsys.File.
+func (f *overlayDir) Utimens(atim, mtim int64) experimentalsys.Errno {
+	return f.fs.Utimens(f.path, atim, mtim)
+}
+
+// Close implements experimentalsys.File.
+func (f *overlayDir) Close() experimentalsys.Errno {
+	return 0
+}
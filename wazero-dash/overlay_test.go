@@ -0,0 +1,145 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+func TestOverlayDir(t *testing.T) {
+	ctx := context.Background()
+	host := t.TempDir()
+	if err := os.WriteFile(filepath.Join(host, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(host, "b.sh"), []byte("echo b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithOverlayDir(host, "/proj"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	for _, script := range []string{
+		`true >/proj/new`,
+		`chmod 755 /proj/b.sh; for f in /proj/*; do echo "${f##*/}"; done`,
+	} {
+		if _, err := d.Eval(ctx, script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+	}
+	if got := stdout.String(); got != "a.txt\nb.sh\nnew\n" {
+		t.Fatalf("unexpected output %q (stderr %q)", got, stderr.String())
+	}
+
+	want := []FileChange{{Path: "/proj/b.sh", Kind: FileModified}, {Path: "/proj/new", Kind: FileCreated}}
+	if got := d.FileChanges(); !slices.Equal(got, want) {
+		t.Fatalf("FileChanges: got %v, want %v", got, want)
+	}
+	if data, err := d.ReadFile("/proj/b.sh"); err != nil || string(data) != "echo b\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(host, "new")); !os.IsNotExist(err) {
+		t.Fatalf("overlay wrote to the host directory: %v", err)
+	}
+	if st, err := os.Stat(filepath.Join(host, "b.sh")); err != nil || st.Mode().Perm() != 0o644 {
+		t.Fatalf("overlay changed the host file: %v, %v", st, err)
+	}
+
+	if _, err := NewDash(ctx, WithOverlayDir(host, "proj")); err == nil {
+		t.Fatal("expected an error for a relative mount point")
+	}
+}
+
+func TestOverlayFSChanges(t *testing.T) {
+	o := newOverlayFS(&sysfs.AdaptFS{FS: fstest.MapFS{
+		"keep":          {Data: []byte("keep")},
+		"edit":          {Data: []byte("old")},
+		"gone":          {Data: []byte("gone")},
+		"moved":         {Data: []byte("moved")},
+		"dir/inner":     {Data: []byte("inner")},
+		"redo/old":      {Data: []byte("old")},
+		"same":          {Data: []byte("same")},
+		"empty/.keep":   {Data: nil},
+		"tree/sub/leaf": {Data: []byte("leaf")},
+	}})
+
+	writeFile := func(p, data string) {
+		t.Helper()
+		f, errno := o.OpenFile(p, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_TRUNC, 0o644)
+		if errno != 0 {
+			t.Fatalf("OpenFile %s: %v", p, errno)
+		}
+		if _, errno := f.Write([]byte(data)); errno != 0 {
+			t.Fatalf("Write %s: %v", p, errno)
+		}
+		f.Close()
+	}
+	check := func(errno experimentalsys.Errno) {
+		t.Helper()
+		if errno != 0 {
+			t.Fatal(errno)
+		}
+	}
+
+	writeFile("edit", "new")
+	writeFile("same", "same")
+	writeFile("dir/added", "added")
+	check(o.Unlink("gone"))
+	check(o.Rename("moved", "renamed"))
+	check(o.Unlink("redo/old"))
+	check(o.Rmdir("redo"))
+	check(o.Mkdir("redo", 0o755))
+	check(o.Rename("tree", "forest"))
+
+	if errno := o.Rmdir("dir"); errno != experimentalsys.ENOTEMPTY {
+		t.Fatalf("Rmdir of a non-empty directory: %v", errno)
+	}
+	if _, errno := o.Stat("gone"); errno != experimentalsys.ENOENT {
+		t.Fatalf("Stat of a removed file: %v", errno)
+	}
+	if _, errno := o.Stat("redo/old"); errno != experimentalsys.ENOENT {
+		t.Fatalf("recreated directory shows base files: %v", errno)
+	}
+	if data, errno := readAllFS(o, "forest/sub/leaf"); errno != 0 || string(data) != "leaf" {
+		t.Fatalf("renamed tree: %q, %v", data, errno)
+	}
+	if data, errno := readAllFS(o, "keep"); errno != 0 || string(data) != "keep" {
+		t.Fatalf("base file: %q, %v", data, errno)
+	}
+
+	var got []string
+	for _, c := range o.changes() {
+		got = append(got, c.Kind.String()+" "+c.Path)
+	}
+	slices.Sort(got)
+	want := []string{
+		"created dir/added",
+		"created forest",
+		"created forest/sub",
+		"created forest/sub/leaf",
+		"created renamed",
+		"deleted gone",
+		"deleted moved",
+		"deleted redo/old",
+		"deleted tree",
+		"modified edit",
+		"modified redo",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,100 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrSkipped is the error of a script RunParallel did not start because an
+// earlier script failed in FailFast mode.
+var ErrSkipped = errors.New("dash: skipped after an earlier failure")
+
+// Script is a script run by RunParallel.
+type Script struct {
+	// Name identifies the script in errors.
+	Name string
+	Code string
+	// Options configure the shell running the script, see Run.
+	Options []RunOption
+}
+
+// ScriptResult is the outcome of a Script.
+type ScriptResult struct {
+	Name string
+	Result
+	// Err is set if the script could not run to completion.
+	Err error
+}
+
+// ParallelMode selects how RunParallel handles failing scripts.
+type ParallelMode int
+
+const (
+	// CollectAll runs every script regardless of failures.
+	CollectAll ParallelMode = iota
+	// FailFast stops starting scripts after the first failure. Scripts
+	// already running complete.
+	FailFast
+)
+
+// RunParallel runs each script like Run, at most concurrency at a time, or
+// GOMAXPROCS if concurrency is not positive.
+//
+// The results are in the order of scripts. A script fails if it returns an
+// error or a non-zero exit status, reported as an *ExitError; the returned
+// error joins the failures.
+func RunParallel(ctx context.Context, scripts []Script, concurrency int, mode ParallelMode) ([]ScriptResult, error) {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	results := make([]ScriptResult, len(scripts))
+	var (
+		mu     sync.Mutex
+		failed bool
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, concurrency)
+	for i, s := range scripts {
+		results[i].Name = s.Name
+		sem <- struct{}{}
+		mu.Lock()
+		if failed && mode == FailFast {
+			results[i].Err = ErrSkipped
+		} else if ctx.Err() != nil {
+			results[i].Err = context.Cause(ctx)
+		}
+		mu.Unlock()
+		if results[i].Err != nil {
+			<-sem
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := Run(ctx, s.Code, s.Options...)
+			results[i].Result, results[i].Err = res, err
+			if err != nil || res.Status != 0 {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		switch {
+		case errors.Is(r.Err, ErrSkipped):
+		case r.Err != nil:
+			errs = append(errs, fmt.Errorf("dash: script %s: %w", r.Name, r.Err))
+		case r.Status != 0:
+			errs = append(errs, fmt.Errorf("dash: script %s: %w", r.Name, &ExitError{Status: r.Status}))
+		}
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,37 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunParallel(t *testing.T) {
+	ctx := context.Background()
+	scripts := []Script{
+		{Name: "a", Code: `echo "a $N"`, Options: []RunOption{WithEnv("N", "1")}},
+		{Name: "fail", Code: `echo broken; false`},
+		{Name: "c", Code: `echo c`},
+	}
+	results, err := RunParallel(ctx, scripts, 2, CollectAll)
+	if err == nil || !strings.Contains(err.Error(), "script fail: exit status 1") {
+		t.Fatalf("expected the failing script in the error, got %v", err)
+	}
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Name+"="+string(r.Stdout))
+	}
+	if got, want := strings.Join(out, ""), "a=a 1\nfail=broken\nc=c\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	results, err = RunParallel(ctx, scripts[1:], 1, FailFast)
+	if err == nil || !errors.Is(results[1].Err, ErrSkipped) || results[0].Status != 1 {
+		t.Fatalf("expected c to be skipped, got %+v (%v)", results, err)
+	}
+
+	if _, err := RunParallel(ctx, scripts[:1], 0, FailFast); err != nil {
+		t.Fatal("RunParallel:", err)
+	}
+}
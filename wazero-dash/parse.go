@@ -0,0 +1,570 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/dashast"
+)
+
+// Parse parses script as dash does, without executing any of it, and
+// returns its parse tree. A script dash fails to parse returns the
+// *SyntaxError of CheckSyntax.
+//
+// The tree is dash's own with the dash_parse export, aliases expanded and
+// command substitutions in words elided to $(...).
+// Other reactor builds have dash check the script and build the tree from
+// its tokens in Go, without expanding aliases; dash drops brace groups
+// without redirections from its tree, which that tree keeps.
+func (d *Dash) Parse(ctx context.Context, script string) (*dashast.File, error) {
+	if err := d.CheckSyntax(ctx, script); err != nil {
+		return nil, err
+	}
+	script = d.normalizeScript(script)
+	if d.dashParse != nil {
+		return d.parseTree(ctx, script)
+	}
+	return parseScript(script)
+}
+
+// parseTree calls dash_parse and decodes the returned tree.
+func (d *Dash) parseTree(ctx context.Context, script string) (*dashast.File, error) {
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	srcPtr, err := d.allocString(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	defer d.freePtr(ctx, srcPtr)
+	results, err := d.call(ctx, d.dashParse, uint64(srcPtr), uint64(len(script)))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(results[0])
+	if ptr == 0 {
+		return nil, errors.New("dash_parse failed")
+	}
+	defer d.freePtr(ctx, ptr)
+	return decodeParseTree([]byte(d.readCString(ptr)))
+}
+
+// treeNode is a node of the tree returned by dash_parse, with the fields
+// of dash's node types.
+type treeNode struct {
+	Type     string         `json:"type"`
+	Line     int            `json:"line"`
+	Assign   []string       `json:"assign"`
+	Args     []string       `json:"args"`
+	Redirect []treeRedirect `json:"redirect"`
+	Cmds     []*treeNode    `json:"cmds"`
+	Body     *treeNode      `json:"body"`
+	Left     *treeNode      `json:"left"`
+	Right    *treeNode      `json:"right"`
+	Test     *treeNode      `json:"test"`
+	Else     *treeNode      `json:"else"`
+	Var      string         `json:"var"`
+	Word     string         `json:"word"`
+	Items    []treeCaseItem `json:"items"`
+	Name     string         `json:"name"`
+}
+
+// treeRedirect is a redirection in the tree returned by dash_parse.
+type treeRedirect struct {
+	Op      string `json:"op"`
+	Fd      int    `json:"fd"`
+	Target  string `json:"target"`
+	HereDoc string `json:"heredoc"`
+}
+
+// treeCaseItem is a case item in the tree returned by dash_parse.
+type treeCaseItem struct {
+	Patterns []string  `json:"patterns"`
+	Body     *treeNode `json:"body"`
+}
+
+// decodeParseTree decodes the JSON document returned by dash_parse.
+func decodeParseTree(data []byte) (f *dashast.File, err error) {
+	var doc struct {
+		Tree *treeNode `json:"tree"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("dash_parse: %w", err)
+	}
+	defer recoverTreeError(&err)
+	return &dashast.File{List: treeList(doc.Tree)}, nil
+}
+
+// treeList converts a dash list, joined by semi nodes, to a List.
+func treeList(n *treeNode) *dashast.List {
+	l := &dashast.List{}
+	for n != nil && n.Type == "semi" {
+		l.Items = append(l.Items, treeList(n.Left).Items...)
+		n = n.Right
+	}
+	if n != nil {
+		l.Items = append(l.Items, treeAndOr(n))
+	}
+	return l
+}
+
+// treeAndOr converts a dash and-or list, whose and and or nodes group to
+// the left, to an AndOr.
+func treeAndOr(n *treeNode) *dashast.AndOr {
+	switch n.Type {
+	case "background":
+		ao := treeAndOr(n.Body)
+		ao.Background = true
+		return ao
+	case "and", "or":
+		ao := treeAndOr(n.Left)
+		ao.Ops = append(ao.Ops, map[string]string{"and": "&&", "or": "||"}[n.Type])
+		ao.Pipelines = append(ao.Pipelines, treePipeline(n.Right))
+		return ao
+	}
+	return &dashast.AndOr{Pipelines: []*dashast.Pipeline{treePipeline(n)}}
+}
+
+// treePipeline converts a dash pipeline, or a command alone, to a Pipeline.
+func treePipeline(n *treeNode) *dashast.Pipeline {
+	switch n.Type {
+	case "not":
+		p := treePipeline(n.Body)
+		p.Negated = true
+		return p
+	case "pipe":
+		p := &dashast.Pipeline{}
+		for _, c := range n.Cmds {
+			p.Commands = append(p.Commands, treeCommand(c))
+		}
+		return p
+	}
+	return &dashast.Pipeline{Commands: []dashast.Node{treeCommand(n)}}
+}
+
+// treeCommand converts a dash command node. Lists where a command is
+// expected were brace groups.
+func treeCommand(n *treeNode) dashast.Node {
+	if n == nil {
+		panic(treeError("dash_parse: missing command"))
+	}
+	redirects := treeRedirects(n.Redirect)
+	switch n.Type {
+	case "cmd":
+		return &dashast.Command{Line: n.Line, Assigns: treeWords(n.Assign), Args: treeWords(n.Args), Redirects: redirects}
+	case "if":
+		c := &dashast.If{Line: n.Line, Cond: treeList(n.Test), Then: treeList(n.Body), Redirects: redirects}
+		if n.Else != nil {
+			c.Else = treeList(n.Else)
+		}
+		return c
+	case "while", "until":
+		return &dashast.While{Line: n.Line, Until: n.Type == "until", Cond: treeList(n.Test), Body: treeList(n.Body), Redirects: redirects}
+	case "for":
+		return &dashast.For{Line: n.Line, Var: n.Var, Words: treeWords(n.Args), Body: treeList(n.Body), Redirects: redirects}
+	case "case":
+		c := &dashast.Case{Line: n.Line, Word: &dashast.Word{Text: n.Word}, Redirects: redirects}
+		for _, item := range n.Items {
+			c.Items = append(c.Items, &dashast.CaseItem{Patterns: treeWords(item.Patterns), Body: treeList(item.Body)})
+		}
+		return c
+	case "subshell":
+		return &dashast.Subshell{Line: n.Line, Body: treeList(n.Body), Redirects: redirects}
+	case "defun":
+		return &dashast.FuncDef{Line: n.Line, Name: n.Name, Body: treeCommand(n.Body)}
+	case "redir":
+		// Redirections of a compound command other than a subshell.
+		switch c := treeCommand(n.Body).(type) {
+		case *dashast.If:
+			c.Redirects = append(c.Redirects, redirects...)
+			return c
+		case *dashast.While:
+			c.Redirects = append(c.Redirects, redirects...)
+			return c
+		case *dashast.For:
+			c.Redirects = append(c.Redirects, redirects...)
+			return c
+		case *dashast.Case:
+			c.Redirects = append(c.Redirects, redirects...)
+			return c
+		}
+		return &dashast.Group{Line: n.Line, Body: treeList(n.Body), Redirects: redirects}
+	case "semi", "and", "or", "pipe", "not", "background":
+		return &dashast.Group{Line: n.Line, Body: treeList(n)}
+	}
+	panic(treeError("dash_parse: unknown node type: " + n.Type))
+}
+
+// treeWords converts the words of a dash node.
+func treeWords(texts []string) []*dashast.Word {
+	if texts == nil {
+		return nil
+	}
+	words := make([]*dashast.Word, len(texts))
+	for i, text := range texts {
+		words[i] = &dashast.Word{Text: text}
+	}
+	return words
+}
+
+// treeRedirects converts the redirections of a dash node.
+func treeRedirects(rs []treeRedirect) []*dashast.Redirect {
+	var redirects []*dashast.Redirect
+	for _, r := range rs {
+		redirects = append(redirects, &dashast.Redirect{Op: r.Op, Fd: r.Fd, Target: &dashast.Word{Text: r.Target}, HereDoc: r.HereDoc})
+	}
+	return redirects
+}
+
+// treeError is an error building a parse tree, raised with panic.
+type treeError string
+
+// recoverTreeError recovers a treeError into *err.
+func recoverTreeError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(treeError)
+		if !ok {
+			panic(r)
+		}
+		*err = errors.New(string(e))
+	}
+}
+
+// parseScript builds the parse tree of a script dash parses, from its
+// tokens.
+func parseScript(script string) (f *dashast.File, err error) {
+	defer recoverTreeError(&err)
+	p := &treeParser{l: &wordLexer{src: script}}
+	for i := 0; i < len(script); i++ {
+		if script[i] == '\n' {
+			p.newlines = append(p.newlines, i)
+		}
+	}
+	p.advance()
+	list := p.list()
+	if p.tok.text != "" {
+		p.unexpected()
+	}
+	return &dashast.File{List: list}, nil
+}
+
+// treeParser parses the tokens of a script.
+type treeParser struct {
+	l   *wordLexer
+	tok wordToken
+	// newlines are the offsets of the newlines of the script.
+	newlines []int
+}
+
+// advance reads the next token.
+func (p *treeParser) advance() {
+	tok, err := p.l.next()
+	if err != nil {
+		panic(treeError("dash: Parse: " + err.Error()))
+	}
+	p.tok = tok
+}
+
+// line returns the line of the current token.
+func (p *treeParser) line() int {
+	n := 0
+	for n < len(p.newlines) && p.newlines[n] < p.tok.pos {
+		n++
+	}
+	return n + 1
+}
+
+// unexpected raises an error for the current token.
+func (p *treeParser) unexpected() {
+	text := p.tok.text
+	if text == "" {
+		text = "end of file"
+	}
+	panic(treeError(fmt.Sprintf("dash: Parse: line %d: unexpected %q", p.line(), text)))
+}
+
+// isWord reports whether the current token is the word s.
+func (p *treeParser) isWord(s string) bool {
+	return !p.tok.op && p.tok.text == s
+}
+
+// isOp reports whether the current token is the operator s.
+func (p *treeParser) isOp(s string) bool {
+	return p.tok.op && p.tok.text == s
+}
+
+// expectWord consumes the word s.
+func (p *treeParser) expectWord(s string) {
+	if !p.isWord(s) {
+		p.unexpected()
+	}
+	p.advance()
+}
+
+// expectOp consumes the operator s.
+func (p *treeParser) expectOp(s string) {
+	if !p.isOp(s) {
+		p.unexpected()
+	}
+	p.advance()
+}
+
+// word consumes and returns a word.
+func (p *treeParser) word() *dashast.Word {
+	if p.tok.op || p.tok.text == "" {
+		p.unexpected()
+	}
+	w := &dashast.Word{Text: p.tok.text}
+	p.advance()
+	return w
+}
+
+// skipNewlines consumes newline tokens.
+func (p *treeParser) skipNewlines() {
+	for p.isOp("\n") {
+		p.advance()
+	}
+}
+
+// atListEnd reports whether the current token ends a list: the end of the
+// script or a token closing a compound command.
+func (p *treeParser) atListEnd() bool {
+	if p.tok.op {
+		return p.tok.text == ")" || p.tok.text == ";;"
+	}
+	switch p.tok.text {
+	case "", "then", "else", "elif", "fi", "do", "done", "esac", "}":
+		return true
+	}
+	return false
+}
+
+// list parses a list up to a token ending it.
+func (p *treeParser) list() *dashast.List {
+	l := &dashast.List{}
+	p.skipNewlines()
+	for !p.atListEnd() {
+		ao := p.andOr()
+		l.Items = append(l.Items, ao)
+		switch {
+		case p.isOp("&"):
+			ao.Background = true
+		case !p.isOp(";") && !p.isOp("\n"):
+			return l
+		}
+		p.advance()
+		p.skipNewlines()
+	}
+	return l
+}
+
+// andOr parses an and-or list.
+func (p *treeParser) andOr() *dashast.AndOr {
+	ao := &dashast.AndOr{Pipelines: []*dashast.Pipeline{p.pipeline()}}
+	for p.isOp("&&") || p.isOp("||") {
+		ao.Ops = append(ao.Ops, p.tok.text)
+		p.advance()
+		p.skipNewlines()
+		ao.Pipelines = append(ao.Pipelines, p.pipeline())
+	}
+	return ao
+}
+
+// pipeline parses a pipeline.
+func (p *treeParser) pipeline() *dashast.Pipeline {
+	pl := &dashast.Pipeline{}
+	if p.isWord("!") {
+		pl.Negated = true
+		p.advance()
+	}
+	pl.Commands = append(pl.Commands, p.command())
+	for p.isOp("|") {
+		p.advance()
+		p.skipNewlines()
+		pl.Commands = append(pl.Commands, p.command())
+	}
+	return pl
+}
+
+// command parses a command and the redirections of a compound command.
+func (p *treeParser) command() dashast.Node {
+	line := p.line()
+	var redirects *[]*dashast.Redirect
+	var n dashast.Node
+	switch {
+	case p.isOp("("):
+		p.advance()
+		c := &dashast.Subshell{Line: line, Body: p.list()}
+		p.expectOp(")")
+		n, redirects = c, &c.Redirects
+	case p.isWord("{"):
+		p.advance()
+		c := &dashast.Group{Line: line, Body: p.list()}
+		p.expectWord("}")
+		n, redirects = c, &c.Redirects
+	case p.isWord("if"):
+		c := p.ifCommand(line)
+		n, redirects = c, &c.Redirects
+	case p.isWord("while"), p.isWord("until"):
+		c := &dashast.While{Line: line, Until: p.tok.text == "until"}
+		p.advance()
+		c.Cond = p.list()
+		p.expectWord("do")
+		c.Body = p.list()
+		p.expectWord("done")
+		n, redirects = c, &c.Redirects
+	case p.isWord("for"):
+		c := p.forCommand(line)
+		n, redirects = c, &c.Redirects
+	case p.isWord("case"):
+		c := p.caseCommand(line)
+		n, redirects = c, &c.Redirects
+	default:
+		return p.simpleCommand(line)
+	}
+	for p.isRedirect() {
+		*redirects = append(*redirects, p.redirect())
+	}
+	return n
+}
+
+// ifCommand parses an if command, or the rest of one from an elif.
+func (p *treeParser) ifCommand(line int) *dashast.If {
+	p.advance()
+	c := &dashast.If{Line: line, Cond: p.list()}
+	p.expectWord("then")
+	c.Then = p.list()
+	switch {
+	case p.isWord("elif"):
+		elif := p.ifCommand(p.line())
+		c.Else = &dashast.List{Items: []*dashast.AndOr{{Pipelines: []*dashast.Pipeline{{Commands: []dashast.Node{elif}}}}}}
+		return c
+	case p.isWord("else"):
+		p.advance()
+		c.Else = p.list()
+	}
+	p.expectWord("fi")
+	return c
+}
+
+// forCommand parses a for loop.
+func (p *treeParser) forCommand(line int) *dashast.For {
+	p.advance()
+	c := &dashast.For{Line: line, Var: p.word().Text}
+	p.skipNewlines()
+	if p.isWord("in") {
+		p.advance()
+		c.Words = []*dashast.Word{}
+		for !p.tok.op && p.tok.text != "" {
+			c.Words = append(c.Words, p.word())
+		}
+		if !p.isOp(";") && !p.isOp("\n") {
+			p.unexpected()
+		}
+		p.advance()
+	} else if p.isOp(";") {
+		p.advance()
+	}
+	p.skipNewlines()
+	p.expectWord("do")
+	c.Body = p.list()
+	p.expectWord("done")
+	return c
+}
+
+// caseCommand parses a case command.
+func (p *treeParser) caseCommand(line int) *dashast.Case {
+	p.advance()
+	c := &dashast.Case{Line: line, Word: p.word()}
+	p.skipNewlines()
+	p.expectWord("in")
+	p.skipNewlines()
+	for !p.isWord("esac") {
+		if p.isOp("(") {
+			p.advance()
+		}
+		item := &dashast.CaseItem{Patterns: []*dashast.Word{p.word()}}
+		for p.isOp("|") {
+			p.advance()
+			item.Patterns = append(item.Patterns, p.word())
+		}
+		p.expectOp(")")
+		item.Body = p.list()
+		c.Items = append(c.Items, item)
+		if !p.isOp(";;") {
+			break
+		}
+		p.advance()
+		p.skipNewlines()
+	}
+	p.expectWord("esac")
+	return c
+}
+
+// simpleCommand parses a simple command, or a function definition.
+func (p *treeParser) simpleCommand(line int) dashast.Node {
+	c := &dashast.Command{Line: line}
+	for {
+		switch {
+		case p.isRedirect():
+			c.Redirects = append(c.Redirects, p.redirect())
+		case !p.tok.op && p.tok.text != "":
+			w := p.word()
+			if len(c.Args) == 0 && isAssignmentWord(w.Text) {
+				c.Assigns = append(c.Assigns, w)
+				continue
+			}
+			c.Args = append(c.Args, w)
+			if len(c.Args) == 1 && len(c.Assigns) == 0 && len(c.Redirects) == 0 && p.isOp("(") {
+				p.advance()
+				p.expectOp(")")
+				p.skipNewlines()
+				return &dashast.FuncDef{Line: line, Name: w.Text, Body: p.command()}
+			}
+		default:
+			if len(c.Assigns)+len(c.Args)+len(c.Redirects) == 0 {
+				p.unexpected()
+			}
+			return c
+		}
+	}
+}
+
+// isRedirect reports whether the current token is a redirection operator.
+func (p *treeParser) isRedirect() bool {
+	return p.tok.op && strings.ContainsAny(p.tok.text, "<>")
+}
+
+// redirect parses a redirection. The body of a here-document is read by
+// the lexer after the next newline.
+func (p *treeParser) redirect() *dashast.Redirect {
+	op := strings.TrimLeft(p.tok.text, "0123456789")
+	r := &dashast.Redirect{Op: op, Fd: 1}
+	if op[0] == '<' {
+		r.Fd = 0
+	}
+	if fd := p.tok.text[:len(p.tok.text)-len(op)]; fd != "" {
+		r.Fd, _ = strconv.Atoi(fd)
+	}
+	p.advance()
+	if p.tok.op || p.tok.text == "" {
+		p.unexpected()
+	}
+	r.Target = &dashast.Word{Text: p.tok.text}
+	if op == "<<" || op == "<<-" {
+		delim := strings.NewReplacer(`\`, "", `'`, "", `"`, "").Replace(p.tok.text)
+		p.l.heredocs = append(p.l.heredocs, &hereDoc{delim: delim, strip: op == "<<-", body: &r.HereDoc})
+	}
+	p.advance()
+	return r
+}
+
+// isAssignmentWord reports whether word is a NAME=value assignment.
+func isAssignmentWord(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && isShellName(name)
+}
@@ -0,0 +1,219 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/dashast"
+)
+
+// parseTestScript has each kind of node.
+const parseTestScript = `# setup
+X=1 Y="a b" cmd -v "$X" 2>/dev/null | grep x && ! false || echo no &
+f() { echo "$1"; }
+if a; then b; elif c; then d; else e; fi >out
+for i in 1 2; do echo $i; done
+for j; do :; done
+until read l; do :; done <in
+case $x in (a|b) echo ab;; *) ;; esac
+(cd /tmp; ls) 2>&1
+cat <<EOF; echo after
+body $x
+EOF
+cat <<-'E'
+	tabbed
+	E
+`
+
+// parseTestTree is parseTestScript as rendered by sexpr.
+const parseTestTree = `(and-or& (pipe (cmd@2 X=1 Y="a b" : cmd -v "$X" 2>/dev/null) (cmd@2 grep x)) && (pipe! (cmd@2 false)) || (cmd@2 echo no))
+(func@3 f (group@3 (cmd@3 echo "$1")))
+(if@4 (cmd@4 a) then (cmd@4 b) else (if@4 (cmd@4 c) then (cmd@4 d) else (cmd@4 e)) 1>out)
+(for@5 i in 1 2 do (cmd@5 echo $i))
+(for@6 j do (cmd@6 :))
+(until@7 (cmd@7 read l) do (cmd@7 :) 0<in)
+(case@8 $x (a|b (cmd@8 echo ab)) (*))
+(subshell@9 (cmd@9 cd /tmp) (cmd@9 ls) 2>&1)
+(cmd@10 cat 0<<EOF[body $x\n])
+(cmd@10 echo after)
+(cmd@13 cat 0<<-'E'[tabbed\n])`
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+	f, err := d.Parse(ctx, parseTestScript)
+	if err != nil {
+		t.Fatal("Parse:", err)
+	}
+	if got := sexpr(f); got != parseTestTree {
+		t.Fatalf("Parse tree:\n%s\nwant:\n%s", got, parseTestTree)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("Parse wrote %q, %q", stdout.String(), stderr.String())
+	}
+
+	if f, err := d.Parse(ctx, ""); err != nil || len(f.List.Items) != 0 {
+		t.Fatalf("Parse of an empty script: %v, %v", f, err)
+	}
+	_, err = d.Parse(ctx, "if true; then echo\nfi fi")
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Line != 2 {
+		t.Fatalf("expected a *SyntaxError on line 2, got %v", err)
+	}
+}
+
+func TestDecodeParseTree(t *testing.T) {
+	// The dash_parse tree of part of parseTestScript.
+	const doc = `{"tree": {"type": "semi",
+		"left": {"type": "background", "body": {"type": "or",
+			"left": {"type": "and",
+				"left": {"type": "pipe", "cmds": [
+					{"type": "cmd", "line": 2, "assign": ["X=1", "Y=\"a b\""], "args": ["cmd", "-v", "\"$X\""], "redirect": [{"op": ">", "fd": 2, "target": "/dev/null"}]},
+					{"type": "cmd", "line": 2, "args": ["grep", "x"]}]},
+				"right": {"type": "not", "body": {"type": "cmd", "line": 2, "args": ["false"]}}},
+			"right": {"type": "cmd", "line": 2, "args": ["echo", "no"]}}},
+		"right": {"type": "semi",
+			"left": {"type": "defun", "line": 3, "name": "f", "body": {"type": "cmd", "line": 3, "args": ["echo", "\"$1\""]}},
+			"right": {"type": "semi",
+				"left": {"type": "redir", "redirect": [{"op": ">", "fd": 1, "target": "out"}], "body": {"type": "if", "line": 4,
+					"test": {"type": "cmd", "line": 4, "args": ["a"]}, "body": {"type": "cmd", "line": 4, "args": ["b"]},
+					"else": {"type": "if", "line": 4, "test": {"type": "cmd", "line": 4, "args": ["c"]}, "body": {"type": "cmd", "line": 4, "args": ["d"]},
+						"else": {"type": "cmd", "line": 4, "args": ["e"]}}}},
+				"right": {"type": "semi",
+					"left": {"type": "case", "line": 8, "word": "$x", "items": [
+						{"patterns": ["a", "b"], "body": {"type": "cmd", "line": 8, "args": ["echo", "ab"]}},
+						{"patterns": ["*"]}]},
+					"right": {"type": "subshell", "line": 9, "redirect": [{"op": ">&", "fd": 2, "target": "1"}],
+						"body": {"type": "semi", "left": {"type": "cmd", "line": 9, "args": ["cd", "/tmp"]}, "right": {"type": "cmd", "line": 9, "args": ["ls"]}}}}}}}}`
+	f, err := decodeParseTree([]byte(doc))
+	if err != nil {
+		t.Fatal("decodeParseTree:", err)
+	}
+	// dash drops the brace group of f.
+	want := strings.Join([]string{
+		strings.Split(parseTestTree, "\n")[0],
+		"(func@3 f (cmd@3 echo \"$1\"))",
+		strings.Split(parseTestTree, "\n")[2],
+		strings.Split(parseTestTree, "\n")[6],
+		strings.Split(parseTestTree, "\n")[7],
+	}, "\n")
+	if got := sexpr(f); got != want {
+		t.Fatalf("decoded tree:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, doc := range []string{`{"tree": {"type": "nosuch"}}`, `{"tree": {"type": "pipe", "cmds": [null]}}`, `[`} {
+		if _, err := decodeParseTree([]byte(doc)); err == nil {
+			t.Errorf("decodeParseTree(%s): expected an error", doc)
+		}
+	}
+}
+
+// sexpr renders a parse tree compactly, one top-level item per line.
+func sexpr(f *dashast.File) string {
+	var lines []string
+	for _, item := range f.List.Items {
+		lines = append(lines, sexprNode(item))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sexprNode renders n.
+func sexprNode(n dashast.Node) string {
+	words := func(ws []*dashast.Word) string {
+		var s []string
+		for _, w := range ws {
+			s = append(s, w.Text)
+		}
+		return strings.Join(s, " ")
+	}
+	list := func(l *dashast.List) string {
+		var s []string
+		for _, item := range l.Items {
+			s = append(s, sexprNode(item))
+		}
+		return strings.Join(s, " ")
+	}
+	redirects := func(rs []*dashast.Redirect) string {
+		var s string
+		for _, r := range rs {
+			s += fmt.Sprintf(" %d%s%s", r.Fd, r.Op, r.Target.Text)
+			if r.HereDoc != "" {
+				s += "[" + strings.ReplaceAll(r.HereDoc, "\n", `\n`) + "]"
+			}
+		}
+		return s
+	}
+	switch n := n.(type) {
+	case *dashast.AndOr:
+		if len(n.Pipelines) == 1 && !n.Background {
+			return sexprNode(n.Pipelines[0])
+		}
+		s := "(and-or"
+		if n.Background {
+			s += "&"
+		}
+		for i, p := range n.Pipelines {
+			if i > 0 {
+				s += " " + n.Ops[i-1]
+			}
+			s += " " + sexprNode(p)
+		}
+		return s + ")"
+	case *dashast.Pipeline:
+		if len(n.Commands) == 1 && !n.Negated {
+			return sexprNode(n.Commands[0])
+		}
+		s := "(pipe"
+		if n.Negated {
+			s += "!"
+		}
+		for _, c := range n.Commands {
+			s += " " + sexprNode(c)
+		}
+		return s + ")"
+	case *dashast.Command:
+		s := fmt.Sprintf("(cmd@%d ", n.Line)
+		if len(n.Assigns) != 0 {
+			s += words(n.Assigns) + " : "
+		}
+		return s + words(n.Args) + redirects(n.Redirects) + ")"
+	case *dashast.If:
+		s := fmt.Sprintf("(if@%d %s then %s", n.Line, list(n.Cond), list(n.Then))
+		if n.Else != nil {
+			s += " else " + list(n.Else)
+		}
+		return s + redirects(n.Redirects) + ")"
+	case *dashast.While:
+		kind := "while"
+		if n.Until {
+			kind = "until"
+		}
+		return fmt.Sprintf("(%s@%d %s do %s%s)", kind, n.Line, list(n.Cond), list(n.Body), redirects(n.Redirects))
+	case *dashast.For:
+		s := fmt.Sprintf("(for@%d %s", n.Line, n.Var)
+		if n.Words != nil {
+			s += " in " + words(n.Words)
+		}
+		return s + " do " + list(n.Body) + redirects(n.Redirects) + ")"
+	case *dashast.Case:
+		s := fmt.Sprintf("(case@%d %s", n.Line, n.Word.Text)
+		for _, item := range n.Items {
+			s += " (" + strings.ReplaceAll(words(item.Patterns), " ", "|")
+			if body := list(item.Body); body != "" {
+				s += " " + body
+			}
+			s += ")"
+		}
+		return s + redirects(n.Redirects) + ")"
+	case *dashast.Group:
+		return fmt.Sprintf("(group@%d %s%s)", n.Line, list(n.Body), redirects(n.Redirects))
+	case *dashast.Subshell:
+		return fmt.Sprintf("(subshell@%d %s%s)", n.Line, list(n.Body), redirects(n.Redirects))
+	case *dashast.FuncDef:
+		return fmt.Sprintf("(func@%d %s %s)", n.Line, n.Name, sexprNode(n.Body))
+	}
+	return fmt.Sprintf("(%T)", n)
+}
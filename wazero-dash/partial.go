@@ -0,0 +1,49 @@
+package dash
+
+import "context"
+
+// CheckComplete reports whether src is a complete shell command, as parsed
+// by dash, rather than one needing more lines: an open if, for, while,
+// case, function body or brace group, an unterminated quote or here
+// document, or a trailing backslash or operator such as && or |. Nothing
+// is executed, and commands with other syntax errors are complete.
+func (d *Dash) CheckComplete(ctx context.Context, src string) (bool, error) {
+	if err := d.ready(); err != nil {
+		return false, err
+	}
+	return d.parses(ctx, d.normalizeScript(src))
+}
+
+// EvalPartial evaluates the REPL input line like Eval once it completes a
+// command, together with the lines given before. Until then it returns
+// needMore, evaluating nothing, so frontends can show the PS2 prompt and
+// read the next line. DiscardPartial drops the lines held.
+func (d *Dash) EvalPartial(ctx context.Context, line string) (status int, needMore bool, err error) {
+	src := line
+	if d.partial != "" {
+		src = d.partial + "\n" + line
+	}
+	complete, err := d.CheckComplete(ctx, src)
+	if err != nil {
+		return -1, false, err
+	}
+	if !complete {
+		d.partial = src
+		return 0, true, nil
+	}
+	d.partial = ""
+	status, err = d.Eval(ctx, src)
+	return status, false, err
+}
+
+// DiscardPartial drops the lines held by EvalPartial, as when Ctrl+C is
+// pressed at the PS2 prompt.
+func (d *Dash) DiscardPartial() {
+	d.partial = ""
+}
+
+// PS2 returns the continuation prompt for a REPL reading the rest of a
+// command: $PS2 as stored, "> " by default.
+func (d *Dash) PS2(ctx context.Context) (string, error) {
+	return d.GetVar(ctx, "PS2")
+}
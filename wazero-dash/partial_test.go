@@ -0,0 +1,76 @@
+package dash
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckComplete(t *testing.T) {
+	ctx := context.Background()
+	d, _, _ := newTestDash(t)
+	for src, want := range map[string]bool{
+		"echo a":                    true,
+		"":                          true,
+		"echo a \\\\":               true,
+		"# comment \\":              true,
+		"fi":                        true,
+		"done":                      true,
+		"}":                         true,
+		"echo a\n}":                 true,
+		"cat <<EOF\nbody\nEOF\n\\":  false,
+		"echo `a":                   false,
+		"cat <<EOF\nbody\nEOF":      true,
+		"if true; then":             false,
+		"for i in 1 2; do\n  echo":  false,
+		"while :; do":               false,
+		"case $x in":                false,
+		"f() {":                     false,
+		"{ echo":                    false,
+		"echo 'a":                   false,
+		"echo \"a":                  false,
+		"echo ${a":                  false,
+		"echo a \\":                 false,
+		"true &&":                   false,
+		"true |":                    false,
+		"cat <<EOF\nbody":           false,
+		"if true; then\necho a\nfi": true,
+	} {
+		got, err := d.CheckComplete(ctx, src)
+		if err != nil || got != want {
+			t.Errorf("CheckComplete(%q) = %v, %v; want %v", src, got, err, want)
+		}
+	}
+}
+
+func TestEvalPartial(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	if ps2, err := d.PS2(ctx); err != nil || ps2 != "> " {
+		t.Fatalf("PS2 = %q, %v", ps2, err)
+	}
+
+	for _, line := range []string{"for i in 1 2; do", "  echo \"line", "$i\""} {
+		if _, needMore, err := d.EvalPartial(ctx, line); err != nil || !needMore {
+			t.Fatalf("EvalPartial(%q) = %v, %v; want more", line, needMore, err)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("incomplete command ran: %q", stdout.String())
+	}
+	status, needMore, err := d.EvalPartial(ctx, "done; false")
+	if err != nil || needMore || status != 1 {
+		t.Fatalf("EvalPartial = %d, %v, %v", status, needMore, err)
+	}
+	if want := "line\n1\nline\n2\n"; stdout.String() != want {
+		t.Fatalf("got %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if _, needMore, _ := d.EvalPartial(ctx, "if true; then"); !needMore {
+		t.Fatal("expected more input")
+	}
+	d.DiscardPartial()
+	if status, needMore, err := d.EvalPartial(ctx, "echo fresh"); err != nil || needMore || status != 0 || stdout.String() != "fresh\n" {
+		t.Fatalf("EvalPartial after DiscardPartial = %d, %v, %v, %q", status, needMore, err, stdout.String())
+	}
+}
@@ -0,0 +1,279 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// PolicyDeniedStatus is the exit status of a command denied by the Policy.
+const PolicyDeniedStatus = 126
+
+// Policy is a complete sandbox configuration, usually loaded from a reviewed
+// JSON document with LoadPolicy and applied with WithPolicy:
+//
+//	{
+//	  "mounts": [{"host": "./src", "guest": "/src", "readOnly": true}],
+//	  "tempDir": {"maxBytes": 1048576},
+//	  "env": {"allow": ["HOME", "CI_*"], "set": {"MODE": "audit"}},
+//	  "commands": {"allow": ["git", "make"], "deny": ["curl"]},
+//	  "network": {"allow": ["example.com", "*.github.com"]},
+//	  "limits": {"maxArgBytes": 65536, "writeQuota": {"maxBytes": 1048576},
+//	             "commandTimeout": "30s", "commandTimeouts": {"make": "5m"}}
+//	}
+type Policy struct {
+	// Mounts are host directories mounted like WithDirMount.
+	Mounts []PolicyMount `json:"mounts,omitempty"`
+	// ReadOnly makes the whole guest file system read-only, see
+	// WithReadOnlyFS.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// ModeMapping applies to Mounts, see WithModeMapping.
+	ModeMapping *ModeMapping `json:"modeMapping,omitempty"`
+	// Symlinks applies to Mounts, see WithSymlinkPolicy.
+	Symlinks *SymlinkPolicy `json:"symlinks,omitempty"`
+	// TempDir configures the in-memory /tmp.
+	TempDir *PolicyTempDir `json:"tempDir,omitempty"`
+	Env     PolicyEnv      `json:"env,omitempty"`
+	// Commands restricts the commands dispatched to the host.
+	Commands PolicyCommands `json:"commands,omitempty"`
+	// Network restricts the hosts the embedder may fetch from on behalf of
+	// the shell, see AllowsHost. Nil allows all hosts. The guest itself has
+	// no network access.
+	Network *PolicyNetwork `json:"network,omitempty"`
+	Limits  PolicyLimits   `json:"limits,omitempty"`
+}
+
+// PolicyMount is a host directory mount of a Policy.
+type PolicyMount struct {
+	Host     string `json:"host"`
+	Guest    string `json:"guest"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// PolicyTempDir configures the in-memory /tmp of a Policy.
+type PolicyTempDir struct {
+	// MaxBytes caps the file data /tmp holds. Zero disables the cap.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// Disabled removes /tmp, see WithoutTempDir.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// PolicyEnv selects the environment variables exported at Init.
+type PolicyEnv struct {
+	// Allow lists the host environment variables passed to the shell, as
+	// names or path.Match patterns such as "CI_*".
+	Allow []string `json:"allow,omitempty"`
+	// Set exports fixed values, overriding allowed host variables.
+	Set map[string]string `json:"set,omitempty"`
+}
+
+// PolicyCommands restricts the commands dispatched to the host: the
+// ExecHandler and host commands such as chmod. Shell builtins and functions
+// are not affected. Entries are names or path.Match patterns.
+type PolicyCommands struct {
+	// Allow, if not empty, lists the only commands that may run.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists commands that may not run, even if allowed.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// PolicyNetwork lists the hosts that may be contacted, as names or
+// path.Match patterns such as "*.example.com". Empty allows none.
+type PolicyNetwork struct {
+	Allow []string `json:"allow"`
+}
+
+// PolicyLimits sets the resource limits of a Policy.
+type PolicyLimits struct {
+	MaxMemoryBytes    uint64         `json:"maxMemoryBytes,omitempty"`
+	MaxArgBytes       int            `json:"maxArgBytes,omitempty"`
+	MaxExpansionBytes int            `json:"maxExpansionBytes,omitempty"`
+	MaxHereDocBytes   int            `json:"maxHereDocBytes,omitempty"`
+	WriteQuota        WriteQuota     `json:"writeQuota,omitempty"`
+	CommandTimeout    PolicyDuration `json:"commandTimeout,omitempty"`
+	// CommandTimeouts sets the timeout per command name.
+	CommandTimeouts map[string]PolicyDuration `json:"commandTimeouts,omitempty"`
+}
+
+// PolicyDuration is a time.Duration written as a string such as "1m30s".
+type PolicyDuration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d PolicyDuration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *PolicyDuration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return errors.New("negative duration " + string(text))
+	}
+	*d = PolicyDuration(v)
+	return nil
+}
+
+// LoadPolicy reads a JSON Policy from r. Unknown fields and invalid values
+// are errors, so a mistyped setting cannot silently loosen the sandbox.
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("dash: policy: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("dash: policy: unexpected data after the policy document")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate reports the first invalid setting of p.
+func (p *Policy) Validate() error {
+	for _, m := range p.Mounts {
+		if m.Host == "" || !strings.HasPrefix(m.Guest, "/") {
+			return fmt.Errorf("dash: policy: mount %q:%q needs a host path and an absolute guest path", m.Host, m.Guest)
+		}
+	}
+	if p.TempDir != nil && p.TempDir.MaxBytes < 0 {
+		return errors.New("dash: policy: negative tempDir.maxBytes")
+	}
+	for name := range p.Env.Set {
+		if !isShellName(name) {
+			return fmt.Errorf("dash: policy: invalid variable name %q in env.set", name)
+		}
+	}
+	patterns := slices.Concat(p.Env.Allow, p.Commands.Allow, p.Commands.Deny)
+	if p.Network != nil {
+		patterns = append(patterns, p.Network.Allow...)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("dash: policy: invalid pattern %q", pattern)
+		}
+	}
+	if p.Limits.WriteQuota.MaxBytes < 0 || p.Limits.WriteQuota.MaxFiles < 0 {
+		return errors.New("dash: policy: negative write quota")
+	}
+	return nil
+}
+
+// isShellName reports whether s is a valid shell variable name.
+func isShellName(s string) bool {
+	for i, c := range s {
+		if c != '_' && !('a' <= c|0x20 && c|0x20 <= 'z') && !(i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// matchAny reports whether name matches one of patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsCommand reports whether the policy lets the host command name run.
+func (p *Policy) AllowsCommand(name string) bool {
+	if len(p.Commands.Allow) > 0 && !matchAny(p.Commands.Allow, name) {
+		return false
+	}
+	return !matchAny(p.Commands.Deny, name)
+}
+
+// AllowsHost reports whether the policy lets the embedder contact host,
+// such as the dash-wasi run subcommand fetching a script.
+func (p *Policy) AllowsHost(host string) bool {
+	return p.Network == nil || matchAny(p.Network.Allow, strings.ToLower(host))
+}
+
+// WithPolicy applies the sandbox policy p, which must be valid. Options
+// given after it override its settings.
+func WithPolicy(p *Policy) Option {
+	return func(o *options) {
+		o.policy = p
+		for _, m := range p.Mounts {
+			o.dirMounts = append(o.dirMounts, dirMount{host: m.Host, guest: m.Guest, readOnly: m.ReadOnly})
+		}
+		o.readOnlyFS = o.readOnlyFS || p.ReadOnly
+		if p.ModeMapping != nil {
+			o.modeMapping = *p.ModeMapping
+		}
+		if p.Symlinks != nil {
+			o.symlinks = *p.Symlinks
+		}
+		if t := p.TempDir; t != nil {
+			o.tempDir = !t.Disabled
+			o.tempDirMaxBytes = t.MaxBytes
+		}
+	}
+}
+
+// applyPolicy installs the limits and environment of the policy in s.
+func (s *dashState) applyPolicy(p *Policy) {
+	s.policy = p
+	s.sizeLimits = SizeLimits{
+		MaxMemoryBytes:    p.Limits.MaxMemoryBytes,
+		MaxArgBytes:       p.Limits.MaxArgBytes,
+		MaxExpansionBytes: p.Limits.MaxExpansionBytes,
+		MaxHereDocBytes:   p.Limits.MaxHereDocBytes,
+	}
+	s.quota = p.Limits.WriteQuota
+	s.commandTimeouts = CommandTimeouts{Default: time.Duration(p.Limits.CommandTimeout)}
+	for name, d := range p.Limits.CommandTimeouts {
+		if s.commandTimeouts.Commands == nil {
+			s.commandTimeouts.Commands = make(map[string]time.Duration)
+		}
+		s.commandTimeouts.Commands[name] = time.Duration(d)
+	}
+
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if isShellName(name) && matchAny(p.Env.Allow, name) {
+			env[name] = value
+		}
+	}
+	for name, value := range p.Env.Set {
+		env[name] = value
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		s.env = append(s.env, envAssignment{name: name, value: []envPart{{text: env[name]}}})
+	}
+}
+
+// checkPolicy reports whether the policy lets the host command argv[0] run,
+// printing an error if not.
+func checkPolicy(ctx context.Context, mod api.Module, state *dashState, argv []string) bool {
+	if state.policy == nil || state.policy.AllowsCommand(argv[0]) {
+		return true
+	}
+	commandError(ctx, mod, state, argv[0], "denied by policy")
+	state.publish(Event{Kind: EventPolicyDenied, Argv: argv})
+	return false
+}
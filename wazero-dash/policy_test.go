@@ -0,0 +1,94 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestPolicy(t *testing.T) {
+	host := t.TempDir()
+	if err := os.WriteFile(filepath.Join(host, "data"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POLICY_TEST_TOKEN", "secret")
+	t.Setenv("POLICY_CI_JOB", "42")
+	doc := `{
+  "mounts": [{"host": ` + strconv.Quote(host) + `, "guest": "/src", "readOnly": true}],
+  "tempDir": {"maxBytes": 4096},
+  "env": {"allow": ["POLICY_CI_*"], "set": {"MODE": "audit"}},
+  "commands": {"allow": ["git", "make*", "mktemp"], "deny": ["makepkg"]},
+  "network": {"allow": ["example.com", "*.github.com"]},
+  "limits": {"maxArgBytes": 64, "commandTimeout": "30s", "commandTimeouts": {"make": "5m"}}
+}`
+	p, err := LoadPolicy(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("LoadPolicy:", err)
+	}
+	for host, want := range map[string]bool{"example.com": true, "api.GitHub.com": true, "github.com": false, "evil.com": false} {
+		if got := p.AllowsHost(host); got != want {
+			t.Fatalf("AllowsHost(%q) = %v", host, got)
+		}
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(wazero.NewModuleConfig().WithStdout(&stdout).WithStderr(&stderr)), WithPolicy(p))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	var ran []string
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		ran = append(ran, argv[0])
+		return 0
+	})
+
+	script := `git status; make all; makepkg; curl x; echo "denied $?"
+echo "$MODE ${POLICY_CI_JOB:-} ${POLICY_TEST_TOKEN:-unset}"
+test -f /src/data && echo mounted; true >/src/new; test -e /src/new || echo read-only`
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if strings.Join(ran, " ") != "git make" {
+		t.Fatalf("unexpected commands %q", ran)
+	}
+	if got, want := stdout.String(), "denied 126\naudit 42 unset\nmounted\nread-only\n"; got != want {
+		t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "makepkg: denied by policy") || !strings.Contains(stderr.String(), "curl: denied by policy") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+	if d.SizeLimits().MaxArgBytes != 64 || d.state.commandTimeouts.limit("make") != 5*time.Minute || d.TempDirUsage().MaxBytes != 4096 {
+		t.Fatal("policy limits not applied")
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"mount": []}`,
+		`{"mounts": [{"host": ".", "guest": "relative"}]}`,
+		`{"commands": {"allow": ["["]}}`,
+		`{"env": {"set": {"BAD NAME": "x"}}}`,
+		`{"limits": {"commandTimeout": "soon"}}`,
+		`{"modeMapping": "sometimes"}`,
+		`{"symlinks": "chase"}`,
+		`{} {}`,
+	} {
+		if _, err := LoadPolicy(strings.NewReader(doc)); err == nil || !strings.HasPrefix(err.Error(), "dash: policy") {
+			t.Fatalf("%s: expected a policy error, got %v", doc, err)
+		}
+	}
+}
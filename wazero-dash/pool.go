@@ -0,0 +1,267 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Pool.Acquire once the pool is closed.
+var ErrPoolClosed = errors.New("dash: pool closed")
+
+// Pool keeps initialized shells ready for servers evaluating many short
+// scripts, which would otherwise pay for instantiating and initializing a
+// Dash per request. The module is compiled once, into the
+// SharedCompilationCache or the WithCompilationCache cache.
+type Pool struct {
+	opts options
+	// envFiles holds the contents of the WithEnvFile readers, read once.
+	envFiles [][]byte
+
+	// idle holds the instances ready for Acquire. A nil entry is a slot
+	// whose replacement failed; Acquire creates the instance itself.
+	idle chan *Dash
+
+	mu     sync.Mutex
+	closed bool
+	// refills tracks the replacements being created.
+	refills sync.WaitGroup
+}
+
+// PooledDash is a Dash acquired from a Pool. Call Release when done
+// instead of Close.
+type PooledDash struct {
+	*Dash
+	pool *Pool
+	once sync.Once
+	// settings are those of the shell when acquired.
+	settings poolSettings
+}
+
+// poolSettings are the settings of a shell that its user can change with
+// Dash methods, which Release restores.
+type poolSettings struct {
+	execHandler     ExecHandler
+	hostCalls       map[string]HostCallFunc
+	registered      map[string]BuiltinFunc
+	builtins        map[string]BuiltinFunc
+	commandNotFound CommandNotFoundHandler
+	commandTimeouts CommandTimeouts
+	sizeLimits      SizeLimits
+	quota           WriteQuota
+	ps4             PromptFunc
+	profiler        *Profiler
+	ps1             PromptFunc
+	normalizeCRLF   bool
+}
+
+// saveSettings returns the settings of d.
+func saveSettings(d *Dash) poolSettings {
+	s := d.state
+	return poolSettings{
+		execHandler:     s.execHandler,
+		hostCalls:       maps.Clone(s.hostCalls),
+		registered:      maps.Clone(s.registered),
+		builtins:        maps.Clone(s.builtins),
+		commandNotFound: s.commandNotFound,
+		commandTimeouts: s.commandTimeouts,
+		sizeLimits:      s.sizeLimits,
+		quota:           s.quota,
+		ps4:             s.ps4,
+		profiler:        s.profiler,
+		ps1:             d.ps1,
+		normalizeCRLF:   d.normalizeCRLF,
+	}
+}
+
+// restore sets the settings of d to ps.
+func (ps poolSettings) restore(d *Dash) {
+	s := d.state
+	s.execHandler = ps.execHandler
+	s.hostCalls = maps.Clone(ps.hostCalls)
+	s.registered = maps.Clone(ps.registered)
+	s.builtins = maps.Clone(ps.builtins)
+	s.commandNotFound = ps.commandNotFound
+	s.commandTimeouts = ps.commandTimeouts
+	s.sizeLimits = ps.sizeLimits
+	s.quota = ps.quota
+	s.ps4 = ps.ps4
+	s.profiler = ps.profiler
+	d.ps1 = ps.ps1
+	d.normalizeCRLF = ps.normalizeCRLF
+}
+
+// NewPool creates a pool of size initialized shells, each created by
+// NewDash with opts and initialized with the WithArgs arguments. The
+// streams of WithStdio are shared; servers usually use EvalCapture or
+// EvalWithInput instead. WithRuntime and WithCompiledModule are not
+// supported, as each instance has its own runtime.
+func NewPool(ctx context.Context, size int, opts ...Option) (*Pool, error) {
+	if size <= 0 {
+		return nil, errors.New("dash: pool size must be positive")
+	}
+	p := &Pool{opts: defaultOptions(), idle: make(chan *Dash, size)}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	if p.opts.runtime != nil {
+		return nil, errors.New("dash: NewPool cannot be combined with WithRuntime")
+	}
+	for _, r := range p.opts.envFiles {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		p.envFiles = append(p.envFiles, data)
+	}
+	for range size {
+		d, err := p.newInstance(ctx)
+		if err != nil {
+			_ = p.Close(ctx)
+			return nil, err
+		}
+		p.idle <- d
+	}
+	return p, nil
+}
+
+// newInstance creates and initializes a pool instance.
+func (p *Pool) newInstance(ctx context.Context) (*Dash, error) {
+	o := p.opts
+	o.envFiles = make([]io.Reader, len(p.envFiles))
+	for i, data := range p.envFiles {
+		o.envFiles[i] = bytes.NewReader(data)
+	}
+	d, err := newDashWithOptions(ctx, &o)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Init(ctx, nil); err != nil {
+		_ = d.Close(ctx)
+		return nil, err
+	}
+	return d, nil
+}
+
+// Acquire returns an initialized shell for the exclusive use of the
+// caller, waiting while all are in use.
+func (p *Pool) Acquire(ctx context.Context) (*PooledDash, error) {
+	select {
+	case d, ok := <-p.idle:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+		if d == nil {
+			var err error
+			if d, err = p.newInstance(ctx); err != nil {
+				p.putEmpty()
+				return nil, err
+			}
+		}
+		return &PooledDash{Dash: d, pool: p, settings: saveSettings(d)}, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// putEmpty returns an empty slot, for the next Acquire to fill.
+func (p *Pool) putEmpty() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.idle <- nil
+	}
+}
+
+// Release returns the shell to its pool, so no state set by the caller
+// leaks to the next user: the instance is Reset, its /tmp emptied and the
+// settings changed with its methods, such as handlers and limits, restored.
+// If that fails, or the pool's shells have file systems Reset cannot
+// restore, from WithMemRoot, an image, overlays or WithJournal, the
+// instance is closed and a fresh one is initialized in the background.
+// Release may be called more than once.
+func (d *PooledDash) Release() {
+	d.once.Do(func() {
+		p := d.pool
+		ctx := context.Background()
+		if p.resettable() && d.reset(ctx) == nil {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.closed {
+				_ = d.Dash.Close(ctx)
+				return
+			}
+			p.idle <- d.Dash
+			return
+		}
+		_ = d.Dash.Close(ctx)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.closed {
+			return
+		}
+		p.refills.Add(1)
+		go func() {
+			defer p.refills.Done()
+			nd, err := p.newInstance(context.Background())
+			if err != nil {
+				nd = nil
+			}
+			p.idle <- nd
+		}()
+	})
+}
+
+// resettable reports whether Release can reuse the pool's shells, which
+// have no in-memory file systems other than /tmp nor a journal.
+func (p *Pool) resettable() bool {
+	o := &p.opts
+	return !o.memRoot && o.image == nil && len(o.overlays) == 0 && !o.journal
+}
+
+// reset returns the shell to its state when acquired.
+func (d *PooledDash) reset(ctx context.Context) error {
+	// Builtin overrides are restored first, for Reset to reinstall them.
+	d.settings.restore(d.Dash)
+	if err := d.Reset(ctx); err != nil {
+		return err
+	}
+	if tmp := d.state.tmp; tmp != nil {
+		if errno := tmp.clearDir(".", func(string) bool { return false }); errno != 0 {
+			return errno
+		}
+	}
+	d.state.quotaUsage = QuotaUsage{}
+	return nil
+}
+
+// Close closes the idle shells of the pool. Shells still acquired are
+// closed when released. Acquire returns ErrPoolClosed afterwards.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	p.refills.Wait()
+	var errs []error
+	for {
+		select {
+		case d := <-p.idle:
+			if d != nil {
+				errs = append(errs, d.Close(ctx))
+			}
+			continue
+		default:
+		}
+		break
+	}
+	close(p.idle)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,135 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPool(ctx, 2, WithEnvFile(strings.NewReader("GREETING=hello\n")),
+		WithInitScript("greet() { echo \"$GREETING, $1\"; }"))
+	if err != nil {
+		t.Fatal("NewPool:", err)
+	}
+
+	// Every instance is preconfigured, including replacements.
+	var wg sync.WaitGroup
+	for i := range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := p.Acquire(ctx)
+			if err != nil {
+				t.Error("Acquire:", err)
+				return
+			}
+			defer d.Release()
+			stdout, _, _, err := d.EvalCapture(ctx, "greet w"+strconv.Itoa(i)+"; LEAK=1")
+			if err != nil {
+				t.Error("EvalCapture:", err)
+				return
+			}
+			if want := "hello, w" + strconv.Itoa(i) + "\n"; string(stdout) != want {
+				t.Errorf("expected %q, got %q", want, stdout)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Released shells do not leak state.
+	d, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	if v, err := d.GetVar(ctx, "LEAK"); err != nil || v != "" {
+		t.Fatalf("expected LEAK unset, got %q, %v", v, err)
+	}
+
+	// Acquire waits while all shells are in use.
+	d2, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.Acquire(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	d.Release()
+	d.Release()
+	d2.Release()
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+	if _, err := p.Acquire(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestPoolReleaseResets(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPool(ctx, 1)
+	if err != nil {
+		t.Fatal("NewPool:", err)
+	}
+	defer p.Close(ctx)
+
+	d, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	instance := d.Dash
+	d.SetExecHandler(func(ctx context.Context, argv []string) int { return 0 })
+	if err := d.RegisterBuiltin("leak", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int { return 0 }); err != nil {
+		t.Fatal("RegisterBuiltin:", err)
+	}
+	d.SetSizeLimits(SizeLimits{MaxArgBytes: 64})
+	if _, _, _, err := d.EvalCapture(ctx, "LEAK=1; echo x >/tmp/leak; mkdir /tmp/dir"); err != nil {
+		t.Fatal("EvalCapture:", err)
+	}
+	d.Release()
+
+	// The shell is reused, as it was acquired.
+	d, err = p.Acquire(ctx)
+	if err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	if d.Dash != instance {
+		t.Fatal("Release did not reuse the shell")
+	}
+	stdout, _, status, err := d.EvalCapture(ctx, `echo "${LEAK-unset}" /tmp/*`)
+	if err != nil || status != 0 || string(stdout) != "unset /tmp/*\n" {
+		t.Fatalf("state leaked: %d, %v, %q", status, err, stdout)
+	}
+	if _, ok := d.state.commands["leak"]; ok || d.state.execHandler != nil || d.SizeLimits() != (SizeLimits{}) {
+		t.Fatal("settings leaked")
+	}
+	d.Release()
+
+	// Shells whose root file system Reset cannot restore are replaced.
+	p2, err := NewPool(ctx, 1, WithMemRoot(0))
+	if err != nil {
+		t.Fatal("NewPool:", err)
+	}
+	defer p2.Close(ctx)
+	d, err = p2.Acquire(ctx)
+	if err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	instance = d.Dash
+	d.Release()
+	if d, err = p2.Acquire(ctx); err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	if d.Dash == instance {
+		t.Fatal("Release reused a shell with a memory root")
+	}
+	d.Release()
+}
@@ -0,0 +1,220 @@
+package dash
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSpanDetail is the longest Span.Detail recorded. Longer texts are
+// truncated.
+const maxSpanDetail = 256
+
+// SpanKind is the kind of a profiled span.
+type SpanKind int
+
+// Profiled span kinds.
+const (
+	// SpanEval is a call to Eval or EvalWithSource.
+	SpanEval SpanKind = iota
+	// SpanCommand is a command dispatched to the host: an ExecHandler
+	// command, a host command such as chmod, a builtin override or a host
+	// call.
+	SpanCommand
+	// SpanFunction is a shell function call in a script run by a Debugger.
+	SpanFunction
+	// SpanLine is a command of a script run by a Debugger, named after the
+	// line it starts on.
+	SpanLine
+)
+
+// String returns the kind name.
+func (k SpanKind) String() string {
+	switch k {
+	case SpanEval:
+		return "eval"
+	case SpanCommand:
+		return "command"
+	case SpanFunction:
+		return "function"
+	case SpanLine:
+		return "line"
+	default:
+		return "unknown"
+	}
+}
+
+// Span is a timed section of shell execution.
+type Span struct {
+	Kind SpanKind
+	// Name is "eval", the command or function name, or NAME:LINE for
+	// line spans.
+	Name string
+	// Detail is the evaluated script, the command line, the function
+	// arguments or the line text.
+	Detail   string
+	Start    time.Time
+	Duration time.Duration
+	// Depth is the number of spans enclosing this one.
+	Depth int
+}
+
+// Profiler records a timeline of the evaluations and commands of a shell,
+// to find what dominates the runtime of a slow script:
+//
+//	var p dash.Profiler
+//	d.SetProfiler(&p)
+//	d.Eval(ctx, script)
+//	p.WriteChromeTrace(f) // open in chrome://tracing or ui.perfetto.dev
+//
+// Builtins and shell functions run inside the guest and are accounted to the
+// enclosing span, unless the script runs through a Debugger, which records a
+// span per command and function call.
+//
+// Profiler is safe for concurrent use. The zero value is ready to use.
+type Profiler struct {
+	mu    sync.Mutex
+	spans []*Span
+	// open holds the spans not yet ended, innermost last.
+	open []*Span
+}
+
+// SetProfiler starts recording spans into p. nil stops profiling.
+func (d *Dash) SetProfiler(p *Profiler) {
+	d.state.profiler = p
+}
+
+// Spans returns the ended spans in start order.
+func (p *Profiler) Spans() []Span {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spans := make([]Span, 0, len(p.spans))
+	for _, s := range p.spans {
+		if !slices.Contains(p.open, s) {
+			spans = append(spans, *s)
+		}
+	}
+	return spans
+}
+
+// Reset discards the ended spans.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spans = slices.Clone(p.open)
+}
+
+// begin opens a span and returns a function ending it along with the
+// spans it encloses that are still open.
+func (p *Profiler) begin(kind SpanKind, name, detail string) func() {
+	if len(detail) > maxSpanDetail {
+		detail = detail[:maxSpanDetail]
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := &Span{Kind: kind, Name: name, Detail: detail, Start: time.Now(), Depth: len(p.open)}
+	p.spans = append(p.spans, s)
+	p.open = append(p.open, s)
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.endLocked(s)
+	}
+}
+
+// endLocked ends s and the open spans it encloses. Does nothing if s is
+// not open.
+func (p *Profiler) endLocked(s *Span) {
+	i := slices.Index(p.open, s)
+	if i < 0 {
+		return
+	}
+	now := time.Now()
+	for _, s := range p.open[i:] {
+		s.Duration = now.Sub(s.Start)
+	}
+	clear(p.open[i:])
+	p.open = p.open[:i]
+}
+
+// endFunction ends the innermost open function span, if no span other than
+// its lines is open inside it.
+func (p *Profiler) endFunction() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := len(p.open) - 1; i >= 0; i-- {
+		switch p.open[i].Kind {
+		case SpanFunction:
+			p.endLocked(p.open[i])
+			return
+		case SpanLine:
+		default:
+			return
+		}
+	}
+}
+
+// line starts the line span of a Debugger command, ending the previous
+// command of the same function.
+func (p *Profiler) line(name, detail string) {
+	p.mu.Lock()
+	if n := len(p.open); n > 0 && p.open[n-1].Kind == SpanLine {
+		p.endLocked(p.open[n-1])
+	}
+	p.mu.Unlock()
+	p.begin(SpanLine, name, detail)
+}
+
+// evalDetail returns the script recorded for an Eval of cmd: the original
+// script while a Debugger runs its instrumented copy.
+func (s *dashState) evalDetail(cmd string) string {
+	// EvalWithSource offsets line numbers with leading newlines.
+	cmd = strings.TrimLeft(cmd, "\n")
+	if g := s.debugger; g != nil && cmd == strings.TrimLeft(g.script, "\n") {
+		return strings.TrimLeft(strings.Join(g.lines, "\n"), "\n")
+	}
+	return cmd
+}
+
+// traceEvent is an event of the Chrome trace event format.
+type traceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat"`
+	Phase     string            `json:"ph"`
+	Timestamp float64           `json:"ts"`
+	Duration  float64           `json:"dur"`
+	PID       int               `json:"pid"`
+	TID       int               `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the ended spans as a Chrome trace event file,
+// viewable in chrome://tracing, Perfetto or speedscope. Timestamps are
+// relative to the first span.
+func (p *Profiler) WriteChromeTrace(w io.Writer) error {
+	spans := p.Spans()
+	events := make([]traceEvent, 0, len(spans))
+	for _, s := range spans {
+		ev := traceEvent{
+			Name:      s.Name,
+			Category:  s.Kind.String(),
+			Phase:     "X",
+			Timestamp: float64(s.Start.Sub(spans[0].Start).Nanoseconds()) / 1e3,
+			Duration:  float64(s.Duration.Nanoseconds()) / 1e3,
+			PID:       1,
+			TID:       1,
+		}
+		if s.Detail != "" {
+			ev.Args = map[string]string{"detail": s.Detail}
+		}
+		events = append(events, ev)
+	}
+	enc := json.NewEncoder(w)
+	return enc.Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
@@ -0,0 +1,108 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	ctx := context.Background()
+
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		time.Sleep(10 * time.Millisecond)
+		return 0
+	})
+	var p Profiler
+	d.SetProfiler(&p)
+	if _, err := d.Eval(ctx, "true; slow one; slow two"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	d.SetProfiler(nil)
+	if _, err := d.Eval(ctx, "slow three"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	spans := p.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", spans)
+	}
+	eval, one, two := spans[0], spans[1], spans[2]
+	if eval.Kind != SpanEval || eval.Detail != "true; slow one; slow two" || eval.Depth != 0 {
+		t.Fatalf("unexpected eval span %+v", eval)
+	}
+	for i, s := range []Span{one, two} {
+		if s.Kind != SpanCommand || s.Name != "slow" || s.Depth != 1 || s.Duration < 10*time.Millisecond {
+			t.Fatalf("unexpected command span %d: %+v", i, s)
+		}
+		if s.Start.Before(eval.Start) || s.Start.Add(s.Duration).After(eval.Start.Add(eval.Duration)) {
+			t.Fatalf("command span %d outside eval span", i)
+		}
+	}
+	if one.Detail != "slow one" || two.Start.Before(one.Start.Add(one.Duration)) {
+		t.Fatalf("unexpected command spans %+v %+v", one, two)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteChromeTrace(&buf); err != nil {
+		t.Fatal("WriteChromeTrace:", err)
+	}
+	var trace struct {
+		TraceEvents []struct {
+			Name string  `json:"name"`
+			Cat  string  `json:"cat"`
+			Ph   string  `json:"ph"`
+			Ts   float64 `json:"ts"`
+			Dur  float64 `json:"dur"`
+		} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal("invalid trace:", err)
+	}
+	if len(trace.TraceEvents) != 3 || trace.TraceEvents[1].Cat != "command" || trace.TraceEvents[1].Ph != "X" || trace.TraceEvents[1].Dur < 10e3 {
+		t.Fatalf("unexpected trace %s", buf.String())
+	}
+
+	p.Reset()
+	if len(p.Spans()) != 0 {
+		t.Fatal("expected no spans after Reset")
+	}
+}
+
+func TestProfilerDebugger(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	ctx := context.Background()
+
+	var p Profiler
+	d.SetProfiler(&p)
+	g, err := d.NewDebugger(ctx, "f() {\n  x=1\n  mktemp\n}\nf a b\ntrue\n", Source{Name: "p.sh"}, nil)
+	if err != nil {
+		t.Fatal("NewDebugger:", err)
+	}
+	if _, err := g.Run(ctx, DebugContinue); err != nil {
+		t.Fatal("Run:", err)
+	}
+
+	var got []string
+	for _, s := range p.Spans() {
+		got = append(got, fmt.Sprintf("%v %s %s %d", s.Kind, s.Name, s.Detail, s.Depth))
+	}
+	want := []string{
+		"eval eval f() {\n  x=1\n  mktemp\n}\nf a b\ntrue\n 0",
+		"line p.sh:1 f() { 1",
+		"line p.sh:5 f a b 1",
+		"function f a b 2",
+		"line p.sh:2 x=1 3",
+		"line p.sh:3 mktemp 3",
+		"command mktemp mktemp 4",
+		"line p.sh:6 true 1",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected spans\n%q\ngot\n%q", want, got)
+	}
+}
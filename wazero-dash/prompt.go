@@ -0,0 +1,88 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// ps4Marker is stored in PS4 while a PS4 hook is set. Dash expands it at
+// the start of each xtrace line and fd_write replaces it with the
+// rendered prompt.
+const ps4Marker = "\x01dash-wasi-ps4\x01"
+
+// defaultPS4 is dash's default xtrace prefix.
+const defaultPS4 = "+ "
+
+// PromptInfo is the shell state passed to prompt hooks.
+type PromptInfo struct {
+	// Cwd is the working directory ($PWD).
+	Cwd string
+	// ExitStatus is the status of the last command ($?).
+	ExitStatus int
+	// Time is when the prompt is rendered.
+	Time time.Time
+}
+
+// PromptFunc renders a prompt or trace prefix.
+type PromptFunc func(PromptInfo) string
+
+// SetPS1Func sets a hook rendering the primary prompt returned by PS1,
+// replacing the $PS1 variable. nil restores the variable.
+func (d *Dash) SetPS1Func(fn PromptFunc) {
+	d.ps1 = fn
+}
+
+// PS1 returns the primary prompt for a REPL: the SetPS1Func hook's result
+// if set, otherwise $PS1 as stored. Prompt escapes are not expanded.
+func (d *Dash) PS1(ctx context.Context) (string, error) {
+	if d.ps1 == nil {
+		return d.GetVar(ctx, "PS1")
+	}
+	info, err := d.promptInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	return d.ps1(info), nil
+}
+
+// SetPS4Func sets a hook rendering the xtrace (set -x) prefix of each traced
+// command. This sets $PS4 to an internal marker; assigning PS4 from a
+// script disables the hook. nil restores the default "+ ".
+func (d *Dash) SetPS4Func(ctx context.Context, fn PromptFunc) error {
+	ps4 := defaultPS4
+	if fn != nil {
+		ps4 = ps4Marker
+	}
+	if err := d.SetVar(ctx, "PS4", ps4); err != nil {
+		return err
+	}
+	d.state.ps4 = fn
+	return nil
+}
+
+// promptInfo collects the state passed to prompt hooks.
+func (d *Dash) promptInfo(ctx context.Context) (PromptInfo, error) {
+	cwd, err := d.GetVar(ctx, "PWD")
+	if err != nil {
+		return PromptInfo{}, err
+	}
+	status, err := d.GetExitStatus(ctx)
+	if err != nil {
+		return PromptInfo{}, err
+	}
+	return PromptInfo{Cwd: cwd, ExitStatus: status, Time: time.Now()}, nil
+}
+
+// renderPS4 replaces PS4 markers in stderr output with the hook's prompt.
+// Returns nil if p holds no marker.
+func (s *dashState) renderPS4(ctx context.Context, p []byte) []byte {
+	if s.ps4 == nil || s.dash == nil || !bytes.Contains(p, []byte(ps4Marker)) {
+		return nil
+	}
+	info, err := s.dash.promptInfo(ctx)
+	if err != nil {
+		return bytes.ReplaceAll(p, []byte(ps4Marker), []byte(defaultPS4))
+	}
+	return bytes.ReplaceAll(p, []byte(ps4Marker), []byte(s.ps4(info)))
+}
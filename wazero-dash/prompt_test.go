@@ -0,0 +1,55 @@
+package dash
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestPromptHooks(t *testing.T) {
+	d, _, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	if _, err := d.Eval(ctx, `PS1='% '`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if ps1, err := d.PS1(ctx); err != nil || ps1 != "% " {
+		t.Fatalf("expected PS1 %q, got %q (%v)", "% ", ps1, err)
+	}
+
+	render := func(info PromptInfo) string {
+		if info.Time.IsZero() {
+			t.Error("prompt info has no time")
+		}
+		return info.Cwd + " " + strconv.Itoa(info.ExitStatus) + "> "
+	}
+	d.SetPS1Func(render)
+	if _, err := d.Eval(ctx, "cd /tmp; false"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if ps1, err := d.PS1(ctx); err != nil || ps1 != "/tmp 1> " {
+		t.Fatalf("expected rendered PS1, got %q (%v)", ps1, err)
+	}
+
+	if err := d.SetPS4Func(ctx, render); err != nil {
+		t.Fatal("SetPS4Func:", err)
+	}
+	if _, err := d.Eval(ctx, "set -x; true; false; set +x"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	want := "/tmp 0> true\n/tmp 0> false\n/tmp 1> set +x\n"
+	if got := stderr.String(); got != want {
+		t.Fatalf("expected xtrace %q, got %q", want, got)
+	}
+
+	stderr.Reset()
+	if err := d.SetPS4Func(ctx, nil); err != nil {
+		t.Fatal("SetPS4Func:", err)
+	}
+	if _, err := d.Eval(ctx, "set -x; true; set +x"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stderr.String(); got != "+ true\n+ set +x\n" {
+		t.Fatalf("expected default xtrace, got %q", got)
+	}
+}
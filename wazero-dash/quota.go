@@ -0,0 +1,166 @@
+package dash
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// ErrQuotaExceeded is wrapped by the error returned by Eval when the guest
+// exceeded its WriteQuota.
+var ErrQuotaExceeded = errors.New("dash: write quota exceeded")
+
+// WriteQuota caps the file system writes of a session on managed mounts
+// (WithDirMount and /tmp). Mounts passed with WithFSConfig are not
+// limited.
+//
+// Zero values disable the corresponding limit.
+type WriteQuota struct {
+	// MaxBytes caps the total number of bytes written to files.
+	MaxBytes int64
+	// MaxFiles caps the number of files, directories and links created.
+	MaxFiles int64
+}
+
+// QuotaUsage is the file system usage counted against the WriteQuota.
+type QuotaUsage struct {
+	// Bytes is the total number of bytes written to files.
+	Bytes int64
+	// Files is the number of files, directories and links created.
+	Files int64
+}
+
+// QuotaExceededError is returned by Eval with the exit status when a write
+// was refused by the WriteQuota. The refused call fails in the guest with
+// EIO; the shell remains usable.
+type QuotaExceededError struct {
+	// Resource is the exhausted resource: "bytes" or "files".
+	Resource string
+	// Limit is the configured limit for Resource.
+	Limit int64
+}
+
+// Error implements error.
+func (e *QuotaExceededError) Error() string {
+	return ErrQuotaExceeded.Error() + ": " + e.Resource + " limit " + strconv.FormatInt(e.Limit, 10)
+}
+
+// Unwrap returns ErrQuotaExceeded.
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// SetWriteQuota configures the write quota for subsequent evaluations.
+// Usage counted so far is kept.
+func (d *Dash) SetWriteQuota(quota WriteQuota) {
+	d.state.quota = quota
+}
+
+// WriteQuota returns the configured write quota.
+func (d *Dash) WriteQuota() WriteQuota {
+	return d.state.quota
+}
+
+// QuotaUsage returns the usage counted against the write quota since the
+// shell was created.
+func (d *Dash) QuotaUsage() QuotaUsage {
+	return d.state.quotaUsage
+}
+
+// chargeBytes counts n written bytes, refusing them if over the quota.
+func (s *dashState) chargeBytes(n int64) bool {
+	if limit := s.quota.MaxBytes; limit != 0 && s.quotaUsage.Bytes+n > limit {
+		s.quotaHit = &QuotaExceededError{Resource: "bytes", Limit: limit}
+		return false
+	}
+	s.quotaUsage.Bytes += n
+	return true
+}
+
+// chargeFile counts a created file, refusing it if over the quota.
+func (s *dashState) chargeFile() bool {
+	if limit := s.quota.MaxFiles; limit != 0 && s.quotaUsage.Files+1 > limit {
+		s.quotaHit = &QuotaExceededError{Resource: "files", Limit: limit}
+		return false
+	}
+	s.quotaUsage.Files++
+	return true
+}
+
+// quotaFS enforces the write quota on a managed mount.
+type quotaFS struct {
+	experimentalsys.FS
+	state *dashState
+}
+
+// OpenFile implements experimentalsys.FS.
+func (f *quotaFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	const write = experimentalsys.O_WRONLY | experimentalsys.O_RDWR | experimentalsys.O_APPEND | experimentalsys.O_CREAT | experimentalsys.O_TRUNC
+	if flag&write == 0 {
+		return f.FS.OpenFile(p, flag, perm)
+	}
+	if flag&experimentalsys.O_CREAT != 0 {
+		if _, errno := f.FS.Stat(p); errno == experimentalsys.ENOENT && !f.state.chargeFile() {
+			return nil, experimentalsys.EIO
+		}
+	}
+	file, errno := f.FS.OpenFile(p, flag, perm)
+	if errno != 0 {
+		return nil, errno
+	}
+	return &quotaFile{File: file, state: f.state}, 0
+}
+
+// Mkdir implements experimentalsys.FS.
+func (f *quotaFS) Mkdir(p string, perm fs.FileMode) experimentalsys.Errno {
+	if !f.state.chargeFile() {
+		return experimentalsys.EIO
+	}
+	return f.FS.Mkdir(p, perm)
+}
+
+// Link implements experimentalsys.FS.
+func (f *quotaFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	if !f.state.chargeFile() {
+		return experimentalsys.EIO
+	}
+	return f.FS.Link(oldPath, newPath)
+}
+
+// Symlink implements experimentalsys.FS.
+func (f *quotaFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	if !f.state.chargeFile() {
+		return experimentalsys.EIO
+	}
+	return f.FS.Symlink(oldPath, linkName)
+}
+
+// quotaFile charges writes to an open file against the write quota.
+type quotaFile struct {
+	experimentalsys.File
+	state *dashState
+}
+
+// Write implements experimentalsys.File.
+func (f *quotaFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	if !f.state.chargeBytes(int64(len(buf))) {
+		return 0, experimentalsys.EIO
+	}
+	return f.File.Write(buf)
+}
+
+// Pwrite implements experimentalsys.File.
+func (f *quotaFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	if !f.state.chargeBytes(int64(len(buf))) {
+		return 0, experimentalsys.EIO
+	}
+	return f.File.Pwrite(buf, off)
+}
+
+// _ is a type assertion
+var (
+	_ experimentalsys.FS   = (*quotaFS)(nil)
+	_ experimentalsys.File = (*quotaFile)(nil)
+)
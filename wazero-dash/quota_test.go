@@ -0,0 +1,51 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func TestWriteQuota(t *testing.T) {
+	d, stdout, _ := newTestDash(t)
+	ctx := context.Background()
+
+	d.SetWriteQuota(WriteQuota{MaxFiles: 2})
+	status, err := d.Eval(ctx, "mktemp; mktemp -d; mktemp || echo refused")
+	var qerr *QuotaExceededError
+	if !errors.As(err, &qerr) || !errors.Is(err, ErrQuotaExceeded) || qerr.Resource != "files" || qerr.Limit != 2 {
+		t.Fatalf("expected a files quota error, got %v", err)
+	}
+	if status != 0 || !strings.HasSuffix(stdout.String(), "\nrefused\n") {
+		t.Fatalf("unexpected status %d, output %q", status, stdout.String())
+	}
+	if usage := d.QuotaUsage(); usage.Files != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// The shell remains usable and the error is reported once.
+	if status, err := d.Eval(ctx, "true"); status != 0 || err != nil {
+		t.Fatalf("expected success, got %d, %v", status, err)
+	}
+
+	// Bytes are charged per write.
+	state := &dashState{quota: WriteQuota{MaxBytes: 8}}
+	fsys := &quotaFS{FS: newMemFS(0), state: state}
+	f, errno := fsys.OpenFile("a", experimentalsys.O_WRONLY|experimentalsys.O_CREAT, 0o600)
+	if errno != 0 {
+		t.Fatal("OpenFile:", errno)
+	}
+	defer f.Close()
+	if _, errno := f.Write([]byte("12345")); errno != 0 {
+		t.Fatal("Write:", errno)
+	}
+	if _, errno := f.Write([]byte("6789")); errno != experimentalsys.EIO {
+		t.Fatalf("expected EIO, got %v", errno)
+	}
+	if state.quotaUsage != (QuotaUsage{Bytes: 5, Files: 1}) || state.quotaHit == nil || state.quotaHit.Resource != "bytes" {
+		t.Fatalf("unexpected usage %+v, hit %v", state.quotaUsage, state.quotaHit)
+	}
+}
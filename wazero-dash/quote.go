@@ -0,0 +1,246 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Quote returns s quoted for the shell, so that evaluating the result as a
+// word yields s unchanged. Words made of safe characters only are returned
+// as is; others are single-quoted, closing and reopening the quotes around
+// an escaped quote for each embedded single quote. printf %q uses the same
+// quoting.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return !isSafeShellChar(r) }) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// QuoteArgs returns args quoted by Quote and separated by spaces, so that
+// evaluating the result in a command's arguments yields args unchanged:
+//
+//	d.Eval(ctx, "set -- "+dash.QuoteArgs(args))
+func QuoteArgs(args []string) string {
+	var b strings.Builder
+	for i, a := range args {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(Quote(a))
+	}
+	return b.String()
+}
+
+// Quotef formats a shell command like fmt.Sprintf, quoting the values
+// interpolated so each one is a single word the shell does not expand:
+//
+//	%s  the value formatted with %v and quoted by Quote; a []string is
+//	    quoted by QuoteArgs
+//	%r  the value formatted with %v, unquoted, for trusted shell code
+//	%%  a percent sign
+//
+// Other verbs, flags, and a count of arguments not matching the verbs are
+// errors.
+func Quotef(format string, args ...any) (string, error) {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i == len(format) {
+			return "", errors.New("dash: Quotef: format ends with %")
+		}
+		verb := format[i]
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if verb != 's' && verb != 'r' {
+			return "", fmt.Errorf("dash: Quotef: unsupported verb %%%c", verb)
+		}
+		if n == len(args) {
+			return "", fmt.Errorf("dash: Quotef: missing argument for %%%c", verb)
+		}
+		arg := args[n]
+		n++
+		switch words, ok := arg.([]string); {
+		case verb == 'r':
+			fmt.Fprint(&b, arg)
+		case ok:
+			b.WriteString(QuoteArgs(words))
+		default:
+			b.WriteString(Quote(fmt.Sprint(arg)))
+		}
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("dash: Quotef: %d arguments for %d verbs", len(args), n)
+	}
+	return b.String(), nil
+}
+
+// Evalf evaluates the command formatted by Quotef, so values from Go
+// cannot inject shell code:
+//
+//	d.Evalf(ctx, "grep -e %s -- %s", pattern, files)
+func (d *Dash) Evalf(ctx context.Context, format string, args ...any) (int, error) {
+	cmd, err := Quotef(format, args...)
+	if err != nil {
+		return -1, err
+	}
+	return d.Eval(ctx, cmd)
+}
+
+// isSafeShellChar reports whether r needs no quoting in any position of a
+// word.
+func isSafeShellChar(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("%+,-./:@_", r)
+}
+
+// printfCommand formats with printf %q support, for reactor builds whose
+// printf builtin lacks it. The printf function installed by Init for those
+// forwards formats that may contain %q to it.
+const printfCommand = "@printf"
+
+// printfFunc wraps the printf builtin to add the %q conversion. The format
+// is the first or, after --, the second argument; only formats matching
+// printfQuoted go to the host, the others run the builtin directly.
+const printfFunc = `printf() {
+	case $1 in
+	--) case $2 in ` + printfQuoted + `) ` + printfCommand + ` "$@"; return ;; esac ;;
+	` + printfQuoted + `) ` + printfCommand + ` "$@"; return ;;
+	esac
+	command printf "$@"
+}`
+
+// printfQuoted is the case pattern of the formats with a %q conversion,
+// possibly with flags, a width or a precision. It also matches a few
+// formats without one, such as %%q, which the host formats like the
+// builtin.
+const printfQuoted = `*%q* | *%[-+\ #0-9.]*q*`
+
+// installPrintf defines the printf function adding %q if the printf
+// builtin lacks it, in reactor builds without the dash_quote export of
+// reactor/src/bltin/printf.c, and removes it otherwise.
+func (d *Dash) installPrintf(ctx context.Context) error {
+	script := printfFunc
+	if d.dashQuote != nil {
+		script = "unset -f printf"
+	}
+	_, err := d.evalQuiet(ctx, script)
+	return err
+}
+
+// runPrintf implements printfCommand: it rewrites each %q conversion of the
+// format to %s with the quoted argument and runs the printf builtin on the
+// result.
+func runPrintf(ctx context.Context, d *Dash, argv []string) int {
+	args := argv[1:]
+	if len(args) != 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		commandError(ctx, d.mod, d.state, "printf", "usage: printf format [arg ...]")
+		return 2
+	}
+	format, quoted := rewritePrintfFormat(args[0])
+	args = args[1:]
+	if n := len(quoted); n != 0 {
+		// The format is reused while arguments remain, and missing
+		// arguments are empty, for which %q prints ''.
+		args = slices.Clone(args)
+		passes := max(1, (len(args)+n-1)/n)
+		for len(args) < (passes-1)*n+lastTrue(quoted)+1 {
+			args = append(args, "")
+		}
+		for i := range args {
+			if quoted[i%n] {
+				args[i] = Quote(args[i])
+			}
+		}
+	}
+	var cmd strings.Builder
+	cmd.WriteString("command printf -- ")
+	cmd.WriteString(Quote(format))
+	for _, a := range args {
+		cmd.WriteByte(' ')
+		cmd.WriteString(Quote(a))
+	}
+	status, err := d.eval(ctx, cmd.String())
+	if err != nil {
+		return 1
+	}
+	return status
+}
+
+// lastTrue returns the index of the last set element of b, or -1.
+func lastTrue(b []bool) int {
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// rewritePrintfFormat replaces the %q conversions of a printf format with
+// %s. quoted has an element per argument consumed by one pass over the
+// format, set for the %q conversions; it is nil if the format consumes no
+// arguments.
+func rewritePrintfFormat(format string) (string, []bool) {
+	var b strings.Builder
+	var quoted []bool
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		b.WriteByte(c)
+		if c == '\\' && i+1 < len(format) {
+			i++
+			b.WriteByte(format[i])
+			continue
+		}
+		if c != '%' {
+			continue
+		}
+		// Flags, field width and precision, where * consumes an argument.
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0123456789.*", format[j]) >= 0 {
+			if format[j] == '*' {
+				quoted = append(quoted, false)
+			}
+			j++
+		}
+		if j == len(format) {
+			b.WriteString(format[i+1:])
+			break
+		}
+		b.WriteString(format[i+1 : j])
+		switch conv := format[j]; conv {
+		case '%':
+			b.WriteByte('%')
+		case 'q':
+			b.WriteByte('s')
+			quoted = append(quoted, true)
+		default:
+			b.WriteByte(conv)
+			quoted = append(quoted, false)
+		}
+		i = j
+	}
+	if !slices.Contains(quoted, true) {
+		quoted = nil
+	}
+	return b.String(), quoted
+}
@@ -0,0 +1,161 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// quoteValues are values that are hard to quote correctly.
+var quoteValues = []string{
+	"", "plain", "a b", "it's", `"double"`, `back\slash`, "$HOME", "${x:-y}",
+	"`id`", "$(id)", "a;b", "a|b&c", "<in >out", "*", "?[a]", "~", "~user",
+	"#comment", "!", "x=y", "-n", "--", "tab\there", "new\nline", "trail\n",
+	"'", "''", `'\''`, "\\", "ünïcødé", "%s%q%%", "a\x01b",
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	d, _, _ := newTestDash(t)
+	for _, v := range quoteValues {
+		if status, err := d.Eval(ctx, "v="+Quote(v)); err != nil || status != 0 {
+			t.Fatalf("Quote(%q) = %s: status %d, %v", v, Quote(v), status, err)
+		}
+		if got, err := d.GetVar(ctx, "v"); err != nil || got != v {
+			t.Fatalf("Quote(%q) = %s evaluated to %q, %v", v, Quote(v), got, err)
+		}
+	}
+}
+
+func TestQuoteArgsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	d, _, _ := newTestDash(t)
+	var all []byte
+	for c := 1; c < 256; c++ {
+		all = append(all, byte(c))
+	}
+	for _, args := range [][]string{nil, {""}, {"", ""}, quoteValues, {string(all)}} {
+		if status, err := d.Eval(ctx, "set -- "+QuoteArgs(args)); err != nil || status != 0 {
+			t.Fatalf("QuoteArgs(%q) = %s: status %d, %v", args, QuoteArgs(args), status, err)
+		}
+		params, err := d.positionalParams(ctx)
+		if err != nil {
+			t.Fatal("positionalParams:", err)
+		}
+		if !slices.Equal(params, args) && len(params)+len(args) != 0 {
+			t.Fatalf("QuoteArgs(%q) evaluated to %q", args, params)
+		}
+		// Each argument is one word, which expands to itself.
+		words, err := SplitWords(ctx, QuoteArgs(args))
+		if err != nil || len(words) != len(args) {
+			t.Fatalf("QuoteArgs(%q) split into %q, %v", args, words, err)
+		}
+		for i, w := range words {
+			if fields, err := d.Expand(ctx, w); err != nil || !slices.Equal(fields, []string{args[i]}) {
+				t.Fatalf("Expand(%s) = %q, %v; want %q", w, fields, err, args[i])
+			}
+		}
+	}
+}
+
+func TestPrintfQuote(t *testing.T) {
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithDiagnostics(func(*Diagnostics) {}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	for _, v := range quoteValues {
+		if err := d.SetVar(ctx, "v", v); err != nil {
+			t.Fatal("SetVar:", err)
+		}
+		out, _, status, err := d.EvalCapture(ctx, `printf '%q' "$v"`)
+		if err != nil || status != 0 {
+			t.Fatalf("printf %%q %q: status %d, %v", v, status, err)
+		}
+		if string(out) != Quote(v) {
+			t.Fatalf("printf %%q %q printed %s, Quote returns %s", v, out, Quote(v))
+		}
+		if status, err := d.Eval(ctx, "w="+string(out)); err != nil || status != 0 {
+			t.Fatalf("evaluating %s: status %d, %v", out, status, err)
+		}
+		if got, _ := d.GetVar(ctx, "w"); got != v {
+			t.Fatalf("printf %%q %q round-tripped to %q", v, got)
+		}
+	}
+
+	tests := []struct {
+		cmd, want string
+	}{
+		{`printf '%q %q\n' a 'b c' d`, "a 'b c'\nd ''\n"},
+		{`printf '[%6q]\n'`, "[    '']\n"},
+		{`printf '%s=%q\n' k 'v v'`, "k='v v'\n"},
+		{`printf '%d%%%q\n' 5 'x y'`, "5%'x y'\n"},
+		{`printf -- '%q\n' '$x'`, "'$x'\n"},
+		{`printf '%-4q|\n' a`, "a   |\n"},
+		{`printf '%%q %s\n' x`, "%q x\n"},
+		{`printf '%s q\n' plain`, "plain q\n"},
+	}
+	for _, tc := range tests {
+		out, stderr, status, err := d.EvalCapture(ctx, tc.cmd)
+		if err != nil || status != 0 || string(out) != tc.want {
+			t.Fatalf("%s: got %q, status %d, %v (stderr %q), want %q", tc.cmd, out, status, err, stderr, tc.want)
+		}
+	}
+
+	// Formats without %q run the builtin directly.
+	for _, cmd := range []string{`printf '%s\n' q`, `printf -- '%s\n' q`, `printf 'q%s\n' x`} {
+		d.state.recent = nil
+		if status, err := d.Eval(ctx, cmd); err != nil || status != 0 {
+			t.Fatalf("%s: status %d, %v", cmd, status, err)
+		}
+		for _, c := range d.state.recent {
+			if strings.Contains(c, printfCommand) {
+				t.Fatalf("%s went to the host: %q", cmd, d.state.recent)
+			}
+		}
+	}
+
+	if err := d.OverrideBuiltin(ctx, "printf", nil); err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+	if out, _, _, _ := d.EvalCapture(ctx, `printf '%q' 'a b'`); string(out) != "'a b'" {
+		t.Fatalf("restoring printf dropped %%q: %q", out)
+	}
+}
+
+func TestEvalf(t *testing.T) {
+	ctx := context.Background()
+	d, _, _ := newTestDash(t)
+	for _, v := range quoteValues {
+		if status, err := d.Evalf(ctx, "%r=%s  # 100%%", "v", v); err != nil || status != 0 {
+			t.Fatalf("Evalf(%q): status %d, %v", v, status, err)
+		}
+		if got, err := d.GetVar(ctx, "v"); err != nil || got != v {
+			t.Fatalf("Evalf(%q) set %q, %v", v, got, err)
+		}
+	}
+
+	if _, err := d.Evalf(ctx, "set -- %s %s", quoteValues, 42); err != nil {
+		t.Fatal("Evalf:", err)
+	}
+	params, err := d.positionalParams(ctx)
+	if err != nil {
+		t.Fatal("positionalParams:", err)
+	}
+	if want := append(slices.Clone(quoteValues), "42"); !slices.Equal(params, want) {
+		t.Fatalf("got %q, want %q", params, want)
+	}
+
+	for _, format := range []string{"echo %d", "echo %s", "echo %s %s %s", "echo 100%"} {
+		if _, err := Quotef(format, 1, 2); err == nil {
+			t.Fatalf("Quotef(%q): expected an error", format)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ReadTimeoutStatus is the exit status of read -t when the timeout expired,
+// 128 plus SIGALRM like bash.
+const ReadTimeoutStatus = 142
+
+// The reactor's read builtin takes -t, waiting for input with a function
+// of the env module, see reactor/src/miscbltin.c:
+//
+//	__fd_poll(fd, timeout, errno) -> ready
+//
+// It returns 1 once the guest descriptor fd is readable and 0 after
+// timeout milliseconds, or -1 and stores the error at the guest address
+// errno on failure.
+const fdPollImport = "__fd_poll"
+
+// readCommand implements read -t for reactor builds whose read builtin
+// lacks it. The read function installed by Init for those forwards calls
+// that may use -t to it.
+const readCommand = "@read"
+
+// readFunc wraps the read builtin to add the -t option. Only calls with a
+// word starting with -t or -rt, as the option is written, go to the host,
+// which parses the options exactly; the others run the builtin directly.
+const readFunc = `read() {
+	case " $* " in
+	*" -t"* | *" -rt"*) ` + readCommand + ` "$@" ;;
+	*) command read "$@" ;;
+	esac
+}`
+
+// installRead installs readFunc if the shell has a stdin given with
+// WithStdio, the only one read -t waits on, and the read builtin does not
+// import fdPollImport. Other shells keep the plain builtin.
+func (d *Dash) installRead(ctx context.Context) error {
+	if d.state.stdin == nil || importsFunc(d.compiled, "env", fdPollImport) {
+		return nil
+	}
+	_, err := d.evalQuiet(ctx, readFunc)
+	return err
+}
+
+// importsFunc reports whether compiled imports the function name of
+// module.
+func importsFunc(compiled wazero.CompiledModule, module, name string) bool {
+	for _, f := range compiled.ImportedFunctions() {
+		if m, n, _ := f.Import(); m == module && n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fdPollHost implements __fd_poll. Only the stdin given with WithStdio is
+// waited on, as by read -t with the read function; other descriptors are
+// readable at once. A SIGINT sent meanwhile makes stdin readable, for the
+// read to be interrupted.
+func fdPollHost(ctx context.Context, mod api.Module, fd uint32, timeout int32, errno uint32) int32 {
+	const fn = fdPollImport
+	state := hostState(ctx, fn)
+	h, ok := state.fds.host(fd)
+	if !ok {
+		return guestErrno(mod, errno, wasiErrnoBadf)
+	}
+	if h != fdStdin || state.stdin == nil || state.input != nil {
+		return 1
+	}
+	// Show prompts before waiting for input.
+	_ = state.flushStdout()
+	ready, err := state.stdin.wait(ctx, time.Now().Add(time.Duration(timeout)*time.Millisecond))
+	if err != nil {
+		const wasiErrnoIntr = 27
+		return guestErrno(mod, errno, wasiErrnoIntr)
+	}
+	if !ready {
+		return 0
+	}
+	return 1
+}
+
+// pollReader is the guest stdin given with WithStdio, readable with a
+// timeout. A timed read that expires leaves a background Read of the
+// underlying reader outstanding; its data is kept for the next read, so no
+// input is lost. With WithInterrupts, reads are woken by Interrupt the
+// same way.
+type pollReader struct {
+	r      io.Reader
+	sigint *sigintState
+
+	mu  sync.Mutex
+	buf []byte
+	err error
+	// pending is closed when the outstanding background Read completes,
+	// or nil if there is none.
+	pending chan struct{}
+}
+
+// newPollReader returns a pollReader reading from r, woken by the SIGINTs
+// of sigint if it is not nil.
+func newPollReader(r io.Reader, sigint *sigintState) *pollReader {
+	return &pollReader{r: r, sigint: sigint}
+}
+
+// Stdin returns the stdin given with WithStdio as the shell reads it, or
+// nil. Hosts reading stdin between evaluations, such as a REPL, read it
+// through this reader so input read ahead by timed-out or interrupted reads
+// is not lost.
+func (d *Dash) Stdin() io.Reader {
+	if d.state.stdin == nil {
+		return nil
+	}
+	return d.state.stdin
+}
+
+// Read implements io.Reader.
+func (p *pollReader) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.buf) != 0 {
+			n := copy(b, p.buf)
+			p.buf = p.buf[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		if p.err != nil {
+			err := p.err
+			p.mu.Unlock()
+			return 0, err
+		}
+		if p.pending == nil && p.sigint != nil {
+			p.pending = make(chan struct{})
+			go p.fill(p.pending)
+		}
+		pending := p.pending
+		p.mu.Unlock()
+		if pending == nil {
+			return p.r.Read(b)
+		}
+		if p.sigint == nil {
+			<-pending
+			continue
+		}
+		select {
+		case <-pending:
+		case <-p.sigint.wakeChan():
+			return 0, errReadInterrupted
+		}
+	}
+}
+
+// readByte returns the next byte, waiting at most until deadline or until
+// ctx is done. ok is false if it timed out.
+func (p *pollReader) readByte(ctx context.Context, deadline time.Time) (c byte, ok bool, err error) {
+	if ok, err := p.wait(ctx, deadline); !ok {
+		return 0, false, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case len(p.buf) == 0 && p.err != nil:
+		return 0, true, p.err
+	case len(p.buf) == 0:
+		return 0, true, errReadInterrupted
+	}
+	c = p.buf[0]
+	p.buf = p.buf[1:]
+	return c, true, nil
+}
+
+// wait waits until a byte or the end of input can be read, at most until
+// deadline or until ctx is done. With WithInterrupts, an Interrupt ends
+// the wait as if the input were readable, for the read to report it. ok is
+// false if it timed out or ctx is done.
+func (p *pollReader) wait(ctx context.Context, deadline time.Time) (ok bool, err error) {
+	for {
+		p.mu.Lock()
+		if len(p.buf) != 0 || p.err != nil {
+			p.mu.Unlock()
+			return true, nil
+		}
+		if p.pending == nil {
+			p.pending = make(chan struct{})
+			go p.fill(p.pending)
+		}
+		pending := p.pending
+		p.mu.Unlock()
+
+		var wake <-chan struct{}
+		if p.sigint != nil {
+			wake = p.sigint.wakeChan()
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-pending:
+			timer.Stop()
+		case <-wake:
+			timer.Stop()
+			return true, nil
+		case <-timer.C:
+			return false, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return false, context.Cause(ctx)
+		}
+	}
+}
+
+// fill reads from the underlying reader into the buffer, closing pending
+// when done.
+func (p *pollReader) fill(pending chan struct{}) {
+	data := make([]byte, 4096)
+	n, err := p.r.Read(data)
+	p.mu.Lock()
+	p.buf = append(p.buf, data[:n]...)
+	if err != nil {
+		p.err = err
+	}
+	p.pending = nil
+	p.mu.Unlock()
+	close(pending)
+}
+
+// runRead implements readCommand. It reads a line from the guest stdin
+// within the timeout, then lets the read builtin assign it, so field
+// splitting and backslash handling are dash's own. On timeout the partial
+// input is assigned and the status is ReadTimeoutStatus.
+//
+// The timeout applies to stdin given with WithStdio. Other input, such as
+// that of EvalWithInput, is read without one.
+func runRead(ctx context.Context, d *Dash, argv []string) int {
+	const name = "read"
+	args := argv[1:]
+	var opts []string
+	timeout, timed, raw := time.Duration(0), false, false
+	for len(args) != 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case 't', 'p':
+				val := arg[i+1:]
+				if val == "" {
+					if len(args) == 0 {
+						commandError(ctx, d.mod, d.state, name, "-"+arg[i:i+1]+": option requires an argument")
+						return 2
+					}
+					val, args = args[0], args[1:]
+				}
+				if arg[i] == 'p' {
+					opts = append(opts, "-p", val)
+				} else {
+					secs, err := strconv.ParseFloat(val, 64)
+					if err != nil || secs < 0 {
+						commandError(ctx, d.mod, d.state, name, val+": invalid timeout specification")
+						return 2
+					}
+					timeout, timed = time.Duration(secs*float64(time.Second)), true
+				}
+				i = len(arg)
+			case 'r':
+				raw = true
+				opts = append(opts, "-r")
+			default:
+				// Let the builtin report the option.
+				opts = append(opts, "-"+arg[i:i+1])
+			}
+		}
+	}
+	opts = append(opts, "--")
+	opts = append(opts, args...)
+
+	stdin := d.state.stdin
+	if !timed || stdin == nil || d.state.input != nil {
+		return d.readBuiltin(ctx, opts, nil)
+	}
+
+	var line bytes.Buffer
+	deadline := time.Now().Add(timeout)
+	for {
+		c, ok, err := stdin.readByte(ctx, deadline)
+		switch {
+		case !ok && err != nil:
+			return 130
+		case !ok:
+			// Dash's read keeps failing once it sees the end of its
+			// input, so the partial line is passed as a complete one.
+			if !raw && continued(append(line.Bytes(), '\n')) {
+				line.Truncate(line.Len() - 1)
+			}
+			line.WriteByte('\n')
+			d.readBuiltin(ctx, opts, &line)
+			return ReadTimeoutStatus
+		case err != nil:
+			// End of input: the builtin assigns the partial line and
+			// fails.
+			return d.readBuiltin(ctx, opts, &line)
+		}
+		line.WriteByte(c)
+		if c == '\n' && (raw || !continued(line.Bytes())) {
+			return d.readBuiltin(ctx, opts, &line)
+		}
+	}
+}
+
+// continued reports whether line, ending in a newline, ends in an escaping
+// backslash, so read without -r continues on the next line.
+func continued(line []byte) bool {
+	n := 0
+	for i := len(line) - 2; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// readBuiltin runs the read builtin with opts, reading from input instead
+// of the guest stdin if it is not nil.
+func (d *Dash) readBuiltin(ctx context.Context, opts []string, input io.Reader) int {
+	if input != nil {
+		prev := d.state.input
+		d.state.input = input
+		defer func() { d.state.input = prev }()
+	}
+	quoted := make([]string, len(opts))
+	for i, o := range opts {
+		quoted[i] = Quote(o)
+	}
+	status, err := d.eval(ctx, "command read "+strings.Join(quoted, " "))
+	if err != nil {
+		return 1
+	}
+	return status
+}
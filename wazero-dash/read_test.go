@@ -0,0 +1,115 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTimeout(t *testing.T) {
+	ctx := context.Background()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(pr, &stdout, &stderr))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	eval := func(script, want string) {
+		t.Helper()
+		stdout.Reset()
+		stderr.Reset()
+		if _, err := d.Eval(ctx, script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		if stdout.String() != want {
+			t.Fatalf("%s: got %q (stderr %q), want %q", script, stdout.String(), stderr.String(), want)
+		}
+	}
+
+	go pw.Write([]byte("one two\\\n three\n"))
+	eval(`read -t 5 a b; echo "$? a=$a b=$b"`, "0 a=one b=two three\n")
+
+	// The partial line is assigned on timeout.
+	go pw.Write([]byte("part"))
+	eval(`read -t 0.2 x; echo "$? x=$x"`, "142 x=part\n")
+
+	// Input arriving after a timeout is kept for the next read.
+	eval(`read -t 0.05 x; echo "$? x=$x"`, "142 x=\n")
+	go pw.Write([]byte("late\n"))
+	eval(`read -rt 5 x; echo "$? x=$x"`, "0 x=late\n")
+
+	eval(`read -t soon x; echo $?`, "2\n")
+	if !strings.Contains(stderr.String(), "invalid timeout") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	// read without -t and EvalWithInput are unaffected.
+	go pw.Write([]byte("plain\n"))
+	eval(`read x; echo "$? x=$x"`, "0 x=plain\n")
+	stdout.Reset()
+	if _, err := d.EvalWithInput(ctx, `read -t 1 x; echo "$? x=$x"`, strings.NewReader("given\n")); err != nil {
+		t.Fatal("EvalWithInput:", err)
+	}
+	if stdout.String() != "0 x=given\n" {
+		t.Fatalf("EvalWithInput: got %q", stdout.String())
+	}
+}
+
+func TestReadBuiltin(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	if _, err := d.Eval(ctx, "type read"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stdout.String(); got != "read is a shell builtin\n" {
+		t.Fatalf("without stdin: %q", got)
+	}
+
+	// Options and operands containing a t run the builtin directly.
+	var out bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(strings.NewReader("one two\n"), &out, &out), WithDiagnostics(func(*Diagnostics) {}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, `read -r text tail; echo "$text/$tail"`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := out.String(); got != "one/two\n" {
+		t.Fatalf("read -r text: %q", got)
+	}
+	for _, cmd := range d.state.recent {
+		if strings.Contains(cmd, readCommand) {
+			t.Fatalf("read -r text went to the host: %q", d.state.recent)
+		}
+	}
+}
+
+func TestPollReaderWait(t *testing.T) {
+	ctx := context.Background()
+	r, w := io.Pipe()
+	defer w.Close()
+	p := newPollReader(r, nil)
+	if ok, err := p.wait(ctx, time.Now().Add(20*time.Millisecond)); ok || err != nil {
+		t.Fatalf("wait without input = %v, %v", ok, err)
+	}
+	go w.Write([]byte("x"))
+	if ok, err := p.wait(ctx, time.Now().Add(time.Minute)); !ok || err != nil {
+		t.Fatalf("wait with input = %v, %v", ok, err)
+	}
+	if c, ok, err := p.readByte(ctx, time.Now()); c != 'x' || !ok || err != nil {
+		t.Fatalf("readByte = %q, %v, %v", c, ok, err)
+	}
+}
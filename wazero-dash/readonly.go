@@ -0,0 +1,154 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/tetratelabs/wazero/api"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// WithReadOnlyFS makes the whole guest file system read-only.
+//
+// Every WASI call that would modify a file system fails with EROFS, whatever
+// the mount configuration, including mounts passed with WithFSConfig. The
+// built-in chmod and mktemp fail the same way. Writes to the standard
+// streams are unaffected.
+func WithReadOnlyFS() Option {
+	return func(o *options) {
+		o.readOnlyFS = true
+	}
+}
+
+// wasiErrnoRofs is the WASI errno for a read-only file system.
+const wasiErrnoRofs = 69
+
+// WASI path_open flags that imply write access.
+const (
+	wasiOflagCreat   = 1 << 0
+	wasiOflagTrunc   = 1 << 3
+	wasiFdflagAppend = 1 << 0
+	wasiRightFdWrite = 1 << 6
+)
+
+// readOnlyWASIFuncs lists the WASI functions that modify a file system
+// through a path. path_open is handled separately by readOnlyPathOpen.
+var readOnlyWASIFuncs = []string{
+	"path_create_directory",
+	"path_filestat_set_times",
+	"path_link",
+	"path_remove_directory",
+	"path_rename",
+	"path_symlink",
+	"path_unlink_file",
+}
+
+// readOnlyFdWASIFuncs lists the WASI functions that modify an open file
+// given as their first parameter.
+var readOnlyFdWASIFuncs = []string{
+	"fd_allocate",
+	"fd_filestat_set_size",
+	"fd_filestat_set_times",
+}
+
+// readOnlyWASI replaces the WASI functions that modify a file system in
+// funcs, which maps their names to their implementations, with versions
+// failing with EROFS.
+func readOnlyWASI(funcs map[string]api.GoModuleFunction) error {
+	for _, name := range append(append([]string{"path_open"}, readOnlyWASIFuncs...), readOnlyFdWASIFuncs...) {
+		if funcs[name] == nil {
+			return errors.New("missing wasi export: " + name)
+		}
+	}
+	for _, name := range readOnlyWASIFuncs {
+		funcs[name] = api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			stack[0] = wasiErrnoRofs
+		})
+	}
+	for _, name := range readOnlyFdWASIFuncs {
+		orig := funcs[name]
+		funcs[name] = api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			if uint32(stack[0]) > fdStderr {
+				stack[0] = wasiErrnoRofs
+				return
+			}
+			orig.Call(ctx, mod, stack)
+		})
+	}
+	funcs["path_open"] = readOnlyPathOpen(funcs["path_open"])
+	return nil
+}
+
+// readOnlyPathOpen wraps WASI path_open, failing opens that could write.
+//
+// Stack: fd, dirflags, path, path_len, oflags, fs_rights_base,
+// fs_rights_inheriting, fdflags, result.opened_fd -> errno
+func readOnlyPathOpen(pathOpen api.GoModuleFunction) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		oflags, rights, fdflags := uint32(stack[4]), stack[5], uint32(stack[7])
+		if oflags&(wasiOflagCreat|wasiOflagTrunc) != 0 || fdflags&wasiFdflagAppend != 0 || rights&wasiRightFdWrite != 0 {
+			stack[0] = wasiErrnoRofs
+			return
+		}
+		pathOpen.Call(ctx, mod, stack)
+	}
+}
+
+// readOnlyFS fails every modifying call on a managed mount with EROFS,
+// so host commands observe WithReadOnlyFS like the guest does.
+type readOnlyFS struct {
+	experimentalsys.FS
+}
+
+// OpenFile implements experimentalsys.FS.
+func (f *readOnlyFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	const write = experimentalsys.O_WRONLY | experimentalsys.O_RDWR | experimentalsys.O_APPEND | experimentalsys.O_CREAT | experimentalsys.O_TRUNC
+	if flag&write != 0 {
+		return nil, experimentalsys.EROFS
+	}
+	return f.FS.OpenFile(p, flag, perm)
+}
+
+// Mkdir implements experimentalsys.FS.
+func (f *readOnlyFS) Mkdir(string, fs.FileMode) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Chmod implements experimentalsys.FS.
+func (f *readOnlyFS) Chmod(string, fs.FileMode) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Rename implements experimentalsys.FS.
+func (f *readOnlyFS) Rename(string, string) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Rmdir implements experimentalsys.FS.
+func (f *readOnlyFS) Rmdir(string) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Unlink implements experimentalsys.FS.
+func (f *readOnlyFS) Unlink(string) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Link implements experimentalsys.FS.
+func (f *readOnlyFS) Link(string, string) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Symlink implements experimentalsys.FS.
+func (f *readOnlyFS) Symlink(string, string) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// Utimens implements experimentalsys.FS.
+func (f *readOnlyFS) Utimens(string, int64, int64) experimentalsys.Errno {
+	return experimentalsys.EROFS
+}
+
+// _ is a type assertion
+var _ experimentalsys.FS = (*readOnlyFS)(nil)
@@ -0,0 +1,68 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestReadOnlyFS(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	managed, opaque := t.TempDir(), t.TempDir()
+	for _, dir := range []string{managed, opaque} {
+		if err := os.WriteFile(filepath.Join(dir, "f"), []byte("data\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := wazero.NewModuleConfig().WithStdout(&stdout).WithStderr(&stderr)
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config),
+		WithReadOnlyFS(),
+		WithDirMount(managed, "/work"),
+		WithFSConfig(wazero.NewFSConfig().WithDirMount(opaque, "/opaque")),
+	)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	script := `for f in /work/* /opaque/*; do test -f "$f" && echo "$f"; done
+echo x >/work/new
+echo x >>/opaque/f
+echo x >/tmp/new
+test -e /tmp/new || echo no tmp file
+mktemp || echo mktemp failed
+chmod 600 /work/f || echo chmod failed`
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	want := "/work/f\n/opaque/f\nno tmp file\nmktemp failed\nchmod failed\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+	}
+	if n := strings.Count(strings.ToLower(stderr.String()), "read-only file system"); n != 5 {
+		t.Fatalf("expected 5 EROFS errors, got %d: %q", n, stderr.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(managed, "new")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file created on the host, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(opaque, "f")); string(data) != "data\n" {
+		t.Fatalf("host file modified: %q", data)
+	}
+	if b, err := d.ModeBehavior(ctx, "/work/f"); err != nil || b.Chmod || !b.HostBacked {
+		t.Fatalf("unexpected mode behavior %+v (%v)", b, err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package repl runs an interactive read-eval-print loop on a shell of the
+// wazero-dash library, for applications embedding a sandboxed shell pane
+// with their own IO and theming:
+//
+//	err := repl.Run(ctx, d, repl.Options{
+//		Prompt: func(ctx context.Context, d *dash.Dash, continuation bool) string {
+//			return "\x1b[32msandbox\x1b[0m$ "
+//		},
+//		History: history,
+//		Output:  pane,
+//	})
+//
+// The loop reads lines until a command is complete, evaluates it with
+// Dash.Eval, records it in the History and prompts again, until the end of
+// the input or an exit or quit line. Commands write to the stdio given with
+// dash.WithStdio; Options.Output only gets the prompts and the REPL's own
+// messages.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/complete"
+)
+
+// Completer returns the completion candidates for the word ending at the
+// byte offset cursor in line.
+type Completer func(ctx context.Context, d *dash.Dash, line string, cursor int) []complete.Candidate
+
+// Options configures Run. The zero value reads lines from the shell's
+// stdin with PS1 and PS2 prompts on stderr, completing with
+// complete.Complete.
+type Options struct {
+	// Prompt returns the prompt to print, the continuation prompt if
+	// continuation is set. If nil, the shell's PS1 and PS2 are used.
+	Prompt func(ctx context.Context, d *dash.Dash, continuation bool) string
+	// Completer completes the word at the cursor. If nil,
+	// complete.Complete is used.
+	Completer Completer
+	// History records the evaluated commands, if not nil.
+	History dash.History
+	// Input reads the lines typed. If nil, lines are read from the shell's
+	// stdin with NewLineReader.
+	Input LineReader
+	// Output receives the prompts, the banner and the errors of the REPL.
+	// If nil, os.Stderr is used.
+	Output io.Writer
+	// Interrupts receives the user's Ctrl+C presses, as signal.Notify
+	// delivers them. The first one sends a SIGINT to the running command,
+	// or runs the INT trap at the prompt and drops the pending lines; the
+	// next ones abort commands that ignore it. The shell must be created
+	// with dash.WithInterrupts.
+	Interrupts <-chan os.Signal
+	// Banner is printed to Output before the first prompt.
+	Banner string
+}
+
+// LineReader reads the lines typed at the prompt. Applications with a line
+// editor implement it to offer their own editing and rendering.
+type LineReader interface {
+	// ReadLine shows prompt and returns the next line, without its newline,
+	// or io.EOF at the end of the input. complete returns the completion
+	// candidates for the word ending at cursor in line.
+	ReadLine(ctx context.Context, prompt string, complete func(line string, cursor int) []complete.Candidate) (string, error)
+}
+
+// Run runs the REPL on d, which must be initialized, until the end of the
+// input or an exit or quit line. ReadLine errors other than io.EOF are
+// returned; evaluation errors are printed to Output.
+func Run(ctx context.Context, d *dash.Dash, opts Options) error {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	in := opts.Input
+	if in == nil {
+		in = NewLineReader(d.Stdin(), out)
+	}
+	completer := opts.Completer
+	if completer == nil {
+		completer = complete.Complete
+	}
+	prompt := opts.Prompt
+	if prompt == nil {
+		prompt = shellPrompt
+	}
+	completeLine := func(line string, cursor int) []complete.Candidate {
+		return completer(ctx, d, line, cursor)
+	}
+	if opts.Banner != "" {
+		fmt.Fprintln(out, opts.Banner)
+	}
+
+	type readResult struct {
+		line string
+		err  error
+	}
+	// pending holds the lines of a command still incomplete.
+	pending := ""
+	for {
+		p := prompt(ctx, d, pending != "")
+		// Lines are read on request, so commands reading stdin get the
+		// input typed while they run.
+		read := make(chan readResult, 1)
+		go func(prompt string) {
+			line, err := in.ReadLine(ctx, prompt, completeLine)
+			read <- readResult{line, err}
+		}(p)
+		var r readResult
+	wait:
+		for {
+			select {
+			case r = <-read:
+				break wait
+			case <-opts.Interrupts:
+				fmt.Fprintln(out)
+				if err := d.Interrupt(ctx); err != nil {
+					fmt.Fprintf(out, "error: %v\n", err)
+				}
+				if pending != "" {
+					pending = ""
+					p = prompt(ctx, d, false)
+				}
+				fmt.Fprint(out, p)
+			}
+		}
+		if errors.Is(r.err, io.EOF) {
+			fmt.Fprintln(out)
+			return nil
+		}
+		if r.err != nil {
+			return r.err
+		}
+
+		line := r.line
+		if pending != "" {
+			line = pending + "\n" + line
+		} else if line == "exit" || line == "quit" {
+			return nil
+		} else if line == "" {
+			continue
+		}
+		// Read on at the continuation prompt until the command is complete.
+		if complete, err := d.CheckComplete(ctx, line); err == nil && !complete {
+			pending = line
+			continue
+		}
+		pending = ""
+
+		start := time.Now()
+		status, err := evalLine(ctx, d, line, opts.Interrupts, out)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+		if opts.History != nil {
+			if err := opts.History.Append(ctx, dash.HistoryEntry{Time: start, Command: line, Status: status}); err != nil {
+				fmt.Fprintf(out, "history: %v\n", err)
+			}
+		}
+	}
+}
+
+// shellPrompt returns the shell's PS1 or PS2, or "$ " if they cannot be
+// read.
+func shellPrompt(ctx context.Context, d *dash.Dash, continuation bool) string {
+	prompt, err := d.PS1(ctx)
+	if continuation {
+		prompt, err = d.PS2(ctx)
+	}
+	if err != nil {
+		return "$ "
+	}
+	return prompt
+}
+
+// evalLine evaluates line and returns its exit status. The first interrupt
+// received meanwhile is sent to the shell, the next ones abort the
+// evaluation. Lines stopped by the SIGINT and syntax errors, which dash
+// has reported on stderr, are not errors, as in sh.
+func evalLine(ctx context.Context, d *dash.Dash, line string, interrupts <-chan os.Signal, out io.Writer) (int, error) {
+	evalCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		status, err := d.Eval(evalCtx, line)
+		done <- result{status, err}
+	}()
+	interrupted := false
+	for {
+		select {
+		case r := <-done:
+			var se *dash.SyntaxError
+			if errors.Is(r.err, dash.ErrSIGINT) || errors.As(r.err, &se) {
+				return r.status, nil
+			}
+			return r.status, r.err
+		case <-interrupts:
+			if interrupted {
+				cancel()
+				continue
+			}
+			interrupted = true
+			fmt.Fprintln(out)
+			if err := d.Interrupt(ctx); err != nil {
+				return -1, err
+			}
+		}
+	}
+}
+
+// NewLineReader returns a LineReader reading lines from r and printing the
+// prompts to w, for terminals in their usual line mode. Tab completion
+// works within that mode: a line ending with a tab is completed instead of
+// returned. A single candidate, or the common prefix of several, which are
+// then listed, is shown after the prompt, and the next line typed
+// continues it:
+//
+//	$ ech<Tab><Enter>
+//	$ echo hi<Enter>
+//
+// reads "echo hi".
+func NewLineReader(r io.Reader, w io.Writer) LineReader {
+	return &lineReader{scanner: bufio.NewScanner(r), w: w}
+}
+
+// lineReader is the LineReader returned by NewLineReader.
+type lineReader struct {
+	scanner *bufio.Scanner
+	w       io.Writer
+}
+
+// ReadLine implements LineReader.
+func (l *lineReader) ReadLine(ctx context.Context, prompt string, complete func(line string, cursor int) []complete.Candidate) (string, error) {
+	line := ""
+	for {
+		fmt.Fprint(l.w, prompt+line)
+		if !l.scanner.Scan() {
+			if err := l.scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		line += l.scanner.Text()
+		if !strings.HasSuffix(line, "\t") {
+			return line, nil
+		}
+		line = strings.TrimSuffix(line, "\t")
+		line = l.complete(line, complete(line, len(line)))
+	}
+}
+
+// complete returns line with its last word completed from cands, listing
+// them if there are several.
+func (l *lineReader) complete(line string, cands []complete.Candidate) string {
+	if len(cands) == 0 {
+		return line
+	}
+	prefix := cands[0].Text
+	for _, c := range cands[1:] {
+		prefix = prefix[:commonPrefix(prefix, c.Text)]
+	}
+	if len(cands) == 1 && !strings.HasSuffix(prefix, "/") {
+		prefix += " "
+	}
+	if len(cands) > 1 {
+		for _, c := range cands {
+			if c.Description != "" {
+				fmt.Fprintf(l.w, "%s\t%s\n", c.Text, c.Description)
+			} else {
+				fmt.Fprintln(l.w, c.Text)
+			}
+		}
+	}
+	// A common prefix shorter than the word, as with quoting, keeps it.
+	start := cands[0].Start
+	if len(prefix) < len(line)-start {
+		return line
+	}
+	return line[:start] + prefix
+}
+
+// commonPrefix returns the length of the common prefix of a and b.
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
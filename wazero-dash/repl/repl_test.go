@@ -0,0 +1,201 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+	"github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash/complete"
+)
+
+func newTestDash(t *testing.T, stdin io.Reader, opts ...dash.Option) (*dash.Dash, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	var stdout bytes.Buffer
+	d, err := dash.NewDash(ctx, append(opts, dash.WithStdio(stdin, &stdout, &stdout))...)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	return d, &stdout
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	input := "x=1\n\nif true\nthen echo $x; fi\nfi\necho done\nexit\necho skipped\n"
+	d, stdout := newTestDash(t, strings.NewReader(input))
+	history := dash.NewMemHistory(0)
+	var out bytes.Buffer
+	err := Run(ctx, d, Options{
+		Prompt: func(ctx context.Context, d *dash.Dash, continuation bool) string {
+			if continuation {
+				return "... "
+			}
+			return ">>> "
+		},
+		History: history,
+		Output:  &out,
+		Banner:  "welcome",
+	})
+	if err != nil {
+		t.Fatal("Run:", err)
+	}
+	if got, want := stdout.String(), "1\n"; !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "done\n") {
+		t.Fatalf("stdout %q", got)
+	}
+	if got, want := out.String(), "welcome\n>>> >>> >>> ... >>> >>> >>> "; got != want {
+		t.Fatalf("output %q, want %q", got, want)
+	}
+
+	entries, _ := history.List(ctx, 0)
+	var lines []string
+	var statuses []int
+	for _, e := range entries {
+		lines = append(lines, e.Command)
+		statuses = append(statuses, e.Status)
+		if e.Time.IsZero() {
+			t.Fatalf("entry %q has no time", e.Command)
+		}
+	}
+	if want := []string{"x=1", "if true\nthen echo $x; fi", "fi", "echo done"}; !slices.Equal(lines, want) {
+		t.Fatalf("history %q, want %q", lines, want)
+	}
+	if statuses[2] != 2 {
+		t.Fatalf("syntax error recorded with status %d", statuses[2])
+	}
+}
+
+func TestRunDefaults(t *testing.T) {
+	ctx := context.Background()
+	d, stdout := newTestDash(t, strings.NewReader("PS1='% '\necho hi\n"))
+	ps1, _ := d.PS1(ctx)
+	var out bytes.Buffer
+	if err := Run(ctx, d, Options{Output: &out}); err != nil {
+		t.Fatal("Run:", err)
+	}
+	if stdout.String() != "hi\n" || out.String() != ps1+"% % \n" {
+		t.Fatalf("stdout %q, output %q", stdout.String(), out.String())
+	}
+}
+
+// scriptedReader is a LineReader returning lines and recording the prompts
+// and completions it was given.
+type scriptedReader struct {
+	lines   []string
+	prompts []string
+	cands   []complete.Candidate
+}
+
+func (r *scriptedReader) ReadLine(ctx context.Context, prompt string, complete func(string, int) []complete.Candidate) (string, error) {
+	r.prompts = append(r.prompts, prompt)
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	if line == "fail" {
+		return "", errors.New("editor failed")
+	}
+	r.cands = complete(line, len(line))
+	return line, nil
+}
+
+func TestRunInput(t *testing.T) {
+	ctx := context.Background()
+	d, _ := newTestDash(t, nil)
+	ps1, _ := d.PS1(ctx)
+	in := &scriptedReader{lines: []string{"GREETING_X=1", "echo $GREETING_", "fail", "echo after"}}
+	var called int
+	err := Run(ctx, d, Options{
+		Input:  in,
+		Output: io.Discard,
+		Completer: func(ctx context.Context, d *dash.Dash, line string, cursor int) []complete.Candidate {
+			called++
+			return complete.Complete(ctx, d, line, cursor)
+		},
+	})
+	if err == nil || err.Error() != "editor failed" {
+		t.Fatalf("Run returned %v", err)
+	}
+	if called != 2 || len(in.cands) != 1 || in.cands[0].Text != "$GREETING_X" {
+		t.Fatalf("completer called %d times, last candidates %+v", called, in.cands)
+	}
+	if !slices.Equal(in.prompts, []string{ps1, ps1, ps1}) {
+		t.Fatalf("prompts %q", in.prompts)
+	}
+}
+
+func TestLineReaderComplete(t *testing.T) {
+	ctx := context.Background()
+	d, _ := newTestDash(t, nil)
+	completeLine := func(line string, cursor int) []complete.Candidate {
+		return complete.Complete(ctx, d, line, cursor)
+	}
+	if _, err := d.Eval(ctx, "GREETING_A=1 GREETING_B=2"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	for _, tc := range []struct{ input, line, output string }{
+		{"ech\t\nhi\n", "echo hi", "$ $ echo "},
+		{"echo $GREET\t\nB\n", "echo $GREETING_B", "$ $GREETING_A\n$GREETING_B\n$ echo $GREETING_"},
+		{"echo -\t\n\n", "echo -n ", "$ $ echo -n "},
+		{"zzz\t\n\n", "zzz", "$ $ zzz"},
+	} {
+		var out bytes.Buffer
+		line, err := NewLineReader(strings.NewReader(tc.input), &out).ReadLine(ctx, "$ ", completeLine)
+		if err != nil {
+			t.Fatal("ReadLine:", err)
+		}
+		if line != tc.line || out.String() != tc.output {
+			t.Errorf("%q: line %q, output %q, want %q and %q", tc.input, line, out.String(), tc.line, tc.output)
+		}
+	}
+
+	if _, err := NewLineReader(strings.NewReader(""), io.Discard).ReadLine(ctx, "$ ", completeLine); err != io.EOF {
+		t.Fatalf("ReadLine at the end returned %v", err)
+	}
+}
+
+func TestRunInterrupt(t *testing.T) {
+	ctx := context.Background()
+	r, w := io.Pipe()
+	d, stdout := newTestDash(t, r, dash.WithInterrupts())
+	if _, err := d.Eval(ctx, `trap 'echo trapped' INT`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	interrupts := make(chan os.Signal)
+	done := make(chan error, 1)
+	continued := make(chan struct{}, 1)
+	go func() {
+		done <- Run(ctx, d, Options{
+			Prompt: func(ctx context.Context, d *dash.Dash, continuation bool) string {
+				if continuation {
+					continued <- struct{}{}
+				}
+				return "$ "
+			},
+			Output:     io.Discard,
+			Interrupts: interrupts,
+		})
+	}()
+	// At the prompt, an interrupt runs the trap and drops the pending lines.
+	w.Write([]byte("if true\n"))
+	<-continued
+	interrupts <- os.Interrupt
+	w.Write([]byte("echo next\n"))
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal("Run:", err)
+	}
+	if got := stdout.String(); got != "trapped\nnext\n" {
+		t.Fatalf("stdout %q", got)
+	}
+}
@@ -0,0 +1,41 @@
+package dash
+
+import (
+	"context"
+	"errors"
+)
+
+// Reset returns the shell to its state right after Init: variables,
+// functions, aliases, options, traps, the working directory and $? are
+// restored from a copy of the guest memory Init takes, which is much
+// faster than creating a new Dash. Builtin overrides and the PS4 hook are
+// reinstalled, and the lines held by EvalPartial are dropped.
+//
+// State kept by the host is not reset: files, including those of /tmp,
+// the journal, quota usage and the registered commands and handlers.
+func (d *Dash) Reset(ctx context.Context) error {
+	if err := d.ready(); err != nil {
+		return err
+	}
+	if d.state.depth != 0 {
+		return errors.New("dash: Reset cannot be called from a host command")
+	}
+	if d.initSnap == nil {
+		// Init failed after initializing the shell.
+		return ErrNotInitialized
+	}
+	d.rollback(d.initSnap, 0)
+	d.partial = ""
+	if s := d.state.sessions; s != nil {
+		s.saved = nil
+	}
+	for name := range d.state.builtins {
+		if err := d.installOverride(ctx, name); err != nil {
+			return err
+		}
+	}
+	if d.state.ps4 != nil {
+		return d.SetVar(ctx, "PS4", ps4Marker)
+	}
+	return nil
+}
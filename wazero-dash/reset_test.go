@@ -0,0 +1,58 @@
+package dash
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithInitScript("BASE=1\nbase() { echo base; }"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Reset(ctx); err != ErrNotInitialized {
+		t.Fatalf("expected ErrNotInitialized, got %v", err)
+	}
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	err = d.OverrideBuiltin(ctx, "echo", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		io.WriteString(stdout, "override\n")
+		return 0
+	})
+	if err != nil {
+		t.Fatal("OverrideBuiltin:", err)
+	}
+
+	if _, err := d.Eval(ctx, "BASE=2; X=1; f() { :; }; alias ll='ls -l'; cd /tmp; set -u; unset -f echo; false"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if err := d.Reset(ctx); err != nil {
+		t.Fatal("Reset:", err)
+	}
+	stdout, _, status, err := d.EvalCapture(ctx, `s=$?; [ "$BASE" = 1 ] && [ -z "${X-}" ] && ! command -v f >/dev/null && ! alias ll 2>/dev/null &&
+	[ "$PWD" = / ] && case $- in *u*) false;; esac && [ $s = 0 ] && base && echo x`)
+	if err != nil {
+		t.Fatal("EvalCapture:", err)
+	}
+	if status != 0 || string(stdout) != "override\noverride\n" {
+		t.Fatalf("expected post-Init state, got status %d stdout %q", status, stdout)
+	}
+}
+
+func TestResetDropsPartial(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	if _, needMore, err := d.EvalPartial(ctx, "if true; then"); err != nil || !needMore {
+		t.Fatalf("EvalPartial = %v, %v; want more", needMore, err)
+	}
+	if err := d.Reset(ctx); err != nil {
+		t.Fatal("Reset:", err)
+	}
+	if status, needMore, err := d.EvalPartial(ctx, "command echo fresh"); err != nil || needMore || status != 0 || stdout.String() != "fresh\n" {
+		t.Fatalf("EvalPartial after Reset = %d, %v, %v, %q", status, needMore, err, stdout.String())
+	}
+}
@@ -0,0 +1,133 @@
+package dash
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// OutputRouter is a writer passing lines that start with a registered
+// prefix to a handler and everything else to an underlying writer, so CI
+// style directives such as "::set-output" or "##vso[" can be intercepted:
+//
+//	out := dash.NewOutputRouter(os.Stdout)
+//	out.Route("::set-output ", func(line string) { ... })
+//	d, _ := dash.NewDash(ctx, dash.WithStdio(nil, out, os.Stderr))
+//
+// Output that cannot start a routed line is written through at once; a
+// partial line that may still match is held until its newline or Flush.
+// OutputRouter is safe for concurrent use.
+type OutputRouter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	routes  []outputRoute
+	pending []byte
+	// midLine is set while passing through a line that matched no route.
+	midLine bool
+}
+
+// outputRoute is a prefix registered with Route.
+type outputRoute struct {
+	prefix string
+	fn     func(line string)
+}
+
+// NewOutputRouter returns an OutputRouter writing unrouted output to w.
+func NewOutputRouter(w io.Writer) *OutputRouter {
+	return &OutputRouter{w: w}
+}
+
+// Route passes lines starting with prefix to fn, without the trailing
+// newline, instead of writing them. Routes are tried in registration order.
+// fn must not write to r.
+func (r *OutputRouter) Route(prefix string, fn func(line string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, outputRoute{prefix: prefix, fn: fn})
+}
+
+// Write implements io.Writer.
+func (r *OutputRouter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(p)
+	for len(p) != 0 {
+		i := bytes.IndexByte(p, '\n')
+		var chunk []byte
+		if i < 0 {
+			chunk, p = p, nil
+		} else {
+			chunk, p = p[:i+1], p[i+1:]
+		}
+		if err := r.write(chunk, i >= 0); err != nil {
+			return n - len(p) - len(chunk), err
+		}
+	}
+	return n, nil
+}
+
+// write handles chunk, the rest of the current line up to and including
+// its newline if complete is set.
+func (r *OutputRouter) write(chunk []byte, complete bool) error {
+	if r.midLine {
+		r.midLine = !complete
+		_, err := r.w.Write(chunk)
+		return err
+	}
+	r.pending = append(r.pending, chunk...)
+	line := string(bytes.TrimSuffix(r.pending, []byte("\n")))
+	if route := r.match(line); route != nil {
+		if complete {
+			r.pending = r.pending[:0]
+			route.fn(line)
+		}
+		return nil
+	}
+	if !complete && r.mayMatch(line) {
+		return nil
+	}
+	r.midLine = !complete
+	_, err := r.w.Write(r.pending)
+	r.pending = r.pending[:0]
+	return err
+}
+
+// match returns the route of line, or nil.
+func (r *OutputRouter) match(line string) *outputRoute {
+	for i := range r.routes {
+		if strings.HasPrefix(line, r.routes[i].prefix) {
+			return &r.routes[i]
+		}
+	}
+	return nil
+}
+
+// mayMatch reports whether the partial line could still grow into a routed
+// line.
+func (r *OutputRouter) mayMatch(line string) bool {
+	for _, route := range r.routes {
+		if strings.HasPrefix(route.prefix, line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush handles a held partial line as if it were complete.
+func (r *OutputRouter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return nil
+	}
+	line := string(r.pending)
+	if route := r.match(line); route != nil {
+		r.pending = r.pending[:0]
+		route.fn(line)
+		return nil
+	}
+	_, err := r.w.Write(r.pending)
+	r.pending = r.pending[:0]
+	return err
+}
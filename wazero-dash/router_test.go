@@ -0,0 +1,52 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOutputRouter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewOutputRouter(&out)
+	var outputs, vso []string
+	r.Route("::set-output ", func(line string) { outputs = append(outputs, line) })
+	r.Route("##vso[", func(line string) { vso = append(vso, line) })
+
+	for _, w := range []string{"plain line\n::set", "-output name=x\n##v", "so[task.setvariable]1\n", "::no match\n", "prompt> ", "same line\n", "##vs"} {
+		if _, err := r.Write([]byte(w)); err != nil {
+			t.Fatal("Write:", err)
+		}
+	}
+	if got, want := out.String(), "plain line\n::no match\nprompt> same line\n"; got != want {
+		t.Fatalf("expected %q before Flush, got %q", want, got)
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatal("Flush:", err)
+	}
+	if out.String() != "plain line\n::no match\nprompt> same line\n##vs" {
+		t.Fatalf("unexpected output after Flush %q", out.String())
+	}
+	if strings.Join(outputs, "|") != "::set-output name=x" || strings.Join(vso, "|") != "##vso[task.setvariable]1" {
+		t.Fatalf("unexpected routed lines %q %q", outputs, vso)
+	}
+
+	// Routed output from a script.
+	out.Reset()
+	outputs = nil
+	d, err := NewDash(context.Background(), WithStdio(nil, r, nil))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(context.Background())
+	if err := d.Init(context.Background(), nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(context.Background(), `echo building; printf '::set-output %s\n' version=1.2`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if out.String() != "building\n" || strings.Join(outputs, "|") != "::set-output version=1.2" {
+		t.Fatalf("unexpected output %q, routed %q", out.String(), outputs)
+	}
+}
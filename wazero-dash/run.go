@@ -0,0 +1,28 @@
+package dash
+
+import "context"
+
+// RunOption configures Run. Every Option of NewDash applies.
+type RunOption = Option
+
+// Result is the outcome of Run.
+type Result struct {
+	Stdout, Stderr []byte
+	Status         int
+}
+
+// Run evaluates script in a new, initialized shell and releases it, for
+// callers that want to run a script hermetically without managing a Dash.
+// The output is returned in the Result rather than written to streams.
+func Run(ctx context.Context, script string, opts ...RunOption) (Result, error) {
+	d, err := NewDash(ctx, opts...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		return Result{}, err
+	}
+	stdout, stderr, status, err := d.EvalCapture(ctx, script)
+	return Result{Stdout: stdout, Stderr: stderr, Status: status}, err
+}
@@ -0,0 +1,26 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	res, err := Run(ctx, `read name; echo "hello $name from $MODE"; test -f /data/x && test -f /data/missing`,
+		WithStdio(strings.NewReader("world\n"), nil, nil),
+		WithEnv("MODE", "test"),
+		WithFS(fstest.MapFS{"x": {}}, "/data"))
+	if err != nil {
+		t.Fatal("Run:", err)
+	}
+	if string(res.Stdout) != "hello world from test\n" || res.Status != 1 {
+		t.Fatalf("unexpected result %q, status %d (stderr %q)", res.Stdout, res.Status, res.Stderr)
+	}
+
+	if _, err := Run(ctx, "true", WithStdlib("missing")); err == nil {
+		t.Fatal("expected an error for an invalid option")
+	}
+}
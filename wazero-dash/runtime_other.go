@@ -0,0 +1,15 @@
+//go:build !wasip1
+
+package dash
+
+import "github.com/tetratelabs/wazero"
+
+// newRuntimeConfig returns the config of the runtimes created by NewDash,
+// using the compiler where wazero supports it.
+func newRuntimeConfig() wazero.RuntimeConfig {
+	return wazero.NewRuntimeConfig()
+}
+
+// yieldGuest lets other goroutines run while the guest evaluates. The Go
+// scheduler preempts the guest on its own here.
+func yieldGuest() {}
@@ -0,0 +1,24 @@
+//go:build wasip1
+
+package dash
+
+import (
+	"runtime"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// newRuntimeConfig returns the config of the runtimes created by NewDash.
+// The wazero compiler cannot target WebAssembly: built for wasip1, the
+// wrapper nests the dash reactor in the interpreter.
+func newRuntimeConfig() wazero.RuntimeConfig {
+	return wazero.NewRuntimeConfigInterpreter()
+}
+
+// yieldGuest lets other goroutines run while the guest evaluates. Nothing
+// preempts the goroutine running the interpreter in WebAssembly, so the
+// timers of context deadlines and the goroutine closing the module when
+// the context is done would otherwise wait for the evaluation to return.
+func yieldGuest() {
+	runtime.Gosched()
+}
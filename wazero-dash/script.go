@@ -0,0 +1,95 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// scriptExitFunc records the status of exit for EvalScript, which aliases
+// exit to it, in scriptExitVar. The reactor loses the status of exit N.
+const (
+	scriptExitFunc = "__dash_wasi_exit"
+	scriptExitVar  = "__dash_wasi_exit_status"
+)
+
+// EvalScript evaluates script as sh runs a script file, for tools using
+// Dash in place of /bin/sh script.sh. It differs from EvalWithSource in
+// that:
+//
+//   - exit N ends the script with status N, also from a function or trap,
+//     where Eval returns the status of the command before it;
+//   - the EXIT trap runs when the script ends, by reaching its end, exit,
+//     set -e or a syntax error, with $? set to the status, which exit in
+//     the trap replaces. The trap is then reset.
+//
+// The returned status is the one sh would exit with. A syntax error is
+// returned as a *SyntaxError with status 2, and a SIGINT sent by Interrupt
+// as ErrSIGINT with SIGINTStatus, after the EXIT trap; the SIGINT rolls the
+// shell back, so only an EXIT trap set before the script runs then. Other
+// errors are returned at once. The shell stays usable afterwards.
+//
+// exit is aliased while the script runs, unless the shell has an alias of
+// that name, so \exit and command exit keep the reactor's behavior.
+func (d *Dash) EvalScript(ctx context.Context, script string, src Source) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	var aliased []byte
+	_, err := d.captureStderr(func() error {
+		var err error
+		aliased, err = d.evalQuiet(ctx, "command alias exit")
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	if len(aliased) == 0 {
+		if _, err := d.evalQuiet(ctx, scriptExitFunc+`() { `+scriptExitVar+`=${1-$?}; \exit "$@"; }
+alias exit=`+scriptExitFunc); err != nil {
+			return -1, err
+		}
+		defer func() { _, _ = d.evalQuiet(ctx, "unalias exit") }()
+	}
+	defer func() { _, _ = d.evalQuiet(ctx, "unset "+scriptExitVar) }()
+
+	if _, err := d.evalQuiet(ctx, "unset "+scriptExitVar); err != nil {
+		return -1, err
+	}
+	status, err := d.EvalWithSource(ctx, script, src)
+	var se *SyntaxError
+	if err != nil && !errors.As(err, &se) && !errors.Is(err, ErrSIGINT) {
+		return status, err
+	}
+	status = d.scriptExitStatus(ctx, status)
+
+	action, ok, terr := d.trapAction(ctx, "EXIT")
+	if terr != nil || !ok || action == "" {
+		return status, errors.Join(err, terr)
+	}
+	if _, terr := d.evalQuiet(ctx, "trap - EXIT; unset "+scriptExitVar); terr != nil {
+		return status, errors.Join(err, terr)
+	}
+	if terr := d.setExitStatus(ctx, status); terr != nil {
+		return status, errors.Join(err, terr)
+	}
+	if _, terr := d.Eval(ctx, action); terr != nil && !errors.As(terr, &se) {
+		return status, errors.Join(err, terr)
+	}
+	return d.scriptExitStatus(ctx, status), err
+}
+
+// scriptExitStatus returns the status recorded by exit in EvalScript, or
+// status if exit did not run.
+func (d *Dash) scriptExitStatus(ctx context.Context, status int) int {
+	v, err := d.GetVar(ctx, scriptExitVar)
+	if err != nil || v == "" {
+		return status
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		// dash reported the illegal number.
+		return status
+	}
+	return n & 0xff
+}
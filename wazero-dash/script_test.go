@@ -0,0 +1,73 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEvalScript(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+
+	for _, tc := range []struct {
+		name, script, stdout string
+		status               int
+	}{
+		{"end", "echo a; false", "a\n", 1},
+		{"exit", "echo a; exit 3; echo b", "a\n", 3},
+		{"exit status", "false; exit", "", 1},
+		{"exit wraps", "exit 258", "", 2},
+		{"exit in function", "f() { exit 5; }; f; echo no", "", 5},
+		{"not found", "nosuchcommand", "", 127},
+		{"errexit", "set -e; echo a; false; echo b", "a\n", 1},
+		{"exit trap", `trap 'echo "trap $?"' EXIT; echo a`, "a\ntrap 0\n", 0},
+		{"exit trap after exit", `trap 'echo "trap $?"' EXIT; exit 4`, "trap 4\n", 4},
+		{"exit trap after errexit", `set -e; trap 'echo "trap $?"' EXIT; false; echo no`, "trap 1\n", 1},
+		{"exit in exit trap", `trap 'exit 7' EXIT; exit 4`, "", 7},
+		{"syntax error", `trap 'echo "trap $?"' EXIT; echo a
+fi`, "a\ntrap 2\n", 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout.Reset()
+			status, err := d.EvalScript(ctx, tc.script, Source{Name: "script.sh"})
+			var se *SyntaxError
+			if errors.As(err, &se) && tc.status == 2 {
+				err = nil
+			}
+			if err != nil || status != tc.status || stdout.String() != tc.stdout {
+				t.Fatalf("got %d, %q, %v; want %d, %q", status, stdout.String(), err, tc.status, tc.stdout)
+			}
+			if _, err := d.Eval(ctx, "set +e"); err != nil {
+				t.Fatal("Eval:", err)
+			}
+		})
+	}
+
+	// The shell is left as before: the trap ran once and exit is not aliased.
+	stdout.Reset()
+	if _, err := d.Eval(ctx, "trap; alias; echo done"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if stdout.String() != "done\n" {
+		t.Fatalf("EvalScript left %q", stdout.String())
+	}
+
+	// A function defined by a script still exits once it is over.
+	if _, err := d.EvalScript(ctx, "quit() { echo bye; exit 6; }", Source{}); err != nil {
+		t.Fatal("EvalScript:", err)
+	}
+	stdout.Reset()
+	if _, err := d.Eval(ctx, "quit; echo no"); err != nil || stdout.String() != "bye\n" {
+		t.Fatalf("quit printed %q, %v", stdout.String(), err)
+	}
+
+	// An alias of exit is left alone.
+	if _, err := d.Eval(ctx, "alias exit='echo aliased'"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	stdout.Reset()
+	if status, err := d.EvalScript(ctx, "exit 3", Source{}); err != nil || status != 0 || stdout.String() != "aliased 3\n" {
+		t.Fatalf("got %d, %q, %v", status, stdout.String(), err)
+	}
+}
@@ -0,0 +1,92 @@
+package dash
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// SelfTestReport is the result of SelfTest.
+type SelfTestReport struct {
+	// Checks are the checks run, in order.
+	Checks []SelfTestCheck
+	// Duration is the time SelfTest took, including starting the instance.
+	Duration time.Duration
+}
+
+// SelfTestCheck is the result of a check run by SelfTest.
+type SelfTestCheck struct {
+	Name string
+	// Failure describes how the check failed, or is empty if it passed.
+	Failure  string
+	Duration time.Duration
+}
+
+// OK reports whether every check passed.
+func (r *SelfTestReport) OK() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the failed checks as "name: failure".
+func (r *SelfTestReport) Failures() []string {
+	var failures []string
+	for _, c := range r.Checks {
+		if c.Failure != "" {
+			failures = append(failures, c.Name+": "+c.Failure)
+		}
+	}
+	return failures
+}
+
+// selfTestMount is the guest path of the memory file system SelfTest
+// redirects into.
+const selfTestMount = "/selftest"
+
+// selfTestChecks is the suite run by SelfTest.
+var selfTestChecks = []reactorSmokeCheck{
+	{"echo", `echo hello world; echo -n a; echo b`, "hello world\nab\n", 0},
+	{"variables", `x=1; y=${x}2; unset x; echo "${x-unset} $y ${#y}"`, "unset 12 2\n", 0},
+	{"arithmetic", `i=5; i=$((i * 3 + 1)); echo $i $((i % 5)) $((1 << 3))`, "16 1 8\n", 0},
+	{"redirection", `cd ` + selfTestMount + ` && echo written >out && echo appended >>out && { read a; read b; } <out && echo "$a $b"`, "written appended\n", 0},
+	{"exit status", `f() { return 3; }; f; echo $?; false`, "3\n", 1},
+}
+
+// SelfTest starts a throwaway shell on r and runs a small canonical suite
+// against it: echo, variable operations, arithmetic, writing a file of a
+// memory file system by redirection and reading it back. Embedders can run it as a health check, or to catch a
+// broken custom build passed with WithCompiledModule in opts. Failed checks
+// are reported rather than returned as errors, which are only returned if
+// the shell cannot be started.
+//
+// The shell is closed before SelfTest returns, but the host modules it
+// installed stay in r, so r must be a runtime of its own, for example one
+// sharing the embedder's compilation cache.
+func SelfTest(ctx context.Context, r wazero.Runtime, opts ...Option) (*SelfTestReport, error) {
+	start := time.Now()
+	ws, err := NewWorkspace(selfTestMount, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option{WithRuntime(r)}, opts...)
+	opts = append(opts, func(o *options) {
+		o.workspaces = append(o.workspaces, ws)
+	})
+	d, err := NewDash(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	report := &SelfTestReport{}
+	for _, check := range selfTestChecks {
+		checkStart := time.Now()
+		failure := check.run(ctx, d)
+		report.Checks = append(report.Checks, SelfTestCheck{Name: check.name, Failure: failure, Duration: time.Since(checkStart)})
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
@@ -0,0 +1,35 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	newRuntime := func() wazero.Runtime {
+		r := wazero.NewRuntime(ctx)
+		t.Cleanup(func() { _ = r.Close(ctx) })
+		return r
+	}
+
+	report, err := SelfTest(ctx, newRuntime())
+	if err != nil {
+		t.Fatal("SelfTest:", err)
+	}
+	if !report.OK() || len(report.Checks) != len(selfTestChecks) || report.Duration <= 0 {
+		t.Fatalf("expected every check to pass, got %+v", report)
+	}
+
+	// A shell that misbehaves fails the affected checks only.
+	report, err = SelfTest(ctx, newRuntime(), WithInitScript("alias unset=:"))
+	if err != nil {
+		t.Fatal("SelfTest:", err)
+	}
+	if failures := report.Failures(); len(failures) != 1 || !strings.HasPrefix(failures[0], "variables: got ") {
+		t.Fatalf("expected only the variables check to fail, got %q", failures)
+	}
+}
@@ -0,0 +1,120 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// errRestoring aborts the evaluation running the restore command.
+var errRestoring = errors.New("dash: restoring checkpoint")
+
+// WithSessionCheckpoints adds the checkpoint and restore commands, which
+// bookmark the shell state and return to it:
+//
+//	checkpoint NAME   save the state as of the end of this Eval
+//	checkpoint        list the saved checkpoints
+//	restore NAME      abort this Eval and return to checkpoint NAME
+//
+// A checkpoint is a copy of the guest memory: variables, functions,
+// aliases, options and the working directory. Files and open file
+// descriptors are not saved. The commands are available within Eval, so
+// with a REPL evaluating one line per call, a checkpoint saves the state
+// left by its line.
+func WithSessionCheckpoints() Option {
+	return func(o *options) {
+		o.sessionCheckpoints = true
+	}
+}
+
+// sessionCheckpoints holds the states saved by the checkpoint command.
+type sessionCheckpoints struct {
+	saved map[string]*memorySnapshot
+
+	// active is set while a top-level Eval runs. pending names the
+	// checkpoints to save when it returns, restore is the state to return
+	// to instead.
+	active  bool
+	pending []string
+	restore *memorySnapshot
+}
+
+// begin is called when a top-level Eval starts.
+func (s *sessionCheckpoints) begin() {
+	s.active, s.pending, s.restore = true, nil, nil
+}
+
+// end is called when a top-level Eval returns, after it freed its memory.
+// ok is false if the evaluation failed; its checkpoints are not saved.
+func (s *sessionCheckpoints) end(mod api.Module, ok bool) {
+	switch {
+	case s.restore != nil:
+		s.restore.restore(mod)
+	case ok && len(s.pending) != 0:
+		snap := captureMemory(mod)
+		if s.saved == nil {
+			s.saved = make(map[string]*memorySnapshot)
+		}
+		for _, name := range s.pending {
+			s.saved[name] = snap
+		}
+	}
+	s.active, s.pending, s.restore = false, nil, nil
+}
+
+// checkpointCommand implements checkpoint.
+func checkpointCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "checkpoint"
+	s := d.state.sessions
+	if !s.active {
+		commandError(ctx, d.mod, d.state, name, "only available in Eval")
+		return 2
+	}
+	switch len(argv) {
+	case 1:
+		names := make([]string, 0, len(s.saved))
+		for n := range s.saved {
+			names = append(names, n+"\n")
+		}
+		slices.Sort(names)
+		if err := guestWrite(ctx, d.mod, d.state, fdStdout, []byte(strings.Join(names, ""))); err != nil {
+			return 1
+		}
+		return 0
+	case 2:
+		if argv[1] == "" {
+			commandError(ctx, d.mod, d.state, name, "empty checkpoint name")
+			return 2
+		}
+		s.pending = append(s.pending, argv[1])
+		return 0
+	default:
+		commandError(ctx, d.mod, d.state, name, "usage: checkpoint [name]")
+		return 2
+	}
+}
+
+// restoreCommand implements restore.
+func restoreCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "restore"
+	s := d.state.sessions
+	if !s.active {
+		commandError(ctx, d.mod, d.state, name, "only available in Eval")
+		return 2
+	}
+	if len(argv) != 2 {
+		commandError(ctx, d.mod, d.state, name, "usage: restore name")
+		return 2
+	}
+	snap, ok := s.saved[argv[1]]
+	if !ok {
+		commandError(ctx, d.mod, d.state, name, argv[1]+": no such checkpoint")
+		return 1
+	}
+	// Abort the guest call: Eval restores the checkpoint once it returns.
+	s.restore = snap
+	panic(errRestoring)
+}
@@ -0,0 +1,77 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSessionCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithSessionCheckpoints())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	eval := func(script, want string) {
+		t.Helper()
+		stdout.Reset()
+		stderr.Reset()
+		if _, err := d.Eval(ctx, script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		if stdout.String() != want {
+			t.Fatalf("%s: got %q (stderr %q), want %q", script, stdout.String(), stderr.String(), want)
+		}
+	}
+
+	eval(`x=1; f() { echo "f$x"; }; cd /tmp; checkpoint base`, "")
+	eval(`x=2; unset -f f; alias ll=ls; cd /`, "")
+	eval(`restore base; echo skipped`, "")
+	eval(`echo "$x $PWD"; f; alias ll || echo unaliased`, "1 /tmp\nf1\nunaliased\n")
+
+	// Checkpoints survive restores and can be overwritten.
+	eval(`x=3; checkpoint other; checkpoint base`, "")
+	eval(`x=4; restore other`, "")
+	eval(`echo $x; checkpoint`, "3\nbase\nother\n")
+
+	eval(`restore missing; echo $?`, "1\n")
+	if !strings.Contains(stderr.String(), "no such checkpoint") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	// The shell keeps working after many restores.
+	for range 20 {
+		eval(`y=$x; restore base`, "")
+	}
+	eval(`echo ${y-unset} $x`, "unset 3\n")
+}
+
+func TestSessionCheckpointsNested(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx, WithSessionCheckpoints())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "x=1; checkpoint a"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	// A restore from a function or a nested evaluation aborts the whole
+	// Eval.
+	if status, err := d.Eval(ctx, `x=2; f() { eval 'restore a'; x=3; }; f; x=4`); err != nil || status != 0 {
+		t.Fatalf("restore: status %d, %v", status, err)
+	}
+	if got, _ := d.GetVar(ctx, "x"); got != "1" {
+		t.Fatalf("x=%q after restore", got)
+	}
+}
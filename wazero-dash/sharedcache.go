@@ -0,0 +1,57 @@
+package dash
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// sharedCaches is the process-wide registry of SharedCompilationCache.
+var sharedCaches struct {
+	mu     sync.Mutex
+	dir    string
+	caches map[[sha256.Size]byte]wazero.CompilationCache
+}
+
+// SharedCompilationCache returns the process-wide compilation cache for the
+// module wasm, keyed by its content hash, so every package of a binary
+// compiles an identical module once. NewDash uses it for the embedded dash
+// module unless given WithRuntime or WithCompilationCache.
+//
+// The cache is never closed. It is kept in memory unless SetSharedCacheDir
+// was called first.
+func SharedCompilationCache(wasm []byte) (wazero.CompilationCache, error) {
+	key := sha256.Sum256(wasm)
+	sharedCaches.mu.Lock()
+	defer sharedCaches.mu.Unlock()
+	if c, ok := sharedCaches.caches[key]; ok {
+		return c, nil
+	}
+	c := wazero.NewCompilationCache()
+	if dir := sharedCaches.dir; dir != "" {
+		var err error
+		if c, err = wazero.NewCompilationCacheWithDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	if sharedCaches.caches == nil {
+		sharedCaches.caches = make(map[[sha256.Size]byte]wazero.CompilationCache)
+	}
+	sharedCaches.caches[key] = c
+	return c, nil
+}
+
+// SetSharedCacheDir makes the shared compilation caches persist compiled
+// code in dir, so it is also reused across processes. It must be called
+// before the first SharedCompilationCache, usually from main.
+func SetSharedCacheDir(dir string) error {
+	sharedCaches.mu.Lock()
+	defer sharedCaches.mu.Unlock()
+	if len(sharedCaches.caches) != 0 {
+		return errors.New("dash: SetSharedCacheDir called after a shared cache was created")
+	}
+	sharedCaches.dir = dir
+	return nil
+}
@@ -0,0 +1,28 @@
+package dash
+
+import (
+	"context"
+	"testing"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+)
+
+func TestSharedCompilationCache(t *testing.T) {
+	ctx := context.Background()
+	res, err := Run(ctx, "echo shared")
+	if err != nil || string(res.Stdout) != "shared\n" {
+		t.Fatalf("Run: %q, %v", res.Stdout, err)
+	}
+	a, err := SharedCompilationCache(dashwasi.DashWASM)
+	if err != nil {
+		t.Fatal("SharedCompilationCache:", err)
+	}
+	b, _ := SharedCompilationCache(dashwasi.DashWASM)
+	other, _ := SharedCompilationCache([]byte("\x00asm\x01\x00\x00\x00"))
+	if a != b || a == other {
+		t.Fatal("expected one cache per module content")
+	}
+	if err := SetSharedCacheDir(t.TempDir()); err == nil {
+		t.Fatal("expected SetSharedCacheDir to fail once caches exist")
+	}
+}
@@ -0,0 +1,266 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// SIGINTStatus is the exit status of an evaluation stopped by Interrupt,
+// 128 plus SIGINT.
+const SIGINTStatus = 130
+
+// ErrSIGINT is returned with SIGINTStatus by an evaluation stopped by
+// Interrupt.
+var ErrSIGINT = errors.New("dash: stopped by SIGINT")
+
+// errSIGINT aborts the evaluation stopped by Interrupt.
+var errSIGINT = errors.New("dash: SIGINT")
+
+// errReadInterrupted is returned by a read of the guest stdin woken by
+// Interrupt.
+var errReadInterrupted = errors.New("dash: read interrupted")
+
+// WithInterrupts enables Interrupt. Each top-level evaluation saves the
+// guest memory first, to roll back to if it is interrupted, copying the
+// pages changed since the previous evaluation.
+func WithInterrupts() Option {
+	return func(o *options) {
+		o.interrupts = true
+	}
+}
+
+// sigintState tracks the SIGINTs sent by Interrupt.
+type sigintState struct {
+	mu sync.Mutex
+	// running counts the top-level evaluations running, including those
+	// run by the host while handling a SIGINT.
+	running int
+	pending bool
+	// wake is closed while a SIGINT is pending, waking blocked host
+	// commands and reads.
+	wake chan struct{}
+
+	// idle is closed when the INT trap run by Interrupt outside of an
+	// evaluation finishes, or is nil.
+	idle chan struct{}
+
+	// caught is set while an evaluation unwinds for a delivered SIGINT,
+	// action is the INT trap to run then.
+	caught bool
+	action string
+}
+
+// idleTrapKey marks the context of the INT trap run by Interrupt outside of
+// an evaluation.
+type idleTrapKey struct{}
+
+// newSIGINTState returns a sigintState with no pending SIGINT.
+func newSIGINTState() *sigintState {
+	return &sigintState{wake: make(chan struct{})}
+}
+
+// begin marks the start of a top-level evaluation, waiting for the INT
+// trap run by Interrupt outside of an evaluation.
+func (s *sigintState) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.idle != nil {
+		idle := s.idle
+		s.mu.Unlock()
+		<-idle
+		s.mu.Lock()
+	}
+	if s.running == 0 {
+		s.caught, s.action = false, ""
+		s.clear()
+	}
+	s.running++
+}
+
+// end marks the end of a top-level evaluation, dropping any SIGINT sent
+// too late to be handled.
+func (s *sigintState) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running--; s.running == 0 {
+		s.clear()
+	}
+}
+
+// send marks a SIGINT pending if an evaluation runs, reporting whether one
+// does.
+func (s *sigintState) send() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendLocked()
+}
+
+// sendLocked implements send. s.mu must be held.
+func (s *sigintState) sendLocked() bool {
+	if s.running == 0 {
+		return false
+	}
+	if !s.pending {
+		s.pending = true
+		close(s.wake)
+	}
+	return true
+}
+
+// take consumes the pending SIGINT, reporting whether there was one.
+func (s *sigintState) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending {
+		return false
+	}
+	s.clear()
+	return true
+}
+
+// clear drops the pending SIGINT. s.mu must be held.
+func (s *sigintState) clear() {
+	if s.pending {
+		s.pending = false
+		s.wake = make(chan struct{})
+	}
+}
+
+// wakeChan returns the channel closed when a SIGINT is pending.
+func (s *sigintState) wakeChan() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wake
+}
+
+// commandContext returns a context for a host command, canceled when a
+// SIGINT is sent, and the function releasing it.
+func (s *sigintState) commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	wake, stop := s.wakeChan(), make(chan struct{})
+	go func() {
+		select {
+		case <-wake:
+			cancel()
+		case <-stop:
+		}
+	}()
+	return ctx, func() {
+		close(stop)
+		cancel()
+	}
+}
+
+// Interrupt sends the equivalent of SIGINT to the running evaluation, as
+// Ctrl+C does in a terminal. It requires WithInterrupts and may be called
+// from another goroutine than the one running Eval.
+//
+// Blocked host commands, such as sleep or an ExecHandler, see their context
+// canceled and reads of the stdin given with WithStdio fail. The evaluation
+// then stops at its next command or write, or when it ends: it is rolled
+// back to its state before the call, $? is set to SIGINTStatus, the INT
+// trap runs and Eval returns SIGINTStatus and ErrSIGINT. With `trap "" INT`
+// the SIGINT is ignored. Loops running only builtins that print nothing, such as
+// `while :; do :; done`, are not stopped; cancel the context of Eval for
+// those.
+//
+// If no evaluation runs, Interrupt runs the INT trap itself with ctx, as
+// at a prompt; evaluations started meanwhile wait for it.
+func (d *Dash) Interrupt(ctx context.Context) error {
+	s := d.state.sigint
+	if s == nil {
+		return errors.New("dash: Interrupt requires WithInterrupts")
+	}
+	s.mu.Lock()
+	if s.sendLocked() || s.idle != nil {
+		// The evaluation handles it, or the trap is already running.
+		s.mu.Unlock()
+		return nil
+	}
+	s.idle = make(chan struct{})
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		close(s.idle)
+		s.idle = nil
+		s.mu.Unlock()
+	}()
+
+	if err := d.ready(); err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, idleTrapKey{}, true)
+	action, ignored, err := d.intTrap(ctx)
+	if err != nil || ignored {
+		return err
+	}
+	return d.runSIGINTTrap(ctx, action)
+}
+
+// deliverSIGINT stops the running evaluation if a SIGINT is pending and
+// not ignored. Called by host functions where the guest may be stopped.
+func (d *Dash) deliverSIGINT(ctx context.Context) {
+	s := d.state.sigint
+	if s == nil || !s.take() {
+		return
+	}
+	action, ignored, err := d.intTrap(ctx)
+	if err == nil && ignored {
+		return
+	}
+	s.caught, s.action = true, action
+	panic(errSIGINT)
+}
+
+// intTrap returns the action of the INT trap and whether it ignores the
+// signal. The guest memory is restored afterwards, so it may be called
+// from any host function.
+func (d *Dash) intTrap(ctx context.Context) (action string, ignored bool, err error) {
+	action, ok, err := d.trapAction(ctx, "INT")
+	return action, ok && action == "", err
+}
+
+// trapAction returns the action of the trap for the condition cond, such
+// as INT or EXIT, and whether one is set. The guest memory is restored
+// afterwards.
+func (d *Dash) trapAction(ctx context.Context, cond string) (action string, ok bool, err error) {
+	snap := captureMemory(d.mod)
+	defer snap.restore(d.mod)
+	out, err := d.captureStdout(func() error {
+		_, err := d.eval(ctx, "command trap")
+		return err
+	})
+	if err != nil {
+		return "", false, err
+	}
+	// Each trap is listed as: trap -- 'action' SIGNAL
+	rest := string(out)
+	for rest != "" {
+		var line string
+		line, rest, err = unquoteWord(strings.TrimPrefix(rest, "trap -- "))
+		if err != nil {
+			return "", false, err
+		}
+		rest = strings.TrimPrefix(rest, "\n")
+		if action, ok := strings.CutSuffix(line, " "+cond); ok {
+			return action, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// runSIGINTTrap sets $? to SIGINTStatus and runs the INT trap action, if
+// any, keeping that status.
+func (d *Dash) runSIGINTTrap(ctx context.Context, action string) error {
+	if action != "" {
+		if err := d.setExitStatus(ctx, SIGINTStatus); err != nil {
+			return err
+		}
+		if _, err := d.eval(ctx, action); err != nil {
+			return err
+		}
+	}
+	return d.setExitStatus(ctx, SIGINTStatus)
+}
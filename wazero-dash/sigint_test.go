@@ -0,0 +1,143 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for writes from the evaluating
+// goroutine while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func TestInterrupt(t *testing.T) {
+	ctx := context.Background()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var stdout syncBuffer
+	d, err := NewDash(ctx, WithStdio(pr, &stdout, io.Discard), WithInterrupts())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		<-ctx.Done()
+		return 1
+	})
+
+	// interrupt evaluates script, sending a SIGINT once it printed start.
+	interrupt := func(script string) int {
+		t.Helper()
+		stdout.Reset()
+		h := d.EvalAsync(ctx, script)
+		for !bytes.Contains([]byte(stdout.String()), []byte("start")) {
+			select {
+			case <-h.Done():
+				t.Fatalf("%s finished before the SIGINT: %q", script, stdout.String())
+			case <-time.After(time.Millisecond):
+			}
+		}
+		if err := d.Interrupt(ctx); err != nil {
+			t.Fatal("Interrupt:", err)
+		}
+		status, err := h.Wait()
+		if status == SIGINTStatus && !errors.Is(err, ErrSIGINT) || status != SIGINTStatus && err != nil {
+			t.Fatalf("%s: status %d, %v", script, status, err)
+		}
+		return status
+	}
+	eval := func(script, want string) {
+		t.Helper()
+		stdout.Reset()
+		if _, err := d.Eval(ctx, script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		if got := stdout.String(); got != want {
+			t.Fatalf("%s: got %q, want %q", script, got, want)
+		}
+	}
+
+	// A blocked command is canceled and the evaluation stops there.
+	if status := interrupt("x=1; echo start; block; echo after"); status != SIGINTStatus {
+		t.Fatalf("got status %d", status)
+	}
+	if got := stdout.String(); got != "start\n" {
+		t.Fatalf("got %q", got)
+	}
+	eval(`echo "$? x=$x"`, "130 x=\n")
+
+	// Loops that print are stopped, and the INT trap runs.
+	eval(`trap 'echo "caught $?"' INT`, "")
+	if status := interrupt("while :; do echo start; done"); status != SIGINTStatus {
+		t.Fatalf("got status %d", status)
+	}
+	if got := stdout.String(); !bytes.HasSuffix([]byte(got), []byte("start\ncaught 130\n")) {
+		t.Fatalf("got %q", got)
+	}
+
+	// A blocked read is woken, without losing later input.
+	for _, script := range []string{"echo start; read line; echo after", "echo start; read line"} {
+		if status := interrupt(script); status != SIGINTStatus {
+			t.Fatalf("%s: got status %d", script, status)
+		}
+		if got := stdout.String(); got != "start\ncaught 130\n" {
+			t.Fatalf("%s: got %q", script, got)
+		}
+	}
+	go pw.Write([]byte("line\n"))
+	eval(`read line; echo "$line"`, "line\n")
+
+	// Ignored SIGINTs only cancel the blocked command.
+	if status := interrupt("trap '' INT; echo start; block; echo \"after $?\""); status != 0 {
+		t.Fatalf("got status %d", status)
+	}
+	if got := stdout.String(); got != "start\nafter 1\n" {
+		t.Fatalf("got %q", got)
+	}
+
+	// With no evaluation running the trap runs at once.
+	eval(`trap 'echo idle' INT`, "")
+	stdout.Reset()
+	if err := d.Interrupt(ctx); err != nil {
+		t.Fatal("Interrupt:", err)
+	}
+	if got := stdout.String(); got != "idle\n" {
+		t.Fatalf("idle trap printed %q", got)
+	}
+	eval(`echo $?`, "130\n")
+}
+
+func TestInterruptRequiresOption(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	if err := d.Interrupt(context.Background()); err == nil {
+		t.Fatal("Interrupt without WithInterrupts succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package dash
+
+import (
+	"errors"
+	"strconv"
+)
+
+// SnapshotStrategy selects how much of the guest's C stack setjmp saves
+// and longjmp restores along with the WASM execution state.
+//
+// Saving the whole stack costs a copy proportional to its depth at every
+// setjmp, which dash calls for each command it evaluates: deeply recursive
+// shell functions make it dominate the runtime. A bounded window keeps the
+// cost constant, restoring only the frames nearest to the setjmp caller.
+type SnapshotStrategy int
+
+const (
+	// SnapshotFullStack saves the C stack from the stack pointer to its
+	// top. Longjmp restores the stack exactly; the default.
+	SnapshotFullStack SnapshotStrategy = iota
+	// SnapshotWindow saves at most the window set by WithSnapshotWindow
+	// above the stack pointer, covering the frame calling setjmp. Frames
+	// further up keep the values they had at longjmp, as with a native
+	// setjmp.
+	SnapshotWindow
+)
+
+// DefaultSnapshotWindow is the C stack window saved by SnapshotWindow
+// unless WithSnapshotWindow sets another.
+const DefaultSnapshotWindow = 4 << 10
+
+// String returns the strategy name as accepted by UnmarshalText.
+func (s SnapshotStrategy) String() string {
+	switch s {
+	case SnapshotFullStack:
+		return "full"
+	case SnapshotWindow:
+		return "window"
+	default:
+		return "SnapshotStrategy(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s SnapshotStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *SnapshotStrategy) UnmarshalText(text []byte) error {
+	for _, v := range []SnapshotStrategy{SnapshotFullStack, SnapshotWindow} {
+		if string(text) == v.String() {
+			*s = v
+			return nil
+		}
+	}
+	return errors.New("unknown snapshot strategy: " + string(text))
+}
+
+// WithSnapshotStrategy sets how setjmp saves the C stack. The default is
+// SnapshotFullStack.
+func WithSnapshotStrategy(strategy SnapshotStrategy) Option {
+	return func(o *options) {
+		o.snapshotStrategy = strategy
+	}
+}
+
+// WithSnapshotWindow sets the bytes of C stack saved by SnapshotWindow.
+// Values below 1 select DefaultSnapshotWindow.
+func WithSnapshotWindow(size int) Option {
+	return func(o *options) {
+		o.snapshotWindow = size
+	}
+}
+
+// stackWindow returns the C stack bytes setjmp saves with o, or 0 for
+// the whole stack.
+func (o *options) stackWindow() uint32 {
+	if o.snapshotStrategy != SnapshotWindow {
+		return 0
+	}
+	if o.snapshotWindow < 1 {
+		return DefaultSnapshotWindow
+	}
+	return uint32(min(o.snapshotWindow, 1<<31))
+}
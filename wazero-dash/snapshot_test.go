@@ -0,0 +1,91 @@
+package dash
+
+import (
+	"context"
+	"testing"
+)
+
+// recurseScript calls a shell function recursing depth times, then
+// evaluates cmd.
+func recurseScript(depth, cmd string) string {
+	return "f() { if [ $1 -gt 0 ]; then f $(($1-1)); else " + cmd + "; fi; }; f " + depth
+}
+
+func TestSnapshotStrategy(t *testing.T) {
+	var s SnapshotStrategy
+	if err := s.UnmarshalText([]byte("window")); err != nil || s != SnapshotWindow {
+		t.Fatalf("UnmarshalText(window) = %v, %v", s, err)
+	}
+	if err := s.UnmarshalText([]byte("asyncify")); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		max  int
+	}{
+		{"full", nil, 1 << 30},
+		{"window", []Option{WithSnapshotStrategy(SnapshotWindow)}, DefaultSnapshotWindow},
+		{"small window", []Option{WithSnapshotStrategy(SnapshotWindow), WithSnapshotWindow(64)}, 64},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDash(ctx, tc.opts...)
+			if err != nil {
+				t.Fatal("NewDash:", err)
+			}
+			defer d.Close(ctx)
+			if err := d.Init(ctx, nil); err != nil {
+				t.Fatal("Init:", err)
+			}
+			largest := 0
+			d.state.registerCommand("probe", func(ctx context.Context, d *Dash, argv []string) int {
+				for _, cp := range d.state.checkpoints {
+					if cp != nil {
+						largest = max(largest, len(cp.cstack))
+					}
+				}
+				return 0
+			})
+			// Errors longjmp out of the recursion, which must still work.
+			stdout, _, status, err := d.EvalCapture(ctx, recurseScript("100", "probe; command eval 'if'")+"; echo alive")
+			if err != nil || status != 0 || string(stdout) != "alive\n" {
+				t.Fatalf("EvalCapture = %q, %d, %v", stdout, status, err)
+			}
+			if largest == 0 || largest > tc.max {
+				t.Fatalf("largest saved C stack %d bytes, want 1 to %d", largest, tc.max)
+			}
+			if tc.opts == nil && largest <= DefaultSnapshotWindow {
+				t.Fatalf("expected the full stack above the window, got %d bytes", largest)
+			}
+		})
+	}
+}
+
+func BenchmarkSnapshotStrategy(b *testing.B) {
+	ctx := context.Background()
+	workloads := []struct{ name, script string }{
+		{"loop", "i=0; while [ $i -lt 100 ]; do i=$((i+1)); done"},
+		{"recursion", recurseScript("300", "true")},
+	}
+	for _, strategy := range []SnapshotStrategy{SnapshotFullStack, SnapshotWindow} {
+		for _, w := range workloads {
+			b.Run(strategy.String()+"/"+w.name, func(b *testing.B) {
+				d, err := NewDash(ctx, WithSnapshotStrategy(strategy))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer d.Close(ctx)
+				if err := d.Init(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+				for b.Loop() {
+					if _, err := d.Eval(ctx, w.script); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
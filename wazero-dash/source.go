@@ -0,0 +1,158 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// maxSourceName is the longest source name EvalWithSource reports in
+// diagnostics. Longer names are truncated.
+const maxSourceName = 255
+
+// Source identifies where an evaluated script came from.
+type Source struct {
+	// Name is reported as the script name in error messages and as $0
+	// while the script runs. Empty keeps the shell's arg0.
+	Name string
+
+	// Line is the line number of the first line of the script in the
+	// original source. Values below 1 are treated as 1.
+	Line int
+}
+
+// EvalWithSource evaluates a shell command string that was taken from a
+// larger source, such as a file or an embedded snippet.
+//
+// Error messages and line numbers reported by dash (including $LINENO and
+// xtrace output where the reactor supports them) refer to the original
+// source: they name src.Name and count lines from src.Line.
+func (d *Dash) EvalWithSource(ctx context.Context, cmd string, src Source) (int, error) {
+	if d.ready() != nil {
+		return d.Eval(ctx, cmd)
+	}
+
+	// Dash numbers lines from 1 for each evaluation; leading newlines
+	// shift the count without changing the meaning of the script.
+	if src.Line > 1 {
+		cmd = strings.Repeat("\n", src.Line-1) + cmd
+	}
+
+	if src.Name != "" {
+		d.setArg0(src.Name)
+		defer d.setArg0(d.arg0)
+	}
+	return d.Eval(ctx, cmd)
+}
+
+// ErrSourceNotFound is wrapped by the SourceError returned by SourceFile
+// for a file that does not exist.
+var ErrSourceNotFound = errors.New("dash: source file not found")
+
+// ErrSourceSyntax is wrapped by the SourceError returned by SourceFile for
+// a file with a syntax error, and by SyntaxError.
+var ErrSourceSyntax = errors.New("dash: syntax error")
+
+// SourceError reports a script file run by SourceFile that failed.
+type SourceError struct {
+	Path string
+	// Err is ErrSourceNotFound, a *SyntaxError matching ErrSourceSyntax,
+	// an *ExitError for a non-zero exit status, or the error reading the
+	// file.
+	Err error
+	// Message is the shell's error message for ErrSourceSyntax, such as
+	// "dash: 3: Syntax error: end of file unexpected".
+	Message string
+}
+
+// Error implements error.
+func (e *SourceError) Error() string {
+	msg := e.Err.Error() + ": source " + e.Path
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns Err.
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// SourceFile runs the script file at the guest path p in the current shell,
+// like `. p`: the variables and functions it defines persist. Relative
+// paths are resolved against the working directory, not searched in PATH,
+// and p is never parsed by the shell, so it needs no quoting.
+//
+// The file must be on a mount managed by Dash, as for ReadFile. Failures
+// return a *SourceError with the exit status: missing files wrap
+// ErrSourceNotFound, syntax errors ErrSourceSyntax, and other non-zero
+// exit statuses an *ExitError. As with `.`, the commands before a syntax
+// error run.
+func (d *Dash) SourceFile(ctx context.Context, p string) (int, error) {
+	if err := d.ready(); err != nil {
+		return -1, err
+	}
+	script, err := d.ReadFile(d.guestPath(ctx, p))
+	if err != nil {
+		var errno experimentalsys.Errno
+		if errors.As(err, &errno) && (errno == experimentalsys.ENOENT || errno == experimentalsys.ENOTDIR) {
+			err = ErrSourceNotFound
+		} else if pathErr, ok := err.(*fs.PathError); ok {
+			err = pathErr.Err
+		}
+		return -1, &SourceError{Path: p, Err: err}
+	}
+	var status int
+	msg, err := d.watchStderr(func() error {
+		var err error
+		status, err = d.Eval(ctx, string(script))
+		return err
+	})
+	var se *SyntaxError
+	switch {
+	case errors.As(err, &se):
+		return status, &SourceError{Path: p, Err: se, Message: msg}
+	case err != nil:
+		return status, err
+	case status != 0:
+		return status, &SourceError{Path: p, Err: &ExitError{Status: status}}
+	}
+	return 0, nil
+}
+
+// setArg0 overwrites the guest arg0 buffer in place.
+//
+// Dash references arg0 by pointer for $0 and error message prefixes, so
+// rewriting the buffer renames the running script without touching shell
+// state.
+func (d *Dash) setArg0(name string) {
+	if d.arg0Ptr == 0 {
+		return
+	}
+	if len(name) > maxSourceName {
+		name = name[:maxSourceName]
+	}
+	d.mod.Memory().Write(d.arg0Ptr, append([]byte(name), 0))
+}
+
+// SetNormalizeCRLF enables translating Windows CRLF line endings to LF in
+// scripts passed to Eval and EvalWithSource.
+//
+// Without it, a script checked out with CRLF endings fails with errors such
+// as "\r: not found", since dash treats the carriage return as part of the
+// last word on each line. Lone CR characters are left untouched.
+func (d *Dash) SetNormalizeCRLF(enable bool) {
+	d.normalizeCRLF = enable
+}
+
+// normalizeScript applies the configured script text normalization.
+func (d *Dash) normalizeScript(cmd string) string {
+	if d.normalizeCRLF && strings.Contains(cmd, "\r\n") {
+		cmd = strings.ReplaceAll(cmd, "\r\n", "\n")
+	}
+	return cmd
+}
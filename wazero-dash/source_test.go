@@ -0,0 +1,125 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEvalWithSource(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+
+	status, err := d.EvalWithSource(ctx, "echo $0\n\nif then", Source{Name: "deploy.sh", Line: 10})
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Line != 12 {
+		t.Fatalf("expected a syntax error at line 12, got %v", err)
+	}
+	if status != 2 {
+		t.Fatalf("expected exit status 2, got %d", status)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "deploy.sh" {
+		t.Fatalf("expected $0 deploy.sh, got %q", got)
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "deploy.sh: 12: ") {
+		t.Fatalf("expected error at deploy.sh:12, got %q", got)
+	}
+
+	// arg0 is restored and stays intact across evaluations.
+	stdout.Reset()
+	for range 3 {
+		if _, err := d.Eval(ctx, "x='some unrelated allocation'"); err != nil {
+			t.Fatal("Eval:", err)
+		}
+	}
+	if _, err := d.Eval(ctx, "echo $0"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "dash" {
+		t.Fatalf("expected $0 dash, got %q", got)
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+
+	script := "x=one\r\nif [ \"$x\" = one ]; then\r\n  echo matched\r\nfi\r\n"
+	if status, _ := d.Eval(ctx, script); status == 0 {
+		t.Fatal("expected CRLF script to fail without normalization")
+	}
+
+	d.SetNormalizeCRLF(true)
+	stdout.Reset()
+	status, err := d.Eval(ctx, script)
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %d", status)
+	}
+	if got := stdout.String(); got != "matched\n" {
+		t.Fatalf("expected 'matched', got %q", got)
+	}
+}
+
+func TestSourceFile(t *testing.T) {
+	ctx := context.Background()
+	var stdout, stderr bytes.Buffer
+	d, err := NewDash(ctx, WithStdio(nil, &stdout, &stderr), WithFS(fstest.MapFS{
+		"it's a lib.sh": {Data: []byte("greeting=hello\ngreet() { echo \"$greeting $1\"; }\n")},
+		"fail.sh":       {Data: []byte("echo failing\nfalse\n")},
+		"syntax.sh":     {Data: []byte("echo before\nif\n")},
+		"status2.sh":    {Data: []byte("f() { return 2; }; f\n")},
+	}, "/src"))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	if status, err := d.SourceFile(ctx, "/src/it's a lib.sh"); err != nil || status != 0 {
+		t.Fatalf("SourceFile = %d, %v", status, err)
+	}
+	if _, err := d.Eval(ctx, "greet world"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got := stdout.String(); got != "hello world\n" {
+		t.Fatalf("expected the sourced function to run, got %q", got)
+	}
+
+	// Relative paths are not searched in PATH.
+	if _, err := d.Eval(ctx, "cd /src"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	status, err := d.SourceFile(ctx, "fail.sh")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Status != 1 || status != 1 {
+		t.Fatalf("expected an ExitError with status 1, got %d, %v", status, err)
+	}
+
+	stdout.Reset()
+	status, err = d.SourceFile(ctx, "syntax.sh")
+	var srcErr *SourceError
+	if !errors.Is(err, ErrSourceSyntax) || !errors.As(err, &srcErr) || !strings.Contains(srcErr.Message, "Syntax error") || status != 2 {
+		t.Fatalf("expected a syntax error, got %d, %v", status, err)
+	}
+	if got := stdout.String(); got != "before\n" {
+		t.Fatalf("expected the commands before the syntax error to run, got %q", got)
+	}
+	status, err = d.SourceFile(ctx, "status2.sh")
+	if !errors.As(err, &exitErr) || exitErr.Status != 2 || status != 2 {
+		t.Fatalf("expected an ExitError with status 2, got %d, %v", status, err)
+	}
+
+	for _, p := range []string{"missing.sh", "/src/fail.sh/x"} {
+		if _, err := d.SourceFile(ctx, p); !errors.Is(err, ErrSourceNotFound) {
+			t.Fatalf("SourceFile(%q): expected ErrSourceNotFound, got %v", p, err)
+		}
+	}
+}
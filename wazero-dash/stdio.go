@@ -0,0 +1,471 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Guest file descriptors for the standard streams.
+const (
+	fdStdin  = 0
+	fdStdout = 1
+	fdStderr = 2
+)
+
+// WASI errno values returned by host functions.
+const (
+	wasiErrnoSuccess = 0
+	wasiErrnoIO      = 29
+)
+
+// compileWASI compiles the WASI host module used by dash.
+//
+// fd_write is wrapped so the host can capture guest stdout; the original
+// implementation is stored in state.fdWrite. fd_read is wrapped the same
+// way for EvalWithInput, see fdReadHost. fd_close is wrapped to keep the
+// standard streams open, see fdCloseHost. With WithReadOnlyFS the functions
+// modifying the file system are replaced, see readOnlyWASI. Every function
+// taking file descriptors gets the guest's, translated with state.fds, see
+// fdTableWASI.
+func compileWASI(ctx context.Context, r wazero.Runtime, state *dashState) (wazero.CompiledModule, error) {
+	plain, err := wasi_snapshot_preview1.NewBuilder(r).Compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer plain.Close(ctx)
+	defs := plain.ExportedFunctions()
+	funcs := make(map[string]api.GoModuleFunction, len(defs))
+	for name := range defs {
+		if funcs[name], err = lookupWASIFunc(plain, name); err != nil {
+			return nil, err
+		}
+	}
+	state.fdWrite, state.fdRead, state.fdAdvise = funcs["fd_write"], funcs["fd_read"], funcs["fd_advise"]
+	state.fdPrestatGet = funcs["fd_prestat_get"]
+	funcs["fd_write"] = api.GoModuleFunc(fdWriteHost)
+	funcs["fd_read"] = api.GoModuleFunc(fdReadHost)
+	funcs["fd_close"] = fdCloseHost(funcs["fd_close"])
+	if state.readOnly {
+		if err := readOnlyWASI(funcs); err != nil {
+			return nil, err
+		}
+	}
+	state.fdClose = funcs["fd_close"]
+
+	builder := r.NewHostModuleBuilder(wasi_snapshot_preview1.ModuleName)
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		def := defs[name]
+		builder.NewFunctionBuilder().
+			WithGoModuleFunction(fdTableWASI(state, name, funcs[name]), def.ParamTypes(), def.ResultTypes()).
+			WithParameterNames(def.ParamNames()...).
+			Export(name)
+	}
+	return builder.Compile(ctx)
+}
+
+// fdCloseHost wraps WASI fd_close, ignoring closes of the standard streams.
+//
+// The guest closes its standard streams for redirections like `>&-`; they
+// belong to the embedder, and Reset gives them back to the guest.
+func fdCloseHost(fdClose api.GoModuleFunction) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		if uint32(stack[0]) <= fdStderr {
+			stack[0] = wasiErrnoSuccess
+			return
+		}
+		fdClose.Call(ctx, mod, stack)
+	}
+}
+
+// fdWriteHost implements WASI fd_write, diverting stdout and stderr to the
+// active capture buffers if any and rendering the PS4 hook in stderr. Its
+// fd is the host descriptor, which is stdout even if the guest redirected
+// it, for example to a file.
+//
+// Stack: fd, iovs, iovs_len, result.nwritten -> errno
+func fdWriteHost(ctx context.Context, mod api.Module, stack []uint64) {
+	state, _ := ctx.Value(dashStateKey{}).(*dashState)
+	if state == nil {
+		return
+	}
+	state.checkTerminated()
+	state.checkInterrupted(ctx)
+	if state.sigint != nil && state.dash != nil {
+		state.dash.deliverSIGINT(ctx)
+	}
+	fd := uint32(stack[0])
+	if fd == fdStdout || fd == fdStderr {
+		state.faults.delayWrite(ctx)
+		state.checkInterrupted(ctx)
+	}
+	if fd == fdStderr {
+		// Keep stdout and stderr in order on a shared terminal.
+		_ = state.flushStdout()
+	}
+	if state.events != nil && (fd == fdStdout || fd == fdStderr) {
+		if data, ok := readIovecs(mod.Memory(), uint32(stack[1]), uint32(stack[2])); ok && len(data) > 0 {
+			state.publish(Event{Kind: EventOutput, Stream: Stream(fd), Data: bytes.Clone(data)})
+		}
+	}
+	if fd == fdStderr && state.stderrTail != nil {
+		if data, ok := readIovecs(mod.Memory(), uint32(stack[1]), uint32(stack[2])); ok {
+			state.stderrTail.write(data)
+		}
+	}
+	capture := state.captureFor(fd)
+	if capture == nil && (fd != fdStderr || state.ps4 == nil) {
+		state.fdWrite.Call(ctx, mod, stack)
+		return
+	}
+
+	const wasiErrnoFault = 21
+	mem := mod.Memory()
+	data, ok := readIovecs(mem, uint32(stack[1]), uint32(stack[2]))
+	if !ok {
+		stack[0] = wasiErrnoFault
+		return
+	}
+	if capture != nil {
+		capture.Write(data)
+	} else {
+		// The hook may call into the guest.
+		rendered := state.renderPS4(ctx, bytes.Clone(data))
+		if rendered == nil {
+			state.fdWrite.Call(ctx, mod, stack)
+			return
+		}
+		if err := hostWrite(ctx, mod, state, fd, rendered); err != nil {
+			stack[0] = wasiErrnoIO
+			return
+		}
+	}
+	if !mem.WriteUint32Le(uint32(stack[3]), uint32(len(data))) {
+		stack[0] = wasiErrnoFault
+		return
+	}
+	stack[0] = wasiErrnoSuccess
+}
+
+// readIovecs returns the concatenated contents of a WASI iovec array. The
+// result aliases guest memory if the array has a single entry.
+func readIovecs(mem api.Memory, iovs, iovsLen uint32) ([]byte, bool) {
+	if iovsLen == 1 {
+		// The common case needs no copy.
+		buf, ok1 := mem.ReadUint32Le(iovs)
+		n, ok2 := mem.ReadUint32Le(iovs + 4)
+		data, ok3 := mem.Read(buf, n)
+		return data, ok1 && ok2 && ok3
+	}
+	var data []byte
+	for i := uint32(0); i < iovsLen; i++ {
+		buf, ok1 := mem.ReadUint32Le(iovs + i*8)
+		n, ok2 := mem.ReadUint32Le(iovs + i*8 + 4)
+		chunk, ok3 := mem.Read(buf, n)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, false
+		}
+		data = append(data, chunk...)
+	}
+	return data, true
+}
+
+// flushStdout writes the output buffered by WithStdoutBuffer.
+func (s *dashState) flushStdout() error {
+	if s.stdoutBuf == nil {
+		return nil
+	}
+	if err := s.stdoutBuf.Flush(); err != nil {
+		return fmt.Errorf("dash: flushing stdout: %w", err)
+	}
+	return nil
+}
+
+// captureStdout runs fn with guest stdout diverted into a buffer and
+// returns what was written. Captures nest.
+func (d *Dash) captureStdout(fn func() error) ([]byte, error) {
+	prev := d.state.capture
+	var buf bytes.Buffer
+	d.state.capture = &buf
+	defer func() { d.state.capture = prev }()
+	err := fn()
+	return buf.Bytes(), err
+}
+
+// captureStderr is captureStdout for guest stderr.
+func (d *Dash) captureStderr(fn func() error) ([]byte, error) {
+	prev := d.state.captureErr
+	var buf bytes.Buffer
+	d.state.captureErr = &buf
+	defer func() { d.state.captureErr = prev }()
+	err := fn()
+	return buf.Bytes(), err
+}
+
+// maxStderrTail is the most bytes of stderr kept by stderrTail.
+const maxStderrTail = 1 << 10
+
+// stderrTail keeps the last bytes written to guest stderr.
+type stderrTail struct {
+	buf []byte
+}
+
+// write appends p, dropping the oldest bytes beyond maxStderrTail.
+func (t *stderrTail) write(p []byte) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > maxStderrTail {
+		t.buf = t.buf[len(t.buf)-maxStderrTail:]
+	}
+}
+
+// lastLine returns the last non-empty line written.
+func (t *stderrTail) lastLine() string {
+	s := strings.TrimRight(string(t.buf), "\n")
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
+
+// watchStderr runs fn keeping the end of what it writes to guest stderr,
+// which still goes to its destination, and returns its last line.
+func (d *Dash) watchStderr(fn func() error) (string, error) {
+	prev := d.state.stderrTail
+	tail := &stderrTail{}
+	d.state.stderrTail = tail
+	defer func() {
+		if prev != nil {
+			prev.write(tail.buf)
+		}
+		d.state.stderrTail = prev
+	}()
+	err := fn()
+	return tail.lastLine(), err
+}
+
+// captureFor returns the active capture buffer of fd, or nil.
+func (s *dashState) captureFor(fd uint32) *bytes.Buffer {
+	switch fd {
+	case fdStdout:
+		return s.capture
+	case fdStderr:
+		return s.captureErr
+	default:
+		return nil
+	}
+}
+
+// evalQuiet evaluates script with stdout captured, leaving $? unchanged.
+// Used by accessors implemented in terms of shell builtins.
+func (d *Dash) evalQuiet(ctx context.Context, script string) ([]byte, error) {
+	_, out, err := d.evalQuietStatus(ctx, script)
+	return out, err
+}
+
+// evalQuietStatus is evalQuiet, also returning the exit status of script.
+// Unlike the output of `echo $?`, functions defined by scripts cannot fake
+// the status of special builtins such as unset and eval.
+func (d *Dash) evalQuietStatus(ctx context.Context, script string) (int, []byte, error) {
+	// Internal evaluations are not published, see WithEvents.
+	events := d.state.events
+	d.state.events = nil
+	defer func() { d.state.events = events }()
+	prev, statusErr := d.GetExitStatus(ctx)
+	var status int
+	out, err := d.captureStdout(func() error {
+		var err error
+		status, err = d.eval(ctx, script)
+		return err
+	})
+	if err != nil {
+		return -1, nil, err
+	}
+	if statusErr == nil {
+		if err := d.setExitStatus(ctx, prev); err != nil {
+			return -1, nil, err
+		}
+	}
+	return status, out, nil
+}
+
+// setExitStatus sets $? by returning status from a self-removing function.
+func (d *Dash) setExitStatus(ctx context.Context, status int) error {
+	const fn = "__dash_wasi_status"
+	_, err := d.eval(ctx, fn+"() { unset -f "+fn+"; return "+strconv.Itoa(status&0xff)+"; }; "+fn)
+	return err
+}
+
+// lookupWASIFunc returns the Go implementation of a compiled WASI function.
+func lookupWASIFunc(wasi wazero.CompiledModule, name string) (api.GoModuleFunction, error) {
+	def, ok := wasi.ExportedFunctions()[name]
+	if !ok {
+		return nil, errors.New("missing wasi export: " + name)
+	}
+	gofn, ok := def.GoFunction().(api.GoModuleFunction)
+	if !ok {
+		return nil, errors.New("unexpected wasi function type: " + name)
+	}
+	return gofn, nil
+}
+
+// guestWrite writes p to the guest file descriptor fd.
+//
+// Host functions use this to produce output on the module's configured stdio.
+// The data is copied into a scratch buffer in WASM memory and handed to the
+// WASI fd_write implementation, exactly as if the guest had written it.
+func guestWrite(ctx context.Context, mod api.Module, state *dashState, fd uint32, p []byte) error {
+	h, ok := state.fds.host(fd)
+	if !ok {
+		return errors.New("fd_write: bad file descriptor")
+	}
+	return hostWrite(ctx, mod, state, h, p)
+}
+
+// hostWrite is guestWrite for the host descriptor fd.
+func hostWrite(ctx context.Context, mod api.Module, state *dashState, fd uint32, p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if capture := state.captureFor(fd); capture != nil {
+		capture.Write(p)
+		return nil
+	}
+	if state.fdWrite == nil {
+		return errors.New("fd_write not available")
+	}
+	if fd == fdStderr {
+		if err := state.flushStdout(); err != nil {
+			return err
+		}
+	}
+	malloc := mod.ExportedFunction(dashwasi.ExportMalloc)
+	free := mod.ExportedFunction(dashwasi.ExportFree)
+	if malloc == nil || free == nil {
+		return &ExportMissingError{Name: dashwasi.ExportMalloc}
+	}
+
+	// Layout: iovec{buf, len} (8 bytes), nwritten (4 bytes), data.
+	if state.faults.failMalloc() {
+		return errMallocFault
+	}
+	results, err := malloc.Call(ctx, uint64(12+len(p)))
+	if err != nil {
+		return err
+	}
+	scratch := uint32(results[0])
+	if scratch == 0 {
+		return errors.New("malloc returned null")
+	}
+	defer func() { _, _ = free.Call(ctx, uint64(scratch)) }()
+
+	mem := mod.Memory()
+	iov, nwritten, data := scratch, scratch+8, scratch+12
+	if !mem.Write(data, p) {
+		return errors.New("failed to write output to memory")
+	}
+
+	for off := uint32(0); off < uint32(len(p)); {
+		mem.WriteUint32Le(iov, data+off)
+		mem.WriteUint32Le(iov+4, uint32(len(p))-off)
+		stack := []uint64{uint64(fd), uint64(iov), 1, uint64(nwritten)}
+		state.fdWrite.Call(ctx, mod, stack)
+		if errno := uint32(stack[0]); errno != wasiErrnoSuccess {
+			return errors.New("fd_write failed")
+		}
+		n, _ := mem.ReadUint32Le(nwritten)
+		if n == 0 {
+			return errors.New("fd_write made no progress")
+		}
+		off += n
+	}
+	return nil
+}
+
+// guestRead reads up to len(p) bytes from the guest file descriptor fd
+// using the WASI fd_read implementation. Returns io.EOF at end of file.
+func guestRead(ctx context.Context, mod api.Module, state *dashState, fd uint32, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	fd, ok := state.fds.host(fd)
+	if !ok {
+		return 0, errors.New("fd_read: bad file descriptor")
+	}
+	if state.input != nil && fd == fdStdin {
+		return state.input.Read(p)
+	}
+	if state.fdRead == nil {
+		return 0, errors.New("fd_read not available")
+	}
+	malloc := mod.ExportedFunction(dashwasi.ExportMalloc)
+	free := mod.ExportedFunction(dashwasi.ExportFree)
+	if malloc == nil || free == nil {
+		return 0, &ExportMissingError{Name: dashwasi.ExportMalloc}
+	}
+
+	// Layout: iovec{buf, len} (8 bytes), nread (4 bytes), data.
+	if state.faults.failMalloc() {
+		return 0, errMallocFault
+	}
+	results, err := malloc.Call(ctx, uint64(12+len(p)))
+	if err != nil {
+		return 0, err
+	}
+	scratch := uint32(results[0])
+	if scratch == 0 {
+		return 0, errors.New("malloc returned null")
+	}
+	defer func() { _, _ = free.Call(ctx, uint64(scratch)) }()
+
+	mem := mod.Memory()
+	iov, nread, data := scratch, scratch+8, scratch+12
+	mem.WriteUint32Le(iov, data)
+	mem.WriteUint32Le(iov+4, uint32(len(p)))
+	stack := []uint64{uint64(fd), uint64(iov), 1, uint64(nread)}
+	state.fdRead.Call(ctx, mod, stack)
+	if errno := uint32(stack[0]); errno != wasiErrnoSuccess {
+		return 0, errors.New("fd_read failed")
+	}
+	n, _ := mem.ReadUint32Le(nread)
+	if n == 0 {
+		return 0, io.EOF
+	}
+	buf, ok := mem.Read(data, n)
+	if !ok {
+		return 0, errors.New("failed to read input from memory")
+	}
+	return copy(p, buf), nil
+}
+
+// guestStream reads from and writes to a guest file descriptor on behalf
+// of a host command.
+type guestStream struct {
+	ctx   context.Context
+	mod   api.Module
+	state *dashState
+	fd    uint32
+}
+
+// Read implements io.Reader.
+func (s *guestStream) Read(p []byte) (int, error) {
+	return guestRead(s.ctx, s.mod, s.state, s.fd, p)
+}
+
+// Write implements io.Writer.
+func (s *guestStream) Write(p []byte) (int, error) {
+	if err := guestWrite(s.ctx, s.mod, s.state, s.fd, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// _ is a type assertion
+var _ io.ReadWriter = (*guestStream)(nil)
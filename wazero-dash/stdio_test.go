@@ -0,0 +1,140 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// countingWriter records the number of writes made to it.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+// Write implements io.Writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+// promptCheckReader fails the test if stdout lacks prompt when read.
+type promptCheckReader struct {
+	t      *testing.T
+	stdout *countingWriter
+	prompt string
+	input  *strings.Reader
+}
+
+// Read implements io.Reader.
+func (r *promptCheckReader) Read(p []byte) (int, error) {
+	if !strings.HasSuffix(r.stdout.String(), r.prompt) {
+		r.t.Errorf("expected %q flushed before reading, got %q", r.prompt, r.stdout.String())
+	}
+	return r.input.Read(p)
+}
+
+func TestStdoutBuffer(t *testing.T) {
+	ctx := context.Background()
+	out := &countingWriter{}
+	stdin := &promptCheckReader{t: t, stdout: out, prompt: "name? ", input: strings.NewReader("world\n")}
+	d, err := NewDash(ctx, WithStdio(stdin, out, out), WithStdoutBuffer(4096))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	out.Reset()
+	out.writes = 0
+	if _, err := d.Eval(ctx, "i=0; while [ $i -lt 100 ]; do echo line; i=$((i+1)); done"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if want := strings.Repeat("line\n", 100); out.String() != want {
+		t.Fatalf("expected 100 lines, got %q", out.String())
+	}
+	if out.writes != 1 {
+		t.Fatalf("expected the lines in 1 write, got %d", out.writes)
+	}
+
+	// Stderr and reads of stdin flush stdout.
+	out.Reset()
+	if _, err := d.Eval(ctx, "echo a; @stderr b; echo c; printf 'name? '; read name; echo \"hi $name\""); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if want := "a\nb\nc\nname? hi world\n"; out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+// benchmarkStdout measures the throughput of script writing to stdout.
+func benchmarkStdout(b *testing.B, script string, opts ...Option) {
+	ctx := context.Background()
+	out := &countingWriter{}
+	d, err := NewDash(ctx, append([]Option{WithStdio(nil, out, nil)}, opts...)...)
+	if err != nil {
+		b.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		b.Fatal("Init:", err)
+	}
+	if err := d.SetVar(ctx, "big", strings.Repeat("x", 64<<10)); err != nil {
+		b.Fatal("SetVar:", err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		out.Reset()
+		if _, err := d.Eval(ctx, script); err != nil {
+			b.Fatal("Eval:", err)
+		}
+	}
+	b.SetBytes(int64(out.Len()))
+	b.ReportMetric(float64(out.writes)/float64(b.N), "writes/op")
+}
+
+const (
+	benchLargeWrite  = `echo "$big"`
+	benchSmallWrites = `i=0; while [ $i -lt 1000 ]; do echo line; i=$((i+1)); done`
+)
+
+func BenchmarkStdoutLargeWrite(b *testing.B) {
+	benchmarkStdout(b, benchLargeWrite)
+}
+
+func BenchmarkStdoutLargeWriteBuffered(b *testing.B) {
+	benchmarkStdout(b, benchLargeWrite, WithStdoutBuffer(64<<10))
+}
+
+func BenchmarkStdoutSmallWrites(b *testing.B) {
+	benchmarkStdout(b, benchSmallWrites)
+}
+
+func BenchmarkStdoutSmallWritesBuffered(b *testing.B) {
+	benchmarkStdout(b, benchSmallWrites, WithStdoutBuffer(64<<10))
+}
+
+func BenchmarkCaptureLargeWrite(b *testing.B) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		b.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		b.Fatal("Init:", err)
+	}
+	if err := d.SetVar(ctx, "big", strings.Repeat("x", 64<<10)); err != nil {
+		b.Fatal("SetVar:", err)
+	}
+	b.SetBytes(64 << 10)
+	b.ResetTimer()
+	for range b.N {
+		if _, _, _, err := d.EvalCapture(ctx, benchLargeWrite); err != nil {
+			b.Fatal("EvalCapture:", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package dash
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+)
+
+// StdlibDir is the guest directory holding the embedded shell function
+// library, one file per module: StdlibDir + "/string.sh" and so on.
+const StdlibDir = "/usr/lib/dash-wasi"
+
+// StdlibModules lists the modules of the embedded shell function library:
+//
+//   - string: str_trim, str_replace, str_upper, str_lower, str_repeat and
+//     the str_starts_with, str_ends_with and str_contains tests
+//   - retry: retry and with_backoff
+//   - log: log_debug, log_info, log_warn and log_error, filtered by
+//     $LOG_LEVEL
+//   - json: json_escape
+//
+// The reactor has no command substitution, so functions computing a string
+// take the name of the variable to store it in as their first argument.
+var StdlibModules = []string{"string", "retry", "log", "json"}
+
+// stdlibScripts holds the library sources.
+//
+//go:embed stdlib/*.sh
+var stdlibScripts embed.FS
+
+// stderrCommand writes its arguments to the guest stderr. The library's
+// log functions use it.
+const stderrCommand = "@stderr"
+
+// WithStdlib mounts the embedded shell function library read-only at
+// StdlibDir and sources the given modules, or all StdlibModules if none,
+// during Init. The mounted files document the functions.
+//
+// It also provides the sleep host command used by with_backoff.
+func WithStdlib(modules ...string) Option {
+	return func(o *options) {
+		o.stdlib = true
+		if len(modules) == 0 {
+			modules = StdlibModules
+		}
+		o.stdlibModules = slices.Clone(modules)
+	}
+}
+
+// stdlibFS returns the file system mounted at StdlibDir.
+func stdlibFS() (fs.FS, error) {
+	return fs.Sub(stdlibScripts, "stdlib")
+}
+
+// checkStdlibModules returns an error if a module is unknown.
+func checkStdlibModules(modules []string) error {
+	for _, m := range modules {
+		if !slices.Contains(StdlibModules, m) {
+			return fmt.Errorf("dash: unknown stdlib module: %s", m)
+		}
+	}
+	return nil
+}
+
+// sourceStdlib evaluates core.sh and the modules selected by WithStdlib.
+// The embedded sources are evaluated directly, without reading the mount,
+// named after their path for error messages.
+func (d *Dash) sourceStdlib(ctx context.Context) error {
+	if len(d.state.stdlib) == 0 {
+		return nil
+	}
+	// json_escape finds character codes by position in this string.
+	var ctl strings.Builder
+	for c := byte(1); c < 32; c++ {
+		ctl.WriteByte(c)
+	}
+	if err := d.SetVar(ctx, "_stdlib_ctl", ctl.String()); err != nil {
+		return err
+	}
+	defer d.setArg0(d.arg0)
+	for _, m := range append([]string{"core"}, d.state.stdlib...) {
+		script, err := stdlibScripts.ReadFile("stdlib/" + m + ".sh")
+		if err != nil {
+			return err
+		}
+		d.setArg0(StdlibDir + "/" + m + ".sh")
+		status, err := d.eval(ctx, string(script))
+		if err != nil {
+			return err
+		}
+		if status != 0 {
+			return fmt.Errorf("dash: sourcing stdlib module %s failed with status %d", m, status)
+		}
+	}
+	return nil
+}
+
+// stdlibMount returns the read-only mount of StdlibDir.
+func stdlibMount() (mount, error) {
+	fsys, err := stdlibFS()
+	if err != nil {
+		return mount{}, err
+	}
+	return mount{guest: StdlibDir, fs: &readOnlyFS{FS: &sysfs.AdaptFS{FS: fsys}}}, nil
+}
+
+// sleepCommand implements `sleep SECONDS`, accepting fractions.
+func sleepCommand(ctx context.Context, d *Dash, argv []string) int {
+	const name = "sleep"
+	if len(argv) != 2 {
+		commandError(ctx, d.mod, d.state, name, "usage: sleep seconds")
+		return 2
+	}
+	secs, err := strconv.ParseFloat(argv[1], 64)
+	if err != nil || secs < 0 {
+		commandError(ctx, d.mod, d.state, name, "invalid time interval '"+argv[1]+"'")
+		return 2
+	}
+	t := time.NewTimer(time.Duration(secs * float64(time.Second)))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return 0
+	case <-ctx.Done():
+		return 1
+	}
+}
+
+// runStderr handles `@stderr ARGS...`, writing the arguments separated by
+// spaces and a newline to the guest stderr.
+func runStderr(ctx context.Context, d *Dash, argv []string) int {
+	if err := guestWrite(ctx, d.mod, d.state, fdStderr, []byte(strings.Join(argv[1:], " ")+"\n")); err != nil {
+		return 1
+	}
+	return 0
+}
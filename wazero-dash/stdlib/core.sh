@@ -0,0 +1,17 @@
+# core.sh: helpers shared by the dash-wasi standard library modules.
+#
+# The reactor has no command substitution, so functions computing a string
+# store it in a variable named by their first argument.
+
+# _stdlib_set NAME VALUE
+# Assigns VALUE to the variable NAME. Fails with status 2 if NAME is not a
+# valid variable name.
+_stdlib_set() {
+	case $1 in
+	'' | [!A-Za-z_]* | *[!A-Za-z0-9_]*)
+		@stderr "$1: invalid variable name"
+		return 2
+		;;
+	esac
+	eval "$1=\$2"
+}
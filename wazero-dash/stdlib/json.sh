@@ -0,0 +1,57 @@
+# json.sh: JSON encoding helpers. Results are stored in the variable named
+# by the first argument.
+
+# json_escape VAR STRING
+# Sets VAR to STRING encoded as a JSON string, including the quotes.
+json_escape() {
+	local _json_s="$2" _json_out= _json_c _json_rest _json_n
+	case $_json_s in
+	*[\"\\[:cntrl:]]*) ;;
+	*)
+		_stdlib_set "$1" "\"$_json_s\""
+		return
+		;;
+	esac
+	while [ -n "$_json_s" ]; do
+		_json_rest=${_json_s#?}
+		_json_c=${_json_s%"$_json_rest"}
+		_json_s=$_json_rest
+		case $_json_c in
+		\" | \\)
+			_json_c=\\$_json_c
+			;;
+		[[:cntrl:]])
+			# _stdlib_ctl holds the control characters 1 to 31 in order.
+			_json_n=${_stdlib_ctl%%"$_json_c"*}
+			if [ "$_json_n" != "$_stdlib_ctl" ]; then
+				_json_n=$((${#_json_n} + 1))
+				case $_json_n in
+				8) _json_c='\b' ;;
+				9) _json_c='\t' ;;
+				10) _json_c='\n' ;;
+				12) _json_c='\f' ;;
+				13) _json_c='\r' ;;
+				*) _json_hex "$_json_n" ;;
+				esac
+			fi
+			;;
+		esac
+		_json_out=$_json_out$_json_c
+	done
+	_stdlib_set "$1" "\"$_json_out\""
+}
+
+# _json_hex N
+# Sets _json_c to the \u escape of the character code N, below 32.
+_json_hex() {
+	local _json_lo=$(($1 % 16))
+	case $_json_lo in
+	10) _json_lo=a ;;
+	11) _json_lo=b ;;
+	12) _json_lo=c ;;
+	13) _json_lo=d ;;
+	14) _json_lo=e ;;
+	15) _json_lo=f ;;
+	esac
+	_json_c="\\u00$(($1 / 16))$_json_lo"
+}
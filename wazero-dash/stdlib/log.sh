@@ -0,0 +1,38 @@
+# log.sh: leveled logging to stderr.
+#
+# LOG_LEVEL selects the lowest level written: debug, info (the default),
+# warn or error.
+
+# log_debug MESSAGE...
+log_debug() {
+	_log_write 0 DEBUG "$@"
+}
+
+# log_info MESSAGE...
+log_info() {
+	_log_write 1 INFO "$@"
+}
+
+# log_warn MESSAGE...
+log_warn() {
+	_log_write 2 WARN "$@"
+}
+
+# log_error MESSAGE...
+log_error() {
+	_log_write 3 ERROR "$@"
+}
+
+# _log_write LEVEL LABEL MESSAGE...
+_log_write() {
+	local _log_min _log_label="[$2]"
+	case ${LOG_LEVEL:-info} in
+	debug) _log_min=0 ;;
+	warn) _log_min=2 ;;
+	error) _log_min=3 ;;
+	*) _log_min=1 ;;
+	esac
+	[ "$1" -ge "$_log_min" ] || return 0
+	shift 2
+	@stderr "$_log_label" "$@"
+}
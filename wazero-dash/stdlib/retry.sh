@@ -0,0 +1,46 @@
+# retry.sh: running commands until they succeed.
+
+# retry ATTEMPTS COMMAND [ARG...]
+# Runs COMMAND until it succeeds, at most ATTEMPTS times. Returns the
+# status of the last attempt.
+retry() {
+	local _retry_n="$1" _retry_i=1 _retry_status
+	shift
+	while :; do
+		"$@" && return 0
+		_retry_status=$?
+		[ "$_retry_i" -ge "$_retry_n" ] && return "$_retry_status"
+		_retry_i=$((_retry_i + 1))
+	done
+}
+
+# with_backoff [-n ATTEMPTS] [-d DELAY] [-m MAX_DELAY] COMMAND [ARG...]
+# Runs COMMAND until it succeeds, at most ATTEMPTS times (default 5),
+# sleeping DELAY seconds (default 1) after the first failure and doubling
+# the delay after each further one, up to MAX_DELAY seconds (default 60).
+# Delays are whole seconds. Returns the status of the last attempt.
+with_backoff() {
+	local _backoff_n=5 _backoff_delay=1 _backoff_max=60 _backoff_i=1 _backoff_status
+	while [ $# -gt 0 ]; do
+		case $1 in
+		-n) _backoff_n=$2 ;;
+		-d) _backoff_delay=$2 ;;
+		-m) _backoff_max=$2 ;;
+		--)
+			shift
+			break
+			;;
+		*) break ;;
+		esac
+		shift 2
+	done
+	while :; do
+		"$@" && return 0
+		_backoff_status=$?
+		[ "$_backoff_i" -ge "$_backoff_n" ] && return "$_backoff_status"
+		sleep "$_backoff_delay"
+		_backoff_delay=$((_backoff_delay * 2))
+		[ "$_backoff_delay" -gt "$_backoff_max" ] && _backoff_delay=$_backoff_max
+		_backoff_i=$((_backoff_i + 1))
+	done
+}
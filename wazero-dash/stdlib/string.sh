@@ -0,0 +1,103 @@
+# string.sh: string helpers. Results are stored in the variable named by
+# the first argument.
+
+# str_trim VAR STRING
+# Sets VAR to STRING without leading and trailing whitespace.
+str_trim() {
+	local _str_s="$2"
+	_str_s=${_str_s#"${_str_s%%[![:space:]]*}"}
+	_str_s=${_str_s%"${_str_s##*[![:space:]]}"}
+	_stdlib_set "$1" "$_str_s"
+}
+
+# str_replace VAR STRING OLD NEW
+# Sets VAR to STRING with every occurrence of OLD replaced by NEW.
+str_replace() {
+	local _str_s="$2" _str_out=
+	if [ -z "$3" ]; then
+		_stdlib_set "$1" "$2"
+		return
+	fi
+	while :; do
+		case $_str_s in
+		*"$3"*)
+			_str_out=$_str_out${_str_s%%"$3"*}$4
+			_str_s=${_str_s#*"$3"}
+			;;
+		*)
+			break
+			;;
+		esac
+	done
+	_stdlib_set "$1" "$_str_out$_str_s"
+}
+
+# str_upper VAR STRING
+# Sets VAR to STRING with the ASCII letters in upper case.
+str_upper() {
+	_str_map "$1" "$2" abcdefghijklmnopqrstuvwxyz ABCDEFGHIJKLMNOPQRSTUVWXYZ
+}
+
+# str_lower VAR STRING
+# Sets VAR to STRING with the ASCII letters in lower case.
+str_lower() {
+	_str_map "$1" "$2" ABCDEFGHIJKLMNOPQRSTUVWXYZ abcdefghijklmnopqrstuvwxyz
+}
+
+# _str_map VAR STRING FROM TO
+# Sets VAR to STRING with each character of FROM replaced by the character
+# of TO at the same position.
+_str_map() {
+	local _str_s="$2" _str_out= _str_c _str_rest _str_from _str_to
+	while [ -n "$_str_s" ]; do
+		_str_rest=${_str_s#?}
+		_str_c=${_str_s%"$_str_rest"}
+		_str_s=$_str_rest
+		case $3 in
+		*"$_str_c"*)
+			# Drop as many characters from TO as precede c in FROM.
+			_str_from=${3%%"$_str_c"*}
+			_str_to=$4
+			while [ -n "$_str_from" ]; do
+				_str_from=${_str_from#?}
+				_str_to=${_str_to#?}
+			done
+			_str_c=${_str_to%"${_str_to#?}"}
+			;;
+		esac
+		_str_out=$_str_out$_str_c
+	done
+	_stdlib_set "$1" "$_str_out"
+}
+
+# str_repeat VAR STRING COUNT
+# Sets VAR to STRING repeated COUNT times.
+str_repeat() {
+	local _str_out= _str_i=0
+	while [ "$_str_i" -lt "$3" ]; do
+		_str_out=$_str_out$2
+		_str_i=$((_str_i + 1))
+	done
+	_stdlib_set "$1" "$_str_out"
+}
+
+# str_starts_with STRING PREFIX
+# Succeeds if STRING starts with PREFIX.
+str_starts_with() {
+	case $1 in "$2"*) return 0 ;; esac
+	return 1
+}
+
+# str_ends_with STRING SUFFIX
+# Succeeds if STRING ends with SUFFIX.
+str_ends_with() {
+	case $1 in *"$2") return 0 ;; esac
+	return 1
+}
+
+# str_contains STRING SUBSTRING
+# Succeeds if STRING contains SUBSTRING.
+str_contains() {
+	case $1 in *"$2"*) return 0 ;; esac
+	return 1
+}
@@ -0,0 +1,112 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func newStdlibDash(t *testing.T, modules ...string) (*Dash, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	t.Cleanup(func() { _ = r.Close(ctx) })
+
+	var stdout, stderr bytes.Buffer
+	config := wazero.NewModuleConfig().WithStdout(&stdout).WithStderr(&stderr)
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config), WithStdlib(modules...))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	t.Cleanup(func() { _ = d.Close(ctx) })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	return d, &stdout, &stderr
+}
+
+func TestStdlib(t *testing.T) {
+	d, stdout, stderr := newStdlibDash(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		script string
+		want   string
+	}{
+		{`str_trim x "  a b	 "`, "a b"},
+		{`str_replace x "a.b.c" . --`, "a--b--c"},
+		{`str_replace x "a*b" "*" ""`, "ab"},
+		{`str_upper x "Hello, World*"`, "HELLO, WORLD*"},
+		{`str_lower x "Hello, World*"`, "hello, world*"},
+		{`str_repeat x ab 3`, "ababab"},
+		{`str_starts_with foobar foo && str_ends_with foobar bar && ! str_contains foobar x && x=ok`, "ok"},
+		{`json_escape x plain`, `"plain"`},
+		{"json_escape x \"a\\\"b\\\\c\n\td\x01\x1f\"", `"a\"b\\c\n\td\u0001\u001f"`},
+	} {
+		if _, err := d.Eval(ctx, "unset x; "+tc.script); err != nil {
+			t.Fatal("Eval:", err)
+		}
+		got, err := d.GetVar(ctx, "x")
+		if err != nil {
+			t.Fatal("GetVar:", err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %q, got %q (stderr %q)", tc.script, tc.want, got, stderr.String())
+		}
+	}
+
+	stderr.Reset()
+	if _, err := d.Eval(ctx, "log_debug hidden; log_info hello world; LOG_LEVEL=debug; log_debug shown; LOG_LEVEL=error; log_warn hidden; log_error bad"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got, want := stderr.String(), "[INFO] hello world\n[DEBUG] shown\n[ERROR] bad\n"; got != want {
+		t.Fatalf("expected log %q, got %q", want, got)
+	}
+
+	start := time.Now()
+	if _, err := d.Eval(ctx, `f() { n=$((n+1)); [ $n -ge 3 ]; }
+n=0; retry 5 f; echo "retry $? $n"
+n=0; retry 2 f; echo "retry $? $n"
+n=0; with_backoff -n 2 -d 0 f; echo "backoff $? $n"
+n=0; with_backoff -n 3 -d 0 f; echo "backoff $? $n"`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if got, want := stdout.String(), "retry 0 3\nretry 1 2\nbackoff 1 2\nbackoff 0 3\n"; got != want {
+		t.Fatalf("expected %q, got %q (stderr %q)", want, got, stderr.String())
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("with_backoff -d 0 slept")
+	}
+
+	status, err := d.Eval(ctx, `str_trim "bad name" x`)
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if status != 2 {
+		t.Fatalf("expected status 2 for an invalid name, got %d", status)
+	}
+}
+
+func TestStdlibModules(t *testing.T) {
+	d, stdout, _ := newStdlibDash(t, "log")
+	ctx := context.Background()
+
+	if _, err := d.Eval(ctx, `command -v log_info; command -v str_trim || echo no str_trim
+echo /usr/lib/dash-wasi/*
+sleep 0.01 && echo slept`); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	want := "log_info\nno str_trim\n/usr/lib/dash-wasi/core.sh /usr/lib/dash-wasi/json.sh /usr/lib/dash-wasi/log.sh /usr/lib/dash-wasi/retry.sh /usr/lib/dash-wasi/string.sh\nslept\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	if _, err := NewDash(ctx, WithRuntime(r), WithStdlib("missing")); err == nil {
+		t.Fatal("expected an error for an unknown module")
+	}
+}
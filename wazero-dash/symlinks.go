@@ -0,0 +1,338 @@
+package dash
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// SymlinkPolicy controls how the guest follows symbolic links on host
+// directory mounts.
+//
+// Paths climbing above a mount with .. are always rejected. Symbolic links,
+// however, are followed by the host with SymlinkFollow, so a link inside a
+// mounted directory pointing outside of it lets scripts reach the wider host
+// file system. SymlinkDeny and SymlinkWithinRoot resolve every path one
+// component at a time instead, keeping scripts inside the mount. They do not
+// protect against host processes changing the directory concurrently.
+type SymlinkPolicy int
+
+const (
+	// SymlinkFollow lets the host follow symbolic links wherever they point.
+	SymlinkFollow SymlinkPolicy = iota
+	// SymlinkDeny fails with ELOOP wherever a symbolic link would be
+	// followed, and with EPERM when creating one. Links can still be listed,
+	// read with readlink and removed.
+	SymlinkDeny
+	// SymlinkWithinRoot follows symbolic links as if the mount were the
+	// root directory: absolute targets start at the mount and .. stops at
+	// it. Links created or moved by the guest must have relative targets
+	// staying inside the mount, with .. only leading, or fail with EPERM.
+	SymlinkWithinRoot
+)
+
+// maxSymlinkHops is the number of symbolic links followed resolving a path
+// before failing with ELOOP, as SYMLOOP_MAX on Linux.
+const maxSymlinkHops = 40
+
+// String returns the policy name as accepted by UnmarshalText.
+func (p SymlinkPolicy) String() string {
+	switch p {
+	case SymlinkFollow:
+		return "follow"
+	case SymlinkDeny:
+		return "deny"
+	case SymlinkWithinRoot:
+		return "within-root"
+	default:
+		return "SymlinkPolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p SymlinkPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *SymlinkPolicy) UnmarshalText(text []byte) error {
+	for _, v := range []SymlinkPolicy{SymlinkFollow, SymlinkDeny, SymlinkWithinRoot} {
+		if string(text) == v.String() {
+			*p = v
+			return nil
+		}
+	}
+	return errors.New("unknown symlink policy: " + string(text))
+}
+
+// WithSymlinkPolicy sets how symbolic links are followed on the WithDirMount,
+// WithOverlayDir and Policy mounts. The default is SymlinkFollow.
+func WithSymlinkPolicy(policy SymlinkPolicy) Option {
+	return func(o *options) {
+		o.symlinks = policy
+	}
+}
+
+// hostDirFS returns the file system of the host directory dir, confined to
+// it unless policy is SymlinkFollow.
+func hostDirFS(dir string, policy SymlinkPolicy) experimentalsys.FS {
+	fsys := sysfs.DirFS(dir)
+	if policy == SymlinkFollow {
+		return fsys
+	}
+	return &confinedFS{FS: fsys, policy: policy}
+}
+
+// confinedFS resolves the symbolic links of paths itself, so that the
+// wrapped host directory file system only sees paths without links, except
+// for a last component it does not follow.
+type confinedFS struct {
+	experimentalsys.FS
+	policy SymlinkPolicy
+}
+
+// resolve returns p with its symbolic links resolved within the root. The
+// last component is followed if followLast is set or p has a trailing
+// slash; it need not exist, so files can be created.
+func (f *confinedFS) resolve(p string, followLast bool) (string, experimentalsys.Errno) {
+	trailing := strings.HasSuffix(p, "/")
+	followLast = followLast || trailing
+	pending := strings.Split(p, "/")
+	var resolved []string
+	hops := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+		last := !hasComponents(pending)
+		cur := path.Join(append(resolved, name)...)
+		if last && !followLast {
+			resolved = append(resolved, name)
+			break
+		}
+		st, errno := f.FS.Lstat(cur)
+		if errno == experimentalsys.ENOENT && last {
+			resolved = append(resolved, name)
+			break
+		} else if errno != 0 {
+			return "", errno
+		}
+		if st.Mode&fs.ModeSymlink == 0 {
+			if !last && !st.Mode.IsDir() {
+				return "", experimentalsys.ENOTDIR
+			}
+			resolved = append(resolved, name)
+			continue
+		}
+		if f.policy == SymlinkDeny {
+			return "", experimentalsys.ELOOP
+		}
+		if hops++; hops > maxSymlinkHops {
+			return "", experimentalsys.ELOOP
+		}
+		target, errno := f.FS.Readlink(cur)
+		if errno != 0 {
+			return "", errno
+		}
+		if path.IsAbs(target) {
+			resolved = resolved[:0]
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	if len(resolved) == 0 {
+		return ".", 0
+	}
+	if trailing {
+		// Keep the slash so the host fails with ENOTDIR on files.
+		return path.Join(resolved...) + "/", 0
+	}
+	return path.Join(resolved...), 0
+}
+
+// hasComponents reports whether the remaining components of a path name
+// anything but the directory reached.
+func hasComponents(pending []string) bool {
+	for _, name := range pending {
+		if name != "" && name != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenFile implements experimentalsys.FS. Like open(2), O_NOFOLLOW and
+// O_EXCL leave a final symbolic link unresolved.
+func (f *confinedFS) OpenFile(p string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	followLast := flag&experimentalsys.O_NOFOLLOW == 0 && !(flag&experimentalsys.O_CREAT != 0 && flag&experimentalsys.O_EXCL != 0)
+	p, errno := f.resolve(p, followLast)
+	if errno != 0 {
+		return nil, errno
+	}
+	return f.FS.OpenFile(p, flag, perm)
+}
+
+// Lstat implements experimentalsys.FS.
+func (f *confinedFS) Lstat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	p, errno := f.resolve(p, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return f.FS.Lstat(p)
+}
+
+// Stat implements experimentalsys.FS.
+func (f *confinedFS) Stat(p string) (sys.Stat_t, experimentalsys.Errno) {
+	p, errno := f.resolve(p, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return f.FS.Stat(p)
+}
+
+// Mkdir implements experimentalsys.FS.
+func (f *confinedFS) Mkdir(p string, perm fs.FileMode) experimentalsys.Errno {
+	p, errno := f.resolve(p, false)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Mkdir(p, perm)
+}
+
+// Chmod implements experimentalsys.FS.
+func (f *confinedFS) Chmod(p string, perm fs.FileMode) experimentalsys.Errno {
+	p, errno := f.resolve(p, true)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Chmod(p, perm)
+}
+
+// Rename implements experimentalsys.FS. Symbolic links are renamed, not
+// their targets.
+func (f *confinedFS) Rename(from, to string) experimentalsys.Errno {
+	from, errno := f.resolve(from, false)
+	if errno != 0 {
+		return errno
+	}
+	if to, errno = f.resolve(to, false); errno != 0 {
+		return errno
+	}
+	if st, errno := f.FS.Lstat(from); errno == 0 && st.Mode&fs.ModeSymlink != 0 {
+		target, errno := f.FS.Readlink(from)
+		if errno != 0 {
+			return errno
+		}
+		if escapesRoot(path.Dir(to), target) {
+			return experimentalsys.EPERM
+		}
+	}
+	return f.FS.Rename(from, to)
+}
+
+// Rmdir implements experimentalsys.FS.
+func (f *confinedFS) Rmdir(p string) experimentalsys.Errno {
+	p, errno := f.resolve(p, false)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Rmdir(p)
+}
+
+// Unlink implements experimentalsys.FS. Symbolic links are removed, not
+// their targets.
+func (f *confinedFS) Unlink(p string) experimentalsys.Errno {
+	p, errno := f.resolve(p, false)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Unlink(p)
+}
+
+// Link implements experimentalsys.FS. As POSIX specifies, a symbolic link
+// oldPath is followed, so the new link never names a file outside the root
+// whatever the host does.
+func (f *confinedFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	oldPath, errno := f.resolve(oldPath, true)
+	if errno != 0 {
+		return errno
+	}
+	if newPath, errno = f.resolve(newPath, false); errno != 0 {
+		return errno
+	}
+	return f.FS.Link(oldPath, newPath)
+}
+
+// Symlink implements experimentalsys.FS.
+func (f *confinedFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	if f.policy == SymlinkDeny {
+		return experimentalsys.EPERM
+	}
+	linkName, errno := f.resolve(linkName, false)
+	if errno != 0 {
+		return errno
+	}
+	if escapesRoot(path.Dir(linkName), oldPath) {
+		return experimentalsys.EPERM
+	}
+	return f.FS.Symlink(oldPath, linkName)
+}
+
+// escapesRoot reports whether a link in the directory dir with the given
+// target could point outside the root. Only relative targets whose ..
+// components lead, and climb no higher than the root, are safe: a .. later
+// in the target could follow an intermediate link.
+func escapesRoot(dir, target string) bool {
+	if path.IsAbs(target) {
+		return true
+	}
+	depth := 0
+	if dir = strings.TrimSuffix(dir, "/"); dir != "." {
+		depth = strings.Count(dir, "/") + 1
+	}
+	climbing := true
+	for _, name := range strings.Split(target, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			if !climbing || depth == 0 {
+				return true
+			}
+			depth--
+		default:
+			climbing = false
+		}
+	}
+	return false
+}
+
+// Readlink implements experimentalsys.FS.
+func (f *confinedFS) Readlink(p string) (string, experimentalsys.Errno) {
+	p, errno := f.resolve(p, false)
+	if errno != 0 {
+		return "", errno
+	}
+	return f.FS.Readlink(p)
+}
+
+// Utimens implements experimentalsys.FS.
+func (f *confinedFS) Utimens(p string, atim, mtim int64) experimentalsys.Errno {
+	p, errno := f.resolve(p, true)
+	if errno != 0 {
+		return errno
+	}
+	return f.FS.Utimens(p, atim, mtim)
+}
@@ -0,0 +1,307 @@
+package dash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+func TestSymlinkPolicyText(t *testing.T) {
+	for _, p := range []SymlinkPolicy{SymlinkFollow, SymlinkDeny, SymlinkWithinRoot} {
+		text, err := p.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got SymlinkPolicy
+		if err := got.UnmarshalText(text); err != nil || got != p {
+			t.Errorf("round trip of %v: got %v, %v", p, got, err)
+		}
+	}
+	var p SymlinkPolicy
+	if err := p.UnmarshalText([]byte("chase")); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+	policy, err := LoadPolicy(strings.NewReader(`{"symlinks": "within-root"}`))
+	if err != nil || policy.Symlinks == nil || *policy.Symlinks != SymlinkWithinRoot {
+		t.Errorf("LoadPolicy: got %+v, %v", policy, err)
+	}
+}
+
+// symlinkTree creates a mount root next to a directory holding a secret,
+// with links from the root to the secret, and returns their parent.
+//
+//	outside/secret
+//	root/inside
+//	root/ok -> inside
+//	root/sub/up -> ../inside
+//	root/sub/deep -> ../../../inside
+//	root/rel -> ../outside/secret
+//	root/abs -> <base>/outside/secret
+//	root/outdir -> ../outside
+//	root/loop -> loop
+func symlinkTree(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires privileges on Windows")
+	}
+	base := t.TempDir()
+	for _, dir := range []string{"outside", "root/sub"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string]string{"outside/secret": "secret\n", "root/inside": "inside\n"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range map[string]string{
+		"root/ok":       "inside",
+		"root/sub/up":   "../inside",
+		"root/sub/deep": "../../../inside",
+		"root/rel":      "../outside/secret",
+		"root/abs":      filepath.Join(base, "outside", "secret"),
+		"root/outdir":   "../outside",
+		"root/loop":     "loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+// TestSymlinkEscape encodes the threat model of host directory mounts: a
+// script, possibly hostile, only controls the guest, and must not read,
+// write, stat or chmod host files outside of the mounted directories. The
+// host directory may contain symbolic links planted by someone else, pointing
+// anywhere. Host processes changing the directory while the script runs
+// (TOCTOU races) and hard links planted on the host are out of scope, as are
+// host tools later following links the script left.
+//
+// Lexical escapes with .. are rejected under every policy. Symbolic links
+// escape under SymlinkFollow, which is why it is not a sandbox.
+func TestSymlinkEscape(t *testing.T) {
+	const (
+		inside = "inside\n"
+		secret = "secret\n"
+	)
+	for _, tc := range []struct {
+		policy SymlinkPolicy
+		// files maps guest paths to their expected contents, empty if the
+		// path must not be reachable.
+		files map[string]string
+	}{
+		{SymlinkFollow, map[string]string{
+			"/work/inside":                   inside,
+			"/work/ok":                       inside,
+			"/work/rel":                      secret,
+			"/work/outdir/secret":            secret,
+			"/work/../outside/secret":        "",
+			"/work/sub/../../outside/secret": "",
+		}},
+		{SymlinkDeny, map[string]string{
+			"/work/inside":            inside,
+			"/work/ok":                "",
+			"/work/sub/up":            "",
+			"/work/rel":               "",
+			"/work/abs":               "",
+			"/work/outdir/secret":     "",
+			"/work/loop":              "",
+			"/work/../outside/secret": "",
+		}},
+		{SymlinkWithinRoot, map[string]string{
+			"/work/inside":            inside,
+			"/work/ok":                inside,
+			"/work/sub/up":            inside,
+			"/work/sub/deep":          inside,
+			"/work/rel":               "",
+			"/work/abs":               "",
+			"/work/outdir/secret":     "",
+			"/work/loop":              "",
+			"/work/../outside/secret": "",
+		}},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			ctx := context.Background()
+			base := symlinkTree(t)
+			d, err := NewDash(ctx, WithDirMount(filepath.Join(base, "root"), "/work"), WithSymlinkPolicy(tc.policy))
+			if err != nil {
+				t.Fatal("NewDash:", err)
+			}
+			defer d.Close(ctx)
+			if err := d.Init(ctx, nil); err != nil {
+				t.Fatal("Init:", err)
+			}
+
+			for guest, want := range tc.files {
+				stdout, _, _, err := d.EvalCapture(ctx, `test -f '`+guest+`' && echo found`)
+				if err != nil {
+					t.Fatal("EvalCapture:", err)
+				}
+				if found := string(stdout) == "found\n"; found != (want != "") {
+					t.Errorf("test -f %s: got %q", guest, stdout)
+				}
+				data, err := d.ReadFile(guest)
+				if want == "" && err == nil {
+					t.Errorf("ReadFile %s: expected an error, got %q", guest, data)
+				} else if want != "" && (err != nil || string(data) != want) {
+					t.Errorf("ReadFile %s: expected %q, got %q, %v", guest, want, data, err)
+				}
+			}
+			stdout, _, _, err := d.EvalCapture(ctx, `cd /work/sub && test -f ../../outside/secret && echo found`)
+			if err != nil {
+				t.Fatal("EvalCapture:", err)
+			}
+			if len(stdout) != 0 {
+				t.Errorf("test -f above the mount from a subdirectory: got %q", stdout)
+			}
+			if tc.policy == SymlinkFollow {
+				return
+			}
+
+			// Redirections open the files even though the guest then fails
+			// to move the descriptor, so the host shows what they reached.
+			script := `cd /work
+true >rel
+true >outdir/new
+chmod 777 rel || echo chmod failed
+cd outdir || echo cd failed
+test -L rel && echo listed
+test -e rel || echo dangling`
+			stdout, _, _, err = d.EvalCapture(ctx, script)
+			if err != nil {
+				t.Fatal("EvalCapture:", err)
+			}
+			want := "chmod failed\ncd failed\nlisted\ndangling\n"
+			if got := string(stdout); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+			data, err := os.ReadFile(filepath.Join(base, "outside", "secret"))
+			if err != nil || string(data) != secret {
+				t.Errorf("outside file changed: %q, %v", data, err)
+			}
+			if info, err := os.Stat(filepath.Join(base, "outside", "secret")); err != nil || info.Mode().Perm() != 0o644 {
+				t.Errorf("outside file mode changed: %v, %v", info, err)
+			}
+			for _, name := range []string{"outside/new", "root/outside"} {
+				if _, err := os.Lstat(filepath.Join(base, name)); !os.IsNotExist(err) {
+					t.Errorf("expected no %s, got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+// TestConfinedFS covers the link, rename and removal edge cases the shell
+// has no commands for.
+func TestConfinedFS(t *testing.T) {
+	errno := func(t *testing.T, op string, got, want experimentalsys.Errno) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: expected %v, got %v", op, want, got)
+		}
+	}
+
+	t.Run("deny", func(t *testing.T) {
+		base := symlinkTree(t)
+		root := filepath.Join(base, "root")
+		fsys := hostDirFS(root, SymlinkDeny)
+
+		errno(t, "Symlink", fsys.Symlink("inside", "new"), experimentalsys.EPERM)
+		errno(t, "Link through a symlink", fsys.Link("rel", "hard"), experimentalsys.ELOOP)
+		errno(t, "Mkdir below a symlink", fsys.Mkdir("outdir/new", 0o755), experimentalsys.ELOOP)
+		_, e := fsys.Stat("ok")
+		errno(t, "Stat", e, experimentalsys.ELOOP)
+		_, e = fsys.Lstat("rel")
+		errno(t, "Lstat", e, 0)
+		target, e := fsys.Readlink("rel")
+		errno(t, "Readlink", e, 0)
+		if target != "../outside/secret" {
+			t.Errorf("Readlink: got %q", target)
+		}
+		errno(t, "Rename", fsys.Rename("rel", "sub/rel"), 0)
+		errno(t, "Unlink", fsys.Unlink("sub/rel"), 0)
+		if _, err := os.Stat(filepath.Join(base, "outside", "secret")); err != nil {
+			t.Errorf("unlinking the link removed its target: %v", err)
+		}
+	})
+
+	t.Run("within-root", func(t *testing.T) {
+		base := symlinkTree(t)
+		root := filepath.Join(base, "root")
+		fsys := hostDirFS(root, SymlinkWithinRoot)
+
+		for _, tc := range []struct {
+			target, link string
+			want         experimentalsys.Errno
+		}{
+			{"inside", "l1", 0},
+			{"../inside", "sub/l2", 0},
+			{"./sub/up", "l3", 0},
+			{"../inside", "l4", experimentalsys.EPERM},
+			{"/etc/passwd", "l5", experimentalsys.EPERM},
+			{"sub/../../outside", "l6", experimentalsys.EPERM},
+			// ok is a link to inside, so ok/.. might be anywhere.
+			{"ok/../inside", "l7", experimentalsys.EPERM},
+			{"../../outside/secret", "sub/l8", experimentalsys.EPERM},
+			// The link name resolves first: outdir/l9 is root/outside/l9.
+			{"x", "outdir/l9", experimentalsys.ENOENT},
+		} {
+			errno(t, "Symlink "+tc.link+" -> "+tc.target, fsys.Symlink(tc.target, tc.link), tc.want)
+		}
+
+		// Moving a relative link shallower would make it escape.
+		errno(t, "Rename sub/l2 to the root", fsys.Rename("sub/l2", "l2"), experimentalsys.EPERM)
+		errno(t, "Rename sub/up to the root", fsys.Rename("sub/up", "up"), experimentalsys.EPERM)
+		errno(t, "Mkdir", fsys.Mkdir("sub/more", 0o755), 0)
+		errno(t, "Rename sub/l2 deeper", fsys.Rename("sub/l2", "sub/more/l2"), 0)
+		// Renaming replaces a link, not its target.
+		errno(t, "Rename over a link", fsys.Rename("l1", "rel"), 0)
+		if data, err := os.ReadFile(filepath.Join(base, "outside", "secret")); err != nil || string(data) != "secret\n" {
+			t.Errorf("renaming over a link changed its target: %q, %v", data, err)
+		}
+		errno(t, "Rename through a link", fsys.Rename("inside", "outdir/stolen"), experimentalsys.ENOENT)
+
+		// Hard links follow the old path within the root.
+		errno(t, "Link", fsys.Link("ok", "hard"), 0)
+		info, err := os.Lstat(filepath.Join(root, "hard"))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Errorf("expected hard to be a regular file: %v, %v", info, err)
+		}
+		errno(t, "Link to an outside file", fsys.Link("abs", "hard2"), experimentalsys.ENOENT)
+		errno(t, "Link into an outside directory", fsys.Link("inside", "outdir/hard"), experimentalsys.ENOENT)
+
+		_, e := fsys.Stat("loop")
+		errno(t, "Stat loop", e, experimentalsys.ELOOP)
+		_, e = fsys.Stat("ok/")
+		errno(t, "Stat with a trailing slash", e, experimentalsys.ENOTDIR)
+		st, e := fsys.Stat("sub/deep")
+		errno(t, "Stat clamped link", e, 0)
+		if !st.Mode.IsRegular() {
+			t.Errorf("sub/deep: expected a regular file, got %v", st.Mode)
+		}
+		errno(t, "Unlink", fsys.Unlink("abs"), 0)
+		errno(t, "Rmdir through a link", fsys.Rmdir("outdir"), experimentalsys.ENOTDIR)
+		if _, err := os.Stat(filepath.Join(base, "outside")); err != nil {
+			t.Errorf("outside directory removed: %v", err)
+		}
+
+		for _, name := range []string{"outside/stolen", "outside/hard", "outside/l9"} {
+			if _, err := os.Lstat(filepath.Join(base, name)); !os.IsNotExist(err) {
+				t.Errorf("expected no %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		if _, ok := hostDirFS(t.TempDir(), SymlinkFollow).(*confinedFS); ok {
+			t.Error("SymlinkFollow should use the host directory directly")
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aperturerobotics/go-dash-wasi-reactor/shell"
+)
+
+// ErrReentrantCall is returned by the methods of a SyncDash called from a
+// host command run by one of its own calls, which would deadlock.
+var ErrReentrantCall = errors.New("dash: reentrant call from a host command")
+
+// SyncDash serializes the calls to a Dash made from several goroutines. A
+// Dash is not safe for concurrent use: two goroutines calling Eval at once
+// corrupt the shell.
+//
+// Host commands run by a call, such as RegisterCommand handlers or an
+// ExecHandler, receive a context marking it. Calling the SyncDash with that
+// context returns ErrReentrantCall; use the context given to the command so
+// it is detected. Host commands may still use the Dash itself.
+type SyncDash struct {
+	mu sync.Mutex
+	d  *Dash
+}
+
+var _ shell.Interpreter = (*SyncDash)(nil)
+
+// Synchronized returns a SyncDash serializing the calls to d. d must not
+// be used directly afterwards, other than by host commands.
+func Synchronized(d *Dash) *SyncDash {
+	return &SyncDash{d: d}
+}
+
+// syncCallKey is the context key of the syncCall chain.
+type syncCallKey struct{}
+
+// syncCall marks the contexts of the calls made through a SyncDash, linked
+// to the outer calls when SyncDashes call each other.
+type syncCall struct {
+	s      *SyncDash
+	parent *syncCall
+}
+
+// lock acquires s for a call with ctx, returning the context to call the
+// Dash with.
+func (s *SyncDash) lock(ctx context.Context) (context.Context, error) {
+	parent, _ := ctx.Value(syncCallKey{}).(*syncCall)
+	for c := parent; c != nil; c = c.parent {
+		if c.s == s {
+			return nil, ErrReentrantCall
+		}
+	}
+	s.mu.Lock()
+	return context.WithValue(ctx, syncCallKey{}, &syncCall{s: s, parent: parent}), nil
+}
+
+// Do calls fn with the Dash while holding the lock, for the methods
+// SyncDash does not wrap.
+func (s *SyncDash) Do(ctx context.Context, fn func(ctx context.Context, d *Dash) error) error {
+	ctx, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	return fn(ctx, s.d)
+}
+
+// Init calls Dash.Init.
+func (s *SyncDash) Init(ctx context.Context, args []string) error {
+	return s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		return d.Init(ctx, args)
+	})
+}
+
+// Eval calls Dash.Eval.
+func (s *SyncDash) Eval(ctx context.Context, cmd string) (status int, err error) {
+	status = -1
+	err = s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		status, err = d.Eval(ctx, cmd)
+		return err
+	})
+	return status, err
+}
+
+// EvalWithLimits calls Dash.EvalWithLimits.
+func (s *SyncDash) EvalWithLimits(ctx context.Context, cmd string, limits Limits) (status int, err error) {
+	status = -1
+	err = s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		status, err = d.EvalWithLimits(ctx, cmd, limits)
+		return err
+	})
+	return status, err
+}
+
+// GetVar calls Dash.GetVar.
+func (s *SyncDash) GetVar(ctx context.Context, name string) (value string, err error) {
+	err = s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		value, err = d.GetVar(ctx, name)
+		return err
+	})
+	return value, err
+}
+
+// SetVar calls Dash.SetVar.
+func (s *SyncDash) SetVar(ctx context.Context, name, value string) error {
+	return s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		return d.SetVar(ctx, name, value)
+	})
+}
+
+// Close calls Dash.Close once the running call returns.
+func (s *SyncDash) Close(ctx context.Context) error {
+	return s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		return d.Close(ctx)
+	})
+}
+
+// Capabilities calls Dash.Capabilities, which needs no lock.
+func (s *SyncDash) Capabilities() shell.Capabilities {
+	return s.d.Capabilities()
+}
+
+// Interrupt calls Dash.Interrupt. A SIGINT for a running evaluation is sent
+// at once; otherwise Interrupt waits for the running call, then runs the
+// INT trap.
+func (s *SyncDash) Interrupt(ctx context.Context) error {
+	if sig := s.d.state.sigint; sig != nil && sig.send() {
+		return nil
+	}
+	return s.Do(ctx, func(ctx context.Context, d *Dash) error {
+		return d.Interrupt(ctx)
+	})
+}
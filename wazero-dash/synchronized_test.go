@@ -0,0 +1,79 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSynchronized(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	s := Synchronized(d)
+	defer s.Close(ctx)
+	if err := s.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	// Concurrent calls are serialized.
+	const workers, rounds = 8, 10
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := "w" + strconv.Itoa(i)
+			for range rounds {
+				if _, err := s.Eval(ctx, "n=$((n + 1))"); err != nil {
+					t.Error("Eval:", err)
+					return
+				}
+				if err := s.SetVar(ctx, name, "x"); err != nil {
+					t.Error("SetVar:", err)
+					return
+				}
+				if _, err := s.GetVar(ctx, "n"); err != nil {
+					t.Error("GetVar:", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	n, err := s.GetVar(ctx, "n")
+	if err != nil {
+		t.Fatal("GetVar:", err)
+	}
+	if n != strconv.Itoa(workers*rounds) {
+		t.Fatalf("expected n=%d, got %s", workers*rounds, n)
+	}
+
+	// Reentrant calls from host commands fail instead of deadlocking; the
+	// Dash itself remains usable there.
+	var reentrant, direct error
+	if err := d.RegisterBuiltin("reenter", func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		_, reentrant = s.Eval(ctx, "true")
+		direct = d.SetVar(ctx, "inner", "ok")
+		return 0
+	}); err != nil {
+		t.Fatal("RegisterBuiltin:", err)
+	}
+	if _, err := s.Eval(ctx, "reenter"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if !errors.Is(reentrant, ErrReentrantCall) {
+		t.Fatalf("expected ErrReentrantCall, got %v", reentrant)
+	}
+	if direct != nil {
+		t.Fatal("SetVar from host command:", direct)
+	}
+	if v, _ := s.GetVar(ctx, "inner"); v != "ok" {
+		t.Fatalf("expected inner=ok, got %q", v)
+	}
+}
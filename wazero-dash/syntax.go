@@ -0,0 +1,181 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError reports a script that dash fails to parse.
+//
+// The line and message are the ones the parser records for the
+// dash_get_last_error export. Reactor builds without that export have them
+// scraped from the message dash writes to stderr, a heuristic that a
+// command printing a line worded like dash's also matches. Either way, the
+// error of a string run by the eval builtin is located in the script
+// itself.
+type SyntaxError struct {
+	// Line is the line of the error, counted from 1.
+	Line int
+	// Column is the byte offset of Near in the line, counted from 1, or 0
+	// if unknown. It is guessed from the last occurrence of Near, see
+	// tokenColumn.
+	Column int
+	// Near is the token the parser did not expect, such as "fi", or empty
+	// if the message names none.
+	Near string
+	// Message is the parser's message, such as `"fi" unexpected`.
+	Message string
+}
+
+// Error implements error.
+func (e *SyntaxError) Error() string {
+	pos := strconv.Itoa(e.Line)
+	if e.Column > 0 {
+		pos += ":" + strconv.Itoa(e.Column)
+	}
+	return ErrSourceSyntax.Error() + ": line " + pos + ": " + e.Message
+}
+
+// Unwrap returns ErrSourceSyntax.
+func (e *SyntaxError) Unwrap() error {
+	return ErrSourceSyntax
+}
+
+// unexpectedPattern matches a syntax error message naming the token the
+// parser did not expect.
+var unexpectedPattern = regexp.MustCompile(`^"(.*)" unexpected`)
+
+// CheckSyntax parses script as sh -n does, without executing any of it,
+// and returns a *SyntaxError if it fails to parse. Aliases defined in the
+// shell apply. The Column of an error is found by locating Near in the
+// line, so it is only known when the parser names the token.
+func (d *Dash) CheckSyntax(ctx context.Context, script string) error {
+	if err := d.ready(); err != nil {
+		return err
+	}
+	script = d.normalizeScript(script)
+	e, err := d.parseOnly(ctx, script)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		return e
+	}
+	return nil
+}
+
+// parseOnly evaluates script with the noexec option set, so it is parsed
+// but not executed, and returns its syntax error, if any. The shell cannot
+// unset noexec itself, so its memory is restored afterwards, from a
+// snapshot kept between calls: only the pages changed since the last call
+// are copied.
+func (d *Dash) parseOnly(ctx context.Context, script string) (*SyntaxError, error) {
+	snap := d.parseSnap
+	if snap == nil {
+		snap = captureMemory(d.mod)
+	} else {
+		d.parseSnap = nil
+		snap.refresh(d.mod)
+	}
+	defer func() {
+		snap.restore(d.mod)
+		d.parseSnap = snap
+	}()
+	var e *SyntaxError
+	stderr, err := d.captureStderr(func() error {
+		if _, err := d.evalQuiet(ctx, "set -n"); err != nil {
+			return err
+		}
+		if _, err := d.takeSyntaxError(ctx, script); err != nil {
+			return err
+		}
+		if _, err := d.evalQuiet(ctx, script); err != nil {
+			return err
+		}
+		var err error
+		e, err = d.takeSyntaxError(ctx, script)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.dashGetLastError == nil {
+		e = parseSyntaxError(stderr, script)
+	}
+	return e, nil
+}
+
+// takeSyntaxError returns the syntax error dash recorded since the last
+// call, located in script, and forgets it. Returns nil without the
+// dash_get_last_error export.
+func (d *Dash) takeSyntaxError(ctx context.Context, script string) (*SyntaxError, error) {
+	if d.dashGetLastError == nil {
+		return nil, nil
+	}
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	results, err := d.call(ctx, d.dashGetLastError)
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(results[0])
+	if ptr == 0 {
+		return nil, nil
+	}
+	defer d.freePtr(ctx, ptr)
+	entry := d.readCString(ptr)
+	line, msg, ok := strings.Cut(entry, ":")
+	n, err := strconv.Atoi(line)
+	if !ok || err != nil {
+		return nil, errors.New("dash_get_last_error: unexpected error: " + entry)
+	}
+	return newSyntaxError(n, msg, script), nil
+}
+
+// parseSyntaxError returns the syntax error reported in stderr for script,
+// or nil if there is none.
+func parseSyntaxError(stderr []byte, script string) *SyntaxError {
+	m := syntaxErrorPattern.FindSubmatch(stderr)
+	if m == nil {
+		return nil
+	}
+	line, _ := strconv.Atoi(string(m[1]))
+	return newSyntaxError(line, string(m[2]), script)
+}
+
+// newSyntaxError returns the syntax error msg at line of script, locating
+// the token it names.
+func newSyntaxError(line int, msg, script string) *SyntaxError {
+	e := &SyntaxError{Line: line, Message: strings.TrimSpace(msg)}
+	if u := unexpectedPattern.FindStringSubmatch(e.Message); u != nil {
+		e.Near = u[1]
+		if lines := strings.Split(script, "\n"); e.Line >= 1 && e.Line <= len(lines) {
+			e.Column = tokenColumn(lines[e.Line-1], e.Near)
+		}
+	}
+	return e
+}
+
+// tokenColumn returns the column, counted from 1, of the last occurrence of
+// tok in line that is not part of a longer word, or 0 if there is none.
+// Dash reports the first token it cannot use, which follows the valid
+// part of the line; the last occurrence is the likeliest.
+func tokenColumn(line, tok string) int {
+	if tok == "" {
+		return 0
+	}
+	word := func(b byte) bool {
+		return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+	}
+	for i := strings.LastIndex(line, tok); i >= 0; i = strings.LastIndex(line[:i], tok) {
+		end := i + len(tok)
+		if !word(tok[0]) || (i == 0 || !word(line[i-1])) && (end == len(line) || !word(line[end])) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckSyntax(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+	if _, err := d.Eval(ctx, "x=before"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+
+	valid := "echo run\nx=after\nif true; then\n  for f in a b; do echo $f; done\nfi\ncase $x in a) ;; esac"
+	if err := d.CheckSyntax(ctx, valid); err != nil {
+		t.Fatalf("CheckSyntax(valid) = %v", err)
+	}
+
+	for _, tc := range []struct {
+		script string
+		want   SyntaxError
+	}{
+		{"echo a\nfi\necho b", SyntaxError{Line: 2, Column: 1, Near: "fi", Message: `"fi" unexpected`}},
+		{"for in; do done", SyntaxError{Line: 1, Column: 12, Near: "done", Message: `"done" unexpected`}},
+		{"echo ok\ntrue && ;", SyntaxError{Line: 2, Column: 9, Near: ";", Message: `";" unexpected`}},
+		{"if true; then\n  echo a", SyntaxError{Line: 2, Message: `end of file unexpected (expecting "fi")`}},
+		{"echo 'a", SyntaxError{Line: 1, Message: "Unterminated quoted string"}},
+		// Stray closing keywords cannot escape the check.
+		{"}\nfi\nx=pwned\nif false; then {", SyntaxError{Line: 1, Column: 1, Near: "}", Message: `"}" unexpected`}},
+	} {
+		err := d.CheckSyntax(ctx, tc.script)
+		var se *SyntaxError
+		if !errors.As(err, &se) || *se != tc.want {
+			t.Errorf("CheckSyntax(%q) = %#v, want %#v", tc.script, err, tc.want)
+		}
+		if !errors.Is(err, ErrSourceSyntax) {
+			t.Errorf("CheckSyntax(%q) does not match ErrSourceSyntax", tc.script)
+		}
+	}
+
+	// Nothing ran and the shell works as before.
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("CheckSyntax wrote %q, %q", stdout.String(), stderr.String())
+	}
+	if status, err := d.Eval(ctx, `echo "$x"`); err != nil || status != 0 || stdout.String() != "before\n" {
+		t.Fatalf("shell changed by CheckSyntax: %d, %v, %q", status, err, stdout.String())
+	}
+	if want := "dash: syntax error: line 2:1: \"fi\" unexpected"; d.CheckSyntax(ctx, "echo a\nfi").Error() != want {
+		t.Fatalf("Error() = %q, want %q", d.CheckSyntax(ctx, "echo a\nfi").Error(), want)
+	}
+}
+
+func TestEvalSyntaxError(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, stderr := newTestDash(t)
+
+	status, err := d.Eval(ctx, "echo before\nif true; then\n  echo a\nfi fi")
+	var se *SyntaxError
+	if !errors.As(err, &se) || status != 2 {
+		t.Fatalf("Eval = %d, %v; want 2 and a *SyntaxError", status, err)
+	}
+	if want := (SyntaxError{Line: 4, Column: 4, Near: "fi", Message: `"fi" unexpected`}); *se != want {
+		t.Fatalf("got %#v, want %#v", *se, want)
+	}
+	if stdout.String() != "before\n" || stderr.Len() == 0 {
+		t.Fatalf("got stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	// Other scripts exiting with status 2 are not syntax errors.
+	stderr.Reset()
+	if status, err := d.Eval(ctx, "cd /missing"); err != nil || status != 2 || stderr.Len() == 0 {
+		t.Fatalf("Eval = %d, %v (stderr %q); want 2 and no error", status, err, stderr.String())
+	}
+}
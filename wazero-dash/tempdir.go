@@ -0,0 +1,149 @@
+package dash
+
+import (
+	"context"
+	"crypto/rand"
+	"path"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// TempDir is the guest path of the in-memory temporary directory.
+const TempDir = "/tmp"
+
+// DefaultTempDirMaxBytes is the default cap on file data stored in TempDir.
+const DefaultTempDirMaxBytes = 64 << 20
+
+// DirUsage reports the storage used by an in-memory directory.
+type DirUsage struct {
+	// Bytes is the size of the file data stored.
+	Bytes int64
+	// Files is the number of files, directories and links.
+	Files int64
+	// MaxBytes is the cap on Bytes, or zero if uncapped.
+	MaxBytes int64
+}
+
+// TempDirUsage returns the storage used by the in-memory /tmp.
+//
+// Each Dash has its own /tmp, discarded on Close. Writes past the cap fail
+// with EIO. Returns the zero value if the temp directory is disabled.
+func (d *Dash) TempDirUsage() DirUsage {
+	tmp := d.state.tmp
+	if tmp == nil {
+		return DirUsage{}
+	}
+	bytes, files := tmp.usage()
+	return DirUsage{Bytes: bytes, Files: files, MaxBytes: tmp.maxBytes}
+}
+
+// mktempCommand implements `mktemp [-dqt] [-p dir] [template]` on TempDir.
+//
+// Dash has no external commands to run, so scripts relying on mktemp would
+// otherwise fail. Templates without a slash are created in TempDir, or in
+// the -p directory, which must also be inside TempDir.
+func mktempCommand(ctx context.Context, d *Dash, argv []string) int {
+	mod, state := d.mod, d.state
+	const name = "mktemp"
+	var mkdir, quiet bool
+	dir := TempDir
+	args := argv[1:]
+	for len(args) != 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+		for i := 1; i < len(opt); i++ {
+			switch opt[i] {
+			case 'd':
+				mkdir = true
+			case 'q':
+				quiet = true
+			case 't':
+				// Template is relative to the temp directory: the default.
+			case 'p':
+				switch {
+				case i+1 < len(opt):
+					dir = opt[i+1:]
+				case len(args) != 0:
+					dir, args = args[0], args[1:]
+				default:
+					commandError(ctx, mod, state, name, "option requires an argument -- p")
+					return 1
+				}
+				i = len(opt)
+			default:
+				commandError(ctx, mod, state, name, "usage: mktemp [-dqt] [-p dir] [template]")
+				return 1
+			}
+		}
+	}
+
+	template := "tmp.XXXXXXXXXX"
+	switch len(args) {
+	case 0:
+	case 1:
+		template = args[0]
+	default:
+		commandError(ctx, mod, state, name, "too many templates")
+		return 1
+	}
+	if !strings.Contains(template, "/") {
+		template = path.Join(dir, template)
+	}
+
+	fail := func(msg string) int {
+		if !quiet {
+			commandError(ctx, mod, state, name, msg)
+		}
+		return 1
+	}
+	rel, ok := strings.CutPrefix(path.Clean(template), TempDir+"/")
+	if !ok {
+		return fail("cannot create " + template + ": only supported in " + TempDir)
+	}
+	nx := len(rel) - len(strings.TrimRight(rel, "X"))
+	if nx < 3 {
+		return fail("too few X's in template " + template)
+	}
+
+	tmp, _, _ := state.lookupMount(TempDir)
+	for range 100 {
+		candidate := rel[:len(rel)-nx] + randomSuffix(nx)
+		var errno experimentalsys.Errno
+		if mkdir {
+			errno = tmp.fs.Mkdir(candidate, 0o700)
+		} else {
+			var f experimentalsys.File
+			f, errno = tmp.fs.OpenFile(candidate, experimentalsys.O_RDWR|experimentalsys.O_CREAT|experimentalsys.O_EXCL, 0o600)
+			if errno == 0 {
+				_ = f.Close()
+			}
+		}
+		switch errno {
+		case 0:
+			if err := guestWrite(ctx, mod, state, fdStdout, []byte(TempDir+"/"+candidate+"\n")); err != nil {
+				return 1
+			}
+			return 0
+		case experimentalsys.EEXIST:
+			continue
+		default:
+			return fail("failed to create " + TempDir + "/" + candidate + ": " + errno.Error())
+		}
+	}
+	return fail("failed to create a unique name from " + template)
+}
+
+// randomSuffix returns n random alphanumeric characters.
+func randomSuffix(n int) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b)
+}
@@ -0,0 +1,69 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestTempDir(t *testing.T) {
+	d, stdout, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	script := `test -d /tmp && echo dir
+mktemp
+mktemp -d /tmp/work.XXXX
+for f in /tmp/*; do test -d "$f" && echo "d $f" || echo "f $f"; done
+echo after redirect >/tmp/x
+echo stdout restored`
+	if _, err := d.Eval(ctx, script); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("unexpected output %q (stderr %q)", stdout.String(), stderr.String())
+	}
+	file, dir := lines[1], lines[2]
+	if !strings.HasPrefix(file, "/tmp/tmp.") || !strings.HasPrefix(dir, "/tmp/work.") {
+		t.Fatalf("unexpected mktemp paths %q, %q", file, dir)
+	}
+	if lines[3] != "f "+file || lines[4] != "d "+dir || lines[5] != "stdout restored" {
+		t.Fatalf("unexpected listing %q", lines[3:])
+	}
+	if data, err := d.ReadFile("/tmp/x"); err != nil || string(data) != "after redirect\n" {
+		t.Fatalf("ReadFile: %q, %v", data, err)
+	}
+
+	if usage := d.TempDirUsage(); usage.Files != 3 || usage.MaxBytes != DefaultTempDirMaxBytes {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// Sessions do not share /tmp.
+	other, _, _ := newTestDash(t)
+	if usage := other.TempDirUsage(); usage.Files != 0 {
+		t.Fatalf("expected an empty /tmp, got %+v", usage)
+	}
+}
+
+func TestWithoutTempDir(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	d, err := NewDash(ctx, WithRuntime(r), WithoutTempDir())
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if status, _ := d.Eval(ctx, "test -d /tmp"); status == 0 {
+		t.Fatal("expected no /tmp")
+	}
+	if usage := d.TempDirUsage(); usage != (DirUsage{}) {
+		t.Fatalf("expected zero usage, got %+v", usage)
+	}
+}
@@ -0,0 +1,61 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// ErrTerminated is wrapped by the errors returned after Terminate.
+var ErrTerminated = errors.New("dash: terminated")
+
+// TerminatedError is returned by in-flight and subsequent calls on a shell
+// stopped by Terminate.
+type TerminatedError struct {
+	// Code is the exit code passed to Terminate.
+	Code int
+}
+
+// Error implements error.
+func (e *TerminatedError) Error() string {
+	return ErrTerminated.Error() + " with exit code " + strconv.Itoa(e.Code)
+}
+
+// Unwrap returns ErrTerminated.
+func (e *TerminatedError) Unwrap() error {
+	return ErrTerminated
+}
+
+// Terminate forcibly stops the shell and releases the module.
+//
+// Terminate may be called from any goroutine, including while an Eval is
+// running. The guest is stopped at its next host function call (dash makes
+// one for nearly every command), or immediately if the runtime was created
+// with wazero.RuntimeConfig.WithCloseOnContextDone. The in-flight call and
+// every later call return a *TerminatedError; Close becomes a no-op.
+// Calling Terminate again has no effect.
+func (d *Dash) Terminate(code int) error {
+	t := &TerminatedError{Code: code}
+	if !d.state.terminated.CompareAndSwap(nil, t) {
+		return nil
+	}
+	return d.mod.CloseWithExitCode(context.Background(), uint32(code))
+}
+
+// ready returns an error if the shell cannot run commands.
+func (d *Dash) ready() error {
+	if t := d.state.terminated.Load(); t != nil {
+		return t
+	}
+	if !d.initialized {
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// checkTerminated aborts the current guest call if Terminate was called.
+func (s *dashState) checkTerminated() {
+	if t := s.terminated.Load(); t != nil {
+		panic(t)
+	}
+}
@@ -0,0 +1,48 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTerminate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("from handler", func(t *testing.T) {
+		d, _, _ := newTestDash(t)
+		d.SetExecHandler(func(ctx context.Context, argv []string) int {
+			if err := d.Terminate(3); err != nil {
+				t.Error("Terminate:", err)
+			}
+			return 0
+		})
+		_, err := d.Eval(ctx, "kill-switch; echo unreachable")
+		var term *TerminatedError
+		if !errors.As(err, &term) || term.Code != 3 {
+			t.Fatalf("expected TerminatedError with code 3, got %v", err)
+		}
+		if _, err := d.GetVar(ctx, "PWD"); !errors.Is(err, ErrTerminated) {
+			t.Fatalf("expected ErrTerminated after Terminate, got %v", err)
+		}
+		if err := d.Close(ctx); err != nil {
+			t.Fatal("Close:", err)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		d, _, _ := newTestDash(t)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_ = d.Terminate(1)
+		}()
+		_, err := d.Eval(ctx, "while :; do x=$((x+1)); done")
+		if !errors.Is(err, ErrTerminated) {
+			t.Fatalf("expected ErrTerminated, got %v", err)
+		}
+		if _, err := d.Eval(ctx, "true"); !errors.Is(err, ErrTerminated) {
+			t.Fatalf("expected ErrTerminated, got %v", err)
+		}
+	})
+}
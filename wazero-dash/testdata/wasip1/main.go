@@ -0,0 +1,32 @@
+// Command wasip1 evaluates its argument with a nested dash, for
+// TestWASIP1. It is built for GOOS=wasip1 GOARCH=wasm.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	dash "github.com/aperturerobotics/go-dash-wasi-reactor/wazero-dash"
+)
+
+func main() {
+	ctx := context.Background()
+	d, err := dash.NewDash(ctx, dash.WithStdio(os.Stdin, os.Stdout, os.Stderr))
+	if err == nil {
+		err = d.Init(ctx, nil)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(125)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	status, err := d.Eval(ctx, os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(124)
+	}
+	os.Exit(status)
+}
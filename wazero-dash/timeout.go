@@ -0,0 +1,71 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrCommandTimeout is the cause of the context passed to a command that
+// ran past its CommandTimeouts limit.
+var ErrCommandTimeout = errors.New("dash: command timed out")
+
+// CommandTimeoutStatus is the exit status of a command that timed out,
+// matching timeout(1).
+const CommandTimeoutStatus = 124
+
+// CommandTimeouts limits how long a single command dispatched to the host
+// may run: ExecHandler commands and builtin overrides. The limit is
+// independent of any deadline on the context passed to Eval.
+//
+// Zero durations disable the corresponding limit.
+type CommandTimeouts struct {
+	// Default applies to commands without an entry in Commands.
+	Default time.Duration
+	// Commands sets the limit per command name, overriding Default.
+	Commands map[string]time.Duration
+}
+
+// limit returns the timeout for the command name.
+func (t CommandTimeouts) limit(name string) time.Duration {
+	if d, ok := t.Commands[name]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// SetCommandTimeouts configures per-command timeouts for subsequent
+// commands.
+//
+// When a command exceeds its limit, the context passed to its handler is
+// cancelled with cause ErrCommandTimeout, like a job being killed. Once the
+// handler returns, the command fails with CommandTimeoutStatus and a message
+// on stderr. Handlers must honor the context; for example, ExecHandlers using
+// exec.CommandContext are stopped by it.
+func (d *Dash) SetCommandTimeouts(timeouts CommandTimeouts) {
+	d.state.commandTimeouts = timeouts
+}
+
+// CommandTimeouts returns the configured per-command timeouts.
+func (d *Dash) CommandTimeouts() CommandTimeouts {
+	return d.state.commandTimeouts
+}
+
+// runWithTimeout runs a command handler under the timeout for name,
+// reporting on stderr if it expired.
+func runWithTimeout(ctx context.Context, mod api.Module, state *dashState, name string, run func(context.Context) int) int {
+	limit := state.commandTimeouts.limit(name)
+	if limit <= 0 {
+		return run(ctx)
+	}
+	cmdCtx, cancel := context.WithTimeoutCause(ctx, limit, ErrCommandTimeout)
+	defer cancel()
+	status := run(cmdCtx)
+	if !errors.Is(context.Cause(cmdCtx), ErrCommandTimeout) {
+		return status
+	}
+	commandError(ctx, mod, state, name, "timed out after "+limit.String())
+	return CommandTimeoutStatus
+}
@@ -0,0 +1,40 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommandTimeouts(t *testing.T) {
+	d, _, stderr := newTestDash(t)
+	ctx := context.Background()
+
+	d.SetExecHandler(func(ctx context.Context, argv []string) int {
+		if argv[0] != "slow" {
+			return 0
+		}
+		<-ctx.Done()
+		if !errors.Is(context.Cause(ctx), ErrCommandTimeout) {
+			t.Errorf("unexpected cause %v", context.Cause(ctx))
+		}
+		return 1
+	})
+	d.SetCommandTimeouts(CommandTimeouts{Commands: map[string]time.Duration{"slow": 20 * time.Millisecond}})
+
+	start := time.Now()
+	status, err := d.Eval(ctx, "other && slow")
+	if err != nil {
+		t.Fatal("Eval:", err)
+	}
+	if status != CommandTimeoutStatus {
+		t.Fatalf("expected status %d, got %d", CommandTimeoutStatus, status)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("timeout took %v", elapsed)
+	}
+	if got := stderr.String(); got != "slow: timed out after 20ms\n" {
+		t.Fatalf("unexpected stderr %q", got)
+	}
+}
@@ -0,0 +1,195 @@
+package dash
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceVersion is the version of the trace format written by
+// WithTraceWriter. It changes when a field is removed or changes meaning;
+// new record types and fields may be added within a version, and readers
+// should ignore those they do not know.
+const TraceVersion = 1
+
+// ErrTraceVersion is returned by ReadTrace for a record of another
+// TraceVersion, wrapped in a TraceVersionError.
+var ErrTraceVersion = errors.New("dash: unsupported trace version")
+
+// TraceVersionError reports the version of an unsupported trace record.
+type TraceVersionError struct {
+	Line    int
+	Version int
+}
+
+// Error implements error.
+func (e *TraceVersionError) Error() string {
+	return ErrTraceVersion.Error() + ": line " + strconv.Itoa(e.Line) + ": version " + strconv.Itoa(e.Version)
+}
+
+// Unwrap returns ErrTraceVersion.
+func (e *TraceVersionError) Unwrap() error {
+	return ErrTraceVersion
+}
+
+// TraceRecord is a line of a trace: one JSON object per Event, with Type
+// set to the EventKind name. Only the fields of that kind are set, as
+// documented on EventKind.
+//
+// Commands record Argv after expansion: expansions are performed by the
+// guest, so the words a command ran with are the traced expansion.
+type TraceRecord struct {
+	// Version is TraceVersion.
+	Version int `json:"v"`
+	// Seq numbers the records of the trace from 1.
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Shell numbers the instances writing to the trace from 1, told apart
+	// when a Pool shares the writer.
+	Shell int    `json:"shell"`
+	Type  string `json:"type"`
+
+	Script string   `json:"script,omitempty"`
+	Argv   []string `json:"argv,omitempty"`
+	// Status is set for eval-finished and command records.
+	Status *int   `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// DurationNs is the duration of eval-finished and command records in
+	// nanoseconds.
+	DurationNs int64 `json:"durationNs,omitempty"`
+
+	Var  *TraceVar  `json:"var,omitempty"`
+	File *TraceFile `json:"file,omitempty"`
+
+	// Stream is "stdout" or "stderr" and Data the written text of output
+	// records, with invalid UTF-8 replaced by U+FFFD.
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+}
+
+// TraceVar is the variable change of a var-changed record.
+type TraceVar struct {
+	Name string `json:"name"`
+	// Kind is "created", "modified" or "unset".
+	Kind string `json:"kind"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// TraceFile is the file mutation of an fs-write record, named after the
+// JournalOp.
+type TraceFile struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	Target  string `json:"target,omitempty"`
+	Written int64  `json:"written,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+}
+
+// WithTraceWriter writes the Events of the Dash to w as JSON lines in the
+// TraceVersion format, for tools such as `dash-wasi trace view`. The
+// instances of a Pool share w, each record naming its Shell.
+//
+// Records are written synchronously and unbuffered, so w should buffer
+// writes itself if they are slow. Once a write fails, the trace stops.
+func WithTraceWriter(w io.Writer) Option {
+	t := &traceWriter{w: w, shells: make(map[*Dash]int)}
+	return func(o *options) {
+		o.events = joinEvents(o.events, t)
+	}
+}
+
+// traceWriter encodes Events as trace records.
+type traceWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	seq    int64
+	shells map[*Dash]int
+	err    error
+}
+
+// Event implements Events.
+func (t *traceWriter) Event(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	shell, ok := t.shells[e.Dash]
+	if !ok {
+		shell = len(t.shells) + 1
+		t.shells[e.Dash] = shell
+	}
+	if e.Kind == EventClosed {
+		delete(t.shells, e.Dash)
+	}
+	t.seq++
+	rec := newTraceRecord(e)
+	rec.Seq, rec.Shell = t.seq, shell
+	line, err := json.Marshal(rec)
+	if err != nil {
+		t.err = err
+		return
+	}
+	_, t.err = t.w.Write(append(line, '\n'))
+}
+
+// newTraceRecord returns the record of e, without Seq and Shell.
+func newTraceRecord(e Event) TraceRecord {
+	rec := TraceRecord{Version: TraceVersion, Time: e.Time, Type: e.Kind.String()}
+	switch e.Kind {
+	case EventEvalStarted:
+		rec.Script = e.Script
+	case EventEvalFinished:
+		rec.Script = e.Script
+		rec.Status = &e.Status
+		rec.DurationNs = int64(e.Duration)
+		if e.Err != nil {
+			rec.Error = e.Err.Error()
+		}
+	case EventCommand:
+		rec.Argv = e.Argv
+		rec.Status = &e.Status
+		rec.DurationNs = int64(e.Duration)
+	case EventPolicyDenied:
+		rec.Argv = e.Argv
+	case EventVarChanged:
+		rec.Var = &TraceVar{Name: e.Var.Name, Kind: e.Var.Kind.String(), Old: e.Var.Old, New: e.Var.New}
+	case EventFSWrite:
+		f := e.File
+		rec.File = &TraceFile{Op: f.Op.String(), Path: f.Path, Target: f.Target, Written: f.Written, Size: f.Size}
+	case EventOutput:
+		rec.Stream = e.Stream.String()
+		rec.Data = string(e.Data)
+	}
+	return rec
+}
+
+// ReadTrace calls fn for each record of the trace read from r, stopping at
+// the first error fn returns. Records of another TraceVersion fail with a
+// TraceVersionError.
+func ReadTrace(r io.Reader, fn func(TraceRecord) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec TraceRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("dash: trace line %d: %w", line, err)
+		}
+		if rec.Version != TraceVersion {
+			return &TraceVersionError{Line: line, Version: rec.Version}
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,92 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTraceWriter(t *testing.T) {
+	ctx := context.Background()
+	var trace bytes.Buffer
+	var kinds []EventKind
+	observer := EventsFunc(func(e Event) { kinds = append(kinds, e.Kind) })
+	d, err := NewDash(ctx, WithTraceWriter(&trace), WithEvents(observer), WithStdio(nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	d.SetExecHandler(func(ctx context.Context, argv []string) int { return 0 })
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, _, err := d.EvalDiff(ctx, "X=a; greet $X; echo hi; true >/tmp/f"); err != nil {
+		t.Fatal("EvalDiff:", err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal("Close:", err)
+	}
+
+	var recs []TraceRecord
+	if err := ReadTrace(&trace, func(rec TraceRecord) error {
+		recs = append(recs, rec)
+		return nil
+	}); err != nil {
+		t.Fatal("ReadTrace:", err)
+	}
+	// WithEvents observers see the same events.
+	if len(recs) != len(kinds) {
+		t.Fatalf("got %d records for %d events", len(recs), len(kinds))
+	}
+	byType := make(map[string]TraceRecord)
+	for i, rec := range recs {
+		if rec.Seq != int64(i+1) || rec.Shell != 1 || rec.Time.IsZero() || rec.Type != kinds[i].String() {
+			t.Fatalf("unexpected record %d: %+v", i, rec)
+		}
+		if _, ok := byType[rec.Type]; !ok {
+			byType[rec.Type] = rec
+		}
+	}
+	if recs[0].Type != "created" || recs[len(recs)-1].Type != "closed" {
+		t.Fatalf("expected created first and closed last, got %+v", recs)
+	}
+	if rec := byType["command"]; !slices.Equal(rec.Argv, []string{"greet", "a"}) || rec.Status == nil || *rec.Status != 0 {
+		t.Fatalf("unexpected command record: %+v", rec)
+	}
+	if rec := byType["output"]; rec.Stream != "stdout" || rec.Data != "hi\n" {
+		t.Fatalf("unexpected output record: %+v", rec)
+	}
+	if rec := byType["fs-write"]; rec.File == nil || rec.File.Op != "create" || rec.File.Path != "/tmp/f" {
+		t.Fatalf("unexpected fs-write record: %+v", rec)
+	}
+	if rec := byType["var-changed"]; rec.Var == nil || *rec.Var != (TraceVar{Name: "X", Kind: "created", New: "a"}) {
+		t.Fatalf("unexpected var-changed record: %+v", rec)
+	}
+	if rec := byType["eval-finished"]; rec.Script != "X=a; greet $X; echo hi; true >/tmp/f" || rec.Status == nil || rec.DurationNs <= 0 {
+		t.Fatalf("unexpected eval-finished record: %+v", rec)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	trace := `{"v":1,"seq":1,"type":"created","future":true}
+
+{"v":2,"seq":2,"type":"closed"}
+`
+	var types []string
+	err := ReadTrace(strings.NewReader(trace), func(rec TraceRecord) error {
+		types = append(types, rec.Type)
+		return nil
+	})
+	var verr *TraceVersionError
+	if !errors.As(err, &verr) || !errors.Is(err, ErrTraceVersion) || verr.Line != 3 || verr.Version != 2 {
+		t.Fatalf("expected a version error on line 3, got %v", err)
+	}
+	if !slices.Equal(types, []string{"created"}) {
+		t.Fatalf("unexpected records %q", types)
+	}
+	if err := ReadTrace(strings.NewReader("{"), func(TraceRecord) error { return nil }); err == nil || !strings.HasPrefix(err.Error(), "dash: trace line 1:") {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+}
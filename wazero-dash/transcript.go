@@ -0,0 +1,134 @@
+package dash
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Stream identifies an output stream of the shell.
+type Stream int
+
+// Output streams. The values match the guest file descriptors.
+const (
+	// StreamStdout is the standard output stream.
+	StreamStdout Stream = fdStdout
+	// StreamStderr is the standard error stream.
+	StreamStderr Stream = fdStderr
+)
+
+// String returns the stream name.
+func (s Stream) String() string {
+	switch s {
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	default:
+		return "unknown"
+	}
+}
+
+// Chunk is a contiguous piece of output written to one stream.
+type Chunk struct {
+	Stream Stream
+	Data   []byte
+}
+
+// Transcript records stdout and stderr as a single ordered stream.
+//
+// Capturing the two streams into separate buffers loses their relative
+// order. Wire both writers of a Transcript into the module config to keep
+// what a user at a terminal would have seen:
+//
+//	var tr dash.Transcript
+//	config := wazero.NewModuleConfig().
+//		WithStdout(tr.Stdout()).
+//		WithStderr(tr.Stderr())
+//
+// Consecutive writes to the same stream are merged into one chunk.
+// Transcript is safe for concurrent use. The zero value is ready to use.
+type Transcript struct {
+	mu     sync.Mutex
+	chunks []Chunk
+}
+
+// Stdout returns a writer recording chunks tagged StreamStdout.
+func (t *Transcript) Stdout() io.Writer {
+	return &transcriptWriter{t: t, stream: StreamStdout}
+}
+
+// Stderr returns a writer recording chunks tagged StreamStderr.
+func (t *Transcript) Stderr() io.Writer {
+	return &transcriptWriter{t: t, stream: StreamStderr}
+}
+
+// Chunks returns a copy of the recorded chunks in write order.
+func (t *Transcript) Chunks() []Chunk {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Chunk, len(t.chunks))
+	for i, c := range t.chunks {
+		out[i] = Chunk{Stream: c.Stream, Data: bytes.Clone(c.Data)}
+	}
+	return out
+}
+
+// Bytes returns the combined output of both streams in write order.
+func (t *Transcript) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var buf bytes.Buffer
+	for _, c := range t.chunks {
+		buf.Write(c.Data)
+	}
+	return buf.Bytes()
+}
+
+// String returns the combined output of both streams in write order.
+func (t *Transcript) String() string {
+	return string(t.Bytes())
+}
+
+// Stream returns the output recorded on one stream.
+func (t *Transcript) Stream(s Stream) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var buf bytes.Buffer
+	for _, c := range t.chunks {
+		if c.Stream == s {
+			buf.Write(c.Data)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Reset discards all recorded output.
+func (t *Transcript) Reset() {
+	t.mu.Lock()
+	t.chunks = nil
+	t.mu.Unlock()
+}
+
+// write records p on stream s.
+func (t *Transcript) write(s Stream, p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.chunks); n != 0 && t.chunks[n-1].Stream == s {
+		t.chunks[n-1].Data = append(t.chunks[n-1].Data, p...)
+		return
+	}
+	t.chunks = append(t.chunks, Chunk{Stream: s, Data: bytes.Clone(p)})
+}
+
+// transcriptWriter writes to one stream of a Transcript.
+type transcriptWriter struct {
+	t      *Transcript
+	stream Stream
+}
+
+// Write implements io.Writer.
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.t.write(w.stream, p)
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+package dash
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func TestTranscript(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	var tr Transcript
+	config := wazero.NewModuleConfig().
+		WithStdout(tr.Stdout()).
+		WithStderr(tr.Stderr())
+
+	d, err := NewDash(ctx, WithRuntime(r), WithModuleConfig(config))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	for _, cmd := range []string{"echo one", "cd /nonexistent", "echo two; echo three"} {
+		if _, err := d.Eval(ctx, cmd); err != nil {
+			t.Fatal("Eval:", err)
+		}
+	}
+
+	chunks := tr.Chunks()
+	want := []Stream{StreamStdout, StreamStderr, StreamStdout}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d: %q", len(want), len(chunks), tr.String())
+	}
+	for i, c := range chunks {
+		if c.Stream != want[i] {
+			t.Fatalf("chunk %d: expected %v, got %v (%q)", i, want[i], c.Stream, c.Data)
+		}
+	}
+	if got := string(tr.Stream(StreamStdout)); got != "one\ntwo\nthree\n" {
+		t.Fatalf("unexpected stdout %q", got)
+	}
+	if got := string(chunks[1].Data); got != "dash: 1: cd: can't cd to /nonexistent\n" {
+		t.Fatalf("unexpected stderr chunk %q", got)
+	}
+}
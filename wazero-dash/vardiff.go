@@ -0,0 +1,132 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// VarChangeKind is how an evaluation changed a shell variable.
+type VarChangeKind int
+
+// Kinds of variable changes.
+const (
+	// VarCreated marks a variable that was not set before.
+	VarCreated VarChangeKind = iota
+	// VarModified marks a variable whose value changed.
+	VarModified
+	// VarUnset marks a variable that is no longer set.
+	VarUnset
+)
+
+// String returns the kind name.
+func (k VarChangeKind) String() string {
+	switch k {
+	case VarCreated:
+		return "created"
+	case VarModified:
+		return "modified"
+	case VarUnset:
+		return "unset"
+	default:
+		return "unknown"
+	}
+}
+
+// VarChange describes a shell variable changed by an evaluation.
+type VarChange struct {
+	Name string
+	Kind VarChangeKind
+	// Old is the value before the change; empty for VarCreated.
+	Old string
+	// New is the value after the change; empty for VarUnset.
+	New string
+}
+
+// DiffVars returns the changes from the variables in before to those in
+// after, sorted by name.
+func DiffVars(before, after map[string]string) []VarChange {
+	var changes []VarChange
+	for name, old := range before {
+		switch value, ok := after[name]; {
+		case !ok:
+			changes = append(changes, VarChange{Name: name, Kind: VarUnset, Old: old})
+		case value != old:
+			changes = append(changes, VarChange{Name: name, Kind: VarModified, Old: old, New: value})
+		}
+	}
+	for name, value := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, VarChange{Name: name, Kind: VarCreated, New: value})
+		}
+	}
+	slices.SortFunc(changes, func(a, b VarChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return changes
+}
+
+// EvalDiff evaluates cmd like Eval and also reports the shell variables it
+// created, modified or unset, exported or not.
+//
+// The variables are listed with `set` before and after the evaluation, so
+// changes undone within cmd are not reported.
+func (d *Dash) EvalDiff(ctx context.Context, cmd string) (int, []VarChange, error) {
+	before, err := d.shellVars(ctx)
+	if err != nil {
+		return -1, nil, err
+	}
+	status, evalErr := d.Eval(ctx, cmd)
+	if evalErr != nil && status < 0 {
+		return status, nil, evalErr
+	}
+	after, err := d.shellVars(ctx)
+	if err != nil {
+		return status, nil, err
+	}
+	changes := DiffVars(before, after)
+	for _, change := range changes {
+		d.state.publish(Event{Kind: EventVarChanged, Var: change})
+	}
+	return status, changes, evalErr
+}
+
+// shellVars returns all set shell variables.
+func (d *Dash) shellVars(ctx context.Context) (map[string]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.dashListVars != nil {
+		return d.listVars(ctx, 0)
+	}
+	out, err := d.evalQuiet(ctx, "set")
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseVarList(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("parse set: %w", err)
+	}
+	return vars, nil
+}
+
+// parseVarList parses the output of `set`: one `NAME=value` entry per
+// variable, quoted as for parseExportList.
+func parseVarList(out string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for out != "" {
+		name, rest, ok := strings.Cut(out, "=")
+		if !ok || name == "" || strings.Contains(name, "\n") {
+			return nil, errors.New("unexpected line: " + firstLine(out))
+		}
+		value, tail, err := unquoteWord(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		vars[name] = value
+		out = strings.TrimPrefix(tail, "\n")
+	}
+	return vars, nil
+}
@@ -0,0 +1,36 @@
+package dash
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestEvalDiff(t *testing.T) {
+	d, _, _ := newTestDash(t)
+	ctx := context.Background()
+
+	if _, err := d.Eval(ctx, "A=1; B=2; C=3"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	status, changes, err := d.EvalDiff(ctx, "A=changed; unset B; C=tmp; C=3; NEW=\"two\nlines\"; false")
+	if err != nil {
+		t.Fatal("EvalDiff:", err)
+	}
+	if status != 1 {
+		t.Fatalf("expected status 1, got %d", status)
+	}
+	want := []VarChange{
+		{Name: "A", Kind: VarModified, Old: "1", New: "changed"},
+		{Name: "B", Kind: VarUnset, Old: "2"},
+		{Name: "NEW", Kind: VarCreated, New: "two\nlines"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("expected %+v, got %+v", want, changes)
+	}
+
+	// Listing variables leaves $? alone.
+	if status, _ := d.GetExitStatus(ctx); status != 1 {
+		t.Fatalf("expected exit status 1, got %d", status)
+	}
+}
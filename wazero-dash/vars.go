@@ -0,0 +1,155 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrVarReadOnly is wrapped by the VarError returned when changing a
+// read-only shell variable.
+var ErrVarReadOnly = errors.New("dash: variable is read-only")
+
+// ErrInvalidVarName is wrapped by the VarError returned for a name that is
+// not a valid shell variable name.
+var ErrInvalidVarName = errors.New("dash: invalid variable name")
+
+// VarError reports a failed change of the shell variable Name.
+type VarError struct {
+	// Op is "unset", "export" or "readonly".
+	Op   string
+	Name string
+	// Err is ErrVarReadOnly, ErrInvalidVarName or the shell's message.
+	Err error
+}
+
+// Error implements error.
+func (e *VarError) Error() string {
+	return e.Err.Error() + ": " + e.Op + " " + e.Name
+}
+
+// Unwrap returns Err.
+func (e *VarError) Unwrap() error {
+	return e.Err
+}
+
+// UnsetVar unsets a shell variable, for example to remove a secret once a
+// script used it. Unsetting a variable that is not set succeeds; a
+// read-only one fails with ErrVarReadOnly.
+func (d *Dash) UnsetVar(ctx context.Context, name string) error {
+	var old string
+	var wasSet bool
+	if d.state.events != nil {
+		old, wasSet, _ = d.LookupVar(ctx, name)
+	}
+	if err := d.changeVar(ctx, "unset", d.dashUnsetVar, name); err != nil {
+		return err
+	}
+	if wasSet {
+		d.state.publish(Event{Kind: EventVarChanged, Var: VarChange{Name: name, Kind: VarUnset, Old: old}})
+	}
+	return nil
+}
+
+// ExportVar marks a shell variable for export to the environment of the
+// commands the shell runs, as `export name`. A variable that is not set is
+// exported once it is assigned.
+func (d *Dash) ExportVar(ctx context.Context, name string) error {
+	return d.changeVar(ctx, "export", d.dashExportVar, name)
+}
+
+// SetVarReadOnly sets a shell variable and makes it read-only, as
+// `readonly name=value`, so scripts cannot change or unset it. Changing a
+// variable that is already read-only fails with ErrVarReadOnly.
+func (d *Dash) SetVarReadOnly(ctx context.Context, name, value string) error {
+	var old string
+	var wasSet bool
+	if d.state.events != nil {
+		old, wasSet, _ = d.LookupVar(ctx, name)
+	}
+	if err := d.changeVar(ctx, "readonly", d.dashSetVarRO, name, value); err != nil {
+		return err
+	}
+	d.state.publishVarSet(name, old, wasSet, value)
+	return nil
+}
+
+// publishVarSet publishes the change of the variable name, set to old if
+// wasSet, to value made by the host, if any.
+func (s *dashState) publishVarSet(name, old string, wasSet bool, value string) {
+	if s.events == nil || wasSet && old == value {
+		return
+	}
+	change := VarChange{Name: name, Kind: VarModified, Old: old, New: value}
+	if !wasSet {
+		change.Kind = VarCreated
+	}
+	s.publish(Event{Kind: EventVarChanged, Var: change})
+}
+
+// changeVar performs the variable change op on name with the export fn,
+// passing value if given, or with the builtin op if the module lacks the
+// export.
+func (d *Dash) changeVar(ctx context.Context, op string, fn api.Function, name string, value ...string) error {
+	if err := d.ready(); err != nil {
+		return err
+	}
+	if !isShellName(name) {
+		return &VarError{Op: op, Name: name, Err: ErrInvalidVarName}
+	}
+	if fn == nil {
+		return d.changeVarBuiltin(ctx, op, name, value...)
+	}
+
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	var params []uint64
+	for _, s := range append([]string{name}, value...) {
+		ptr, err := d.allocString(ctx, s)
+		if err != nil {
+			return err
+		}
+		defer d.freePtr(ctx, ptr)
+		params = append(params, uint64(ptr))
+	}
+	results, err := d.call(ctx, fn, params...)
+	if err != nil {
+		return err
+	}
+	if int32(results[0]) != 0 {
+		if op == "export" {
+			return &VarError{Op: op, Name: name, Err: errors.New("dash_exportvar failed")}
+		}
+		return &VarError{Op: op, Name: name, Err: ErrVarReadOnly}
+	}
+	return nil
+}
+
+// changeVarBuiltin performs the variable change op with the special
+// builtin of the same name, which scripts cannot redefine, passing value if
+// given, leaving $? alone and keeping its error message off stderr.
+func (d *Dash) changeVarBuiltin(ctx context.Context, op, name string, value ...string) error {
+	word := name
+	if len(value) > 0 {
+		word += "=" + Quote(value[0])
+	}
+	var status int
+	msg, err := d.captureStderr(func() error {
+		var err error
+		status, _, err = d.evalQuietStatus(ctx, op+" "+word)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if status == 0 {
+		return nil
+	}
+	if strings.Contains(string(msg), "read only") {
+		return &VarError{Op: op, Name: name, Err: ErrVarReadOnly}
+	}
+	return &VarError{Op: op, Name: name, Err: errors.New(strings.TrimSpace(string(msg)))}
+}
@@ -0,0 +1,129 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestVarAttributes(t *testing.T) {
+	ctx := context.Background()
+	var changes []VarChange
+	d, err := NewDash(ctx, WithEvents(EventsFunc(func(e Event) {
+		if e.Kind == EventVarChanged {
+			changes = append(changes, e.Var)
+		}
+	})))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	eval := func(script string) string {
+		t.Helper()
+		stdout, stderr, _, err := d.EvalCapture(ctx, script)
+		if err != nil || len(stderr) != 0 {
+			t.Fatalf("EvalCapture(%q): %v %q", script, err, stderr)
+		}
+		return string(stdout)
+	}
+
+	if err := d.SetVar(ctx, "TOKEN", "secret"); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+	if err := d.ExportVar(ctx, "TOKEN"); err != nil {
+		t.Fatal("ExportVar:", err)
+	}
+	if env, _ := d.ExportedVars(ctx); env["TOKEN"] != "secret" {
+		t.Fatalf("expected TOKEN exported, got %q", env)
+	}
+	eval("false")
+	if err := d.UnsetVar(ctx, "TOKEN"); err != nil {
+		t.Fatal("UnsetVar:", err)
+	}
+	if got := eval("echo $? ${TOKEN-unset}"); got != "1 unset\n" {
+		t.Fatalf("expected $? kept and TOKEN unset, got %q", got)
+	}
+	if err := d.UnsetVar(ctx, "TOKEN"); err != nil {
+		t.Fatal("UnsetVar of an unset variable:", err)
+	}
+	if err := d.SetVar(ctx, "EMPTY", ""); err != nil {
+		t.Fatal("SetVar:", err)
+	}
+
+	if err := d.SetVarReadOnly(ctx, "MODE", "it's fixed"); err != nil {
+		t.Fatal("SetVarReadOnly:", err)
+	}
+	if got := eval("echo \"$MODE\"; readonly -p"); got != "it's fixed\nreadonly MODE='it'\"'\"'s fixed'\n" {
+		t.Fatalf("unexpected read-only variable: %q", got)
+	}
+	for name, err := range map[string]error{
+		"unset":    d.UnsetVar(ctx, "MODE"),
+		"readonly": d.SetVarReadOnly(ctx, "MODE", "other"),
+	} {
+		var verr *VarError
+		if !errors.As(err, &verr) || !errors.Is(err, ErrVarReadOnly) || verr.Op != name || verr.Name != "MODE" {
+			t.Fatalf("%s: expected a read-only error, got %v", name, err)
+		}
+	}
+	// Functions shadowing regular builtins do not hide the error.
+	eval("echo() { :; }; printf() { :; }; command() { :; }")
+	if err := d.UnsetVar(ctx, "MODE"); !errors.Is(err, ErrVarReadOnly) {
+		t.Fatalf("UnsetVar with echo redefined: expected a read-only error, got %v", err)
+	}
+	eval("unset -f echo printf command")
+	if err := d.ExportVar(ctx, "MODE"); err != nil {
+		t.Fatal("ExportVar of a read-only variable:", err)
+	}
+	for _, name := range []string{"", "1X", "A-B", "X=1"} {
+		if err := d.UnsetVar(ctx, name); !errors.Is(err, ErrInvalidVarName) {
+			t.Fatalf("UnsetVar(%q): expected an invalid name error, got %v", name, err)
+		}
+	}
+
+	want := []VarChange{
+		{Name: "TOKEN", Kind: VarCreated, New: "secret"},
+		{Name: "TOKEN", Kind: VarUnset, Old: "secret"},
+		{Name: "EMPTY", Kind: VarCreated},
+		{Name: "MODE", Kind: VarCreated, New: "it's fixed"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestLookupVar(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDash(ctx)
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+	if _, err := d.Eval(ctx, "EMPTY=; FULL=x; export DECLARED; GONE=1; unset GONE"); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	for name, want := range map[string]struct {
+		value string
+		ok    bool
+	}{
+		"EMPTY":    {"", true},
+		"FULL":     {"x", true},
+		"DECLARED": {"", false},
+		"GONE":     {"", false},
+		"NEVER":    {"", false},
+	} {
+		value, ok, err := d.LookupVar(ctx, name)
+		if err != nil {
+			t.Fatal("LookupVar:", err)
+		}
+		if value != want.value || ok != want.ok {
+			t.Fatalf("LookupVar(%q) = %q, %v, want %q, %v", name, value, ok, want.value, want.ok)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+	"github.com/tetratelabs/wazero"
+)
+
+// ReactorReport is the result of VerifyReactor.
+type ReactorReport struct {
+	// Version and Commit are recorded in the module's
+	// dashwasi.VersionSection, or empty if it has none.
+	Version string
+	Commit  string
+	// SHA256 is the hex SHA-256 of the module.
+	SHA256 string
+	// Drift lists the differences from the version recorded in the
+	// dashwasi package.
+	Drift []string
+	// Failures lists the smoke checks the module failed.
+	Failures []string
+}
+
+// OK reports whether the module matches the recorded version and passed
+// the smoke checks.
+func (r *ReactorReport) OK() bool {
+	return len(r.Drift) == 0 && len(r.Failures) == 0
+}
+
+// reactorSmokeCheck is a script run by VerifyReactor, with the stdout and
+// exit status it must produce.
+type reactorSmokeCheck struct {
+	name, script, stdout string
+	status               int
+}
+
+// reactorSmokeChecks is a subset of the conformance corpus, covering each
+// of its areas.
+var reactorSmokeChecks = []reactorSmokeCheck{
+	{"param expansion", `x=abc.tar.gz; echo ${x%%.*} ${x#*.} ${#x} ${unset:-default}`, "abc tar.gz 10 default\n", 0},
+	{"quoting", `printf '%s|' 'a b' "c\$d" e\ f`, "a b|c$d|e f|", 0},
+	{"field splitting", `IFS=:; v=a:b::c; set -- $v; echo $#`, "4\n", 0},
+	{"arithmetic", `echo $((7 * 6)) $((1 << 4)) $((17 % 5))`, "42 16 2\n", 0},
+	{"control", `f() { return $1; }; f 3; echo $?; for i in 1 2 3; do [ $i = 2 ] && continue; echo $i; done`, "3\n1\n3\n", 0},
+	{"traps", `trap 'echo bye' EXIT; trap`, "trap -- 'echo bye' EXIT\n", 0},
+	{"exit status", `false`, "", 1},
+}
+
+// VerifyReactor checks the reactor module wasm, such as dashwasi.DashWASM
+// after regenerating it, against the Version, Commit and SHA256 recorded
+// in the dashwasi package, and runs a smoke subset of the conformance
+// corpus against it. Differences and failed checks are reported rather
+// than returned as errors, which are only returned if wasm cannot be
+// compiled.
+func VerifyReactor(ctx context.Context, wasm []byte) (*ReactorReport, error) {
+	sum := sha256.Sum256(wasm)
+	report := &ReactorReport{SHA256: hex.EncodeToString(sum[:])}
+	version, commit, ok := dashwasi.ReadVersionSection(wasm)
+	switch {
+	case !ok:
+		report.Drift = append(report.Drift, "no "+dashwasi.VersionSection+" section records the version")
+	default:
+		report.Version, report.Commit = version, commit
+		if version != dashwasi.Version {
+			report.Drift = append(report.Drift, "version "+version+" differs from "+dashwasi.Version)
+		}
+		if commit != dashwasi.Commit {
+			report.Drift = append(report.Drift, "commit "+commit+" differs from "+dashwasi.Commit)
+		}
+	}
+	if report.SHA256 != dashwasi.SHA256 {
+		report.Drift = append(report.Drift, "sha256 "+report.SHA256+" differs from "+dashwasi.SHA256)
+	}
+
+	// Each check gets a runtime of its own, sharing the compiled code.
+	cache := wazero.NewCompilationCache()
+	defer cache.Close(ctx)
+	config := wazero.NewRuntimeConfig().WithCompilationCache(cache)
+	r := wazero.NewRuntimeWithConfig(ctx, config)
+	_, err := r.CompileModule(ctx, wasm)
+	_ = r.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range reactorSmokeChecks {
+		if msg := runSmokeCheck(ctx, config, wasm, check); msg != "" {
+			report.Failures = append(report.Failures, check.name+": "+msg)
+		}
+	}
+	return report, nil
+}
+
+// runSmokeCheck runs check in a fresh shell running wasm and describes how
+// it failed, or returns "" if it passed.
+func runSmokeCheck(ctx context.Context, config wazero.RuntimeConfig, wasm []byte, check reactorSmokeCheck) string {
+	r := wazero.NewRuntimeWithConfig(ctx, config)
+	defer r.Close(ctx)
+	compiled, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		return err.Error()
+	}
+	d, err := NewDash(ctx, WithRuntime(r), WithCompiledModule(compiled))
+	if err != nil {
+		return err.Error()
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		return err.Error()
+	}
+	return check.run(ctx, d)
+}
+
+// run runs check in d and describes how it failed, or returns "" if it
+// passed.
+func (check reactorSmokeCheck) run(ctx context.Context, d *Dash) string {
+	stdout, _, status, err := d.EvalCapture(ctx, check.script)
+	switch {
+	case err != nil:
+		return err.Error()
+	case string(stdout) != check.stdout:
+		return "got " + strconv.Quote(string(stdout)) + ", want " + strconv.Quote(check.stdout)
+	case status != check.status:
+		return "exit status " + strconv.Itoa(status) + ", want " + strconv.Itoa(check.status)
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package dash
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	dashwasi "github.com/aperturerobotics/go-dash-wasi-reactor"
+)
+
+func TestVerifyReactor(t *testing.T) {
+	ctx := context.Background()
+	report, err := VerifyReactor(ctx, dashwasi.DashWASM)
+	if err != nil {
+		t.Fatal("VerifyReactor:", err)
+	}
+	if !report.OK() || report.Version != dashwasi.Version || report.Commit != dashwasi.Commit {
+		t.Fatalf("expected the embedded reactor to verify, got %+v", report)
+	}
+
+	// An extra custom section changes the module but not its behavior.
+	modified := append([]byte(nil), dashwasi.DashWASM...)
+	modified = append(modified, 0, 3, 1, 'x', 'y')
+	report, err = VerifyReactor(ctx, modified)
+	if err != nil {
+		t.Fatal("VerifyReactor:", err)
+	}
+	if len(report.Drift) != 1 || !strings.HasPrefix(report.Drift[0], "sha256 ") || len(report.Failures) != 0 {
+		t.Fatalf("expected only the SHA-256 to drift, got %+v", report)
+	}
+
+	report, err = VerifyReactor(ctx, []byte("\x00asm\x01\x00\x00\x00"))
+	if err != nil {
+		t.Fatal("VerifyReactor:", err)
+	}
+	if len(report.Drift) != 2 || len(report.Failures) != len(reactorSmokeChecks) {
+		t.Fatalf("expected an empty module to drift and fail every check, got %+v", report)
+	}
+}
@@ -0,0 +1,76 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// TestWASIP1 builds testdata/wasip1 for wasip1 and runs it in wazero,
+// nesting the dash reactor in the interpreter inside the Go guest.
+func TestWASIP1(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and compiles a Go program for wasip1")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	wasmPath := filepath.Join(t.TempDir(), "guest.wasm")
+	build := exec.Command(goCmd, "build", "-o", wasmPath, "./testdata/wasip1")
+	build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	wasm, err := os.ReadFile(wasmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	compiled, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		t.Fatal("CompileModule:", err)
+	}
+
+	for _, tc := range []struct {
+		name, script, stdin string
+		status              uint32
+		stdout, stderr      string
+	}{
+		{"stdio", `read line; echo "got $line"; printf '%d\n' x`, "input\n", 1, "got input\n0\n", "expected numeric value"},
+		{"timeout", "while :; do :; done", "", 124, "", "context deadline exceeded"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			config := wazero.NewModuleConfig().
+				WithArgs("wasip1", tc.script).
+				WithStdin(strings.NewReader(tc.stdin)).
+				WithStdout(&stdout).
+				WithStderr(&stderr).
+				WithSysWalltime().
+				WithSysNanotime().
+				WithSysNanosleep()
+			_, err := r.InstantiateModule(ctx, compiled, config)
+			var exitErr *sys.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != tc.status {
+				t.Fatalf("expected exit status %d, got %v; stderr: %s", tc.status, err, &stderr)
+			}
+			if stdout.String() != tc.stdout || !strings.Contains(stderr.String(), tc.stderr) {
+				t.Fatalf("expected %q and %q on stderr, got %q and %q", tc.stdout, tc.stderr, &stdout, &stderr)
+			}
+		})
+	}
+}
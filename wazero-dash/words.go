@@ -0,0 +1,407 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// errHereDocument is returned by SplitWords for a here-document body.
+var errHereDocument = errors.New("dash: SplitWords: here-document bodies are not supported")
+
+// shellOperators are the operators of the shell grammar, longest first.
+var shellOperators = []string{"<<-", "&&", "||", ";;", "<<", ">>", "<&", ">&", "<>", ">|", "&", "|", ";", "(", ")", "<", ">", "\n"}
+
+// SplitWords splits line into tokens, for hosts inspecting or rewriting
+// commands before evaluating them. It is a lexer written in Go after dash's
+// rules, which runs without a shell; Dash.SplitWords has dash's lexer
+// split the line. Words
+// are kept as written, with their quotes, escapes and expansions, so each
+// can be passed to Expand; operators such as "|", "&&", ";" and newlines
+// are tokens of their own, with the file descriptor of a redirection, as
+// in "2>". Comments and line continuations are dropped. Joining the tokens
+// with spaces gives a line that evaluates like line does.
+//
+// Nothing is evaluated and aliases are not expanded. A quote, expansion or
+// substitution left open fails with a *SyntaxError worded as dash's.
+// Here-document bodies are not supported.
+func SplitWords(ctx context.Context, line string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	l := &wordLexer{src: line}
+	var tokens []string
+	heredoc := false
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tok.text == "":
+			return tokens, nil
+		case tok.text == "\n" && heredoc:
+			return nil, errHereDocument
+		case isHereDocOp(tok):
+			heredoc = true
+		}
+		tokens = append(tokens, tok.text)
+	}
+}
+
+// SplitWords splits line into tokens as the SplitWords function does, with
+// dash's lexer finding where each token ends through the dash_split_words
+// export. Line continuations are dropped from the tokens as written.
+// Reactor builds without the export split the line with the SplitWords
+// function.
+func (d *Dash) SplitWords(ctx context.Context, line string) ([]string, error) {
+	if err := d.ready(); err != nil {
+		return nil, err
+	}
+	if d.dashSplitWords == nil {
+		return SplitWords(ctx, line)
+	}
+	ends, err := d.tokenEnds(ctx, line)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	heredoc := false
+	l := &wordLexer{src: line}
+	for _, end := range ends {
+		l.skipBlanks()
+		tok := wordToken{pos: l.pos, op: end&1 != 0}
+		end >>= 1
+		if end < l.pos || end > len(line) {
+			return nil, errors.New("dash_split_words: token out of range")
+		}
+		tok.text = line[l.pos:end]
+		if tok.op {
+			tok.text = strings.ReplaceAll(tok.text, "\\\n", "")
+		} else {
+			// Drop line continuations as the SplitWords function does.
+			w := &wordLexer{src: tok.text}
+			if text, err := w.word(); err == nil && w.pos == len(tok.text) {
+				tok.text = text
+			}
+		}
+		l.pos = end
+		switch {
+		case tok.text == "\n" && heredoc:
+			return nil, errHereDocument
+		case isHereDocOp(tok):
+			heredoc = true
+		}
+		tokens = append(tokens, tok.text)
+	}
+	return tokens, nil
+}
+
+// tokenEnds calls dash_split_words on line and returns the token ends it
+// lists, each the offset past a token shifted left by one, with the low bit
+// set for operators.
+func (d *Dash) tokenEnds(ctx context.Context, line string) ([]int, error) {
+	ctx = d.callCtx(ctx)
+	defer d.scopeCheckpoints()()
+
+	if _, err := d.takeSyntaxError(ctx, line); err != nil {
+		return nil, err
+	}
+	srcPtr, err := d.allocString(ctx, line)
+	if err != nil {
+		return nil, err
+	}
+	defer d.freePtr(ctx, srcPtr)
+	var ptr uint32
+	stderr, err := d.captureStderr(func() error {
+		results, err := d.call(ctx, d.dashSplitWords, uint64(srcPtr), uint64(len(line)))
+		if err != nil {
+			return err
+		}
+		ptr = uint32(results[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ptr == 0 {
+		e, err := d.takeSyntaxError(ctx, line)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			e = parseSyntaxError(stderr, line)
+		}
+		if e == nil {
+			return nil, errors.New("dash_split_words failed")
+		}
+		return nil, e
+	}
+	defer d.freePtr(ctx, ptr)
+
+	mem := d.mod.Memory()
+	var ends []int
+	for off := ptr; ; off += 4 {
+		v, ok := mem.ReadUint32Le(off)
+		if !ok {
+			return nil, errors.New("dash_split_words: list out of range")
+		}
+		if int32(v) == -1 {
+			return ends, nil
+		}
+		ends = append(ends, int(v))
+	}
+}
+
+// wordToken is a token of a shell line: a word, or an operator with op set.
+type wordToken struct {
+	text string
+	op   bool
+	// pos is the offset of the token in the line.
+	pos int
+}
+
+// isHereDocOp reports whether tok is a here-document operator, with or
+// without an IO number.
+func isHereDocOp(tok wordToken) bool {
+	return tok.op && strings.Contains(tok.text, "<<")
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// wordLexer scans the tokens of a shell line for SplitWords and Parse.
+type wordLexer struct {
+	src string
+	pos int
+	b   strings.Builder
+	// heredocs are the here-documents whose bodies follow the next
+	// newline, read into their Redirect by next.
+	heredocs []*hereDoc
+}
+
+// hereDoc is a here-document waiting for its body.
+type hereDoc struct {
+	delim string
+	strip bool
+	body  *string
+}
+
+// next consumes and returns the next token, or one with empty text at the
+// end of the line. The bodies of the pending here-documents are read after
+// a newline token.
+func (l *wordLexer) next() (wordToken, error) {
+	l.skipBlanks()
+	tok := wordToken{pos: l.pos}
+	if l.pos == len(l.src) {
+		return tok, nil
+	}
+	if tok.text = l.operator(); tok.text != "" {
+		tok.op = true
+		if tok.text == "\n" {
+			l.readHereDocs()
+		}
+		return tok, nil
+	}
+	word, err := l.word()
+	if err != nil {
+		return tok, err
+	}
+	tok.text = word
+	if isDigits(word) && l.pos < len(l.src) && (l.src[l.pos] == '<' || l.src[l.pos] == '>') {
+		// An IO number belongs to the redirection.
+		tok.text += l.operator()
+		tok.op = true
+	}
+	return tok, nil
+}
+
+// skipBlanks consumes the blanks, line continuations and comment at the
+// position, which separate tokens.
+func (l *wordLexer) skipBlanks() {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t' || strings.HasPrefix(l.src[l.pos:], "\\\n")) {
+		if l.src[l.pos] == '\\' {
+			l.pos++
+		}
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '#' {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.src) - l.pos
+		}
+		l.pos += end
+	}
+}
+
+// readHereDocs reads the bodies of the pending here-documents, which
+// start at the position, each up to its delimiter line or the end.
+func (l *wordLexer) readHereDocs() {
+	for _, h := range l.heredocs {
+		var body strings.Builder
+		for l.pos < len(l.src) {
+			line, rest, _ := strings.Cut(l.src[l.pos:], "\n")
+			l.pos = len(l.src) - len(rest)
+			if h.strip {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == h.delim {
+				break
+			}
+			body.WriteString(line + "\n")
+		}
+		*h.body = body.String()
+	}
+	l.heredocs = nil
+}
+
+// operator consumes and returns the operator at the position, or "".
+func (l *wordLexer) operator() string {
+	for _, op := range shellOperators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// word consumes and returns the word at the position.
+func (l *wordLexer) word() (string, error) {
+	l.b.Reset()
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || strings.IndexByte(";&|()<>\n", c) >= 0 {
+			break
+		}
+		if err := l.unit(); err != nil {
+			return "", err
+		}
+	}
+	return l.b.String(), nil
+}
+
+// unit consumes a character, quoted string, expansion or substitution,
+// appending it to the word.
+func (l *wordLexer) unit() error {
+	c := l.src[l.pos]
+	switch {
+	case c == '\\':
+		l.escape()
+	case c == '\'':
+		end := strings.IndexByte(l.src[l.pos+1:], '\'')
+		if end < 0 {
+			return l.syntaxError("Unterminated quoted string")
+		}
+		l.copy(end + 2)
+	case c == '"':
+		l.copy(1)
+		for {
+			if l.pos == len(l.src) {
+				return l.syntaxError("Unterminated quoted string")
+			}
+			if l.src[l.pos] == '"' {
+				l.copy(1)
+				return nil
+			}
+			if err := l.quotedUnit(); err != nil {
+				return err
+			}
+		}
+	case c == '`':
+		l.copy(1)
+		for {
+			if l.pos == len(l.src) {
+				return l.syntaxError("EOF in backquote substitution")
+			}
+			switch l.src[l.pos] {
+			case '`':
+				l.copy(1)
+				return nil
+			case '\\':
+				l.escape()
+			default:
+				l.copy(1)
+			}
+		}
+	case c == '$' && strings.HasPrefix(l.src[l.pos:], "$(("):
+		l.copy(3)
+		return l.nested(')', 2, "Missing '))'")
+	case c == '$' && strings.HasPrefix(l.src[l.pos:], "$("):
+		l.copy(2)
+		return l.nested(')', 1, `end of file unexpected (expecting ")")`)
+	case c == '$' && strings.HasPrefix(l.src[l.pos:], "${"):
+		l.copy(2)
+		return l.nested('}', 1, "Missing '}'")
+	default:
+		l.copy(1)
+	}
+	return nil
+}
+
+// quotedUnit consumes a unit inside double quotes, where only escapes,
+// expansions and substitutions are special.
+func (l *wordLexer) quotedUnit() error {
+	switch c := l.src[l.pos]; {
+	case c == '\\':
+		l.escape()
+	case c == '$' || c == '`':
+		return l.unit()
+	default:
+		l.copy(1)
+	}
+	return nil
+}
+
+// nested consumes the rest of an expansion or substitution opened depth
+// times, up to and including its close bytes. msg is dash's message for
+// one left open.
+func (l *wordLexer) nested(close byte, depth int, msg string) error {
+	open := byte('(')
+	if close == '}' {
+		open = 0
+	}
+	for {
+		if l.pos == len(l.src) {
+			return l.syntaxError(msg)
+		}
+		switch c := l.src[l.pos]; c {
+		case close:
+			l.copy(1)
+			if depth--; depth == 0 {
+				return nil
+			}
+		case open:
+			l.copy(1)
+			depth++
+		default:
+			if err := l.unit(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// escape consumes a backslash and the character it quotes, dropping a
+// line continuation.
+func (l *wordLexer) escape() {
+	if strings.HasPrefix(l.src[l.pos:], "\\\n") {
+		l.pos += 2
+		return
+	}
+	l.copy(min(2, len(l.src)-l.pos))
+}
+
+// copy appends the next n bytes to the word.
+func (l *wordLexer) copy(n int) {
+	l.b.WriteString(l.src[l.pos : l.pos+n])
+	l.pos += n
+}
+
+// syntaxError returns the *SyntaxError for msg at the end of the line,
+// where dash reports a construct left open.
+func (l *wordLexer) syntaxError(msg string) error {
+	return &SyntaxError{Line: strings.Count(l.src, "\n") + 1, Message: msg}
+}
@@ -0,0 +1,75 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	ctx := context.Background()
+	d, stdout, _ := newTestDash(t)
+	splits := map[string]func(context.Context, string) ([]string, error){
+		"SplitWords":      SplitWords,
+		"Dash.SplitWords": d.SplitWords,
+	}
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  echo   a\tb ", []string{"echo", "a", "b"}},
+		{`echo 'a b' "c $d" e\ f`, []string{"echo", "'a b'", `"c $d"`, `e\ f`}},
+		{"a|b&&c||d;e&", []string{"a", "|", "b", "&&", "c", "||", "d", ";", "e", "&"}},
+		{"(a) ; b", []string{"(", "a", ")", ";", "b"}},
+		{"cat <in >>out 2>&1 2 >x <>rw", []string{"cat", "<", "in", ">>", "out", "2>&", "1", "2", ">", "x", "<>", "rw"}},
+		{"a\nb # comment | c\nd", []string{"a", "\n", "b", "\n", "d"}},
+		{"a#b #c", []string{"a#b"}},
+		{"echo long\\\nline \\\nnext", []string{"echo", "longline", "next"}},
+		{`echo $(ls "a b" | wc) ${x:-"y; z"} $((1 + (2)))`, []string{"echo", `$(ls "a b" | wc)`, `${x:-"y; z"}`, "$((1 + (2)))"}},
+		{"echo `a | b` \"`c`\"", []string{"echo", "`a | b`", "\"`c`\""}},
+		{`x="a;b" y=$z cmd`, []string{`x="a;b"`, "y=$z", "cmd"}},
+		{"case $x in a) b;; esac", []string{"case", "$x", "in", "a", ")", "b", ";;", "esac"}},
+	} {
+		for name, split := range splits {
+			got, err := split(ctx, tc.line)
+			if err != nil || !slices.Equal(got, tc.want) {
+				t.Errorf("%s(%q) = %q, %v; want %q", name, tc.line, got, err, tc.want)
+			}
+		}
+	}
+
+	// A construct left open fails as it does in dash's parser.
+	for _, line := range []string{"echo 'a", `echo "a`, "echo `a", "echo $(a", "echo $((1", "echo ${a", "a\necho 'b"} {
+		for name, split := range splits {
+			_, err := split(ctx, line)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("%s(%q) = %v, want a *SyntaxError", name, line, err)
+			}
+			if _, want := d.Eval(ctx, line); want == nil || err.Error() != want.Error() {
+				t.Errorf("%s(%q) = %v; dash reports %v", name, line, err, want)
+			}
+		}
+	}
+	for name, split := range splits {
+		if _, err := split(ctx, "cat <<EOF\nbody\nEOF"); err == nil || !strings.Contains(err.Error(), "here-document") {
+			t.Fatalf("%s: expected a here-document error, got %v", name, err)
+		}
+	}
+
+	// Joined tokens evaluate like the line.
+	line := `v='a  b'; printf '[%s]' "$v" ${v} 'c'\''d' && echo " ok"`
+	tokens, err := SplitWords(ctx, line)
+	if err != nil {
+		t.Fatal("SplitWords:", err)
+	}
+	for _, script := range []string{line, strings.Join(tokens, " ")} {
+		stdout.Reset()
+		if _, err := d.Eval(ctx, script); err != nil || stdout.String() != "[a  b][a][b][c'd] ok\n" {
+			t.Fatalf("%q printed %q, %v", script, stdout.String(), err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+// Workspace is a writable in-memory file system shared by short-lived
+// shells, so the steps of a pipeline cooperate through files while keeping
+// their interpreter state apart: variables, functions and options do not
+// carry over from one step to the next.
+//
+// A Workspace is safe for concurrent use. Steps running at the same time
+// see each other's writes.
+type Workspace struct {
+	fs         *memFS
+	mountPoint string
+	opts       []Option
+}
+
+// Step is a script run in a Workspace.
+type Step struct {
+	// Name identifies the step in errors.
+	Name string
+	Code string
+	// Options configure the step's shell after the workspace options, for
+	// example its environment with WithEnv.
+	Options []RunOption
+	// Limits bound the step's evaluation, see EvalWithLimits. The zero
+	// value sets no limits.
+	Limits Limits
+}
+
+// NewWorkspace returns a Workspace with an empty file system mounted at the
+// absolute guest path mountPoint, capping the file data it can hold at
+// maxBytes; zero disables the cap. opts configure the shell of every step.
+func NewWorkspace(mountPoint string, maxBytes int64, opts ...Option) (*Workspace, error) {
+	if !path.IsAbs(mountPoint) {
+		return nil, errors.New("dash: workspace mount point must be absolute: " + mountPoint)
+	}
+	return &Workspace{fs: newMemFS(maxBytes), mountPoint: path.Clean(mountPoint), opts: opts}, nil
+}
+
+// MountPoint returns the guest path of the workspace file system.
+func (w *Workspace) MountPoint() string {
+	return w.mountPoint
+}
+
+// RunStep runs step like Run in a new shell with the workspace mounted and
+// as its working directory, and releases the shell.
+func (w *Workspace) RunStep(ctx context.Context, step Step) (Result, error) {
+	opts := append(slices.Clip(w.opts), func(o *options) {
+		o.workspaces = append(o.workspaces, w)
+	})
+	d, err := NewDash(ctx, append(opts, step.Options...)...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		return Result{}, err
+	}
+	if status, err := d.eval(ctx, "cd "+Quote(w.mountPoint)); err != nil || status != 0 {
+		return Result{}, errors.Join(errors.New("dash: cannot enter the workspace"), err)
+	}
+
+	res := Result{Status: -1}
+	res.Stderr, err = d.captureStderr(func() error {
+		var err error
+		res.Stdout, err = d.captureStdout(func() error {
+			var err error
+			res.Status, err = d.EvalWithLimits(ctx, step.Code, step.Limits)
+			return err
+		})
+		return err
+	})
+	return res, err
+}
+
+// Run runs steps in order with RunStep. A step fails if it returns an error
+// or a non-zero exit status, reported as an *ExitError; the later steps are
+// then skipped with ErrSkipped. The results are in the order of steps.
+func (w *Workspace) Run(ctx context.Context, steps ...Step) ([]ScriptResult, error) {
+	results := make([]ScriptResult, len(steps))
+	var failure error
+	for i, step := range steps {
+		results[i].Name = step.Name
+		if failure != nil {
+			results[i].Err = ErrSkipped
+			continue
+		}
+		res, err := w.RunStep(ctx, step)
+		results[i].Result, results[i].Err = res, err
+		if err == nil && res.Status != 0 {
+			err = &ExitError{Status: res.Status}
+		}
+		if err != nil {
+			failure = fmt.Errorf("dash: step %s: %w", step.Name, err)
+		}
+	}
+	return results, failure
+}
+
+// ReadFile returns the contents of the file name, relative to the
+// workspace root, for example the output of the last step.
+func (w *Workspace) ReadFile(name string) ([]byte, error) {
+	f, errno := w.fs.OpenFile(name, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errno}
+	}
+	defer f.Close()
+	var data []byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, errno := f.Read(buf)
+		data = append(data, buf[:n]...)
+		if errno != 0 {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: errno}
+		}
+		if n == 0 {
+			return data, nil
+		}
+	}
+}
+
+// WriteFile writes data to the file name, relative to the workspace root,
+// creating it with perm or truncating it. Its directory must exist; see
+// MkdirAll.
+func (w *Workspace) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, errno := w.fs.OpenFile(name, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_TRUNC, perm)
+	if errno != 0 {
+		return &fs.PathError{Op: "open", Path: name, Err: errno}
+	}
+	defer f.Close()
+	for len(data) > 0 {
+		n, errno := f.Write(data)
+		if errno != 0 {
+			return &fs.PathError{Op: "write", Path: name, Err: errno}
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// MkdirAll creates the directory name, relative to the workspace root,
+// with any missing parents.
+func (w *Workspace) MkdirAll(name string, perm fs.FileMode) error {
+	dir := ""
+	for _, elem := range splitPath(name) {
+		dir = path.Join(dir, elem)
+		if errno := w.fs.Mkdir(dir, perm); errno != 0 && errno != experimentalsys.EEXIST {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errno}
+		}
+	}
+	return nil
+}
+
+// Usage returns the storage used by the workspace file system.
+func (w *Workspace) Usage() DirUsage {
+	bytes, files := w.fs.usage()
+	return DirUsage{Bytes: bytes, Files: files, MaxBytes: w.fs.maxBytes}
+}
@@ -0,0 +1,68 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkspace(t *testing.T) {
+	ctx := context.Background()
+	// Builtins cannot write to redirected files in the reactor, but the
+	// redirections create them.
+	w, err := NewWorkspace("/work", 1<<20, WithEnv("STAGE", "ci"), WithInitScript(`touch() { for f; do true >"$f" || :; done; }`))
+	if err != nil {
+		t.Fatal("NewWorkspace:", err)
+	}
+	if err := w.MkdirAll("in/data", 0o755); err != nil {
+		t.Fatal("MkdirAll:", err)
+	}
+	if err := w.WriteFile("in/data/name", []byte("world\n"), 0o644); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+
+	results, err := w.Run(ctx,
+		Step{Name: "produce", Code: `x=leaked; test -s in/data/name && touch "ok-$STAGE-$MODE"`, Options: []RunOption{WithEnv("MODE", "fast")}},
+		Step{Name: "consume", Code: `echo "$PWD x=$x MODE=$MODE"; test -f ok-ci-fast && echo found`},
+	)
+	if err != nil {
+		t.Fatal("Run:", err)
+	}
+	if got, want := string(results[1].Stdout), "/work x= MODE=\nfound\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	data, err := w.ReadFile("in/data/name")
+	if err != nil || string(data) != "world\n" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	if u := w.Usage(); u.Files != 4 || u.Bytes != 6 || u.MaxBytes != 1<<20 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	// A failing step skips the later ones.
+	results, err = w.Run(ctx,
+		Step{Name: "fail", Code: "touch partial; false"},
+		Step{Name: "after", Code: "touch after"},
+	)
+	if !errors.As(err, new(*ExitError)) {
+		t.Fatalf("expected an ExitError, got %v", err)
+	}
+	if results[0].Status != 1 || !errors.Is(results[1].Err, ErrSkipped) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	res, err := w.RunStep(ctx, Step{Code: "echo partial* after*"})
+	if err != nil || string(res.Stdout) != "partial after*\n" {
+		t.Fatalf("expected the writes of the failed step only, got %q, %v", res.Stdout, err)
+	}
+
+	// Limits apply per step.
+	_, err = w.RunStep(ctx, Step{Name: "spin", Code: "while :; do :; done", Limits: Limits{MaxWallTime: 50 * time.Millisecond}})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	if _, err := NewWorkspace("work", 0); err == nil {
+		t.Fatal("expected an error for a relative mount point")
+	}
+}
@@ -0,0 +1,178 @@
+package dash
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// YieldConfig configures cooperative yield points. See WithYield.
+type YieldConfig struct {
+	// Interval is the time an Eval runs between calls to Func.
+	Interval time.Duration
+
+	// Func is called from the goroutine running Eval, at the first yield
+	// point after each Interval. It may block, for example until a
+	// Scheduler gives the Eval another turn. A non-nil error aborts the
+	// evaluation, which is rolled back and returns a *YieldError.
+	Func func(ctx context.Context) error
+}
+
+// WithYield inserts cooperative yield points into a running Eval, so a host
+// running many shells on limited CPU can share it fairly instead of letting
+// one hot loop starve the others. Yield points come before each command the
+// shell runs, including builtins in loops such as while :; do :; done.
+// Time spent blocked in host commands counts towards the interval.
+func WithYield(config YieldConfig) Option {
+	return func(o *options) {
+		o.yield = &config
+	}
+}
+
+// YieldError reports an evaluation aborted by YieldConfig.Func.
+type YieldError struct {
+	Err error
+}
+
+// Error implements error.
+func (e *YieldError) Error() string {
+	return "dash: yield: " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by YieldConfig.Func.
+func (e *YieldError) Unwrap() error {
+	return e.Err
+}
+
+// yieldState tracks the yield points of the running Eval.
+type yieldState struct {
+	config YieldConfig
+
+	active bool
+	last   time.Time
+	// err is set when config.Func aborted the evaluation.
+	err *YieldError
+}
+
+// begin starts the yield interval for an Eval. The returned func stops.
+func (y *yieldState) begin() func() {
+	y.active = true
+	y.last = time.Now()
+	y.err = nil
+	return func() { y.active = false }
+}
+
+// point is a yield point, calling config.Func if the interval has passed.
+// It aborts the guest call if config.Func fails.
+func (y *yieldState) point(ctx context.Context) {
+	if !y.active || time.Since(y.last) < y.config.Interval {
+		return
+	}
+	err := y.config.Func(ctx)
+	y.last = time.Now()
+	if err != nil {
+		y.active = false
+		y.err = &YieldError{Err: err}
+		panic(y.err)
+	}
+}
+
+// Scheduler shares a fixed number of run slots fairly between the Evals of
+// many shells. An Eval holds a slot from Acquire to Release, and passing
+// Yield as YieldConfig.Func hands its slot to the longest waiting Eval at
+// each interval:
+//
+//	s := dash.NewScheduler(runtime.GOMAXPROCS(0))
+//	d, _ := dash.NewDash(ctx, dash.WithYield(dash.YieldConfig{
+//		Interval: 10 * time.Millisecond,
+//		Func:     s.Yield,
+//	}))
+//	if err := s.Acquire(ctx); err != nil {
+//		return err
+//	}
+//	defer s.Release()
+//	status, err := d.Eval(ctx, script)
+//
+// A Scheduler is safe for concurrent use.
+type Scheduler struct {
+	mu      sync.Mutex
+	free    int
+	waiters []chan struct{}
+	// debt counts the slots given up by failed Yields, whose holders
+	// still call Release.
+	debt int
+}
+
+// NewScheduler returns a Scheduler with the given number of slots.
+func NewScheduler(slots int) *Scheduler {
+	return &Scheduler{free: max(slots, 1)}
+}
+
+// Acquire waits for a free slot, in the order callers began waiting. It
+// returns an error wrapping ctx.Err() if ctx is done first.
+func (s *Scheduler) Acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiters) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiters = append(s.waiters, ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		i := slices.Index(s.waiters, ch)
+		if i >= 0 {
+			s.waiters = slices.Delete(s.waiters, i, i+1)
+		}
+		s.mu.Unlock()
+		if i < 0 {
+			// The slot was handed over meanwhile; pass it on.
+			s.Release()
+		}
+		return interruptedError(ctx)
+	}
+}
+
+// Release frees a slot taken by Acquire, handing it to the longest waiting
+// caller.
+func (s *Scheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.debt > 0 {
+		s.debt--
+		return
+	}
+	if len(s.waiters) == 0 {
+		s.free++
+		return
+	}
+	close(s.waiters[0])
+	s.waiters = s.waiters[1:]
+}
+
+// Yield lets the callers waiting for a slot run before resuming with one.
+// It returns immediately if nobody is waiting. If ctx is done before a slot
+// is free, the caller is left without one but should still call Release.
+func (s *Scheduler) Yield(ctx context.Context) error {
+	s.mu.Lock()
+	waiting := len(s.waiters) != 0
+	s.mu.Unlock()
+	if !waiting {
+		return nil
+	}
+	s.Release()
+	if err := s.Acquire(ctx); err != nil {
+		s.mu.Lock()
+		s.debt++
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
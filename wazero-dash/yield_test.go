@@ -0,0 +1,125 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const yieldLoop = "i=0; while [ $i -lt 2000 ]; do i=$((i+1)); done"
+
+func TestYield(t *testing.T) {
+	ctx := context.Background()
+	var calls int
+	errStop := errors.New("stop")
+	var stopAt int
+	d, err := NewDash(ctx, WithYield(YieldConfig{
+		Interval: time.Millisecond,
+		Func: func(context.Context) error {
+			if calls++; calls == stopAt {
+				return errStop
+			}
+			return nil
+		},
+	}))
+	if err != nil {
+		t.Fatal("NewDash:", err)
+	}
+	defer d.Close(ctx)
+	if err := d.Init(ctx, nil); err != nil {
+		t.Fatal("Init:", err)
+	}
+
+	if status, err := d.Eval(ctx, yieldLoop); err != nil || status != 0 {
+		t.Fatalf("Eval = %d, %v", status, err)
+	}
+	if calls == 0 {
+		t.Fatal("expected yields during the loop")
+	}
+
+	// An error from Func aborts the evaluation and rolls it back.
+	calls, stopAt = 0, 2
+	_, err = d.Eval(ctx, "x=changed; "+yieldLoop)
+	var ye *YieldError
+	if !errors.As(err, &ye) || !errors.Is(err, errStop) {
+		t.Fatalf("expected a YieldError wrapping errStop, got %v", err)
+	}
+	stopAt = 0
+	if v, err := d.GetVar(ctx, "x"); err != nil || v != "" {
+		t.Fatalf("x = %q, %v; want it rolled back", v, err)
+	}
+
+	// Short evaluations do not yield.
+	calls = 0
+	if _, err := d.Eval(ctx, "true"); err != nil || calls != 0 {
+		t.Fatalf("Eval(true) = %v with %d yields", err, calls)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	s := NewScheduler(1)
+
+	// Two shells sharing one slot take turns at each interval.
+	var mu sync.Mutex
+	var turns []int
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for id := range 2 {
+		d, err := NewDash(ctx, WithYield(YieldConfig{
+			Interval: time.Millisecond,
+			Func: func(ctx context.Context) error {
+				err := s.Yield(ctx)
+				mu.Lock()
+				turns = append(turns, id)
+				mu.Unlock()
+				return err
+			},
+		}))
+		if err != nil {
+			t.Fatal("NewDash:", err)
+		}
+		defer d.Close(ctx)
+		if err := d.Init(ctx, nil); err != nil {
+			t.Fatal("Init:", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[id] = s.Acquire(ctx); errs[id] != nil {
+				return
+			}
+			defer s.Release()
+			_, errs[id] = d.Eval(ctx, yieldLoop)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal("Eval:", err)
+	}
+	var switches int
+	for i := 1; i < len(turns); i++ {
+		if turns[i] != turns[i-1] {
+			switches++
+		}
+	}
+	if switches < 2 {
+		t.Fatalf("expected the shells to take turns, got %v", turns)
+	}
+
+	// Waiting for a slot stops with the context.
+	if err := s.Acquire(ctx); err != nil {
+		t.Fatal("Acquire:", err)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	s.Release()
+	if err := s.Acquire(ctx); err != nil {
+		t.Fatal("Acquire after Release:", err)
+	}
+}